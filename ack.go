@@ -0,0 +1,289 @@
+// ack.go
+//
+// Acknowledge button (flag --ack, "ack" of relayed notifications). The card
+// of the message gets an Acknowledge button, a message without card is sent
+// as a card with the text of the message:
+//
+//	notify_by_webex_teams -t KMP-Team -r Alerts -m "**disk full** on db-1" --severity critical --ack
+//
+// The relay (serve --ack) handles the button: when the attachmentActions
+// webhook event of the button arrives on /webhook, the card is replaced by
+// the card without the button and with who acknowledged it and when. Webex
+// can not edit cards, so the card is posted again and the old card is
+// deleted. Webex has no API to list card submissions, so there is no
+// polling: the webhook has to be created, e.g.
+//
+//	notify_by_webex_teams webhooks create --resource attachmentActions --target-url https://relay.example.com/webhook
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+const (
+	// input of the submission of the Acknowledge button, with the value ackValue
+	ackInput = "notify_by_webex_teams"
+	ackValue = "acknowledge"
+	// time a handled card is remembered, to ignore further clicks
+	ackKeep = 24 * time.Hour
+)
+
+// withAckButton adds the Acknowledge button to the card of n if n.Ack is
+// set and clears n.Ack, so a spooled or retried n gets no second button. A
+// message without card gets a card with the text of the message.
+func withAckButton(n *notification) error {
+	if !n.Ack {
+		return nil
+	}
+	if len(notificationFiles(n)) > 0 {
+		return errors.New("--ack can not be used with files")
+	}
+	card := n.Card
+	if len(card) == 0 {
+		if len(strings.TrimSpace(n.Markdown)) == 0 {
+			return errors.New("--ack needs a message or card")
+		}
+		text := n.Markdown
+		if !noEmoji {
+			text = expandEmoji(text)
+		}
+		data, err := json.Marshal(map[string]interface{}{
+			"type":    "AdaptiveCard",
+			"version": defaultCardVersion,
+			"body": []interface{}{
+				map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		card = string(data)
+	}
+	card, err := normalizeCard(card)
+	if err != nil {
+		return err
+	}
+	attachment, content, err := decodeCard(card)
+	if err != nil {
+		return err
+	}
+	actions, _ := content["actions"].([]interface{})
+	content["actions"] = append(actions, map[string]interface{}{
+		"type":  "Action.Submit",
+		"title": tr("Acknowledge"),
+		"data":  map[string]interface{}{ackInput: ackValue},
+	})
+	data, err := json.Marshal(attachment)
+	if err != nil {
+		return err
+	}
+	if n.Card, err = normalizeCard(string(data)); err != nil {
+		return err
+	}
+	n.Ack = false
+	return nil
+}
+
+// hasAckButton reports whether the card has the Acknowledge button of
+// withAckButton.
+func hasAckButton(card string) bool {
+	if len(card) == 0 {
+		return false
+	}
+	_, content, err := decodeCard(card)
+	if err != nil {
+		return false
+	}
+	actions, _ := content["actions"].([]interface{})
+	for _, action := range actions {
+		action, _ := action.(map[string]interface{})
+		data, _ := action["data"].(map[string]interface{})
+		if data[ackInput] == ackValue {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeCard returns the card attachment and its content.
+func decodeCard(card string) (map[string]interface{}, map[string]interface{}, error) {
+	var attachment map[string]interface{}
+	dec := json.NewDecoder(strings.NewReader(card))
+	dec.UseNumber()
+	if err := dec.Decode(&attachment); err != nil {
+		return nil, nil, err
+	}
+	content, _ := attachment["content"].(map[string]interface{})
+	if content == nil {
+		return nil, nil, errors.New("invalid card attachment")
+	}
+	return attachment, content, nil
+}
+
+// acknowledger handles the Acknowledge buttons of serve --ack.
+type acknowledger struct {
+	webex *webex.Client
+	mu    sync.Mutex
+	// handled cards by message ID, with the time of the acknowledgement
+	handled map[string]time.Time
+	// acknowledgements in progress, waited for on shutdown
+	running sync.WaitGroup
+}
+
+// acks is the acknowledger of serve --ack, nil without.
+var acks *acknowledger
+
+// newAcknowledger returns the acknowledger of serve --ack.
+func newAcknowledger() (*acknowledger, error) {
+	client, err := webexClient()
+	if err != nil {
+		return nil, err
+	}
+	return &acknowledger{webex: client, handled: make(map[string]time.Time)}, nil
+}
+
+// handle acknowledges the card of an attachmentActions webhook event if the
+// Acknowledge button was pressed.
+func (a *acknowledger) handle(ev *webex.WebhookEvent) {
+	var data struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(ev.Data, &data); err != nil || len(data.ID) == 0 {
+		slog.Warn("ack: invalid attachmentActions event", "error", err)
+		return
+	}
+	a.running.Add(1)
+	go func() {
+		defer a.running.Done()
+		ctx, cancel := requestContext()
+		defer cancel()
+		if err := a.acknowledge(ctx, data.ID); err != nil {
+			slog.Error("acknowledging the card failed", "actionID", data.ID, "error", err)
+		}
+	}()
+}
+
+// acknowledge replaces the card of the submission by the acknowledged card.
+func (a *acknowledger) acknowledge(ctx context.Context, actionID string) error {
+	action, err := a.webex.GetAttachmentAction(ctx, actionID)
+	if err != nil {
+		return err
+	}
+	if action.Inputs[ackInput] != ackValue {
+		return nil
+	}
+	if !a.claim(action.MessageID) {
+		slog.Debug("card already acknowledged", "messageID", action.MessageID)
+		return nil
+	}
+	old, err := a.webex.GetMessage(ctx, action.MessageID)
+	if err != nil {
+		a.release(action.MessageID)
+		return err
+	}
+	if len(old.Attachments) == 0 {
+		a.release(action.MessageID)
+		return fmt.Errorf("message %s has no card", action.MessageID)
+	}
+	by := action.PersonID
+	if p, err := a.webex.GetPerson(ctx, action.PersonID); err == nil {
+		by = p.DisplayName
+	} else {
+		slog.Warn("looking up the person of the acknowledgement failed", "personID", action.PersonID, "error", err)
+	}
+	at := action.Created
+	if at.IsZero() {
+		at = time.Now()
+	}
+	text := trf("Acknowledged by %s at %s", by, formatTime(at.Local()))
+
+	card, err := acknowledgedCard(string(old.Attachments[0]), text)
+	if err != nil {
+		a.release(action.MessageID)
+		return err
+	}
+	m, err := a.webex.CreateMessage(ctx, &webex.MessageRequest{
+		RoomID:      old.RoomID,
+		ParentID:    old.ParentID,
+		Markdown:    strings.TrimSpace(old.Markdown + "\n\n✅ " + text),
+		Attachments: []json.RawMessage{json.RawMessage(card)},
+	})
+	if err != nil {
+		a.release(action.MessageID)
+		return err
+	}
+	if err := a.webex.DeleteMessage(ctx, old.ID); err != nil {
+		slog.Warn("deleting the acknowledged card failed", "messageID", old.ID, "error", err)
+	}
+	slog.Info("card acknowledged", "messageID", m.ID, "by", by)
+	return nil
+}
+
+// claim marks the card of the message as handled, false if it already is.
+func (a *acknowledger) claim(messageID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, t := range a.handled {
+		if time.Since(t) > ackKeep {
+			delete(a.handled, id)
+		}
+	}
+	if _, ok := a.handled[messageID]; ok {
+		return false
+	}
+	a.handled[messageID] = time.Now()
+	return true
+}
+
+// release forgets the card of the message after a failed acknowledgement,
+// so the button can be pressed again.
+func (a *acknowledger) release(messageID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.handled, messageID)
+}
+
+// acknowledgedCard returns card without the Acknowledge button and with
+// text in a green container at the end.
+func acknowledgedCard(card, text string) (string, error) {
+	attachment, content, err := decodeCard(card)
+	if err != nil {
+		return "", err
+	}
+	var actions []interface{}
+	list, _ := content["actions"].([]interface{})
+	for _, action := range list {
+		if a, ok := action.(map[string]interface{}); ok {
+			if data, ok := a["data"].(map[string]interface{}); ok && data[ackInput] == ackValue {
+				continue
+			}
+		}
+		actions = append(actions, action)
+	}
+	if len(actions) > 0 {
+		content["actions"] = actions
+	} else {
+		delete(content, "actions")
+	}
+	body, _ := content["body"].([]interface{})
+	content["body"] = append(body, map[string]interface{}{
+		"type": "Container", "style": "good", "bleed": true,
+		"items": []interface{}{
+			map[string]interface{}{"type": "TextBlock", "text": "✅ " + text, "weight": "Bolder", "wrap": true},
+		},
+	})
+	data, err := json.Marshal(attachment)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestWithAckButton(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		n        notification
+		wantCard []string
+		wantErr  string
+	}{
+		{name: "no ack", n: notification{Markdown: "disk full"}},
+		{name: "message", n: notification{Markdown: "**disk full** :fire:", Ack: true},
+			wantCard: []string{`"text":"**disk full** 🔥"`, `{"data":{"notify_by_webex_teams":"acknowledge"},"title":"Acknowledge","type":"Action.Submit"}`}},
+		{name: "card", n: notification{Markdown: "disk full", Card: testCard, Ack: true},
+			wantCard: []string{`"text":"disk full"`, `"title":"Acknowledge"`}},
+		{name: "files", n: notification{Markdown: "disk full", File: "report.pdf", Ack: true}, wantErr: "--ack can not be used with files"},
+		{name: "empty", n: notification{Markdown: " ", Ack: true}, wantErr: "--ack needs a message or card"},
+	} {
+		err := withAckButton(&tt.n)
+		if len(tt.wantErr) > 0 {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("%s: withAckButton() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || tt.n.Ack {
+			t.Errorf("%s: withAckButton() = %v, ack %v", tt.name, err, tt.n.Ack)
+		}
+		for _, want := range tt.wantCard {
+			if !strings.Contains(tt.n.Card, want) {
+				t.Errorf("%s: withAckButton() card = %s, want %s", tt.name, tt.n.Card, want)
+			}
+		}
+		if got := hasAckButton(tt.n.Card); got != (len(tt.wantCard) > 0) {
+			t.Errorf("%s: hasAckButton() = %v", tt.name, got)
+		}
+	}
+	if hasAckButton(`{"content": []}`) {
+		t.Error("hasAckButton() of an invalid card = true")
+	}
+}
+
+func TestAcknowledgedCard(t *testing.T) {
+	n := &notification{Card: `{"type": "AdaptiveCard", "version": "1.3", "body": [{"type": "TextBlock", "text": "disk full"}],
+		"actions": [{"type": "Action.OpenUrl", "title": "Runbook", "url": "https://wiki.example.com/disk"}]}`, Ack: true}
+	if err := withAckButton(n); err != nil {
+		t.Fatal(err)
+	}
+	card, err := acknowledgedCard(n.Card, "Acknowledged by Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasAckButton(card) || !strings.Contains(card, `"title":"Runbook"`) || !strings.Contains(card, `"text":"✅ Acknowledged by Alice"`) {
+		t.Errorf("acknowledgedCard() = %s, want the runbook button and the acknowledgement", card)
+	}
+
+	card, err = acknowledgedCard(`{"contentType": "application/vnd.microsoft.card.adaptive", "content": {"type": "AdaptiveCard", "version": "1.3",
+		"actions": [{"type": "Action.Submit", "title": "Acknowledge", "data": {"notify_by_webex_teams": "acknowledge"}}]}}`, "Acknowledged by Alice")
+	if err != nil || strings.Contains(card, "actions") {
+		t.Errorf("acknowledgedCard() = %s, %v, want no actions", card, err)
+	}
+}
+
+func TestAcknowledge(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddPerson("alice@example.com", "Alice")
+	room := fake.AddRoom("Alerts", "", "group")
+	a, err := newAcknowledger()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &notification{Markdown: "disk full", Ack: true}
+	if err := withAckButton(n); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	msg, err := a.webex.CreateMessage(ctx, &webex.MessageRequest{RoomID: room.ID, Markdown: n.Markdown, Attachments: []json.RawMessage{json.RawMessage(n.Card)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := fake.AddAttachmentAction(msg.ID, "alice@example.com", map[string]interface{}{"comment": "on it"})
+	if err := a.acknowledge(ctx, other.ID); err != nil || len(fake.Messages()) != 1 {
+		t.Fatalf("acknowledge() of another submission = %v, %d messages", err, len(fake.Messages()))
+	}
+
+	action := fake.AddAttachmentAction(msg.ID, "alice@example.com", map[string]interface{}{ackInput: ackValue})
+	if err := a.acknowledge(ctx, action.ID); err != nil {
+		t.Fatal(err)
+	}
+	messages := fake.Messages()
+	if len(messages) != 1 || messages[0].ID == msg.ID {
+		t.Fatalf("messages = %+v, want the acknowledged card only", messages)
+	}
+	if !strings.HasPrefix(messages[0].Markdown, "disk full\n\n✅ Acknowledged by Alice at ") || hasAckButton(string(messages[0].Attachments[0])) {
+		t.Errorf("acknowledged message = %q with card %s", messages[0].Markdown, messages[0].Attachments[0])
+	}
+
+	// a second click on the deleted card is ignored
+	again := fake.AddAttachmentAction(msg.ID, "alice@example.com", map[string]interface{}{ackInput: ackValue})
+	if err := a.acknowledge(ctx, again.ID); err != nil || len(fake.Messages()) != 1 {
+		t.Errorf("acknowledge() of a handled card = %v, %d messages", err, len(fake.Messages()))
+	}
+}
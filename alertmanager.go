@@ -0,0 +1,333 @@
+// alertmanager.go
+//
+// Prometheus Alertmanager and Grafana alerting webhooks (serve
+// --alertmanager, --grafana). The notifications of an Alertmanager webhook
+// receiver POSTed to /alertmanager and of a Grafana webhook contact point
+// POSTed to /grafana are posted as cards listing the alerts of the group:
+// colored by severity while firing, green when resolved. Grafana sends the
+// payload of Alertmanager with a title and a message. The fields used are:
+//
+//	{
+//	  "receiver": "webex",
+//	  "status": "firing",
+//	  "alerts": [
+//	    { "status": "firing", "labels": { "alertname": "HighCPU", "instance": "web-1" },
+//	      "annotations": { "summary": "CPU above 90%" }, "startsAt": "2026-10-15T08:00:00Z",
+//	      "generatorURL": "https://prometheus.example.com/graph?g0.expr=..." }
+//	  ],
+//	  "groupLabels": { "alertname": "HighCPU" },
+//	  "commonLabels": { "alertname": "HighCPU", "severity": "critical" },
+//	  "commonAnnotations": { "summary": "CPU above 90%" },
+//	  "externalURL": "https://alertmanager.example.com",
+//	  "title": "[FIRING:1] HighCPU",
+//	  "message": "..."
+//	}
+//
+// The alerts go to -t/-r or -D, or to the room of the first rule of the
+// config file matching the event (see rules.go). The label severity selects
+// the severity of the card if it is a known severity (see severity.go),
+// other firing alerts are critical. Neither Alertmanager nor Grafana sign
+// webhooks. With --alertmanager-secret or --grafana-secret only requests
+// with the secret as bearer token in the Authorization header are accepted,
+// set as authorization of the http_config of the receiver or as
+// credentials of the contact point.
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// alerts of a group listed on the card
+	alertmanagerMaxAlerts = 10
+	// length of the Grafana message on the card
+	alertmanagerMaxMessage = 1000
+)
+
+// alertmanagerAlert is an alert of an Alertmanager or Grafana webhook.
+type alertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+}
+
+// alertmanagerGroup is the payload of an Alertmanager or Grafana webhook,
+// the alerts of a group.
+type alertmanagerGroup struct {
+	Receiver          string              `json:"receiver"`
+	Status            string              `json:"status"`
+	Alerts            []alertmanagerAlert `json:"alerts"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	// Grafana only
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+// alertReceiver is the receiver of serve --alertmanager or --grafana.
+type alertReceiver struct {
+	name    string
+	display string
+	secret  *string
+}
+
+var (
+	alertmanagerReceiver = alertReceiver{name: "alertmanager", display: "Alertmanager", secret: &alertmanagerSecret}
+	grafanaReceiver      = alertReceiver{name: "grafana", display: "Grafana", secret: &grafanaSecret}
+)
+
+// checkAlertmanagerRoute returns an error if the alerts of the source have
+// no room: neither -t nor -D nor a rule with a room.
+func checkAlertmanagerRoute(source string) error {
+	if len(teamName) > 0 || len(emailAddr) > 0 {
+		return nil
+	}
+	for _, r := range eventRules {
+		if (len(r.Source) == 0 || r.Source == source) && r.route() != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("--%s needs flag -t or -D or a rule with a room in the config file (-c)", source)
+}
+
+// handler returns the handler of the webhooks of the source.
+func (s alertReceiver) handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !allowedSource(r) {
+			slog.Warn(s.display+" alert of a source not allowed rejected", "remote", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if len(*s.secret) > 0 && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+*s.secret)) != 1 {
+			slog.Warn(s.display+" alert without valid secret rejected", "remote", r.RemoteAddr)
+			http.Error(w, "invalid secret", http.StatusUnauthorized)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+		if err != nil {
+			http.Error(w, "invalid "+s.display+" alert: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var g alertmanagerGroup
+		if err := json.Unmarshal(body, &g); err != nil || len(g.Alerts) == 0 {
+			http.Error(w, "invalid "+s.display+" alert", http.StatusBadRequest)
+			return
+		}
+		title := g.title()
+		n := s.notification(&g)
+		if n == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if len(n.TeamName) == 0 && len(n.Email) == 0 {
+			slog.Warn(s.display+" alert without room ignored", "title", title, "receiver", g.Receiver)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if digestWindow > 0 {
+			queueDigest(n)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		ctx, cancel := requestContext()
+		defer cancel()
+		if err := sendOrSpool(ctx, n); err != nil {
+			slog.Error("posting "+s.display+" alert failed", "title", title, "error", err)
+			http.Error(w, redact(err.Error()), http.StatusBadGateway)
+			return
+		}
+		slog.Info(s.display+" alert posted", "title", title, "team", n.TeamName, "room", n.RoomName, "email", n.Email)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// firing returns the number of firing alerts of the group.
+func (g *alertmanagerGroup) firing() int {
+	count := 0
+	for _, a := range g.Alerts {
+		if a.Status != "resolved" {
+			count++
+		}
+	}
+	return count
+}
+
+// title returns the title of Grafana or one like it, e.g.
+// "[FIRING:2] HighCPU web".
+func (g *alertmanagerGroup) title() string {
+	if len(g.Title) > 0 {
+		return g.Title
+	}
+	var values []string
+	for _, key := range sortedKeys(g.GroupLabels) {
+		values = append(values, g.GroupLabels[key])
+	}
+	if len(values) == 0 {
+		values = append(values, g.CommonLabels["alertname"])
+	}
+	status := "RESOLVED"
+	if g.Status != "resolved" {
+		status = fmt.Sprintf("FIRING:%d", g.firing())
+	}
+	return strings.TrimSpace(fmt.Sprintf("[%s] %s", status, strings.Join(values, " ")))
+}
+
+// fields returns the fields of the group for the rules: status, receiver,
+// alertname, severity, title, labels.<name> of the common labels and
+// annotations.<name> of the common annotations.
+func (g *alertmanagerGroup) fields() map[string]string {
+	fields := map[string]string{"status": g.Status, "receiver": g.Receiver, "alertname": g.CommonLabels["alertname"],
+		"severity": g.CommonLabels["severity"], "title": g.title()}
+	for key, value := range g.CommonLabels {
+		fields["labels."+key] = value
+	}
+	for key, value := range g.CommonAnnotations {
+		fields["annotations."+key] = value
+	}
+	return fields
+}
+
+// notification returns the notification of the group, nil if the group is
+// suppressed by a rule (see rules.go).
+func (s alertReceiver) notification(g *alertmanagerGroup) *notification {
+	n := &notification{TeamName: teamName, RoomName: roomName, Email: emailAddr, Mentions: mentionEmails, Severity: severityName, Ack: ackButton}
+	resolved := g.Status == "resolved"
+	// the severity colors the card
+	if len(n.Severity) == 0 && !resolved {
+		n.Severity = "critical"
+		if severity := g.CommonLabels["severity"]; len(severity) > 0 {
+			if _, err := lookupSeverity(severity); err == nil {
+				n.Severity = strings.ToLower(severity)
+			}
+		}
+	}
+
+	title := g.title()
+	n.Markdown = "**" + title + "**"
+	if resolved {
+		n.Markdown = "✅ " + n.Markdown
+	}
+	card, err := s.card(g, resolved && len(n.Severity) == 0)
+	if err != nil {
+		slog.Error("building the "+s.display+" card failed", "title", title, "error", err)
+	}
+	n.Card = card
+
+	post, err := applyEventRule(s.name, g.fields(), n)
+	if err != nil {
+		slog.Error("applying the rule to the "+s.display+" alert failed", "title", title, "error", err)
+	}
+	if !post {
+		return nil
+	}
+	return n
+}
+
+// alertSummary returns the summary of an alert: its summary or description
+// annotation, or the alert name.
+func alertSummary(a *alertmanagerAlert) string {
+	for _, key := range []string{"summary", "description"} {
+		if text := a.Annotations[key]; len(text) > 0 {
+			return text
+		}
+	}
+	return a.Labels["alertname"]
+}
+
+// card returns the card attachment of the group, green if good.
+func (s alertReceiver) card(g *alertmanagerGroup, good bool) (string, error) {
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": s.display, "isSubtle": true},
+		map[string]interface{}{"type": "TextBlock", "text": g.title(), "size": "Large", "weight": "Bolder", "wrap": true},
+	}
+	if message := strings.TrimSpace(g.Message); len(message) > 0 {
+		if utf8.RuneCountInString(message) > alertmanagerMaxMessage {
+			message = string([]rune(message)[:alertmanagerMaxMessage-1]) + "…"
+		}
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": message, "wrap": true})
+	}
+
+	var facts []interface{}
+	for _, key := range sortedKeys(g.CommonLabels) {
+		if key != "alertname" && len(g.CommonLabels[key]) > 0 {
+			facts = append(facts, map[string]interface{}{"title": key, "value": g.CommonLabels[key]})
+		}
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+
+	for i := range g.Alerts {
+		if i == alertmanagerMaxAlerts {
+			body = append(body, map[string]interface{}{"type": "TextBlock", "text": trf("… and %d more", len(g.Alerts)-i), "isSubtle": true})
+			break
+		}
+		a := &g.Alerts[i]
+		icon := "🔥"
+		if a.Status == "resolved" {
+			icon = "✅"
+		}
+		// the labels which differ between the alerts, e.g. the instance
+		var labels []string
+		for _, key := range sortedKeys(a.Labels) {
+			if _, common := g.CommonLabels[key]; !common {
+				labels = append(labels, key+"="+a.Labels[key])
+			}
+		}
+		items := []interface{}{
+			map[string]interface{}{"type": "TextBlock", "text": icon + " " + alertSummary(a), "weight": "Bolder", "wrap": true},
+		}
+		var details []string
+		if len(labels) > 0 {
+			details = append(details, strings.Join(labels, ", "))
+		}
+		if !a.StartsAt.IsZero() {
+			details = append(details, trf("since %s", formatTime(a.StartsAt.Local())))
+		}
+		if len(details) > 0 {
+			items = append(items, map[string]interface{}{"type": "TextBlock", "text": strings.Join(details, " · "), "isSubtle": true, "spacing": "None", "wrap": true})
+		}
+		container := map[string]interface{}{"type": "Container", "items": items}
+		if safeURL(a.GeneratorURL, "http", "https") {
+			container["selectAction"] = map[string]interface{}{"type": "Action.OpenUrl", "url": a.GeneratorURL}
+		}
+		body = append(body, container)
+	}
+
+	if good {
+		body = []interface{}{
+			map[string]interface{}{"type": "Container", "style": "good", "bleed": true, "items": body},
+		}
+	}
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": defaultCardVersion,
+		"body":    body,
+	}
+	if safeURL(g.ExternalURL, "http", "https") {
+		card["actions"] = []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": trf("Open in %s", s.display), "url": g.ExternalURL},
+		}
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
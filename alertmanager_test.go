@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const alertmanagerFiring = `{
+  "receiver": "webex",
+  "status": "firing",
+  "alerts": [
+    { "status": "firing", "labels": { "alertname": "HighCPU", "team": "payments", "instance": "web-1" },
+      "annotations": { "summary": "CPU above 90% on web-1" }, "startsAt": "2026-10-15T08:00:00Z",
+      "generatorURL": "https://prometheus.example.com/graph" },
+    { "status": "firing", "labels": { "alertname": "HighCPU", "team": "payments", "instance": "web-2" },
+      "annotations": {}, "startsAt": "2026-10-15T08:01:00Z", "generatorURL": "javascript:alert(1)" }
+  ],
+  "groupLabels": { "alertname": "HighCPU" },
+  "commonLabels": { "alertname": "HighCPU", "team": "payments" },
+  "commonAnnotations": {},
+  "externalURL": "https://alertmanager.example.com"
+}`
+
+func TestAlertmanagerGroup(t *testing.T) {
+	for _, tt := range []struct {
+		g         alertmanagerGroup
+		wantTitle string
+	}{
+		{alertmanagerGroup{Status: "firing", Alerts: []alertmanagerAlert{{Status: "firing"}, {Status: "resolved"}, {Status: "firing"}},
+			GroupLabels: map[string]string{"alertname": "HighCPU", "cluster": "eu-1"}}, "[FIRING:2] HighCPU eu-1"},
+		{alertmanagerGroup{Status: "resolved", Alerts: []alertmanagerAlert{{Status: "resolved"}},
+			CommonLabels: map[string]string{"alertname": "DiskFull"}}, "[RESOLVED] DiskFull"},
+		{alertmanagerGroup{Status: "firing", Title: "[FIRING:1] Grafana rule", Alerts: []alertmanagerAlert{{Status: "firing"}}}, "[FIRING:1] Grafana rule"},
+	} {
+		if got := tt.g.title(); got != tt.wantTitle {
+			t.Errorf("title() = %q, want %q", got, tt.wantTitle)
+		}
+	}
+
+	g := alertmanagerGroup{Status: "firing", Receiver: "webex", CommonLabels: map[string]string{"alertname": "HighCPU", "severity": "warning"},
+		CommonAnnotations: map[string]string{"runbook": "https://wiki.example.com/cpu"}}
+	fields := g.fields()
+	for field, want := range map[string]string{"status": "firing", "receiver": "webex", "alertname": "HighCPU", "severity": "warning",
+		"labels.severity": "warning", "annotations.runbook": "https://wiki.example.com/cpu"} {
+		if fields[field] != want {
+			t.Errorf("fields()[%s] = %q, want %q", field, fields[field], want)
+		}
+	}
+}
+
+func TestAlertmanagerHandler(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func() { teamName, roomName, alertmanagerSecret, eventRules = "", "", "", nil }()
+	teamName, roomName = "KMP-Team", "Alerts"
+	alertmanagerSecret = "s3cr3t"
+	eventRules = []*eventRule{
+		{Source: "alertmanager", Match: map[string]string{"labels.team": "payments", "status": "resolved"}, Suppress: true},
+		{Source: "grafana", Match: map[string]string{"alertname": "HighCPU"}, Team: "KMP-Team", Room: "Grafana"},
+	}
+
+	for _, tt := range []struct {
+		name     string
+		receiver alertReceiver
+		auth     string
+		body     string
+		want     int
+		wantRoom string
+	}{
+		{name: "no secret", receiver: alertmanagerReceiver, body: alertmanagerFiring, want: http.StatusUnauthorized},
+		{name: "wrong secret", receiver: alertmanagerReceiver, auth: "Bearer s3cr3", body: alertmanagerFiring, want: http.StatusUnauthorized},
+		{name: "invalid", receiver: alertmanagerReceiver, auth: "Bearer s3cr3t", body: `{"status":"firing"}`, want: http.StatusBadRequest},
+		{name: "firing", receiver: alertmanagerReceiver, auth: "Bearer s3cr3t", body: alertmanagerFiring, want: http.StatusNoContent, wantRoom: "Alerts"},
+		{name: "suppressed", receiver: alertmanagerReceiver, auth: "Bearer s3cr3t", body: strings.ReplaceAll(alertmanagerFiring, `"firing"`, `"resolved"`), want: http.StatusNoContent},
+		{name: "grafana rule", receiver: grafanaReceiver, body: alertmanagerFiring, want: http.StatusNoContent, wantRoom: "Grafana"},
+	} {
+		before := len(fake.Messages())
+		r := httptest.NewRequest("POST", "/"+tt.receiver.name, strings.NewReader(tt.body))
+		if len(tt.auth) > 0 {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		tt.receiver.handler()(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+			continue
+		}
+		messages := fake.Messages()[before:]
+		if len(tt.wantRoom) == 0 {
+			if len(messages) > 0 {
+				t.Errorf("%s: %d messages posted, want none", tt.name, len(messages))
+			}
+			continue
+		}
+		if len(messages) != 1 || len(messages[0].Attachments) != 1 {
+			t.Errorf("%s: messages = %+v, want one card", tt.name, messages)
+			continue
+		}
+		var room string
+		for _, r := range fake.Rooms() {
+			if r.ID == messages[0].RoomID {
+				room = r.Title
+			}
+		}
+		if room != tt.wantRoom {
+			t.Errorf("%s: posted to room %q, want %q", tt.name, room, tt.wantRoom)
+		}
+		card := string(messages[0].Attachments[0])
+		for _, want := range []string{"[FIRING:2] HighCPU", "CPU above 90% on web-1", "instance=web-2", "https://prometheus.example.com/graph"} {
+			if !strings.Contains(card, want) {
+				t.Errorf("%s: card does not contain %q: %s", tt.name, want, card)
+			}
+		}
+		if strings.Contains(card, "javascript:") {
+			t.Errorf("%s: card links to javascript: %s", tt.name, card)
+		}
+	}
+}
+
+func TestCheckAlertmanagerRoute(t *testing.T) {
+	defer func() { teamName, eventRules = "", nil }()
+	for _, tt := range []struct {
+		team    string
+		rules   []*eventRule
+		wantErr bool
+	}{
+		{team: "KMP-Team"},
+		{rules: []*eventRule{{Source: "alertmanager", Team: "KMP-Team"}}},
+		{rules: []*eventRule{{Team: "KMP-Team"}}},
+		{rules: []*eventRule{{Source: "grafana", Team: "KMP-Team"}}, wantErr: true},
+		{rules: []*eventRule{{Source: "alertmanager", Suppress: true}}, wantErr: true},
+		{wantErr: true},
+	} {
+		teamName, eventRules = tt.team, tt.rules
+		if err := checkAlertmanagerRoute("alertmanager"); tt.wantErr != (err != nil) {
+			t.Errorf("checkAlertmanagerRoute() with team %q and %d rules: error = %v", tt.team, len(tt.rules), err)
+		}
+	}
+}
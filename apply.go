@@ -0,0 +1,195 @@
+// apply.go
+//
+// Declarative management of teams, rooms and members (command apply). A
+// YAML file describes the teams with their rooms and the rooms without
+// team, each room as in the provisioning files (see provision.go):
+//
+//	teams:
+//	  - name: KMP-Team
+//	    rooms:
+//	      - room: Alerts
+//	        locked: true
+//	        moderators: [lead@example.com]
+//	        members: [alice@example.com, bob@example.com]
+//	      - room: Deployments
+//	        announcement: true
+//	rooms:
+//	  - room: Ops Bridge
+//	    members: [oncall@example.com]
+//
+//	notify_by_webex_teams apply -f spaces.yaml --dry-run
+//	notify_by_webex_teams apply -f spaces.yaml --prune
+//
+// apply creates the missing teams and rooms and makes the rooms as
+// described. --prune removes the members which are not listed from the
+// rooms with members, --dry-run only prints the drift, i.e. what apply would
+// change. Rooms of the teams which are not described are reported, never
+// deleted.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	applyKeys     = []string{"teams", "rooms"}
+	applyTeamKeys = []string{"name", "rooms"}
+	// the team of a room is given by its team in the file
+	applyRoomKeys = []string{"room", "locked", "announcement", "moderators", "members", "welcome", "welcomeFile", "webhooks"}
+)
+
+// applyTeam is a team of the apply file with its rooms.
+type applyTeam struct {
+	name  string
+	rooms []*space
+}
+
+// readApplyFile reads the teams of the apply file, the rooms without team
+// as team without name.
+func readApplyFile(file string) ([]*applyTeam, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	m, ok := doc.(yamlMapping)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a mapping with the keys teams and rooms", file)
+	}
+	if err := checkYAMLKeys(file, m, applyKeys); err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(file)
+
+	var teams []*applyTeam
+	v, _ := m.Get("teams")
+	items, ok := v.([]interface{})
+	if v != nil && !ok {
+		return nil, fmt.Errorf("%s: teams is not a list", file)
+	}
+	for i, item := range items {
+		name := fmt.Sprintf("%s: team %d", file, i+1)
+		tm, ok := item.(yamlMapping)
+		if !ok {
+			return nil, fmt.Errorf("%s: not a mapping", name)
+		}
+		if err := checkYAMLKeys(name, tm, applyTeamKeys); err != nil {
+			return nil, err
+		}
+		v, _ := tm.Get("name")
+		t := &applyTeam{name: yamlString(v)}
+		if len(t.name) == 0 {
+			return nil, fmt.Errorf("%s: no name", name)
+		}
+		v, _ = tm.Get("rooms")
+		if t.rooms, err = readApplyRooms(name, v, dir, t.name); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+
+	v, _ = m.Get("rooms")
+	rooms, err := readApplyRooms(file, v, dir, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(rooms) > 0 {
+		teams = append(teams, &applyTeam{rooms: rooms})
+	}
+	if len(teams) == 0 {
+		return nil, fmt.Errorf("%s: no teams or rooms", file)
+	}
+	return teams, nil
+}
+
+// readApplyRooms reads the list of rooms v of the team of the given name.
+func readApplyRooms(name string, v interface{}, dir, team string) ([]*space, error) {
+	items, ok := v.([]interface{})
+	if v != nil && !ok {
+		return nil, fmt.Errorf("%s: rooms is not a list", name)
+	}
+	var rooms []*space
+	seen := make(map[string]bool)
+	for i, item := range items {
+		name := fmt.Sprintf("%s: room %d", name, i+1)
+		m, ok := item.(yamlMapping)
+		if !ok {
+			return nil, fmt.Errorf("%s: not a mapping", name)
+		}
+		if err := checkYAMLKeys(name, m, applyRoomKeys); err != nil {
+			return nil, err
+		}
+		s, err := parseSpace(name, m, dir, &space{team: team})
+		if err != nil {
+			return nil, err
+		}
+		if seen[s.room] {
+			return nil, fmt.Errorf("%s: room %q is described twice", name, s.room)
+		}
+		seen[s.room] = true
+		rooms = append(rooms, s)
+	}
+	return rooms, nil
+}
+
+func cmdApply(action string, args []string) error {
+	files := append(append([]string{}, uploadFiles...), args...)
+	if len(files) != 1 {
+		return errors.New("use apply -f <file> with one file")
+	}
+	teams, err := readApplyFile(files[0])
+	if err != nil {
+		return err
+	}
+	r, err := newReconciler(dryRun, pruneMembers)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	for _, t := range teams {
+		teamID, err := r.team(ctx, t.name, true)
+		if err != nil {
+			return err
+		}
+		for _, s := range t.rooms {
+			if err := r.space(ctx, teamID, s); err != nil {
+				return err
+			}
+		}
+		if len(teamID) == 0 || len(t.name) == 0 {
+			continue
+		}
+		// the general room of the team has the name of the team
+		described := map[string]bool{t.name: true}
+		for _, s := range t.rooms {
+			described[s.room] = true
+		}
+		rooms, err := r.client.ListRooms(ctx, teamID, "group")
+		if err != nil {
+			return err
+		}
+		for _, room := range rooms {
+			if !described[room.Title] {
+				fmt.Printf("room %q of team %q is not described\n", room.Title, t.name)
+			}
+		}
+	}
+
+	switch {
+	case r.changes == 0:
+		fmt.Println("no changes")
+	case r.dryRun:
+		fmt.Printf("%d changes to apply\n", r.changes)
+	default:
+		fmt.Printf("%d changes applied\n", r.changes)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadApplyFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		yaml    string
+		want    string
+		wantErr string
+	}{
+		{yaml: "teams:\n  - name: KMP-Team\n    rooms:\n      - room: Alerts\n      - room: Deployments\nrooms:\n  - room: Ops Bridge\n",
+			want: "KMP-Team/Alerts KMP-Team/Deployments /Ops Bridge"},
+		{yaml: "rooms:\n  - room: Ops Bridge\n", want: "/Ops Bridge"},
+		{yaml: "teams:\n  - rooms:\n      - room: Alerts\n", wantErr: "no name"},
+		{yaml: "teams:\n  - name: KMP-Team\n    rooms:\n      - room: Alerts\n      - room: Alerts\n", wantErr: "described twice"},
+		// the team of a room is the team of the file
+		{yaml: "teams:\n  - name: KMP-Team\n    rooms:\n      - room: Alerts\n        team: Other\n", wantErr: "team"},
+		{yaml: "teams: KMP-Team\n", wantErr: "teams is not a list"},
+		{yaml: "spaces: []\n", wantErr: "spaces"},
+		{yaml: "teams: []\n", wantErr: "no teams or rooms"},
+	} {
+		file := filepath.Join(dir, "spaces.yaml")
+		if err := os.WriteFile(file, []byte(tt.yaml), 0600); err != nil {
+			t.Fatal(err)
+		}
+		teams, err := readApplyFile(file)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readApplyFile(%q) error = %v, want %s", tt.yaml, err, tt.wantErr)
+			}
+			continue
+		}
+		var got []string
+		for _, team := range teams {
+			for _, s := range team.rooms {
+				got = append(got, s.team+"/"+s.room)
+			}
+		}
+		if err != nil || strings.Join(got, " ") != tt.want {
+			t.Errorf("readApplyFile(%q) = %q, %v, want %s", tt.yaml, got, err, tt.want)
+		}
+	}
+}
+
+func TestApplyPrune(t *testing.T) {
+	fake := useFakeWebex(t)
+	defer func() { dryRun, pruneMembers = false, false }()
+	file := filepath.Join(t.TempDir(), "spaces.yaml")
+	yaml := "teams:\n  - name: KMP-Team\n    rooms:\n      - room: Alerts\n        members: [alice@example.com]\n      - room: Lobby\n"
+	if err := os.WriteFile(file, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	members := func(title string) string {
+		var roomID string
+		for _, r := range fake.Rooms() {
+			if r.Title == title {
+				roomID = r.ID
+			}
+		}
+		var emails []string
+		for _, m := range fake.Memberships() {
+			if m.RoomID == roomID {
+				emails = append(emails, m.PersonEmail)
+			}
+		}
+		return strings.Join(emails, ",")
+	}
+	apply := func(dry, prune bool) {
+		t.Helper()
+		dryRun, pruneMembers = dry, prune
+		if err := cmdApply("", []string{file}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apply(true, true)
+	if len(fake.Rooms()) != 0 {
+		t.Fatalf("dry run created %d rooms", len(fake.Rooms()))
+	}
+	apply(false, false)
+	if got := members("Alerts"); got != "alice@example.com" {
+		t.Fatalf("members of Alerts = %s", got)
+	}
+
+	for _, title := range []string{"Alerts", "Lobby"} {
+		for _, r := range fake.Rooms() {
+			if r.Title == title {
+				fake.AddMembership(r.ID, "mallory@example.com", false)
+			}
+		}
+	}
+	apply(false, false)
+	apply(true, true)
+	if got := members("Alerts"); got != "alice@example.com,mallory@example.com" {
+		t.Errorf("members of Alerts after dry run with prune = %s, want mallory kept", got)
+	}
+	apply(false, true)
+	if got := members("Alerts"); got != "alice@example.com" {
+		t.Errorf("members of Alerts after prune = %s, want mallory removed", got)
+	}
+	// a room without members keeps its members
+	if got := members("Lobby"); got != "mallory@example.com" {
+		t.Errorf("members of Lobby after prune = %s, want mallory kept", got)
+	}
+}
@@ -0,0 +1,429 @@
+// awssecret.go
+//
+// Bot token from AWS Secrets Manager or SSM Parameter Store (flag
+// --token-aws-secret). The secret is given by name or ARN, a parameter of
+// Parameter Store with the prefix ssm: or by its ARN. A secret with JSON
+// key/value pairs takes the key after #:
+//
+//	notify_by_webex_teams --token-aws-secret prod/webex-bot -t KMP-Team -r Alerts -m "backup done"
+//	notify_by_webex_teams --token-aws-secret prod/webex#token ...
+//	notify_by_webex_teams --token-aws-secret ssm:/notify/webex-token ...
+//
+// The credentials are taken from the default credential chain of the AWS
+// SDKs: the environment variables (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN, as set for Lambda functions), web identity
+// (AWS_WEB_IDENTITY_TOKEN_FILE and AWS_ROLE_ARN, e.g. of EKS), the shared
+// credentials file (~/.aws/credentials, profile AWS_PROFILE), the container
+// credentials of ECS and the instance role of EC2 (IMDSv2). The region is
+// taken from the ARN, AWS_REGION, AWS_DEFAULT_REGION, ~/.aws/config or the
+// instance metadata. AWS_ENDPOINT_URL_SECRETS_MANAGER and AWS_ENDPOINT_URL_SSM
+// set other endpoints, e.g. VPC endpoints.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// endpoints of the instance metadata of EC2 and the credentials of ECS
+	imdsEndpoint      = "http://169.254.169.254"
+	ecsCredentialsURL = "http://169.254.170.2"
+)
+
+// awsCredentials are the credentials of a signed AWS request.
+type awsCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+}
+
+// awsSecret returns the value of the secret or parameter of ref.
+func awsSecret(ctx context.Context, ref string) (string, error) {
+	name, key, _ := strings.Cut(ref, "#")
+	service, target := "secretsmanager", "secretsmanager.GetSecretValue"
+	if strings.HasPrefix(name, "ssm:") || strings.HasPrefix(name, "arn:aws:ssm:") {
+		service, target = "ssm", "AmazonSSM.GetParameter"
+		name = strings.TrimPrefix(name, "ssm:")
+	}
+	if len(name) == 0 {
+		return "", fmt.Errorf("invalid AWS secret %q. use <name>, <name>#<key> or ssm:<parameter>", ref)
+	}
+
+	region := awsRegion(ctx)
+	if parts := strings.Split(name, ":"); len(parts) > 4 && parts[0] == "arn" {
+		region = parts[3]
+	}
+	if len(region) == 0 {
+		return "", errors.New("no AWS region. set AWS_REGION")
+	}
+	creds, err := awsCredentialChain(ctx, region)
+	if err != nil {
+		return "", err
+	}
+
+	var value string
+	if service == "ssm" {
+		var resp struct {
+			Parameter struct {
+				Value string `json:"Value"`
+			} `json:"Parameter"`
+		}
+		body := map[string]interface{}{"Name": name, "WithDecryption": true}
+		err = awsRequest(ctx, creds, region, service, os.Getenv("AWS_ENDPOINT_URL_SSM"), target, body, &resp)
+		value = resp.Parameter.Value
+	} else {
+		var resp struct {
+			SecretString string `json:"SecretString"`
+		}
+		body := map[string]interface{}{"SecretId": name}
+		err = awsRequest(ctx, creds, region, service, os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER"), target, body, &resp)
+		value = resp.SecretString
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	if len(key) > 0 {
+		var pairs map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &pairs); err != nil {
+			return "", fmt.Errorf("%s is no JSON with key/value pairs", name)
+		}
+		value, _ = pairs[key].(string)
+	}
+	if len(value) == 0 {
+		return "", fmt.Errorf("no value of %s", ref)
+	}
+	return value, nil
+}
+
+// awsRegion returns the region of the environment, the config file or the
+// instance metadata.
+func awsRegion(ctx context.Context) string {
+	if region := firstNonEmpty(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")); len(region) > 0 {
+		return region
+	}
+	section := "profile " + awsProfile()
+	if awsProfile() == "default" {
+		section = "default"
+	}
+	file := firstNonEmpty(os.Getenv("AWS_CONFIG_FILE"), awsFile("config"))
+	if region := readAWSFile(file, section)["region"]; len(region) > 0 {
+		return region
+	}
+	region, _ := imdsGet(ctx, "/latest/meta-data/placement/region")
+	return region
+}
+
+func awsProfile() string {
+	return firstNonEmpty(os.Getenv("AWS_PROFILE"), "default")
+}
+
+func awsFile(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+// readAWSFile returns the keys of the given section of the AWS config or
+// credentials file, nil if the file does not exist.
+func readAWSFile(file, section string) map[string]string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var keys map[string]string
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case len(line) == 0 || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			current = strings.TrimSpace(strings.Trim(line, "[]"))
+		case current == section:
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			if keys == nil {
+				keys = make(map[string]string)
+			}
+			keys[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return keys
+}
+
+// awsCredentialChain returns the first credentials of the default
+// credential chain.
+func awsCredentialChain(ctx context.Context, region string) (*awsCredentials, error) {
+	creds := &awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		Token:           os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	var err error
+	switch {
+	case len(creds.AccessKeyID) > 0 && len(creds.SecretAccessKey) > 0:
+	case len(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")) > 0 && len(os.Getenv("AWS_ROLE_ARN")) > 0:
+		creds, err = webIdentityCredentials(ctx, region)
+	default:
+		file := firstNonEmpty(os.Getenv("AWS_SHARED_CREDENTIALS_FILE"), awsFile("credentials"))
+		keys := readAWSFile(file, awsProfile())
+		if len(keys["aws_access_key_id"]) > 0 {
+			creds = &awsCredentials{
+				AccessKeyID:     keys["aws_access_key_id"],
+				SecretAccessKey: keys["aws_secret_access_key"],
+				Token:           keys["aws_session_token"],
+			}
+		} else {
+			creds, err = containerCredentials(ctx)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	addSecret(creds.SecretAccessKey)
+	addSecret(creds.Token)
+	return creds, nil
+}
+
+// webIdentityCredentials returns the credentials of the role of
+// AWS_ROLE_ARN for the web identity token of AWS_WEB_IDENTITY_TOKEN_FILE.
+func webIdentityCredentials(ctx context.Context, region string) (*awsCredentials, error) {
+	token, err := os.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	q.Set("Action", "AssumeRoleWithWebIdentity")
+	q.Set("Version", "2011-06-15")
+	q.Set("RoleArn", os.Getenv("AWS_ROLE_ARN"))
+	q.Set("RoleSessionName", firstNonEmpty(os.Getenv("AWS_ROLE_SESSION_NAME"), "notify_by_webex_teams"))
+	q.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+	endpoint := firstNonEmpty(os.Getenv("AWS_ENDPOINT_URL_STS"), "https://sts."+region+".amazonaws.com")
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"/?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("AssumeRoleWithWebIdentity: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("AssumeRoleWithWebIdentity: %w", err)
+	}
+	c := result.Credentials
+	return &awsCredentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, Token: c.SessionToken}, nil
+}
+
+// containerCredentials returns the credentials of the ECS task or else of
+// the instance role of EC2.
+func containerCredentials(ctx context.Context) (*awsCredentials, error) {
+	var u string
+	header := make(http.Header)
+	switch {
+	case len(os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")) > 0:
+		u = ecsCredentialsURL + os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")
+	case len(os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")) > 0:
+		u = os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+		auth := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+		if file := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); len(file) > 0 {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			auth = strings.TrimSpace(string(data))
+		}
+		if len(auth) > 0 {
+			header.Set("Authorization", auth)
+		}
+	default:
+		role, err := imdsGet(ctx, "/latest/meta-data/iam/security-credentials/")
+		if err != nil {
+			return nil, errors.New("no AWS credentials. set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, AWS_PROFILE or use an instance role")
+		}
+		role, _, _ = strings.Cut(strings.TrimSpace(role), "\n")
+		data, err := imdsGet(ctx, "/latest/meta-data/iam/security-credentials/"+role)
+		if err != nil {
+			return nil, fmt.Errorf("credentials of the instance role %s: %w", role, err)
+		}
+		var creds awsCredentials
+		if err := json.Unmarshal([]byte(data), &creds); err != nil {
+			return nil, err
+		}
+		return &creds, nil
+	}
+
+	data, err := metadataRequest(ctx, "GET", u, header)
+	if err != nil {
+		return nil, fmt.Errorf("container credentials: %w", err)
+	}
+	var creds awsCredentials
+	if err := json.Unmarshal([]byte(data), &creds); err != nil {
+		return nil, err
+	}
+	return &creds, nil
+}
+
+// imdsGet returns the instance metadata of path, using a IMDSv2 session
+// token.
+func imdsGet(ctx context.Context, path string) (string, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return "", errors.New("instance metadata disabled")
+	}
+	endpoint := firstNonEmpty(os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"), imdsEndpoint)
+	token, err := metadataRequest(ctx, "PUT", endpoint+"/latest/api/token", http.Header{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"300"}})
+	if err != nil {
+		return "", err
+	}
+	return metadataRequest(ctx, "GET", endpoint+path, http.Header{"X-Aws-Ec2-Metadata-Token": {token}})
+}
+
+// metadataRequest sends a request to the metadata endpoints of AWS.
+func metadataRequest(ctx context.Context, method, u string, header http.Header) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header = header
+	resp, err := metadataClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(resp.Status)
+	}
+	return string(data), nil
+}
+
+// awsRequest sends a request of the JSON protocol of AWS, e.g. of Secrets
+// Manager, signed with Signature Version 4, and decodes the response into
+// out.
+func awsRequest(ctx context.Context, creds *awsCredentials, region, service, endpoint, target string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	endpoint = firstNonEmpty(endpoint, "https://"+service+"."+region+".amazonaws.com")
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	signAWSRequest(req, body, creds, region, service, time.Now().UTC())
+
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var awsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &awsErr) == nil && len(awsErr.Type) > 0 {
+			// the type may be prefixed with a namespace, e.g. com.amazonaws.kms#...
+			typ := awsErr.Type[strings.LastIndex(awsErr.Type, "#")+1:]
+			return fmt.Errorf("%s: %s %s", resp.Status, typ, awsErr.Message)
+		}
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// signAWSRequest signs req with AWS Signature Version 4 in the
+// Authorization header.
+func signAWSRequest(req *http.Request, body []byte, creds *awsCredentials, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if len(creds.Token) > 0 {
+		req.Header.Set("X-Amz-Security-Token", creds.Token)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = req.Header.Get(name)
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4Escape(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signed,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := now.Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{now.Format("20060102"), region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signed, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// test vectors of the Signature Version 4 test suite
+func TestSignAWSRequest(t *testing.T) {
+	creds := &awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name, url, want string
+	}{
+		{"get-vanilla", "https://example.amazonaws.com/",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	} {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		req.Header = http.Header{}
+		signAWSRequest(req, nil, creds, "us-east-1", "service", now)
+		if got := req.Header.Get("Authorization"); got != tt.want {
+			t.Errorf("%s: Authorization = %s, want %s", tt.name, got, tt.want)
+		}
+		if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+			t.Errorf("%s: X-Amz-Date = %s", tt.name, got)
+		}
+	}
+}
+
+func TestSigV4Escape(t *testing.T) {
+	for _, tt := range []struct {
+		s           string
+		encodeSlash bool
+		want        string
+	}{
+		{"/notify/webex-token", false, "/notify/webex-token"},
+		{"/notify/webex-token", true, "%2Fnotify%2Fwebex-token"},
+		{"a b+c~d_e.f", true, "a%20b%2Bc~d_e.f"},
+		{"ä", true, "%C3%A4"},
+	} {
+		if got := sigV4Escape(tt.s, tt.encodeSlash); got != tt.want {
+			t.Errorf("sigV4Escape(%q, %v) = %q, want %q", tt.s, tt.encodeSlash, got, tt.want)
+		}
+	}
+}
+
+func TestAWSSecret(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			http.Error(w, `{"__type":"UnrecognizedClientException","message":"unsigned"}`, http.StatusBadRequest)
+			return
+		}
+		var in map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&in)
+		switch {
+		case r.Header.Get("X-Amz-Target") == "AmazonSSM.GetParameter" && in["Name"] == "/notify/webex-token":
+			fmt.Fprint(w, `{"Parameter":{"Value":"ssm-token"}}`)
+		case in["SecretId"] == "prod/webex-bot":
+			fmt.Fprint(w, `{"SecretString":"plain-token"}`)
+		case in["SecretId"] == "prod/webex":
+			fmt.Fprint(w, `{"SecretString":"{\"token\":\"json-token\"}"}`)
+		default:
+			http.Error(w, `{"__type":"com.amazonaws.secretsmanager#ResourceNotFoundException","message":"not found"}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_REGION", "eu-central-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", srv.URL)
+	t.Setenv("AWS_ENDPOINT_URL_SSM", srv.URL)
+
+	for _, tt := range []struct {
+		ref, want, wantErr string
+	}{
+		{ref: "prod/webex-bot", want: "plain-token"},
+		{ref: "prod/webex#token", want: "json-token"},
+		{ref: "ssm:/notify/webex-token", want: "ssm-token"},
+		{ref: "prod/webex#other", wantErr: "no value"},
+		{ref: "prod/webex-bot#token", wantErr: "no JSON"},
+		{ref: "prod/missing", wantErr: "ResourceNotFoundException not found"},
+		{ref: "ssm:", wantErr: "invalid AWS secret"},
+	} {
+		got, err := awsSecret(context.Background(), tt.ref)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("awsSecret(%q) error = %v, want %s", tt.ref, err, tt.wantErr)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("awsSecret(%q) = %q, %v, want %q", tt.ref, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// azurekeyvault.go
+//
+// Bot token from Azure Key Vault (flag --token-azure-keyvault). The secret is
+// given by its URL, optionally with version, or as <vault name>/<secret name>:
+//
+//	notify_by_webex_teams --token-azure-keyvault ops-vault/webex-bot-token -t KMP-Team -r Alerts -m "release deployed"
+//	notify_by_webex_teams --token-azure-keyvault https://ops-vault.vault.azure.net/secrets/webex-bot-token ...
+//
+// The access token for Key Vault is taken from, in this order, the workload
+// identity of AKS (AZURE_FEDERATED_TOKEN_FILE, AZURE_CLIENT_ID,
+// AZURE_TENANT_ID), a service principal (AZURE_CLIENT_ID, AZURE_CLIENT_SECRET,
+// AZURE_TENANT_ID), the managed identity of App Service and Functions
+// (IDENTITY_ENDPOINT, IDENTITY_HEADER) and the managed identity of the VM,
+// e.g. of an Azure DevOps agent, from the instance metadata service. A user
+// assigned managed identity is selected by AZURE_CLIENT_ID.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	azureIMDSEndpoint  = "http://169.254.169.254"
+	azureAuthorityHost = "https://login.microsoftonline.com"
+	azureKeyVaultAPI   = "7.4"
+)
+
+// azureKeyVaultSecret returns the value of the Key Vault secret of ref.
+func azureKeyVaultSecret(ctx context.Context, ref string) (string, error) {
+	secretURL, err := azureSecretURL(ref)
+	if err != nil {
+		return "", err
+	}
+	// the resource of the access token is the Key Vault service of the
+	// cloud of the vault, e.g. https://vault.azure.net
+	_, domain, _ := strings.Cut(secretURL.Host, ".")
+	resource := secretURL.Scheme + "://" + domain
+	token, err := azureAccessToken(ctx, resource)
+	if err != nil {
+		return "", err
+	}
+	addSecret(token)
+
+	secretURL.RawQuery = "api-version=" + azureKeyVaultAPI
+	var secret struct {
+		Value string `json:"value"`
+	}
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return "", err
+	}
+	if err := azureRequest(ctx, client, "GET", secretURL.String(), header, nil, &secret); err != nil {
+		return "", fmt.Errorf("reading %s: %w", ref, err)
+	}
+	if len(secret.Value) == 0 {
+		return "", fmt.Errorf("secret %s is empty", ref)
+	}
+	return secret.Value, nil
+}
+
+// azureSecretURL returns the URL of the Key Vault secret of ref, a URL or
+// <vault name>/<secret name>.
+func azureSecretURL(ref string) (*url.URL, error) {
+	if !strings.Contains(ref, "://") {
+		vault, name, ok := strings.Cut(ref, "/")
+		if !ok || len(vault) == 0 || len(name) == 0 {
+			return nil, fmt.Errorf("invalid Key Vault secret %q. use <vault name>/<secret name> or the URL of the secret", ref)
+		}
+		ref = "https://" + vault + ".vault.azure.net/secrets/" + name
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Scheme != "https" || !strings.Contains(u.Host, ".") || !strings.HasPrefix(u.Path, "/secrets/") {
+		return nil, fmt.Errorf("invalid Key Vault secret URL %q, e.g. https://<vault name>.vault.azure.net/secrets/<secret name>", ref)
+	}
+	return u, nil
+}
+
+// azureAccessToken returns an access token for the given resource of the
+// first credential of the environment.
+func azureAccessToken(ctx context.Context, resource string) (string, error) {
+	clientID, tenantID := os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_TENANT_ID")
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	var err error
+	switch {
+	case len(os.Getenv("AZURE_FEDERATED_TOKEN_FILE")) > 0 && len(clientID) > 0 && len(tenantID) > 0:
+		assertion, err := os.ReadFile(os.Getenv("AZURE_FEDERATED_TOKEN_FILE"))
+		if err != nil {
+			return "", err
+		}
+		err = azureClientCredentials(ctx, tenantID, resource, url.Values{
+			"client_id":             {clientID},
+			"client_assertion_type": {"urn:ietf:params:oauth:client-assertion-type:jwt-bearer"},
+			"client_assertion":      {strings.TrimSpace(string(assertion))},
+		}, &resp)
+		if err != nil {
+			return "", fmt.Errorf("workload identity: %w", err)
+		}
+	case len(os.Getenv("AZURE_CLIENT_SECRET")) > 0 && len(clientID) > 0 && len(tenantID) > 0:
+		addSecret(os.Getenv("AZURE_CLIENT_SECRET"))
+		err = azureClientCredentials(ctx, tenantID, resource, url.Values{
+			"client_id":     {clientID},
+			"client_secret": {os.Getenv("AZURE_CLIENT_SECRET")},
+		}, &resp)
+		if err != nil {
+			return "", fmt.Errorf("service principal: %w", err)
+		}
+	case len(os.Getenv("IDENTITY_ENDPOINT")) > 0 && len(os.Getenv("IDENTITY_HEADER")) > 0:
+		q := url.Values{"api-version": {"2019-08-01"}, "resource": {resource}}
+		if len(clientID) > 0 {
+			q.Set("client_id", clientID)
+		}
+		header := http.Header{"X-Identity-Header": {os.Getenv("IDENTITY_HEADER")}}
+		err = azureRequest(ctx, metadataClient(), "GET", os.Getenv("IDENTITY_ENDPOINT")+"?"+q.Encode(), header, nil, &resp)
+		if err != nil {
+			return "", fmt.Errorf("managed identity: %w", err)
+		}
+	default:
+		q := url.Values{"api-version": {"2018-02-01"}, "resource": {resource}}
+		if len(clientID) > 0 {
+			q.Set("client_id", clientID)
+		}
+		endpoint := firstNonEmpty(os.Getenv("AZURE_IMDS_ENDPOINT"), azureIMDSEndpoint)
+		ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+		defer cancel()
+		err = azureRequest(ctx, metadataClient(), "GET", endpoint+"/metadata/identity/oauth2/token?"+q.Encode(), http.Header{"Metadata": {"true"}}, nil, &resp)
+		if err != nil {
+			return "", fmt.Errorf("no Azure credentials. use a managed identity or set AZURE_CLIENT_ID, AZURE_TENANT_ID and AZURE_CLIENT_SECRET: %w", err)
+		}
+	}
+	if len(resp.AccessToken) == 0 {
+		return "", errors.New("no Azure access token")
+	}
+	return resp.AccessToken, nil
+}
+
+// azureClientCredentials requests an access token of Microsoft Entra ID with
+// the client credentials flow.
+func azureClientCredentials(ctx context.Context, tenantID, resource string, values url.Values, out interface{}) error {
+	values.Set("grant_type", "client_credentials")
+	values.Set("scope", resource+"/.default")
+	authority := strings.TrimSuffix(firstNonEmpty(os.Getenv("AZURE_AUTHORITY_HOST"), azureAuthorityHost), "/")
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	return azureRequest(ctx, client, "POST", authority+"/"+url.PathEscape(tenantID)+"/oauth2/v2.0/token", header, strings.NewReader(values.Encode()), out)
+}
+
+// azureRequest sends a request to Entra ID, the managed identity endpoints
+// or Key Vault and decodes the JSON response into out.
+func azureRequest(ctx context.Context, client *http.Client, method, u string, header http.Header, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Key Vault returns {"error":{"code":...,"message":...}}, Entra ID
+		// {"error":...,"error_description":...}
+		var azureErr struct {
+			Error       json.RawMessage `json:"error"`
+			Description string          `json:"error_description"`
+		}
+		var kvErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &azureErr) == nil {
+			if json.Unmarshal(azureErr.Error, &kvErr) == nil && len(kvErr.Message) > 0 {
+				return fmt.Errorf("%s: %s", resp.Status, kvErr.Message)
+			}
+			if len(azureErr.Description) > 0 {
+				return fmt.Errorf("%s: %s", resp.Status, strings.SplitN(azureErr.Description, "\r\n", 2)[0])
+			}
+		}
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAzureSecretURL(t *testing.T) {
+	for _, tt := range []struct {
+		ref, want string
+		wantErr   bool
+	}{
+		{"ops-vault/webex-bot-token", "https://ops-vault.vault.azure.net/secrets/webex-bot-token", false},
+		{"https://ops-vault.vault.usgovcloudapi.net/secrets/webex-bot-token/4711", "https://ops-vault.vault.usgovcloudapi.net/secrets/webex-bot-token/4711", false},
+		{"ops-vault", "", true},
+		{"/webex-bot-token", "", true},
+		{"http://ops-vault.vault.azure.net/secrets/webex-bot-token", "", true},
+		{"https://ops-vault.vault.azure.net/keys/webex", "", true},
+	} {
+		u, err := azureSecretURL(tt.ref)
+		if (err != nil) != tt.wantErr || err == nil && u.String() != tt.want {
+			t.Errorf("azureSecretURL(%q) = %v, %v, want %s", tt.ref, u, err, tt.want)
+		}
+	}
+}
+
+func TestAzureAccessToken(t *testing.T) {
+	resource := "https://vault.azure.net"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch {
+		case r.URL.Path == "/tenant-1/oauth2/v2.0/token" && r.Form.Get("scope") == resource+"/.default" && r.Form.Get("grant_type") == "client_credentials":
+			switch {
+			case r.Form.Get("client_assertion") == "federated-jwt":
+				fmt.Fprint(w, `{"access_token":"workload-token"}`)
+			case r.Form.Get("client_secret") == "s3cr3t":
+				fmt.Fprint(w, `{"access_token":"sp-token"}`)
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error":"invalid_client","error_description":"AADSTS7000215: Invalid client secret provided.\r\nTrace ID: 1"}`)
+			}
+		case r.URL.Path == "/msi/token" && r.Header.Get("X-Identity-Header") == "header-1" && r.Form.Get("resource") == resource:
+			fmt.Fprint(w, `{"access_token":"app-service-token"}`)
+		case r.URL.Path == "/metadata/identity/oauth2/token" && r.Header.Get("Metadata") == "true" && r.Form.Get("client_id") == "client-1":
+			fmt.Fprint(w, `{"access_token":"imds-token"}`)
+		default:
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	federated := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(federated, []byte("federated-jwt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name          string
+		env           map[string]string
+		want, wantErr string
+	}{
+		{name: "workload identity", env: map[string]string{"AZURE_FEDERATED_TOKEN_FILE": federated, "AZURE_CLIENT_SECRET": "s3cr3t"}, want: "workload-token"},
+		{name: "service principal", env: map[string]string{"AZURE_CLIENT_SECRET": "s3cr3t"}, want: "sp-token"},
+		{name: "wrong secret", env: map[string]string{"AZURE_CLIENT_SECRET": "guess"},
+			wantErr: "service principal: 401 Unauthorized: AADSTS7000215: Invalid client secret provided."},
+		{name: "app service", env: map[string]string{"AZURE_TENANT_ID": "", "IDENTITY_ENDPOINT": srv.URL + "/msi/token", "IDENTITY_HEADER": "header-1"}, want: "app-service-token"},
+		{name: "vm", env: map[string]string{"AZURE_TENANT_ID": ""}, want: "imds-token"},
+		{name: "no credentials", env: map[string]string{"AZURE_TENANT_ID": "", "AZURE_CLIENT_ID": ""}, wantErr: "no Azure credentials"},
+	} {
+		env := map[string]string{"AZURE_CLIENT_ID": "client-1", "AZURE_TENANT_ID": "tenant-1", "AZURE_AUTHORITY_HOST": srv.URL + "/",
+			"AZURE_IMDS_ENDPOINT": srv.URL, "AZURE_FEDERATED_TOKEN_FILE": "", "AZURE_CLIENT_SECRET": "", "IDENTITY_ENDPOINT": "", "IDENTITY_HEADER": ""}
+		for k, v := range tt.env {
+			env[k] = v
+		}
+		for k, v := range env {
+			t.Setenv(k, v)
+		}
+		got, err := azureAccessToken(context.Background(), resource)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("%s: azureAccessToken() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: azureAccessToken() = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestAzureRequestError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		switch r.URL.Path {
+		case "/keyvault":
+			fmt.Fprint(w, `{"error":{"code":"Forbidden","message":"The user does not have secrets get permission"}}`)
+		case "/plain":
+			fmt.Fprint(w, `forbidden`)
+		}
+	}))
+	defer srv.Close()
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"/keyvault", "403 Forbidden: The user does not have secrets get permission"},
+		{"/plain", "403 Forbidden"},
+	} {
+		var out struct{}
+		err := azureRequest(context.Background(), srv.Client(), "GET", srv.URL+tt.path, http.Header{}, nil, &out)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("azureRequest(%s) error = %v, want %s", tt.path, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// batch.go
+//
+// Result summary of the batch modes --recipients, --manifest and --multi.
+// The result of every target is printed as a table at the end and, with
+// --report, written to a JSON file, e.g. for the artifacts of a CI job:
+//
+//	notify_by_webex_teams --recipients projects.csv -m "Maintenance of {{.Vars.project}}" --report report.json --fail-on 50%
+//
+// --fail-on decides when a partial failure makes the command fail: any (the
+// default) fails if a single target failed, all only if every target failed,
+// <n>% if at least n percent failed and never not at all.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// batchResult is the result of a target of a batch.
+type batchResult struct {
+	// Item is the line of the recipients file, the file of the manifest or
+	// the number of the message of --multi
+	Item   string `json:"item"`
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Code and TrackingID are set for errors of the Webex API
+	Code       int    `json:"code,omitempty"`
+	TrackingID string `json:"trackingId,omitempty"`
+}
+
+// batchReport is the JSON report of --report.
+type batchReport struct {
+	Mode     string        `json:"mode"`
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Total    int           `json:"total"`
+	Sent     int           `json:"sent"`
+	Failed   int           `json:"failed"`
+	Results  []batchResult `json:"results"`
+}
+
+// batch collects the results of a batch mode.
+type batch struct {
+	report batchReport
+	// noun names the items in the error, e.g. "recipients"
+	noun string
+	// column is the table header of the items, e.g. "LINE"
+	column string
+}
+
+func newBatch(mode, noun, column string) *batch {
+	return &batch{report: batchReport{Mode: mode, Started: time.Now(), Results: []batchResult{}}, noun: noun, column: column}
+}
+
+// itemName returns the name of an item in the error, e.g. "line 3".
+func (b *batch) itemName(item string) string {
+	if b.column == "FILE" {
+		return item
+	}
+	return strings.ToLower(b.column) + " " + item
+}
+
+// add records the result of sending item to target.
+func (b *batch) add(item, target string, err error) {
+	r := batchResult{Item: item, Target: target, Status: "sent"}
+	if err != nil {
+		r.Status, r.Error = "failed", redact(strings.Join(strings.Fields(err.Error()), " "))
+		var apiErr *webex.APIError
+		if errors.As(err, &apiErr) {
+			r.Code, r.TrackingID = apiErr.StatusCode, apiErr.TrackingID
+		}
+		b.report.Failed++
+	} else {
+		b.report.Sent++
+	}
+	b.report.Total++
+	b.report.Results = append(b.report.Results, r)
+}
+
+// finish prints the summary, writes the report of --report and returns an
+// error if the failures fail the command by --fail-on.
+func (b *batch) finish() error {
+	b.report.Finished = time.Now()
+	w := newTable(b.column, "TO", "RESULT")
+	for _, r := range b.report.Results {
+		result := r.Status
+		if len(r.Error) > 0 {
+			result += ": " + r.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Item, r.Target, result)
+	}
+	w.Flush()
+	slog.Info(b.report.Mode+" done", "sent", b.report.Sent, "failed", b.report.Failed)
+
+	if len(reportFile) > 0 {
+		data, err := json.MarshalIndent(&b.report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(reportFile, append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("--report: %w", err)
+		}
+	}
+
+	if b.report.Failed == 0 {
+		return nil
+	}
+	var failedItems []string
+	for _, r := range b.report.Results {
+		if r.Status == "failed" {
+			failedItems = append(failedItems, b.itemName(r.Item))
+		}
+	}
+	err := fmt.Errorf("%d of %d %s failed: %s", b.report.Failed, b.report.Total, b.noun, strings.Join(failedItems, ", "))
+	fail, _ := failsBatch(failOn, b.report.Failed, b.report.Total)
+	if !fail {
+		slog.Warn("partial failure accepted by --fail-on", "failOn", failOn, "error", err)
+		return nil
+	}
+	return err
+}
+
+// failsBatch reports whether failed of total items fail the command by the
+// policy of --fail-on.
+func failsBatch(policy string, failed, total int) (bool, error) {
+	switch policy {
+	case "any", "":
+		return failed > 0, nil
+	case "all":
+		return failed > 0 && failed == total, nil
+	case "never":
+		return false, nil
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(policy, "%"), 64)
+	if !strings.HasSuffix(policy, "%") || err != nil || percent <= 0 || percent > 100 {
+		return false, fmt.Errorf("invalid --fail-on %q. use any, all, never or a percentage, e.g. 50%%", policy)
+	}
+	return failed > 0 && float64(failed)*100 >= percent*float64(total), nil
+}
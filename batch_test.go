@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestFailsBatch(t *testing.T) {
+	for _, tt := range []struct {
+		policy        string
+		failed, total int
+		want, err     bool
+	}{
+		{"", 0, 3, false, false},
+		{"any", 1, 3, true, false},
+		{"all", 2, 3, false, false},
+		{"all", 3, 3, true, false},
+		{"never", 3, 3, false, false},
+		{"50%", 1, 3, false, false},
+		{"50%", 2, 4, true, false},
+		{"50%", 0, 4, false, false},
+		{"100%", 4, 4, true, false},
+		{"0%", 1, 4, false, true},
+		{"150%", 1, 4, false, true},
+		{"50", 1, 4, false, true},
+		{"some", 1, 4, false, true},
+	} {
+		got, err := failsBatch(tt.policy, tt.failed, tt.total)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("failsBatch(%q, %d, %d) = %v, %v, want %v, error %v", tt.policy, tt.failed, tt.total, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestBatchFinish(t *testing.T) {
+	defer func(r, f string) { reportFile, failOn = r, f }(reportFile, failOn)
+	reportFile = filepath.Join(t.TempDir(), "report.json")
+
+	for _, tt := range []struct {
+		failOn string
+		want   string
+	}{
+		{"any", "2 of 3 recipients failed: line 3, line 4"},
+		{"all", ""},
+		{"50%", "2 of 3 recipients failed: line 3, line 4"},
+		{"never", ""},
+	} {
+		failOn = tt.failOn
+		b := newBatch("recipients", "recipients", "LINE")
+		b.add("2", "KMP-Team/Billing", nil)
+		b.add("3", "KMP-Team/Shop", &webex.APIError{StatusCode: 404, Message: "room not found", TrackingID: "ROUTER_1"})
+		b.add("4", "", errors.New("no   team,\nroom or email"))
+		err := b.finish()
+		if got := errorString(err); got != tt.want {
+			t.Errorf("finish() with --fail-on %s = %q, want %q", tt.failOn, got, tt.want)
+		}
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report batchReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Mode != "recipients" || report.Total != 3 || report.Sent != 1 || report.Failed != 2 || len(report.Results) != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	if r := report.Results[1]; r.Status != "failed" || r.Code != 404 || r.TrackingID != "ROUTER_1" {
+		t.Errorf("report.Results[1] = %+v, want failed with code 404 and tracking ID", r)
+	}
+	if r := report.Results[2]; r.Error != "no team, room or email" || r.Code != 0 {
+		t.Errorf("report.Results[2] = %+v, want the error on one line", r)
+	}
+	if report.Finished.Before(report.Started) {
+		t.Errorf("report finished %v before started %v", report.Finished, report.Started)
+	}
+}
+
+func TestBatchItemName(t *testing.T) {
+	if got := newBatch("manifest", "files", "FILE").itemName("deploy.md"); got != "deploy.md" {
+		t.Errorf("itemName() = %q, want deploy.md", got)
+	}
+	if got := newBatch("multi", "messages", "MESSAGE").itemName("2"); got != "message 2" {
+		t.Errorf("itemName() = %q, want message 2", got)
+	}
+}
+
+// errorString returns the message of err or "" if err is nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
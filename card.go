@@ -0,0 +1,328 @@
+// card.go
+//
+// Client-side validation of Adaptive Card attachments (flags -a and -A).
+// Webex silently drops malformed cards, so the structure is checked against
+// the Adaptive Card 1.x schema before sending and errors are reported with
+// their location, e.g. "content.body[2].columns[0]: Column needs items".
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	cardContentType = "application/vnd.microsoft.card.adaptive"
+
+	// newest Adaptive Card version rendered by Webex
+	maxWebexCardVersion = 3
+)
+
+var cardVersionPattern = regexp.MustCompile(`^1\.(\d+)$`)
+
+// cardError is a validation error at a location of the card.
+type cardError struct {
+	path string
+	msg  string
+}
+
+func (e *cardError) Error() string {
+	return fmt.Sprintf("invalid card: %s: %s", e.path, e.msg)
+}
+
+// cardValidator checks a decoded card. Input IDs must be unique per card.
+type cardValidator struct {
+	inputIDs map[string]string
+}
+
+// loadCard returns the card attachment of flag -a or the content of the
+// file of flag -A, validated and wrapped into an attachment if it is a bare
+// AdaptiveCard. YAML card descriptions (.yaml, .yml) are compiled first.
+func loadCard() (string, error) {
+	card := cardAttachment
+	if len(cardFile) > 0 {
+		if len(card) > 0 {
+			return "", errors.New("use either flag -a or flag -A")
+		}
+		data, err := os.ReadFile(cardFile)
+		if err != nil {
+			return "", err
+		}
+		card = string(data)
+		if isCardYAML(cardFile) {
+			card, err = compileCardYAML(card)
+			if err != nil {
+				return "", fmt.Errorf("%s: %v", cardFile, err)
+			}
+		}
+	}
+	if len(card) == 0 {
+		return "", nil
+	}
+	return normalizeCard(expandEnv(card, jsonEscape))
+}
+
+// normalizeCard validates card and returns it as attachment with
+// contentType and content.
+func normalizeCard(card string) (string, error) {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(card))
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	if err != nil {
+		return "", jsonErrorLocation(card, err)
+	}
+
+	attachment, ok := v.(map[string]interface{})
+	if !ok {
+		return "", &cardError{"$", "card must be a JSON object"}
+	}
+	if attachment["type"] == "AdaptiveCard" {
+		attachment = map[string]interface{}{"contentType": cardContentType, "content": attachment}
+	}
+
+	if ct, _ := attachment["contentType"].(string); ct != cardContentType {
+		return "", &cardError{"contentType", fmt.Sprintf("must be %q", cardContentType)}
+	}
+	content, ok := attachment["content"].(map[string]interface{})
+	if !ok {
+		return "", &cardError{"content", "must be an AdaptiveCard object"}
+	}
+	cv := &cardValidator{inputIDs: make(map[string]string)}
+	err = cv.card("content", content, true)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err = enc.Encode(attachment)
+	return strings.TrimSpace(buf.String()), err
+}
+
+// jsonErrorLocation adds line and column to JSON syntax errors.
+func jsonErrorLocation(data string, err error) error {
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 1 || offset > int64(len(data)) {
+		return fmt.Errorf("invalid card JSON: %v", err)
+	}
+	// the offset is after the offending byte
+	before := data[:offset-1]
+	line := strings.Count(before, "\n") + 1
+	column := len(before) - strings.LastIndex(before, "\n")
+	return fmt.Errorf("invalid card JSON at line %d, column %d: %v", line, column, err)
+}
+
+// card checks an AdaptiveCard. The version is required for the top-level
+// card only, not for cards of Action.ShowCard.
+func (cv *cardValidator) card(path string, card map[string]interface{}, topLevel bool) error {
+	if card["type"] != "AdaptiveCard" {
+		return &cardError{path + ".type", `must be "AdaptiveCard"`}
+	}
+	version, hasVersion := card["version"].(string)
+	if topLevel || hasVersion {
+		m := cardVersionPattern.FindStringSubmatch(version)
+		if m == nil {
+			return &cardError{path + ".version", fmt.Sprintf("must be a 1.x version, not %q", version)}
+		}
+		if minor, _ := strconv.Atoi(m[1]); minor > maxWebexCardVersion {
+			slog.Warn("Webex renders Adaptive Cards up to version 1.3", "version", version)
+		}
+	}
+	if err := cv.elements(path+".body", card["body"], false); err != nil {
+		return err
+	}
+	return cv.actions(path+".actions", card["actions"], false)
+}
+
+// elements checks an array of card elements.
+func (cv *cardValidator) elements(path string, v interface{}, required bool) error {
+	if v == nil {
+		if required {
+			return &cardError{path, "is required"}
+		}
+		return nil
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return &cardError{path, "must be an array"}
+	}
+	for i, item := range items {
+		err := cv.element(fmt.Sprintf("%s[%d]", path, i), item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cv *cardValidator) element(path string, v interface{}) error {
+	e, ok := v.(map[string]interface{})
+	if !ok {
+		return &cardError{path, "must be an object"}
+	}
+	typ, _ := e["type"].(string)
+	switch typ {
+	case "TextBlock":
+		return requireString(path, e, "text")
+	case "Image":
+		return requireString(path, e, "url")
+	case "ImageSet":
+		images, ok := e["images"].([]interface{})
+		if !ok {
+			return &cardError{path + ".images", "ImageSet needs an array of images"}
+		}
+		for i, image := range images {
+			m, _ := image.(map[string]interface{})
+			if err := requireString(fmt.Sprintf("%s.images[%d]", path, i), m, "url"); err != nil {
+				return err
+			}
+		}
+	case "RichTextBlock":
+		if _, ok := e["inlines"].([]interface{}); !ok {
+			return &cardError{path + ".inlines", "RichTextBlock needs an array of inlines"}
+		}
+	case "FactSet":
+		facts, ok := e["facts"].([]interface{})
+		if !ok {
+			return &cardError{path + ".facts", "FactSet needs an array of facts"}
+		}
+		for i, fact := range facts {
+			m, _ := fact.(map[string]interface{})
+			factPath := fmt.Sprintf("%s.facts[%d]", path, i)
+			if err := requireString(factPath, m, "title"); err != nil {
+				return err
+			}
+			if err := requireString(factPath, m, "value"); err != nil {
+				return err
+			}
+		}
+	case "Container":
+		return cv.elements(path+".items", e["items"], true)
+	case "ColumnSet":
+		columns, ok := e["columns"].([]interface{})
+		if !ok && e["columns"] != nil {
+			return &cardError{path + ".columns", "must be an array"}
+		}
+		for i, column := range columns {
+			columnPath := fmt.Sprintf("%s.columns[%d]", path, i)
+			m, ok := column.(map[string]interface{})
+			if !ok || m["type"] != nil && m["type"] != "Column" {
+				return &cardError{columnPath, `must be an object of type "Column"`}
+			}
+			if err := cv.elements(columnPath+".items", m["items"], false); err != nil {
+				return err
+			}
+		}
+	case "ActionSet":
+		return cv.actions(path+".actions", e["actions"], true)
+	case "Input.Text", "Input.Number", "Input.Date", "Input.Time", "Input.Toggle", "Input.ChoiceSet":
+		return cv.input(path, typ, e)
+	case "":
+		return &cardError{path + ".type", "is required"}
+	default:
+		return &cardError{path + ".type", fmt.Sprintf("unknown element type %q", typ)}
+	}
+	return nil
+}
+
+func (cv *cardValidator) input(path, typ string, e map[string]interface{}) error {
+	if err := requireString(path, e, "id"); err != nil {
+		return err
+	}
+	id := e["id"].(string)
+	if other, ok := cv.inputIDs[id]; ok {
+		return &cardError{path + ".id", fmt.Sprintf("duplicate input id %q, also used by %s", id, other)}
+	}
+	cv.inputIDs[id] = path
+
+	switch typ {
+	case "Input.Toggle":
+		return requireString(path, e, "title")
+	case "Input.ChoiceSet":
+		choices, ok := e["choices"].([]interface{})
+		if !ok {
+			return &cardError{path + ".choices", "Input.ChoiceSet needs an array of choices"}
+		}
+		for i, choice := range choices {
+			m, _ := choice.(map[string]interface{})
+			choicePath := fmt.Sprintf("%s.choices[%d]", path, i)
+			if err := requireString(choicePath, m, "title"); err != nil {
+				return err
+			}
+			if err := requireString(choicePath, m, "value"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (cv *cardValidator) actions(path string, v interface{}, required bool) error {
+	if v == nil {
+		if required {
+			return &cardError{path, "is required"}
+		}
+		return nil
+	}
+	actions, ok := v.([]interface{})
+	if !ok {
+		return &cardError{path, "must be an array"}
+	}
+	for i, action := range actions {
+		actionPath := fmt.Sprintf("%s[%d]", path, i)
+		a, ok := action.(map[string]interface{})
+		if !ok {
+			return &cardError{actionPath, "must be an object"}
+		}
+		typ, _ := a["type"].(string)
+		switch typ {
+		case "Action.Submit", "Action.ToggleVisibility":
+		case "Action.OpenUrl":
+			if err := requireString(actionPath, a, "url"); err != nil {
+				return err
+			}
+		case "Action.ShowCard":
+			card, ok := a["card"].(map[string]interface{})
+			if !ok {
+				return &cardError{actionPath + ".card", "Action.ShowCard needs a card"}
+			}
+			if err := cv.card(actionPath+".card", card, false); err != nil {
+				return err
+			}
+		case "":
+			return &cardError{actionPath + ".type", "is required"}
+		default:
+			return &cardError{actionPath + ".type", fmt.Sprintf("unknown action type %q", typ)}
+		}
+	}
+	return nil
+}
+
+// requireString checks that the property name of object e is a non-empty string.
+func requireString(path string, e map[string]interface{}, name string) error {
+	if e == nil {
+		return &cardError{path, "must be an object"}
+	}
+	s, ok := e[name].(string)
+	if !ok || len(s) == 0 {
+		return &cardError{path + "." + name, "is required and must be a non-empty string"}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeCard(t *testing.T) {
+	for _, tt := range []struct {
+		card, want string
+	}{
+		{`{"type": "AdaptiveCard", "version": "1.3", "body": [{"type": "TextBlock", "text": "a < b & c"}]}`,
+			`{"content":{"body":[{"text":"a < b & c","type":"TextBlock"}],"type":"AdaptiveCard","version":"1.3"},"contentType":"application/vnd.microsoft.card.adaptive"}`},
+		{testCard,
+			`{"content":{"body":[{"text":"disk full","type":"TextBlock"}],"type":"AdaptiveCard","version":"1.3"},"contentType":"application/vnd.microsoft.card.adaptive"}`},
+		{`{"type": "AdaptiveCard", "version": "1.2", "actions": [{"type": "Action.ShowCard", "title": "More", "card": {"type": "AdaptiveCard", "body": [{"type": "Input.Text", "id": "comment"}]}}]}`,
+			`{"content":{"actions":[{"card":{"body":[{"id":"comment","type":"Input.Text"}],"type":"AdaptiveCard"},"title":"More","type":"Action.ShowCard"}],"type":"AdaptiveCard","version":"1.2"},"contentType":"application/vnd.microsoft.card.adaptive"}`},
+	} {
+		got, err := normalizeCard(tt.card)
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeCard(%s) = %s, %v, want %s", tt.card, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCardErrors(t *testing.T) {
+	card := func(body string) string {
+		return `{"type": "AdaptiveCard", "version": "1.3", "body": [` + body + `]}`
+	}
+	for _, tt := range []struct {
+		card, wantErr string
+	}{
+		{`[]`, "invalid card: $: card must be a JSON object"},
+		{`{"contentType": "text/plain", "content": {}}`, `invalid card: contentType: must be "application/vnd.microsoft.card.adaptive"`},
+		{`{"contentType": "application/vnd.microsoft.card.adaptive", "content": []}`, "invalid card: content: must be an AdaptiveCard object"},
+		{`{"type": "AdaptiveCard", "version": "2.0"}`, `invalid card: content.version: must be a 1.x version, not "2.0"`},
+		{`{"type": "AdaptiveCard"}`, `invalid card: content.version: must be a 1.x version, not ""`},
+		{card(`{"type": "TextBlock"}`), "invalid card: content.body[0].text: is required and must be a non-empty string"},
+		{card(`{"text": "x"}`), "invalid card: content.body[0].type: is required"},
+		{card(`{"type": "Chart.Pie"}`), `invalid card: content.body[0].type: unknown element type "Chart.Pie"`},
+		{card(`{"type": "Container"}`), "invalid card: content.body[0].items: is required"},
+		{card(`{"type": "ColumnSet", "columns": [{"type": "Column", "items": [{"type": "Image"}]}]}`), "invalid card: content.body[0].columns[0].items[0].url: is required"},
+		{card(`{"type": "ColumnSet", "columns": [{"type": "Container"}]}`), `invalid card: content.body[0].columns[0]: must be an object of type "Column"`},
+		{card(`{"type": "FactSet", "facts": [{"title": "Env"}]}`), "invalid card: content.body[0].facts[0].value: is required"},
+		{card(`{"type": "ImageSet", "images": [{}]}`), "invalid card: content.body[0].images[0].url: is required"},
+		{card(`{"type": "RichTextBlock"}`), "invalid card: content.body[0].inlines: RichTextBlock needs an array of inlines"},
+		{card(`{"type": "Input.Text", "id": "x"}, {"type": "Input.Number", "id": "x"}`), `invalid card: content.body[1].id: duplicate input id "x", also used by content.body[0]`},
+		{card(`{"type": "Input.Toggle", "id": "x"}`), "invalid card: content.body[0].title: is required"},
+		{card(`{"type": "Input.ChoiceSet", "id": "x", "choices": [{"title": "Prod"}]}`), "invalid card: content.body[0].choices[0].value: is required"},
+		{card(`{"type": "ActionSet"}`), "invalid card: content.body[0].actions: is required"},
+		{`{"type": "AdaptiveCard", "version": "1.3", "actions": [{"type": "Action.OpenUrl", "title": "x"}]}`, "invalid card: content.actions[0].url: is required"},
+		{`{"type": "AdaptiveCard", "version": "1.3", "actions": [{"type": "Action.Execute"}]}`, `invalid card: content.actions[0].type: unknown action type "Action.Execute"`},
+		{`{"type": "AdaptiveCard", "version": "1.3", "actions": [{"type": "Action.ShowCard"}]}`, "invalid card: content.actions[0].card: Action.ShowCard needs a card"},
+		{`{"type": "AdaptiveCard", "version": "1.3",` + "\n" + `"body": [}`, "invalid card JSON at line 2, column 10"},
+		{`{"type": "AdaptiveCard", "version": 1.3`, "invalid card JSON: unexpected EOF"},
+	} {
+		_, err := normalizeCard(tt.card)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+			t.Errorf("normalizeCard(%s) error = %v, want %s", tt.card, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadCard(t *testing.T) {
+	defer func(a, f string) { cardAttachment, cardFile = a, f }(cardAttachment, cardFile)
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "card.yaml")
+	if err := os.WriteFile(yamlFile, []byte("title: Deployed\ncolor: blue\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(dir, "card.json")
+	if err := os.WriteFile(jsonFile, []byte(testCard), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		attachment, file string
+		want, wantErr    string
+	}{
+		{want: ""},
+		{attachment: testCard, want: `"text":"disk full"`},
+		{file: jsonFile, want: `"text":"disk full"`},
+		{attachment: testCard, file: jsonFile, wantErr: "use either flag -a or flag -A"},
+		{file: yamlFile, wantErr: yamlFile + `: card color "blue" is unknown`},
+		{file: filepath.Join(dir, "missing.json"), wantErr: "no such file"},
+	} {
+		cardAttachment, cardFile = tt.attachment, tt.file
+		got, err := loadCard()
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadCard() of -a %q -A %q error = %v, want %s", tt.attachment, tt.file, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !strings.Contains(got, tt.want) {
+			t.Errorf("loadCard() of -a %q -A %q = %s, %v, want %s", tt.attachment, tt.file, got, err, tt.want)
+		}
+	}
+}
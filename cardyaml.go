@@ -0,0 +1,288 @@
+// cardyaml.go
+//
+// Compiler for simplified card descriptions in YAML (flag -A with a .yaml or
+// .yml file). Instead of writing the Adaptive Card JSON by hand, a card is
+// described by title, text, facts, image, inputs and buttons:
+//
+//	title: Deployment finished
+//	color: good
+//	text: Version **1.2.3** is live
+//	facts:
+//	  Environment: production
+//	  Duration: 4m 12s
+//	inputs:
+//	  - id: comment
+//	    label: Comment
+//	buttons:
+//	  - title: Open dashboard
+//	    url: https://grafana.example.com/d/deploy
+//	  - title: Acknowledge
+//	    data: {action: ack}
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const defaultCardVersion = "1.3"
+
+var (
+	cardYAMLKeys   = []string{"title", "color", "text", "image", "facts", "inputs", "buttons", "version"}
+	cardInputKeys  = []string{"id", "type", "label", "placeholder", "value", "multiline", "required", "choices"}
+	cardButtonKeys = []string{"title", "url", "data"}
+	cardInputTypes = map[string]string{
+		"text":   "Input.Text",
+		"number": "Input.Number",
+		"date":   "Input.Date",
+		"time":   "Input.Time",
+		"toggle": "Input.Toggle",
+		"choice": "Input.ChoiceSet",
+	}
+	cardColors = map[string]string{
+		"default":   "Default",
+		"good":      "Good",
+		"warning":   "Warning",
+		"attention": "Attention",
+		"accent":    "Accent",
+	}
+)
+
+// isCardYAML reports whether the card file is a YAML card description.
+func isCardYAML(filename string) bool {
+	lower := strings.ToLower(filename)
+	return strings.HasSuffix(lower, ".yaml") || strings.HasSuffix(lower, ".yml")
+}
+
+// compileCardYAML compiles a YAML card description to a card attachment.
+func compileCardYAML(data string) (string, error) {
+	doc, err := parseYAML(data)
+	if err != nil {
+		return "", err
+	}
+	desc, ok := doc.(yamlMapping)
+	if !ok {
+		return "", fmt.Errorf("card description must be a mapping with %s", strings.Join(cardYAMLKeys, ", "))
+	}
+	if err := checkYAMLKeys("card", desc, cardYAMLKeys); err != nil {
+		return "", err
+	}
+
+	version := defaultCardVersion
+	if v, ok := desc.Get("version"); ok {
+		version = fmt.Sprint(v)
+	}
+	var body []interface{}
+	var actions []interface{}
+
+	if v, ok := desc.Get("title"); ok {
+		title := map[string]interface{}{"type": "TextBlock", "text": yamlString(v), "size": "Large", "weight": "Bolder", "wrap": true}
+		if c, ok := desc.Get("color"); ok {
+			color, ok := cardColors[strings.ToLower(yamlString(c))]
+			if !ok {
+				return "", fmt.Errorf("card color %q is unknown. use %s", yamlString(c), strings.Join(sortedKeys(cardColors), ", "))
+			}
+			title["color"] = color
+		}
+		body = append(body, title)
+	}
+	if v, ok := desc.Get("text"); ok {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": yamlString(v), "wrap": true})
+	}
+	if v, ok := desc.Get("image"); ok {
+		body = append(body, map[string]interface{}{"type": "Image", "url": yamlString(v)})
+	}
+	if v, ok := desc.Get("facts"); ok {
+		facts, err := compileFacts(v)
+		if err != nil {
+			return "", err
+		}
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	if v, ok := desc.Get("inputs"); ok {
+		items, ok := v.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("card inputs must be a list")
+		}
+		for i, item := range items {
+			input, err := compileInput(i, item)
+			if err != nil {
+				return "", err
+			}
+			body = append(body, input)
+		}
+	}
+	if v, ok := desc.Get("buttons"); ok {
+		items, ok := v.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("card buttons must be a list")
+		}
+		for i, item := range items {
+			action, err := compileButton(i, item)
+			if err != nil {
+				return "", err
+			}
+			actions = append(actions, action)
+		}
+	}
+
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": version,
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"body":    body,
+	}
+	if len(actions) > 0 {
+		card["actions"] = actions
+	}
+	attachment, err := json.Marshal(map[string]interface{}{"contentType": cardContentType, "content": card})
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(attachment))
+}
+
+// compileFacts accepts a mapping (title: value) or a list of single entry
+// mappings.
+func compileFacts(v interface{}) ([]interface{}, error) {
+	var pairs yamlMapping
+	switch v := v.(type) {
+	case yamlMapping:
+		pairs = v
+	case []interface{}:
+		for i, item := range v {
+			m, ok := item.(yamlMapping)
+			if !ok || len(m) != 1 {
+				return nil, fmt.Errorf("card facts[%d] must be a single \"title: value\" entry", i)
+			}
+			pairs = append(pairs, m[0])
+		}
+	default:
+		return nil, fmt.Errorf("card facts must be a mapping of titles to values")
+	}
+	var facts []interface{}
+	for _, p := range pairs {
+		facts = append(facts, map[string]interface{}{"title": p.Key, "value": yamlString(p.Value)})
+	}
+	return facts, nil
+}
+
+func compileInput(i int, v interface{}) (map[string]interface{}, error) {
+	name := fmt.Sprintf("card inputs[%d]", i)
+	desc, ok := v.(yamlMapping)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a mapping", name)
+	}
+	if err := checkYAMLKeys(name, desc, cardInputKeys); err != nil {
+		return nil, err
+	}
+
+	typ := "text"
+	if t, ok := desc.Get("type"); ok {
+		typ = strings.ToLower(yamlString(t))
+	}
+	inputType, ok := cardInputTypes[typ]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown type %q. use %s", name, typ, strings.Join(sortedKeys(cardInputTypes), ", "))
+	}
+
+	input := map[string]interface{}{"type": inputType}
+	for _, p := range desc {
+		switch p.Key {
+		case "id", "placeholder", "value":
+			input[p.Key] = yamlString(p.Value)
+		case "label":
+			input["label"] = yamlString(p.Value)
+			if inputType == "Input.Toggle" {
+				input["title"] = yamlString(p.Value)
+			}
+		case "multiline":
+			input["isMultiline"] = p.Value == true
+		case "required":
+			input["isRequired"] = p.Value == true
+		case "choices":
+			choices, err := compileChoices(name, p.Value)
+			if err != nil {
+				return nil, err
+			}
+			input["choices"] = choices
+		}
+	}
+	return input, nil
+}
+
+// compileChoices accepts a list of values or a mapping of values to titles.
+func compileChoices(name string, v interface{}) ([]interface{}, error) {
+	var choices []interface{}
+	switch v := v.(type) {
+	case []interface{}:
+		for _, c := range v {
+			choices = append(choices, map[string]interface{}{"title": yamlString(c), "value": yamlString(c)})
+		}
+	case yamlMapping:
+		for _, p := range v {
+			choices = append(choices, map[string]interface{}{"title": yamlString(p.Value), "value": p.Key})
+		}
+	default:
+		return nil, fmt.Errorf("%s: choices must be a list or a mapping of values to titles", name)
+	}
+	return choices, nil
+}
+
+func compileButton(i int, v interface{}) (map[string]interface{}, error) {
+	name := fmt.Sprintf("card buttons[%d]", i)
+	desc, ok := v.(yamlMapping)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a mapping", name)
+	}
+	if err := checkYAMLKeys(name, desc, cardButtonKeys); err != nil {
+		return nil, err
+	}
+	title, _ := desc.Get("title")
+	action := map[string]interface{}{"type": "Action.Submit", "title": yamlString(title)}
+	if url, ok := desc.Get("url"); ok {
+		action["type"] = "Action.OpenUrl"
+		action["url"] = yamlString(url)
+	}
+	if data, ok := desc.Get("data"); ok {
+		if action["type"] == "Action.OpenUrl" {
+			return nil, fmt.Errorf("%s: use either url or data", name)
+		}
+		action["data"] = yamlToJSON(data)
+	}
+	return action, nil
+}
+
+// checkYAMLKeys returns an error for keys of m which are not in allowed,
+// e.g. typos like "buttom".
+func checkYAMLKeys(name string, m yamlMapping, allowed []string) error {
+	for _, p := range m {
+		known := false
+		for _, a := range allowed {
+			known = known || p.Key == a
+		}
+		if !known {
+			return fmt.Errorf("%s: unknown key %q. use %s", name, p.Key, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// yamlString returns a scalar as string, nil as empty string.
+func yamlString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompileCardYAML(t *testing.T) {
+	desc := `title: Deployment finished
+color: good
+text: Version **1.2.3** is live
+facts:
+  Environment: production
+  Duration: 4m 12s
+inputs:
+  - id: comment
+    label: Comment
+    multiline: true
+  - id: notify
+    type: toggle
+    label: Notify the team
+  - id: env
+    type: choice
+    choices: {prod: Production, test: Test}
+buttons:
+  - title: Open dashboard
+    url: https://grafana.example.com/d/deploy
+  - title: Acknowledge
+    data: {action: ack}
+`
+	want := `{"contentType": "application/vnd.microsoft.card.adaptive", "content": {
+		"type": "AdaptiveCard", "version": "1.3", "$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"body": [
+			{"type": "TextBlock", "text": "Deployment finished", "size": "Large", "weight": "Bolder", "wrap": true, "color": "Good"},
+			{"type": "TextBlock", "text": "Version **1.2.3** is live", "wrap": true},
+			{"type": "FactSet", "facts": [{"title": "Environment", "value": "production"}, {"title": "Duration", "value": "4m 12s"}]},
+			{"type": "Input.Text", "id": "comment", "label": "Comment", "isMultiline": true},
+			{"type": "Input.Toggle", "id": "notify", "label": "Notify the team", "title": "Notify the team"},
+			{"type": "Input.ChoiceSet", "id": "env", "choices": [{"title": "Production", "value": "prod"}, {"title": "Test", "value": "test"}]}
+		],
+		"actions": [
+			{"type": "Action.OpenUrl", "title": "Open dashboard", "url": "https://grafana.example.com/d/deploy"},
+			{"type": "Action.Submit", "title": "Acknowledge", "data": {"action": "ack"}}
+		]}}`
+
+	card, err := compileCardYAML(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, wantCard interface{}
+	if err := json.Unmarshal([]byte(card), &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantCard); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantCard) {
+		t.Errorf("compileCardYAML() = %s\nwant %s", card, want)
+	}
+}
+
+func TestCompileCardYAMLErrors(t *testing.T) {
+	for _, tt := range []struct {
+		desc, wantErr string
+	}{
+		{"- title: x\n", "card description must be a mapping"},
+		{"title: x\nbuttom: y\n", `card: unknown key "buttom"`},
+		{"title: x\ncolor: pink\n", `card color "pink" is unknown. use accent, attention, default, good, warning`},
+		{"facts: production\n", "card facts must be a mapping"},
+		{"facts:\n  - a: 1\n    b: 2\n", `card facts[0] must be a single "title: value" entry`},
+		{"inputs: comment\n", "card inputs must be a list"},
+		{"inputs:\n  - id: x\n    type: slider\n", `card inputs[0]: unknown type "slider"`},
+		{"inputs:\n  - id: x\n    type: choice\n    choices: prod\n", "card inputs[0]: choices must be a list"},
+		{"buttons:\n  - title: x\n    url: https://x\n    data: {a: b}\n", "card buttons[0]: use either url or data"},
+		{"buttons:\n  - Ack\n", "card buttons[0] must be a mapping"},
+	} {
+		_, err := compileCardYAML(tt.desc)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("compileCardYAML(%q) error = %v, want %s", tt.desc, err, tt.wantErr)
+		}
+	}
+}
+
+func TestIsCardYAML(t *testing.T) {
+	for _, tt := range []struct {
+		file string
+		want bool
+	}{
+		{"card.yaml", true},
+		{"cards/Deploy.YML", true},
+		{"card.json", false},
+		{"yaml", false},
+	} {
+		if got := isCardYAML(tt.file); got != tt.want {
+			t.Errorf("isCardYAML(%q) = %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
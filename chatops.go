@@ -0,0 +1,264 @@
+// chatops.go
+//
+// Chatops bot (serve --chatops). Messages to the bot, received by the relay
+// on /webhook, run the commands of the "commands" table of the config file
+// (-c) and the output is posted as reply:
+//
+//	"commands": [
+//	  { "name": "uptime", "command": ["uptime"], "allow": ["*@example.com"] },
+//	  { "name": "restart", "command": ["sudo", "systemctl", "restart", "app"],
+//	    "description": "restart the app", "allow": ["ops@example.com"], "timeout": "2m" },
+//	  { "name": "logs", "command": ["journalctl", "-n", "50", "-u"], "args": true,
+//	    "allow": ["ops@example.com"] }
+//	]
+//
+// Only the listed commands run, without a shell, and only for the persons
+// of their allow list (email addresses, *@domain or *). The words after the
+// name are passed as arguments only if "args" is true. "help" lists the
+// commands the sender may run. The webhook for the bot is created with
+// "webhooks create --resource messages --event created".
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+const (
+	// default timeout of a chatops command
+	chatDefaultTimeout = time.Minute
+	// output of a command in the reply
+	chatOutputMax = 4000
+)
+
+// chatCommand is an entry of the commands table of the config file.
+type chatCommand struct {
+	Name        string   `json:"name"`
+	Command     []string `json:"command"`
+	Description string   `json:"description"`
+	Args        bool     `json:"args"`
+	Allow       []string `json:"allow"`
+	Timeout     string   `json:"timeout"`
+	timeout     time.Duration
+}
+
+// chatBot answers the messages to the bot.
+type chatBot struct {
+	client   *webex.Client
+	me       *webex.Person
+	commands map[string]*chatCommand
+	// commands in progress, waited for on shutdown
+	running sync.WaitGroup
+}
+
+// bot is the chatops bot of serve --chatops, nil without.
+var bot *chatBot
+
+// newChatBot returns the bot with the commands of the config file.
+func newChatBot() (*chatBot, error) {
+	if len(configFile) == 0 {
+		return nil, errors.New("--chatops needs the commands of a config file. use flag -c")
+	}
+	c, err := loadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Commands) == 0 {
+		return nil, fmt.Errorf("no commands in config file %s", configFile)
+	}
+	b := &chatBot{commands: make(map[string]*chatCommand)}
+	for _, cmd := range c.Commands {
+		name := strings.ToLower(cmd.Name)
+		switch {
+		case len(name) == 0 || strings.ContainsAny(name, " \t"):
+			return nil, fmt.Errorf("config file %s: invalid command name %q", configFile, cmd.Name)
+		case name == "help":
+			return nil, fmt.Errorf("config file %s: help is a built-in command", configFile)
+		case len(cmd.Command) == 0:
+			return nil, fmt.Errorf("config file %s, command %s: no command", configFile, cmd.Name)
+		case len(cmd.Allow) == 0:
+			return nil, fmt.Errorf("config file %s, command %s: no allow list. use [\"*\"] to allow everyone", configFile, cmd.Name)
+		case b.commands[name] != nil:
+			return nil, fmt.Errorf("config file %s: command %s defined twice", configFile, cmd.Name)
+		}
+		cmd.timeout = chatDefaultTimeout
+		if len(cmd.Timeout) > 0 {
+			cmd.timeout, err = time.ParseDuration(cmd.Timeout)
+			if err != nil || cmd.timeout <= 0 {
+				return nil, fmt.Errorf("config file %s, command %s: invalid timeout %q", configFile, cmd.Name, cmd.Timeout)
+			}
+		}
+		b.commands[name] = cmd
+	}
+
+	b.client, err = webexClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	b.me, err = b.client.Me(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("chatops bot ready", "bot", b.me.DisplayName, "commands", len(b.commands))
+	return b, nil
+}
+
+// handle answers the message of a messages/created webhook event.
+func (b *chatBot) handle(ev *webex.WebhookEvent) {
+	var data struct {
+		ID       string `json:"id"`
+		PersonID string `json:"personId"`
+	}
+	if err := json.Unmarshal(ev.Data, &data); err != nil || len(data.ID) == 0 {
+		slog.Warn("chatops: invalid message event", "error", err)
+		return
+	}
+	if data.PersonID == b.me.ID {
+		// the replies of the bot
+		return
+	}
+	b.running.Add(1)
+	go func() {
+		defer b.running.Done()
+		b.answer(data.ID)
+	}()
+}
+
+// answer runs the command of the message and replies with its output.
+func (b *chatBot) answer(messageID string) {
+	ctx, cancel := requestContext()
+	msg, err := b.client.GetMessage(ctx, messageID)
+	cancel()
+	if err != nil {
+		slog.Error("chatops: getting the message failed", "messageID", messageID, "error", err)
+		return
+	}
+	fields := strings.Fields(b.stripMention(msg.Text))
+	if len(fields) == 0 {
+		return
+	}
+	name, args := strings.ToLower(fields[0]), fields[1:]
+
+	var reply string
+	cmd := b.commands[name]
+	switch {
+	case name == "help":
+		reply = b.help(msg.PersonEmail)
+	case cmd == nil || !cmd.allowed(msg.PersonEmail):
+		if cmd != nil {
+			slog.Warn("chatops: command not allowed", "command", name, "person", msg.PersonEmail)
+		}
+		// the same answer, so the commands of others are not revealed
+		reply = fmt.Sprintf("unknown command `%s`. use `help`", name)
+	case len(args) > 0 && !cmd.Args:
+		reply = fmt.Sprintf("`%s` takes no arguments", name)
+	default:
+		reply = cmd.run(args, msg.PersonEmail)
+	}
+
+	parentID := msg.ParentID
+	if len(parentID) == 0 {
+		parentID = msg.ID
+	}
+	ctx, cancel = requestContext()
+	defer cancel()
+	_, err = b.client.CreateMessage(ctx, &webex.MessageRequest{RoomID: msg.RoomID, ParentID: parentID, Markdown: reply})
+	if err != nil {
+		slog.Error("chatops: replying failed", "command", name, "error", err)
+	}
+}
+
+// stripMention removes the mention of the bot in a group room, e.g. "Bot
+// uptime", from the text of a message.
+func (b *chatBot) stripMention(text string) string {
+	text = strings.TrimSpace(text)
+	first, _, _ := strings.Cut(b.me.DisplayName, " ")
+	for _, name := range []string{b.me.DisplayName, b.me.NickName, first} {
+		if len(name) > 0 && len(text) >= len(name) && strings.EqualFold(text[:len(name)], name) {
+			return text[len(name):]
+		}
+	}
+	return text
+}
+
+// help lists the commands email may run.
+func (b *chatBot) help(email string) string {
+	var lines []string
+	for name, cmd := range b.commands {
+		if !cmd.allowed(email) {
+			continue
+		}
+		line := "- `" + name
+		if cmd.Args {
+			line += " <args>"
+		}
+		line += "`"
+		if len(cmd.Description) > 0 {
+			line += " " + cmd.Description
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "you may not run any commands"
+	}
+	sort.Strings(lines)
+	return "commands:\n" + strings.Join(lines, "\n")
+}
+
+// allowed reports whether the person with the email address may run the
+// command.
+func (c *chatCommand) allowed(email string) bool {
+	email = strings.ToLower(email)
+	for _, a := range c.Allow {
+		a = strings.ToLower(a)
+		if a == "*" || a == email || (strings.HasPrefix(a, "*@") && strings.HasSuffix(email, a[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// run runs the command with args and returns the reply.
+func (c *chatCommand) run(args []string, email string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	output := &tailBuffer{max: runOutputMax}
+	cmd := exec.CommandContext(ctx, c.Command[0], append(c.Command[1:len(c.Command):len(c.Command)], args...)...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// children keeping the output open do not block the reply
+	cmd.WaitDelay = 5 * time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start).Round(time.Millisecond)
+	slog.Info("chatops command", "command", c.Name, "args", args, "person", email, "duration", duration, "error", err)
+
+	status := fmt.Sprintf("✅ `%s` done in %s", c.Name, duration)
+	switch {
+	case ctx.Err() != nil:
+		status = fmt.Sprintf("❌ `%s` timed out after %s", c.Name, c.timeout)
+	case err != nil && cmd.ProcessState == nil:
+		status = fmt.Sprintf("❌ `%s` could not be started", c.Name)
+		slog.Error("chatops: starting the command failed", "command", c.Name, "error", err)
+	case err != nil:
+		status = fmt.Sprintf("❌ `%s` failed with exit code %d after %s", c.Name, cmd.ProcessState.ExitCode(), duration)
+	}
+	if out := strings.TrimSpace(output.String()); len(out) > 0 {
+		block, _ := codeBlock(outputTail(out, chatOutputMax), "", chatOutputMax+100)
+		status += "\n" + block
+	}
+	return status
+}
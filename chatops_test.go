@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestChatCommandAllowed(t *testing.T) {
+	for _, tt := range []struct {
+		allow []string
+		email string
+		want  bool
+	}{
+		{[]string{"ops@example.com"}, "ops@example.com", true},
+		{[]string{"ops@example.com"}, "OPS@Example.com", true},
+		{[]string{"ops@example.com"}, "dev@example.com", false},
+		{[]string{"*@example.com"}, "dev@example.com", true},
+		{[]string{"*@example.com"}, "dev@badexample.com", false},
+		{[]string{"*@example.com"}, "dev@example.com.evil.org", false},
+		{[]string{"*"}, "anyone@example.org", true},
+		{nil, "ops@example.com", false},
+	} {
+		c := &chatCommand{Allow: tt.allow}
+		if got := c.allowed(tt.email); got != tt.want {
+			t.Errorf("allowed(%q) with allow %q = %v, want %v", tt.email, tt.allow, got, tt.want)
+		}
+	}
+}
+
+func TestChatStripMention(t *testing.T) {
+	b := &chatBot{me: &webex.Person{DisplayName: "Ops Bot", NickName: "ops"}}
+	for _, tt := range []struct{ text, want string }{
+		{"Ops Bot uptime", " uptime"},
+		{"ops bot logs app", " logs app"},
+		{"Ops uptime", " uptime"},
+		{"uptime", "uptime"},
+	} {
+		if got := b.stripMention(tt.text); got != tt.want {
+			t.Errorf("stripMention(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestServeNeedsSecret(t *testing.T) {
+	defer func() { chatops, forwardURL, ackButton, webhookSecret = false, "", false, "" }()
+	for _, tt := range []struct {
+		chatops bool
+		forward string
+		ack     bool
+	}{
+		{chatops: true},
+		{forward: "https://awx.example.com/api/v2/job_templates/42/launch/"},
+		{ack: true},
+	} {
+		chatops, forwardURL, ackButton, webhookSecret = tt.chatops, tt.forward, tt.ack, ""
+		if err := cmdServe("", nil); err == nil || !strings.Contains(err.Error(), "--secret") {
+			t.Errorf("cmdServe() with %+v and without secret: error = %v", tt, err)
+		}
+	}
+}
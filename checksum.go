@@ -0,0 +1,102 @@
+// checksum.go
+//
+// Checksums of the files (flag --checksum). The digest of every file sent
+// is added to the message, so the recipients can verify the artifact, e.g.
+// of a release:
+//
+//	release 2.4.1
+//
+//	sha256 `9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08` shop-2.4.1.tar.gz
+//
+// The algorithms are sha256, sha512, sha1 and md5.
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var checksumHashes = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+// checkChecksum checks the algorithm of --checksum.
+func checkChecksum(algorithm string) error {
+	if len(algorithm) == 0 {
+		return nil
+	}
+	if _, ok := checksumHashes[algorithm]; !ok {
+		return fmt.Errorf("invalid --checksum %q. use sha256, sha512, sha1 or md5", algorithm)
+	}
+	if maxImageSize > 0 {
+		// the checksum of a downscaled image would not match the original
+		return errors.New("--checksum can not be combined with --max-image-size")
+	}
+	return nil
+}
+
+// fileChecksum returns the digest of the file as hex string.
+func fileChecksum(algorithm, file string) (string, error) {
+	newHash, ok := checksumHashes[algorithm]
+	if !ok {
+		return "", fmt.Errorf("invalid checksum algorithm %q", algorithm)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// applyChecksums adds the checksums of the files of n to its message and
+// marks n, so a spooled or retried n gets no second list.
+func applyChecksums(n *notification) error {
+	if n.ChecksumsAdded {
+		return nil
+	}
+	markdown, err := withChecksums(n.Markdown, checksumAlgo, notificationFiles(n))
+	if err != nil {
+		return err
+	}
+	n.Markdown = markdown
+	n.ChecksumsAdded = len(checksumAlgo) > 0
+	return nil
+}
+
+// withChecksums returns markdown with the checksums of the files added.
+func withChecksums(markdown, algorithm string, files []string) (string, error) {
+	if len(algorithm) == 0 || len(files) == 0 {
+		return markdown, nil
+	}
+	lines := make([]string, len(files))
+	for i, file := range files {
+		sum, err := fileChecksum(algorithm, file)
+		if err != nil {
+			return "", fmt.Errorf("checksum: %w", err)
+		}
+		lines[i] = fmt.Sprintf("%s `%s` %s", algorithm, sum, filepath.Base(file))
+	}
+	list := strings.Join(lines, "\n")
+	if len(strings.TrimSpace(markdown)) == 0 {
+		return list, nil
+	}
+	return strings.TrimRight(markdown, "\n") + "\n\n" + list, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithChecksums(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "release.tar.gz")
+	if err := os.WriteFile(file, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		markdown, algorithm string
+		files               []string
+		want                string
+	}{
+		{"release 4.2", "", []string{file}, "release 4.2"},
+		{"release 4.2", "sha256", nil, "release 4.2"},
+		{"release 4.2\n", "sha256", []string{file}, "release 4.2\n\nsha256 `ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad` release.tar.gz"},
+		{"", "md5", []string{file, file}, "md5 `900150983cd24fb0d6963f7d28e17f72` release.tar.gz\nmd5 `900150983cd24fb0d6963f7d28e17f72` release.tar.gz"},
+		{"x", "sha1", []string{file}, "x\n\nsha1 `a9993e364706816aba3e25717850c26c9cd0d89d` release.tar.gz"},
+	} {
+		if got, err := withChecksums(tt.markdown, tt.algorithm, tt.files); err != nil || got != tt.want {
+			t.Errorf("withChecksums(%q, %q) = %q, %v, want %q", tt.markdown, tt.algorithm, got, err, tt.want)
+		}
+	}
+	if _, err := withChecksums("x", "sha256", []string{filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("withChecksums() of a missing file: error = nil")
+	}
+}
+
+func TestApplyChecksumsOnce(t *testing.T) {
+	defer func(algo string) { checksumAlgo = algo }(checksumAlgo)
+	checksumAlgo = "sha256"
+	file := filepath.Join(t.TempDir(), "release.tar.gz")
+	if err := os.WriteFile(file, []byte("abc"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	n := &notification{Markdown: "release 4.2", File: file}
+	for i := 0; i < 2; i++ {
+		if err := applyChecksums(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "release 4.2\n\nsha256 `ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad` release.tar.gz"
+	if n.Markdown != want || !n.ChecksumsAdded {
+		t.Errorf("applyChecksums() twice = %q, want %q", n.Markdown, want)
+	}
+}
+
+func TestCheckChecksum(t *testing.T) {
+	defer func(size byteSize) { maxImageSize = size }(maxImageSize)
+	for _, tt := range []struct {
+		algorithm string
+		imageSize byteSize
+		wantErr   bool
+	}{
+		{"", 0, false},
+		{"", 1024, false},
+		{"sha512", 0, false},
+		{"crc32", 0, true},
+		{"sha256", 1024, true},
+	} {
+		maxImageSize = tt.imageSize
+		if err := checkChecksum(tt.algorithm); tt.wantErr != (err != nil) {
+			t.Errorf("checkChecksum(%q) with --max-image-size %d: error = %v", tt.algorithm, tt.imageSize, err)
+		}
+	}
+}
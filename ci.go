@@ -0,0 +1,215 @@
+// ci.go
+//
+// Build status card (flag --ci-card). The build is taken from the
+// environment variables of GitHub Actions, GitLab CI, Jenkins or Azure
+// DevOps: project, pipeline, job, branch, commit, the URL of the job and,
+// where available, the status and the duration. The status can be given by
+// --ci-status, e.g. --ci-status ${{ job.status }} on GitHub. With
+// "run --ci-card -- <command>" the status and duration are those of the
+// command.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ciBuild is the build of the CI environment.
+type ciBuild struct {
+	System      string
+	Project     string
+	Pipeline    string
+	Job         string
+	Branch      string
+	Commit      string
+	CommitTitle string
+	CommitURL   string
+	URL         string
+	User        string
+	Status      string
+	Started     time.Time
+	Duration    time.Duration
+}
+
+// runDuration is the duration of the command of run for --ci-card.
+var runDuration time.Duration
+
+// detectCI returns the build of the CI environment.
+func detectCI() (*ciBuild, error) {
+	env := os.Getenv
+	var b *ciBuild
+	switch {
+	case env("GITHUB_ACTIONS") == "true":
+		server, repo := env("GITHUB_SERVER_URL"), env("GITHUB_REPOSITORY")
+		b = &ciBuild{
+			System:    "GitHub Actions",
+			Project:   repo,
+			Pipeline:  env("GITHUB_WORKFLOW"),
+			Job:       env("GITHUB_JOB"),
+			Branch:    env("GITHUB_REF_NAME"),
+			Commit:    env("GITHUB_SHA"),
+			CommitURL: server + "/" + repo + "/commit/" + env("GITHUB_SHA"),
+			URL:       server + "/" + repo + "/actions/runs/" + env("GITHUB_RUN_ID"),
+			User:      env("GITHUB_ACTOR"),
+		}
+	case env("GITLAB_CI") == "true":
+		b = &ciBuild{
+			System:      "GitLab CI",
+			Project:     env("CI_PROJECT_PATH"),
+			Pipeline:    "#" + env("CI_PIPELINE_IID"),
+			Job:         env("CI_JOB_NAME"),
+			Branch:      env("CI_COMMIT_REF_NAME"),
+			Commit:      env("CI_COMMIT_SHA"),
+			CommitTitle: env("CI_COMMIT_TITLE"),
+			CommitURL:   env("CI_PROJECT_URL") + "/-/commit/" + env("CI_COMMIT_SHA"),
+			URL:         env("CI_JOB_URL"),
+			User:        env("GITLAB_USER_LOGIN"),
+			Status:      env("CI_JOB_STATUS"),
+		}
+		b.Started, _ = time.Parse(time.RFC3339, env("CI_JOB_STARTED_AT"))
+	case len(env("JENKINS_URL")) > 0:
+		branch := env("BRANCH_NAME")
+		if len(branch) == 0 {
+			branch = strings.TrimPrefix(env("GIT_BRANCH"), "origin/")
+		}
+		b = &ciBuild{
+			System:   "Jenkins",
+			Project:  env("JOB_NAME"),
+			Pipeline: "#" + env("BUILD_NUMBER"),
+			Branch:   branch,
+			Commit:   env("GIT_COMMIT"),
+			URL:      env("BUILD_URL"),
+		}
+	case strings.EqualFold(env("TF_BUILD"), "true"):
+		b = &ciBuild{
+			System:      "Azure DevOps",
+			Project:     env("BUILD_REPOSITORY_NAME"),
+			Pipeline:    env("BUILD_DEFINITIONNAME") + " " + env("BUILD_BUILDNUMBER"),
+			Job:         env("SYSTEM_JOBDISPLAYNAME"),
+			Branch:      env("BUILD_SOURCEBRANCHNAME"),
+			Commit:      env("BUILD_SOURCEVERSION"),
+			CommitTitle: env("BUILD_SOURCEVERSIONMESSAGE"),
+			URL:         env("SYSTEM_COLLECTIONURI") + env("SYSTEM_TEAMPROJECT") + "/_build/results?buildId=" + env("BUILD_BUILDID"),
+			User:        env("BUILD_REQUESTEDFOR"),
+			Status:      env("AGENT_JOBSTATUS"),
+		}
+	default:
+		return nil, errors.New("no CI environment found. --ci-card supports GitHub Actions, GitLab CI, Jenkins and Azure DevOps")
+	}
+
+	if len(ciStatus) > 0 {
+		b.Status = ciStatus
+	}
+	b.Status = ciStatusOf(b.Status)
+	switch {
+	case runDuration > 0:
+		b.Duration = runDuration
+	case !b.Started.IsZero():
+		b.Duration = time.Since(b.Started).Round(time.Second)
+	}
+	return b, nil
+}
+
+// ciStatusOf maps the status names of the CI systems to success, failure,
+// canceled or unknown.
+func ciStatusOf(status string) string {
+	switch strings.ToLower(status) {
+	case "success", "succeeded", "passed", "ok":
+		return "success"
+	case "failure", "failed", "error", "succeededwithissues":
+		return "failure"
+	case "canceled", "cancelled", "aborted":
+		return "canceled"
+	}
+	return "unknown"
+}
+
+// title returns the headline of the build, e.g. "Build failed: org/app".
+func (b *ciBuild) title() string {
+	status := map[string]string{"success": "✅ Build succeeded", "failure": "❌ Build failed", "canceled": "⏹️ Build canceled"}[b.Status]
+	if len(status) == 0 {
+		status = "Build finished"
+	}
+	return tr(status) + ": " + b.Project
+}
+
+// text returns the build as markdown, the message of clients without cards.
+func (b *ciBuild) text(message string) string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "**%s**", b.title())
+	if len(b.Branch) > 0 {
+		fmt.Fprintf(&s, " (%s)", b.Branch)
+	}
+	if len(b.URL) > 0 {
+		fmt.Fprintf(&s, " [%s](%s)", b.System, b.URL)
+	}
+	if len(message) > 0 {
+		s.WriteString("\n" + message)
+	}
+	return s.String()
+}
+
+// card returns the card attachment of the build.
+func (b *ciBuild) card(message string) (string, error) {
+	var facts []interface{}
+	addFact := func(title, value string) {
+		if len(strings.TrimSpace(value)) > 0 {
+			facts = append(facts, map[string]interface{}{"title": title, "value": value})
+		}
+	}
+	commit := b.Commit
+	if len(commit) > 8 {
+		commit = commit[:8]
+	}
+	if len(b.CommitTitle) > 0 {
+		commit += " " + b.CommitTitle
+	}
+	addFact(tr("Pipeline"), strings.Trim(b.Pipeline, "# "))
+	addFact(tr("Job"), b.Job)
+	addFact(tr("Branch"), b.Branch)
+	addFact(tr("Commit"), commit)
+	addFact(tr("Triggered by"), b.User)
+	if b.Duration > 0 {
+		addFact(tr("Duration"), b.Duration.String())
+	}
+
+	color := map[string]string{"success": "good", "failure": "attention", "canceled": "warning"}[b.Status]
+	if len(color) == 0 {
+		color = "default"
+	}
+	items := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": b.title(), "size": "Large", "weight": "Bolder", "wrap": true},
+	}
+	if len(message) > 0 {
+		items = append(items, map[string]interface{}{"type": "TextBlock", "text": message, "wrap": true})
+	}
+	if len(facts) > 0 {
+		items = append(items, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	var actions []interface{}
+	if len(b.URL) > 0 {
+		actions = append(actions, map[string]interface{}{"type": "Action.OpenUrl", "title": trf("Open in %s", b.System), "url": b.URL})
+	}
+	if len(b.CommitURL) > 0 && len(b.Commit) > 0 {
+		actions = append(actions, map[string]interface{}{"type": "Action.OpenUrl", "title": tr("Open commit"), "url": b.CommitURL})
+	}
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": defaultCardVersion,
+		"body": []interface{}{
+			map[string]interface{}{"type": "Container", "style": color, "bleed": true, "items": items},
+		},
+	}
+	if len(actions) > 0 {
+		card["actions"] = actions
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectCI(t *testing.T) {
+	defer func(status string, d time.Duration) { ciStatus, runDuration = status, d }(ciStatus, runDuration)
+	for _, tt := range []struct {
+		name      string
+		env       map[string]string
+		status    string
+		wantTitle string
+		wantURL   string
+		wantErr   bool
+	}{
+		{name: "none", wantErr: true},
+		{name: "github", env: map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SERVER_URL": "https://github.com", "GITHUB_REPOSITORY": "org/app",
+			"GITHUB_RUN_ID": "42", "GITHUB_REF_NAME": "main"}, status: "failure",
+			wantTitle: "❌ Build failed: org/app", wantURL: "https://github.com/org/app/actions/runs/42"},
+		{name: "gitlab", env: map[string]string{"GITLAB_CI": "true", "CI_PROJECT_PATH": "org/app", "CI_JOB_STATUS": "success",
+			"CI_JOB_URL": "https://gitlab.example.com/org/app/-/jobs/7"},
+			wantTitle: "✅ Build succeeded: org/app", wantURL: "https://gitlab.example.com/org/app/-/jobs/7"},
+		{name: "jenkins", env: map[string]string{"JENKINS_URL": "https://jenkins.example.com/", "JOB_NAME": "app", "GIT_BRANCH": "origin/main",
+			"BUILD_URL": "https://jenkins.example.com/job/app/3/"}, status: "ABORTED",
+			wantTitle: "⏹️ Build canceled: app", wantURL: "https://jenkins.example.com/job/app/3/"},
+		{name: "azure", env: map[string]string{"TF_BUILD": "True", "BUILD_REPOSITORY_NAME": "app", "AGENT_JOBSTATUS": "SucceededWithIssues",
+			"SYSTEM_COLLECTIONURI": "https://dev.azure.com/org/", "SYSTEM_TEAMPROJECT": "proj", "BUILD_BUILDID": "9"},
+			wantTitle: "❌ Build failed: app", wantURL: "https://dev.azure.com/org/proj/_build/results?buildId=9"},
+	} {
+		for _, name := range []string{"GITHUB_ACTIONS", "GITLAB_CI", "JENKINS_URL", "TF_BUILD"} {
+			t.Setenv(name, "")
+		}
+		for name, value := range tt.env {
+			t.Setenv(name, value)
+		}
+		ciStatus, runDuration = tt.status, 0
+		b, err := detectCI()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: detectCI() error = nil", tt.name)
+			}
+			continue
+		}
+		if err != nil || b.title() != tt.wantTitle || b.URL != tt.wantURL {
+			t.Errorf("%s: detectCI() = %q, %q, %v, want %q, %q", tt.name, b.title(), b.URL, err, tt.wantTitle, tt.wantURL)
+		}
+	}
+}
+
+func TestCIBuildText(t *testing.T) {
+	b := &ciBuild{System: "GitHub Actions", Project: "org/app", Branch: "main", URL: "https://github.com/org/app/actions/runs/42", Status: "unknown"}
+	if got, want := b.text("deployed"), "**Build finished: org/app** (main) [GitHub Actions](https://github.com/org/app/actions/runs/42)\ndeployed"; got != want {
+		t.Errorf("text() = %q, want %q", got, want)
+	}
+	b.Commit, b.CommitTitle = "0123456789abcdef", "fix login"
+	card, err := b.card("")
+	if err != nil || !strings.Contains(card, `"01234567 fix login"`) {
+		t.Errorf("card() = %s, %v", card, err)
+	}
+}
@@ -0,0 +1,159 @@
+// circuit.go
+//
+// Circuit breaker of the sends (flags --circuit-threshold, --circuit-cooldown
+// and --circuit-alert-cmd). After --circuit-threshold consecutive sends failed
+// for a transient reason, e.g. during a Webex outage, the circuit opens: the
+// receiver and daemon modes stop sending to the API and spool the incoming
+// events with --spool, or reject them. After --circuit-cooldown a single send,
+// usually of the spool flusher, probes the API. The circuit closes when it
+// succeeds and stays open for another cooldown when it fails.
+//
+// The opening is reported once, on channels not depending on Webex: an error
+// log record, the metric notify_circuit_open, the readiness probe /readyz and
+// the command of --circuit-alert-cmd, e.g. a mail or SMS script:
+//
+//	notify_by_webex_teams serve --listen :8080 --spool --circuit-alert-cmd "/usr/local/bin/page-oncall webex-down"
+//
+// The command is run without a shell, with the environment variables
+// NOTIFY_CIRCUIT (open or closed) and NOTIFY_CIRCUIT_ERROR, when the circuit
+// opens and when it closes again.
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// circuitAlertTimeout limits the run time of --circuit-alert-cmd.
+const circuitAlertTimeout = time.Minute
+
+// errCircuitOpen is returned for sends rejected while the circuit is open.
+var errCircuitOpen = errors.New("Webex API circuit open after repeated failures, not sending")
+
+// circuitBreaker counts the consecutive transient failures of the sends.
+type circuitBreaker struct {
+	sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	// probedAt is the time of the opening or of the last failed probe
+	probedAt time.Time
+	// probing is set while the single send after the cooldown is running
+	probing bool
+	opens   uint64
+}
+
+var circuit circuitBreaker
+
+// allow reports whether a send may go to the API: always while the circuit
+// is closed, once per cooldown while it is open.
+func (c *circuitBreaker) allow() bool {
+	if circuitThresh <= 0 {
+		return true
+	}
+	c.Lock()
+	defer c.Unlock()
+	if !c.open {
+		return true
+	}
+	if c.probing || time.Since(c.probedAt) < circuitCooldown {
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+// record counts the result of a send allowed by allow. Only transient
+// failures count, any other result shows that the API is reachable.
+func (c *circuitBreaker) record(err error) {
+	if circuitThresh <= 0 {
+		return
+	}
+	c.Lock()
+	wasOpen := c.open
+	c.probing = false
+	if err != nil && isTransient(err) {
+		c.failures++
+		if c.open {
+			// the probe failed, wait for another cooldown
+			c.probedAt = time.Now()
+		} else if c.failures >= circuitThresh {
+			c.open, c.openedAt, c.probedAt = true, time.Now(), time.Now()
+			c.opens++
+		}
+	} else {
+		c.failures, c.open = 0, false
+	}
+	opened, closed := c.open && !wasOpen, wasOpen && !c.open
+	failures, openedAt := c.failures, c.openedAt
+	c.Unlock()
+
+	switch {
+	case opened:
+		slog.Error("circuit open: the Webex API failed repeatedly, sends are paused", "failures", failures, "cooldown", circuitCooldown, "spool", useSpool, "error", err)
+		go runCircuitAlert("open", err)
+	case closed:
+		slog.Info("circuit closed: the Webex API is reachable again", "openFor", time.Since(openedAt).Round(time.Second))
+		go runCircuitAlert("closed", nil)
+	}
+}
+
+// state returns whether the circuit is open and the number of openings.
+func (c *circuitBreaker) state() (open bool, opens uint64) {
+	c.Lock()
+	defer c.Unlock()
+	return c.open, c.opens
+}
+
+// sendGuarded sends n unless the circuit is open and records the result.
+func sendGuarded(ctx context.Context, n *notification) error {
+	if !circuit.allow() {
+		return errCircuitOpen
+	}
+	err := sendNotification(ctx, n)
+	circuit.record(err)
+	return err
+}
+
+// runCircuitAlert runs the command of --circuit-alert-cmd for the new state
+// of the circuit.
+func runCircuitAlert(state string, cause error) {
+	if len(circuitAlertCmd) == 0 {
+		return
+	}
+	args, err := splitCommandLine(circuitAlertCmd)
+	if err != nil || len(args) == 0 {
+		slog.Error("invalid --circuit-alert-cmd", "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), circuitAlertTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "NOTIFY_CIRCUIT="+state)
+	if cause != nil {
+		cmd.Env = append(cmd.Env, "NOTIFY_CIRCUIT_ERROR="+redact(cause.Error()))
+	}
+	output := &tailBuffer{max: preUploadOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = 5 * time.Second
+	if err := cmd.Run(); err != nil {
+		slog.Error("--circuit-alert-cmd failed", "circuit", state, "error", err, "output", strings.Join(strings.Fields(output.String()), " "))
+		return
+	}
+	slog.Info("circuit alert sent", "circuit", state)
+}
+
+// checkCircuit returns an error while the circuit is open, for /readyz.
+func checkCircuit() error {
+	if open, _ := circuit.state(); open {
+		return errCircuitOpen
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	defer func(thresh int, cooldown time.Duration) { circuitThresh, circuitCooldown = thresh, cooldown }(circuitThresh, circuitCooldown)
+	circuitThresh, circuitCooldown = 3, time.Hour
+	outage := &webex.APIError{StatusCode: http.StatusBadGateway}
+
+	var c circuitBreaker
+	for i := 0; i < 2; i++ {
+		c.record(outage)
+	}
+	// a permanent error shows that the API is reachable
+	c.record(errors.New("no team"))
+	for i := 0; i < 2; i++ {
+		c.record(outage)
+	}
+	if open, _ := c.state(); open || !c.allow() {
+		t.Fatalf("circuit open after 2 consecutive failures, threshold 3")
+	}
+	c.record(outage)
+	if open, opens := c.state(); !open || opens != 1 {
+		t.Fatalf("state() = %v, %d after 3 consecutive failures, want open once", open, opens)
+	}
+	if c.allow() {
+		t.Errorf("allow() = true during the cooldown")
+	}
+
+	// after the cooldown a single probe is allowed
+	c.probedAt = time.Now().Add(-2 * time.Hour)
+	if !c.allow() {
+		t.Fatalf("allow() = false after the cooldown")
+	}
+	if c.allow() {
+		t.Errorf("allow() = true for a second send while probing")
+	}
+	c.record(outage)
+	if open, _ := c.state(); !open || c.allow() {
+		t.Errorf("circuit closed or allowed after a failed probe")
+	}
+
+	c.probedAt = time.Now().Add(-2 * time.Hour)
+	if !c.allow() {
+		t.Fatalf("allow() = false after the second cooldown")
+	}
+	c.record(nil)
+	if open, opens := c.state(); open || opens != 1 || !c.allow() {
+		t.Errorf("state() = %v, %d after a successful probe, want closed", open, opens)
+	}
+
+	circuitThresh = 0
+	var disabled circuitBreaker
+	for i := 0; i < 10; i++ {
+		disabled.record(outage)
+	}
+	if open, _ := disabled.state(); open || !disabled.allow() {
+		t.Errorf("circuit open without --circuit-threshold")
+	}
+}
+
+func TestSendGuarded(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(thresh int, cooldown time.Duration) { circuitThresh, circuitCooldown = thresh, cooldown }(circuitThresh, circuitCooldown)
+	circuitThresh, circuitCooldown = 2, time.Hour
+
+	n := &notification{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "disk full"}
+	fake.Fail(2, http.StatusServiceUnavailable)
+	for i := 0; i < 2; i++ {
+		if err := sendGuarded(context.Background(), n); err == nil || errors.Is(err, errCircuitOpen) {
+			t.Fatalf("sendGuarded() %d with failing API: error = %v", i+1, err)
+		}
+	}
+	if err := sendGuarded(context.Background(), n); !errors.Is(err, errCircuitOpen) {
+		t.Errorf("sendGuarded() with open circuit: error = %v, want %v", err, errCircuitOpen)
+	}
+	if err := checkCircuit(); !errors.Is(err, errCircuitOpen) {
+		t.Errorf("checkCircuit() = %v, want %v", err, errCircuitOpen)
+	}
+	if got := len(fake.Messages()); got != 0 {
+		t.Errorf("%d messages sent with open circuit", got)
+	}
+}
@@ -0,0 +1,128 @@
+// code.go
+//
+// Code snippets (flag --code). The file, or standard input with "--code -",
+// is appended to the message as fenced code block, with the language of
+// --code-lang or of the file extension for syntax highlighting. The fence is
+// longer than any run of backticks in the code, so code containing ``` does
+// not break the block. Code which does not fit into a Webex message is
+// truncated and, if it was read from a file, the file is sent with the
+// message.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Webex rejects messages longer than maxMarkdownLength. codeReserve is left
+// for mentions and the severity prefix.
+const (
+	maxMarkdownLength = 7439
+	codeReserve       = 300
+)
+
+// codeLanguages maps file extensions to the language of the code block if
+// the extension itself is not the language.
+var codeLanguages = map[string]string{
+	".yml":   "yaml",
+	".sh":    "bash",
+	".ps1":   "powershell",
+	".py":    "python",
+	".js":    "javascript",
+	".ts":    "typescript",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".patch": "diff",
+	".tf":    "hcl",
+	".md":    "markdown",
+	".conf":  "",
+	".txt":   "",
+	".log":   "",
+}
+
+// appendCode appends the code of file ("-" is standard input) to the
+// message.
+func appendCode(file, lang string) error {
+	var code string
+	if file == "-" {
+		if useStdIn {
+			return errors.New("--code - and -i both read standard input")
+		}
+		text, err := readMessage(os.Stdin, keepCRLF)
+		if err != nil {
+			return err
+		}
+		code = text
+	} else {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if !utf8.Valid(data) {
+			return fmt.Errorf("%s is not UTF-8 text. use flag -f to send files", file)
+		}
+		code = strings.ReplaceAll(string(data), "\r\n", "\n")
+		if !flagGiven("code-lang") {
+			lang = codeLanguage(file)
+		}
+	}
+
+	prefix := markdownMsg
+	if len(prefix) > 0 && !strings.HasSuffix(prefix, "\n") {
+		prefix += "\n"
+	}
+	block, truncated := codeBlock(code, lang, maxMarkdownLength-codeReserve-len(prefix))
+	markdownMsg = prefix + block
+	if truncated && file != "-" {
+		uploadFiles = append(uploadFiles, file)
+	}
+	return nil
+}
+
+func codeLanguage(file string) string {
+	ext := strings.ToLower(filepath.Ext(file))
+	if lang, ok := codeLanguages[ext]; ok {
+		return lang
+	}
+	return strings.TrimPrefix(ext, ".")
+}
+
+// codeBlock returns code as fenced code block of at most limit bytes. Longer
+// code is cut at a line end and marked as truncated.
+func codeBlock(code, lang string, limit int) (string, bool) {
+	code = strings.TrimRight(code, "\n")
+	fence := strings.Repeat("`", max(3, longestRun(code, '`')+1))
+	// the opening and closing fence and the truncation note
+	limit -= 2*len(fence) + len(lang) + 40
+
+	truncated := false
+	if len(code) > limit {
+		lines := strings.Count(code, "\n") + 1
+		cut := max(strings.LastIndexByte(code[:max(limit, 0)], '\n'), 0)
+		omitted := lines
+		if cut > 0 {
+			omitted -= strings.Count(code[:cut], "\n") + 1
+		}
+		code = code[:cut] + fmt.Sprintf("\n… %d more lines", omitted)
+		truncated = true
+	}
+	return fence + lang + "\n" + code + "\n" + fence + "\n", truncated
+}
+
+// longestRun returns the length of the longest run of c in s.
+func longestRun(s string, c byte) int {
+	longest, run := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != c {
+			run = 0
+			continue
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return longest
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCodeLanguage(t *testing.T) {
+	for _, tt := range []struct {
+		file, want string
+	}{
+		{"deploy.yml", "yaml"},
+		{"main.go", "go"},
+		{"/etc/nginx/nginx.conf", ""},
+		{"install.SH", "bash"},
+		{"fix.patch", "diff"},
+		{"Makefile", ""},
+	} {
+		if got := codeLanguage(tt.file); got != tt.want {
+			t.Errorf("codeLanguage(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	for _, tt := range []struct {
+		code, lang    string
+		limit         int
+		want          string
+		wantTruncated bool
+	}{
+		{"x := 1\n\n", "go", 1000, "```go\nx := 1\n```\n", false},
+		{"use ``` or ````", "", 1000, "`````\nuse ``` or ````\n`````\n", false},
+		{"a\nb\nc\nd", "", 48, "```\na\n… 3 more lines\n```\n", true},
+		{"abcdef", "", 40, "```\n\n… 1 more lines\n```\n", true},
+	} {
+		got, truncated := codeBlock(tt.code, tt.lang, tt.limit)
+		if got != tt.want || truncated != tt.wantTruncated {
+			t.Errorf("codeBlock(%q, %q, %d) = %q, %v, want %q, %v", tt.code, tt.lang, tt.limit, got, truncated, tt.want, tt.wantTruncated)
+		}
+	}
+}
+
+func TestLongestRun(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"no ticks", 0},
+		{"a`b``c", 2},
+		{"````", 4},
+	} {
+		if got := longestRun(tt.s, '`'); got != tt.want {
+			t.Errorf("longestRun(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestAppendCode(t *testing.T) {
+	defer func(m string, u stringList) { markdownMsg, uploadFiles = m, u }(markdownMsg, uploadFiles)
+	dir := t.TempDir()
+	small := filepath.Join(dir, "deploy.yml")
+	if err := os.WriteFile(small, []byte("replicas: 3\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	big := filepath.Join(dir, "build.log")
+	if err := os.WriteFile(big, []byte(strings.Repeat("step done\n", 1000)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "image.bin")
+	if err := os.WriteFile(binary, []byte{0xff, 0xfe, 0}, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	markdownMsg, uploadFiles = "**Deployed**", nil
+	if err := appendCode(small, ""); err != nil {
+		t.Fatal(err)
+	}
+	if want := "**Deployed**\n```yaml\nreplicas: 3\n```\n"; markdownMsg != want || len(uploadFiles) > 0 {
+		t.Errorf("appendCode(%s) message = %q, files %q, want %q", small, markdownMsg, uploadFiles, want)
+	}
+
+	markdownMsg, uploadFiles = "", nil
+	if err := appendCode(big, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(markdownMsg) > maxMarkdownLength-codeReserve || !strings.Contains(markdownMsg, "more lines") {
+		t.Errorf("appendCode(%s) message of %d bytes not truncated", big, len(markdownMsg))
+	}
+	if len(uploadFiles) != 1 || uploadFiles[0] != big {
+		t.Errorf("appendCode(%s) files = %q, want the log file", big, uploadFiles)
+	}
+
+	if err := appendCode(binary, ""); err == nil || !strings.Contains(err.Error(), "not UTF-8") {
+		t.Errorf("appendCode(%s) error = %v, want not UTF-8", binary, err)
+	}
+}
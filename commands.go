@@ -0,0 +1,819 @@
+// commands.go
+//
+// Subcommands of the CLI (send, edit, delete, rooms, teams, members,
+// webhooks, card, serve). All subcommands share the flags of the flat CLI,
+// so old invocations without a subcommand keep working unchanged and behave
+// like the send command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+type command struct {
+	name string
+	// actions of the command, the first one is the default
+	actions []string
+	usage   string
+	run     func(action string, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "send", usage: "send: send a message, file or card (default if no command is given)", run: cmdSend},
+		{name: "edit", usage: "edit <message id> -m <markdown>: replace the text of a message", run: cmdEdit},
+		{name: "delete", usage: "delete <message id>... | delete --purge -r <room> [--older-than 30d] [--match <text>]: delete messages", run: cmdDelete},
+		{name: "rooms", actions: []string{"list", "create", "lock", "unlock"}, usage: "rooms [list|create|lock|unlock]: list the rooms (of team -t), create, lock or unlock room -r", run: cmdRooms},
+		{name: "teams", actions: []string{"list"}, usage: "teams [list]: list the teams", run: cmdTeams},
+		{name: "members", actions: []string{"list", "add", "remove"}, usage: "members [list|add|remove] [<email>...]: manage the members of room -t/-r", run: cmdMembers},
+		{name: "provision", usage: "provision -f <YAML file>: create or update a room with its moderation, members, welcome message and webhooks", run: cmdProvision},
+		{name: "apply", usage: "apply -f <YAML file> [--dry-run] [--prune]: create the teams, rooms and members of the file and report the drift", run: cmdApply},
+		{name: "webhooks", actions: []string{"list", "create", "delete"}, usage: "webhooks [list|create|delete] [<webhook id>...]: manage webhooks", run: cmdWebhooks},
+		{name: "whoami", usage: "whoami: show the identity of the token and whether it is valid", run: cmdWhoami},
+		{name: "token", actions: []string{"check"}, usage: "token check [--min-validity <duration>]: check the token and show its identity, organization and remaining validity", run: cmdToken},
+		{name: "templates", actions: []string{"list"}, usage: "templates [list]: list the message templates of the config file (-c)", run: cmdTemplates},
+		{name: "card", usage: "card -a <card> | -A <card file>: send a card attachment", run: cmdCard},
+		{name: "status", usage: "status [-m <title>] [-- <command> [<args>]]: update one message with the latest line of standard input or of the command output", run: cmdStatus},
+		{name: "run", usage: "run [--only-failure] -- <command> [<args>]: run a command and send its exit status, duration and output", run: cmdRun},
+		{name: "export", usage: "export -r <room> [--since 30d] [-o <file>.json|.csv|.html]: export the messages of a room", run: cmdExport},
+		{name: "search", usage: "search -r <room> --match <text> [--since 7d]: list the matching messages of a room with their IDs", run: cmdSearch},
+		{name: "thread", actions: []string{"list"}, usage: "thread [list] <parent message id> [-o <file>.json|.csv]: list the replies to a message", run: cmdThread},
+		{name: "meeting", actions: []string{"create"}, usage: "meeting create -r <room> [--title <title>] [--duration 1h]: create a meeting and post the join details to the room", run: cmdMeeting},
+		{name: "interactive", usage: "interactive: choose a room, compose, review and send messages interactively", run: cmdInteractive},
+		{name: "service", actions: []string{"install", "start", "stop", "uninstall"}, usage: "service install|start|stop|uninstall [--service-name <name>] [-- <mode arguments>]: run serve, --mqtt, --daemon or --k8s-watch as system service", run: cmdService},
+		{name: "decrypt", usage: "decrypt <file>: print a spool file, state file or journal encrypted with --state-key-file decrypted", run: cmdDecrypt},
+		{name: "serve", usage: "serve [--listen <address>]: HTTP relay for notifications and receiver for webhooks", run: cmdServe},
+	}
+	flag.Usage = usage
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	name := path.Base(os.Args[0])
+	fmt.Fprintf(out, "usage: %s [<command> [<action>]] [flags] [<args>]\n\ncommands:\n", name)
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %s\n", cmd.usage)
+	}
+	fmt.Fprintf(out, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+// findCommand returns the command named by the first argument and the
+// remaining arguments, or nil and all arguments for the flat CLI.
+func findCommand(args []string) (*command, []string) {
+	if len(args) == 0 {
+		return nil, args
+	}
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd, args[1:]
+		}
+	}
+	return nil, args
+}
+
+// parseFlags parses the flags in args and returns the positional arguments.
+// Unlike flag.Parse, flags may follow positional arguments, e.g.
+// "delete <message id> -T <token>".
+func parseFlags(args []string) []string {
+	var positional []string
+	for {
+		flag.CommandLine.Parse(args)
+		rest := flag.Args()
+		if terminated(args[:len(args)-len(rest)]) {
+			return append(positional, rest...)
+		}
+		args = rest
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// terminated reports whether the flags parsed by flag.Parse end with "--",
+// which flag.Parse drops. A "--" which is the value of a flag, e.g. in
+// "-m --", does not end the flags.
+func terminated(parsed []string) bool {
+	for i := 0; i < len(parsed); i++ {
+		if parsed[i] == "--" {
+			return true
+		}
+		name := strings.TrimLeft(parsed[i], "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		f := flag.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			continue
+		}
+		// skip the value
+		i++
+	}
+	return false
+}
+
+// runCommand runs cmd with the positional arguments args. The first
+// argument selects the action if the command has any.
+func runCommand(cmd *command, args []string) error {
+	var action string
+	if len(cmd.actions) > 0 {
+		action = cmd.actions[0]
+		if len(args) > 0 {
+			action = args[0]
+			args = args[1:]
+		}
+		valid := false
+		for _, a := range cmd.actions {
+			valid = valid || a == action
+		}
+		if !valid {
+			return fmt.Errorf("unknown action %q for command %s. use %s", action, cmd.name, strings.Join(cmd.actions, ", "))
+		}
+	}
+	return cmd.run(action, args)
+}
+
+func noArgs(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected argument %q", args[0])
+	}
+	return nil
+}
+
+// cmdSend sends the message given by the flags. This is the behavior of the
+// flat CLI.
+func cmdSend(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if err := checkChecksum(checksumAlgo); err != nil {
+		return err
+	}
+	card, err := loadCard()
+	if err != nil {
+		return err
+	}
+	cardAttachment = card
+	switch {
+	case len(junitReports) > 0:
+		if len(cardAttachment) > 0 || ciCard {
+			return errors.New("--junit can not be combined with -a, -A or --ci-card")
+		}
+		summary, err := readJUnit(junitReports)
+		if err != nil {
+			return err
+		}
+		// nil outside of a CI environment
+		build, _ := detectCI()
+		if cardAttachment, err = summary.card(markdownMsg, build); err != nil {
+			return err
+		}
+		markdownMsg = summary.text(markdownMsg, build)
+		if junitAttach {
+			uploadFiles = append(uploadFiles, summary.Files...)
+		}
+	case jsonCardInput:
+		if len(cardAttachment) > 0 || ciCard {
+			return errors.New("--json-card can not be combined with -a, -A or --ci-card")
+		}
+		card, text, err := jsonCardFromStdin()
+		if err != nil {
+			return err
+		}
+		cardAttachment, markdownMsg = card, text
+	case ciCard:
+		if len(cardAttachment) > 0 {
+			return errors.New("--ci-card can not be combined with -a or -A")
+		}
+		build, err := detectCI()
+		if err != nil {
+			return err
+		}
+		if cardAttachment, err = build.card(markdownMsg); err != nil {
+			return err
+		}
+		markdownMsg = build.text(markdownMsg)
+	}
+	if len(markdownMsg) == 0 && len(templateName) == 0 && len(recipientsFile) == 0 {
+		slog.Warn("no message. use flag -m or flag -i")
+	}
+
+	n := &notification{
+		TeamName: teamName,
+		RoomName: roomName,
+		Email:    emailAddr,
+		Markdown: markdownMsg,
+		Card:     cardAttachment,
+		Mentions: mentionEmails,
+		Severity: severityName,
+		Ack:      ackButton,
+
+		IdempotencyKey: idempotencyKey,
+		ReplyLast:      replyLast,
+	}
+	if len(uploadFiles) == 1 {
+		n.File = uploadFiles[0]
+	} else {
+		n.Files = uploadFiles
+	}
+	if n.Labels, err = parseLabels(labelList); err != nil {
+		return err
+	}
+	if len(recipientsFile) > 0 {
+		return sendToRecipients(n, recipientsFile)
+	}
+	if len(templateName) > 0 {
+		n.Template = templateName
+		n.Vars, err = parseVars(templateVars)
+		if err != nil {
+			return err
+		}
+		// the room of the template is used unless -r is given
+		if !flagGiven("r", "room") {
+			n.RoomName = ""
+		}
+		if err := applyTemplate(n); err != nil {
+			return err
+		}
+		if len(n.RoomName) == 0 {
+			n.RoomName = roomName
+		}
+	}
+	if err := routeNotification(n, templateName); err != nil {
+		return err
+	}
+	if err := checkOnCall(); err != nil {
+		return err
+	}
+	if onCallGiven() {
+		ctx, cancel := requestContext()
+		err := routeOnCall(ctx, n)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	if err := applyFooter(n); err != nil {
+		return fmt.Errorf("--footer: %w", err)
+	}
+	cleanup, err := handleOverflow(n)
+	if err != nil {
+		return err
+	}
+	// a spooled message needs the attached file later
+	spooled := false
+	defer func() {
+		if !spooled {
+			cleanup()
+		}
+	}()
+	if err := attachLocalImages(n); err != nil {
+		return err
+	}
+	// fail before a delayed message is held or spooled
+	if err := checkFiles(n); err != nil {
+		return err
+	}
+	if len(n.Severity) > 0 {
+		// fail before a delayed message is held or spooled
+		if _, err := lookupSeverity(n.Severity); err != nil {
+			return err
+		}
+	}
+	if err := checkEscalation(); err != nil {
+		return err
+	}
+	if showPreview || len(previewHTML) > 0 {
+		if err := withAckButton(n); err != nil {
+			return err
+		}
+		if err := applySeverity(n); err != nil {
+			return err
+		}
+		if !noEmoji {
+			n.Markdown = expandEmoji(n.Markdown)
+		}
+		if err := applyChecksums(n); err != nil {
+			return err
+		}
+		return previewMessage(n.Markdown, n.Card, previewHTML)
+	}
+	// fail before a delayed message is held or spooled
+	if err := preUploadCheck(notificationFiles(n)); err != nil {
+		return err
+	}
+	if mentionAll {
+		err := confirmMentionAll(roomName)
+		if err != nil {
+			return err
+		}
+		n.MentionAll = true
+	}
+
+	sendAt, err := scheduledTime(sendAtString, sendDelay)
+	if err != nil {
+		return err
+	}
+	if !sendAt.IsZero() && sendAt.After(time.Now()) {
+		if useSpool {
+			err := spoolNotification(n, sendAt)
+			spooled = err == nil
+			return err
+		}
+		slog.Info("holding message", "until", sendAt.Format(time.RFC3339))
+		time.Sleep(time.Until(sendAt))
+	}
+
+	ctx, cancel := requestContext()
+	defer cancel()
+	m, err := sendMessage(ctx, n)
+	if err == nil && m != nil && !multiDoc {
+		printMessage(m)
+		if len(saveAs) > 0 {
+			if err := saveHandle(saveAs, m); err != nil {
+				return fmt.Errorf("--save-as: %w", err)
+			}
+		}
+		// the message is sent, a later --reply-last only misses its thread
+		if err := saveLastMessage(m); err != nil {
+			slog.Warn("saving the last message of the room for --reply-last", "error", err)
+		}
+	}
+	if err == nil && escalateAfter > 0 {
+		slog.Info("waiting for the acknowledgement", "window", escalateAfter)
+		escalations.Wait()
+	}
+	switch {
+	case err == nil && useSpool:
+		// the API is reachable again, send what was spooled before
+		if err := flushSpoolDir(); err != nil {
+			slog.Warn("flushing the spool", "error", err)
+		}
+	case err != nil && useSpool && isTransient(err):
+		err := spoolFailed(n, err)
+		spooled = err == nil
+		return err
+	}
+	return err
+}
+
+func cmdCard(action string, args []string) error {
+	if len(cardAttachment) == 0 && len(cardFile) == 0 {
+		return errors.New("no card. use flag -a or flag -A")
+	}
+	return cmdSend(action, args)
+}
+
+func cmdEdit(action string, args []string) error {
+	if len(args) > 0 {
+		editMessageId = args[0]
+		args = args[1:]
+	}
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if len(editRef) > 0 {
+		if len(editMessageId) > 0 {
+			return errors.New("use either a message id or --edit-ref")
+		}
+		id, err := lookupHandle(editRef)
+		if err != nil {
+			return err
+		}
+		editMessageId = id
+	}
+	if len(editMessageId) == 0 {
+		return errors.New("no message id. use edit <message id>, flag -e or --edit-ref")
+	}
+	if len(markdownMsg) == 0 {
+		return errors.New("no message. use flag -m or flag -i")
+	}
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	markdown := markdownMsg
+	if !noEmoji {
+		markdown = expandEmoji(markdown)
+	}
+	_, err = client.EditMessage(ctx, editMessageId, markdown)
+	return err
+}
+
+func cmdDelete(action string, args []string) error {
+	if purgeMessages {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		return runPurge()
+	}
+	if len(deleteMessageId) > 0 {
+		args = append([]string{deleteMessageId}, args...)
+	}
+	if len(deleteRef) > 0 {
+		id, err := lookupHandle(deleteRef)
+		if err != nil {
+			return err
+		}
+		args = append(args, id)
+	}
+	if len(args) == 0 {
+		return errors.New("no message id. use delete <message id>, flag -d or --delete-ref")
+	}
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	for _, id := range args {
+		if err := client.DeleteMessage(ctx, id); err != nil {
+			return err
+		}
+	}
+	if len(deleteRef) > 0 {
+		return removeHandle(deleteRef)
+	}
+	return nil
+}
+
+func cmdRooms(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	var teamID string
+	if len(teamName) > 0 {
+		teamID, err = client.TeamIDByName(ctx, teamName)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch action {
+	case "create":
+		room, err := client.CreateRoomWith(ctx, &webex.RoomRequest{
+			TeamID:             teamID,
+			Title:              roomName,
+			IsLocked:           lockedRooms || announceRooms,
+			IsAnnouncementOnly: announceRooms,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println(room.ID)
+		return nil
+	case "lock", "unlock":
+		room, err := findRoom(ctx, teamName, roomName)
+		if err != nil {
+			return err
+		}
+		room, err = client.LockRoom(ctx, room.ID, action == "lock", announceRooms)
+		if err != nil {
+			return err
+		}
+		slog.Info("room "+action+"ed", "room", room.Title, "locked", room.IsLocked, "announcementOnly", room.IsAnnouncementOnly)
+		return nil
+	default:
+		rooms, err := client.ListRooms(ctx, teamID, "")
+		if err != nil {
+			return err
+		}
+		w := newTable("ID", "TYPE", "LOCKED", "TITLE")
+		for _, r := range rooms {
+			locked := ""
+			switch {
+			case r.IsAnnouncementOnly:
+				locked = "announcement"
+			case r.IsLocked:
+				locked = "locked"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.ID, r.Type, locked, r.Title)
+		}
+		return w.Flush()
+	}
+}
+
+func cmdTeams(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	teams, err := client.ListTeams(ctx)
+	if err != nil {
+		return err
+	}
+	w := newTable("ID", "NAME")
+	for _, t := range teams {
+		fmt.Fprintf(w, "%s\t%s\n", t.ID, t.Name)
+	}
+	return w.Flush()
+}
+
+func cmdMembers(action string, args []string) error {
+	if len(emailAddr) > 0 {
+		args = append(args, emailAddr)
+	}
+	if action != "list" && len(args) == 0 {
+		return errors.New("no email address. use members " + action + " <email>...")
+	}
+	if len(teamName) == 0 {
+		return errors.New("no team. use flag -t")
+	}
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	roomID, err := lookupRoomID(ctx, teamName, roomName)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "add":
+		for _, email := range args {
+			if _, err := client.CreateMembership(ctx, roomID, email, memberModerator); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "remove":
+		members, err := client.ListMemberships(ctx, roomID)
+		if err != nil {
+			return err
+		}
+		for _, email := range args {
+			m := findMembership(members, email)
+			if m == nil {
+				return fmt.Errorf("%s is not a member of room %s", email, roomName)
+			}
+			if err := client.DeleteMembership(ctx, m.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		members, err := client.ListMemberships(ctx, roomID)
+		if err != nil {
+			return err
+		}
+		w := newTable("EMAIL", "NAME", "MODERATOR")
+		for _, m := range members {
+			fmt.Fprintf(w, "%s\t%s\t%t\n", m.PersonEmail, m.PersonDisplayName, m.IsModerator)
+		}
+		return w.Flush()
+	}
+}
+
+func findMembership(members []webex.Membership, email string) *webex.Membership {
+	for i := range members {
+		if strings.EqualFold(members[i].PersonEmail, email) {
+			return &members[i]
+		}
+	}
+	return nil
+}
+
+func cmdWebhooks(action string, args []string) error {
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	switch action {
+	case "create":
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		if len(webhookTarget) == 0 {
+			return errors.New("no target URL. use flag --target-url")
+		}
+		wh, err := client.CreateWebhook(ctx, &webex.Webhook{
+			Name:      webhookName,
+			TargetURL: webhookTarget,
+			Resource:  webhookResource,
+			Event:     webhookEvent,
+			Filter:    webhookFilter,
+			Secret:    webhookSecret,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println(wh.ID)
+		return nil
+	case "delete":
+		if len(args) == 0 {
+			return errors.New("no webhook id. use webhooks delete <webhook id>...")
+		}
+		for _, id := range args {
+			if err := client.DeleteWebhook(ctx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		hooks, err := client.ListWebhooks(ctx)
+		if err != nil {
+			return err
+		}
+		w := newTable("ID", "NAME", "RESOURCE", "EVENT", "STATUS", "TARGET URL")
+		for _, h := range hooks {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", h.ID, h.Name, h.Resource, h.Event, h.Status, h.TargetURL)
+		}
+		return w.Flush()
+	}
+}
+
+func cmdExport(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	return runExport(outputFile)
+}
+
+func cmdSearch(action string, args []string) error {
+	if len(args) > 0 && len(searchMatch) == 0 {
+		searchMatch = strings.Join(args, " ")
+		args = nil
+	}
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	return runSearch()
+}
+
+func cmdThread(action string, args []string) error {
+	if len(deleteMessageId) > 0 {
+		args = append([]string{deleteMessageId}, args...)
+	}
+	if len(args) == 0 {
+		return errors.New("no parent message id. use thread list <message id> or flag -d")
+	}
+	if err := noArgs(args[1:]); err != nil {
+		return err
+	}
+	return runThread(args[0], outputFile)
+}
+
+func cmdMeeting(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	return runMeetingCreate()
+}
+
+func cmdInteractive(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	return runInteractive()
+}
+
+func cmdWhoami(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	return runWhoami()
+}
+
+func cmdServe(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	if deleteWebhooks && len(webhookTarget) == 0 {
+		return errors.New("no target URL of the webhooks to delete. use flag --target-url")
+	}
+	if err := checkEscalation(); err != nil {
+		return err
+	}
+	// the events run commands, start automation and acknowledge alerts, so
+	// they have to be signed by Webex
+	if (chatops || len(forwardURL) > 0 || ackButton) && len(webhookSecret) == 0 {
+		return errors.New("--chatops, --forward-url and --ack accept signed webhook events only. use flag --secret")
+	}
+	if chatops {
+		b, err := newChatBot()
+		if err != nil {
+			return err
+		}
+		bot = b
+	}
+	if len(forwardURL) > 0 {
+		f, err := newForwarder()
+		if err != nil {
+			return err
+		}
+		forward = f
+	}
+	if jiraWebhooks {
+		routes, err := newJiraRoutes()
+		if err != nil {
+			return err
+		}
+		jiraRoutes = routes
+	}
+	if pagerDutyHooks {
+		p, err := newPagerDutyRelay()
+		if err != nil {
+			return err
+		}
+		pagerDuty = p
+	}
+	if sentryHooks {
+		s, err := newSentryRelay()
+		if err != nil {
+			return err
+		}
+		sentry = s
+	}
+	if datadogHooks {
+		routes, err := newDatadogRoutes()
+		if err != nil {
+			return err
+		}
+		datadogRoutes = routes
+	}
+	rules, err := loadEventRules()
+	if err != nil {
+		return err
+	}
+	eventRules = rules
+	if alertmanagerHooks {
+		if err := checkAlertmanagerRoute(alertmanagerReceiver.name); err != nil {
+			return err
+		}
+	}
+	if grafanaHooks {
+		if err := checkAlertmanagerRoute(grafanaReceiver.name); err != nil {
+			return err
+		}
+	}
+	if ackButton {
+		a, err := newAcknowledger()
+		if err != nil {
+			return err
+		}
+		acks = a
+	}
+	return runServer(serveListen)
+}
+
+// printMessage prints the ID and the file URLs of the sent message m to
+// standard output, one per line, e.g. for scripts editing or deleting the
+// message later with -e or -d. The logs go to standard error.
+func printMessage(m *webex.Message) {
+	fmt.Println(m.ID)
+	for _, file := range m.Files {
+		fmt.Println(file)
+	}
+}
+
+// newTable returns a tabwriter for the output of the list actions with the
+// given column headers already written.
+func newTable(headers ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	return w
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	defer func() { markdownMsg, useStdIn = "", false }()
+	for _, tt := range []struct {
+		args    []string
+		want    []string
+		message string
+	}{
+		{args: []string{"id", "-m", "hi"}, want: []string{"id"}, message: "hi"},
+		{args: []string{"-m", "hi", "id", "-i"}, want: []string{"id"}, message: "hi"},
+		{args: []string{"a", "--", "-m", "b"}, want: []string{"a", "-m", "b"}},
+		{args: []string{"-i", "--", "-x"}, want: []string{"-x"}},
+		// "--" as flag value
+		{args: []string{"-m", "--", "id"}, want: []string{"id"}, message: "--"},
+		{args: []string{"--message", "--", "id", "-i"}, want: []string{"id"}, message: "--"},
+		{args: []string{"-m=--", "id"}, want: []string{"id"}, message: "--"},
+		{args: []string{"-m", "--", "--", "-i"}, want: []string{"-i"}, message: "--"},
+	} {
+		markdownMsg, useStdIn = "", false
+		got := parseFlags(tt.args)
+		if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) || markdownMsg != tt.message {
+			t.Errorf("parseFlags(%q) = %q, -m %q, want %q, -m %q", tt.args, got, markdownMsg, tt.want, tt.message)
+		}
+	}
+}
+
+func TestSendMessage(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"report.txt", "errors.log"} {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(name), 0600); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+
+	for _, tt := range []struct {
+		n         notification
+		wantFiles int
+	}{
+		{notification{TeamName: "KMP-Team", RoomName: "KMP-Team", Markdown: "backup done"}, 0},
+		{notification{TeamName: "KMP-Team", RoomName: "KMP-Team", Markdown: "nightly reports", Files: files}, 2},
+	} {
+		sent, err := sendMessage(context.Background(), &tt.n)
+		if err != nil || sent == nil || len(sent.ID) == 0 || len(sent.Files) != tt.wantFiles {
+			t.Fatalf("sendMessage(%q) = %+v, %v, want %d files", tt.n.Markdown, sent, err, tt.wantFiles)
+		}
+		out := captureStdout(t, func() { printMessage(sent) })
+		want := strings.Join(append([]string{sent.ID}, sent.Files...), "\n") + "\n"
+		if out != want {
+			t.Errorf("printMessage() = %q, want %q", out, want)
+		}
+		for i, url := range sent.Files {
+			if got := string(fake.File(url)); got != filepath.Base(files[i]) {
+				t.Errorf("file %s = %q, want %s", url, got, filepath.Base(files[i]))
+			}
+		}
+	}
+}
+
+func TestCmdRooms(t *testing.T) {
+	fake := useFakeWebex(t)
+	team := fake.AddTeam("KMP-Team")
+	defer func(team, room string, locked, announce bool) {
+		teamName, roomName, lockedRooms, announceRooms = team, room, locked, announce
+	}(teamName, roomName, lockedRooms, announceRooms)
+	room := func(title string) (locked, announcement bool) {
+		for _, r := range fake.Rooms() {
+			if r.Title == title && r.TeamID == team {
+				return r.IsLocked, r.IsAnnouncementOnly
+			}
+		}
+		t.Fatalf("room %s not found", title)
+		return
+	}
+
+	for _, tt := range []struct {
+		action, room             string
+		locked, announce         bool
+		wantLocked, wantAnnounce bool
+	}{
+		{action: "create", room: "Alerts", locked: true, wantLocked: true},
+		{action: "create", room: "Announcements", announce: true, wantLocked: true, wantAnnounce: true},
+		{action: "create", room: "Chat"},
+		{action: "lock", room: "Chat", wantLocked: true},
+		{action: "lock", room: "Alerts", announce: true, wantLocked: true, wantAnnounce: true},
+		{action: "unlock", room: "Announcements"},
+	} {
+		teamName, roomName, lockedRooms, announceRooms = "KMP-Team", tt.room, tt.locked, tt.announce
+		var err error
+		captureStdout(t, func() { err = cmdRooms(tt.action, nil) })
+		if locked, announce := room(tt.room); err != nil || locked != tt.wantLocked || announce != tt.wantAnnounce {
+			t.Errorf("rooms %s %s = %v, locked %v, announcement only %v, want %v, %v",
+				tt.action, tt.room, err, locked, announce, tt.wantLocked, tt.wantAnnounce)
+		}
+	}
+
+	lockedRooms, announceRooms = false, false
+	var err error
+	out := captureStdout(t, func() { err = cmdRooms("list", nil) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"announcement  Alerts\n", "locked        Chat\n", "KMP-Team\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("rooms list = %q, want %q", out, want)
+		}
+	}
+
+	// missing rooms are created locked
+	lockedRooms = true
+	if _, err := lookupRoomID(context.Background(), "KMP-Team", "Oncall"); err != nil {
+		t.Fatal(err)
+	}
+	if locked, _ := room("Oncall"); !locked {
+		t.Error("room created by lookupRoomID() with --locked is not locked")
+	}
+}
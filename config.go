@@ -0,0 +1,102 @@
+// config.go
+//
+// Optional JSON configuration file (flag -c). It holds the settings of the
+// long-running modes, e.g. the schedule table of the recurring message daemon,
+// the styles of the severities (see severity.go), the directory of the
+// message templates (see template.go), the commands of the chatops bot
+// (see chatops.go), the rooms of the Jira projects, PagerDuty services,
+// Sentry projects and Datadog tags (see jira.go, pagerduty.go, sentry.go,
+// datadog.go), the rules of their events (see rules.go), the routing rules
+// of the messages (see routing.go) and the profiles of the bots (see
+// profile.go).
+//
+// example:
+//
+//	{
+//	  "schedules": [
+//	    { "name": "standup", "cron": "45 8 * * 1-5", "team": "KMP-Team", "room": "Daily",
+//	      "message": "Standup in 15 minutes" },
+//	    { "name": "weekly report", "cron": "0 14 * * 5", "email": "john.smith@example.com",
+//	      "message": "Please send your weekly report for week {{.Week}}" }
+//	  ],
+//	  "severities": {
+//	    "critical": { "emoji": "🔥", "color": "attention", "mentions": ["oncall@example.com"] }
+//	  },
+//	  "templates": "/etc/notify/templates"
+//	}
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+type config struct {
+	Schedules  []*schedule               `json:"schedules"`
+	Severities map[string]*severityStyle `json:"severities"`
+	Templates  string                    `json:"templates"`
+	Commands   []*chatCommand            `json:"commands"`
+	Jira       *jiraConfig               `json:"jira"`
+	PagerDuty  *pagerDutyConfig          `json:"pagerduty"`
+	Sentry     *sentryConfig             `json:"sentry"`
+	Datadog    *datadogConfig            `json:"datadog"`
+	Routes     []*routeRule              `json:"routes"`
+	Rules      []*eventRule              `json:"rules"`
+	Profiles   map[string]*profile       `json:"profiles"`
+	TLS        *tlsOptions               `json:"tls"`
+	Scrub      *scrubOptions             `json:"scrub"`
+}
+
+// schedule is a single entry of the daemon's schedule table. Message is a
+// Go text/template, see scheduleData for the available fields.
+type schedule struct {
+	Name    string `json:"name"`
+	Cron    string `json:"cron"`
+	Team    string `json:"team"`
+	Room    string `json:"room"`
+	Email   string `json:"email"`
+	Message string `json:"message"`
+	File    string `json:"file"`
+	Card    string `json:"card"`
+}
+
+func loadConfig(filename string) (*config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var c config
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %v", filename, err)
+	}
+	for name, p := range c.Profiles {
+		if err := p.check(name); err != nil {
+			return nil, fmt.Errorf("config file %s, %v", filename, err)
+		}
+	}
+	for i, r := range c.Routes {
+		if err := r.check(i); err != nil {
+			return nil, fmt.Errorf("config file %s, %v", filename, err)
+		}
+		if _, ok := c.Profiles[r.Profile]; len(r.Profile) > 0 && !ok {
+			return nil, fmt.Errorf("config file %s, route %d: unknown profile %q", filename, i+1, r.Profile)
+		}
+	}
+	for i, r := range c.Rules {
+		if err := r.check(i); err != nil {
+			return nil, fmt.Errorf("config file %s, %v", filename, err)
+		}
+	}
+	for _, sched := range c.Schedules {
+		if len(sched.Card) == 0 {
+			continue
+		}
+		sched.Card, err = normalizeCard(sched.Card)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s, schedule %q: %v", filename, sched.Name, err)
+		}
+	}
+	return &c, nil
+}
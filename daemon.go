@@ -0,0 +1,237 @@
+// daemon.go
+//
+// Recurring message daemon (flag --daemon). Sends the messages of the schedule
+// table in the config file whenever their cron expression matches.
+//
+// cron expressions have the usual five fields
+//
+//	minute hour day-of-month month day-of-week
+//
+// with support for *, lists (1,15), ranges (1-5), steps (*/15, 8-18/2) and the
+// shortcuts @yearly, @monthly, @weekly, @daily and @hourly. Day of week 0 and 7
+// are Sunday. If both day fields are restricted, either one has to match.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// scheduleData is passed to the message template of a schedule.
+type scheduleData struct {
+	Name string
+	Time time.Time
+	Week int
+}
+
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+type scheduledJob struct {
+	*schedule
+	spec *cronSpec
+	tmpl *template.Template
+}
+
+func runDaemon(c *config) error {
+	if len(c.Schedules) == 0 {
+		return errors.New("no schedules in config file")
+	}
+
+	var jobs []*scheduledJob
+	for i, s := range c.Schedules {
+		if len(s.Name) == 0 {
+			s.Name = fmt.Sprintf("schedule %d", i+1)
+		}
+		if len(s.Team) == 0 && len(s.Email) == 0 {
+			return fmt.Errorf("%s: team or email required", s.Name)
+		}
+		spec, err := parseCron(s.Cron)
+		if err != nil {
+			return fmt.Errorf("%s: %v", s.Name, err)
+		}
+		tmpl, err := template.New(s.Name).Funcs(templateFuncs).Parse(s.Message)
+		if err != nil {
+			return fmt.Errorf("%s: %v", s.Name, err)
+		}
+		jobs = append(jobs, &scheduledJob{schedule: s, spec: spec, tmpl: tmpl})
+	}
+	slog.Info("schedules loaded", "count", len(jobs))
+
+	ctx, stop := shutdownContext()
+	defer stop()
+	if useSpool {
+		go runSpoolFlusher(ctx)
+	}
+	go runTokenReloader(ctx)
+	var running sync.WaitGroup
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-time.After(next.Sub(now)):
+		case <-ctx.Done():
+			running.Wait()
+			finishShutdown()
+			slog.Info("daemon stopped")
+			return nil
+		}
+
+		for _, job := range jobs {
+			if job.spec.matches(next) {
+				running.Add(1)
+				go func(job *scheduledJob) {
+					defer running.Done()
+					job.run(next)
+				}(job)
+			}
+		}
+	}
+}
+
+func (job *scheduledJob) run(t time.Time) {
+	_, week := t.ISOWeek()
+	out := new(bytes.Buffer)
+	err := job.tmpl.Execute(out, &scheduleData{Name: job.Name, Time: t, Week: week})
+	if err != nil {
+		slog.Error("rendering scheduled message failed", "schedule", job.Name, "error", err)
+		return
+	}
+
+	room := job.Room
+	if len(room) == 0 {
+		room = roomName
+	}
+	n := &notification{
+		TeamName: job.Team,
+		RoomName: room,
+		Email:    job.Email,
+		Markdown: out.String(),
+		File:     job.File,
+		Card:     job.Card,
+	}
+	if digestWindow > 0 {
+		queueDigest(n)
+		return
+	}
+	slog.Info("sending scheduled message", "schedule", job.Name)
+	ctx, cancel := requestContext()
+	defer cancel()
+	err = sendOrSpool(ctx, n)
+	if err != nil {
+		slog.Error("sending scheduled message failed", "schedule", job.Name, "error", err)
+	}
+}
+
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+func parseCron(expr string) (*cronSpec, error) {
+	if shortcut, ok := cronShortcuts[strings.TrimSpace(expr)]; ok {
+		expr = shortcut
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: 5 fields expected", expr)
+	}
+
+	var spec cronSpec
+	var err error
+	if spec.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if spec.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if spec.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if spec.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if spec.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	// 7 is an alias for Sunday
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+	spec.domStar = strings.HasPrefix(fields[2], "*")
+	spec.dowStar = strings.HasPrefix(fields[4], "*")
+	return &spec, nil
+}
+
+// parseCronField returns a bit set of the values matched by field.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in cron field %q", field)
+			}
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range in cron field %q", field)
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in cron field %q", field)
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range %d-%d in cron field %q", min, max, field)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (spec *cronSpec) matches(t time.Time) bool {
+	if spec.minute&(1<<uint(t.Minute())) == 0 ||
+		spec.hour&(1<<uint(t.Hour())) == 0 ||
+		spec.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := spec.dom&(1<<uint(t.Day())) != 0
+	dowMatch := spec.dow&(1<<uint(t.Weekday())) != 0
+	if spec.domStar || spec.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	for _, tt := range []struct {
+		field    string
+		min, max int
+		want     []int
+		wantErr  bool
+	}{
+		{field: "5", min: 0, max: 59, want: []int{5}},
+		{field: "1,3,5", min: 0, max: 59, want: []int{1, 3, 5}},
+		{field: "10-12", min: 0, max: 59, want: []int{10, 11, 12}},
+		{field: "*/15", min: 0, max: 59, want: []int{0, 15, 30, 45}},
+		{field: "1-10/4", min: 0, max: 59, want: []int{1, 5, 9}},
+		{field: "50/5", min: 0, max: 59, want: []int{50, 55}},
+		{field: "*", min: 1, max: 3, want: []int{1, 2, 3}},
+		{field: "60", min: 0, max: 59, wantErr: true},
+		{field: "0", min: 1, max: 31, wantErr: true},
+		{field: "5-1", min: 0, max: 59, wantErr: true},
+		{field: "*/0", min: 0, max: 59, wantErr: true},
+		{field: "a", min: 0, max: 59, wantErr: true},
+		{field: "1-x", min: 0, max: 59, wantErr: true},
+	} {
+		got, err := parseCronField(tt.field, tt.min, tt.max)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseCronField(%q) = %b, want error", tt.field, got)
+			}
+			continue
+		}
+		var want uint64
+		for _, v := range tt.want {
+			want |= 1 << uint(v)
+		}
+		if err != nil || got != want {
+			t.Errorf("parseCronField(%q) = %b, %v, want %b", tt.field, got, err, want)
+		}
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"* * * *", "* * * * * *", "0 24 * * *", "0 0 32 * *", "0 0 * 13 *", "0 0 * * 8"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) = nil error, want error", expr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2026-10-15 is a Thursday
+	at := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	for _, tt := range []struct {
+		expr string
+		time string
+		want bool
+	}{
+		{"30 9 * * *", "2026-10-15 09:30", true},
+		{"30 9 * * *", "2026-10-15 09:31", false},
+		{"*/15 * * * *", "2026-10-15 13:45", true},
+		{"0 9 * * 1-5", "2026-10-15 09:00", true},
+		{"0 9 * * 1-5", "2026-10-17 09:00", false},
+		// 7 is Sunday as well as 0
+		{"0 9 * * 7", "2026-10-18 09:00", true},
+		// day of month or day of week if both are restricted
+		{"0 9 1 * 4", "2026-10-15 09:00", true},
+		{"0 9 1 * 4", "2026-11-01 09:00", true},
+		{"0 9 1 * 4", "2026-10-16 09:00", false},
+		// both if one is *
+		{"0 9 * 10 4", "2026-11-05 09:00", false},
+		{"@monthly", "2026-11-01 00:00", true},
+		{"@weekly", "2026-10-18 00:00", true},
+		{"@yearly", "2026-10-01 00:00", false},
+	} {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", tt.expr, err)
+		}
+		if got := spec.matches(at(tt.time)); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.expr, tt.time, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+// datadog.go
+//
+// Datadog webhooks (serve --datadog). Monitor notifications POSTed to
+// /datadog by the Datadog webhook integration are posted as cards colored by
+// the alert type: red for errors, yellow for warnings and green for
+// recoveries. The payload of the webhook is a template in Datadog, the
+// fields used are:
+//
+//	{
+//	  "id": "$ID",
+//	  "title": "$EVENT_TITLE",
+//	  "body": "$EVENT_MSG",
+//	  "alert_type": "$ALERT_TYPE",
+//	  "transition": "$ALERT_TRANSITION",
+//	  "priority": "$PRIORITY",
+//	  "hostname": "$HOSTNAME",
+//	  "tags": "$TAGS",
+//	  "link": "$LINK",
+//	  "snapshot": "$SNAPSHOT"
+//	}
+//
+// Only title is required, the default payload of Datadog works as well. The
+// rooms are routed by the tags of the monitor in the config file (-c), the
+// first route with a tag of the alert wins, other alerts go to -t/-r or -D:
+//
+//	{
+//	  "datadog": {
+//	    "routes": [
+//	      { "tag": "team:payments", "team": "KMP-Team", "room": "Payments" },
+//	      { "tag": "service:shop-*", "team": "KMP-Team", "room": "Shop" }
+//	    ]
+//	  }
+//	}
+//
+// Datadog does not sign webhooks. With --datadog-secret only alerts with the
+// secret in the header X-Datadog-Secret, a custom header of the webhook in
+// Datadog, are accepted.
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// length of the message of the monitor on the card
+const datadogMaxBody = 1000
+
+// datadogConfig is the section datadog of the config file.
+type datadogConfig struct {
+	Routes []*datadogRoute `json:"routes"`
+}
+
+// datadogRoute is the room of the alerts with a tag. Tag may contain the
+// wildcards of path.Match, e.g. service:shop-*.
+type datadogRoute struct {
+	Tag string `json:"tag"`
+	eventRoute
+}
+
+// datadogTags are the tags of an alert, a comma separated string ($TAGS)
+// or an array.
+type datadogTags []string
+
+func (t *datadogTags) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*t = list
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = nil
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 {
+			*t = append(*t, tag)
+		}
+	}
+	return nil
+}
+
+// datadogAlert is the payload of a Datadog webhook.
+type datadogAlert struct {
+	ID         string      `json:"id"`
+	Title      string      `json:"title"`
+	Body       string      `json:"body"`
+	AlertType  string      `json:"alert_type"`
+	Transition string      `json:"transition"`
+	Priority   string      `json:"priority"`
+	Hostname   string      `json:"hostname"`
+	Tags       datadogTags `json:"tags"`
+	Link       string      `json:"link"`
+	Snapshot   string      `json:"snapshot"`
+}
+
+// datadogRoutes are the routes of the config file, nil without
+// serve --datadog.
+var datadogRoutes []*datadogRoute
+
+// transition in the title of the default payload, e.g.
+// "[P2] [Triggered on {host:web-1}] CPU high"
+var datadogTitleTransition = regexp.MustCompile(`\[(Triggered|Re-Triggered|Recovered|Warn|No Data|Re-Warn|Renotify)\b`)
+
+// newDatadogRoutes returns the routes of the config file.
+func newDatadogRoutes() ([]*datadogRoute, error) {
+	routes := []*datadogRoute{}
+	if len(configFile) > 0 {
+		c, err := loadConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+		if c.Datadog != nil {
+			for i, r := range c.Datadog.Routes {
+				if _, err := path.Match(r.Tag, ""); err != nil || len(r.Tag) == 0 {
+					return nil, fmt.Errorf("config file %s, datadog route %d: invalid tag %q", configFile, i+1, r.Tag)
+				}
+				if len(r.Team) == 0 && len(r.Email) == 0 {
+					return nil, fmt.Errorf("config file %s, datadog route %s: set team or email", configFile, r.Tag)
+				}
+				if len(r.Team) > 0 && len(r.Room) == 0 {
+					r.Room = roomName
+				}
+				routes = append(routes, r)
+			}
+		}
+	}
+	if len(routes) == 0 && len(teamName) == 0 && len(emailAddr) == 0 {
+		return nil, errors.New("--datadog needs the routes of the tags in the config file (-c) or flag -t or -D")
+	}
+	return routes, nil
+}
+
+func handleDatadog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !allowedSource(r) {
+		slog.Warn("Datadog alert of a source not allowed rejected", "remote", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if len(datadogSecret) > 0 && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Datadog-Secret")), []byte(datadogSecret)) != 1 {
+		slog.Warn("Datadog alert without valid secret rejected", "remote", r.RemoteAddr)
+		http.Error(w, "invalid secret", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, "invalid Datadog alert: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var alert datadogAlert
+	if err := json.Unmarshal(body, &alert); err != nil || len(alert.Title) == 0 {
+		http.Error(w, "invalid Datadog alert", http.StatusBadRequest)
+		return
+	}
+	n := datadogNotification(&alert)
+	if n == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if len(n.TeamName) == 0 && len(n.Email) == 0 {
+		slog.Warn("Datadog alert without room ignored", "title", alert.Title, "tags", strings.Join(alert.Tags, ","))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if digestWindow > 0 {
+		queueDigest(n)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	if err := sendOrSpool(ctx, n); err != nil {
+		slog.Error("posting Datadog alert failed", "title", alert.Title, "error", err)
+		http.Error(w, redact(err.Error()), http.StatusBadGateway)
+		return
+	}
+	slog.Info("Datadog alert posted", "title", alert.Title, "team", n.TeamName, "room", n.RoomName, "email", n.Email)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// datadogRouteOf returns the first route matching a tag of the alert, nil if
+// none matches.
+func datadogRouteOf(tags []string) *eventRoute {
+	for _, r := range datadogRoutes {
+		for _, tag := range tags {
+			if ok, _ := path.Match(r.Tag, tag); ok {
+				return &r.eventRoute
+			}
+		}
+	}
+	return nil
+}
+
+// datadogNotification returns the notification of the alert, nil if the
+// alert is suppressed by a rule (see rules.go).
+func datadogNotification(a *datadogAlert) *notification {
+	n := &notification{TeamName: teamName, RoomName: roomName, Email: emailAddr, Mentions: mentionEmails, Severity: severityName, Ack: ackButton}
+	if route := datadogRouteOf(a.Tags); route != nil {
+		n.TeamName, n.RoomName, n.Email = route.Team, route.Room, route.Email
+	}
+	if len(a.Transition) == 0 {
+		if m := datadogTitleTransition.FindStringSubmatch(a.Title); m != nil {
+			a.Transition = m[1]
+		}
+	}
+	recovered := a.AlertType == "success" || a.Transition == "Recovered"
+	// the severity colors the card
+	if len(n.Severity) == 0 && !recovered {
+		n.Severity = map[string]string{"error": "critical", "warning": "warning", "info": "info"}[a.AlertType]
+		if len(n.Severity) == 0 {
+			n.Severity = map[string]string{"Triggered": "critical", "Re-Triggered": "critical", "Warn": "warning", "Re-Warn": "warning", "No Data": "warning"}[a.Transition]
+		}
+	}
+
+	n.Markdown = "**" + a.Title + "**"
+	if len(a.Link) > 0 {
+		n.Markdown = fmt.Sprintf("[**%s**](%s)", a.Title, a.Link)
+	}
+	if recovered {
+		n.Markdown = "✅ " + n.Markdown
+	}
+	card, err := datadogCard(a, recovered && len(n.Severity) == 0)
+	if err != nil {
+		slog.Error("building the Datadog card failed", "title", a.Title, "error", err)
+	}
+	n.Card = card
+
+	fields := map[string]string{"title": a.Title, "transition": a.Transition, "alert_type": a.AlertType,
+		"priority": a.Priority, "hostname": a.Hostname, "id": a.ID, "tags": strings.Join(a.Tags, ",")}
+	for _, tag := range a.Tags {
+		if key, value, ok := strings.Cut(tag, ":"); ok {
+			fields["tags."+key] = value
+		}
+	}
+	post, err := applyEventRule("datadog", fields, n)
+	if err != nil {
+		slog.Error("applying the rule to the Datadog alert failed", "title", a.Title, "error", err)
+	}
+	if !post {
+		return nil
+	}
+	return n
+}
+
+// datadogText returns the message of the monitor without the %%% markers
+// of Datadog markdown.
+func datadogText(body string) string {
+	text := strings.TrimSpace(strings.ReplaceAll(body, "%%%", ""))
+	if utf8.RuneCountInString(text) > datadogMaxBody {
+		text = string([]rune(text)[:datadogMaxBody-1]) + "…"
+	}
+	return text
+}
+
+// datadogCard returns the card attachment of the alert, green if good.
+func datadogCard(a *datadogAlert, good bool) (string, error) {
+	var facts []interface{}
+	// facts without value are invalid
+	addFact := func(title, value string) {
+		if len(value) > 0 {
+			facts = append(facts, map[string]interface{}{"title": title, "value": value})
+		}
+	}
+	addFact(tr("Transition"), a.Transition)
+	addFact(tr("Priority"), a.Priority)
+	addFact(tr("Host"), a.Hostname)
+	addFact(tr("Tags"), strings.Join(a.Tags, ", "))
+
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": tr("Datadog monitor"), "isSubtle": true},
+		map[string]interface{}{"type": "TextBlock", "text": a.Title, "size": "Large", "weight": "Bolder", "wrap": true},
+	}
+	if text := datadogText(a.Body); len(text) > 0 {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true})
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	if strings.HasPrefix(a.Snapshot, "https://") {
+		body = append(body, map[string]interface{}{"type": "Image", "url": a.Snapshot, "altText": "snapshot"})
+	}
+	if good {
+		body = []interface{}{
+			map[string]interface{}{"type": "Container", "style": "good", "bleed": true, "items": body},
+		}
+	}
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": defaultCardVersion,
+		"body":    body,
+	}
+	if len(a.Link) > 0 {
+		card["actions"] = []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": trf("Open in %s", "Datadog"), "url": a.Link},
+		}
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
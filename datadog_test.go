@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDatadogTags(t *testing.T) {
+	for _, tt := range []struct {
+		data string
+		want datadogTags
+	}{
+		{`["env:prod", "service:shop"]`, datadogTags{"env:prod", "service:shop"}},
+		{`"env:prod, service:shop,"`, datadogTags{"env:prod", "service:shop"}},
+		{`""`, nil},
+	} {
+		var got datadogTags
+		if err := json.Unmarshal([]byte(tt.data), &got); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unmarshal tags %s = %q, %v, want %q", tt.data, got, err, tt.want)
+		}
+	}
+	var tags datadogTags
+	if err := json.Unmarshal([]byte(`42`), &tags); err == nil {
+		t.Error("unmarshal tags 42 succeeded")
+	}
+}
+
+func TestDatadogText(t *testing.T) {
+	if got := datadogText("%%%\nCPU above **90%**\n%%%"); got != "CPU above **90%**" {
+		t.Errorf("datadogText() = %q", got)
+	}
+	if got := datadogText(strings.Repeat("é", datadogMaxBody+1)); len([]rune(got)) != datadogMaxBody || !strings.HasSuffix(got, "…") {
+		t.Errorf("datadogText() of a long body = %d runes", len([]rune(got)))
+	}
+}
+
+func TestDatadogNotification(t *testing.T) {
+	defer func(team, room string, routes []*datadogRoute) {
+		teamName, roomName, datadogRoutes = team, room, routes
+	}(teamName, roomName, datadogRoutes)
+	teamName, roomName = "KMP-Team", "Monitoring"
+	datadogRoutes = []*datadogRoute{{Tag: "service:shop-*", eventRoute: eventRoute{Team: "KMP-Team", Room: "Shop"}}}
+
+	for _, tt := range []struct {
+		name         string
+		alert        datadogAlert
+		wantRoom     string
+		wantSeverity string
+		wantMarkdown string
+		wantCard     []string
+	}{
+		{name: "triggered", alert: datadogAlert{Title: "[P2] [Triggered on {host:web-1}] CPU high", Tags: datadogTags{"env:prod", "service:shop-api"},
+			Link: "https://app.datadoghq.com/monitors/1", Snapshot: "https://p.datadoghq.com/snapshot.png"},
+			wantRoom: "Shop", wantSeverity: "critical", wantMarkdown: "[**[P2] [Triggered on {host:web-1}] CPU high**](https://app.datadoghq.com/monitors/1)",
+			wantCard: []string{`{"title":"Transition","value":"Triggered"}`, `"url":"https://p.datadoghq.com/snapshot.png"`, `"title":"Open in Datadog"`}},
+		{name: "warning", alert: datadogAlert{Title: "Disk usage", AlertType: "warning", Transition: "Warn", Tags: datadogTags{"service:billing"}},
+			wantRoom: "Monitoring", wantSeverity: "warning", wantMarkdown: "**Disk usage**"},
+		{name: "no data", alert: datadogAlert{Title: "[No Data on {host:db-1}] Postgres"},
+			wantRoom: "Monitoring", wantSeverity: "warning", wantMarkdown: "**[No Data on {host:db-1}] Postgres**"},
+		{name: "recovered", alert: datadogAlert{Title: "[Recovered on {host:web-1}] CPU high", Body: "%%%\nCPU back to 40%\n%%%"},
+			wantRoom: "Monitoring", wantMarkdown: "✅ **[Recovered on {host:web-1}] CPU high**",
+			wantCard: []string{`"style":"good"`, `"text":"CPU back to 40%"`}},
+	} {
+		n := datadogNotification(&tt.alert)
+		if n == nil {
+			t.Errorf("%s: datadogNotification() = nil", tt.name)
+			continue
+		}
+		if n.RoomName != tt.wantRoom || n.Severity != tt.wantSeverity || n.Markdown != tt.wantMarkdown {
+			t.Errorf("%s: datadogNotification() = %q, %s to %s, want %q, %s to %s", tt.name, n.Markdown, n.Severity, n.RoomName, tt.wantMarkdown, tt.wantSeverity, tt.wantRoom)
+		}
+		for _, want := range tt.wantCard {
+			if !strings.Contains(n.Card, want) {
+				t.Errorf("%s: datadogNotification() card = %s, want %s", tt.name, n.Card, want)
+			}
+		}
+	}
+
+	defer func() { eventRules = nil }()
+	eventRules = []*eventRule{{Source: "datadog", Match: map[string]string{"tags.env": "staging"}, Suppress: true}}
+	if n := datadogNotification(&datadogAlert{Title: "CPU high", Tags: datadogTags{"env:staging"}}); n != nil {
+		t.Errorf("datadogNotification() of a suppressed alert = %+v, want nil", n)
+	}
+}
+
+func TestNewDatadogRoutes(t *testing.T) {
+	defer func(file, team, room string) { configFile, teamName, roomName = file, team, room }(configFile, teamName, roomName)
+	configFile, teamName, roomName = filepath.Join(t.TempDir(), "config.json"), "", "Monitoring"
+	for _, tt := range []struct {
+		config, wantErr string
+	}{
+		{`{"datadog": {"routes": [{"tag": "service:shop-*", "team": "KMP-Team"}]}}`, ""},
+		{`{"datadog": {"routes": [{"tag": "service:[", "team": "KMP-Team"}]}}`, `datadog route 1: invalid tag "service:["`},
+		{`{"datadog": {"routes": [{"tag": "env:prod"}]}}`, "datadog route env:prod: set team or email"},
+		{`{}`, "--datadog needs the routes"},
+	} {
+		if err := os.WriteFile(configFile, []byte(tt.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+		routes, err := newDatadogRoutes()
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newDatadogRoutes(%s) error = %v, want %s", tt.config, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || len(routes) != 1 || routes[0].Room != "Monitoring" {
+			t.Errorf("newDatadogRoutes(%s) = %+v, %v, want the route to the room of -r", tt.config, routes, err)
+		}
+	}
+}
+
+func TestHandleDatadog(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(team, room, secret string) { teamName, roomName, datadogSecret = team, room, secret }(teamName, roomName, datadogSecret)
+	teamName, roomName, datadogSecret = "KMP-Team", "Monitoring", "s3cr3t"
+
+	for _, tt := range []struct {
+		name, secret, body string
+		want               int
+	}{
+		{"alert", "s3cr3t", `{"title": "CPU high", "alert_type": "error", "tags": "env:prod"}`, http.StatusNoContent},
+		{"wrong secret", "guess", `{"title": "CPU high"}`, http.StatusUnauthorized},
+		{"no title", "s3cr3t", `{"body": "CPU high"}`, http.StatusBadRequest},
+	} {
+		r := httptest.NewRequest("POST", "/datadog", strings.NewReader(tt.body))
+		r.Header.Set("X-Datadog-Secret", tt.secret)
+		w := httptest.NewRecorder()
+		handleDatadog(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: handleDatadog() status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+	if m := fake.Messages(); len(m) != 1 || m[0].Markdown != "🚨 **CPU high**" {
+		t.Errorf("messages = %+v, want the alert", m)
+	}
+}
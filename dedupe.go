@@ -0,0 +1,176 @@
+// dedupe.go
+//
+// Duplicate message suppression (flag --dedupe). A message is identified by
+// a hash of its recipient and its rendered content. If the same message was
+// sent within the window, it is skipped. The hashes are kept with their send
+// time in a small state file (--dedupe-file), so the window spans separate
+// invocations, e.g. of a monitoring system during an alert storm. Parallel
+// invocations are serialized by a lock file.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	dedupeLockTimeout = 10 * time.Second
+	// a lock older than this is left over from a crashed process
+	dedupeStaleLock = time.Minute
+)
+
+// errDuplicate is returned by deliverNotification for a suppressed message.
+var errDuplicate = errors.New("duplicate message")
+
+func defaultDedupeFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "notify_by_webex_teams", "dedupe.json")
+}
+
+// dedupeKey returns the hash identifying the rendered notification n.
+func dedupeKey(n *notification) string {
+	h := sha256.New()
+	for _, s := range []string{n.TeamName, n.RoomName, n.Email, n.Markdown, n.Card, n.File} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	for _, file := range n.Files {
+		h.Write([]byte(file))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// claimMessage records key as sent now and returns true, or returns false
+// if it was already sent within window. The claim is recorded before the
+// message is sent, so parallel invocations do not both send it. If sending
+// fails, releaseMessage removes the claim.
+func claimMessage(key string, window time.Duration) (bool, error) {
+	return claimKey(dedupeFile, key, window)
+}
+
+func releaseMessage(key string) error {
+	return releaseKey(dedupeFile, key)
+}
+
+// claimKey records key as sent now in the state file and returns true, or
+// returns false if it was already sent within window.
+func claimKey(file, key string, window time.Duration) (bool, error) {
+	var first bool
+	err := updateStateFile(file, func(sent map[string]time.Time) {
+		now := time.Now()
+		for k, t := range sent {
+			if now.Sub(t) >= window {
+				delete(sent, k)
+			}
+		}
+		if _, ok := sent[key]; !ok {
+			sent[key] = now
+			first = true
+		}
+	})
+	return first, err
+}
+
+// releaseKey removes the claim of key from the state file.
+func releaseKey(file, key string) error {
+	return updateStateFile(file, func(sent map[string]time.Time) {
+		delete(sent, key)
+	})
+}
+
+// updateStateFile applies update to the state file while holding its lock.
+func updateStateFile(file string, update func(sent map[string]time.Time)) error {
+	unlock, err := lockStateFile(file)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	sent := make(map[string]time.Time)
+	data, err := readStateFile(file)
+	if err != nil {
+		return err
+	}
+	if data != nil && json.Unmarshal(data, &sent) != nil {
+		// start over instead of failing every message
+		sent = make(map[string]time.Time)
+	}
+	update(sent)
+
+	data, err = json.Marshal(sent)
+	if err != nil {
+		return err
+	}
+	return writeStateFile(file, data)
+}
+
+// lockStateFile creates the directory of the state file and takes its lock.
+func lockStateFile(file string) (func(), error) {
+	err := os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return nil, err
+	}
+	return lockFile(file + ".lock")
+}
+
+// readStateFile returns the opened content of the state file, or nil if it
+// does not exist yet.
+func readStateFile(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err == nil {
+		data, err = openState(data)
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// writeStateFile seals data and replaces the state file with it.
+func writeStateFile(file string, data []byte) error {
+	data, err := sealState(data)
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// lockFile creates the lock file name exclusively, waiting for other
+// processes holding it, and returns the function removing it.
+func lockFile(name string) (func(), error) {
+	deadline := time.Now().Add(dedupeLockTimeout)
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(name); err == nil && time.Since(fi.ModTime()) > dedupeStaleLock {
+			os.Remove(name)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for lock file %s", name)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
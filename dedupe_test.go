@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDedupeKey(t *testing.T) {
+	base := notification{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "disk full"}
+	key := dedupeKey(&base)
+	if again := base; dedupeKey(&again) != key {
+		t.Errorf("dedupeKey() differs for the same message")
+	}
+	for _, n := range []notification{
+		{TeamName: "KMP-Team", RoomName: "Ops", Markdown: "disk full"},
+		{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "disk full!"},
+		{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "disk full", Files: []string{"df.txt"}},
+		// the fields are separated: "Alerts" + "disk full" is not "Alertsdisk" + " full"
+		{TeamName: "KMP-Team", RoomName: "Alertsdisk", Markdown: " full"},
+	} {
+		if dedupeKey(&n) == key {
+			t.Errorf("dedupeKey(%+v) equals the key of %+v", n, base)
+		}
+	}
+}
+
+func TestClaimKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "state", "dedupe.json")
+	for _, tt := range []struct {
+		key    string
+		window time.Duration
+		want   bool
+	}{
+		{"a", time.Hour, true},
+		{"a", time.Hour, false},
+		{"b", time.Hour, true},
+		// expired claims are removed
+		{"a", time.Nanosecond, true},
+	} {
+		got, err := claimKey(file, tt.key, tt.window)
+		if err != nil || got != tt.want {
+			t.Errorf("claimKey(%q, %s) = %v, %v, want %v", tt.key, tt.window, got, err, tt.want)
+		}
+	}
+	if err := releaseKey(file, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if first, _ := claimKey(file, "b", time.Hour); !first {
+		t.Errorf("claimKey() of a released key = false")
+	}
+
+	// a corrupt state file is started over
+	if err := os.WriteFile(file, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if first, err := claimKey(file, "a", time.Hour); err != nil || !first {
+		t.Errorf("claimKey() with a corrupt state file = %v, %v, want true", first, err)
+	}
+}
+
+func TestClaimKeyParallel(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dedupe.json")
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claimed := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first, err := claimKey(file, "alert", time.Hour)
+			if err != nil {
+				t.Error(err)
+			}
+			if first {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Errorf("%d parallel claims of the same key succeeded, want 1", claimed)
+	}
+}
+
+func TestLockFileStale(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "dedupe.json.lock")
+	if err := os.WriteFile(name, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * dedupeStaleLock)
+	if err := os.Chtimes(name, old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockFile(name)
+	if err != nil {
+		t.Fatalf("lockFile() with a stale lock: %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("lock file not removed by unlock: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+// digest.go
+//
+// Digest mode of the long-running modes (flag --digest). Messages to the
+// same recipient are collected for the digest window, starting with the
+// first message, and sent as one digest message. Identical messages are
+// counted instead of repeated, e.g.
+//
+//	**17 notifications** within 5m0s
+//	- ⚠️ disk usage above 90% (12×)
+//	- ⚠️ inode usage above 90% (5×)
+//
+// A window with a single message sends it unchanged. Messages with a file
+// are never combined. The digest has the most severe severity of its
+// messages and @mentions everyone mentioned by them, cards are dropped.
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maximum number of distinct messages listed in a digest
+const digestMaxItems = 30
+
+// severities by increasing rank. other severities rank below info
+var severityRanks = []string{"info", "warning", "critical"}
+
+type digestItem struct {
+	text  string
+	count int
+}
+
+// pendingDigest collects the messages to one recipient.
+type pendingDigest struct {
+	messages []*notification
+	items    []*digestItem
+	timer    *time.Timer
+}
+
+var digests struct {
+	sync.Mutex
+	pending map[string]*pendingDigest
+	sending sync.WaitGroup
+}
+
+// queueDigest adds n to the digest of its recipient, or sends it right away
+// if it cannot be combined.
+func queueDigest(n *notification) {
+	if len(n.File) > 0 || len(n.Files) > 0 {
+		digests.sending.Add(1)
+		go func() {
+			defer digests.sending.Done()
+			sendDigest([]*notification{n}, nil)
+		}()
+		return
+	}
+	key := strings.ToLower(n.TeamName + "\x00" + n.RoomName + "\x00" + n.Email)
+
+	digests.Lock()
+	defer digests.Unlock()
+	if digests.pending == nil {
+		digests.pending = make(map[string]*pendingDigest)
+	}
+	d := digests.pending[key]
+	if d == nil {
+		d = &pendingDigest{}
+		digests.pending[key] = d
+		d.timer = time.AfterFunc(digestWindow, func() {
+			digests.Lock()
+			// flushDigests may have sent the digest already
+			if digests.pending[key] != d {
+				digests.Unlock()
+				return
+			}
+			delete(digests.pending, key)
+			digests.sending.Add(1)
+			digests.Unlock()
+			defer digests.sending.Done()
+			sendDigest(d.messages, d.items)
+		})
+	}
+	d.messages = append(d.messages, n)
+	text := digestText(n)
+	for _, item := range d.items {
+		if item.text == text {
+			item.count++
+			return
+		}
+	}
+	d.items = append(d.items, &digestItem{text: text, count: 1})
+}
+
+// flushDigests sends the pending digests without waiting for the end of
+// their window and waits for the digests being sent, e.g. on shutdown.
+func flushDigests() {
+	digests.Lock()
+	pending := digests.pending
+	digests.pending = nil
+	digests.Unlock()
+	for _, d := range pending {
+		d.timer.Stop()
+		sendDigest(d.messages, d.items)
+	}
+	digests.sending.Wait()
+}
+
+func sendDigest(messages []*notification, items []*digestItem) {
+	n := messages[0]
+	if len(messages) > 1 {
+		n = buildDigest(messages, items)
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	err := sendOrSpool(ctx, n)
+	if err != nil {
+		slog.Error("sending digest failed", "team", n.TeamName, "room", n.RoomName, "email", n.Email, "messages", len(messages), "error", err)
+		return
+	}
+	slog.Info("digest sent", "team", n.TeamName, "room", n.RoomName, "email", n.Email, "messages", len(messages))
+}
+
+// buildDigest combines messages into one notification.
+func buildDigest(messages []*notification, items []*digestItem) *notification {
+	first := messages[0]
+	n := &notification{TeamName: first.TeamName, RoomName: first.RoomName, Email: first.Email}
+	seen := make(map[string]bool)
+	for _, m := range messages {
+		if severityRank(m.Severity) > severityRank(n.Severity) || len(n.Severity) == 0 {
+			n.Severity = m.Severity
+		}
+		n.MentionAll = n.MentionAll || m.MentionAll
+		for _, email := range m.Mentions {
+			if !seen[strings.ToLower(email)] {
+				seen[strings.ToLower(email)] = true
+				n.Mentions = append(n.Mentions, email)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(trf("**%d notifications** within %s", len(messages), digestWindow) + "\n")
+	for i, item := range items {
+		if i == digestMaxItems {
+			b.WriteString("- " + trf("… and %d more", len(items)-digestMaxItems) + "\n")
+			break
+		}
+		if item.count > 1 {
+			fmt.Fprintf(&b, "- %s (%d×)\n", item.text, item.count)
+		} else {
+			fmt.Fprintf(&b, "- %s\n", item.text)
+		}
+	}
+	n.Markdown = b.String()
+	return n
+}
+
+// digestText returns the first line of the message, with the emoji of its
+// severity.
+func digestText(n *notification) string {
+	text := strings.TrimSpace(n.Markdown)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = strings.TrimSpace(text[:i])
+	}
+	if len(text) == 0 && len(n.Card) > 0 {
+		text = "(card)"
+	}
+	if style, err := lookupSeverity(n.Severity); err == nil && len(n.Severity) > 0 && len(style.Emoji) > 0 {
+		text = style.Emoji + " " + text
+	}
+	return text
+}
+
+func severityRank(name string) int {
+	for i, s := range severityRanks {
+		if strings.EqualFold(s, name) {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSeverityRank(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want int
+	}{
+		{"", 0},
+		{"debug", 0},
+		{"info", 1},
+		{"Warning", 2},
+		{"CRITICAL", 3},
+	} {
+		if got := severityRank(tt.name); got != tt.want {
+			t.Errorf("severityRank(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDigestText(t *testing.T) {
+	defer func(file string) { configFile, severitiesOnce = file, sync.Once{} }(configFile)
+	configFile, severitiesOnce = "", sync.Once{}
+	for _, tt := range []struct {
+		n    notification
+		want string
+	}{
+		{notification{Markdown: "  disk full on srv1\nused 95%\n"}, "disk full on srv1"},
+		{notification{Markdown: "disk full", Severity: "warning"}, "⚠️ disk full"},
+		{notification{Markdown: "disk full", Severity: "fatal"}, "disk full"},
+		{notification{Card: testCard}, "(card)"},
+	} {
+		if got := digestText(&tt.n); got != tt.want {
+			t.Errorf("digestText(%q) = %q, want %q", tt.n.Markdown, got, tt.want)
+		}
+	}
+}
+
+func TestBuildDigest(t *testing.T) {
+	defer func(w time.Duration) { digestWindow = w }(digestWindow)
+	digestWindow = 5 * time.Minute
+	messages := []*notification{
+		{TeamName: "KMP-Team", RoomName: "Alerts", Severity: "info", Mentions: []string{"alice@example.com"}},
+		{TeamName: "KMP-Team", RoomName: "Alerts", Severity: "critical", Mentions: []string{"Alice@example.com", "bob@example.com"}},
+		{TeamName: "KMP-Team", RoomName: "Alerts", Severity: "warning", MentionAll: true},
+	}
+	items := []*digestItem{{text: "disk full", count: 2}, {text: "cpu high", count: 1}}
+	n := buildDigest(messages, items)
+	if n.TeamName != "KMP-Team" || n.RoomName != "Alerts" || n.Severity != "critical" || !n.MentionAll {
+		t.Errorf("buildDigest() = %+v, want critical to KMP-Team/Alerts mentioning all", n)
+	}
+	if got := strings.Join(n.Mentions, ","); got != "alice@example.com,bob@example.com" {
+		t.Errorf("buildDigest() mentions = %s, want alice and bob once", got)
+	}
+	if want := "**3 notifications** within 5m0s\n- disk full (2×)\n- cpu high\n"; n.Markdown != want {
+		t.Errorf("buildDigest() markdown = %q, want %q", n.Markdown, want)
+	}
+
+	items = nil
+	for i := 0; i < digestMaxItems+5; i++ {
+		items = append(items, &digestItem{text: strings.Repeat("x", i+1), count: 1})
+	}
+	n = buildDigest(messages, items)
+	if lines := strings.Count(n.Markdown, "\n"); lines != digestMaxItems+2 || !strings.HasSuffix(n.Markdown, "- … and 5 more\n") {
+		t.Errorf("buildDigest() of %d items = %d lines ending %q", len(items), lines, n.Markdown[len(n.Markdown)-20:])
+	}
+}
+
+func TestQueueDigest(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(w time.Duration) { digestWindow = w }(digestWindow)
+	digestWindow = time.Hour
+
+	queueDigest(&notification{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "disk full"})
+	queueDigest(&notification{TeamName: "KMP-Team", RoomName: "alerts", Markdown: "disk full\ndetails"})
+	queueDigest(&notification{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "cpu high"})
+	queueDigest(&notification{TeamName: "KMP-Team", RoomName: "Builds", Markdown: "build failed"})
+	if got := len(fake.Messages()); got != 0 {
+		t.Fatalf("%d messages sent before the end of the window, want 0", got)
+	}
+	flushDigests()
+
+	var got []string
+	for _, m := range fake.Messages() {
+		got = append(got, m.Markdown)
+	}
+	want := []string{"**3 notifications** within 1h0m0s\n- disk full (2×)\n- cpu high\n", "build failed"}
+	if len(got) != 2 || (got[0] != want[0] && got[1] != want[0]) || (got[0] != want[1] && got[1] != want[1]) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+}
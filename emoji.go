@@ -0,0 +1,235 @@
+// emoji.go
+//
+// Expansion of emoji shortcodes like :warning: or :white_check_mark: in
+// messages. Webex markdown shows the shortcodes as typed, and emoji are hard
+// to type in shell scripts. Unknown shortcodes and shortcodes in code spans
+// and code blocks are left unchanged. Flag --no-emoji turns the expansion
+// off.
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var emojiPattern = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+
+// emojiShortcodes are the common shortcodes of GitHub and Slack.
+var emojiShortcodes = map[string]string{
+	"+1":                         "👍",
+	"-1":                         "👎",
+	"100":                        "💯",
+	"alarm_clock":                "⏰",
+	"ambulance":                  "🚑",
+	"arrow_down":                 "⬇️",
+	"arrow_forward":              "▶️",
+	"arrow_left":                 "⬅️",
+	"arrow_right":                "➡️",
+	"arrow_up":                   "⬆️",
+	"arrows_counterclockwise":    "🔄",
+	"balloon":                    "🎈",
+	"bangbang":                   "‼️",
+	"bar_chart":                  "📊",
+	"battery":                    "🔋",
+	"beer":                       "🍺",
+	"bell":                       "🔔",
+	"black_circle":               "⚫",
+	"blue_circle":                "🔵",
+	"bomb":                       "💣",
+	"book":                       "📖",
+	"boom":                       "💥",
+	"broken_heart":               "💔",
+	"bug":                        "🐛",
+	"bulb":                       "💡",
+	"calendar":                   "📆",
+	"calendar_spiral":            "🗓️",
+	"cat":                        "🐱",
+	"chart":                      "💹",
+	"chart_with_downwards_trend": "📉",
+	"chart_with_upwards_trend":   "📈",
+	"check":                      "✔️",
+	"checkered_flag":             "🏁",
+	"christmas_tree":             "🎄",
+	"clap":                       "👏",
+	"clipboard":                  "📋",
+	"clock":                      "🕐",
+	"cloud":                      "☁️",
+	"coffee":                     "☕",
+	"computer":                   "💻",
+	"confused":                   "😕",
+	"construction":               "🚧",
+	"cool":                       "🆒",
+	"crown":                      "👑",
+	"cry":                        "😢",
+	"dart":                       "🎯",
+	"desktop_computer":           "🖥️",
+	"disappointed":               "😞",
+	"dizzy":                      "💫",
+	"dog":                        "🐶",
+	"dollar":                     "💵",
+	"droplet":                    "💧",
+	"electric_plug":              "🔌",
+	"email":                      "📧",
+	"envelope":                   "✉️",
+	"euro":                       "💶",
+	"exclamation":                "❗",
+	"eyes":                       "👀",
+	"facepalm":                   "🤦",
+	"fast_forward":               "⏩",
+	"file_folder":                "📁",
+	"fire":                       "🔥",
+	"fire_engine":                "🚒",
+	"floppy_disk":                "💾",
+	"free":                       "🆓",
+	"gear":                       "⚙️",
+	"ghost":                      "👻",
+	"gift":                       "🎁",
+	"globe_with_meridians":       "🌐",
+	"green_circle":               "🟢",
+	"green_heart":                "💚",
+	"grey_exclamation":           "❕",
+	"grey_question":              "❔",
+	"hammer":                     "🔨",
+	"hammer_and_wrench":          "🛠️",
+	"heart":                      "❤️",
+	"heart_eyes":                 "😍",
+	"heavy_check_mark":           "✔️",
+	"heavy_exclamation_mark":     "❗",
+	"heavy_minus_sign":           "➖",
+	"heavy_multiplication_x":     "✖️",
+	"heavy_plus_sign":            "➕",
+	"hospital":                   "🏥",
+	"hourglass":                  "⌛",
+	"hourglass_flowing_sand":     "⏳",
+	"house":                      "🏠",
+	"inbox_tray":                 "📥",
+	"information_source":         "ℹ️",
+	"joy":                        "😂",
+	"key":                        "🔑",
+	"label":                      "🏷️",
+	"large_blue_circle":          "🔵",
+	"large_green_circle":         "🟢",
+	"link":                       "🔗",
+	"lock":                       "🔒",
+	"loudspeaker":                "📢",
+	"mag":                        "🔍",
+	"mailbox":                    "📫",
+	"medal":                      "🏅",
+	"memo":                       "📝",
+	"money_with_wings":           "💸",
+	"moneybag":                   "💰",
+	"muscle":                     "💪",
+	"new":                        "🆕",
+	"no_entry":                   "⛔",
+	"no_entry_sign":              "🚫",
+	"ok":                         "🆗",
+	"ok_hand":                    "👌",
+	"open_file_folder":           "📂",
+	"orange_circle":              "🟠",
+	"outbox_tray":                "📤",
+	"package":                    "📦",
+	"page_facing_up":             "📄",
+	"pause_button":               "⏸️",
+	"pencil":                     "📝",
+	"pencil2":                    "✏️",
+	"penguin":                    "🐧",
+	"phone":                      "☎️",
+	"point_right":                "👉",
+	"police_car":                 "🚓",
+	"pray":                       "🙏",
+	"pushpin":                    "📌",
+	"question":                   "❓",
+	"rage":                       "😡",
+	"raised_hands":               "🙌",
+	"recycle":                    "♻️",
+	"red_circle":                 "🔴",
+	"repeat":                     "🔁",
+	"rewind":                     "⏪",
+	"robot":                      "🤖",
+	"rocket":                     "🚀",
+	"rotating_light":             "🚨",
+	"satellite":                  "📡",
+	"scream":                     "😱",
+	"see_no_evil":                "🙈",
+	"shield":                     "🛡️",
+	"shrug":                      "🤷",
+	"skull":                      "💀",
+	"sleeping":                   "😴",
+	"small_red_triangle":         "🔺",
+	"small_red_triangle_down":    "🔻",
+	"smile":                      "😄",
+	"smiley":                     "😃",
+	"snail":                      "🐌",
+	"sos":                        "🆘",
+	"sparkles":                   "✨",
+	"speech_balloon":             "💬",
+	"star":                       "⭐",
+	"stop_button":                "⏹️",
+	"stop_sign":                  "🛑",
+	"stopwatch":                  "⏱️",
+	"sunglasses":                 "😎",
+	"sunny":                      "☀️",
+	"sweat_smile":                "😅",
+	"tada":                       "🎉",
+	"telephone_receiver":         "📞",
+	"thermometer":                "🌡️",
+	"thinking":                   "🤔",
+	"thumbsdown":                 "👎",
+	"thumbsup":                   "👍",
+	"timer_clock":                "⏲️",
+	"tools":                      "🛠️",
+	"trophy":                     "🏆",
+	"turtle":                     "🐢",
+	"unlock":                     "🔓",
+	"up":                         "🆙",
+	"warning":                    "⚠️",
+	"wave":                       "👋",
+	"whale":                      "🐳",
+	"white_check_mark":           "✅",
+	"white_circle":               "⚪",
+	"wink":                       "😉",
+	"wrench":                     "🔧",
+	"x":                          "❌",
+	"yellow_circle":              "🟡",
+	"zap":                        "⚡",
+	"zzz":                        "💤",
+}
+
+// expandEmoji replaces the known emoji shortcodes of the markdown text
+// outside of code.
+func expandEmoji(markdown string) string {
+	if !strings.Contains(markdown, ":") {
+		return markdown
+	}
+	var out strings.Builder
+	fenced := false
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			out.WriteString(line)
+			continue
+		}
+		if fenced {
+			out.WriteString(line)
+			continue
+		}
+		// odd parts are code spans
+		parts := strings.Split(line, "`")
+		for j, part := range parts {
+			if j > 0 {
+				out.WriteString("`")
+			}
+			if j%2 == 1 && j < len(parts)-1 {
+				out.WriteString(part)
+				continue
+			}
+			out.WriteString(emojiPattern.ReplaceAllStringFunc(part, func(code string) string {
+				if emoji, ok := emojiShortcodes[strings.Trim(code, ":")]; ok {
+					return emoji
+				}
+				return code
+			}))
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestExpandEmoji(t *testing.T) {
+	for _, tt := range []struct {
+		markdown, want string
+	}{
+		{"backup done :white_check_mark:", "backup done ✅"},
+		{":fire: disk full :fire:", "🔥 disk full 🔥"},
+		{"unknown :no_such_emoji: stays", "unknown :no_such_emoji: stays"},
+		{"time 10:30:00 stays", "time 10:30:00 stays"},
+		{"`:fire:` in code, :fire: outside", "`:fire:` in code, 🔥 outside"},
+		{"```\n:fire:\n```\n:fire:", "```\n:fire:\n```\n🔥"},
+		{"odd ` backtick :fire:", "odd ` backtick 🔥"},
+	} {
+		if got := expandEmoji(tt.markdown); got != tt.want {
+			t.Errorf("expandEmoji(%q) = %q, want %q", tt.markdown, got, tt.want)
+		}
+	}
+}
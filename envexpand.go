@@ -0,0 +1,76 @@
+// envexpand.go
+//
+// Expansion of environment variables in the message (flag --expand-env).
+// ${NAME} in -m, the message file, the card of -a or -A and the message and
+// card files of the templates is replaced by the value of the environment
+// variable NAME, ${NAME:-default} by default if NAME is unset or empty, so
+// wrapper scripts need no quoting:
+//
+//	notify_by_webex_teams --expand-env 'CI_*,DEPLOY_ENV' -t KMP-Team -r Deployments -m '${CI_PROJECT_NAME} deployed to ${DEPLOY_ENV:-staging}'
+//
+// Only the variables of the comma separated names of --expand-env, which may
+// contain the wildcard *, are expanded, so no other environment content, e.g.
+// a token, ends up in a message. The other ${NAME} stay unchanged, $${ is a
+// literal ${. Standard input (-i) and the messages of serve are never
+// expanded. In cards the values are inserted JSON escaped.
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// envVarRE matches ${NAME}, ${NAME:-default} and the escape $${.
+var envVarRE = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// envAllowed reports whether the environment variable name may be expanded
+// by --expand-env.
+func envAllowed(name string) bool {
+	for _, pattern := range strings.Split(expandEnvList, ",") {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// expandEnv expands the allowed environment variables in s. quote is applied
+// to the values, e.g. to escape them for JSON, or nil.
+func expandEnv(s string, quote func(string) string) string {
+	if len(expandEnvList) == 0 {
+		return s
+	}
+	warned := make(map[string]bool)
+	return envVarRE.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+		m := envVarRE.FindStringSubmatch(match)
+		name, def := m[1], m[2]
+		if !envAllowed(name) {
+			if !warned[name] {
+				slog.Warn("environment variable not expanded, it is not allowed by --expand-env", "name", name)
+				warned[name] = true
+			}
+			return match
+		}
+		value := os.Getenv(name)
+		if len(value) == 0 {
+			value = def
+		}
+		if quote != nil {
+			value = quote(value)
+		}
+		return value
+	})
+}
+
+// jsonEscape escapes s for a JSON string, without the quotes.
+func jsonEscape(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestExpandEnv(t *testing.T) {
+	defer func(list string) { expandEnvList = list }(expandEnvList)
+	t.Setenv("CI_PROJECT_NAME", "billing")
+	t.Setenv("DEPLOY_ENV", "")
+	t.Setenv("WEBEX_TOKEN", "s3cr3t")
+	t.Setenv("CI_COMMIT_MESSAGE", `fix "quotes"`)
+
+	for _, tt := range []struct {
+		list, s string
+		quote   func(string) string
+		want    string
+	}{
+		{"", "${CI_PROJECT_NAME}", nil, "${CI_PROJECT_NAME}"},
+		{"CI_*,DEPLOY_ENV", "${CI_PROJECT_NAME} deployed to ${DEPLOY_ENV:-staging}", nil, "billing deployed to staging"},
+		{"CI_*", "${CI_PROJECT_NAME} with ${WEBEX_TOKEN}", nil, "billing with ${WEBEX_TOKEN}"},
+		{"*", "${CI_PROJECT_NAME}, $${CI_PROJECT_NAME}, $CI_PROJECT_NAME", nil, "billing, ${CI_PROJECT_NAME}, $CI_PROJECT_NAME"},
+		{"CI_*", "${CI_UNSET}|${CI_UNSET:-}|${CI_UNSET:-a b}", nil, "||a b"},
+		{" CI_COMMIT_MESSAGE ", `{"text":"${CI_COMMIT_MESSAGE}"}`, jsonEscape, `{"text":"fix \"quotes\""}`},
+	} {
+		expandEnvList = tt.list
+		if got := expandEnv(tt.s, tt.quote); got != tt.want {
+			t.Errorf("expandEnv(%q) with --expand-env %q = %q, want %q", tt.s, tt.list, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// escalate.go
+//
+// Escalation of unacknowledged cards (flags --escalate-after,
+// --escalate-room). A card with Acknowledge button (--ack) which is not
+// acknowledged within --escalate-after is posted again to the escalation
+// room, in the team of the card or -t, with @mentions of --escalate-mention
+// or else the mentions of the message:
+//
+//	notify_by_webex_teams -t KMP-Team -r Alerts -m "**disk full** on db-1" --ack --escalate-after 15m --escalate-room "Ops Managers" --escalate-mention lead@example.com
+//
+// The relay (serve --ack) replaces an acknowledged card by a new card, so a
+// card which still exists at the end of the window was not acknowledged.
+// The CLI waits for the window, the relay escalates the cards of relayed
+// notifications and of its alerts in the background. Escalations pending on
+// shutdown of the relay are lost.
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// escalations are the cards waiting for their acknowledgement
+var escalations sync.WaitGroup
+
+// checkEscalation checks the escalation flags.
+func checkEscalation() error {
+	if escalateAfter == 0 {
+		return nil
+	}
+	switch {
+	case escalateAfter < 0:
+		return errors.New("--escalate-after must be positive")
+	case !ackButton:
+		return errors.New("--escalate-after needs --ack")
+	case len(escalateRoom) == 0:
+		return errors.New("--escalate-after needs --escalate-room")
+	}
+	return nil
+}
+
+// watchEscalation escalates the card message m of n if it is not
+// acknowledged within --escalate-after.
+func watchEscalation(n *notification, m *webex.Message) {
+	if escalateAfter <= 0 || len(escalateRoom) == 0 || !hasAckButton(n.Card) {
+		return
+	}
+	if n.RoomName == escalateRoom {
+		// the escalation itself, which keeps the button
+		return
+	}
+	escalations.Add(1)
+	slog.Debug("waiting for the acknowledgement", "messageID", m.ID, "window", escalateAfter)
+	time.AfterFunc(escalateAfter, func() {
+		defer escalations.Done()
+		if err := escalate(n, m); err != nil {
+			slog.Error("escalating the card failed", "messageID", m.ID, "room", escalateRoom, "error", err)
+		}
+	})
+}
+
+// escalate posts the card to the escalation room unless it was
+// acknowledged.
+func escalate(n *notification, m *webex.Message) error {
+	client, err := profileClient(n.Profile)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	_, err = client.GetMessage(ctx, m.ID)
+	var apiErr *webex.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		slog.Info("card acknowledged in time", "messageID", m.ID)
+		return nil
+	}
+	if err != nil {
+		// rather escalate once too often
+		slog.Warn("looking up the card failed, escalating", "messageID", m.ID, "error", err)
+	}
+
+	team := firstNonEmpty(n.TeamName, teamName)
+	if len(team) == 0 {
+		return errors.New("no team of the escalation room, use flag -t")
+	}
+	from := n.RoomName
+	if len(n.TeamName) == 0 {
+		from = n.Email
+	}
+	mentions := []string(escalateMention)
+	if len(mentions) == 0 {
+		mentions = n.Mentions
+	}
+	e := &notification{
+		TeamName: team,
+		RoomName: escalateRoom,
+		Markdown: strings.TrimSpace(trf("⏫ **not acknowledged within %s** in %s", escalateAfter, from) + "\n\n" + n.Markdown),
+		// the card still has its Acknowledge button
+		Card:     n.Card,
+		Mentions: mentions,
+		Profile:  n.Profile,
+	}
+	if err := sendNotification(ctx, e); err != nil {
+		return err
+	}
+	slog.Info("card escalated", "messageID", m.ID, "team", team, "room", escalateRoom)
+	return nil
+}
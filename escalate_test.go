@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestCheckEscalation(t *testing.T) {
+	defer func(after time.Duration, ack bool, room string) {
+		escalateAfter, ackButton, escalateRoom = after, ack, room
+	}(escalateAfter, ackButton, escalateRoom)
+	for _, tt := range []struct {
+		after   time.Duration
+		ack     bool
+		room    string
+		wantErr string
+	}{
+		{0, false, "", ""},
+		{15 * time.Minute, true, "Ops Managers", ""},
+		{-time.Minute, true, "Ops Managers", "--escalate-after must be positive"},
+		{15 * time.Minute, false, "Ops Managers", "--escalate-after needs --ack"},
+		{15 * time.Minute, true, "", "--escalate-after needs --escalate-room"},
+	} {
+		escalateAfter, ackButton, escalateRoom = tt.after, tt.ack, tt.room
+		if got := errorString(checkEscalation()); got != tt.wantErr {
+			t.Errorf("checkEscalation(%s, %v, %q) = %q, want %q", tt.after, tt.ack, tt.room, got, tt.wantErr)
+		}
+	}
+}
+
+func TestWatchEscalation(t *testing.T) {
+	fake := useFakeWebex(t)
+	team := fake.AddTeam("KMP-Team")
+	alerts := fake.AddRoom("Alerts", team, "group")
+	defer func(after time.Duration, room string, mention stringList) {
+		escalateAfter, escalateRoom, escalateMention = after, room, mention
+	}(escalateAfter, escalateRoom, escalateMention)
+	escalateAfter, escalateRoom, escalateMention = 10*time.Millisecond, "Ops Managers", nil
+
+	client, err := webexClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(markdown string) (*notification, *webex.Message) {
+		n := &notification{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: markdown, Ack: true}
+		if err := withAckButton(n); err != nil {
+			t.Fatal(err)
+		}
+		m, err := client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: alerts.ID, Markdown: n.Markdown,
+			Attachments: []json.RawMessage{json.RawMessage(n.Card)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n, m
+	}
+
+	// the first card is acknowledged in time, the second is escalated
+	n1, m1 := post("disk full on db-1")
+	n2, m2 := post("disk full on db-2")
+	if err := client.DeleteMessage(context.Background(), m1.ID); err != nil {
+		t.Fatal(err)
+	}
+	watchEscalation(n1, m1)
+	watchEscalation(n2, m2)
+	// no card, no escalation
+	watchEscalation(&notification{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "info"}, m2)
+	escalations.Wait()
+
+	var escalated []string
+	for _, m := range fake.Messages() {
+		if m.RoomID == alerts.ID {
+			continue
+		}
+		escalated = append(escalated, m.Markdown)
+		// the escalation keeps the Acknowledge button
+		if len(m.Attachments) != 1 || !hasAckButton(string(m.Attachments[0])) {
+			t.Errorf("escalation without Acknowledge button: %+v", m)
+		}
+	}
+	want := "⏫ **not acknowledged within 10ms** in Alerts\n\ndisk full on db-2"
+	if len(escalated) != 1 || escalated[0] != want {
+		t.Errorf("escalated = %q, want %q", escalated, want)
+	}
+	rooms := 0
+	for _, r := range fake.Rooms() {
+		if r.Title == "Ops Managers" && r.TeamID == team {
+			rooms++
+		}
+	}
+	if rooms != 1 {
+		t.Errorf("%d escalation rooms in KMP-Team, want 1", rooms)
+	}
+
+	// the escalation itself is not escalated again
+	e := *n2
+	e.RoomName = "Ops Managers"
+	watchEscalation(&e, m2)
+	escalations.Wait()
+	if got := len(fake.Messages()); got != 2 {
+		t.Errorf("%d messages after watching the escalation, want 2", got)
+	}
+}
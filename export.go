@@ -0,0 +1,192 @@
+// export.go
+//
+// Export of the message history of a room (command export) as JSON, CSV or
+// HTML archive, e.g. for project close-out documentation.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// roomArchive is the format of a JSON export.
+type roomArchive struct {
+	Room     *webex.Room      `json:"room"`
+	Exported time.Time        `json:"exported"`
+	Since    *time.Time       `json:"since,omitempty"`
+	Messages []*webex.Message `json:"messages"`
+}
+
+// parseSince parses the start of a time range given as a duration back from
+// now (e.g. 90m, 12h or 30d), a date (2006-01-02) or RFC3339. An empty string
+// returns the zero time.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if len(since) == 0 {
+		return time.Time{}, nil
+	}
+	if days, err := strconv.Atoi(strings.TrimSuffix(since, "d")); err == nil && strings.HasSuffix(since, "d") {
+		return now.AddDate(0, 0, -days), nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", since, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q. use a duration like 30d or 12h, 2006-01-02 or RFC3339", since)
+}
+
+// findRoom returns the room with the given title, in the team with the
+// given name if team is not empty. Unlike lookupRoomID the room is never
+// created.
+func findRoom(ctx context.Context, team, title string) (*webex.Room, error) {
+	client, err := webexClient()
+	if err != nil {
+		return nil, err
+	}
+	var teamID string
+	if len(team) > 0 {
+		teamID, err = client.TeamIDByName(ctx, team)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rooms, err := client.ListRooms(ctx, teamID, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := range rooms {
+		if rooms[i].Title == title {
+			return &rooms[i], nil
+		}
+	}
+	return nil, fmt.Errorf("room %q not found", title)
+}
+
+// roomMessages returns the messages of room created at or after since,
+// oldest first.
+func roomMessages(ctx context.Context, room *webex.Room, since time.Time) ([]*webex.Message, error) {
+	client, err := webexClient()
+	if err != nil {
+		return nil, err
+	}
+	messages := []*webex.Message{}
+	err = client.ListMessages(ctx, room.ID, time.Time{}, func(m *webex.Message) bool {
+		if m.Created.Before(since) {
+			return false
+		}
+		messages = append(messages, m)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+func runExport(output string) error {
+	if len(roomName) == 0 {
+		return errors.New("no room. use flag -r")
+	}
+	since, err := parseSince(sinceString, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// paging through a long history takes longer than a single message
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	room, err := findRoom(ctx, teamName, roomName)
+	if err != nil {
+		return err
+	}
+	messages, err := roomMessages(ctx, room, since)
+	if err != nil {
+		return err
+	}
+
+	archive := &roomArchive{Room: room, Exported: time.Now(), Messages: messages}
+	if !since.IsZero() {
+		archive.Since = &since
+	}
+
+	w := io.Writer(os.Stdout)
+	if len(output) > 0 && output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch strings.ToLower(filepath.Ext(output)) {
+	case ".csv":
+		err = writeArchiveCSV(w, archive)
+	case ".html", ".htm":
+		err = writeArchiveHTML(w, archive)
+	case ".json", "", "-":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(archive)
+	default:
+		return fmt.Errorf("unsupported export format %q. use .json, .csv or .html", filepath.Ext(output))
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("room exported", "room", room.Title, "messages", len(messages), "output", output)
+	return nil
+}
+
+func writeArchiveCSV(w io.Writer, archive *roomArchive) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "created", "personEmail", "parentId", "text", "markdown", "files"})
+	for _, m := range archive.Messages {
+		cw.Write([]string{m.ID, m.Created.Format(time.RFC3339), m.PersonEmail, m.ParentID, m.Text, m.Markdown, strings.Join(m.Files, " ")})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeArchiveHTML(w io.Writer, archive *roomArchive) error {
+	var out strings.Builder
+	out.WriteString(strings.Replace(previewHTMLHeader, "message preview", html.EscapeString(archive.Room.Title), 1))
+	fmt.Fprintf(&out, "<h1>%s</h1>\n<p>exported %s, %d messages</p>\n",
+		html.EscapeString(archive.Room.Title), archive.Exported.Format(time.RFC1123), len(archive.Messages))
+	for _, m := range archive.Messages {
+		text := m.Markdown
+		if len(text) == 0 {
+			text = m.Text
+		}
+		fmt.Fprintf(&out, "<div class=\"message\" id=\"%s\">\n<p class=\"meta\"><strong>%s</strong> %s</p>\n",
+			html.EscapeString(m.ID), html.EscapeString(m.PersonEmail), m.Created.Local().Format("2006-01-02 15:04"))
+		out.WriteString(renderMarkdown(text, htmlRenderer{}))
+		for _, f := range m.Files {
+			fmt.Fprintf(&out, "<p class=\"file\">%s</p>\n", htmlRenderer{}.link(html.EscapeString(f), f))
+		}
+		out.WriteString("</div>\n")
+	}
+	out.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, out.String())
+	return err
+}
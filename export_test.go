@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		since   string
+		want    time.Time
+		wantErr bool
+	}{
+		{since: ""},
+		{since: "30d", want: now.AddDate(0, 0, -30)},
+		{since: "90m", want: now.Add(-90 * time.Minute)},
+		{since: "2026-10-01", want: time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)},
+		{since: "2026-10-01T08:00:00Z", want: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)},
+		{since: "d", wantErr: true},
+		{since: "yesterday", wantErr: true},
+	} {
+		got, err := parseSince(tt.since, now)
+		if tt.wantErr != (err != nil) || !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, %v, want %v", tt.since, got, err, tt.want)
+		}
+	}
+}
+
+func TestHTMLLinks(t *testing.T) {
+	for _, tt := range []struct {
+		md, want string
+	}{
+		{"[docs](https://example.com/a?b=1&c=2)", `<a href="https://example.com/a?b=1&amp;c=2">docs</a>`},
+		{"[mail](mailto:ops@example.com)", `<a href="mailto:ops@example.com">mail</a>`},
+		{"[click](javascript:alert(1))", "click &lt;javascript:alert(1&gt;"},
+		{"[click](JavaScript:alert`1`)", "click &lt;JavaScript:alert`1`&gt;"},
+		{"[click](data:text/html;base64,PHNjcmlwdD4=)", "click &lt;data:text/html;base64,PHNjcmlwdD4=&gt;"},
+		{"[click](/relative)", "click &lt;/relative&gt;"},
+		{`[x](https://example.com/"onmouseover="alert)`, `<a href="https://example.com/&#34;onmouseover=&#34;alert">x</a>`},
+	} {
+		got := renderMarkdown(tt.md, htmlRenderer{})
+		if !strings.Contains(got, tt.want) || strings.Contains(got, `href="javascript`) {
+			t.Errorf("renderMarkdown(%q) = %q, want %q", tt.md, got, tt.want)
+		}
+	}
+}
+
+func TestWriteArchiveHTML(t *testing.T) {
+	archive := &roomArchive{
+		Room:     &webex.Room{Title: "Alerts <b>"},
+		Exported: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+		Messages: []*webex.Message{
+			{ID: "1", PersonEmail: "mallory@example.com", Markdown: "<script>alert(1)</script> [win](javascript:alert(1))", Files: []string{"javascript:alert(2)"}},
+			{ID: "2", PersonEmail: "john.smith@example.com", Text: "plain", Files: []string{"https://webexapis.com/v1/contents/1"}},
+		},
+	}
+	var out strings.Builder
+	if err := writeArchiveHTML(&out, archive); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, unsafe := range []string{"<script>", "<b>", `href="javascript`} {
+		if strings.Contains(got, unsafe) {
+			t.Errorf("writeArchiveHTML() contains %s:\n%s", unsafe, got)
+		}
+	}
+	if !strings.Contains(got, `<a href="https://webexapis.com/v1/contents/1">`) || !strings.Contains(got, "<p>plain</p>") {
+		t.Errorf("writeArchiveHTML() =\n%s", got)
+	}
+}
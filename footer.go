@@ -0,0 +1,76 @@
+// footer.go
+//
+// Context footer of the messages (flag --footer). When many cron jobs and
+// scripts share one bot, --footer auto tells where a message came from, with
+// the OS user, the host and the time of the invocation:
+//
+//	notify_by_webex_teams -t KMP-Team -r Alerts -m "backup failed" --footer auto
+//
+// adds "sent by backup@db-1 at 2026-10-15 02:00 CEST (notify_by_webex_teams
+// 1.88)" in italics to the message and as small text to the card. Any other
+// value is a template with .Hostname, .Time and .Vars as in the message
+// templates, e.g. --footer "{{.Hostname}} /etc/cron.d/backup".
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// footerUser returns the name of the OS user.
+func footerUser() string {
+	if u, err := user.Current(); err == nil && len(u.Username) > 0 {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); len(name) > 0 {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// footerText returns the text of --footer for n, or "" without footer.
+func footerText(n *notification) (string, error) {
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	switch footerMode {
+	case "", "none":
+		return "", nil
+	case "auto":
+		return trf("sent by %s at %s", footerUser()+"@"+hostname, formatTime(now)) + " (notify_by_webex_teams " + version + ")", nil
+	}
+	text, err := renderTemplate("footer", footerMode, &templateData{Vars: n.Vars, Time: now, Hostname: hostname})
+	return strings.TrimSpace(text), err
+}
+
+// applyFooter adds the footer of --footer to the message and the card of n.
+func applyFooter(n *notification) error {
+	text, err := footerText(n)
+	if err != nil || len(text) == 0 {
+		return err
+	}
+	if markdown := strings.TrimRight(n.Markdown, "\n"); len(markdown) > 0 {
+		n.Markdown = markdown + "\n\n*" + text + "*"
+	} else {
+		n.Markdown = "*" + text + "*"
+	}
+	if len(n.Card) == 0 {
+		return nil
+	}
+	attachment, content, err := decodeCard(n.Card)
+	if err != nil {
+		return err
+	}
+	body, _ := content["body"].([]interface{})
+	content["body"] = append(body, map[string]interface{}{
+		"type": "TextBlock", "text": text, "size": "Small", "isSubtle": true, "wrap": true, "separator": true,
+	})
+	data, err := json.Marshal(attachment)
+	if err != nil {
+		return err
+	}
+	n.Card, err = normalizeCard(string(data))
+	return err
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestApplyFooter(t *testing.T) {
+	defer func(mode string) { footerMode = mode }(footerMode)
+	hostname, _ := os.Hostname()
+	for _, tt := range []struct {
+		mode, markdown, card string
+		wantMarkdown         string
+		wantCard             string
+	}{
+		{mode: "", markdown: "backup failed", wantMarkdown: "backup failed"},
+		{mode: "none", markdown: "backup failed", wantMarkdown: "backup failed"},
+		{mode: "{{.Hostname}} {{.Vars.job}} ", markdown: "backup failed\n", wantMarkdown: "backup failed\n\n*" + hostname + " /etc/cron.d/backup*"},
+		{mode: "cron", markdown: "", wantMarkdown: "*cron*"},
+		{mode: "cron", markdown: "backup failed", card: testCard, wantMarkdown: "backup failed\n\n*cron*",
+			wantCard: `{"isSubtle":true,"separator":true,"size":"Small","text":"cron","type":"TextBlock","wrap":true}]`},
+	} {
+		footerMode = tt.mode
+		n := &notification{Markdown: tt.markdown, Card: tt.card, Vars: map[string]string{"job": "/etc/cron.d/backup"}}
+		if err := applyFooter(n); err != nil {
+			t.Fatalf("applyFooter() with %q: %v", tt.mode, err)
+		}
+		if n.Markdown != tt.wantMarkdown {
+			t.Errorf("applyFooter() with %q: markdown = %q, want %q", tt.mode, n.Markdown, tt.wantMarkdown)
+		}
+		if len(tt.wantCard) > 0 && !strings.Contains(n.Card, tt.wantCard) {
+			t.Errorf("applyFooter() with %q: card = %s, want %s", tt.mode, n.Card, tt.wantCard)
+		}
+	}
+
+	footerMode = "auto"
+	text, err := footerText(&notification{})
+	if want := "@" + hostname + " at "; err != nil || !strings.HasPrefix(text, "sent by ") || !strings.Contains(text, want) ||
+		!strings.HasSuffix(text, "(notify_by_webex_teams "+version+")") {
+		t.Errorf("footerText() with auto = %q, %v", text, err)
+	}
+	footerMode = "{{.Vars"
+	if _, err := footerText(&notification{}); err == nil {
+		t.Errorf("footerText() with an invalid template: error = nil")
+	}
+}
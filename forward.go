@@ -0,0 +1,145 @@
+// forward.go
+//
+// Card submissions to automation (serve --forward-url). Every
+// attachmentActions event received on /webhook is looked up and POSTed to
+// the callback URL, e.g. to start a Rundeck job or an AWX template with the
+// answer of a card. The payload is the submission as JSON, or the Go
+// text/template of --forward-template with .Action (.Action.Inputs,
+// .Action.PersonID, .Action.MessageID, .Action.RoomID) and .Event:
+//
+//	{"extra_vars": {"answer": {{json .Action.Inputs.answer}}, "message": {{json .Action.MessageID}}}}
+//
+// --forward-header adds headers, e.g. the API token of the endpoint. The
+// callback is called without the proxy of -p, the proxy environment
+// variables and NO_PROXY apply.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// timeout of a callback request
+const forwardTimeout = 30 * time.Second
+
+// forwardData is the data of the payload template.
+type forwardData struct {
+	Action *webex.AttachmentAction
+	Event  *webex.WebhookEvent
+}
+
+// forwarder posts the card submissions to the callback URL.
+type forwarder struct {
+	url      string
+	template *template.Template
+	header   http.Header
+	client   *http.Client
+	webex    *webex.Client
+	// callbacks in progress, waited for on shutdown
+	running sync.WaitGroup
+}
+
+// forward is the forwarder of serve --forward-url, nil without.
+var forward *forwarder
+
+// newForwarder returns the forwarder of the flags.
+func newForwarder() (*forwarder, error) {
+	f := &forwarder{url: forwardURL, header: make(http.Header)}
+	if len(forwardTemplate) > 0 {
+		text, err := os.ReadFile(forwardTemplate)
+		if err != nil {
+			return nil, err
+		}
+		f.template, err = template.New("forward").Funcs(templateFuncs).Option("missingkey=zero").Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("--forward-template: %w", err)
+		}
+	}
+	for _, h := range forwardHeaders {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || len(strings.TrimSpace(name)) == 0 {
+			return nil, fmt.Errorf("invalid --forward-header %q. use \"Name: value\"", h)
+		}
+		f.header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		addSecret(strings.TrimSpace(value))
+	}
+	client, err := newHTTPClient("")
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = forwardTimeout
+	f.client = client
+	if f.webex, err = webexClient(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// handle forwards the submission of an attachmentActions webhook event.
+func (f *forwarder) handle(ev *webex.WebhookEvent) {
+	var data struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(ev.Data, &data); err != nil || len(data.ID) == 0 {
+		slog.Warn("forward: invalid attachmentActions event", "error", err)
+		return
+	}
+	f.running.Add(1)
+	go func() {
+		defer f.running.Done()
+		if err := f.post(data.ID, ev); err != nil {
+			slog.Error("forwarding the card submission failed", "actionID", data.ID, "url", f.url, "error", err)
+		}
+	}()
+}
+
+// post looks up the submission and posts it to the callback URL.
+func (f *forwarder) post(actionID string, ev *webex.WebhookEvent) error {
+	ctx, cancel := requestContext()
+	action, err := f.webex.GetAttachmentAction(ctx, actionID)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	var payload bytes.Buffer
+	if f.template != nil {
+		err = f.template.Execute(&payload, &forwardData{Action: action, Event: ev})
+	} else {
+		err = json.NewEncoder(&payload).Encode(action)
+	}
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", f.url, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header = f.header.Clone()
+	if len(req.Header.Get("Content-Type")) == 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	slog.Info("card submission forwarded", "actionID", actionID, "messageID", action.MessageID, "status", resp.StatusCode)
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestNewForwarder(t *testing.T) {
+	useFakeWebex(t)
+	defer func(tmpl string, headers stringList) { forwardTemplate, forwardHeaders = tmpl, headers }(forwardTemplate, forwardHeaders)
+	broken := filepath.Join(t.TempDir(), "broken.tmpl")
+	if err := os.WriteFile(broken, []byte(`{"answer": {{.Action.Inputs.answer}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		template string
+		headers  stringList
+		wantErr  string
+	}{
+		{headers: stringList{"Authorization: Bearer awx-token-4711", "X-Source:notify"}},
+		{headers: stringList{"Bearer awx-token-4711"}, wantErr: `invalid --forward-header "Bearer awx-token-4711". use "Name: value"`},
+		{headers: stringList{": value"}, wantErr: `invalid --forward-header ": value". use "Name: value"`},
+		{template: broken, wantErr: "--forward-template: template: forward:1: "},
+		{template: filepath.Join(t.TempDir(), "missing.tmpl"), wantErr: "open "},
+	} {
+		forwardTemplate, forwardHeaders = tt.template, tt.headers
+		f, err := newForwarder()
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("newForwarder(%q, %q) error = %v, want %s", tt.template, tt.headers, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || f.header.Get("Authorization") != "Bearer awx-token-4711" || f.header.Get("X-Source") != "notify" {
+			t.Errorf("newForwarder(%q, %q) = %v, header %v", tt.template, tt.headers, err, f.header)
+		}
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	fake := useFakeWebex(t)
+	room := fake.AddRoom("Changes", "", "group")
+	fake.AddPerson("alice@example.com", "Alice")
+	client, err := webexClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	type callback struct {
+		auth, contentType, body string
+	}
+	callbacks := make(chan callback, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		callbacks <- callback{r.Header.Get("Authorization"), r.Header.Get("Content-Type"), string(body)}
+		if strings.Contains(string(body), "reject") {
+			http.Error(w, "job template not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	msg := fake.AddMessage(room.ID, "notify-bot@webex.bot", "approve the change?", time.Now())
+	action := fake.AddAttachmentAction(msg.ID, "alice@example.com", map[string]interface{}{"answer": "approve"})
+	event := func(id string) *webex.WebhookEvent {
+		return &webex.WebhookEvent{Resource: "attachmentActions", Event: "created", Data: json.RawMessage(`{"id":"` + id + `"}`)}
+	}
+
+	header := make(http.Header)
+	header.Set("Authorization", "Bearer awx-token-4711")
+	f := &forwarder{url: srv.URL, header: header, client: srv.Client(), webex: client}
+	f.handle(event(action.ID))
+	f.running.Wait()
+	got := <-callbacks
+	var forwarded webex.AttachmentAction
+	if err := json.Unmarshal([]byte(got.body), &forwarded); err != nil || forwarded.ID != action.ID || forwarded.Inputs["answer"] != "approve" ||
+		got.auth != "Bearer awx-token-4711" || got.contentType != "application/json" {
+		t.Errorf("callback = %+v, %v, want the submission as JSON", got, err)
+	}
+
+	tmpl := filepath.Join(t.TempDir(), "awx.tmpl")
+	if err := os.WriteFile(tmpl, []byte(`{"extra_vars": {"answer": {{json .Action.Inputs.answer}}, "room": {{json .Action.RoomID}}, "event": {{json .Event.Event}}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(tmpl string, url string) { forwardTemplate, forwardURL = tmpl, url }(forwardTemplate, forwardURL)
+	forwardTemplate, forwardURL = tmpl, srv.URL
+	if f, err = newForwarder(); err != nil {
+		t.Fatal(err)
+	}
+	f.handle(event(action.ID))
+	f.running.Wait()
+	if got := <-callbacks; got.body != `{"extra_vars": {"answer": "approve", "room": "`+room.ID+`", "event": "created"}}` {
+		t.Errorf("callback of the template = %s", got.body)
+	}
+
+	reject := fake.AddAttachmentAction(msg.ID, "alice@example.com", map[string]interface{}{"answer": "reject"})
+	if err := f.post(reject.ID, event(reject.ID)); err == nil || err.Error() != "404 Not Found: job template not found" {
+		t.Errorf("post() of a rejected callback error = %v", err)
+	}
+	<-callbacks
+	if err := f.post("action-missing", event("action-missing")); err == nil || len(callbacks) > 0 {
+		t.Errorf("post() of an unknown submission = %v, %d callbacks", err, len(callbacks))
+	}
+	// invalid events are ignored
+	f.handle(&webex.WebhookEvent{Data: json.RawMessage(`{}`)})
+	f.running.Wait()
+	if len(callbacks) > 0 {
+		t.Errorf("%d callbacks of an invalid event", len(callbacks))
+	}
+}
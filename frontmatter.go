@@ -0,0 +1,211 @@
+// frontmatter.go
+//
+// Front matter of message files (-i, --message-file). A message may start
+// with a YAML block between "---" lines with the options of the message, so
+// one file describes the whole notification:
+//
+//	---
+//	team: KMP-Team
+//	room: Alerts
+//	severity: critical
+//	mentions: [oncall@example.com]
+//	files: [report.pdf, graph.png]
+//	card: card.yaml
+//	---
+//	**disk full** on srv1
+//
+// The keys are team, room, email, severity, mentions, files and card (a
+// card file or the JSON of a card). Flags given on the command line take
+// precedence, files and mentions are added to those of the flags. Relative
+// paths are relative to the message file.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var frontMatterKeys = []string{"team", "room", "email", "severity", "mentions", "files", "card"}
+
+// readMessageFile reads the message of --message-file ("-" is standard
+// input).
+func readMessageFile(file string) error {
+	if file == "-" {
+		if useStdIn {
+			return errors.New("--message-file - and -i both read standard input")
+		}
+		text, err := readMessage(os.Stdin, keepCRLF)
+		if err != nil {
+			return err
+		}
+		return addMessageInput(text, ".")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	text, err := readMessage(f, keepCRLF)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return addMessageInput(expandEnv(text, nil), filepath.Dir(file))
+}
+
+// addMessageInput adds the message read by -i or --message-file. Its front
+// matter is applied, or with --multi it is split into messages, see
+// multidoc.go.
+func addMessageInput(text, dir string) error {
+	if multiDoc {
+		docs, err := splitDocuments(text, dir)
+		messageDocs = append(messageDocs, docs...)
+		return err
+	}
+	body, err := applyFrontMatter(text, dir)
+	markdownMsg += body
+	return err
+}
+
+// frontMatter holds the options of a front matter.
+type frontMatter struct {
+	given                       map[string]bool
+	team, room, email, severity string
+	mentions, files             []string
+	card, cardFile              string
+}
+
+// applyFrontMatter sets the options of the front matter of text, if any,
+// and returns the message without it. dir is the directory of relative
+// paths.
+func applyFrontMatter(text, dir string) (string, error) {
+	if !strings.HasPrefix(text, "---\n") && !strings.HasPrefix(text, "---\r\n") {
+		return text, nil
+	}
+	_, rest, _ := strings.Cut(text, "\n")
+	var header, body string
+	found := false
+	for offset := 0; offset < len(rest); {
+		line, _, _ := strings.Cut(rest[offset:], "\n")
+		if trimmed := strings.TrimRight(line, "\r"); trimmed == "---" || trimmed == "..." {
+			header = rest[:offset]
+			body = strings.TrimPrefix(rest[offset+len(line):], "\n")
+			found = true
+			break
+		}
+		offset += len(line) + 1
+	}
+	if !found {
+		// a message starting with a horizontal rule
+		return text, nil
+	}
+
+	doc, err := parseYAML(header)
+	if err != nil {
+		return "", fmt.Errorf("front matter: %w", err)
+	}
+	if doc == nil {
+		return body, nil
+	}
+	m, ok := doc.(yamlMapping)
+	if !ok {
+		// e.g. a text between two horizontal rules
+		return text, nil
+	}
+	if err := checkYAMLKeys("front matter", m, frontMatterKeys); err != nil {
+		return "", err
+	}
+	fm, err := newFrontMatter(m, dir)
+	if err != nil {
+		return "", err
+	}
+	fm.apply()
+	return body, nil
+}
+
+// newFrontMatter returns the options of the front matter m.
+func newFrontMatter(m yamlMapping, dir string) (*frontMatter, error) {
+	path := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	fm := &frontMatter{given: make(map[string]bool)}
+	for _, p := range m {
+		fm.given[p.Key] = true
+		switch p.Key {
+		case "team":
+			fm.team = yamlString(p.Value)
+		case "room":
+			fm.room = yamlString(p.Value)
+		case "email":
+			fm.email = yamlString(p.Value)
+		case "severity":
+			fm.severity = yamlString(p.Value)
+		case "mentions", "files":
+			values, err := yamlStrings(p.Value)
+			if err != nil {
+				return nil, fmt.Errorf("front matter: %s: %w", p.Key, err)
+			}
+			if p.Key == "files" {
+				for _, v := range values {
+					fm.files = append(fm.files, path(v))
+				}
+			} else {
+				fm.mentions = values
+			}
+		case "card":
+			card := strings.TrimSpace(yamlString(p.Value))
+			if strings.HasPrefix(card, "{") {
+				fm.card = card
+			} else if len(card) > 0 {
+				fm.cardFile = path(card)
+			}
+		}
+	}
+	return fm, nil
+}
+
+// apply sets the options of the front matter which are not given as flags.
+func (fm *frontMatter) apply() {
+	set := func(key, flagName string, v *string, value string) {
+		if fm.given[key] && !flagGiven(flagName) {
+			*v = value
+		}
+	}
+	set("team", "t", &teamName, fm.team)
+	set("room", "r", &roomName, fm.room)
+	set("email", "D", &emailAddr, fm.email)
+	set("severity", "severity", &severityName, fm.severity)
+	uploadFiles = append(uploadFiles, fm.files...)
+	mentionEmails = append(mentionEmails, fm.mentions...)
+	if fm.given["card"] && !flagGiven("a", "A") {
+		cardAttachment, cardFile = fm.card, fm.cardFile
+	}
+}
+
+// yamlStrings returns a scalar or a sequence of scalars as strings.
+func yamlStrings(v interface{}) ([]string, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		if _, isMapping := v.(yamlMapping); isMapping {
+			return nil, errors.New("not a list")
+		}
+		if v == nil {
+			return nil, nil
+		}
+		return []string{yamlString(v)}, nil
+	}
+	var values []string
+	for _, item := range items {
+		switch item.(type) {
+		case []interface{}, yamlMapping:
+			return nil, errors.New("not a list of strings")
+		}
+		values = append(values, yamlString(item))
+	}
+	return values, nil
+}
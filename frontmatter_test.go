@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyFrontMatter(t *testing.T) {
+	reset := func() {
+		teamName, roomName, emailAddr, severityName = "", "", "", ""
+		uploadFiles, mentionEmails, cardAttachment, cardFile = nil, nil, "", ""
+	}
+	defer reset()
+	dir := filepath.Join("etc", "notify")
+
+	for _, tt := range []struct {
+		name, text, wantBody string
+		wantTeam, wantRoom   string
+		wantSeverity         string
+		wantFiles            []string
+		wantMentions         []string
+		wantCard             string
+		wantCardFile         string
+		wantErr              string
+	}{
+		{name: "no front matter", text: "**disk full**\n", wantBody: "**disk full**\n"},
+		{name: "all keys",
+			text:     "---\nteam: KMP-Team\nroom: Alerts\nseverity: critical\nmentions: [oncall@example.com]\nfiles: [report.pdf, /tmp/graph.png]\ncard: card.yaml\n---\n**disk full** on srv1\n",
+			wantBody: "**disk full** on srv1\n", wantTeam: "KMP-Team", wantRoom: "Alerts", wantSeverity: "critical",
+			wantFiles: []string{filepath.Join(dir, "report.pdf"), "/tmp/graph.png"}, wantMentions: []string{"oncall@example.com"},
+			wantCardFile: filepath.Join(dir, "card.yaml")},
+		{name: "CRLF and card JSON", text: "---\r\nmentions: alice@example.com\r\ncard: '{\"type\":\"AdaptiveCard\"}'\r\n...\r\nhi",
+			wantBody: "hi", wantMentions: []string{"alice@example.com"}, wantCard: `{"type":"AdaptiveCard"}`},
+		{name: "horizontal rule", text: "---\nno end of the front matter\n", wantBody: "---\nno end of the front matter\n"},
+		{name: "text between rules", text: "---\njust text\n---\nmore\n", wantBody: "---\njust text\n---\nmore\n"},
+		{name: "empty", text: "---\n---\nhi\n", wantBody: "hi\n"},
+		{name: "unknown key", text: "---\nteam: KMP-Team\ntitle: x\n---\nhi\n", wantErr: "title"},
+		{name: "mapping as list", text: "---\nfiles: {a: b}\n---\nhi\n", wantErr: "files: not a list"},
+	} {
+		reset()
+		body, err := applyFrontMatter(tt.text, dir)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: applyFrontMatter() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || body != tt.wantBody {
+			t.Errorf("%s: applyFrontMatter() = %q, %v, want %q", tt.name, body, err, tt.wantBody)
+		}
+		if teamName != tt.wantTeam || roomName != tt.wantRoom || severityName != tt.wantSeverity {
+			t.Errorf("%s: team %q, room %q, severity %q, want %q, %q, %q", tt.name, teamName, roomName, severityName, tt.wantTeam, tt.wantRoom, tt.wantSeverity)
+		}
+		if !reflect.DeepEqual([]string(uploadFiles), tt.wantFiles) || !reflect.DeepEqual([]string(mentionEmails), tt.wantMentions) {
+			t.Errorf("%s: files %q, mentions %q, want %q, %q", tt.name, uploadFiles, mentionEmails, tt.wantFiles, tt.wantMentions)
+		}
+		if cardAttachment != tt.wantCard || cardFile != tt.wantCardFile {
+			t.Errorf("%s: card %q, card file %q, want %q, %q", tt.name, cardAttachment, cardFile, tt.wantCard, tt.wantCardFile)
+		}
+	}
+}
+
+func TestYAMLStrings(t *testing.T) {
+	for _, tt := range []struct {
+		v       interface{}
+		want    []string
+		wantErr bool
+	}{
+		{nil, nil, false},
+		{"a@example.com", []string{"a@example.com"}, false},
+		{[]interface{}{"a", "b"}, []string{"a", "b"}, false},
+		{yamlMapping{}, nil, true},
+		{[]interface{}{"a", []interface{}{"b"}}, nil, true},
+	} {
+		got, err := yamlStrings(tt.v)
+		if tt.wantErr != (err != nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("yamlStrings(%#v) = %q, %v, want %q", tt.v, got, err, tt.want)
+		}
+	}
+}
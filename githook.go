@@ -0,0 +1,158 @@
+// githook.go
+//
+// Push notifications (flag --git-push) for repositories without a forge.
+// As post-receive hook, notify_by_webex_teams reads the "<old> <new> <ref>"
+// lines of standard input, looks up the pushed commits with git and sends
+// one message for the push:
+//
+//	#!/bin/sh
+//	exec notify_by_webex_teams -T "$(cat /etc/webex-token)" -t KMP-Team -r Commits --git-push
+//
+// --commit-url links the commit hashes, e.g.
+// --commit-url https://cgit.example.com/app/commit/?id= (the hash is
+// appended).
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// commits listed per ref
+const gitPushMaxCommits = 20
+
+// gitZeroHash is the old hash of a created and the new hash of a deleted ref
+// (SHA-1 or SHA-256).
+func gitZeroHash(hash string) bool {
+	return len(strings.Trim(hash, "0")) == 0
+}
+
+// appendGitPush appends the push message of the hook input r to the message.
+func appendGitPush(r io.Reader) error {
+	if useStdIn {
+		return errors.New("--git-push and -i both read standard input")
+	}
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		text, err := gitRefUpdate(fields[0], fields[1], fields[2])
+		if err != nil {
+			return err
+		}
+		lines = append(lines, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return errors.New("no ref updates on standard input. --git-push is meant for the post-receive hook")
+	}
+
+	pusher := os.Getenv("GL_USERNAME")
+	if len(pusher) == 0 {
+		pusher = os.Getenv("USER")
+	}
+	header := fmt.Sprintf("**%s** pushed to **%s**", pusher, gitRepoName())
+	if len(markdownMsg) > 0 && !strings.HasSuffix(markdownMsg, "\n") {
+		markdownMsg += "\n"
+	}
+	markdownMsg += header + "\n" + strings.Join(lines, "\n")
+	return nil
+}
+
+// gitRefUpdate returns the markdown of one ref update.
+func gitRefUpdate(oldHash, newHash, ref string) (string, error) {
+	kind, name := "branch", strings.TrimPrefix(ref, "refs/heads/")
+	if strings.HasPrefix(ref, "refs/tags/") {
+		kind, name = "tag", strings.TrimPrefix(ref, "refs/tags/")
+	} else if name == ref {
+		kind = "ref"
+	}
+
+	switch {
+	case gitZeroHash(newHash):
+		return fmt.Sprintf("🗑️ deleted %s `%s`", kind, name), nil
+	case kind == "tag":
+		commit, err := gitLog("-n", "1", newHash)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("🏷️ tag `%s` %s", name, strings.TrimPrefix(strings.Join(commit, ""), "- ")), nil
+	}
+
+	args := []string{oldHash + ".." + newHash}
+	verb := "to"
+	if gitZeroHash(oldHash) {
+		// the commits of a new ref which are not on any other ref
+		args = []string{newHash, "--not", "--exclude=" + ref, "--all"}
+		verb = "on new"
+	}
+	commits, err := gitLog(append([]string{"-n", fmt.Sprint(gitPushMaxCommits + 1)}, args...)...)
+	if err != nil {
+		return "", err
+	}
+	short := newHash[:min(len(newHash), 7)]
+	if len(commits) == 0 {
+		if gitZeroHash(oldHash) {
+			return fmt.Sprintf("🌱 new %s `%s` at `%s`", kind, name, short), nil
+		}
+		return fmt.Sprintf("⏪ %s `%s` reset to `%s`", kind, name, short), nil
+	}
+	count, more := fmt.Sprint(len(commits)), ""
+	if len(commits) > gitPushMaxCommits {
+		commits = commits[:gitPushMaxCommits]
+		count, more = fmt.Sprintf("more than %d", gitPushMaxCommits), "\n- …"
+	}
+	noun := "commits"
+	if len(commits) == 1 {
+		noun = "commit"
+	}
+	head := fmt.Sprintf("%s %s %s %s `%s`", count, noun, verb, kind, name)
+	return head + "\n" + strings.Join(commits, "\n") + more, nil
+}
+
+// gitLog returns the commits of "git log args" as markdown list items.
+func gitLog(args ...string) ([]string, error) {
+	cmd := exec.Command("git", append([]string{"log", "--format=%H%x00%an%x00%s"}, args...)...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	var commits []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		hash := "`" + fields[0][:7] + "`"
+		if len(commitURL) > 0 {
+			hash = "[" + hash + "](" + commitURL + fields[0] + ")"
+		}
+		commits = append(commits, fmt.Sprintf("- %s %s (%s)", hash, fields[2], fields[1]))
+	}
+	return commits, nil
+}
+
+// gitRepoName returns the name of the repository, e.g. app of /srv/git/app.git.
+func gitRepoName() string {
+	out, err := exec.Command("git", "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return "repository"
+	}
+	dir := filepath.Clean(strings.TrimSpace(string(out)))
+	if filepath.Base(dir) == ".git" {
+		dir = filepath.Dir(dir)
+	}
+	return strings.TrimSuffix(filepath.Base(dir), ".git")
+}
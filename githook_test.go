@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitZeroHash(t *testing.T) {
+	for _, tt := range []struct {
+		hash string
+		want bool
+	}{
+		{"0000000000000000000000000000000000000000", true},
+		{strings.Repeat("0", 64), true},
+		{"a94a8fe5ccb19ba61c4c0873d391e987982fbbd3", false},
+	} {
+		if got := gitZeroHash(tt.hash); got != tt.want {
+			t.Errorf("gitZeroHash(%q) = %v, want %v", tt.hash, got, tt.want)
+		}
+	}
+}
+
+func TestGitRefUpdate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("no git")
+	}
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	for name, value := range map[string]string{"GIT_CONFIG_GLOBAL": os.DevNull, "GIT_CONFIG_NOSYSTEM": "1",
+		"GIT_AUTHOR_NAME": "Alice", "GIT_AUTHOR_EMAIL": "alice@example.com", "GIT_COMMITTER_NAME": "Alice", "GIT_COMMITTER_EMAIL": "alice@example.com"} {
+		t.Setenv(name, value)
+	}
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", args...).Output()
+		if err != nil {
+			t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "-q", "-b", "main")
+	git("commit", "-q", "--allow-empty", "-m", "initial")
+	first := git("rev-parse", "HEAD")
+	git("commit", "-q", "--allow-empty", "-m", "fix login")
+	git("commit", "-q", "--allow-empty", "-m", "add logout")
+	head := git("rev-parse", "HEAD")
+	git("tag", "v1.0")
+	git("branch", "feature", first)
+	zero := strings.Repeat("0", 40)
+
+	defer func(url string) { commitURL = url }(commitURL)
+	commitURL = ""
+	for _, tt := range []struct {
+		oldHash, newHash, ref string
+		want                  string
+	}{
+		{first, head, "refs/heads/main", "2 commits to branch `main`\n- `" + head[:7] + "` add logout (Alice)\n- `"},
+		{head, first, "refs/heads/main", "⏪ branch `main` reset to `" + first[:7] + "`"},
+		{zero, first, "refs/heads/feature", "🌱 new branch `feature` at `" + first[:7] + "`"},
+		{zero, head, "refs/tags/v1.0", "🏷️ tag `v1.0` `" + head[:7] + "` add logout (Alice)"},
+		{head, zero, "refs/heads/old", "🗑️ deleted branch `old`"},
+		{head, zero, "refs/notes/commits", "🗑️ deleted ref `refs/notes/commits`"},
+	} {
+		got, err := gitRefUpdate(tt.oldHash, tt.newHash, tt.ref)
+		if err != nil || !strings.HasPrefix(got, tt.want) {
+			t.Errorf("gitRefUpdate(%s) = %q, %v, want %q", tt.ref, got, err, tt.want)
+		}
+	}
+
+	commitURL = "https://cgit.example.com/app/commit/?id="
+	if got, _ := gitRefUpdate(first, head, "refs/heads/main"); !strings.Contains(got, "[`"+head[:7]+"`](https://cgit.example.com/app/commit/?id="+head+")") {
+		t.Errorf("gitRefUpdate() with --commit-url = %q", got)
+	}
+	if got := gitRepoName(); got != filepath.Base(dir) {
+		t.Errorf("gitRepoName() = %q, want %q", got, filepath.Base(dir))
+	}
+}
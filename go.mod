@@ -0,0 +1,3 @@
+module github.com/hgrimm/notify_by_webex_teams
+
+go 1.21
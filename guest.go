@@ -0,0 +1,136 @@
+// guest.go
+//
+// Guest issuer support. A guest issuer ID and secret are used to mint a JWT
+// for a guest persona, which is exchanged for a Webex access token. Without
+// --guest-subject every invocation acts as a new, throwaway guest.
+//
+// doc links:
+//
+//	https://developer.webex.com/docs/guest-issuer
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// path of the API URL (--api-url)
+	guestLoginPath = "/jwt/login"
+
+	guestJWTLifetime = time.Hour
+)
+
+type guestToken struct {
+	AccessToken string
+	ExpiresAt   time.Time
+}
+
+var guestCurrent *guestToken
+
+// guestAccessToken returns a valid access token of the guest persona,
+// minting and exchanging a new JWT if necessary. The caller holds tokenMutex.
+func guestAccessToken() (string, error) {
+	if guestCurrent != nil && time.Until(guestCurrent.ExpiresAt) > time.Minute {
+		return guestCurrent.AccessToken, nil
+	}
+	if len(guestSecret) == 0 {
+		return "", errors.New("no guest issuer secret. use flag --guest-secret")
+	}
+
+	if len(guestSubject) == 0 {
+		id := make([]byte, 8)
+		_, err := rand.Read(id)
+		if err != nil {
+			return "", err
+		}
+		guestSubject = "notify-" + hex.EncodeToString(id)
+	}
+
+	jwt, err := mintGuestJWT(guestIssuerID, guestSecret, guestSubject, guestName, time.Now().Add(guestJWTLifetime))
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", apiURL(guestLoginPath), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", jwt))
+
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	slog.Debug("guest JWT login", "status", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("guest JWT login failed. HTTP status code: %d: %s", resp.StatusCode, body)
+	}
+
+	var login struct {
+		Token     string `json:"token"`
+		ExpiresIn int64  `json:"expiresIn"`
+	}
+	err = json.Unmarshal(body, &login)
+	if err != nil {
+		return "", err
+	}
+	if len(login.Token) == 0 {
+		return "", errors.New("guest JWT login returned no token")
+	}
+
+	addSecret(login.Token)
+	guestCurrent = &guestToken{
+		AccessToken: login.Token,
+		ExpiresAt:   time.Now().Add(time.Duration(login.ExpiresIn) * time.Second),
+	}
+	slog.Info("logged in as guest", "subject", guestSubject, "name", guestName)
+	return guestCurrent.AccessToken, nil
+}
+
+// mintGuestJWT returns a HS256 signed JWT for the guest persona. secret is the
+// base64 encoded guest issuer secret as shown by the developer portal.
+func mintGuestJWT(issuerID, secret, subject, name string, expires time.Time) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", fmt.Errorf("guest issuer secret is not base64 encoded: %v", err)
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"sub":  subject,
+		"name": name,
+		"iss":  issuerID,
+		"exp":  expires.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
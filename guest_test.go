@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMintGuestJWT(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("guest issuer secret"))
+	expires := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	jwt, err := mintGuestJWT("Y2lzY29zcGFyazovL3VzL09SR0FOSVpBVElPTi8x", secret, "notify-1", "Nagios", expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("mintGuestJWT() = %q, want header.claims.signature", jwt)
+	}
+
+	var header map[string]string
+	var claims map[string]interface{}
+	for i, v := range []interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			t.Fatalf("part %d of %q: %v", i, jwt, err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("part %d of %q: %v", i, jwt, err)
+		}
+	}
+	if header["alg"] != "HS256" || header["typ"] != "JWT" {
+		t.Errorf("header = %v, want HS256 JWT", header)
+	}
+	for claim, want := range map[string]interface{}{"sub": "notify-1", "name": "Nagios",
+		"iss": "Y2lzY29zcGFyazovL3VzL09SR0FOSVpBVElPTi8x", "exp": float64(expires.Unix())} {
+		if claims[claim] != want {
+			t.Errorf("claim %s = %v, want %v", claim, claims[claim], want)
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte("guest issuer secret"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)); parts[2] != want {
+		t.Errorf("signature = %s, want %s", parts[2], want)
+	}
+
+	if _, err := mintGuestJWT("issuer", "not base64!", "notify-1", "Nagios", expires); err == nil {
+		t.Errorf("mintGuestJWT() with a secret not base64 encoded: error = nil")
+	}
+}
+
+func TestGuestAccessToken(t *testing.T) {
+	var logins int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != guestLoginPath || !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ey") {
+			http.Error(w, `{"message":"invalid JWT"}`, http.StatusUnauthorized)
+			return
+		}
+		logins++
+		fmt.Fprintf(w, `{"token":"guest-%d","expiresIn":21600}`, logins)
+	}))
+	defer srv.Close()
+	apiBaseURL = srv.URL
+	defer func() {
+		apiBaseURL, guestIssuerID, guestSecret, guestSubject, guestCurrent = "", "", "", "", nil
+	}()
+
+	guestIssuerID, guestCurrent = "issuer", nil
+	if _, err := guestAccessToken(); err == nil || !strings.Contains(err.Error(), "--guest-secret") {
+		t.Errorf("guestAccessToken() without secret: error = %v", err)
+	}
+
+	guestSecret = base64.StdEncoding.EncodeToString([]byte("guest issuer secret"))
+	for i := 0; i < 2; i++ {
+		got, err := guestAccessToken()
+		if err != nil || got != "guest-1" {
+			t.Errorf("guestAccessToken() = %q, %v, want guest-1 from a single login", got, err)
+		}
+	}
+	if !strings.HasPrefix(guestSubject, "notify-") {
+		t.Errorf("generated guest subject = %q, want notify-<random>", guestSubject)
+	}
+
+	guestCurrent.ExpiresAt = time.Now().Add(30 * time.Second)
+	if got, err := guestAccessToken(); err != nil || got != "guest-2" {
+		t.Errorf("guestAccessToken() of an expiring token = %q, %v, want guest-2", got, err)
+	}
+}
@@ -0,0 +1,146 @@
+// handles.go
+//
+// Named message handles (flags --save-as, --edit-ref and --delete-ref). The
+// ID of a sent message is saved under a name chosen by the caller in a small
+// state file (--handles-file), so scripts can update or clean up an earlier
+// notification without keeping the message ID themselves:
+//
+//	notify_by_webex_teams -t KMP-Team -r Deployments -m "deploy 42 started" --save-as deploy-42
+//	notify_by_webex_teams -m "deploy 42 finished" --edit-ref deploy-42
+//	notify_by_webex_teams --delete-ref deploy-42
+//
+// Saving a name again replaces its message. Deleting the message by
+// --delete-ref also removes the handle.
+//
+// The file also keeps the thread of the last message the command line sent
+// to each room, for --reply-last: sequential steps of a pipeline form one
+// thread without passing the message ID on:
+//
+//	notify_by_webex_teams -t KMP-Team -r Deployments -m "build 42 passed"
+//	notify_by_webex_teams -t KMP-Team -r Deployments -m "deploy 42 finished" --reply-last
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// messageHandle is a message saved by --save-as.
+type messageHandle struct {
+	MessageID string    `json:"messageId"`
+	RoomID    string    `json:"roomId"`
+	Saved     time.Time `json:"saved"`
+}
+
+// handleState is the content of the handles file.
+type handleState struct {
+	// Names are the messages saved by --save-as
+	Names map[string]messageHandle `json:"names"`
+	// Last is the thread of the last message sent to each room, by room ID.
+	// Its MessageID is the parent message of the thread
+	Last map[string]messageHandle `json:"last"`
+}
+
+func defaultHandlesFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "notify_by_webex_teams", "handles.json")
+}
+
+// updateHandles applies update to the handles of the state file while
+// holding its lock. The file is only written if update returns true.
+func updateHandles(update func(state *handleState) (bool, error)) error {
+	unlock, err := lockStateFile(handlesFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state := &handleState{}
+	data, err := readStateFile(handlesFile)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			return fmt.Errorf("%s: %w", handlesFile, err)
+		}
+	}
+	if state.Names == nil {
+		state.Names = make(map[string]messageHandle)
+	}
+	if state.Last == nil {
+		state.Last = make(map[string]messageHandle)
+	}
+	changed, err := update(state)
+	if err != nil || !changed {
+		return err
+	}
+	data, err = json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStateFile(handlesFile, data)
+}
+
+// saveHandle saves the sent message m under name.
+func saveHandle(name string, m *webex.Message) error {
+	return updateHandles(func(state *handleState) (bool, error) {
+		state.Names[name] = messageHandle{MessageID: m.ID, RoomID: m.RoomID, Saved: time.Now()}
+		return true, nil
+	})
+}
+
+// lookupHandle returns the ID of the message saved under name.
+func lookupHandle(name string) (string, error) {
+	var id string
+	err := updateHandles(func(state *handleState) (bool, error) {
+		h, ok := state.Names[name]
+		if !ok {
+			return false, fmt.Errorf("no message saved as %q in %s", name, handlesFile)
+		}
+		id = h.MessageID
+		return false, nil
+	})
+	return id, err
+}
+
+// removeHandle removes the handle name.
+func removeHandle(name string) error {
+	return updateHandles(func(state *handleState) (bool, error) {
+		_, ok := state.Names[name]
+		delete(state.Names, name)
+		return ok, nil
+	})
+}
+
+// saveLastMessage keeps the thread of the sent message m as the last thread
+// of its room.
+func saveLastMessage(m *webex.Message) error {
+	parentID := m.ParentID
+	if len(parentID) == 0 {
+		parentID = m.ID
+	}
+	return updateHandles(func(state *handleState) (bool, error) {
+		state.Last[m.RoomID] = messageHandle{MessageID: parentID, RoomID: m.RoomID, Saved: time.Now()}
+		return true, nil
+	})
+}
+
+// lastThread returns the parent message of the thread of the last message
+// sent to the room, or "" if nothing was sent to it yet.
+func lastThread(roomID string) (string, error) {
+	var id string
+	err := updateHandles(func(state *handleState) (bool, error) {
+		id = state.Last[roomID].MessageID
+		return false, nil
+	})
+	return id, err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestMessageHandles(t *testing.T) {
+	defer func(file string) { handlesFile = file }(handlesFile)
+	handlesFile = filepath.Join(t.TempDir(), "handles.json")
+
+	if _, err := lookupHandle("deploy-42"); err == nil || !strings.Contains(err.Error(), `no message saved as "deploy-42"`) {
+		t.Errorf("lookupHandle() without state file: error = %v", err)
+	}
+	for _, id := range []string{"M1", "M2"} {
+		if err := saveHandle("deploy-42", &webex.Message{ID: id, RoomID: "R1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := saveHandle("deploy-43", &webex.Message{ID: "M3", RoomID: "R1"}); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := lookupHandle("deploy-42"); err != nil || id != "M2" {
+		t.Errorf("lookupHandle() = %q, %v, want the message saved last, M2", id, err)
+	}
+	if err := removeHandle("deploy-42"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lookupHandle("deploy-42"); err == nil {
+		t.Errorf("lookupHandle() of a removed handle: error = nil")
+	}
+	if id, err := lookupHandle("deploy-43"); err != nil || id != "M3" {
+		t.Errorf("lookupHandle() of the other handle = %q, %v, want M3", id, err)
+	}
+	if err := removeHandle("deploy-44"); err != nil {
+		t.Errorf("removeHandle() of an unknown handle = %v", err)
+	}
+}
+
+func TestSaveAsEditDeleteRef(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(file string) {
+		handlesFile, teamName, roomName, markdownMsg, saveAs, editRef, deleteRef = file, "", "", "", "", "", ""
+	}(handlesFile)
+	handlesFile = filepath.Join(t.TempDir(), "handles.json")
+
+	teamName, roomName, markdownMsg, saveAs = "KMP-Team", "Deployments", "deploy 42 started", "deploy-42"
+	if err := cmdSend("", nil); err != nil {
+		t.Fatal(err)
+	}
+	messages := fake.Messages()
+	if id, err := lookupHandle("deploy-42"); err != nil || len(messages) != 1 || id != messages[0].ID {
+		t.Fatalf("lookupHandle() after --save-as = %q, %v, want %s", id, err, messages[0].ID)
+	}
+
+	saveAs, editRef, markdownMsg = "", "deploy-42", "deploy 42 finished"
+	if err := cmdEdit("", nil); err != nil {
+		t.Fatal(err)
+	}
+	if messages := fake.Messages(); len(messages) != 1 || messages[0].Markdown != "deploy 42 finished" {
+		t.Errorf("messages after --edit-ref = %+v, want the edited message", messages)
+	}
+
+	editRef, deleteRef = "", "deploy-42"
+	if err := cmdDelete("", nil); err != nil {
+		t.Fatal(err)
+	}
+	if messages := fake.Messages(); len(messages) != 0 {
+		t.Errorf("messages after --delete-ref = %+v, want none", messages)
+	}
+	if _, err := lookupHandle("deploy-42"); err == nil {
+		t.Errorf("lookupHandle() after --delete-ref: error = nil")
+	}
+}
+
+func TestReplyLast(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(file string) {
+		handlesFile, teamName, roomName, markdownMsg, replyLast = file, "", "", "", false
+	}(handlesFile)
+	handlesFile = filepath.Join(t.TempDir(), "handles.json")
+	teamName = "KMP-Team"
+
+	for _, tt := range []struct {
+		room, markdown string
+		replyLast      bool
+	}{
+		{"Deployments", "build 42 passed", false},
+		{"Deployments", "deploy 42 started", true},
+		{"Deployments", "deploy 42 finished", true},
+		// nothing was sent to the room yet
+		{"Alerts", "disk full", true},
+		{"Alerts", "disk ok", true},
+	} {
+		roomName, markdownMsg, replyLast = tt.room, tt.markdown, tt.replyLast
+		if err := cmdSend("", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages := fake.Messages()
+	if len(messages) != 5 {
+		t.Fatalf("%d messages, want 5", len(messages))
+	}
+	for i, wantParent := range []string{"", messages[0].ID, messages[0].ID, "", messages[3].ID} {
+		if messages[i].ParentID != wantParent {
+			t.Errorf("parent of %q = %q, want %q", messages[i].Markdown, messages[i].ParentID, wantParent)
+		}
+	}
+	if id, err := lastThread(messages[0].RoomID); err != nil || id != messages[0].ID {
+		t.Errorf("lastThread() = %q, %v, want %s", id, err, messages[0].ID)
+	}
+	if id, err := lastThread("no-such-room"); err != nil || len(id) > 0 {
+		t.Errorf("lastThread() of a room without messages = %q, %v", id, err)
+	}
+}
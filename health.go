@@ -0,0 +1,81 @@
+// health.go
+//
+// Health and readiness endpoints of the HTTP relay (command serve) for
+// Kubernetes probes and load balancers. /healthz reports that the process
+// serves requests, /readyz that the Webex API is reachable and accepts the
+// token. The readiness check is cached, so frequent probes do not hit the
+// API rate limit.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+const (
+	readinessCacheTime = 30 * time.Second
+	readinessTimeout   = 10 * time.Second
+)
+
+var readiness struct {
+	sync.Mutex
+	checked time.Time
+	err     error
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := checkReadiness(r.Context()); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, redact(err.Error()))
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// checkReadiness checks that the circuit is closed, the Webex API is
+// reachable and the token is valid. The result of the API check is reused
+// for readinessCacheTime.
+func checkReadiness(ctx context.Context) error {
+	if err := checkCircuit(); err != nil {
+		return err
+	}
+	readiness.Lock()
+	defer readiness.Unlock()
+	if time.Since(readiness.checked) < readinessCacheTime {
+		return readiness.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	readiness.err = pingAPI(ctx)
+	readiness.checked = time.Now()
+	return readiness.err
+}
+
+func pingAPI(ctx context.Context) error {
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.Me(ctx)
+	var apiErr *webex.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("the token of %s is invalid or expired", tokenSource())
+	case err != nil:
+		return fmt.Errorf("Webex API not reachable: %v", err)
+	}
+	return nil
+}
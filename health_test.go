@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleHealthz(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleHealthz(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "ok\n" {
+		t.Errorf("/healthz = %d %q, want 200 ok", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleReadyz(t *testing.T) {
+	fake := useFakeWebex(t)
+	resetReadiness := func() {
+		readiness.Lock()
+		readiness.checked, readiness.err = time.Time{}, nil
+		readiness.Unlock()
+	}
+	resetReadiness()
+	defer resetReadiness()
+	readyz := func() (int, string) {
+		w := httptest.NewRecorder()
+		handleReadyz(w, httptest.NewRequest("GET", "/readyz", nil))
+		return w.Code, w.Body.String()
+	}
+
+	apiToken = "wrong-token"
+	if code, body := readyz(); code != http.StatusServiceUnavailable || body != "the token of flag -T is invalid or expired\n" {
+		t.Errorf("/readyz with a wrong token = %d %q", code, body)
+	}
+	// the result is cached
+	apiToken = "test-token"
+	if code, _ := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz within the cache time = %d, want 503", code)
+	}
+	resetReadiness()
+	if code, body := readyz(); code != http.StatusOK || body != "ok\n" {
+		t.Errorf("/readyz = %d %q, want 200 ok", code, body)
+	}
+
+	resetReadiness()
+	fake.Fail(10, http.StatusBadGateway)
+	if code, body := readyz(); code != http.StatusServiceUnavailable || !strings.HasPrefix(body, "Webex API not reachable: ") {
+		t.Errorf("/readyz of an unreachable API = %d %q", code, body)
+	}
+}
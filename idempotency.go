@@ -0,0 +1,126 @@
+// idempotency.go
+//
+// Idempotency keys (flag --idempotency-key, the field idempotencyKey of the
+// relay and spool JSON or the Idempotency-Key header of serve /send). A
+// workflow engine retrying a step sends the same key again, and the message
+// is only posted once per recipient:
+//
+//	notify_by_webex_teams -t KMP-Team -r Deployments -m "release 4.2 deployed" --idempotency-key "deploy-4.2-$RUN_ID"
+//
+// The keys are kept for --idempotency-window in a local state file
+// (--idempotency-file), like the hashes of --dedupe. With
+// --idempotency-check room the key is also added as a marker to the message
+// and the recent messages of the bot in the room are checked for it, so
+// retries on other hosts or containers are caught as well.
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// idempotencyMarker is the prefix of the key in the message.
+const idempotencyMarker = "idempotency-key: "
+
+// errIdempotent is returned by deliverNotification for a message whose
+// idempotency key was already sent.
+var errIdempotent = fmt.Errorf("%w: idempotency key already sent", errDuplicate)
+
+func defaultIdempotencyFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "notify_by_webex_teams", "idempotency.json")
+}
+
+// checkIdempotency checks the flags of the idempotency keys.
+func checkIdempotency() error {
+	switch idempotencyMode {
+	case "local", "room":
+	default:
+		return fmt.Errorf("invalid --idempotency-check %q. use local or room", idempotencyMode)
+	}
+	if idempotencyWin <= 0 {
+		return fmt.Errorf("invalid --idempotency-window %s", idempotencyWin)
+	}
+	return nil
+}
+
+// idempotencyStateKey returns the key of the state file for the
+// idempotency key and the recipient of n.
+func idempotencyStateKey(n *notification) string {
+	h := sha256.New()
+	for _, s := range []string{n.IdempotencyKey, n.Profile, n.TeamName, n.RoomName, n.Email} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return "key:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// claimIdempotencyKey records the idempotency key of n as sent and returns
+// errIdempotent if it was already sent within --idempotency-window. If
+// sending fails, releaseIdempotencyKey removes the claim.
+func claimIdempotencyKey(n *notification) error {
+	first, err := claimKey(idempotencyFile, idempotencyStateKey(n), idempotencyWin)
+	if err != nil {
+		return err
+	}
+	if !first {
+		return errIdempotent
+	}
+	return nil
+}
+
+func releaseIdempotencyKey(n *notification) error {
+	return releaseKey(idempotencyFile, idempotencyStateKey(n))
+}
+
+// withIdempotencyMarker appends the marker of the idempotency key to
+// markdown for --idempotency-check room.
+func withIdempotencyMarker(key, markdown string) string {
+	if len(key) == 0 || idempotencyMode != "room" {
+		return markdown
+	}
+	return strings.TrimRight(markdown, "\n") + "\n\n`" + idempotencyMarker + key + "`"
+}
+
+// checkRoomIdempotency returns errIdempotent if the bot sent a message with
+// the marker of key to the room within --idempotency-window.
+func checkRoomIdempotency(ctx context.Context, client *webex.Client, roomID, key string) error {
+	if len(key) == 0 || idempotencyMode != "room" || len(roomID) == 0 {
+		return nil
+	}
+	me, err := client.Me(ctx)
+	if err != nil {
+		return err
+	}
+	marker := idempotencyMarker + key
+	since := time.Now().Add(-idempotencyWin)
+	found := false
+	err = client.ListMessages(ctx, roomID, time.Time{}, func(m *webex.Message) bool {
+		if m.Created.Before(since) {
+			return false
+		}
+		if m.PersonID == me.ID && (strings.Contains(m.Markdown, marker) || strings.Contains(m.Text, marker)) {
+			found = true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("checking the idempotency key in the room: %w", err)
+	}
+	if found {
+		return errIdempotent
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex/webextest"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(file, mode string, win time.Duration) {
+		idempotencyFile, idempotencyMode, idempotencyWin = file, mode, win
+	}(idempotencyFile, idempotencyMode, idempotencyWin)
+	idempotencyFile = filepath.Join(t.TempDir(), "idempotency.json")
+	idempotencyWin = time.Hour
+
+	send := func(key, room string) {
+		t.Helper()
+		n := &notification{TeamName: "KMP-Team", RoomName: room, Markdown: "release 4.2 deployed", IdempotencyKey: key}
+		if err := sendNotification(context.Background(), n); err != nil {
+			t.Fatalf("sendNotification() with key %q to %s: %v", key, room, err)
+		}
+	}
+
+	idempotencyMode = "local"
+	send("deploy-1", "Deployments")
+	send("deploy-1", "Deployments")
+	if got := len(fake.Messages()); got != 1 {
+		t.Errorf("%d messages after a retry with the same key, want 1", got)
+	}
+	send("deploy-1", "Releases")
+	send("deploy-2", "Deployments")
+	if got := len(fake.Messages()); got != 3 {
+		t.Errorf("%d messages for another room and another key, want 3", got)
+	}
+
+	// a failed send releases the key for the retry
+	fake.Fail(1, http.StatusServiceUnavailable)
+	n := &notification{TeamName: "KMP-Team", RoomName: "Deployments", Markdown: "release 4.3 deployed", IdempotencyKey: "deploy-3"}
+	if err := sendNotification(context.Background(), n); err == nil {
+		t.Fatal("sendNotification() with failing API: err = nil")
+	}
+	send("deploy-3", "Deployments")
+	if got := len(fake.Messages()); got != 4 {
+		t.Errorf("%d messages after the retry of a failed send, want 4", got)
+	}
+
+	// the key was sent from another host: only the marker in the room is known
+	idempotencyMode = "room"
+	idempotencyFile = filepath.Join(t.TempDir(), "idempotency.json")
+	var roomID string
+	for _, r := range fake.Rooms() {
+		if r.Title == "Deployments" {
+			roomID = r.ID
+		}
+	}
+	fake.AddMessage(roomID, webextest.BotEmail, "release 4.4 deployed\n\n`idempotency-key: deploy-4`", time.Now().Add(-time.Minute))
+	fake.AddMessage(roomID, "alice@example.com", "`idempotency-key: deploy-5`", time.Now().Add(-time.Minute))
+	fake.AddMessage(roomID, webextest.BotEmail, "`idempotency-key: deploy-6`", time.Now().Add(-2*time.Hour))
+	before := len(fake.Messages())
+	send("deploy-4", "Deployments")
+	if got := len(fake.Messages()) - before; got != 0 {
+		t.Errorf("%d messages for a key with a marker of the bot in the room, want 0", got)
+	}
+	send("deploy-5", "Deployments")
+	send("deploy-6", "Deployments")
+	messages := fake.Messages()
+	if got := len(messages) - before; got != 2 {
+		t.Fatalf("%d messages for keys marked by another person or outside the window, want 2", got)
+	}
+	if want := "release 4.2 deployed\n\n`idempotency-key: deploy-6`"; messages[len(messages)-1].Markdown != want {
+		t.Errorf("markdown = %q, want %q", messages[len(messages)-1].Markdown, want)
+	}
+}
+
+func TestCheckIdempotency(t *testing.T) {
+	defer func(mode string, win time.Duration) { idempotencyMode, idempotencyWin = mode, win }(idempotencyMode, idempotencyWin)
+	for _, tt := range []struct {
+		mode    string
+		win     time.Duration
+		wantErr bool
+	}{
+		{"local", time.Hour, false},
+		{"room", time.Minute, false},
+		{"cluster", time.Hour, true},
+		{"local", 0, true},
+	} {
+		idempotencyMode, idempotencyWin = tt.mode, tt.win
+		if err := checkIdempotency(); tt.wantErr != (err != nil) {
+			t.Errorf("checkIdempotency() with %q and %s: error = %v", tt.mode, tt.win, err)
+		}
+	}
+}
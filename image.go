@@ -0,0 +1,169 @@
+// image.go
+//
+// Downscaling of images before the upload (flag --max-image-size).
+// Monitoring screenshots are often several MB and slow to upload from remote
+// sites. A PNG or JPEG file larger than the limit is recompressed, and
+// scaled down step by step until it fits, into a temporary file with the
+// same name, which is sent instead. The original file is not changed.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maximum number of encodings to fit an image into --max-image-size
+const shrinkAttempts = 8
+
+// byteSize is a flag value with an optional unit, e.g. 2MB or 500KB.
+type byteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+func (b *byteSize) String() string {
+	for _, u := range byteUnits[:3] {
+		if *b > 0 && int64(*b)%u.size == 0 {
+			return strconv.FormatInt(int64(*b)/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(value string) error {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	unit := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return fmt.Errorf("invalid size %q. use e.g. 2MB or 500KB", value)
+	}
+	*b = byteSize(f * float64(unit))
+	return nil
+}
+
+// shrinkImage returns file, or a downscaled copy if file is a PNG or JPEG
+// image larger than limit, and the function removing the copy.
+func shrinkImage(file string, limit int64) (string, func(), error) {
+	noop := func() {}
+	ext := strings.ToLower(filepath.Ext(file))
+	if limit <= 0 || (ext != ".png" && ext != ".jpg" && ext != ".jpeg") {
+		return file, noop, nil
+	}
+	fi, err := os.Stat(file)
+	if err != nil || fi.Size() <= limit {
+		return file, noop, err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", noop, err
+	}
+	src, format, err := image.Decode(f)
+	f.Close()
+	if err != nil {
+		return "", noop, fmt.Errorf("%s: %v", file, err)
+	}
+
+	// the first attempt only recompresses
+	img := src
+	var data []byte
+	for i := 0; i < shrinkAttempts; i++ {
+		data, err = encodeImage(img, format)
+		if err != nil {
+			return "", noop, err
+		}
+		if int64(len(data)) <= limit || i == shrinkAttempts-1 {
+			break
+		}
+		scale := math.Min(0.9, math.Sqrt(float64(limit)/float64(len(data))))
+		b := img.Bounds()
+		img = scaleImage(src, int(float64(b.Dx())*scale), int(float64(b.Dy())*scale))
+	}
+	if int64(len(data)) > limit {
+		slog.Warn("image still larger than --max-image-size", "file", file, "bytes", len(data))
+	}
+
+	dir, err := os.MkdirTemp("", "notify_by_webex_teams")
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	shrunk := filepath.Join(dir, filepath.Base(file))
+	err = os.WriteFile(shrunk, data, 0600)
+	if err != nil {
+		cleanup()
+		return "", noop, err
+	}
+	b := img.Bounds()
+	slog.Info("image downscaled", "file", file, "bytes", fi.Size(), "newBytes", len(data), "width", b.Dx(), "height", b.Dy())
+	return shrunk, cleanup, nil
+}
+
+func encodeImage(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	} else {
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		err = enc.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
+
+// scaleImage scales src down to width x height, averaging the source pixels
+// covered by every pixel.
+func scaleImage(src image.Image, width, height int) image.Image {
+	b := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+	width, height = max(width, 1), max(height, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				p := rgba.Pix[sy*rgba.Stride+x0*4 : sy*rgba.Stride+x1*4]
+				for i := 0; i < len(p); i += 4 {
+					sum[0] += int(p[i])
+					sum[1] += int(p[i+1])
+					sum[2] += int(p[i+2])
+					sum[3] += int(p[i+3])
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			d := dst.Pix[y*dst.Stride+x*4:]
+			for i := range sum {
+				d[i] = uint8(sum[i] / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestByteSize(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    byteSize
+		str     string
+		wantErr bool
+	}{
+		{"2MB", 2 << 20, "2MB", false},
+		{"500 kb", 500 << 10, "500KB", false},
+		{"1.5G", 3 << 29, "1536MB", false},
+		{"1000", 1000, "1000", false},
+		{"0", 0, "0", false},
+		{"-1MB", 0, "", true},
+		{"2 TB", 0, "", true},
+	} {
+		var b byteSize
+		err := b.Set(tt.in)
+		if (err != nil) != tt.wantErr || err == nil && (b != tt.want || b.String() != tt.str) {
+			t.Errorf("Set(%q) = %d (%s), %v, want %d (%s)", tt.in, b, b.String(), err, tt.want, tt.str)
+		}
+	}
+}
+
+// writeNoise writes a PNG image of random pixels, which compresses badly.
+func writeNoise(t *testing.T, file string, width, height int) {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	r := rand.New(rand.NewSource(1))
+	r.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShrinkImage(t *testing.T) {
+	dir := t.TempDir()
+	screenshot := filepath.Join(dir, "screenshot.png")
+	writeNoise(t, screenshot, 200, 100)
+	fi, err := os.Stat(screenshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(text, make([]byte, 100000), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		file   string
+		limit  int64
+		shrunk bool
+	}{
+		{screenshot, 0, false},
+		{screenshot, fi.Size(), false},
+		{text, 1000, false},
+		{screenshot, fi.Size() / 4, true},
+	} {
+		got, cleanup, err := shrinkImage(tt.file, tt.limit)
+		if err != nil {
+			t.Fatalf("shrinkImage(%s, %d) error = %v", tt.file, tt.limit, err)
+		}
+		if !tt.shrunk {
+			if got != tt.file {
+				t.Errorf("shrinkImage(%s, %d) = %s, want unchanged", tt.file, tt.limit, got)
+			}
+			continue
+		}
+		shrunk, err := os.Stat(got)
+		if err != nil || got == tt.file || filepath.Base(got) != filepath.Base(tt.file) || shrunk.Size() > tt.limit {
+			t.Errorf("shrinkImage(%s, %d) = %s of %v bytes, %v", tt.file, tt.limit, got, shrunk, err)
+		}
+		f, err := os.Open(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if b := img.Bounds(); err != nil || b.Dx() >= 200 || b.Dx() < 2*b.Dy()-2 || b.Dx() > 2*b.Dy()+2 {
+			t.Errorf("shrunk image = %v, %v, want smaller with the same aspect ratio", img.Bounds(), err)
+		}
+		cleanup()
+		if _, err := os.Stat(got); !os.IsNotExist(err) {
+			t.Errorf("shrunk image %s not removed by cleanup: %v", got, err)
+		}
+	}
+
+	broken := filepath.Join(dir, "broken.jpg")
+	if err := os.WriteFile(broken, make([]byte, 1000), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := shrinkImage(broken, 100); err == nil {
+		t.Error("shrinkImage() of an invalid JPEG = nil error")
+	}
+}
+
+func TestScaleImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 2; y++ {
+			c := color.RGBA{0, 0, 0, 0xff}
+			if x%2 == 0 {
+				c = color.RGBA{0xff, 0xff, 0xff, 0xff}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	dst := scaleImage(src, 2, 1)
+	if b := dst.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Fatalf("scaleImage() bounds = %v, want 2x1", b)
+	}
+	// every pixel is the average of a white and a black pixel
+	for x := 0; x < 2; x++ {
+		if got := dst.At(x, 0).(color.RGBA); got != (color.RGBA{0x7f, 0x7f, 0x7f, 0xff}) {
+			t.Errorf("pixel %d = %v, want gray", x, got)
+		}
+	}
+	if b := scaleImage(src, 0, 0).Bounds(); b.Dx() != 1 || b.Dy() != 1 {
+		t.Errorf("scaleImage(0, 0) bounds = %v, want 1x1", b)
+	}
+}
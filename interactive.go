@@ -0,0 +1,316 @@
+// interactive.go
+//
+// Interactive mode (command interactive). Prompts for the room with a fuzzy
+// search across the cached room list, reads a multi-line markdown message,
+// shows it for review and sends it. Meant for manual updates by on-call
+// engineers from a terminal.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+const (
+	roomCacheMaxAge = time.Hour
+	maxRoomMatches  = 10
+)
+
+// roomCache is the format of the room cache file.
+type roomCache struct {
+	Updated time.Time    `json:"updated"`
+	Rooms   []webex.Room `json:"rooms"`
+}
+
+type session struct {
+	in      *bufio.Reader
+	out     io.Writer
+	rooms   []webex.Room
+	room    *webex.Room
+	matches []webex.Room
+}
+
+func roomCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "notify_by_webex_teams", "rooms.json")
+}
+
+// loadRooms returns the rooms of the bot or user from the room cache, or
+// from the API if the cache is older than roomCacheMaxAge or refresh is set.
+func loadRooms(refresh bool) ([]webex.Room, error) {
+	filename := roomCacheFile()
+	cache, cacheErr := readRoomCache(filename)
+	if !refresh && cacheErr == nil && time.Since(cache.Updated) < roomCacheMaxAge {
+		return cache.Rooms, nil
+	}
+
+	client, err := webexClient()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	rooms, err := client.ListRooms(ctx, "", "")
+	if err != nil {
+		if cacheErr == nil {
+			slog.Warn("listing rooms failed, using cached rooms", "error", err, "updated", cache.Updated.Format(time.RFC3339))
+			return cache.Rooms, nil
+		}
+		return nil, err
+	}
+
+	err = writeRoomCache(filename, &roomCache{Updated: time.Now(), Rooms: rooms})
+	if err != nil {
+		slog.Warn("writing room cache failed", "file", filename, "error", err)
+	}
+	return rooms, nil
+}
+
+func readRoomCache(filename string) (*roomCache, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cache roomCache
+	err = json.Unmarshal(data, &cache)
+	if err != nil {
+		return nil, err
+	}
+	return &cache, nil
+}
+
+func writeRoomCache(filename string, cache *roomCache) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0600)
+}
+
+func runInteractive() error {
+	rooms, err := loadRooms(false)
+	if err != nil {
+		return err
+	}
+	s := &session{in: bufio.NewReader(os.Stdin), out: os.Stdout, rooms: rooms}
+
+	fmt.Fprintln(s.out, `type a part of the room name to search, "/refresh" reloads the room list. quit with Ctrl-D`)
+	var text string
+	for {
+		if s.room == nil {
+			err = s.chooseRoom()
+			if err != nil {
+				return ignoreEOF(err)
+			}
+		}
+		if len(text) == 0 {
+			text, err = s.compose()
+			if err != nil {
+				return ignoreEOF(err)
+			}
+			if len(strings.TrimSpace(text)) == 0 {
+				continue
+			}
+		}
+
+		s.preview(text)
+		answer, err := s.ask(fmt.Sprintf("send to %q? [y]es, [e]dit, [r]oom, [q]uit: ", s.room.Title))
+		if err != nil {
+			return ignoreEOF(err)
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			err = s.send(text)
+			if err != nil {
+				slog.Error("sending failed", "room", s.room.Title, "error", err)
+				continue
+			}
+			fmt.Fprintln(s.out, "sent.")
+			text = ""
+		case "e", "edit":
+			text = ""
+		case "r", "room":
+			s.room = nil
+		case "q", "quit":
+			return nil
+		}
+	}
+}
+
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// chooseRoom asks for a search term until a single room is selected.
+func (s *session) chooseRoom() error {
+	for {
+		query, err := s.ask("room> ")
+		if err != nil {
+			return err
+		}
+		if query == "/refresh" {
+			s.rooms, err = loadRooms(true)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(s.out, "%d rooms loaded.\n", len(s.rooms))
+			continue
+		}
+		if n, err := strconv.Atoi(query); err == nil && n >= 1 && n <= len(s.matches) {
+			s.room = &s.matches[n-1]
+			return nil
+		}
+
+		s.matches = matchRooms(s.rooms, query)
+		switch len(s.matches) {
+		case 0:
+			fmt.Fprintln(s.out, "no room found.")
+		case 1:
+			s.room = &s.matches[0]
+			fmt.Fprintf(s.out, "room: %s\n", s.room.Title)
+			return nil
+		default:
+			for i, r := range s.matches {
+				fmt.Fprintf(s.out, "%3d  %s\n", i+1, r.Title)
+			}
+			fmt.Fprintln(s.out, "enter the number of the room or refine the search.")
+		}
+	}
+}
+
+// compose reads a multi-line message terminated by a line containing only ".".
+func (s *session) compose() (string, error) {
+	fmt.Fprintln(s.out, `markdown message, end with a line containing only ".":`)
+	var lines []string
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(lines) > 0 {
+				return strings.Join(lines, "\n"), nil
+			}
+			return "", err
+		}
+		if line == "." {
+			return strings.Join(lines, "\n"), nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+func (s *session) preview(text string) {
+	fmt.Fprintln(s.out, "----")
+	fmt.Fprint(s.out, renderMarkdown(text, terminalRenderer{color: isTerminal(os.Stdout)}))
+	fmt.Fprintln(s.out, "----")
+}
+
+func (s *session) send(text string) error {
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	_, err = client.CreateMessage(ctx, &webex.MessageRequest{RoomID: s.room.ID, Markdown: text})
+	return err
+}
+
+func (s *session) ask(prompt string) (string, error) {
+	fmt.Fprint(s.out, prompt)
+	line, err := s.readLine()
+	return strings.TrimSpace(line), err
+}
+
+func (s *session) readLine() (string, error) {
+	line, err := s.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || len(line) == 0) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// matchRooms returns the rooms whose title fuzzy matches query, best
+// matches first and at most maxRoomMatches.
+func matchRooms(rooms []webex.Room, query string) []webex.Room {
+	type match struct {
+		room  webex.Room
+		score int
+	}
+	var matches []match
+	for _, r := range rooms {
+		if score, ok := fuzzyScore(query, r.Title); ok {
+			matches = append(matches, match{r, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	var result []webex.Room
+	for i := 0; i < len(matches) && i < maxRoomMatches; i++ {
+		result = append(result, matches[i].room)
+	}
+	return result
+}
+
+// fuzzyScore reports whether all characters of pattern appear in s in
+// order, ignoring case and white space. Consecutive characters, matches at
+// word starts and exact substrings score higher.
+func fuzzyScore(pattern, s string) (int, bool) {
+	p := []rune(strings.ToLower(strings.Join(strings.Fields(pattern), "")))
+	text := []rune(strings.ToLower(s))
+	if len(p) == 0 {
+		return 0, true
+	}
+
+	score := 0
+	if strings.Contains(strings.ToLower(s), strings.ToLower(strings.TrimSpace(pattern))) {
+		score += 100
+	}
+	j := 0
+	last := -2
+	for i, c := range text {
+		if j == len(p) {
+			break
+		}
+		if c != p[j] {
+			continue
+		}
+		score++
+		if i == last+1 {
+			score += 5
+		}
+		if i == 0 || !unicode.IsLetter(text[i-1]) && !unicode.IsDigit(text[i-1]) {
+			score += 10
+		}
+		last = i
+		j++
+	}
+	if j < len(p) {
+		return 0, false
+	}
+	// prefer shorter titles among equally good matches
+	return score*100 - len(text), true
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"inc", "Incidents", true},
+		{"INC ops", "Incidents Operations", true},
+		{"ncd", "Incidents", true},
+		{"", "Backups", true},
+		{"dni", "Incidents", false},
+		{"incidents!", "Incidents", false},
+	} {
+		if _, ok := fuzzyScore(tt.pattern, tt.s); ok != tt.want {
+			t.Errorf("fuzzyScore(%q, %q) = %v, want %v", tt.pattern, tt.s, ok, tt.want)
+		}
+	}
+	for _, tt := range []struct{ pattern, better, worse string }{
+		{"ops", "Ops", "Operations"},
+		{"ops", "Ops Team", "Monitoring Ops Team"},
+		{"db", "DB Alerts", "Deploy Bot"},
+		{"backup", "Backup", "Backups"},
+	} {
+		better, _ := fuzzyScore(tt.pattern, tt.better)
+		worse, _ := fuzzyScore(tt.pattern, tt.worse)
+		if better <= worse {
+			t.Errorf("fuzzyScore(%q) of %q = %d, of %q = %d, want the first higher", tt.pattern, tt.better, better, tt.worse, worse)
+		}
+	}
+}
+
+func TestMatchRooms(t *testing.T) {
+	var rooms []webex.Room
+	for _, title := range []string{"Backups", "Incident Review", "Incidents", "Changes"} {
+		rooms = append(rooms, webex.Room{ID: "room-" + title, Title: title})
+	}
+	var titles []string
+	for _, r := range matchRooms(rooms, "inc") {
+		titles = append(titles, r.Title)
+	}
+	if got := strings.Join(titles, ", "); got != "Incidents, Incident Review" {
+		t.Errorf("matchRooms(inc) = %s, want Incidents, Incident Review", got)
+	}
+
+	for i := 0; i < 2*maxRoomMatches; i++ {
+		rooms = append(rooms, webex.Room{ID: fmt.Sprint("team-", i), Title: fmt.Sprint("Team ", i)})
+	}
+	if got := matchRooms(rooms, "team"); len(got) != maxRoomMatches {
+		t.Errorf("matchRooms(team) = %d rooms, want %d", len(got), maxRoomMatches)
+	}
+}
+
+func TestLoadRooms(t *testing.T) {
+	fake := useFakeWebex(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	fake.AddRoom("Incidents", "", "group")
+	titles := func(refresh bool) string {
+		rooms, err := loadRooms(refresh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, r := range rooms {
+			titles = append(titles, r.Title)
+		}
+		return strings.Join(titles, ", ")
+	}
+
+	if got := titles(false); got != "Incidents" {
+		t.Errorf("loadRooms() = %s, want Incidents", got)
+	}
+	if _, err := readRoomCache(roomCacheFile()); err != nil {
+		t.Errorf("room cache %s: %v", roomCacheFile(), err)
+	}
+	fake.AddRoom("Backups", "", "group")
+	if got := titles(false); got != "Incidents" {
+		t.Errorf("loadRooms() = %s, want the cached rooms", got)
+	}
+	fake.Fail(1, http.StatusForbidden)
+	if got := titles(true); got != "Incidents" {
+		t.Errorf("loadRooms(refresh) of an API error = %s, want the cached rooms", got)
+	}
+	if got := titles(true); !strings.Contains(got, "Backups") {
+		t.Errorf("loadRooms(refresh) = %s, want Backups", got)
+	}
+}
+
+func TestRunInteractive(t *testing.T) {
+	fake := useFakeWebex(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	incidents := fake.AddRoom("Incidents", "", "group")
+	fake.AddRoom("Incident Review", "", "group")
+	fake.AddRoom("Backups", "", "group")
+	defer func(stdin *os.File) { os.Stdin = stdin }(os.Stdin)
+
+	input := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(input, []byte("restore\ninc\n1\n# Update\nfailover done\n.\ne\nfailover done, monitoring\n.\ny\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	os.Stdin = f
+
+	out := captureStdout(t, func() { err = runInteractive() })
+	for _, want := range []string{"no room found.\n", "  1  Incidents\n  2  Incident Review\n", "----\nUpdate\n======\n\nfailover done\n\n----\n",
+		`send to "Incidents"? [y]es, [e]dit, [r]oom, [q]uit: `, "sent.\n"} {
+		if err != nil || !strings.Contains(out, want) {
+			t.Errorf("runInteractive() = %v, output %q, want %q", err, out, want)
+		}
+	}
+	messages := fake.Messages()
+	if len(messages) != 1 || messages[0].RoomID != incidents.ID || messages[0].Markdown != "failover done, monitoring" {
+		t.Errorf("messages = %+v, want the edited message in Incidents", messages)
+	}
+}
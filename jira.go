@@ -0,0 +1,346 @@
+// jira.go
+//
+// Jira webhooks (serve --jira). Issue events POSTed to /jira are posted as
+// cards with the issue key, summary, status, assignee and, for updates, the
+// transition or the changed fields. The rooms of the projects are set in the
+// config file (-c), other projects go to -t/-r or -D:
+//
+//	{
+//	  "jira": {
+//	    "projects": {
+//	      "OPS": { "team": "KMP-Team", "room": "Operations" },
+//	      "WEB": { "team": "KMP-Team", "room": "Web" }
+//	    }
+//	  }
+//	}
+//
+// The events are jira:issue_created, jira:issue_updated and comment_created.
+// With --jira-secret only events signed with the secret of the Jira webhook
+// (X-Hub-Signature) are accepted.
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// length of a comment on the card
+const jiraMaxComment = 500
+
+// jiraConfig is the section jira of the config file.
+type jiraConfig struct {
+	Projects map[string]*eventRoute `json:"projects"`
+}
+
+// eventRoute is the recipient of webhook events, e.g. of a Jira project.
+type eventRoute struct {
+	Team  string `json:"team"`
+	Room  string `json:"room"`
+	Email string `json:"email"`
+}
+
+// jiraNamed is a status, priority or issue type of a Jira event.
+type jiraNamed struct {
+	Name string `json:"name"`
+}
+
+// name returns the name, empty for nil.
+func (j *jiraNamed) name() string {
+	if j == nil {
+		return ""
+	}
+	return j.Name
+}
+
+// jiraUser is a user of a Jira event.
+type jiraUser struct {
+	DisplayName string `json:"displayName"`
+}
+
+// jiraEvent is the payload of a Jira webhook.
+type jiraEvent struct {
+	WebhookEvent string    `json:"webhookEvent"`
+	EventType    string    `json:"issue_event_type_name"`
+	User         *jiraUser `json:"user"`
+	Issue        *struct {
+		Key    string `json:"key"`
+		Self   string `json:"self"`
+		Fields struct {
+			Summary   string     `json:"summary"`
+			Assignee  *jiraUser  `json:"assignee"`
+			Status    *jiraNamed `json:"status"`
+			Priority  *jiraNamed `json:"priority"`
+			IssueType *jiraNamed `json:"issuetype"`
+			Project   *struct {
+				Key  string `json:"key"`
+				Name string `json:"name"`
+			} `json:"project"`
+		} `json:"fields"`
+	} `json:"issue"`
+	Changelog *struct {
+		Items []struct {
+			Field      string `json:"field"`
+			FromString string `json:"fromString"`
+			ToString   string `json:"toString"`
+		} `json:"items"`
+	} `json:"changelog"`
+	Comment *struct {
+		Body   string    `json:"body"`
+		Author *jiraUser `json:"author"`
+	} `json:"comment"`
+}
+
+// jiraRoutes are the rooms of the projects, nil without serve --jira.
+var jiraRoutes map[string]*eventRoute
+
+// newJiraRoutes returns the rooms of the projects of the config file.
+func newJiraRoutes() (map[string]*eventRoute, error) {
+	routes := make(map[string]*eventRoute)
+	if len(configFile) > 0 {
+		c, err := loadConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+		if c.Jira != nil {
+			for key, r := range c.Jira.Projects {
+				if len(r.Team) == 0 && len(r.Email) == 0 {
+					return nil, fmt.Errorf("config file %s, jira project %s: set team or email", configFile, key)
+				}
+				if len(r.Team) > 0 && len(r.Room) == 0 {
+					r.Room = roomName
+				}
+				routes[strings.ToUpper(key)] = r
+			}
+		}
+	}
+	if len(routes) == 0 && len(teamName) == 0 && len(emailAddr) == 0 {
+		return nil, errors.New("--jira needs the rooms of the projects in the config file (-c) or flag -t or -D")
+	}
+	return routes, nil
+}
+
+func handleJira(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !allowedSource(r) {
+		slog.Warn("Jira event of a source not allowed rejected", "remote", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, "invalid Jira event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(jiraSecret) > 0 && !verifyHubSignature(body, r.Header.Get("X-Hub-Signature"), jiraSecret) {
+		slog.Warn("Jira event with invalid signature rejected", "remote", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var ev jiraEvent
+	if err := json.Unmarshal(body, &ev); err != nil || ev.Issue == nil {
+		http.Error(w, "invalid Jira event", http.StatusBadRequest)
+		return
+	}
+	n, ok := jiraNotification(&ev)
+	if !ok {
+		slog.Debug("Jira event not posted", "event", ev.WebhookEvent, "type", ev.EventType, "issue", ev.Issue.Key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if len(n.TeamName) == 0 && len(n.Email) == 0 {
+		slog.Warn("Jira event of a project without room ignored", "issue", ev.Issue.Key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if digestWindow > 0 {
+		queueDigest(n)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	if err := sendOrSpool(ctx, n); err != nil {
+		slog.Error("posting Jira event failed", "issue", ev.Issue.Key, "error", err)
+		http.Error(w, redact(err.Error()), http.StatusBadGateway)
+		return
+	}
+	slog.Info("Jira event posted", "event", ev.WebhookEvent, "issue", ev.Issue.Key, "team", n.TeamName, "room", n.RoomName, "email", n.Email)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyHubSignature checks the X-Hub-Signature header "sha256=<hex>", the
+// HMAC-SHA256 of body with the secret.
+func verifyHubSignature(body []byte, signature, secret string) bool {
+	sum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// jiraNotification returns the notification of the event, false if the
+// event is not posted, e.g. suppressed by a rule (see rules.go).
+func jiraNotification(ev *jiraEvent) (*notification, bool) {
+	var action, detail string
+	switch {
+	case ev.WebhookEvent == "jira:issue_created":
+		action = "created"
+	case ev.WebhookEvent == "comment_created" || ev.EventType == "issue_commented":
+		if ev.Comment == nil {
+			return nil, false
+		}
+		action = "commented"
+		detail = strings.TrimSpace(ev.Comment.Body)
+		if utf8.RuneCountInString(detail) > jiraMaxComment {
+			detail = string([]rune(detail)[:jiraMaxComment-1]) + "…"
+		}
+	case ev.WebhookEvent == "jira:issue_updated":
+		action = "updated"
+		if ev.Changelog != nil {
+			var changed []string
+			for _, item := range ev.Changelog.Items {
+				if item.Field == "status" {
+					action = "moved"
+					detail = fmt.Sprintf("%s → %s", item.FromString, item.ToString)
+				}
+				changed = append(changed, item.Field)
+			}
+			if action == "updated" && len(changed) > 0 {
+				detail = "changed " + strings.Join(changed, ", ")
+			}
+		}
+	default:
+		return nil, false
+	}
+
+	issue := ev.Issue
+	project := strings.SplitN(issue.Key, "-", 2)[0]
+	if issue.Fields.Project != nil && len(issue.Fields.Project.Key) > 0 {
+		project = issue.Fields.Project.Key
+	}
+	n := &notification{TeamName: teamName, RoomName: roomName, Email: emailAddr, Mentions: mentionEmails, Severity: severityName}
+	if route := jiraRoutes[strings.ToUpper(project)]; route != nil {
+		n.TeamName, n.RoomName, n.Email = route.Team, route.Room, route.Email
+	}
+
+	by := ""
+	if ev.User != nil {
+		by = ev.User.DisplayName
+	}
+	if action == "commented" && ev.Comment.Author != nil {
+		by = ev.Comment.Author.DisplayName
+	}
+	link := jiraBrowseURL(issue.Self, issue.Key)
+	n.Markdown = fmt.Sprintf("**%s** %s: %s", issue.Key, action, issue.Fields.Summary)
+	if len(link) > 0 {
+		n.Markdown = fmt.Sprintf("[**%s**](%s) %s: %s", issue.Key, link, action, issue.Fields.Summary)
+	}
+	if len(by) > 0 {
+		n.Markdown += " (" + by + ")"
+	}
+	card, err := jiraCard(ev, action, detail, by, link)
+	if err != nil {
+		slog.Error("building the Jira card failed", "issue", issue.Key, "error", err)
+	}
+	n.Card = card
+
+	fields := map[string]string{"event": action, "project": project, "issue": issue.Key, "summary": issue.Fields.Summary,
+		"type": issue.Fields.IssueType.name(), "status": issue.Fields.Status.name(), "priority": issue.Fields.Priority.name(), "user": by}
+	if issue.Fields.Assignee != nil {
+		fields["assignee"] = issue.Fields.Assignee.DisplayName
+	}
+	post, err := applyEventRule("jira", fields, n)
+	if err != nil {
+		slog.Error("applying the rule to the Jira event failed", "issue", issue.Key, "error", err)
+	}
+	return n, post
+}
+
+// jiraBrowseURL returns the URL of the issue in the browser, derived from
+// the REST URL of the issue.
+func jiraBrowseURL(self, key string) string {
+	u, err := url.Parse(self)
+	if err != nil || len(u.Host) == 0 {
+		return ""
+	}
+	base, _, _ := strings.Cut(u.Path, "/rest/")
+	return u.Scheme + "://" + u.Host + base + "/browse/" + key
+}
+
+// jiraCard returns the card attachment of the event.
+func jiraCard(ev *jiraEvent, action, detail, by, link string) (string, error) {
+	issue := ev.Issue
+	var facts []interface{}
+	// facts without value are invalid
+	addFact := func(title, value string) {
+		if len(value) > 0 {
+			facts = append(facts, map[string]interface{}{"title": title, "value": value})
+		}
+	}
+	if issue.Fields.IssueType != nil {
+		addFact(tr("Type"), issue.Fields.IssueType.Name)
+	}
+	if issue.Fields.Status != nil {
+		addFact(tr("Status"), issue.Fields.Status.Name)
+	}
+	if issue.Fields.Priority != nil {
+		addFact(tr("Priority"), issue.Fields.Priority.Name)
+	}
+	assignee := tr("unassigned")
+	if issue.Fields.Assignee != nil {
+		assignee = issue.Fields.Assignee.DisplayName
+	}
+	addFact(tr("Assignee"), assignee)
+	if action == "moved" {
+		addFact(tr("Transition"), detail)
+	}
+	addFact(tr("By"), by)
+
+	title := fmt.Sprintf("%s %s", issue.Key, action)
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": title, "isSubtle": true},
+	}
+	if len(issue.Fields.Summary) > 0 {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": issue.Fields.Summary, "size": "Large", "weight": "Bolder", "wrap": true})
+	}
+	if (action == "commented" || action == "updated") && len(detail) > 0 {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": detail, "wrap": true})
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": defaultCardVersion,
+		"body":    body,
+	}
+	if len(link) > 0 {
+		card["actions"] = []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": trf("Open %s", issue.Key), "url": link},
+		}
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// jiraIssue is the issue of the Jira test events.
+const jiraIssue = `"issue": {"key": "OPS-42", "self": "https://jira.example.com/jira/rest/api/2/issue/10042",
+	"fields": {"summary": "Disk full on srv1", "status": {"name": "In Progress"}, "priority": {"name": "High"},
+	"issuetype": {"name": "Bug"}, "project": {"key": "OPS"}}}`
+
+func TestVerifyHubSignature(t *testing.T) {
+	body := []byte(`{"webhookEvent":"jira:issue_created"}`)
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(body)
+	sum := hex.EncodeToString(mac.Sum(nil))
+	for _, tt := range []struct {
+		signature string
+		want      bool
+	}{
+		{"sha256=" + sum, true},
+		{sum, false},
+		{"sha1=" + sum, false},
+		{"sha256=" + sum[:62] + "00", false},
+		{"sha256=xyz", false},
+		{"", false},
+	} {
+		if got := verifyHubSignature(body, tt.signature, "s3cr3t"); got != tt.want {
+			t.Errorf("verifyHubSignature(%q) = %v, want %v", tt.signature, got, tt.want)
+		}
+	}
+}
+
+func TestJiraBrowseURL(t *testing.T) {
+	for _, tt := range []struct {
+		self, want string
+	}{
+		{"https://jira.example.com/rest/api/2/issue/10042", "https://jira.example.com/browse/OPS-42"},
+		{"https://example.com/jira/rest/api/2/issue/10042", "https://example.com/jira/browse/OPS-42"},
+		{"", ""},
+		{"not a url", ""},
+	} {
+		if got := jiraBrowseURL(tt.self, "OPS-42"); got != tt.want {
+			t.Errorf("jiraBrowseURL(%q) = %q, want %q", tt.self, got, tt.want)
+		}
+	}
+}
+
+func TestJiraNotification(t *testing.T) {
+	defer func(team, room string, routes map[string]*eventRoute) {
+		teamName, roomName, jiraRoutes = team, room, routes
+	}(teamName, roomName, jiraRoutes)
+	teamName, roomName = "KMP-Team", "Jira"
+	jiraRoutes = map[string]*eventRoute{"OPS": {Team: "KMP-Team", Room: "Operations"}}
+
+	for _, tt := range []struct {
+		name, event  string
+		wantPost     bool
+		wantMarkdown string
+		wantCard     []string
+	}{
+		{name: "created", event: `"webhookEvent": "jira:issue_created", "user": {"displayName": "Alice"}`, wantPost: true,
+			wantMarkdown: "[**OPS-42**](https://jira.example.com/jira/browse/OPS-42) created: Disk full on srv1 (Alice)",
+			wantCard:     []string{`"text":"OPS-42 created"`, `"value":"unassigned"`, `"url":"https://jira.example.com/jira/browse/OPS-42"`}},
+		{name: "moved", event: `"webhookEvent": "jira:issue_updated", "changelog": {"items": [{"field": "assignee"}, {"field": "status", "fromString": "Open", "toString": "In Progress"}]}`, wantPost: true,
+			wantMarkdown: "[**OPS-42**](https://jira.example.com/jira/browse/OPS-42) moved: Disk full on srv1",
+			wantCard:     []string{`{"title":"Transition","value":"Open → In Progress"}`}},
+		{name: "updated", event: `"webhookEvent": "jira:issue_updated", "changelog": {"items": [{"field": "priority"}, {"field": "labels"}]}`, wantPost: true,
+			wantMarkdown: "[**OPS-42**](https://jira.example.com/jira/browse/OPS-42) updated: Disk full on srv1",
+			wantCard:     []string{`"text":"changed priority, labels"`}},
+		{name: "commented", event: `"webhookEvent": "comment_created", "user": {"displayName": "Alice"}, "comment": {"body": " freed 20 GB ", "author": {"displayName": "Bob"}}`, wantPost: true,
+			wantMarkdown: "[**OPS-42**](https://jira.example.com/jira/browse/OPS-42) commented: Disk full on srv1 (Bob)",
+			wantCard:     []string{`"text":"freed 20 GB"`, `{"title":"By","value":"Bob"}`}},
+		{name: "comment without comment", event: `"webhookEvent": "comment_created"`},
+		{name: "deleted", event: `"webhookEvent": "jira:issue_deleted"`},
+	} {
+		var ev jiraEvent
+		if err := json.Unmarshal([]byte("{"+tt.event+", "+jiraIssue+"}"), &ev); err != nil {
+			t.Fatal(err)
+		}
+		n, post := jiraNotification(&ev)
+		if post != tt.wantPost {
+			t.Errorf("%s: jiraNotification() = %v, want %v", tt.name, post, tt.wantPost)
+			continue
+		}
+		if !post {
+			continue
+		}
+		if n.Markdown != tt.wantMarkdown || n.TeamName != "KMP-Team" || n.RoomName != "Operations" {
+			t.Errorf("%s: jiraNotification() = %q to %s/%s, want %q to KMP-Team/Operations", tt.name, n.Markdown, n.TeamName, n.RoomName, tt.wantMarkdown)
+		}
+		for _, want := range tt.wantCard {
+			if !strings.Contains(n.Card, want) {
+				t.Errorf("%s: jiraNotification() card = %s, want %s", tt.name, n.Card, want)
+			}
+		}
+	}
+
+	// issues of other projects go to -t/-r
+	ev := jiraEvent{WebhookEvent: "jira:issue_created"}
+	if err := json.Unmarshal([]byte("{"+strings.ReplaceAll(jiraIssue, `"OPS`, `"WEB`)+"}"), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := jiraNotification(&ev); n.RoomName != "Jira" {
+		t.Errorf("jiraNotification() of project WEB to room %q, want Jira", n.RoomName)
+	}
+}
+
+func TestNewJiraRoutes(t *testing.T) {
+	defer func(file, team, room, email string) {
+		configFile, teamName, roomName, emailAddr = file, team, room, email
+	}(configFile, teamName, roomName, emailAddr)
+	configFile, teamName, roomName, emailAddr = "", "", "Jira", ""
+	if _, err := newJiraRoutes(); err == nil || !strings.Contains(err.Error(), "--jira needs the rooms") {
+		t.Errorf("newJiraRoutes() without rooms error = %v", err)
+	}
+
+	configFile = filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"jira": {"projects": {"ops": {"team": "KMP-Team"}, "WEB": {"email": "web@example.com"}}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := newJiraRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := routes["OPS"]; r == nil || r.Team != "KMP-Team" || r.Room != "Jira" {
+		t.Errorf("newJiraRoutes() OPS = %+v, want KMP-Team/Jira", r)
+	}
+	if r := routes["WEB"]; r == nil || r.Email != "web@example.com" {
+		t.Errorf("newJiraRoutes() WEB = %+v, want web@example.com", r)
+	}
+
+	if err := os.WriteFile(configFile, []byte(`{"jira": {"projects": {"OPS": {"room": "Operations"}}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newJiraRoutes(); err == nil || !strings.Contains(err.Error(), "jira project OPS: set team or email") {
+		t.Errorf("newJiraRoutes() of a project without team error = %v", err)
+	}
+}
+
+func TestHandleJira(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(secret string, routes map[string]*eventRoute) { jiraSecret, jiraRoutes = secret, routes }(jiraSecret, jiraRoutes)
+	jiraSecret = "s3cr3t"
+	jiraRoutes = map[string]*eventRoute{"OPS": {Team: "KMP-Team", Room: "Operations"}}
+
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte(jiraSecret))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	created := `{"webhookEvent": "jira:issue_created", ` + jiraIssue + `}`
+	deleted := `{"webhookEvent": "jira:issue_deleted", ` + jiraIssue + `}`
+	for _, tt := range []struct {
+		name, method, body, signature string
+		want                          int
+	}{
+		{"created", "POST", created, sign(created), http.StatusNoContent},
+		{"deleted", "POST", deleted, sign(deleted), http.StatusNoContent},
+		{"get", "GET", created, sign(created), http.StatusMethodNotAllowed},
+		{"wrong signature", "POST", created, sign(deleted), http.StatusUnauthorized},
+		{"no issue", "POST", `{}`, sign(`{}`), http.StatusBadRequest},
+	} {
+		r := httptest.NewRequest(tt.method, "/jira", strings.NewReader(tt.body))
+		r.Header.Set("X-Hub-Signature", tt.signature)
+		w := httptest.NewRecorder()
+		handleJira(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: handleJira() status = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+	if m := fake.Messages(); len(m) != 1 || !strings.Contains(m[0].Markdown, "OPS-42") {
+		t.Errorf("messages = %+v, want the created issue", m)
+	}
+}
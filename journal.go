@@ -0,0 +1,129 @@
+// journal.go
+//
+// Audit journal of the sent messages (flags --journal, --no-journal). Every
+// message sent, suppressed as duplicate or failed is appended as one JSON
+// line to the journal file, with the time, the recipient, the ID of the
+// message and the SHA-256 hash of its content (text, card and file names),
+// but not the content itself:
+//
+//	{"time":"2026-10-15T09:12:03+02:00","team":"KMP-Team","room":"Alerts","roomId":"Y2lz...","messageId":"Y2lz...","sha256":"9f86d0...","result":"sent"}
+//
+// The file is only appended to, by single writes of whole lines, so
+// parallel invocations and the relay can share it. With --state-key-file
+// the lines are encrypted, see statecrypt.go. A journal which can not
+// be written is logged, the message is sent anyway.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// results of the journal entries
+const (
+	journalSent      = "sent"
+	journalDuplicate = "duplicate"
+	journalFailed    = "failed"
+)
+
+// journalEntry is a line of the journal.
+type journalEntry struct {
+	Time      time.Time `json:"time"`
+	Team      string    `json:"team,omitempty"`
+	Room      string    `json:"room,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Profile   string    `json:"profile,omitempty"`
+	RoomID    string    `json:"roomId,omitempty"`
+	MessageID string    `json:"messageId,omitempty"`
+	SHA256    string    `json:"sha256"`
+	Result    string    `json:"result"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var journalMutex sync.Mutex
+
+func defaultJournalFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "notify_by_webex_teams", "journal.jsonl")
+}
+
+// contentHash returns the SHA-256 hash of the content of n.
+func contentHash(n *notification) string {
+	h := sha256.New()
+	for _, s := range append([]string{n.Markdown, n.Card}, notificationFiles(n)...) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// journalNotification appends the result of sending n as message m to the
+// journal. m is nil if nothing was sent.
+func journalNotification(n *notification, m *webex.Message, err error) {
+	if noJournal || len(journalFile) == 0 {
+		return
+	}
+	e := &journalEntry{
+		Time:    time.Now(),
+		Team:    n.TeamName,
+		Room:    n.RoomName,
+		Email:   n.Email,
+		Profile: n.Profile,
+		SHA256:  contentHash(n),
+		Result:  journalSent,
+	}
+	if len(e.Team) == 0 {
+		e.Room = ""
+	}
+	if m != nil {
+		e.RoomID, e.MessageID = m.RoomID, m.ID
+	}
+	switch {
+	case errors.Is(err, errDuplicate):
+		e.Result = journalDuplicate
+	case err != nil:
+		e.Result, e.Error = journalFailed, redact(err.Error())
+	}
+	if err := appendJournal(journalFile, e); err != nil {
+		slog.Error("writing the journal failed", "file", journalFile, "error", err)
+	}
+}
+
+// appendJournal appends e as one line to the journal file.
+func appendJournal(file string, e *journalEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if line, err = sealLine(line); err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
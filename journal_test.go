@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestContentHash(t *testing.T) {
+	n := &notification{Markdown: "disk full", Card: testCard}
+	if got := contentHash(n); len(got) != 64 || got != contentHash(&notification{Markdown: "disk full", Card: testCard, TeamName: "Other"}) {
+		t.Errorf("contentHash() = %q, want the same hash for other recipients", got)
+	}
+	for _, other := range []*notification{
+		{Markdown: "disk full"},
+		{Markdown: "disk ful", Card: "l" + testCard},
+		{Markdown: "disk full", Card: testCard, File: "report.pdf"},
+	} {
+		if contentHash(other) == contentHash(n) {
+			t.Errorf("contentHash(%+v) = contentHash(%+v)", other, n)
+		}
+	}
+}
+
+// readJournal returns the entries of the journal file.
+func readJournal(t *testing.T, file string) []journalEntry {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var entries []journalEntry
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line, err := openLine(s.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var e journalEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			t.Fatalf("journal line %q: %v", s.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestJournalNotification(t *testing.T) {
+	defer func(file string, no bool) { journalFile, noJournal = file, no }(journalFile, noJournal)
+	useStateKey(t, "")
+	journalFile, noJournal = filepath.Join(t.TempDir(), "journal", "journal.jsonl"), false
+	addSecret("journal-token-4711")
+
+	n := &notification{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "disk full"}
+	m := &webex.Message{ID: "message-1", RoomID: "room-1"}
+	journalNotification(n, m, nil)
+	journalNotification(n, nil, fmt.Errorf("sending: %w", errDuplicate))
+	journalNotification(&notification{Email: "alice@example.com", RoomName: "ignored", Markdown: "disk full"}, nil,
+		errors.New("401 Unauthorized, token journal-token-4711"))
+	noJournal = true
+	journalNotification(n, m, nil)
+
+	entries := readJournal(t, journalFile)
+	want := []journalEntry{
+		{Team: "KMP-Team", Room: "Alerts", RoomID: "room-1", MessageID: "message-1", Result: journalSent},
+		{Team: "KMP-Team", Room: "Alerts", Result: journalDuplicate},
+		{Email: "alice@example.com", Result: journalFailed, Error: "401 Unauthorized, token " + redacted},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("journal = %+v, want %d entries", entries, len(want))
+	}
+	for i, e := range entries {
+		if e.Time.IsZero() || e.SHA256 != contentHash(n) {
+			t.Errorf("entry %d: time %v, hash %s, want %s", i, e.Time, e.SHA256, contentHash(n))
+		}
+		e.Time, e.SHA256 = want[i].Time, ""
+		if e != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestJournalEncrypted(t *testing.T) {
+	defer func(file string, no bool) { journalFile, noJournal = file, no }(journalFile, noJournal)
+	useStateKey(t, "correct horse battery staple")
+	journalFile, noJournal = filepath.Join(t.TempDir(), "journal.jsonl"), false
+
+	journalNotification(&notification{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "disk full"}, nil, nil)
+	data, err := os.ReadFile(journalFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), lineMagic) || strings.Contains(string(data), "KMP-Team") {
+		t.Errorf("journal = %q, want encrypted", data)
+	}
+	if entries := readJournal(t, journalFile); len(entries) != 1 || entries[0].Team != "KMP-Team" {
+		t.Errorf("decrypted journal = %+v", entries)
+	}
+}
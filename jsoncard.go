@@ -0,0 +1,157 @@
+// jsoncard.go
+//
+// JSON as card (flag --json-card). The JSON document on standard input,
+// e.g. the output of a tool, is shown as FactSet: nested keys are flattened
+// to paths like "disk.usage" and "hosts[0].name", in the order of the
+// document, and long values are cut. -m is the title of the card.
+//
+//	curl -s http://localhost:9100/status | notify_by_webex_teams -t KMP-Team -r Status -m "node status" --json-card
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// facts shown on the card
+	jsonMaxFacts = 100
+	// length of a value on the card
+	jsonMaxValue = 200
+)
+
+// jsonFact is a flattened key and its value.
+type jsonFact struct {
+	key, value string
+}
+
+// jsonCardFromStdin returns the card and the message of the JSON document
+// on standard input.
+func jsonCardFromStdin() (string, string, error) {
+	if useStdIn {
+		return "", "", errors.New("--json-card and -i both read standard input")
+	}
+	facts, err := flattenJSON(os.Stdin)
+	if err != nil {
+		return "", "", fmt.Errorf("--json-card: %w", err)
+	}
+	return jsonCard(strings.TrimSpace(markdownMsg), facts)
+}
+
+// flattenJSON returns the values of the JSON document of r with their
+// paths.
+func flattenJSON(r io.Reader) ([]jsonFact, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var facts []jsonFact
+	if err := flattenValue(dec, "", &facts); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("more than one JSON document")
+	}
+	return facts, nil
+}
+
+func flattenValue(dec *json.Decoder, path string, facts *[]jsonFact) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	add := func(value string) {
+		if len(path) == 0 {
+			path = "value"
+		}
+		*facts = append(*facts, jsonFact{path, value})
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		n := 0
+		for ; dec.More(); n++ {
+			child := fmt.Sprintf("%s[%d]", path, n)
+			if t == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child = key.(string)
+				if len(path) > 0 {
+					child = path + "." + child
+				}
+			}
+			if err := flattenValue(dec, child, facts); err != nil {
+				return err
+			}
+		}
+		// the closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		if n == 0 && t == '{' {
+			add("{}")
+		} else if n == 0 {
+			add("[]")
+		}
+	case string:
+		if len(t) == 0 {
+			t = `""`
+		}
+		add(t)
+	case json.Number:
+		add(t.String())
+	case bool:
+		add(fmt.Sprint(t))
+	case nil:
+		add("null")
+	}
+	return nil
+}
+
+// jsonCard returns the card attachment with the facts and the message of
+// clients without cards, the title.
+func jsonCard(title string, facts []jsonFact) (string, string, error) {
+	var body []interface{}
+	if len(title) > 0 {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": title, "size": "Medium", "weight": "Bolder", "wrap": true})
+	}
+	var set []interface{}
+	for i, f := range facts {
+		if i == jsonMaxFacts {
+			more := fmt.Sprintf("… %d more", len(facts)-i)
+			body = append(body, map[string]interface{}{"type": "FactSet", "facts": set},
+				map[string]interface{}{"type": "TextBlock", "text": more, "isSubtle": true})
+			set = nil
+			break
+		}
+		value := strings.Join(strings.Fields(f.value), " ")
+		if len(value) == 0 {
+			value = `" "`
+		}
+		if utf8.RuneCountInString(value) > jsonMaxValue {
+			value = string([]rune(value)[:jsonMaxValue-1]) + "…"
+		}
+		set = append(set, map[string]interface{}{"title": f.key, "value": value})
+	}
+	if len(set) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": set})
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": defaultCardVersion,
+		"body":    body,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if len(title) == 0 {
+		title = fmt.Sprintf("%d values", len(facts))
+	}
+	card, err := normalizeCard(string(data))
+	return card, title, err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFlattenJSON(t *testing.T) {
+	for _, tt := range []struct {
+		doc     string
+		want    string
+		wantErr bool
+	}{
+		{doc: `{"status":"ok","disk":{"usage":0.93,"mounts":["/","/var"]},"up":true,"note":null}`,
+			want: `[{status ok} {disk.usage 0.93} {disk.mounts[0] /} {disk.mounts[1] /var} {up true} {note null}]`},
+		{doc: `[{"name":"web-1"},{"name":"web-2"}]`, want: `[{[0].name web-1} {[1].name web-2}]`},
+		{doc: `{"empty":{},"none":[],"blank":""}`, want: `[{empty {}} {none []} {blank ""}]`},
+		{doc: `42`, want: `[{value 42}]`},
+		{doc: `12345678901234567890`, want: `[{value 12345678901234567890}]`},
+		{doc: `{"a":1} {"b":2}`, wantErr: true},
+		{doc: `{"a":`, wantErr: true},
+	} {
+		facts, err := flattenJSON(strings.NewReader(tt.doc))
+		if tt.wantErr != (err != nil) {
+			t.Errorf("flattenJSON(%s) error = %v, want error %v", tt.doc, err, tt.wantErr)
+			continue
+		}
+		if got := fmt.Sprint(facts); !tt.wantErr && got != tt.want {
+			t.Errorf("flattenJSON(%s) = %s, want %s", tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestJSONCard(t *testing.T) {
+	facts := []jsonFact{{"status", "ok"}, {"message", "line 1\n  line 2"}, {"long", strings.Repeat("x", 300)}}
+	card, text, err := jsonCard("node status", facts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "node status" {
+		t.Errorf("text = %q, want the title", text)
+	}
+	for _, want := range []string{`"text":"node status"`, `{"title":"status","value":"ok"}`, `"value":"line 1 line 2"`,
+		`"value":"` + strings.Repeat("x", jsonMaxValue-1) + `…"`} {
+		if !strings.Contains(card, want) {
+			t.Errorf("card does not contain %s: %s", want, card)
+		}
+	}
+
+	facts = nil
+	for i := 0; i < jsonMaxFacts+5; i++ {
+		facts = append(facts, jsonFact{fmt.Sprintf("k%d", i), "v"})
+	}
+	card, text, err = jsonCard("", facts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "105 values" || !strings.Contains(card, "… 5 more") || strings.Contains(card, `"k100"`) {
+		t.Errorf("jsonCard() of %d facts = %q, card %s", len(facts), text, card)
+	}
+}
@@ -0,0 +1,250 @@
+// junit.go
+//
+// Test report summary (flag --junit). The JUnit XML reports, e.g.
+// "--junit 'build/test-results/*.xml'", are summarized in a card with the
+// numbers of passed, failed and skipped tests, the failed tests with their
+// message and the slowest tests. In a CI environment (see ci.go) the card
+// shows the branch and commit and links to the job. With --junit-attach the
+// reports are sent with the message.
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// failed tests listed on the card and in the message
+	junitMaxFailures = 10
+	// slowest tests listed on the card
+	junitMaxSlowest = 5
+	// length of a failure message on the card
+	junitMessageMax = 200
+)
+
+// junitSuite is a testsuite or the testsuites root element of a report.
+type junitSuite struct {
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string       `xml:"name,attr"`
+	Classname string       `xml:"classname,attr"`
+	Time      string       `xml:"time,attr"`
+	Failure   *junitResult `xml:"failure"`
+	Error     *junitResult `xml:"error"`
+	Skipped   *junitResult `xml:"skipped"`
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitTest is a test case of the summary.
+type junitTest struct {
+	Name     string
+	Duration time.Duration
+	Message  string
+}
+
+// junitSummary is the summary of one or more reports.
+type junitSummary struct {
+	Files    []string
+	Passed   int
+	Failed   int
+	Skipped  int
+	Duration time.Duration
+	Failures []junitTest
+	Slowest  []junitTest
+}
+
+// readJUnit reads and summarizes the reports of patterns (file names or
+// glob patterns).
+func readJUnit(patterns []string) (*junitSummary, error) {
+	s := &junitSummary{}
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no JUnit report %s", pattern)
+		}
+		s.Files = append(s.Files, files...)
+	}
+	var tests []junitTest
+	for _, file := range s.Files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var root junitSuite
+		if err := xml.Unmarshal(data, &root); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		tests = s.add(&root, tests)
+	}
+	if s.Passed+s.Failed+s.Skipped == 0 {
+		return nil, errors.New("no test cases in the JUnit reports")
+	}
+	sort.SliceStable(tests, func(i, j int) bool { return tests[i].Duration > tests[j].Duration })
+	s.Slowest = tests[:min(len(tests), junitMaxSlowest)]
+	return s, nil
+}
+
+// add counts the test cases of suite and its nested suites and returns
+// tests with the executed ones appended.
+func (s *junitSummary) add(suite *junitSuite, tests []junitTest) []junitTest {
+	for i := range suite.Suites {
+		tests = s.add(&suite.Suites[i], tests)
+	}
+	for _, c := range suite.Cases {
+		name := c.Name
+		if len(c.Classname) > 0 {
+			name = c.Classname + "." + c.Name
+		}
+		// e.g. time="1,234.5" of some reporters
+		seconds, _ := strconv.ParseFloat(strings.ReplaceAll(c.Time, ",", ""), 64)
+		t := junitTest{Name: name, Duration: time.Duration(seconds * float64(time.Second)).Round(time.Millisecond)}
+		s.Duration += t.Duration
+		switch {
+		case c.Failure != nil || c.Error != nil:
+			result := c.Failure
+			if result == nil {
+				result = c.Error
+			}
+			t.Message = result.Message
+			if len(t.Message) == 0 {
+				t.Message, _, _ = strings.Cut(strings.TrimSpace(result.Text), "\n")
+			}
+			s.Failed++
+			s.Failures = append(s.Failures, t)
+		case c.Skipped != nil:
+			s.Skipped++
+			continue
+		default:
+			s.Passed++
+		}
+		tests = append(tests, t)
+	}
+	return tests
+}
+
+// title returns the headline of the summary, e.g. "❌ 3 of 120 tests failed".
+func (s *junitSummary) title() string {
+	if s.Failed > 0 {
+		return trf("❌ %d of %d tests failed", s.Failed, s.Passed+s.Failed)
+	}
+	return trf("✅ %d tests passed", s.Passed)
+}
+
+// text returns the summary as markdown, the message of clients without
+// cards.
+func (s *junitSummary) text(message string, build *ciBuild) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** %s", s.title(), trf("(%d passed, %d skipped) in %s", s.Passed, s.Skipped, s.Duration.Round(time.Second)))
+	if build != nil && len(build.URL) > 0 {
+		fmt.Fprintf(&b, " [%s](%s)", build.Project, build.URL)
+	}
+	if len(message) > 0 {
+		b.WriteString("\n" + message)
+	}
+	for i, t := range s.Failures {
+		if i == junitMaxFailures {
+			b.WriteString("\n- " + trf("… %d more", len(s.Failures)-i))
+			break
+		}
+		fmt.Fprintf(&b, "\n- `%s`", t.Name)
+	}
+	return b.String()
+}
+
+// card returns the card attachment of the summary. build is nil outside of
+// a CI environment.
+func (s *junitSummary) card(message string, build *ciBuild) (string, error) {
+	color := "good"
+	if s.Failed > 0 {
+		color = "attention"
+	}
+	counts := []interface{}{
+		map[string]interface{}{"title": tr("Passed"), "value": strconv.Itoa(s.Passed)},
+		map[string]interface{}{"title": tr("Failed"), "value": strconv.Itoa(s.Failed)},
+		map[string]interface{}{"title": tr("Skipped"), "value": strconv.Itoa(s.Skipped)},
+		map[string]interface{}{"title": tr("Duration"), "value": s.Duration.Round(time.Second).String()},
+	}
+	if build != nil {
+		if len(build.Branch) > 0 {
+			counts = append(counts, map[string]interface{}{"title": tr("Branch"), "value": build.Branch})
+		}
+		if len(build.Commit) > 0 {
+			counts = append(counts, map[string]interface{}{"title": tr("Commit"), "value": build.Commit[:min(len(build.Commit), 8)]})
+		}
+	}
+	header := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": s.title(), "size": "Large", "weight": "Bolder", "wrap": true},
+	}
+	if build != nil && len(build.Project) > 0 {
+		header = append(header, map[string]interface{}{"type": "TextBlock", "text": build.Project, "isSubtle": true, "spacing": "None"})
+	}
+	if len(message) > 0 {
+		header = append(header, map[string]interface{}{"type": "TextBlock", "text": message, "wrap": true})
+	}
+	header = append(header, map[string]interface{}{"type": "FactSet", "facts": counts})
+	body := []interface{}{
+		map[string]interface{}{"type": "Container", "style": color, "bleed": true, "items": header},
+	}
+
+	if len(s.Failures) > 0 {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": tr("Failed tests"), "weight": "Bolder", "separator": true})
+		for i, t := range s.Failures {
+			if i == junitMaxFailures {
+				body = append(body, map[string]interface{}{"type": "TextBlock", "text": trf("… %d more", len(s.Failures)-i), "isSubtle": true})
+				break
+			}
+			body = append(body, map[string]interface{}{"type": "TextBlock", "text": t.Name, "wrap": true, "color": "Attention"})
+			if msg := strings.TrimSpace(t.Message); len(msg) > 0 {
+				if len(msg) > junitMessageMax {
+					msg = strings.ToValidUTF8(msg[:junitMessageMax], "") + "…"
+				}
+				body = append(body, map[string]interface{}{"type": "TextBlock", "text": msg, "wrap": true, "isSubtle": true, "spacing": "None", "fontType": "Monospace"})
+			}
+		}
+	}
+	var slowest []interface{}
+	for _, t := range s.Slowest {
+		if t.Duration > 0 {
+			slowest = append(slowest, map[string]interface{}{"title": t.Name, "value": t.Duration.String()})
+		}
+	}
+	if len(slowest) > 0 {
+		body = append(body,
+			map[string]interface{}{"type": "TextBlock", "text": tr("Slowest tests"), "weight": "Bolder", "separator": true},
+			map[string]interface{}{"type": "FactSet", "facts": slowest})
+	}
+
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": defaultCardVersion,
+		"body":    body,
+	}
+	if build != nil && len(build.URL) > 0 {
+		card["actions"] = []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": trf("Open in %s", build.System), "url": build.URL},
+		}
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const junitReport = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="api">
+    <testcase classname="api.UserTest" name="testCreate" time="0.5"/>
+    <testcase classname="api.UserTest" name="testDelete" time="1,234.5">
+      <failure message="expected 204, got 500">stack trace</failure>
+    </testcase>
+    <testsuite name="nested">
+      <testcase name="testNested" time="2"><error>NullPointerException
+	at Foo.bar</error></testcase>
+    </testsuite>
+  </testsuite>
+</testsuites>`
+
+func TestReadJUnit(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"TEST-api.xml": junitReport,
+		"TEST-ui.xml":  `<testsuite><testcase name="testLogin" time="3"/><testcase name="testLogout"><skipped/></testcase></testsuite>`,
+		"empty.xml":    `<testsuite/>`,
+		"broken.xml":   `<testsuite><testcase`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := readJUnit([]string{filepath.Join(dir, "TEST-*.xml")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Passed != 2 || s.Failed != 2 || s.Skipped != 1 || len(s.Files) != 2 {
+		t.Errorf("readJUnit() = %d passed, %d failed, %d skipped of %d files, want 2, 2, 1 of 2", s.Passed, s.Failed, s.Skipped, len(s.Files))
+	}
+	if want := 1240 * time.Second; s.Duration != want {
+		t.Errorf("duration = %v, want %v", s.Duration, want)
+	}
+	// the nested suites come first
+	if len(s.Failures) != 2 || s.Failures[0].Name != "testNested" || s.Failures[0].Message != "NullPointerException" ||
+		s.Failures[1].Name != "api.UserTest.testDelete" || s.Failures[1].Message != "expected 204, got 500" {
+		t.Errorf("failures = %+v", s.Failures)
+	}
+	if len(s.Slowest) != 4 || s.Slowest[0].Name != "api.UserTest.testDelete" || s.Slowest[1].Name != "testLogin" {
+		t.Errorf("slowest = %+v", s.Slowest)
+	}
+	if got, want := s.title(), "❌ 2 of 4 tests failed"; got != want {
+		t.Errorf("title() = %q, want %q", got, want)
+	}
+	text := s.text("nightly build", &ciBuild{Project: "billing", URL: "https://ci.example.com/job/1"})
+	for _, want := range []string{"(2 passed, 1 skipped) in 20m40s [billing](https://ci.example.com/job/1)", "\nnightly build", "\n- `api.UserTest.testDelete`"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text() = %q, does not contain %q", text, want)
+		}
+	}
+	card, err := s.card("", &ciBuild{Branch: "main", Commit: "0123456789abcdef"})
+	if err != nil || !strings.Contains(card, `"attention"`) || !strings.Contains(card, `"01234567"`) {
+		t.Errorf("card() = %s, %v", card, err)
+	}
+
+	for _, tt := range []struct {
+		patterns []string
+		wantErr  string
+	}{
+		{[]string{filepath.Join(dir, "missing-*.xml")}, "no JUnit report"},
+		{[]string{filepath.Join(dir, "empty.xml")}, "no test cases"},
+		{[]string{filepath.Join(dir, "broken.xml")}, "broken.xml"},
+	} {
+		if _, err := readJUnit(tt.patterns); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("readJUnit(%q) error = %v, want %s", tt.patterns, err, tt.wantErr)
+		}
+	}
+}
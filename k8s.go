@@ -0,0 +1,494 @@
+// k8s.go
+//
+// Kubernetes events watcher (flag --k8s-watch). Watches the events of a
+// namespace (--namespace, default all namespaces) and posts the matching
+// ones as cards, e.g. as small in-cluster notifier instead of a full
+// alerting stack:
+//
+//	notify_by_webex_teams --k8s-watch --namespace prod --reason Failed,OOMKilling -t KMP-Team -r K8s
+//
+// Without --reason all events of type Warning are posted. Inside a pod the
+// service account is used, otherwise the current context of the kubeconfig
+// (--kubeconfig, $KUBECONFIG or ~/.kube/config) with a token or client
+// certificate. Exec credential plugins are not supported. The service
+// account needs get, list and watch on events.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	k8sServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// the API server ends a watch after this time, it is started again
+	k8sWatchTimeout = 5 * time.Minute
+)
+
+// k8sClient is a minimal client of the Kubernetes API.
+type k8sClient struct {
+	server string
+	token  string
+	client *http.Client
+}
+
+// kubeconfig is the part of a kubeconfig file used by k8sClient.
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Contexts       []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster string `json:"cluster"`
+			User    string `json:"user"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Clusters []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData string `json:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string          `json:"token"`
+			TokenFile             string          `json:"tokenFile"`
+			ClientCertificate     string          `json:"client-certificate"`
+			ClientCertificateData string          `json:"client-certificate-data"`
+			ClientKey             string          `json:"client-key"`
+			ClientKeyData         string          `json:"client-key-data"`
+			Exec                  json.RawMessage `json:"exec"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+// k8sEvent is a core/v1 event.
+type k8sEvent struct {
+	Metadata struct {
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	InvolvedObject struct {
+		Kind      string `json:"kind"`
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"involvedObject"`
+	Reason        string    `json:"reason"`
+	Message       string    `json:"message"`
+	Type          string    `json:"type"`
+	Count         int       `json:"count"`
+	LastTimestamp time.Time `json:"lastTimestamp"`
+	EventTime     time.Time `json:"eventTime"`
+	ReportingComp string    `json:"reportingComponent"`
+	Source        struct {
+		Component string `json:"component"`
+		Host      string `json:"host"`
+	} `json:"source"`
+}
+
+// k8sWatchEvent is a line of a watch. Object is an event, or a status for
+// type ERROR.
+type k8sWatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// newK8sClient returns the client of the service account of the pod or of
+// the kubeconfig file.
+func newK8sClient(kubeconfigFile string) (*k8sClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if len(kubeconfigFile) == 0 && len(host) > 0 {
+		token, err := os.ReadFile(filepath.Join(k8sServiceAccountDir, "token"))
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if err := appendCertsFromFile(pool, filepath.Join(k8sServiceAccountDir, "ca.crt")); err != nil {
+			return nil, err
+		}
+		if len(port) == 0 {
+			port = "443"
+		}
+		server := "https://" + net.JoinHostPort(host, port)
+		return &k8sClient{server: server, token: strings.TrimSpace(string(token)), client: k8sHTTPClient(&tls.Config{RootCAs: pool})}, nil
+	}
+
+	if len(kubeconfigFile) == 0 {
+		kubeconfigFile = strings.Split(os.Getenv("KUBECONFIG"), string(os.PathListSeparator))[0]
+	}
+	if len(kubeconfigFile) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		kubeconfigFile = filepath.Join(home, ".kube", "config")
+	}
+	return loadKubeconfig(kubeconfigFile)
+}
+
+func loadKubeconfig(file string) (*k8sClient, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	data, err = json.Marshal(yamlToJSON(v))
+	if err != nil {
+		return nil, err
+	}
+	var kc kubeconfig
+	if err := json.Unmarshal(data, &kc); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	// relative file names are relative to the kubeconfig file
+	path := func(name string) string {
+		if len(name) == 0 || filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(filepath.Dir(file), name)
+	}
+	// read returns the content of the base64 data or of the file name
+	read := func(b64, name string) ([]byte, error) {
+		if len(b64) > 0 {
+			return base64.StdEncoding.DecodeString(b64)
+		}
+		if len(name) > 0 {
+			return os.ReadFile(path(name))
+		}
+		return nil, nil
+	}
+
+	var clusterName, userName string
+	for _, c := range kc.Contexts {
+		if c.Name == kc.CurrentContext {
+			clusterName, userName = c.Context.Cluster, c.Context.User
+		}
+	}
+	if len(clusterName) == 0 {
+		return nil, fmt.Errorf("%s: current context %q not found", file, kc.CurrentContext)
+	}
+	c := &k8sClient{}
+	tlsConfig := &tls.Config{}
+	for _, cl := range kc.Clusters {
+		if cl.Name != clusterName {
+			continue
+		}
+		c.server = strings.TrimRight(cl.Cluster.Server, "/")
+		tlsConfig.InsecureSkipVerify = cl.Cluster.InsecureSkipTLSVerify
+		ca, err := read(cl.Cluster.CertificateAuthorityData, cl.Cluster.CertificateAuthority)
+		if err != nil {
+			return nil, fmt.Errorf("%s: certificate authority of cluster %s: %v", file, clusterName, err)
+		}
+		if len(ca) > 0 {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("%s: no certificate in the certificate authority of cluster %s", file, clusterName)
+			}
+		}
+	}
+	if len(c.server) == 0 {
+		return nil, fmt.Errorf("%s: cluster %q not found", file, clusterName)
+	}
+	for _, u := range kc.Users {
+		if u.Name != userName {
+			continue
+		}
+		if len(u.User.Exec) > 0 && string(u.User.Exec) != "null" {
+			return nil, fmt.Errorf("%s: user %s uses an exec credential plugin, which is not supported. use a token", file, userName)
+		}
+		c.token = u.User.Token
+		if len(u.User.TokenFile) > 0 {
+			token, err := os.ReadFile(path(u.User.TokenFile))
+			if err != nil {
+				return nil, err
+			}
+			c.token = strings.TrimSpace(string(token))
+		}
+		cert, err := read(u.User.ClientCertificateData, u.User.ClientCertificate)
+		if err != nil {
+			return nil, err
+		}
+		key, err := read(u.User.ClientKeyData, u.User.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		if len(cert) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: client certificate of user %s: %v", file, userName, err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	c.client = k8sHTTPClient(tlsConfig)
+	return c, nil
+}
+
+// k8sHTTPClient returns the HTTP client of the API server. It does not use a
+// proxy, as the API server is usually inside the network.
+func k8sHTTPClient(tlsConfig *tls.Config) *http.Client {
+	tlsConfig.MinVersion = tls.VersionTLS12
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}}
+}
+
+func (c *k8sClient) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.server+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("Kubernetes API %s: HTTP status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// k8sWatcher posts the matching events of a namespace.
+type k8sWatcher struct {
+	client          *k8sClient
+	path            string
+	reasons         map[string]bool
+	resourceVersion string
+}
+
+func runK8sWatch() error {
+	client, err := newK8sClient(kubeconfigFile)
+	if err != nil {
+		return err
+	}
+	if len(teamName) == 0 && len(emailAddr) == 0 {
+		return errors.New("no recipient. use flag -t or flag -D")
+	}
+	w := &k8sWatcher{client: client, path: "/api/v1/events"}
+	if len(k8sNamespace) > 0 {
+		w.path = "/api/v1/namespaces/" + url.PathEscape(k8sNamespace) + "/events"
+	}
+	for _, r := range strings.Split(k8sReasons, ",") {
+		if r = strings.TrimSpace(r); len(r) > 0 {
+			if w.reasons == nil {
+				w.reasons = make(map[string]bool)
+			}
+			w.reasons[r] = true
+		}
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+	if useSpool {
+		go runSpoolFlusher(ctx)
+	}
+	go runTokenReloader(ctx)
+	slog.Info("watching Kubernetes events", "server", client.server, "namespace", k8sNamespace, "reasons", k8sReasons)
+	delay := time.Second
+	for {
+		err = w.watch(ctx)
+		if ctx.Err() != nil {
+			finishShutdown()
+			slog.Info("Kubernetes watch stopped")
+			return nil
+		}
+		if err == nil {
+			// the API server ended the watch
+			delay = time.Second
+			continue
+		}
+		slog.Warn("Kubernetes watch failed", "error", err, "retryIn", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		if delay < time.Minute {
+			delay *= 2
+		}
+	}
+}
+
+// watch watches the events from the last resource version, or from now on.
+func (w *k8sWatcher) watch(ctx context.Context) error {
+	if len(w.resourceVersion) == 0 {
+		// the resource version of the list, so older events are skipped
+		resp, err := w.client.get(ctx, w.path, url.Values{"limit": {"1"}})
+		if err != nil {
+			return err
+		}
+		var list struct {
+			Metadata struct {
+				ResourceVersion string `json:"resourceVersion"`
+			} `json:"metadata"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		w.resourceVersion = list.Metadata.ResourceVersion
+	}
+
+	resp, err := w.client.get(ctx, w.path, url.Values{
+		"watch":               {"1"},
+		"resourceVersion":     {w.resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {fmt.Sprint(int(k8sWatchTimeout.Seconds()))},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var we k8sWatchEvent
+		err := dec.Decode(&we)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch we.Type {
+		case "ERROR":
+			var status struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			json.Unmarshal(we.Object, &status)
+			if status.Code == http.StatusGone {
+				// the resource version is too old, start from now on
+				w.resourceVersion = ""
+			}
+			return fmt.Errorf("Kubernetes watch error %d: %s", status.Code, status.Message)
+		case "ADDED", "MODIFIED", "BOOKMARK":
+			var e k8sEvent
+			if err := json.Unmarshal(we.Object, &e); err != nil {
+				return err
+			}
+			w.resourceVersion = e.Metadata.ResourceVersion
+			if we.Type != "BOOKMARK" && w.matches(&e) {
+				w.post(&e)
+			}
+		}
+	}
+}
+
+func (w *k8sWatcher) matches(e *k8sEvent) bool {
+	if w.reasons != nil {
+		return w.reasons[e.Reason]
+	}
+	return e.Type == "Warning"
+}
+
+func (w *k8sWatcher) post(e *k8sEvent) {
+	card, err := k8sEventCard(e)
+	if err != nil {
+		slog.Error("building the event card failed", "error", err)
+		return
+	}
+	n := &notification{
+		TeamName: teamName,
+		RoomName: roomName,
+		Email:    emailAddr,
+		Markdown: fmt.Sprintf("**%s** %s %s/%s: %s", e.Reason, e.InvolvedObject.Kind, e.Metadata.Namespace, e.InvolvedObject.Name, e.Message),
+		Card:     card,
+		Mentions: mentionEmails,
+		Severity: severityName,
+	}
+	if len(n.Severity) == 0 {
+		n.Severity = "info"
+		if e.Type == "Warning" {
+			n.Severity = "warning"
+		}
+	}
+	if digestWindow > 0 {
+		queueDigest(n)
+		return
+	}
+	slog.Info("posting Kubernetes event", "namespace", e.Metadata.Namespace, "object", e.InvolvedObject.Kind+"/"+e.InvolvedObject.Name, "reason", e.Reason)
+	ctx, cancel := requestContext()
+	defer cancel()
+	if err := sendOrSpool(ctx, n); err != nil {
+		slog.Error("posting Kubernetes event failed", "reason", e.Reason, "error", err)
+	}
+}
+
+// k8sEventCard returns the card attachment of the event.
+func k8sEventCard(e *k8sEvent) (string, error) {
+	source := e.Source.Component
+	if len(source) == 0 {
+		source = e.ReportingComp
+	}
+	if len(e.Source.Host) > 0 {
+		source = trf("%s on %s", source, e.Source.Host)
+	}
+	last := e.LastTimestamp
+	if last.IsZero() {
+		last = e.EventTime
+	}
+	var facts []interface{}
+	// facts without value are invalid
+	addFact := func(title, value string) {
+		if len(value) > 0 {
+			facts = append(facts, map[string]interface{}{"title": title, "value": value})
+		}
+	}
+	addFact(tr("Namespace"), e.Metadata.Namespace)
+	addFact(tr("Object"), strings.Trim(e.InvolvedObject.Kind+"/"+e.InvolvedObject.Name, "/"))
+	addFact(tr("Type"), e.Type)
+	if e.Count > 1 {
+		addFact(tr("Count"), fmt.Sprint(e.Count))
+	}
+	addFact(tr("Source"), source)
+	if !last.IsZero() {
+		addFact(tr("Last seen"), formatTimeSeconds(last.Local()))
+	}
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": e.Reason + ": " + e.InvolvedObject.Name, "size": "Large", "weight": "Bolder", "wrap": true},
+	}
+	if len(e.Message) > 0 {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": e.Message, "wrap": true})
+	}
+	if len(facts) > 0 {
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": defaultCardVersion,
+		"body":    body,
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
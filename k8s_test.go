@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadKubeconfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	write := func(text string) string {
+		file := filepath.Join(dir, "config")
+		if err := os.WriteFile(file, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	config := func(context, user string) string {
+		return `apiVersion: v1
+current-context: ` + context + `
+contexts:
+  - name: prod
+    context: {cluster: prod, user: admin}
+  - name: lost
+    context: {cluster: gone, user: admin}
+clusters:
+  - name: prod
+    cluster:
+      server: https://k8s.example.com:6443/
+      insecure-skip-tls-verify: true
+users:
+  - name: admin
+    user:
+` + user
+	}
+
+	for _, tt := range []struct {
+		name, text, wantToken, wantErr string
+	}{
+		{name: "token", text: config("prod", "      token: abc123\n"), wantToken: "abc123"},
+		{name: "token file", text: config("prod", "      tokenFile: token\n"), wantToken: "file-token"},
+		{name: "exec", text: config("prod", "      exec: {command: aws}\n"), wantErr: "exec credential plugin"},
+		{name: "client certificate", text: config("prod", "      client-certificate-data: bm8=\n      client-key-data: bm8=\n"), wantErr: "client certificate of user admin"},
+		{name: "no context", text: config("test", "      token: abc123\n"), wantErr: `current context "test" not found`},
+		{name: "no cluster", text: config("lost", "      token: abc123\n"), wantErr: `cluster "gone" not found`},
+	} {
+		c, err := loadKubeconfig(write(tt.text))
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: loadKubeconfig() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: loadKubeconfig() error = %v", tt.name, err)
+			continue
+		}
+		if c.server != "https://k8s.example.com:6443" || c.token != tt.wantToken {
+			t.Errorf("%s: loadKubeconfig() = %s with token %q, want token %q", tt.name, c.server, c.token, tt.wantToken)
+		}
+	}
+}
+
+func TestK8sWatcherMatches(t *testing.T) {
+	all := &k8sWatcher{}
+	some := &k8sWatcher{reasons: map[string]bool{"OOMKilling": true}}
+	for _, tt := range []struct {
+		e               k8sEvent
+		wantAll, wantOf bool
+	}{
+		{k8sEvent{Type: "Warning", Reason: "BackOff"}, true, false},
+		{k8sEvent{Type: "Normal", Reason: "Pulled"}, false, false},
+		{k8sEvent{Type: "Warning", Reason: "OOMKilling"}, true, true},
+	} {
+		if got := all.matches(&tt.e); got != tt.wantAll {
+			t.Errorf("matches(%s) without --reason = %v, want %v", tt.e.Reason, got, tt.wantAll)
+		}
+		if got := some.matches(&tt.e); got != tt.wantOf {
+			t.Errorf("matches(%s) with --reason = %v, want %v", tt.e.Reason, got, tt.wantOf)
+		}
+	}
+}
+
+func TestK8sEventCard(t *testing.T) {
+	var e k8sEvent
+	e.Metadata.Namespace = "prod"
+	e.InvolvedObject.Kind, e.InvolvedObject.Name = "Pod", "shop-7d9f"
+	e.Reason, e.Message, e.Type, e.Count = "BackOff", "Back-off restarting failed container", "Warning", 5
+	e.ReportingComp, e.Source.Host = "kubelet", "node-1"
+	e.EventTime = time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	card, err := k8sEventCard(&e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"text":"BackOff: shop-7d9f"`, `{"title":"Object","value":"Pod/shop-7d9f"}`, `{"title":"Count","value":"5"}`,
+		`{"title":"Source","value":"kubelet on node-1"}`, `"title":"Last seen"`} {
+		if !strings.Contains(card, want) {
+			t.Errorf("k8sEventCard() = %s, want %s", card, want)
+		}
+	}
+}
+
+func TestK8sWatch(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(team, room string) { teamName, roomName = team, room }(teamName, roomName)
+	teamName, roomName = "KMP-Team", "K8s"
+
+	event := func(typ, version, reason, eventType string) string {
+		return `{"type": "` + typ + `", "object": {"metadata": {"namespace": "prod", "resourceVersion": "` + version + `"},
+			"involvedObject": {"kind": "Pod", "name": "shop-7d9f"}, "reason": "` + reason + `", "type": "` + eventType + `"}}` + "\n"
+	}
+	var watches []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer abc123" || r.URL.Path != "/api/v1/namespaces/prod/events" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if r.URL.Query().Get("watch") != "1" {
+			w.Write([]byte(`{"metadata": {"resourceVersion": "100"}, "items": []}`))
+			return
+		}
+		watches = append(watches, r.URL.Query().Get("resourceVersion"))
+		if len(watches) > 1 {
+			w.Write([]byte(`{"type": "ERROR", "object": {"code": 410, "message": "too old resource version"}}`))
+			return
+		}
+		w.Write([]byte(event("ADDED", "101", "BackOff", "Warning") + event("ADDED", "102", "Pulled", "Normal") +
+			event("BOOKMARK", "103", "", "")))
+	}))
+	defer server.Close()
+
+	w := &k8sWatcher{client: &k8sClient{server: server.URL, token: "abc123", client: server.Client()}, path: "/api/v1/namespaces/prod/events"}
+	if err := w.watch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if w.resourceVersion != "103" {
+		t.Errorf("resource version after the watch = %q, want 103", w.resourceVersion)
+	}
+	if m := fake.Messages(); len(m) != 1 || m[0].Markdown != "⚠️ **BackOff** Pod prod/shop-7d9f: " {
+		t.Errorf("messages = %+v, want the warning", m)
+	}
+
+	err := w.watch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "Kubernetes watch error 410") || len(w.resourceVersion) > 0 {
+		t.Errorf("watch() error = %v with resource version %q, want 410 and a new start", err, w.resourceVersion)
+	}
+	if strings.Join(watches, ",") != "100,103" {
+		t.Errorf("watched from resource versions %q, want 100,103", watches)
+	}
+
+	w.client.token = "expired"
+	if err := w.watch(context.Background()); err == nil || !strings.Contains(err.Error(), "HTTP status 403") {
+		t.Errorf("watch() with invalid token error = %v, want 403", err)
+	}
+}
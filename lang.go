@@ -0,0 +1,256 @@
+// lang.go
+//
+// Language of the built-in texts (flag --lang): the facts, buttons and
+// headlines of the built-in cards, e.g. of --ci-card, --junit, k8s-watch,
+// meeting create and the receivers of serve, the acknowledgements and
+// escalations, and the default messages of run, with the date format of the
+// language:
+//
+//	notify_by_webex_teams --lang de serve --listen :8080 --pagerduty -t KMP-Team -r Incidents
+//
+// The texts are looked up by their English text. Texts without translation
+// stay English. The user's own messages, cards and templates are unchanged.
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// translations are the built-in texts of the languages other than English,
+// by their English text.
+var translations = map[string]map[string]string{
+	"de": {
+		// --ack, see ack.go
+		"Acknowledge":              "Bestätigen",
+		"Acknowledged by %s at %s": "Bestätigt von %s am %s",
+
+		// --ci-card, see ci.go
+		"✅ Build succeeded": "✅ Build erfolgreich",
+		"❌ Build failed":    "❌ Build fehlgeschlagen",
+		"⏹️ Build canceled": "⏹️ Build abgebrochen",
+		"Build finished":    "Build beendet",
+		"Triggered by":      "Ausgelöst von",
+		"Duration":          "Dauer",
+		"Open in %s":        "In %s öffnen",
+		"Open commit":       "Commit öffnen",
+		"Open %s":           "%s öffnen",
+
+		// --junit, see junit.go
+		"❌ %d of %d tests failed":       "❌ %d von %d Tests fehlgeschlagen",
+		"✅ %d tests passed":             "✅ %d Tests bestanden",
+		"(%d passed, %d skipped) in %s": "(%d bestanden, %d übersprungen) in %s",
+		"… %d more":                     "… %d weitere",
+		"Passed":                        "Bestanden",
+		"Failed":                        "Fehlgeschlagen",
+		"Skipped":                       "Übersprungen",
+		"Failed tests":                  "Fehlgeschlagene Tests",
+		"Slowest tests":                 "Langsamste Tests",
+
+		// k8s-watch, see k8s.go
+		"Object":    "Objekt",
+		"Type":      "Typ",
+		"Count":     "Anzahl",
+		"Source":    "Quelle",
+		"Last seen": "Zuletzt gesehen",
+		"%s on %s":  "%s auf %s",
+
+		// meeting create, see meeting.go
+		"Start":          "Beginn",
+		"End":            "Ende",
+		"Meeting number": "Meeting-Nummer",
+		"Password":       "Passwort",
+		"Video address":  "Videoadresse",
+		"Join meeting":   "Meeting beitreten",
+		"**%s** join: %s (meeting number %s, password %s)": "**%s** Teilnahme: %s (Meeting-Nummer %s, Passwort %s)",
+
+		// serve --pagerduty, --sentry, --jira and --datadog
+		"Urgency":            "Dringlichkeit",
+		"Priority":           "Priorität",
+		"Assigned to":        "Zugewiesen an",
+		"Escalation policy":  "Eskalationsrichtlinie",
+		"Triggered":          "Ausgelöst",
+		"Last change":        "Letzte Änderung",
+		"%s by %s":           "%s von %s",
+		"Culprit":            "Verursacher",
+		"Project":            "Projekt",
+		"Environment":        "Umgebung",
+		"Alerts":             "Alarme",
+		"First seen":         "Zuerst gesehen",
+		"Rule":               "Regel",
+		"Sentry issue %s":    "Sentry-Issue %s",
+		"%d alerts since %s": "%d Alarme seit %s",
+		"Assignee":           "Bearbeiter",
+		"unassigned":         "nicht zugewiesen",
+		"Transition":         "Übergang",
+		"By":                 "Von",
+		"Datadog monitor":    "Datadog-Monitor",
+		"since %s":           "seit %s",
+
+		// run, --escalate-after, the files and the digests
+		"⏫ **not acknowledged within %s** in %s":                     "⏫ **nicht bestätigt innerhalb von %s** in %s",
+		"✅ %s succeeded on **%s** in %s":                             "✅ %s erfolgreich auf **%s** in %s",
+		"was terminated (%s)":                                        "wurde beendet (%s)",
+		"could not be started":                                       "konnte nicht gestartet werden",
+		"failed with exit code %d":                                   "fehlgeschlagen mit Exit-Code %d",
+		"%s %s on **%s** after %s":                                   "%s %s auf **%s** nach %s",
+		"📎 %d files: %s":                                             "📎 %d Dateien: %s",
+		"(link valid until %s)":                                      "(Link gültig bis %s)",
+		"… truncated, %d more lines":                                 "… gekürzt, %d weitere Zeilen",
+		"**%d notifications** within %s":                             "**%d Benachrichtigungen** innerhalb von %s",
+		"… and %d more":                                              "… und %d weitere",
+		"📄 full message attached as message.txt (%d lines, %.1f KB)": "📄 vollständige Nachricht als message.txt angehängt (%d Zeilen, %.1f KB)",
+
+		// the function ago of the templates, see templatetime.go
+		"%s ago": "vor %s",
+		"in %s":  "in %s",
+
+		// --footer auto, see footer.go
+		"sent by %s at %s": "gesendet von %s am %s",
+	},
+	"fr": {
+		// --ack, see ack.go
+		"Acknowledge":              "Acquitter",
+		"Acknowledged by %s at %s": "Acquitté par %s le %s",
+
+		// --ci-card, see ci.go
+		"✅ Build succeeded": "✅ Build réussi",
+		"❌ Build failed":    "❌ Build en échec",
+		"⏹️ Build canceled": "⏹️ Build annulé",
+		"Build finished":    "Build terminé",
+		"Branch":            "Branche",
+		"Triggered by":      "Déclenché par",
+		"Duration":          "Durée",
+		"Open in %s":        "Ouvrir dans %s",
+		"Open commit":       "Ouvrir le commit",
+		"Open %s":           "Ouvrir %s",
+
+		// --junit, see junit.go
+		"❌ %d of %d tests failed":       "❌ %d tests sur %d en échec",
+		"✅ %d tests passed":             "✅ %d tests réussis",
+		"(%d passed, %d skipped) in %s": "(%d réussis, %d ignorés) en %s",
+		"… %d more":                     "… %d de plus",
+		"Passed":                        "Réussis",
+		"Failed":                        "En échec",
+		"Skipped":                       "Ignorés",
+		"Failed tests":                  "Tests en échec",
+		"Slowest tests":                 "Tests les plus lents",
+
+		// k8s-watch, see k8s.go
+		"Object":    "Objet",
+		"Count":     "Nombre",
+		"Last seen": "Vu la dernière fois",
+		"%s on %s":  "%s sur %s",
+
+		// meeting create, see meeting.go
+		"Start":          "Début",
+		"End":            "Fin",
+		"Meeting number": "Numéro de réunion",
+		"Password":       "Mot de passe",
+		"Video address":  "Adresse vidéo",
+		"Join meeting":   "Rejoindre la réunion",
+		"**%s** join: %s (meeting number %s, password %s)": "**%s** rejoindre : %s (numéro de réunion %s, mot de passe %s)",
+
+		// serve --pagerduty, --sentry, --jira and --datadog
+		"Status": "Statut",
+
+		// serve --pagerduty, --sentry, --jira and --datadog
+		"Urgency":            "Urgence",
+		"Priority":           "Priorité",
+		"Assigned to":        "Assigné à",
+		"Escalation policy":  "Politique d'escalade",
+		"Triggered":          "Déclenché",
+		"Last change":        "Dernier changement",
+		"%s by %s":           "%s par %s",
+		"Culprit":            "Origine",
+		"Level":              "Niveau",
+		"Project":            "Projet",
+		"Environment":        "Environnement",
+		"Alerts":             "Alertes",
+		"First seen":         "Vu la première fois",
+		"Rule":               "Règle",
+		"Sentry issue %s":    "Problème Sentry %s",
+		"%d alerts since %s": "%d alertes depuis le %s",
+		"Assignee":           "Responsable",
+		"unassigned":         "non assigné",
+		"By":                 "Par",
+		"Host":               "Hôte",
+		"Datadog monitor":    "Moniteur Datadog",
+		"since %s":           "depuis le %s",
+
+		// run, --escalate-after, the files and the digests
+		"⏫ **not acknowledged within %s** in %s":                     "⏫ **non acquitté en %s** dans %s",
+		"✅ %s succeeded on **%s** in %s":                             "✅ %s réussi sur **%s** en %s",
+		"was terminated (%s)":                                        "a été arrêté (%s)",
+		"could not be started":                                       "n'a pas pu être démarré",
+		"failed with exit code %d":                                   "a échoué avec le code de sortie %d",
+		"%s %s on **%s** after %s":                                   "%s %s sur **%s** après %s",
+		"📎 %d files: %s":                                             "📎 %d fichiers : %s",
+		"(link valid until %s)":                                      "(lien valide jusqu'au %s)",
+		"… truncated, %d more lines":                                 "… tronqué, %d lignes de plus",
+		"**%d notifications** within %s":                             "**%d notifications** en %s",
+		"… and %d more":                                              "… et %d de plus",
+		"📄 full message attached as message.txt (%d lines, %.1f KB)": "📄 message complet joint en message.txt (%d lignes, %.1f Ko)",
+
+		// the function ago of the templates, see templatetime.go
+		"%s ago": "il y a %s",
+		"in %s":  "dans %s",
+
+		// --footer auto, see footer.go
+		"sent by %s at %s": "envoyé par %s le %s",
+	},
+}
+
+// dateLayouts are the layouts of the dates and times in the built-in texts.
+var dateLayouts = map[string]string{
+	"en": "2006-01-02 15:04 MST",
+	"de": "02.01.2006 15:04 MST",
+	"fr": "02/01/2006 15:04 MST",
+}
+
+// checkLang checks the language of --lang.
+func checkLang() error {
+	if _, ok := dateLayouts[textLang]; !ok {
+		langs := make([]string, 0, len(dateLayouts))
+		for lang := range dateLayouts {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		return fmt.Errorf("unsupported --lang %q. use %s", textLang, strings.Join(langs, ", "))
+	}
+	return nil
+}
+
+// tr returns the built-in text s in the language of --lang.
+func tr(s string) string {
+	if t, ok := translations[textLang][s]; ok {
+		return t
+	}
+	return s
+}
+
+// trf formats the built-in text format in the language of --lang.
+func trf(format string, args ...interface{}) string {
+	return fmt.Sprintf(tr(format), args...)
+}
+
+// formatTime returns t in the date format of --lang, e.g. 15.10.2026 14:30
+// CEST for de.
+func formatTime(t time.Time) string {
+	layout, ok := dateLayouts[textLang]
+	if !ok {
+		layout = dateLayouts["en"]
+	}
+	return t.Format(layout)
+}
+
+// formatTimeSeconds is formatTime with seconds.
+func formatTimeSeconds(t time.Time) string {
+	layout, ok := dateLayouts[textLang]
+	if !ok {
+		layout = dateLayouts["en"]
+	}
+	return t.Format(strings.Replace(layout, "15:04", "15:04:05", 1))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestTranslations checks that the translations have the verbs of their
+// English text in the same order, so trf does not mix up its arguments.
+func TestTranslations(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z%]`)
+	for lang, texts := range translations {
+		if _, ok := dateLayouts[lang]; !ok {
+			t.Errorf("language %s has no date layout", lang)
+		}
+		for en, text := range texts {
+			if len(strings.TrimSpace(text)) == 0 {
+				t.Errorf("%s: empty translation of %q", lang, en)
+			}
+			if got, want := verbs.FindAllString(text, -1), verbs.FindAllString(en, -1); strings.Join(got, "") != strings.Join(want, "") {
+				t.Errorf("%s: %q has the verbs %v, %q has %v", lang, text, got, en, want)
+			}
+		}
+	}
+}
+
+func TestTr(t *testing.T) {
+	defer func(lang string) { textLang = lang }(textLang)
+	at := time.Date(2026, 10, 15, 14, 30, 5, 0, time.FixedZone("CEST", 2*60*60))
+	for _, tt := range []struct {
+		lang, text, time, timeSeconds string
+	}{
+		{"en", "❌ 3 of 120 tests failed", "2026-10-15 14:30 CEST", "2026-10-15 14:30:05 CEST"},
+		{"de", "❌ 3 von 120 Tests fehlgeschlagen", "15.10.2026 14:30 CEST", "15.10.2026 14:30:05 CEST"},
+		{"fr", "", "15/10/2026 14:30 CEST", "15/10/2026 14:30:05 CEST"},
+	} {
+		textLang = tt.lang
+		if err := checkLang(); err != nil {
+			t.Errorf("checkLang() of %s = %v", tt.lang, err)
+		}
+		if got := trf("❌ %d of %d tests failed", 3, 120); len(tt.text) > 0 && got != tt.text {
+			t.Errorf("trf() in %s = %q, want %q", tt.lang, got, tt.text)
+		}
+		if got := tr("no such text"); got != "no such text" {
+			t.Errorf("tr() of an untranslated text in %s = %q", tt.lang, got)
+		}
+		if got := formatTime(at); got != tt.time {
+			t.Errorf("formatTime() in %s = %q, want %q", tt.lang, got, tt.time)
+		}
+		if got := formatTimeSeconds(at); got != tt.timeSeconds {
+			t.Errorf("formatTimeSeconds() in %s = %q, want %q", tt.lang, got, tt.timeSeconds)
+		}
+	}
+	textLang = "xx"
+	if err := checkLang(); err == nil || !strings.Contains(err.Error(), "use de, en, fr") {
+		t.Errorf("checkLang() of xx = %v", err)
+	}
+}
@@ -0,0 +1,406 @@
+// largefile.go
+//
+// Large files (flag --large-file-store). Files above the limit of Webex
+// (100 MB) are uploaded to object storage instead and the message gets a
+// download link:
+//
+//	📦 build-2.4.1.iso (812.4 MB): [download](https://...) (link valid until 2026-10-22 10:00 UTC)
+//
+// The store is one of
+//
+//	s3://<bucket>/<prefix>   Amazon S3 or S3 compatible storage, the link is pre-signed
+//	gs://<bucket>/<prefix>   Google Cloud Storage with an HMAC key, the link is pre-signed
+//	https://<host>/<path>/   WebDAV, the link is the URL of the file
+//
+// S3 and GCS take the credentials from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, the region from AWS_REGION
+// (default us-east-1) and an S3 compatible endpoint, e.g. of MinIO, from
+// AWS_ENDPOINT_URL. Requests are signed with AWS Signature Version 4, which
+// GCS accepts for HMAC keys. WebDAV takes the user and password of the URL.
+// The name of the object starts with a random part, so links can not be
+// guessed. --large-file-expiry is the validity of pre-signed links.
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+const (
+	// longest validity of a pre-signed link
+	maxPresignExpiry = 7 * 24 * time.Hour
+	// largest object of a single PUT
+	maxSinglePut = 5 << 30
+)
+
+// fileStore is the storage of --large-file-store.
+type fileStore struct {
+	// name is the URL of the store without password
+	name   string
+	scheme string
+	// endpoint and bucket of S3 and GCS, base URL of WebDAV
+	endpoint     *url.URL
+	bucket       string
+	prefix       string
+	pathStyle    bool
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+// offloadable returns whether file is too large for Webex and goes to the
+// store.
+func offloadable(file string) bool {
+	if len(largeFileStore) == 0 {
+		return false
+	}
+	fi, err := os.Stat(file)
+	return err == nil && fi.Mode().IsRegular() && fi.Size() > webex.MaxFileSize
+}
+
+// newFileStore returns the store of the URL.
+func newFileStore(rawURL string) (*fileStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("--large-file-store: %w", err)
+	}
+	s := &fileStore{name: u.Redacted(), scheme: u.Scheme}
+	switch u.Scheme {
+	case "s3", "gs":
+		if len(u.Host) == 0 {
+			return nil, fmt.Errorf("--large-file-store %s: no bucket", rawURL)
+		}
+		s.bucket = u.Host
+		s.prefix = strings.Trim(u.Path, "/")
+		s.accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.sessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		if len(s.accessKey) == 0 || len(s.secretKey) == 0 {
+			return nil, errors.New("--large-file-store: no credentials. set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		addSecret(s.secretKey)
+		addSecret(s.sessionToken)
+		endpoint := os.Getenv("AWS_ENDPOINT_URL")
+		s.region = firstNonEmpty(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+		switch {
+		case u.Scheme == "gs":
+			endpoint, s.region, s.pathStyle = "https://storage.googleapis.com", "auto", true
+		case len(endpoint) > 0:
+			s.pathStyle = true
+		case strings.Contains(s.bucket, "."):
+			// the certificate of the virtual host does not match
+			endpoint, s.pathStyle = "https://s3."+s.region+".amazonaws.com", true
+		default:
+			endpoint = "https://" + s.bucket + ".s3." + s.region + ".amazonaws.com"
+		}
+		if s.endpoint, err = url.Parse(endpoint); err != nil {
+			return nil, fmt.Errorf("AWS_ENDPOINT_URL: %w", err)
+		}
+	case "http", "https":
+		s.endpoint = u
+		if password, ok := u.User.Password(); ok {
+			addSecret(password)
+		}
+	default:
+		return nil, fmt.Errorf("invalid --large-file-store %q. use s3://<bucket>/<prefix>, gs://<bucket>/<prefix> or a WebDAV URL", rawURL)
+	}
+	if s.client, err = sharedHTTPClient(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
+
+// offloadLargeFiles uploads the files of n above the limit of Webex to the
+// store and adds their links to the message.
+func offloadLargeFiles(ctx context.Context, n *notification) error {
+	var large, kept []string
+	for _, file := range notificationFiles(n) {
+		if offloadable(file) {
+			large = append(large, file)
+		} else {
+			kept = append(kept, file)
+		}
+	}
+	if len(large) == 0 {
+		return nil
+	}
+	store, err := newFileStore(largeFileStore)
+	if err != nil {
+		return err
+	}
+	var links []string
+	for _, file := range large {
+		link, expires, err := store.upload(ctx, file)
+		if err != nil {
+			return fmt.Errorf("uploading %s to %s: %w", file, store.name, err)
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		line := fmt.Sprintf("📦 %s (%.1f MB): [download](%s)", filepath.Base(file), float64(fi.Size())/(1<<20), link)
+		if !expires.IsZero() {
+			line += " " + trf("(link valid until %s)", formatTime(expires.UTC()))
+		}
+		links = append(links, line)
+		slog.Info("large file stored", "file", file, "store", store.name)
+	}
+
+	n.File, n.Files = "", nil
+	addFiles(n, kept...)
+	list := strings.Join(links, "\n")
+	if len(strings.TrimSpace(n.Markdown)) == 0 {
+		n.Markdown = list
+	} else {
+		n.Markdown = strings.TrimRight(n.Markdown, "\n") + "\n\n" + list
+	}
+	return nil
+}
+
+// upload stores the file and returns its link and when the link expires,
+// zero if it does not.
+func (s *fileStore) upload(ctx context.Context, file string) (string, time.Time, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	random := make([]byte, 12)
+	if _, err := rand.Read(random); err != nil {
+		return "", time.Time{}, err
+	}
+	name := hex.EncodeToString(random) + "-" + filepath.Base(file)
+
+	if s.scheme == "http" || s.scheme == "https" {
+		return s.putWebDAV(ctx, f, fi.Size(), name)
+	}
+	if fi.Size() > maxSinglePut {
+		return "", time.Time{}, fmt.Errorf("%s has more than %d GB", file, maxSinglePut>>30)
+	}
+	key := name
+	if len(s.prefix) > 0 {
+		key = s.prefix + "/" + name
+	}
+	objectURL := s.objectURL(key)
+	now := time.Now().UTC()
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", objectURL.String(), f)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.ContentLength = fi.Size()
+	req.Header.Set("Content-Type", webex.FileContentType(file))
+	s.sign(req, now)
+	if err := s.do(req); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiry := min(largeFileExpiry, maxPresignExpiry)
+	link := s.presign(objectURL, filepath.Base(file), now, expiry)
+	return link, now.Add(expiry), nil
+}
+
+// putWebDAV stores the file in the WebDAV collection of the store.
+func (s *fileStore) putWebDAV(ctx context.Context, body io.Reader, size int64, name string) (string, time.Time, error) {
+	u := *s.endpoint
+	u.User = nil
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + name
+	u.RawPath = ""
+	req, err := http.NewRequestWithContext(ctx, "PUT", u.String(), body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", webex.FileContentType(name))
+	if s.endpoint.User != nil {
+		password, _ := s.endpoint.User.Password()
+		req.SetBasicAuth(s.endpoint.User.Username(), password)
+	}
+	if err := s.do(req); err != nil {
+		return "", time.Time{}, err
+	}
+	return u.String(), time.Time{}, nil
+}
+
+func (s *fileStore) do(req *http.Request) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// objectURL returns the URL of the object key.
+func (s *fileStore) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	p := "/" + key
+	if s.pathStyle {
+		p = "/" + s.bucket + p
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + p
+	u.RawPath = sigV4Escape(u.Path, false)
+	return &u
+}
+
+// sign signs req with AWS Signature Version 4 in the Authorization header.
+// The payload is not signed, so the file is streamed.
+func (s *fileStore) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	if len(s.sessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": "UNSIGNED-PAYLOAD",
+		"x-amz-date":           amzDate,
+	}
+	if len(s.sessionToken) > 0 {
+		headers["x-amz-security-token"] = s.sessionToken
+	}
+	signed, signature := s.signature(req.Method, req.URL, headers, now)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, s.scope(now), signed, signature))
+}
+
+// presign returns the pre-signed GET URL of the object, which is downloaded
+// as filename.
+func (s *fileStore) presign(object *url.URL, filename string, now time.Time, expiry time.Duration) string {
+	u := *object
+	q := url.Values{}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(now))
+	q.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	q.Set("X-Amz-Expires", fmt.Sprint(int(expiry.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+	q.Set("response-content-disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if len(s.sessionToken) > 0 {
+		q.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	u.RawQuery = canonicalQuery(q)
+	_, signature := s.signature("GET", &u, map[string]string{"host": u.Host}, now)
+	u.RawQuery += "&X-Amz-Signature=" + signature
+	return u.String()
+}
+
+func (s *fileStore) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature returns the signed headers and the signature of a request with
+// an unsigned payload.
+func (s *fileStore) signature(method string, u *url.URL, headers map[string]string, now time.Time) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		sigV4Escape(u.Path, false),
+		canonicalQuery(u.Query()),
+		canonicalHeaders.String(),
+		signed,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		s.scope(now),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, part := range []string{now.Format("20060102"), s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return signed, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery returns the query sorted by key with the encoding of
+// Signature Version 4.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, sigV4Escape(k, true)+"="+sigV4Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// sigV4Escape percent-encodes everything but the unreserved characters of
+// RFC 3986, and "/" unless encodeSlash is set.
+func sigV4Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
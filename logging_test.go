@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestRedactAttr(t *testing.T) {
+	addSecret("logging-token-4711")
+	apiErr := &webex.APIError{StatusCode: 404, Message: "room not found for logging-token-4711", TrackingID: "ROUTER_1"}
+	for _, tt := range []struct {
+		name string
+		err  error
+		want interface{}
+	}{
+		{"plain", errors.New("open logging-token-4711: denied"), "open " + redacted + ": denied"},
+		{"webex", fmt.Errorf("sending: %w", apiErr), map[string]interface{}{
+			"message":      "sending: " + redact(apiErr.Error()),
+			"code":         float64(404),
+			"webexMessage": "room not found for " + redacted,
+			"trackingId":   "ROUTER_1",
+		}},
+		{"no tracking ID", &webex.APIError{StatusCode: 502, Body: "bad gateway"}, map[string]interface{}{
+			"message": (&webex.APIError{StatusCode: 502, Body: "bad gateway"}).Error(),
+			"code":    float64(502),
+		}},
+	} {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: redactAttr}))
+		logger.Error("failed", "error", tt.err)
+		var got map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got["error"], tt.want) {
+			t.Errorf("%s: logged error = %v, want %v", tt.name, got["error"], tt.want)
+		}
+	}
+}
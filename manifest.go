@@ -0,0 +1,221 @@
+// manifest.go
+//
+// Attachment manifests (flag --manifest). A YAML file lists files, each
+// with its own caption and target, which are all sent in one run:
+//
+//	team: KMP-Team
+//	room: Reports
+//	files:
+//	  - file: report.pdf
+//	    caption: "**nightly report**"
+//	  - file: graph.png
+//	    room: Capacity
+//	  - file: errors.csv
+//	    thread: <ID of the parent message>
+//	  - file: audit.pdf
+//	    email: auditor@example.com
+//
+// team, room and email on top are the defaults of the files, as are the
+// flags -t, -r and -D, which take precedence. thread sends the file as reply
+// to the message. Relative paths are relative to the manifest. All files are
+// checked before anything is sent, a failed upload does not stop the
+// others, and a summary of the files is printed at the end.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+var (
+	manifestKeys     = []string{"team", "room", "email", "files"}
+	manifestFileKeys = []string{"file", "caption", "team", "room", "email", "thread"}
+)
+
+// manifestEntry is a file of the manifest.
+type manifestEntry struct {
+	file, caption     string
+	team, room, email string
+	thread            string
+}
+
+// target returns the recipient of e for the output.
+func (e *manifestEntry) target() string {
+	if len(e.email) > 0 {
+		return e.email
+	}
+	return e.team + " / " + e.room
+}
+
+// readManifest reads the files of the manifest.
+func readManifest(file string) ([]*manifestEntry, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	m, ok := doc.(yamlMapping)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a mapping with the key files", file)
+	}
+	if err := checkYAMLKeys(file, m, manifestKeys); err != nil {
+		return nil, err
+	}
+
+	defaults := &manifestEntry{team: teamName, room: roomName, email: emailAddr}
+	if v, ok := m.Get("team"); ok && !flagGiven("t") {
+		defaults.team = yamlString(v)
+	}
+	if v, ok := m.Get("room"); ok && !flagGiven("r") {
+		defaults.room = yamlString(v)
+	}
+	if v, ok := m.Get("email"); ok && !flagGiven("D") {
+		defaults.email = yamlString(v)
+	}
+	v, _ := m.Get("files")
+	items, ok := v.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("%s: no files", file)
+	}
+
+	dir := filepath.Dir(file)
+	var entries []*manifestEntry
+	for i, item := range items {
+		e := *defaults
+		switch item := item.(type) {
+		case yamlMapping:
+			if err := checkYAMLKeys(fmt.Sprintf("%s: file %d", file, i+1), item, manifestFileKeys); err != nil {
+				return nil, err
+			}
+			// the recipient of the file replaces the recipient of the defaults
+			if _, ok := item.Get("email"); ok {
+				e.team, e.room = "", ""
+			}
+			if _, ok := item.Get("team"); ok {
+				e.email = ""
+			}
+			for _, p := range item {
+				value := yamlString(p.Value)
+				switch p.Key {
+				case "file":
+					e.file = value
+				case "caption":
+					e.caption = value
+				case "team":
+					e.team = value
+				case "room":
+					e.room = value
+				case "email":
+					e.email = value
+				case "thread":
+					e.thread = value
+				}
+			}
+		case []interface{}:
+			return nil, fmt.Errorf("%s: file %d: not a file name or mapping", file, i+1)
+		default:
+			e.file = yamlString(item)
+		}
+		if len(e.file) == 0 {
+			return nil, fmt.Errorf("%s: file %d: no file", file, i+1)
+		}
+		if !filepath.IsAbs(e.file) {
+			e.file = filepath.Join(dir, e.file)
+		}
+		if (len(e.team) == 0) == (len(e.email) == 0) {
+			return nil, fmt.Errorf("%s: %s: set either team or email", file, e.file)
+		}
+		if len(e.team) > 0 && len(e.room) == 0 {
+			return nil, fmt.Errorf("%s: %s: no room. set room or use flag -r", file, e.file)
+		}
+		if err := webex.CheckFile(e.file); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
+
+// sendManifest sends the files of the manifest and prints a summary.
+func sendManifest(file string) error {
+	if len(markdownMsg) > 0 || len(cardAttachment) > 0 || len(cardFile) > 0 || len(uploadFiles) > 0 {
+		return errors.New("--manifest can not be combined with -m, -i, -a, -A or -f. use caption in the manifest")
+	}
+	if err := checkChecksum(checksumAlgo); err != nil {
+		return err
+	}
+	entries, err := readManifest(file)
+	if err != nil {
+		return err
+	}
+	if showPreview || dryRun {
+		for _, e := range entries {
+			fmt.Printf("--- %s → %s\n", e.file, e.target())
+			if len(e.thread) > 0 {
+				fmt.Printf("reply to %s\n", e.thread)
+			}
+			if len(e.caption) > 0 {
+				fmt.Println(strings.TrimRight(e.caption, "\n"))
+			}
+		}
+		return nil
+	}
+	files := make([]string, len(entries))
+	for i, e := range entries {
+		files[i] = e.file
+	}
+	if err := preUploadCheck(files); err != nil {
+		return err
+	}
+
+	b := newBatch("manifest", "files", "FILE")
+	for _, e := range entries {
+		err := sendManifestEntry(e)
+		if err != nil {
+			slog.Error("sending file failed", "file", e.file, "to", e.target(), "error", err)
+		}
+		b.add(e.file, e.target(), err)
+	}
+	return b.finish()
+}
+
+// sendManifestEntry sends a file of the manifest.
+func sendManifestEntry(e *manifestEntry) error {
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	caption := e.caption
+	if !noEmoji {
+		caption = expandEmoji(caption)
+	}
+	if caption, err = withChecksums(caption, checksumAlgo, []string{e.file}); err != nil {
+		return err
+	}
+	m := &webex.MessageRequest{ToPersonEmail: e.email, ParentID: e.thread, Markdown: caption}
+	if len(e.team) > 0 {
+		if m.RoomID, err = lookupRoomID(ctx, e.team, e.room); err != nil {
+			return err
+		}
+	}
+	shrunk, cleanup, err := shrinkImage(e.file, int64(maxImageSize))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	sent, err := client.UploadFileWith(ctx, m, shrunk)
+	journalNotification(&notification{TeamName: e.team, RoomName: e.room, Email: e.email, Markdown: caption, File: e.file}, sent, err)
+	return err
+}
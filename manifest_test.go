@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"report.pdf", "graph.png", "audit.pdf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(team, room, email string) { teamName, roomName, emailAddr = team, room, email }(teamName, roomName, emailAddr)
+	teamName, roomName, emailAddr = "", "", ""
+
+	for _, tt := range []struct {
+		name, manifest string
+		want           []string
+		wantErr        string
+	}{
+		{name: "defaults", manifest: `
+team: KMP-Team
+room: Reports
+files:
+  - file: report.pdf
+    caption: "**nightly report**"
+  - file: graph.png
+    room: Capacity
+    thread: M1
+  - file: audit.pdf
+    email: auditor@example.com
+  - report.pdf
+`, want: []string{
+			"report.pdf KMP-Team / Reports **nightly report**",
+			"graph.png KMP-Team / Capacity thread M1",
+			"audit.pdf auditor@example.com",
+			"report.pdf KMP-Team / Reports",
+		}},
+		{name: "email default", manifest: "email: auditor@example.com\nfiles:\n  - audit.pdf\n  - file: report.pdf\n    team: KMP-Team\n    room: Reports\n",
+			want: []string{"audit.pdf auditor@example.com", "report.pdf KMP-Team / Reports"}},
+		{name: "no files", manifest: "team: KMP-Team\nroom: Reports\n", wantErr: "no files"},
+		{name: "unknown key", manifest: "team: KMP-Team\nroom: Reports\nfiles:\n  - file: report.pdf\n    title: x\n", wantErr: "title"},
+		{name: "no recipient", manifest: "files:\n  - report.pdf\n", wantErr: "set either team or email"},
+		{name: "no room", manifest: "team: KMP-Team\nfiles:\n  - report.pdf\n", wantErr: "no room"},
+		{name: "missing file", manifest: "team: KMP-Team\nroom: Reports\nfiles:\n  - missing.pdf\n", wantErr: "missing.pdf"},
+		{name: "nested list", manifest: "team: KMP-Team\nroom: Reports\nfiles:\n  - [a, b]\n", wantErr: "not a file name or mapping"},
+		{name: "no mapping", manifest: "- report.pdf\n", wantErr: "not a mapping"},
+	} {
+		file := filepath.Join(dir, "manifest.yaml")
+		if err := os.WriteFile(file, []byte(tt.manifest), 0600); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := readManifest(file)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: readManifest() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: readManifest() error = %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, e := range entries {
+			rel, _ := filepath.Rel(dir, e.file)
+			s := rel + " " + e.target()
+			if len(e.caption) > 0 {
+				s += " " + e.caption
+			}
+			if len(e.thread) > 0 {
+				s += " thread " + e.thread
+			}
+			got = append(got, s)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: readManifest() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// meeting.go
+//
+// Command meeting create. Schedules a Webex meeting starting now and posts
+// the join details as a card to the room, e.g. to open an incident bridge
+// with a single command. The Meetings API needs a user or integration token
+// (--oauth-login with scope meeting:schedules_write), bots cannot create
+// meetings.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func runMeetingCreate() error {
+	if len(teamName) == 0 && len(emailAddr) == 0 {
+		return errors.New("no recipient. use flag -t and -r or flag -D")
+	}
+	if meetingDuration <= 0 {
+		return errors.New("the meeting duration must be positive")
+	}
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	// meetings start at full minutes, participants may join before the host
+	start := time.Now().Add(time.Minute).Truncate(time.Minute)
+	meeting, err := client.CreateMeeting(ctx, &webex.MeetingRequest{
+		Title:                 meetingTitle,
+		Start:                 start,
+		End:                   start.Add(meetingDuration),
+		EnabledJoinBeforeHost: true,
+		JoinBeforeHostMinutes: 15,
+	})
+	if err != nil {
+		var apiErr *webex.APIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusForbidden || apiErr.StatusCode == http.StatusUnauthorized) {
+			return fmt.Errorf("creating the meeting failed, bots cannot create meetings. use an integration token with scope meeting:schedules_write: %v", err)
+		}
+		return err
+	}
+
+	card, err := meetingCard(meeting)
+	if err != nil {
+		return err
+	}
+	markdown := trf("**%s** join: %s (meeting number %s, password %s)", meeting.Title, meeting.WebLink, meeting.MeetingNumber, meeting.Password)
+	if len(markdownMsg) > 0 {
+		markdown = markdownMsg + "\n\n" + markdown
+	}
+	err = sendNotification(ctx, &notification{
+		TeamName: teamName,
+		RoomName: roomName,
+		Email:    emailAddr,
+		Markdown: markdown,
+		Card:     card,
+		Mentions: mentionEmails,
+	})
+	if err != nil {
+		return fmt.Errorf("meeting %s created, but posting the join details failed: %v", meeting.WebLink, err)
+	}
+	fmt.Println(meeting.WebLink)
+	return nil
+}
+
+// meetingCard returns the card attachment with the join details of meeting.
+func meetingCard(meeting *webex.Meeting) (string, error) {
+	facts := []interface{}{
+		map[string]interface{}{"title": tr("Start"), "value": formatTime(meeting.Start.Local())},
+		map[string]interface{}{"title": tr("End"), "value": formatTime(meeting.End.Local())},
+		map[string]interface{}{"title": tr("Meeting number"), "value": meeting.MeetingNumber},
+	}
+	if len(meeting.Password) > 0 {
+		facts = append(facts, map[string]interface{}{"title": tr("Password"), "value": meeting.Password})
+	}
+	if len(meeting.SIPAddress) > 0 {
+		facts = append(facts, map[string]interface{}{"title": tr("Video address"), "value": meeting.SIPAddress})
+	}
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": "1.3",
+		"body": []interface{}{
+			map[string]interface{}{"type": "TextBlock", "text": meeting.Title, "size": "Large", "weight": "Bolder", "wrap": true},
+			map[string]interface{}{"type": "FactSet", "facts": facts},
+		},
+		"actions": []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": tr("Join meeting"), "url": meeting.WebLink},
+		},
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestMeetingCard(t *testing.T) {
+	meeting := &webex.Meeting{Title: "INC-42 bridge", MeetingNumber: "265012345", Password: "Xy32345",
+		Start: time.Now(), End: time.Now().Add(time.Hour), WebLink: "https://example.webex.com/meet/j.php?MTID=m1"}
+	for _, tt := range []struct {
+		sip  string
+		want []string
+	}{
+		{"", []string{`"title":"Meeting number","value":"265012345"`, `"title":"Password","value":"Xy32345"`,
+			`{"title":"Join meeting","type":"Action.OpenUrl","url":"https://example.webex.com/meet/j.php?MTID=m1"}`}},
+		{"265012345@example.webex.com", []string{`"title":"Video address","value":"265012345@example.webex.com"`}},
+	} {
+		meeting.SIPAddress = tt.sip
+		card, err := meetingCard(meeting)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(card, want) {
+				t.Errorf("meetingCard() = %s, want %s", card, want)
+			}
+		}
+		if got := strings.Contains(card, "Video address"); got != (len(tt.sip) > 0) {
+			t.Errorf("meetingCard() with SIP address %q = %s", tt.sip, card)
+		}
+	}
+}
+
+func TestRunMeetingCreate(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(team, room, email, title, msg string, duration time.Duration) {
+		teamName, roomName, emailAddr, meetingTitle, markdownMsg, meetingDuration = team, room, email, title, msg, duration
+	}(teamName, roomName, emailAddr, meetingTitle, markdownMsg, meetingDuration)
+	teamName, roomName, emailAddr, meetingTitle, markdownMsg, meetingDuration = "KMP-Team", "Incidents", "", "INC-42 bridge", "database down", time.Hour
+
+	var err error
+	out := captureStdout(t, func() { err = runMeetingCreate() })
+	meetings := fake.Meetings()
+	if err != nil || len(meetings) != 1 || out != meetings[0].WebLink+"\n" {
+		t.Fatalf("runMeetingCreate() = %v, output %q, meetings %+v", err, out, meetings)
+	}
+	m := meetings[0]
+	if m.Title != "INC-42 bridge" || m.End.Sub(m.Start) != time.Hour || m.Start.Second() != 0 || m.Start.Before(time.Now()) {
+		t.Errorf("meeting = %+v, want an hour from the next minute", m)
+	}
+	messages := fake.Messages()
+	if len(messages) != 1 || len(messages[0].Attachments) != 1 ||
+		messages[0].Markdown != "database down\n\n**INC-42 bridge** join: "+m.WebLink+" (meeting number "+m.MeetingNumber+", password "+m.Password+")" {
+		t.Errorf("messages = %+v, want the join details", messages)
+	}
+
+	fake.Fail(1, http.StatusForbidden)
+	if err := runMeetingCreate(); err == nil || !strings.HasPrefix(err.Error(), "creating the meeting failed, bots cannot create meetings") {
+		t.Errorf("runMeetingCreate() of a bot error = %v", err)
+	}
+	for _, tt := range []struct {
+		team     string
+		duration time.Duration
+		wantErr  string
+	}{
+		{"", time.Hour, "no recipient. use flag -t and -r or flag -D"},
+		{"KMP-Team", 0, "the meeting duration must be positive"},
+	} {
+		teamName, meetingDuration = tt.team, tt.duration
+		if err := runMeetingCreate(); errorString(err) != tt.wantErr {
+			t.Errorf("runMeetingCreate(%q, %s) error = %v, want %s", tt.team, tt.duration, err, tt.wantErr)
+		}
+	}
+}
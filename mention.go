@@ -0,0 +1,79 @@
+// mention.go
+//
+// @mentions (flag --mention). The email addresses are resolved to person
+// IDs and injected as <@personId:...> markup in front of the message, so the
+// mentioned people are actually notified.
+//
+// The group mention <@all> is only sent with flag --mention-all, which needs
+// a confirmation. <@all> markup in message texts, e.g. rendered from
+// templates or MQTT payloads, is neutralized.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// withMentions returns markdown prefixed with mentions of the people with
+// the given email addresses.
+func withMentions(ctx context.Context, client *webex.Client, emails []string, markdown string) (string, error) {
+	if len(emails) == 0 {
+		return markdown, nil
+	}
+	var mentions []string
+	for _, email := range emails {
+		p, err := client.PersonByEmail(ctx, email)
+		if err != nil {
+			return "", err
+		}
+		mentions = append(mentions, mentionMarkup(p))
+	}
+	return strings.Join(mentions, " ") + " " + markdown, nil
+}
+
+func mentionMarkup(p *webex.Person) string {
+	name := p.DisplayName
+	if len(name) == 0 && len(p.Emails) > 0 {
+		name = p.Emails[0]
+	}
+	// "|" and ">" would end the markup early
+	name = strings.NewReplacer("|", " ", ">", " ").Replace(name)
+	return fmt.Sprintf("<@personId:%s|%s>", p.ID, name)
+}
+
+var mentionAllPattern = regexp.MustCompile(`(?i)<@all>`)
+
+// withMentionAll neutralizes <@all> markup in markdown and puts the group
+// mention in front of it if mentionAll is set.
+func withMentionAll(mentionAll bool, markdown string) string {
+	markdown = mentionAllPattern.ReplaceAllString(markdown, "@all")
+	if mentionAll {
+		return "<@all> " + markdown
+	}
+	return markdown
+}
+
+// confirmMentionAll returns an error unless the group mention is confirmed
+// by flag --confirm-mention-all or interactively on the terminal.
+func confirmMentionAll(room string) error {
+	if confirmAll {
+		return nil
+	}
+	if !isTerminal(os.Stdin) {
+		return errors.New("--mention-all notifies everyone in the room. confirm with flag --confirm-mention-all")
+	}
+	fmt.Fprintf(os.Stderr, "notify everyone in room %q with @all? [y/N] ", room)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errors.New("@all mention not confirmed")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestWithMentions(t *testing.T) {
+	fake := useFakeWebex(t)
+	alice := fake.AddPerson("alice@example.com", "Alice Smith")
+	bob := fake.AddPerson("bob@example.com", "Bob | Ops>")
+	client, err := webexClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := withMentions(context.Background(), client, []string{"alice@example.com", "bob@example.com"}, "disk full")
+	want := "<@personId:" + alice.ID + "|Alice Smith> <@personId:" + bob.ID + "|Bob   Ops > disk full"
+	if err != nil || got != want {
+		t.Errorf("withMentions() = %q, %v, want %q", got, err, want)
+	}
+	if got, err := withMentions(context.Background(), client, nil, "disk full"); err != nil || got != "disk full" {
+		t.Errorf("withMentions() without emails = %q, %v", got, err)
+	}
+	if _, err := withMentions(context.Background(), client, []string{"nobody@example.com"}, "disk full"); err == nil {
+		t.Errorf("withMentions() of an unknown person: error = nil")
+	}
+}
+
+func TestMentionMarkup(t *testing.T) {
+	for _, tt := range []struct {
+		p    webex.Person
+		want string
+	}{
+		{webex.Person{ID: "P1", DisplayName: "Alice Smith"}, "<@personId:P1|Alice Smith>"},
+		{webex.Person{ID: "P2", Emails: []string{"bob@example.com"}}, "<@personId:P2|bob@example.com>"},
+		{webex.Person{ID: "P3", DisplayName: "a|b>c"}, "<@personId:P3|a b c>"},
+	} {
+		if got := mentionMarkup(&tt.p); got != tt.want {
+			t.Errorf("mentionMarkup(%+v) = %q, want %q", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestWithMentionAll(t *testing.T) {
+	for _, tt := range []struct {
+		mentionAll bool
+		markdown   string
+		want       string
+	}{
+		{false, "disk full", "disk full"},
+		{true, "disk full", "<@all> disk full"},
+		{false, "from MQTT: <@all> <@ALL>", "from MQTT: @all @all"},
+		{true, "<@all> twice", "<@all> @all twice"},
+	} {
+		if got := withMentionAll(tt.mentionAll, tt.markdown); got != tt.want {
+			t.Errorf("withMentionAll(%v, %q) = %q, want %q", tt.mentionAll, tt.markdown, got, tt.want)
+		}
+	}
+}
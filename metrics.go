@@ -0,0 +1,214 @@
+// metrics.go
+//
+// Prometheus metrics of the long-running modes in the text exposition
+// format. The relay (serve) serves them at /metrics, the MQTT bridge and the
+// daemon with flag --metrics-listen. Counted are the sent notifications, the
+// failures by status code, the API requests with their latency, retries and
+// rate limit hits and the state of the circuit breaker (see circuit.go).
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// upper bounds of the request duration histogram in seconds
+var durationBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(seconds float64) {
+	for i, le := range durationBuckets {
+		if seconds <= le {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+type requestKey struct {
+	resource string
+	code     string
+}
+
+var metrics struct {
+	sync.Mutex
+	sent        uint64
+	suppressed  uint64
+	failures    map[string]uint64
+	requests    map[requestKey]uint64
+	retries     uint64
+	rateLimited uint64
+	durations   map[string]*histogram
+}
+
+// countNotification counts a sent notification or its failure by status
+// code. Failures without API response have the code "error".
+func countNotification(err error) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	if err == nil {
+		metrics.sent++
+		return
+	}
+	code := "error"
+	var apiErr *webex.APIError
+	if errors.As(err, &apiErr) {
+		code = strconv.Itoa(apiErr.StatusCode)
+	}
+	if metrics.failures == nil {
+		metrics.failures = make(map[string]uint64)
+	}
+	metrics.failures[code]++
+}
+
+// countSuppressed counts a message skipped as duplicate, see --dedupe.
+func countSuppressed() {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.suppressed++
+}
+
+// observeRequest is the webex.Client.OnRequest hook.
+func observeRequest(info *webex.RequestInfo) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	code := "error"
+	if info.StatusCode > 0 {
+		code = strconv.Itoa(info.StatusCode)
+	}
+	if metrics.requests == nil {
+		metrics.requests = make(map[requestKey]uint64)
+		metrics.durations = make(map[string]*histogram)
+	}
+	metrics.requests[requestKey{info.Resource, code}]++
+	if info.Attempt > 1 {
+		metrics.retries++
+	}
+	if info.StatusCode == http.StatusTooManyRequests {
+		metrics.rateLimited++
+	}
+	h := metrics.durations[info.Resource]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		metrics.durations[info.Resource] = h
+	}
+	h.observe(info.Duration.Seconds())
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w)
+}
+
+func writeMetrics(w io.Writer) {
+	metrics.Lock()
+	defer metrics.Unlock()
+
+	fmt.Fprintln(w, "# HELP notify_messages_sent_total Notifications sent to Webex.")
+	fmt.Fprintln(w, "# TYPE notify_messages_sent_total counter")
+	fmt.Fprintf(w, "notify_messages_sent_total %d\n", metrics.sent)
+
+	fmt.Fprintln(w, "# HELP notify_messages_suppressed_total Duplicate notifications not sent, see --dedupe.")
+	fmt.Fprintln(w, "# TYPE notify_messages_suppressed_total counter")
+	fmt.Fprintf(w, "notify_messages_suppressed_total %d\n", metrics.suppressed)
+
+	fmt.Fprintln(w, "# HELP notify_send_failures_total Notifications not sent, by status code of the API.")
+	fmt.Fprintln(w, "# TYPE notify_send_failures_total counter")
+	codes := make([]string, 0, len(metrics.failures))
+	for code := range metrics.failures {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "notify_send_failures_total{code=%q} %d\n", code, metrics.failures[code])
+	}
+
+	fmt.Fprintln(w, "# HELP notify_api_requests_total Requests to the Webex API, by resource and status code.")
+	fmt.Fprintln(w, "# TYPE notify_api_requests_total counter")
+	keys := make([]requestKey, 0, len(metrics.requests))
+	for k := range metrics.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].resource != keys[j].resource {
+			return keys[i].resource < keys[j].resource
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(w, "notify_api_requests_total{resource=%q,code=%q} %d\n", k.resource, k.code, metrics.requests[k])
+	}
+
+	fmt.Fprintln(w, "# HELP notify_api_retries_total Requests to the Webex API sent again.")
+	fmt.Fprintln(w, "# TYPE notify_api_retries_total counter")
+	fmt.Fprintf(w, "notify_api_retries_total %d\n", metrics.retries)
+
+	fmt.Fprintln(w, "# HELP notify_api_rate_limited_total Requests answered with 429 Too Many Requests.")
+	fmt.Fprintln(w, "# TYPE notify_api_rate_limited_total counter")
+	fmt.Fprintf(w, "notify_api_rate_limited_total %d\n", metrics.rateLimited)
+
+	open, opens := circuit.state()
+	gauge := 0
+	if open {
+		gauge = 1
+	}
+	fmt.Fprintln(w, "# HELP notify_circuit_open 1 while the circuit is open and the sends are paused after repeated API failures.")
+	fmt.Fprintln(w, "# TYPE notify_circuit_open gauge")
+	fmt.Fprintf(w, "notify_circuit_open %d\n", gauge)
+	fmt.Fprintln(w, "# HELP notify_circuit_opens_total Openings of the circuit.")
+	fmt.Fprintln(w, "# TYPE notify_circuit_opens_total counter")
+	fmt.Fprintf(w, "notify_circuit_opens_total %d\n", opens)
+
+	fmt.Fprintln(w, "# HELP notify_api_request_duration_seconds Duration of the requests to the Webex API.")
+	fmt.Fprintln(w, "# TYPE notify_api_request_duration_seconds histogram")
+	resources := make([]string, 0, len(metrics.durations))
+	for resource := range metrics.durations {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		h := metrics.durations[resource]
+		for i, le := range durationBuckets {
+			fmt.Fprintf(w, "notify_api_request_duration_seconds_bucket{resource=%q,le=\"%g\"} %d\n", resource, le, h.counts[i])
+		}
+		fmt.Fprintf(w, "notify_api_request_duration_seconds_bucket{resource=%q,le=\"+Inf\"} %d\n", resource, h.count)
+		fmt.Fprintf(w, "notify_api_request_duration_seconds_sum{resource=%q} %g\n", resource, h.sum)
+		fmt.Fprintf(w, "notify_api_request_duration_seconds_count{resource=%q} %d\n", resource, h.count)
+	}
+}
+
+// startMetricsServer serves /metrics and the probes on listen in the
+// background, for the modes without HTTP server of their own.
+func startMetricsServer(listen string) error {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	slog.Info("serving metrics", "address", ln.Addr().String())
+	go func() {
+		err := srv.Serve(ln)
+		slog.Error("metrics server stopped", "error", err)
+	}()
+	return nil
+}
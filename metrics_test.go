@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// resetMetrics sets all metrics to zero.
+func resetMetrics() {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.sent, metrics.suppressed, metrics.retries, metrics.rateLimited = 0, 0, 0, 0
+	metrics.failures, metrics.requests, metrics.durations = nil, nil, nil
+}
+
+func TestWriteMetrics(t *testing.T) {
+	resetMetrics()
+	defer resetMetrics()
+	countNotification(nil)
+	countNotification(nil)
+	countNotification(&webex.APIError{StatusCode: 403})
+	countNotification(errors.New("connection refused"))
+	countSuppressed()
+	observeRequest(&webex.RequestInfo{Resource: "messages", StatusCode: 429, Duration: 250 * time.Millisecond, Attempt: 1})
+	observeRequest(&webex.RequestInfo{Resource: "messages", StatusCode: 200, Duration: 500 * time.Millisecond, Attempt: 2})
+	observeRequest(&webex.RequestInfo{Resource: "rooms", Duration: 20 * time.Second, Attempt: 1})
+
+	var buf bytes.Buffer
+	writeMetrics(&buf)
+	for _, want := range []string{
+		"notify_messages_sent_total 2\n",
+		"notify_messages_suppressed_total 1\n",
+		"notify_send_failures_total{code=\"403\"} 1\nnotify_send_failures_total{code=\"error\"} 1\n",
+		"notify_api_requests_total{resource=\"messages\",code=\"200\"} 1\nnotify_api_requests_total{resource=\"messages\",code=\"429\"} 1\nnotify_api_requests_total{resource=\"rooms\",code=\"error\"} 1\n",
+		"notify_api_retries_total 1\n",
+		"notify_api_rate_limited_total 1\n",
+		"notify_circuit_open 0\n",
+		"notify_api_request_duration_seconds_bucket{resource=\"messages\",le=\"0.25\"} 1\n",
+		"notify_api_request_duration_seconds_bucket{resource=\"messages\",le=\"0.5\"} 2\n",
+		"notify_api_request_duration_seconds_bucket{resource=\"rooms\",le=\"10\"} 0\nnotify_api_request_duration_seconds_bucket{resource=\"rooms\",le=\"+Inf\"} 1\n",
+		"notify_api_request_duration_seconds_sum{resource=\"messages\"} 0.75\n",
+		"notify_api_request_duration_seconds_count{resource=\"messages\"} 2\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestRetryMetrics(t *testing.T) {
+	fake := useFakeWebex(t)
+	room := fake.AddRoom("Alerts", "", "group")
+	resetMetrics()
+	defer resetMetrics()
+	defer func(retries int, wait time.Duration) { maxRetries, retryInitWait = retries, wait }(maxRetries, retryInitWait)
+	maxRetries, retryInitWait = 2, time.Millisecond
+
+	client, err := webexClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.RateLimit(2, 0)
+	_, err = client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: room.ID, Markdown: "disk full"})
+	countNotification(err)
+	if err != nil || len(fake.Messages()) != 1 {
+		t.Fatalf("CreateMessage() after 2 rate limited tries = %v, %d messages", err, len(fake.Messages()))
+	}
+	fake.RateLimit(3, 0)
+	_, err = client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: room.ID, Markdown: "disk full"})
+	countNotification(err)
+	var apiErr *webex.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("CreateMessage() after 3 rate limited tries = %v, want 429", err)
+	}
+
+	var buf bytes.Buffer
+	writeMetrics(&buf)
+	for _, want := range []string{
+		"notify_messages_sent_total 1\n",
+		"notify_send_failures_total{code=\"429\"} 1\n",
+		"notify_api_requests_total{resource=\"messages\",code=\"200\"} 1\nnotify_api_requests_total{resource=\"messages\",code=\"429\"} 5\n",
+		"notify_api_retries_total 4\n",
+		"notify_api_rate_limited_total 5\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,467 @@
+// mqtt.go
+//
+// MQTT subscribe-and-notify mode. Connects to an MQTT broker, subscribes to
+// one or more topic filters and posts every received payload (raw or rendered
+// through a text/template) to the Webex room given by -t/-r or to -D.
+//
+// The messages are sent like the notifications of the other receivers, see
+// sendOrSpool. With QoS 1 the session is kept by the broker and a message is
+// acknowledged only after it was sent or spooled, so the broker sends a
+// message again which could not be delivered.
+//
+// Only the subset of MQTT 3.1.1 needed for subscribing is implemented
+// (CONNECT, SUBSCRIBE, PUBLISH with QoS 0/1, PINGREQ), so no 3rd party
+// library is required.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const (
+	mqttConnect    = 1
+	mqttConnack    = 2
+	mqttPublish    = 3
+	mqttPuback     = 4
+	mqttSubscribe  = 8
+	mqttSuback     = 9
+	mqttPingreq    = 12
+	mqttPingresp   = 13
+	mqttDisconnect = 14
+
+	mqttKeepAlive = 60 * time.Second
+
+	// return code of SUBACK for a refused topic filter
+	mqttSubscribeFailure = 0x80
+)
+
+// errMQTTRefused is returned if the broker refuses a subscription, which
+// reconnecting does not fix.
+var errMQTTRefused = errors.New("MQTT subscription refused")
+
+// mqttMessage is the data passed to the --mqtt-template template.
+type mqttMessage struct {
+	Topic   string
+	Payload string
+	// JSON holds the decoded payload if it is valid JSON, otherwise nil
+	JSON interface{}
+}
+
+type mqttBridge struct {
+	broker   *url.URL
+	topics   []string
+	qos      byte
+	clientID string
+	tmpl     *template.Template
+
+	conn     net.Conn
+	reader   *bufio.Reader
+	packetID uint16
+}
+
+// runMQTTBridge subscribes to the configured topics and forwards messages
+// to Webex until the shutdown is requested. Lost broker connections are
+// re-established with an increasing delay.
+func runMQTTBridge() error {
+	broker, err := url.Parse(mqttBroker)
+	if err != nil {
+		return err
+	}
+	if len(mqttTopics) == 0 {
+		return errors.New("no MQTT topic. use flag --topic")
+	}
+	if mqttQoS > 1 {
+		return fmt.Errorf("unsupported MQTT QoS %d. use 0 or 1", mqttQoS)
+	}
+
+	b := &mqttBridge{
+		broker:   broker,
+		topics:   mqttTopics,
+		qos:      byte(mqttQoS),
+		clientID: mqttClientID,
+	}
+	if len(b.clientID) == 0 {
+		hostname, _ := os.Hostname()
+		b.clientID = fmt.Sprintf("notify_by_webex_teams-%s-%d", hostname, os.Getpid())
+	}
+	if len(mqttTemplate) > 0 {
+		b.tmpl, err = template.New("mqtt").Funcs(templateFuncs).Parse(mqttTemplate)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(teamName) > 0 {
+		// fail at once for a wrong room, the ID is cached for the messages
+		ctx, cancel := requestContext()
+		roomID, err := lookupRoomID(ctx, teamName, roomName)
+		cancel()
+		if err != nil {
+			return err
+		}
+		slog.Debug("MQTT target room", "roomID", roomID)
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+	if useSpool {
+		go runSpoolFlusher(ctx)
+	}
+	go runTokenReloader(ctx)
+	delay := time.Second
+	for {
+		err = b.run(ctx)
+		if ctx.Err() != nil {
+			finishShutdown()
+			slog.Info("MQTT bridge stopped")
+			return nil
+		}
+		if errors.Is(err, errMQTTRefused) {
+			finishShutdown()
+			return err
+		}
+		slog.Warn("MQTT connection lost", "broker", broker.Host, "error", err, "reconnectIn", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		if delay < time.Minute {
+			delay *= 2
+		}
+	}
+}
+
+// run connects, subscribes and processes incoming packets until an error
+// occurs or ctx is canceled. A message being forwarded is finished first.
+func (b *mqttBridge) run(ctx context.Context) error {
+	if err := b.connect(); err != nil {
+		return err
+	}
+	defer b.conn.Close()
+
+	// held while a message is forwarded
+	var forwarding sync.Mutex
+	disconnect := context.AfterFunc(ctx, func() {
+		forwarding.Lock()
+		defer forwarding.Unlock()
+		b.writePacket(mqttDisconnect<<4, nil)
+		b.conn.Close()
+	})
+	defer disconnect()
+
+	if err := b.subscribe(); err != nil {
+		return err
+	}
+
+	go b.keepAlive(b.conn)
+
+	for {
+		b.conn.SetReadDeadline(time.Now().Add(2 * mqttKeepAlive))
+		packetType, flags, body, err := b.readPacket()
+		if err != nil {
+			return err
+		}
+		switch packetType {
+		case mqttPublish:
+			forwarding.Lock()
+			err := b.handlePublish(flags, body)
+			forwarding.Unlock()
+			if err != nil {
+				return err
+			}
+		case mqttSuback:
+			if err := b.checkSuback(body); err != nil {
+				return err
+			}
+		case mqttPingresp:
+		default:
+			slog.Debug("MQTT packet ignored", "type", packetType)
+		}
+	}
+}
+
+func (b *mqttBridge) connect() error {
+	dial, err := dialContext(proxyString)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	host := b.broker.Host
+	switch b.broker.Scheme {
+	case "tcp", "mqtt":
+		if len(b.broker.Port()) == 0 {
+			host = net.JoinHostPort(b.broker.Hostname(), "1883")
+		}
+		b.conn, err = dial(ctx, "tcp", host)
+	case "ssl", "tls", "mqtts":
+		if len(b.broker.Port()) == 0 {
+			host = net.JoinHostPort(b.broker.Hostname(), "8883")
+		}
+		var tlsConfig *tls.Config
+		tlsConfig, err = newTLSConfig()
+		if err != nil {
+			return err
+		}
+		tlsConfig.ServerName = b.broker.Hostname()
+		var conn net.Conn
+		conn, err = dial(ctx, "tcp", host)
+		if err != nil {
+			return err
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+		b.conn = tlsConn
+	default:
+		return fmt.Errorf("unsupported MQTT broker scheme: %s", b.broker.Scheme)
+	}
+	if err != nil {
+		return err
+	}
+	b.reader = bufio.NewReader(b.conn)
+
+	var connectFlags byte
+	if b.qos == 0 {
+		// with QoS 1 the broker keeps the messages not acknowledged
+		connectFlags |= 0x02 // clean session
+	}
+	payload := new(bytes.Buffer)
+	writeMQTTString(payload, b.clientID)
+	if b.broker.User != nil {
+		connectFlags |= 0x80
+		writeMQTTString(payload, b.broker.User.Username())
+		if password, ok := b.broker.User.Password(); ok {
+			connectFlags |= 0x40
+			writeMQTTString(payload, password)
+		}
+	}
+
+	body := new(bytes.Buffer)
+	writeMQTTString(body, "MQTT")
+	body.WriteByte(4) // protocol level 3.1.1
+	body.WriteByte(connectFlags)
+	binary.Write(body, binary.BigEndian, uint16(mqttKeepAlive/time.Second))
+	body.Write(payload.Bytes())
+
+	if err := b.writePacket(mqttConnect<<4, body.Bytes()); err != nil {
+		b.conn.Close()
+		return err
+	}
+
+	b.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	packetType, _, ack, err := b.readPacket()
+	if err != nil {
+		b.conn.Close()
+		return err
+	}
+	if packetType != mqttConnack || len(ack) < 2 {
+		b.conn.Close()
+		return fmt.Errorf("unexpected MQTT packet type %d instead of CONNACK", packetType)
+	}
+	if ack[1] != 0 {
+		b.conn.Close()
+		return fmt.Errorf("MQTT connection refused, return code %d", ack[1])
+	}
+	return nil
+}
+
+func (b *mqttBridge) subscribe() error {
+	b.packetID++
+	body := new(bytes.Buffer)
+	binary.Write(body, binary.BigEndian, b.packetID)
+	for _, topic := range b.topics {
+		writeMQTTString(body, topic)
+		body.WriteByte(b.qos)
+	}
+	return b.writePacket(mqttSubscribe<<4|0x02, body.Bytes())
+}
+
+// checkSuback checks the return codes of the SUBACK packet, one per topic
+// filter of the subscription.
+func (b *mqttBridge) checkSuback(body []byte) error {
+	if len(body) != 2+len(b.topics) || binary.BigEndian.Uint16(body) != b.packetID {
+		return errors.New("malformed MQTT SUBACK packet")
+	}
+	for i, code := range body[2:] {
+		switch {
+		case code == mqttSubscribeFailure:
+			return fmt.Errorf("%w: %s", errMQTTRefused, b.topics[i])
+		case code < b.qos:
+			slog.Warn("MQTT broker granted a lower QoS", "topic", b.topics[i], "qos", code)
+		}
+	}
+	slog.Info("MQTT subscribed", "broker", b.broker.Host, "topics", strings.Join(b.topics, ", "))
+	return nil
+}
+
+// keepAlive sends PINGREQ packets on conn until writing fails, which
+// happens as soon as run() closes the connection.
+func (b *mqttBridge) keepAlive(conn net.Conn) {
+	ticker := time.NewTicker(mqttKeepAlive / 2)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := conn.Write(encodeMQTTPacket(mqttPingreq<<4, nil)); err != nil {
+			return
+		}
+	}
+}
+
+func (b *mqttBridge) handlePublish(flags byte, body []byte) error {
+	if len(body) < 2 {
+		return errors.New("malformed MQTT PUBLISH packet")
+	}
+	topicLen := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+topicLen {
+		return errors.New("malformed MQTT PUBLISH packet")
+	}
+	topic := string(body[2 : 2+topicLen])
+	rest := body[2+topicLen:]
+
+	var packetID []byte
+	if qos := (flags >> 1) & 0x03; qos > 0 {
+		if len(rest) < 2 {
+			return errors.New("malformed MQTT PUBLISH packet")
+		}
+		packetID = rest[:2]
+		rest = rest[2:]
+	}
+
+	if err := b.forward(topic, rest); err != nil {
+		if packetID != nil {
+			// not acknowledged, the broker sends it again after the reconnect
+			return fmt.Errorf("sending MQTT message of %s failed: %w", topic, err)
+		}
+		slog.Error("sending MQTT message failed", "topic", topic, "error", err)
+		return nil
+	}
+	if packetID != nil {
+		return b.writePacket(mqttPuback<<4, packetID)
+	}
+	return nil
+}
+
+// forward sends the payload received on topic. A payload the template
+// fails for is dropped, it would fail again.
+func (b *mqttBridge) forward(topic string, payload []byte) error {
+	text, err := b.render(topic, payload)
+	if err != nil {
+		slog.Error("rendering MQTT message failed", "topic", topic, "error", err)
+		return nil
+	}
+	if len(strings.TrimSpace(text)) == 0 {
+		return nil
+	}
+	return b.deliver(text)
+}
+
+// render builds the Webex markdown message for a received payload.
+func (b *mqttBridge) render(topic string, payload []byte) (string, error) {
+	if b.tmpl == nil {
+		return string(payload), nil
+	}
+	m := mqttMessage{Topic: topic, Payload: string(payload)}
+	var data interface{}
+	if json.Unmarshal(payload, &data) == nil {
+		m.JSON = data
+	}
+	out := new(bytes.Buffer)
+	if err := b.tmpl.Execute(out, m); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// deliver sends text like the notifications of the other receivers. A
+// message spooled with --spool counts as delivered.
+func (b *mqttBridge) deliver(text string) error {
+	n := &notification{TeamName: teamName, RoomName: roomName, Email: emailAddr, Markdown: text,
+		Mentions: mentionEmails, Severity: severityName, Ack: ackButton}
+	if err := applyFooter(n); err != nil {
+		slog.Error("adding the footer to the MQTT message failed", "error", err)
+	}
+	if digestWindow > 0 {
+		queueDigest(n)
+		return nil
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	return sendOrSpool(ctx, n)
+}
+
+func (b *mqttBridge) writePacket(header byte, body []byte) error {
+	_, err := b.conn.Write(encodeMQTTPacket(header, body))
+	return err
+}
+
+func encodeMQTTPacket(header byte, body []byte) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(header)
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		buf.WriteByte(digit)
+		if length == 0 {
+			break
+		}
+	}
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func (b *mqttBridge) readPacket() (byte, byte, []byte, error) {
+	header, err := b.reader.ReadByte()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	length := 0
+	multiplier := 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, 0, nil, errors.New("malformed MQTT remaining length")
+		}
+		digit, err := b.reader.ReadByte()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(b.reader, body); err != nil {
+		return 0, 0, nil, err
+	}
+	return header >> 4, header & 0x0f, body, nil
+}
+
+func writeMQTTString(w *bytes.Buffer, s string) {
+	binary.Write(w, binary.BigEndian, uint16(len(s)))
+	w.WriteString(s)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestMQTTPacket(t *testing.T) {
+	const header = mqttPublish<<4 | 0x02
+	for _, tt := range []struct {
+		length  int
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	} {
+		body := bytes.Repeat([]byte{'x'}, tt.length)
+		packet := encodeMQTTPacket(header, body)
+		want := append([]byte{header}, tt.encoded...)
+		if !bytes.HasPrefix(packet, want) || len(packet) != len(want)+tt.length {
+			t.Errorf("encodeMQTTPacket() of %d bytes = % x..., want % x", tt.length, packet[:len(want)], want)
+			continue
+		}
+
+		b := &mqttBridge{reader: bufio.NewReader(bytes.NewReader(packet))}
+		typ, flags, got, err := b.readPacket()
+		if err != nil || typ != mqttPublish || flags != 0x02 || !bytes.Equal(got, body) {
+			t.Errorf("readPacket() of %d bytes = %d, %x, %d bytes, %v", tt.length, typ, flags, len(got), err)
+		}
+	}
+}
+
+func TestMQTTReadPacketErrors(t *testing.T) {
+	for name, packet := range map[string][]byte{
+		"remaining length of 5 bytes": {0x30, 0x80, 0x80, 0x80, 0x80, 0x01},
+		"truncated body":              {0x30, 0x05, 'a', 'b'},
+		"missing length":              {0x30},
+	} {
+		b := &mqttBridge{reader: bufio.NewReader(bytes.NewReader(packet))}
+		if _, _, _, err := b.readPacket(); err == nil {
+			t.Errorf("readPacket() of %s: err = nil", name)
+		}
+	}
+}
+
+func TestMQTTMalformedPublish(t *testing.T) {
+	b := &mqttBridge{}
+	for _, tt := range []struct {
+		flags byte
+		body  []byte
+	}{
+		{0x00, nil},
+		{0x00, []byte{0x00}},
+		// topic length beyond the body
+		{0x00, []byte{0x00, 0x05, 'a', 'b'}},
+		// QoS 1 without packet ID
+		{0x02, []byte{0x00, 0x01, 'a', 0x00}},
+	} {
+		if err := b.handlePublish(tt.flags, tt.body); err == nil {
+			t.Errorf("handlePublish(%x, % x): err = nil", tt.flags, tt.body)
+		}
+	}
+}
+
+func TestWriteMQTTString(t *testing.T) {
+	var buf bytes.Buffer
+	writeMQTTString(&buf, "alerts/#")
+	if want := append([]byte{0x00, 0x08}, "alerts/#"...); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("writeMQTTString() = % x, want % x", buf.Bytes(), want)
+	}
+}
+
+// recordConn records the packets written by the bridge.
+type recordConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *recordConn) Write(p []byte) (int, error) { return c.written.Write(p) }
+
+func TestMQTTPuback(t *testing.T) {
+	fake := useFakeWebex(t)
+	teamName, roomName = "KMP-Team", "Alerts"
+	defer func() { teamName, roomName = "", "" }()
+	fake.AddTeam("KMP-Team")
+
+	publish := func(qos byte, payload string) (byte, []byte) {
+		body := []byte{0x00, 0x06}
+		body = append(body, "alerts"...)
+		if qos > 0 {
+			body = append(body, 0x00, 0x07)
+		}
+		return qos << 1, append(body, payload...)
+	}
+	for _, tt := range []struct {
+		name     string
+		qos      byte
+		template string
+		fail     bool
+		wantErr  bool
+		wantSent bool
+		wantAck  bool
+	}{
+		{name: "QoS 0", qos: 0, wantSent: true},
+		{name: "QoS 0 failed", qos: 0, fail: true},
+		{name: "QoS 1", qos: 1, wantSent: true, wantAck: true},
+		// the broker sends the message again
+		{name: "QoS 1 failed", qos: 1, fail: true, wantErr: true},
+		// the template would fail again
+		{name: "QoS 1 invalid template", qos: 1, template: "{{.Missing}}", wantAck: true},
+	} {
+		conn := &recordConn{}
+		b := &mqttBridge{conn: conn}
+		if len(tt.template) > 0 {
+			b.tmpl = template.Must(template.New("mqtt").Parse(tt.template))
+		}
+		if tt.fail {
+			fake.Fail(1, http.StatusBadRequest)
+		}
+		before := len(fake.Messages())
+		err := b.handlePublish(publish(tt.qos, "disk full"))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: handlePublish() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if sent := len(fake.Messages()) > before; sent != tt.wantSent {
+			t.Errorf("%s: message sent = %v, want %v", tt.name, sent, tt.wantSent)
+		}
+		var wantWritten []byte
+		if tt.wantAck {
+			wantWritten = []byte{mqttPuback << 4, 0x02, 0x00, 0x07}
+		}
+		if got := conn.written.Bytes(); !bytes.Equal(got, wantWritten) {
+			t.Errorf("%s: written = % x, want % x", tt.name, got, wantWritten)
+		}
+	}
+}
+
+func TestMQTTSuback(t *testing.T) {
+	b := &mqttBridge{topics: []string{"alerts/#", "jobs/+"}, qos: 1, packetID: 3, broker: &url.URL{Host: "broker"}}
+	for _, tt := range []struct {
+		body    []byte
+		wantErr string
+	}{
+		{[]byte{0x00, 0x03, 0x01, 0x01}, ""},
+		// a lower QoS is only logged
+		{[]byte{0x00, 0x03, 0x01, 0x00}, ""},
+		{[]byte{0x00, 0x03, 0x01, mqttSubscribeFailure}, "MQTT subscription refused: jobs/+"},
+		{[]byte{0x00, 0x03, 0x01}, "malformed"},
+		{[]byte{0x00, 0x04, 0x01, 0x01}, "malformed"},
+	} {
+		err := b.checkSuback(tt.body)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkSuback(% x) error = %v, want %s", tt.body, err, tt.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("checkSuback(% x): %v", tt.body, err)
+		}
+	}
+}
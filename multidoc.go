@@ -0,0 +1,131 @@
+// multidoc.go
+//
+// Several messages in one input (flag --multi). The message of -i or
+// --message-file is split at "---" lines into messages, each optionally
+// with its own front matter (see frontmatter.go), and all are sent in one
+// run with the same connection and room lookups:
+//
+//	---
+//	room: Alerts
+//	---
+//	**disk full** on srv1
+//	---
+//	room: Reports
+//	---
+//	nightly backup done
+//
+// A part is front matter if it is a YAML mapping of front matter keys only,
+// otherwise it is a message. The flags apply to all messages, a front matter
+// only to the message following it. A failed message does not stop the
+// others.
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// messageDoc is one message of the input of --multi.
+type messageDoc struct {
+	fm   *frontMatter
+	body string
+}
+
+// messageDocs are the messages of --multi.
+var messageDocs []messageDoc
+
+// splitDocuments splits text into its messages. dir is the directory of
+// relative paths.
+func splitDocuments(text, dir string) ([]messageDoc, error) {
+	var parts []string
+	var part strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if strings.TrimRight(line, "\r\n") == "---" {
+			parts = append(parts, part.String())
+			part.Reset()
+			continue
+		}
+		part.WriteString(line)
+	}
+	parts = append(parts, part.String())
+
+	var docs []messageDoc
+	var pending *frontMatter
+	for _, p := range parts {
+		if len(strings.TrimSpace(p)) == 0 {
+			continue
+		}
+		if fm := frontMatterPart(p, dir); fm != nil {
+			if pending != nil {
+				return nil, errors.New("--multi: front matter without message")
+			}
+			pending = fm
+			continue
+		}
+		docs = append(docs, messageDoc{fm: pending, body: strings.TrimRight(p, "\n")})
+		pending = nil
+	}
+	if pending != nil {
+		return nil, errors.New("--multi: front matter without message at the end of the input")
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("--multi: no messages")
+	}
+	return docs, nil
+}
+
+// frontMatterPart returns the front matter of part, nil if part is a
+// message.
+func frontMatterPart(part, dir string) *frontMatter {
+	doc, err := parseYAML(part)
+	if err != nil {
+		return nil
+	}
+	m, ok := doc.(yamlMapping)
+	if !ok || checkYAMLKeys("", m, frontMatterKeys) != nil {
+		return nil
+	}
+	fm, err := newFrontMatter(m, dir)
+	if err != nil {
+		return nil
+	}
+	return fm
+}
+
+// sendDocuments sends the messages of --multi.
+func sendDocuments() error {
+	if len(messageDocs) == 0 {
+		return errors.New("--multi needs the messages of -i or --message-file")
+	}
+	if len(markdownMsg) > 0 {
+		return errors.New("--multi can not be combined with -m, --code, --table or --git-push")
+	}
+	// the options of the flags, restored for every message
+	team, room, email, severity := teamName, roomName, emailAddr, severityName
+	files := append(stringList(nil), uploadFiles...)
+	mentions := append(stringList(nil), mentionEmails...)
+	card, file := cardAttachment, cardFile
+
+	b := newBatch("multi", "messages", "MESSAGE")
+	for i, doc := range messageDocs {
+		teamName, roomName, emailAddr, severityName = team, room, email, severity
+		uploadFiles = append(stringList(nil), files...)
+		mentionEmails = append(stringList(nil), mentions...)
+		cardAttachment, cardFile = card, file
+		if doc.fm != nil {
+			doc.fm.apply()
+		}
+		markdownMsg = doc.body
+		err := cmdSend("", nil)
+		if err != nil {
+			slog.Error("sending message failed", "message", i+1, "team", teamName, "room", roomName, "email", emailAddr, "error", err)
+		}
+		b.add(strconv.Itoa(i+1), recipientName(&notification{TeamName: teamName, RoomName: roomName, Email: emailAddr}), err)
+	}
+	if showPreview || dryRun {
+		return nil
+	}
+	return b.finish()
+}
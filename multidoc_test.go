@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitDocuments(t *testing.T) {
+	for _, tt := range []struct {
+		name, text string
+		// body and room of the front matter of the messages
+		want    []string
+		wantErr string
+	}{
+		{name: "front matters", text: "---\nroom: Alerts\n---\n**disk full** on srv1\n---\nroom: Reports\n---\nnightly backup done\n",
+			want: []string{"**disk full** on srv1 @Alerts", "nightly backup done @Reports"}},
+		{name: "plain", text: "first\n---\nsecond\r\n---\r\nthird", want: []string{"first", "second\r", "third"}},
+		{name: "mixed", text: "no options\n---\nseverity: critical\n---\ncritical one\n", want: []string{"no options", "critical one"}},
+		// a mapping with other keys is a message
+		{name: "not front matter", text: "host: srv1\nstatus: down\n", want: []string{"host: srv1\nstatus: down"}},
+		{name: "no message", text: "---\nroom: Alerts\n---\n---\nroom: Reports\n---\nhi\n", wantErr: "front matter without message"},
+		{name: "at the end", text: "hi\n---\nroom: Alerts\n", wantErr: "at the end of the input"},
+		{name: "empty", text: "---\n\n---\n", wantErr: "no messages"},
+	} {
+		docs, err := splitDocuments(tt.text, ".")
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: splitDocuments() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		var got []string
+		for _, doc := range docs {
+			s := doc.body
+			if doc.fm != nil && len(doc.fm.room) > 0 {
+				s += " @" + doc.fm.room
+			}
+			got = append(got, s)
+		}
+		if err != nil || strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: splitDocuments() = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSendDocuments(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func() { teamName, roomName, markdownMsg, messageDocs = "", "", "", nil }()
+	teamName, roomName, markdownMsg = "KMP-Team", "Alerts", ""
+	docs, err := splitDocuments("first\n---\nroom: Reports\n---\nsecond\n", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageDocs = docs
+	if err := sendDocuments(); err != nil {
+		t.Fatal(err)
+	}
+	rooms := make(map[string]string)
+	for _, r := range fake.Rooms() {
+		rooms[r.ID] = r.Title
+	}
+	var got []string
+	for _, m := range fake.Messages() {
+		got = append(got, m.Markdown+" @"+rooms[m.RoomID])
+	}
+	if want := "first @Alerts|second @Reports"; strings.Join(got, "|") != want {
+		t.Errorf("messages = %q, want %s", got, want)
+	}
+}
@@ -6,579 +6,1140 @@
 // by Herwig Grimm (herwig.grimm at aon.at)
 //
 // required args:
-//			-T <Webex bot token>
-//			-t <team name> | -D <email address>
-//			-r <room name>
-//			-m <markdown message> | -i
+//
+//	-T <Webex bot token>
+//	-t <team name> | -D <email address>
+//	-r <room name>
+//	-m <markdown message> | -i
 //
 // optinal args:
-//			-p <proxy server>
-//			-f <png filename and path to send>
-//			-d <message_id>
-//			-a <card_attachment>
-//			-i ... use standard input instead of flag -m
+//
+//	-p <proxy server>
+//	-f <png filename and path to send>
+//	-d <message_id>
+//	-a <card_attachment> | -A <card file>
+//	-i ... use standard input instead of flag -m
+//	--mqtt <broker url> --topic <topic filter> ... MQTT subscribe-and-notify mode
+//
+// commands:
+//
+//	send, edit, delete, rooms, teams, members, webhooks, whoami, card, export, search, interactive, serve (see -h)
 //
 // example:
-//			upload_poc.exe -T <apitoken> -t "Test-Team" -r "INM18/00021" -m "Happy hacking" -f upload_poc.go
+//
+//	upload_poc.exe -T <apitoken> -t "Test-Team" -r "INM18/00021" -m "Happy hacking" -f upload_poc.go
+//	notify_by_webex_teams -T <apitoken> -t "Test-Team" -r "Alerts" --mqtt tcp://broker:1883 --topic "alerts/#"
 //
 // doc links:
-//			https://developer.webex.com/getting-started.html
+//
+//	https://developer.webex.com/getting-started.html
 //
 // changelog:
-//				V0.1 (16.05.2018): 	initial release
-//				V0.2 (20.05.2018): 	now files (HTTP link) can be send via flag -a
-//				V0.3 (25.05.2018): 	complete redesign without 3rd party library (github.com/vallard/spark/)
-//					and new file upload function added via flag -f
-//				V0.4 (24.11.2019): 	new message delete function via flag -d
-//					and card attachment via flag -a. see also https://developer.webex.com/docs/api/guides/cards and https://adaptivecards.io/designer/
-//				V0.5 (07.04.2022): new flag -i for reading messages from standard input and new flag description for flag -T
-//				V0.6 (08.04.2022): new flag -D for sending a private 1:1 message by specified email address
+//
+//	V0.1 (16.05.2018): 	initial release
+//	V0.2 (20.05.2018): 	now files (HTTP link) can be send via flag -a
+//	V0.3 (25.05.2018): 	complete redesign without 3rd party library (github.com/vallard/spark/)
+//		and new file upload function added via flag -f
+//	V0.4 (24.11.2019): 	new message delete function via flag -d
+//		and card attachment via flag -a. see also https://developer.webex.com/docs/api/guides/cards and https://adaptivecards.io/designer/
+//	V0.5 (07.04.2022): new flag -i for reading messages from standard input and new flag description for flag -T
+//	V0.6 (08.04.2022): new flag -D for sending a private 1:1 message by specified email address
+//	V0.7 (15.10.2026): new MQTT subscribe-and-notify mode via flags --mqtt and --topic
+//	V0.8 (15.10.2026): scheduled/delayed send via flags --at and --delay, optionally handed off
+//		to a local spool (--spool) which is sent by a later --spool-flush
+//	V0.9 (15.10.2026): recurring message daemon (--daemon) with a cron-like schedule table
+//		in the new JSON config file (-c)
+//	V0.10 (15.10.2026): OAuth authorization of Webex integrations (--oauth-login) with automatic
+//		access token refresh. used instead of a bot token if flag -T is not set
+//	V0.11 (15.10.2026): send as a guest persona via guest issuer JWT (--guest-issuer-id, --guest-secret)
+//	V0.12 (15.10.2026): custom CA certificates (--cacert, --capath) and --insecure
+//	V0.13 (15.10.2026): SOCKS5 proxy support via -p socks5://... (also used for MQTT connections)
+//	V0.14 (15.10.2026): the proxy environment variables HTTP_PROXY, HTTPS_PROXY, NO_PROXY and ALL_PROXY
+//		are used if flag -p is not set. new flag --no-proxy
+//	V0.15 (15.10.2026): NTLM and Negotiate proxy authentication via flag --proxy-auth
+//	V1.0 (15.10.2026): Webex API code moved to the reusable library package
+//		github.com/hgrimm/notify_by_webex_teams/webex
+//	V1.1 (15.10.2026): all API calls take a context. new flag --timeout
+//	V1.2 (15.10.2026): configurable API base URL in package webex and fake Webex API server
+//		(package webex/webextest) with a test suite
+//	V1.3 (15.10.2026): one shared HTTP client for all requests, so connections are reused
+//	V1.4 (15.10.2026): structured logging (log/slog) with flags --log-format text|json and --log-level.
+//		tokens and passwords are redacted from the log
+//	V1.5 (15.10.2026): subcommands send, edit, delete, rooms, teams, members, webhooks, card and serve.
+//		the flat flags of older versions keep working. new flag -e to edit a message
+//	V1.6 (15.10.2026): long flag names (--token, --team, --room, --message, --file, ...) as aliases
+//		of the single letter flags
+//	V1.7 (15.10.2026): interactive mode (command interactive) with fuzzy room search
+//	V1.8 (15.10.2026): local preview of message and card via flags --preview and --preview-html
+//	V1.9 (15.10.2026): export of the room history as JSON, CSV or HTML (command export)
+//	V1.10 (15.10.2026): search messages of a room (command search)
+//	V1.11 (15.10.2026): purge old messages of the bot via flags --purge, --older-than and --match
+//	V1.12 (15.10.2026): @mentions via flag --mention
+//	V1.13 (15.10.2026): group mention @all via flag --mention-all, which needs a confirmation
+//		(--confirm-mention-all). <@all> in message texts is neutralized
+//	V1.14 (15.10.2026): command whoami shows the identity of the token and whether it is valid
+//	V1.15 (15.10.2026): card attachments are validated against the Adaptive Card 1.x schema before
+//		sending. new flag -A to read the card from a file
+//	V1.16 (15.10.2026): simplified YAML card descriptions (-A card.yaml) with title, text, facts,
+//		inputs and buttons are compiled to Adaptive Cards
+//	V1.17 (15.10.2026): command thread list shows a message and its replies, e.g. as JSON
+//	V1.18 (15.10.2026): rooms lock|unlock and flags --locked/--announcement for moderated,
+//		read-only notification rooms
+//	V1.19 (15.10.2026): command meeting create schedules a Webex meeting and posts the join
+//		details as a card, e.g. as incident bridge
+//	V1.20 (15.10.2026): flag --severity info|warning|critical adds emoji, card color and @mentions,
+//		configurable in the config file
+//	V1.21 (15.10.2026): emoji shortcodes like :warning: are expanded in messages (--no-emoji)
+//	V1.22 (15.10.2026): standard input (-i) is read unchanged on all platforms, only CR LF is
+//		converted to LF (--crlf keeps it). non UTF-8 input is rejected
+//	V1.23 (15.10.2026): command service install|start|stop|uninstall runs serve, --mqtt or --daemon
+//		as systemd unit or Windows service, logging to the journal or the Windows event log
+//	V1.24 (15.10.2026): the relay (serve) has the probes /healthz and /readyz (API and token check)
+//	V1.25 (15.10.2026): Prometheus metrics at /metrics of the relay and with --metrics-listen,
+//		requests answered with 429 Too Many Requests are retried after Retry-After
+//	V1.26 (15.10.2026): graceful shutdown of serve, --mqtt and --daemon on SIGTERM/SIGINT
+//		(--shutdown-timeout, --deregister-webhooks)
+//	V1.27 (15.10.2026): with --spool messages failing because the API or the proxy is unreachable
+//		are spooled and sent later, by the next invocation, --spool-flush or the long-running modes
+//	V1.28 (15.10.2026): --dedupe suppresses identical messages within a time window
+//	V1.29 (15.10.2026): --digest combines the messages of the relay, MQTT bridge and daemon
+//		to the same recipient into one digest message
+//	V1.30 (15.10.2026): named message templates (--template, --var) from the templates directory
+//		of the config file, with defaults for recipient, severity, mentions and card
+//	V1.31 (15.10.2026): mail merge with a CSV file of recipients and template variables (--recipients)
+//	V1.32 (15.10.2026): repeatable -f, several files are uploaded concurrently and listed in a summary
+//		message or threaded under one parent message (--upload-parallel, --thread-files)
+//	V1.33 (15.10.2026): files are checked for existence and the size limit before anything is sent,
+//		with the content type of the file instead of image/png
+//	V1.34 (15.10.2026): PNG and JPEG files are downscaled before the upload (--max-image-size)
+//	V1.35 (15.10.2026): local images referenced by the message, e.g. ![](./graph.png), are sent as files
+//	V1.36 (15.10.2026): code snippets as fenced code block, truncated and attached if too long (--code, --code-lang)
+//	V1.37 (15.10.2026): too long messages fail before sending, are truncated or attached as file (--overflow)
+//	V1.38 (15.10.2026): command run wraps a command and sends its exit status, duration and output (--only-failure).
+//		arguments after -- are no longer parsed as flags
+//	V1.39 (15.10.2026): command status updates one message in place with the latest line of the input (--update-interval)
+//	V1.40 (15.10.2026): Kubernetes events watcher posting the matching events as cards (--k8s-watch, --namespace,
+//		--reason, --kubeconfig)
+//	V1.41 (15.10.2026): build status card from the environment of GitHub Actions, GitLab CI, Jenkins and
+//		Azure DevOps (--ci-card, --ci-status)
+//	V1.42 (15.10.2026): summary card of JUnit XML test reports (--junit, --junit-attach)
+//	V1.43 (15.10.2026): push notifications from the post-receive hook of a git repository (--git-push, --commit-url)
+//	V1.44 (15.10.2026): serve verifies the signature of webhook events with --secret and restricts their
+//		sources with --allow-ip
+//	V1.45 (15.10.2026): chatops bot, messages to the bot run the allowed commands of the config file
+//		and get the output as reply (serve --chatops)
+//	V1.46 (15.10.2026): serve posts card submissions to an HTTP callback (--forward-url, --forward-template,
+//		--forward-header)
+//	V1.47 (15.10.2026): CSV and TSV tables as aligned table in the message (--table, --table-max-width,
+//		--table-max-rows)
+//	V1.48 (15.10.2026): JSON of standard input as card with the flattened keys and values (--json-card)
+//	V1.49 (15.10.2026): YAML front matter with team, room, email, severity, mentions, files and card in
+//		messages of -i and of the new flag --message-file
+//	V1.50 (15.10.2026): several messages separated by --- lines in one input (--multi). room lookups are
+//		cached for 10 minutes
+//	V1.51 (15.10.2026): files with their own caption, room or thread from a YAML manifest, with a
+//		summary of the sent and failed files (--manifest)
+//	V1.52 (15.10.2026): checksums of the files in the message (--checksum sha256)
+//	V1.53 (15.10.2026): command to check every file before the upload, e.g. a virus scan
+//		(--pre-upload-cmd "clamscan %f")
+//	V1.54 (15.10.2026): files above 100 MB are uploaded to S3, GCS or WebDAV and sent as download
+//		link (--large-file-store, --large-file-expiry)
+//	V1.55 (15.10.2026): Jira webhooks as cards, routed to the rooms of the projects (serve --jira)
+//	V1.56 (15.10.2026): PagerDuty webhooks as one card per incident, replaced on every change
+//		(serve --pagerduty)
+//	V1.57 (15.10.2026): Sentry issue alerts as error cards, de-duplicated per issue
+//		(serve --sentry)
+//	V1.58 (15.10.2026): Datadog monitor webhooks as cards colored by alert type, routed by tags
+//		(serve --datadog)
+//	V1.59 (15.10.2026): Acknowledge button on cards, the relay shows who acknowledged
+//		(flag --ack, serve --ack)
+//	V1.60 (15.10.2026): escalation of cards not acknowledged in time to another room
+//		(flags --escalate-after, --escalate-room, --escalate-mention)
+//	V1.61 (15.10.2026): routing rules of the config file by severity, labels and template
+//		(flag --labels)
+//	V1.62 (15.10.2026): rules of the webhook receivers of the relay: room, template,
+//		mentions or suppression per event
+//	V1.63 (15.10.2026): message to the person on call by a schedule file, PagerDuty or Opsgenie
+//		(flags --oncall-file, --oncall-pagerduty, --oncall-opsgenie, --oncall-cc)
+//	V1.64 (15.10.2026): profiles of the config file for bots in several Webex organizations
+//		(flag --profile, "profile" of routing rules and relayed notifications)
+//	V1.65 (15.10.2026): room provisioning with moderation, members, welcome message and webhooks
+//		(command provision)
+//	V1.66 (15.10.2026): declarative management of teams, rooms and members with drift report
+//		(command apply, flag --prune)
+//	V1.67 (15.10.2026): audit journal of the sent messages
+//		(flags --journal, --no-journal)
+//	V1.68 (15.10.2026): encryption at rest of the spool, the state files and the journal
+//		(flag --state-key-file, NOTIFY_STATE_KEY, command decrypt)
+//	V1.69 (15.10.2026): TLS hardening: minimum TLS version, cipher suites and certificate pinning
+//		(flags --tls-min-version, --tls-ciphers, --tls-pin, config file section tls)
+//	V1.70 (15.10.2026): client certificate for HTTPS proxy servers
+//		(flags --proxy-cert, --proxy-key)
+//	V1.71 (15.10.2026): configurable API URL, default https://webexapis.com/v1, and Webex for Government
+//		(flag --api-url, profile apiUrl usgov)
+//	V1.72 (15.10.2026): token check with identity, organization and remaining validity
+//		(command token check, flag --min-validity)
+//	V1.73 (15.10.2026): bot token from HashiCorp Vault, KV version 1 and 2, token or AppRole login
+//		(flag --token-vault, VAULT_ADDR, VAULT_TOKEN, VAULT_ROLE_ID, VAULT_SECRET_ID)
+//	V1.74 (15.10.2026): bot token from AWS Secrets Manager or SSM Parameter Store with the default credential chain
+//		(flag --token-aws-secret)
+//	V1.75 (15.10.2026): bot token from Azure Key Vault with managed identity, workload identity or service principal
+//		(flag --token-azure-keyvault)
+//	V1.76 (15.10.2026): bot token from a file, e.g. a mounted Kubernetes secret, reloaded on change or SIGHUP
+//		(flag --token-file)
+//	V1.77 (15.10.2026): structured Webex API errors with code, message and trackingId in text and JSON log output
+//	V1.78 (15.10.2026): trace of the HTTP requests and responses with redacted credentials
+//		(flags --trace, --trace-file)
+//	V1.79 (15.10.2026): retry budget and backoff of the Webex API requests, also of server errors
+//		(flags --max-retries, --retry-initial-wait, --retry-max-wait, --retry-on)
+//	V1.80 (15.10.2026): circuit breaker of the receiver and daemon modes, spooling during Webex outages
+//		(flags --circuit-threshold, --circuit-cooldown, --circuit-alert-cmd)
+//	V1.81 (15.10.2026): result summary and JSON report of --recipients, --manifest and --multi, exit status by policy
+//		(flags --report, --fail-on)
+//	V1.82 (15.10.2026): idempotency keys, a retried message with the same key is sent only once per recipient
+//		(flags --idempotency-key, --idempotency-window, --idempotency-file, --idempotency-check)
+//	V1.83 (15.10.2026): the ID and the file URLs of the sent message are printed to standard output
+//	V1.84 (15.10.2026): named message handles, edit and delete earlier messages by a name of the script
+//		(flags --save-as, --edit-ref, --delete-ref, --handles-file)
+//	V1.85 (15.10.2026): reply in the thread of the last message sent to the room, so the steps of a
+//		pipeline form one thread (flag --reply-last)
+//	V1.86 (15.10.2026): German and French texts and date formats of the built-in cards and messages (flag --lang)
+//	V1.87 (15.10.2026): time functions of the templates: time zones, date formats, relative times and durations
+//	V1.88 (15.10.2026): footer with the OS user, host and time of the invocation (flag --footer)
+//	V1.89 (15.10.2026): expansion of allowed environment variables in the message and the card (flag --expand-env)
+//	V1.90 (15.10.2026): scrubbing of secrets from the message, the card and the attached text files
+//		(flags --scrub and --scrub-pattern)
+//	V1.91 (15.10.2026): Prometheus Alertmanager and Grafana alerts as cards, routed by the rules
+//		(serve --alertmanager, --grafana)
 //
 // card attachment example:
-//				./notify_by_webex_teams -T "<token>" -t "KMP-Test-Team" -r "Allgemein" -m "Test GRH 010" \
-//				-a '{ "contentType": "application/vnd.microsoft.card.adaptive", "content": { "type": "AdaptiveCard", "version": "1.0", "body": [ { "type": "TextBlock", "text": "Please enter your comment here: " }, { "type": "Input.Text", "id": "name", "title": "New Input.Toggle", "placeholder": "comment text" } ], "actions": [ { "type": "Action.Submit", "title": "accept", "data": { "answer": "accept " } }, { "type": "Action.Submit", "title": "decline", "data": { "answer": "decline " } } ] } }'
+//
+//	./notify_by_webex_teams -T "<token>" -t "KMP-Test-Team" -r "Allgemein" -m "Test GRH 010" \
+//	-a '{ "contentType": "application/vnd.microsoft.card.adaptive", "content": { "type": "AdaptiveCard", "version": "1.0", "body": [ { "type": "TextBlock", "text": "Please enter your comment here: " }, { "type": "Input.Text", "id": "name", "title": "New Input.Toggle", "placeholder": "comment text" } ], "actions": [ { "type": "Action.Submit", "title": "accept", "data": { "answer": "accept " } }, { "type": "Action.Submit", "title": "decline", "data": { "answer": "decline " } } ] } }'
 package main
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
-	"mime/multipart"
-	"net/http"
-	"net/textproto"
+	"log/slog"
 	"net/url"
 	"os"
 	"path"
-	"runtime"
 	"strings"
+	"sync"
 	"time"
-)
-
-type roomsResp struct {
-	Items []struct {
-		ID           string    `json:"id"`
-		Title        string    `json:"title"`
-		Type         string    `json:"type"`
-		IsLocked     bool      `json:"isLocked"`
-		LastActivity time.Time `json:"lastActivity"`
-		TeamID       string    `json:"teamId,omitempty"`
-		CreatorID    string    `json:"creatorId"`
-		Created      time.Time `json:"created"`
-	} `json:"items"`
-}
-
-type SparkRoom struct {
-	Id           string
-	Title        string
-	Type         string
-	IsLocked     bool
-	LastActivity time.Time
-	CreatorId    string
-	Created      time.Time
-	TeamId       string
-}
+	"unicode/utf8"
 
-type Message struct {
-	ID          string    `json:"id"`
-	RoomID      string    `json:"roomId"`
-	RoomType    string    `json:"roomType"`
-	Text        string    `json:"text"`
-	Files       []string  `json:"files"`
-	PersonID    string    `json:"personId"`
-	PersonEmail string    `json:"personEmail"`
-	Markdown    string    `json:"markdown"`
-	HTML        string    `json:"html"`
-	Created     time.Time `json:"created"`
-}
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
 
 var (
-	uploadFile      string
-	proxyString     string
-	markdownMsg     string
-	apiToken        string
-	teamName        string
-	roomName        string
-	showVersion     bool
-	deleteMessageId string
-	cardAttachment  string
-	useStdIn        bool
-	emailAddr       string
+	uploadFiles        stringList
+	uploadParallel     int
+	maxImageSize       byteSize
+	checksumAlgo       string
+	preUploadCmd       string
+	largeFileStore     string
+	largeFileExpiry    time.Duration
+	codeFile           string
+	codeLang           string
+	tableFile          string
+	tableMaxWidth      int
+	tableMaxRows       int
+	overflowMode       string
+	onlyFailure        bool
+	updateInterval     time.Duration
+	k8sWatch           bool
+	k8sNamespace       string
+	k8sReasons         string
+	kubeconfigFile     string
+	ciCard             bool
+	ciStatus           string
+	junitReports       stringList
+	junitAttach        bool
+	jsonCardInput      bool
+	gitPush            bool
+	commitURL          string
+	threadFiles        bool
+	proxyString        string
+	markdownMsg        string
+	apiToken           string
+	teamName           string
+	roomName           string
+	showVersion        bool
+	deleteMessageId    string
+	cardAttachment     string
+	useStdIn           bool
+	messageFile        string
+	multiDoc           bool
+	manifestFile       string
+	emailAddr          string
+	mqttBroker         string
+	mqttTopics         stringList
+	mqttQoS            int
+	mqttClientID       string
+	mqttTemplate       string
+	sendAtString       string
+	sendDelay          time.Duration
+	useSpool           bool
+	spoolDir           string
+	flushSpool         bool
+	spoolInterval      time.Duration
+	dedupeWindow       time.Duration
+	dedupeFile         string
+	digestWindow       time.Duration
+	templateName       string
+	templateVars       stringList
+	recipientsFile     string
+	configFile         string
+	runAsDaemon        bool
+	oauthLoginMode     string
+	oauthTokenFile     string
+	oauthClientID      string
+	oauthSecret        string
+	oauthRedirect      string
+	oauthScopes        string
+	guestIssuerID      string
+	guestSecret        string
+	guestSubject       string
+	guestName          string
+	caCertFile         string
+	caPath             string
+	tlsInsecure        bool
+	tlsMinVersion      string
+	tlsCiphers         string
+	tlsPins            stringList
+	noProxy            bool
+	proxyAuth          string
+	proxyCertFile      string
+	proxyKeyFile       string
+	requestTimeout     time.Duration
+	logFormat          string
+	logLevel           string
+	editMessageId      string
+	memberModerator    bool
+	webhookName        string
+	webhookTarget      string
+	webhookResource    string
+	webhookEvent       string
+	webhookFilter      string
+	webhookSecret      string
+	serveListen        string
+	serveToken         string
+	allowIPs           string
+	chatops            bool
+	forwardURL         string
+	jiraWebhooks       bool
+	jiraSecret         string
+	pagerDutyHooks     bool
+	pagerDutySecret    string
+	pagerDutyState     string
+	sentryHooks        bool
+	sentrySecret       string
+	sentryWindow       time.Duration
+	sentryThreshold    int
+	datadogHooks       bool
+	datadogSecret      string
+	alertmanagerHooks  bool
+	alertmanagerSecret string
+	grafanaHooks       bool
+	grafanaSecret      string
+	ackButton          bool
+	escalateAfter      time.Duration
+	escalateRoom       string
+	escalateMention    stringList
+	labelList          stringList
+	onCallFile         string
+	onCallPD           string
+	onCallOpsgenie     string
+	onCallCC           bool
+	profileName        string
+	apiBaseURL         string
+	forwardTemplate    string
+	forwardHeaders     stringList
+	showPreview        bool
+	previewHTML        string
+	sinceString        string
+	outputFile         string
+	searchMatch        string
+	purgeMessages      bool
+	olderThan          string
+	dryRun             bool
+	pruneMembers       bool
+	journalFile        string
+	noJournal          bool
+	stateKeyFile       string
+	mentionEmails      stringList
+	mentionAll         bool
+	confirmAll         bool
+	cardFile           string
+	lockedRooms        bool
+	announceRooms      bool
+	severityName       string
+	noEmoji            bool
+	keepCRLF           bool
+	serviceName        string
+	serviceRunName     string
+	meetingTitle       string
+	meetingDuration    time.Duration
+	metricsListen      string
+	shutdownTimeout    time.Duration
+	deleteWebhooks     bool
+	minValidity        time.Duration
+	tokenVault         string
+	tokenAWSSecret     string
+	tokenAzureKV       string
+	tokenFile          string
+	traceHTTP          bool
+	traceFile          string
+	maxRetries         int
+	retryInitWait      time.Duration
+	retryMaxWait       time.Duration
+	retryOn            string
+	circuitThresh      int
+	circuitCooldown    time.Duration
+	circuitAlertCmd    string
+	reportFile         string
+	failOn             string
+	idempotencyKey     string
+	idempotencyWin     time.Duration
+	idempotencyFile    string
+	idempotencyMode    string
+	saveAs             string
+	editRef            string
+	deleteRef          string
+	handlesFile        string
+	replyLast          bool
+	textLang           string
+	footerMode         string
+	expandEnvList      string
+	scrubBuiltin       bool
+	scrubPatterns      stringList
 )
 
 const (
-	roomsURL    = "https://api.ciscospark.com/v1/rooms"
-	messagesURL = "https://api.ciscospark.com/v1/messages"
-	version     = "0.6"
+	version = "1.91"
 )
 
+var (
+	webexClientOnce   sync.Once
+	sharedWebexClient *webex.Client
+)
+
+// stringList is a flag.Value collecting the values of a repeatable flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 func init() {
 	flag.StringVar(&apiToken, "T", "", "Webex bot token (bot must be member of team and room)")
+	flag.StringVar(&tokenFile, "token-file", "", "read the bot token from the file, e.g. a mounted Kubernetes secret. serve, mqtt, daemon, k8s-watch: read again when it changes")
+	flag.StringVar(&tokenVault, "token-vault", "", "read the bot token from the HashiCorp Vault secret <path>#<field>, e.g. secret/webex#token (VAULT_ADDR, VAULT_TOKEN or VAULT_ROLE_ID and VAULT_SECRET_ID)")
+	flag.StringVar(&tokenAWSSecret, "token-aws-secret", "", "read the bot token from the AWS Secrets Manager secret <name>[#<key>] or the SSM parameter ssm:<name>, with the default AWS credential chain")
+	flag.StringVar(&tokenAzureKV, "token-azure-keyvault", "", "read the bot token from the Azure Key Vault secret <vault name>/<secret name> or secret URL, with the managed identity")
+	flag.StringVar(&profileName, "profile", "", "profile of the config file (-c) with the token, API URL and proxy server of the bot. -T, -p and --api-url override them")
+	flag.DurationVar(&minValidity, "min-validity", 7*24*time.Hour, "token check: fail if the integration token expires within this time")
+	flag.StringVar(&apiBaseURL, "api-url", "", "URL of the Webex API or usgov for Webex for Government (default https://webexapis.com/v1)")
 	flag.StringVar(&teamName, "t", "", "team name")
 	flag.StringVar(&roomName, "r", "Room1", "room name")
-	flag.StringVar(&uploadFile, "f", "", "PNG filename and path to send")
+	flag.Var(&uploadFiles, "f", "filename and path of a file to send, up to 100 MB (repeatable)")
+	flag.IntVar(&uploadParallel, "upload-parallel", 4, "number of files of -f uploaded at the same time")
+	flag.Var(&maxImageSize, "max-image-size", "downscale PNG and JPEG files of -f larger than this before the upload, e.g. 2MB")
+	flag.StringVar(&preUploadCmd, "pre-upload-cmd", "", "command to check every file before anything is uploaded, e.g. \"clamscan %f\". %f is the file. nothing is sent if it fails")
+	flag.StringVar(&largeFileStore, "large-file-store", "", "upload files above 100 MB to s3://<bucket>/<prefix>, gs://<bucket>/<prefix> or a WebDAV URL and send a download link instead")
+	flag.DurationVar(&largeFileExpiry, "large-file-expiry", 7*24*time.Hour, "validity of the pre-signed links of --large-file-store (at most 168h)")
+	flag.StringVar(&checksumAlgo, "checksum", "", "add the checksum of every file sent to the message: sha256, sha512, sha1 or md5")
+	flag.BoolVar(&threadFiles, "thread-files", false, "send the message first and the files of -f as replies in its thread, instead of a summary message after the files")
 	flag.StringVar(&markdownMsg, "m", "", "markdown message")
-	flag.StringVar(&proxyString, "p", "", "proxy server. format: http://<user>:<password>@<hostname>:<port>")
-	flag.StringVar(&deleteMessageId, "d", "", "delete message. provide message id")
+	flag.StringVar(&proxyString, "p", "", "proxy server. format: http://<user>:<password>@<hostname>:<port> or socks5://<user>:<password>@<hostname>:<port>")
+	flag.StringVar(&deleteMessageId, "d", "", "delete message. provide message id. thread: ID of the parent message")
 	flag.StringVar(&cardAttachment, "a", "", "card attachment -a see https://developer.webex.com/docs/api/guides/cards and https://adaptivecards.io/designer/")
+	flag.StringVar(&cardFile, "A", "", "file with the card attachment (JSON) or a YAML card description (.yaml, .yml). a bare AdaptiveCard is wrapped into an attachment")
 	flag.BoolVar(&showVersion, "V", false, "show version")
 	flag.BoolVar(&useStdIn, "i", false, "read message from standard input")
+	flag.BoolVar(&multiDoc, "multi", false, "split the message of -i or --message-file at --- lines into several messages, each with an optional front matter")
+	flag.StringVar(&messageFile, "message-file", "", "read message from the file, - reads standard input. a YAML front matter sets team, room, email, severity, mentions, files and card")
+	flag.StringVar(&manifestFile, "manifest", "", "YAML file listing files to send, each with its own caption, team, room, email or thread. prints a summary")
+	flag.StringVar(&codeFile, "code", "", "file with code to append to the message as code block, - reads standard input. too long code is truncated and the file is attached")
+	flag.StringVar(&codeLang, "code-lang", "", "language of the code of --code for syntax highlighting, e.g. yaml (default: from the file extension)")
+	flag.StringVar(&tableFile, "table", "", "CSV or TSV file to append to the message as aligned table, - reads standard input. rows left out are sent as file")
+	flag.IntVar(&tableMaxWidth, "table-max-width", 40, "table: maximum width of a cell, longer cells are cut")
+	flag.IntVar(&tableMaxRows, "table-max-rows", 50, "table: maximum number of rows")
+	flag.StringVar(&overflowMode, "overflow", "fail", "message longer than Webex accepts: fail, truncate or attach (summary with the full message as file)")
+	flag.BoolVar(&onlyFailure, "only-failure", false, "run: send a message only if the command fails")
+	flag.DurationVar(&updateInterval, "update-interval", 10*time.Second, "status: minimum time between two edits of the status message")
+	flag.BoolVar(&k8sWatch, "k8s-watch", false, "watch Kubernetes events and post the matching ones as cards")
+	flag.StringVar(&k8sNamespace, "namespace", "", "k8s-watch: namespace of the events (default: all namespaces)")
+	flag.StringVar(&k8sReasons, "reason", "", "k8s-watch: comma separated event reasons to post, e.g. Failed,OOMKilling (default: all events of type Warning)")
+	flag.StringVar(&kubeconfigFile, "kubeconfig", "", "k8s-watch: kubeconfig file (default: service account of the pod, $KUBECONFIG or ~/.kube/config)")
+	flag.BoolVar(&ciCard, "ci-card", false, "send a build status card with branch, commit, job URL and duration of the CI environment (GitHub Actions, GitLab CI, Jenkins, Azure DevOps)")
+	flag.StringVar(&ciStatus, "ci-status", "", "ci-card: status of the build, e.g. success, failure or canceled (default: from the CI environment, of run: of the command)")
+	flag.Var(&junitReports, "junit", "JUnit XML test report or glob pattern to summarize in a card with passed, failed and skipped tests (repeatable)")
+	flag.BoolVar(&junitAttach, "junit-attach", false, "junit: send the test reports with the message")
+	flag.BoolVar(&jsonCardInput, "json-card", false, "send the JSON of standard input as card with the flattened keys and values. -m is the title")
+	flag.BoolVar(&gitPush, "git-push", false, "git post-receive hook: send the pushed commits of the ref updates read from standard input")
+	flag.StringVar(&commitURL, "commit-url", "", "git-push: URL prefix of commit links, the commit hash is appended")
+	flag.BoolVar(&keepCRLF, "crlf", false, "keep CR LF line endings of the message read from standard input (-i)")
 	flag.StringVar(&emailAddr, "D", "", "The email address of the recipient when sending a private 1:1 message.")
+	flag.StringVar(&mqttBroker, "mqtt", "", "MQTT broker to subscribe to. format: tcp://[<user>:<password>@]<hostname>:<port> or ssl://...")
+	flag.Var(&mqttTopics, "topic", "MQTT topic filter to subscribe to, e.g. alerts/# (repeatable)")
+	flag.IntVar(&mqttQoS, "mqtt-qos", 0, "MQTT subscription QoS (0 or 1)")
+	flag.StringVar(&mqttClientID, "mqtt-client-id", "", "MQTT client id (default notify_by_webex_teams-<hostname>-<pid>)")
+	flag.StringVar(&mqttTemplate, "mqtt-template", "", "Go text/template for MQTT messages. fields: .Topic, .Payload, .JSON (default: raw payload)")
+	flag.StringVar(&sendAtString, "at", "", "send the message at the given local time. format: 2006-01-02T15:04, 15:04 or RFC3339")
+	flag.DurationVar(&sendDelay, "delay", 0, "send the message after the given delay, e.g. 30m")
+	flag.BoolVar(&useSpool, "spool", false, "hand a delayed message (--at/--delay) off to the spool directory instead of blocking. spool messages which fail because the API or the proxy is unreachable")
+	flag.StringVar(&spoolDir, "spool-dir", defaultSpoolDir(), "spool directory")
+	flag.BoolVar(&flushSpool, "spool-flush", false, "send all due messages from the spool directory and exit")
+	flag.DurationVar(&dedupeWindow, "dedupe", 0, "skip a message if the same message was sent to the same recipient within the given time, e.g. 10m")
+	flag.StringVar(&dedupeFile, "dedupe-file", defaultDedupeFile(), "state file of --dedupe")
+	flag.StringVar(&journalFile, "journal", defaultJournalFile(), "append-only journal of the sent messages (JSON lines with time, recipient, message ID, content hash and result)")
+	flag.BoolVar(&noJournal, "no-journal", false, "do not write the journal of the sent messages")
+	flag.StringVar(&stateKeyFile, "state-key-file", "", "file with the key to encrypt the spool, the state files and the journal (default: NOTIFY_STATE_KEY)")
+	flag.StringVar(&templateName, "template", "", "name of the message template in the templates directory of the config file (-c)")
+	flag.Var(&templateVars, "var", "key=value of the message template, available as .Vars.key (repeatable)")
+	flag.StringVar(&recipientsFile, "recipients", "", "CSV file with a recipient (columns team, room or email) and template variables per row. sends the rendered message to every row")
+	flag.DurationVar(&digestWindow, "digest", 0, "serve, mqtt, daemon, k8s-watch: combine the messages to the same recipient within the given time into one digest message, e.g. 5m")
+	flag.DurationVar(&spoolInterval, "spool-interval", time.Minute, "serve, mqtt, daemon, k8s-watch: interval of sending the due messages of the spool (with --spool)")
+	flag.StringVar(&configFile, "c", "", "config file (JSON)")
+	flag.BoolVar(&runAsDaemon, "daemon", false, "run as recurring message daemon using the schedules of the config file (-c)")
+	flag.StringVar(&oauthLoginMode, "oauth-login", "", "authorize a Webex integration and store its tokens. mode: code (browser redirect) or device")
+	flag.StringVar(&oauthTokenFile, "oauth-token-file", defaultOAuthTokenFile(), "file with the OAuth tokens of a Webex integration. used if flag -T is not set")
+	flag.StringVar(&oauthClientID, "oauth-client-id", "", "client ID of the Webex integration")
+	flag.StringVar(&oauthSecret, "oauth-client-secret", "", "client secret of the Webex integration")
+	flag.StringVar(&oauthRedirect, "oauth-redirect-uri", "http://localhost:8080/callback", "redirect URI of the Webex integration (code mode)")
+	flag.StringVar(&oauthScopes, "oauth-scopes", "spark:all", "space separated OAuth scopes to request")
+	flag.StringVar(&guestIssuerID, "guest-issuer-id", "", "guest issuer ID. send as a guest persona instead of a bot")
+	flag.StringVar(&guestSecret, "guest-secret", "", "base64 encoded guest issuer secret")
+	flag.StringVar(&guestSubject, "guest-subject", "", "unique user ID of the guest persona (default: new throwaway guest per invocation)")
+	flag.StringVar(&guestName, "guest-name", "Notification Guest", "display name of the guest persona")
+	flag.StringVar(&caCertFile, "cacert", "", "PEM file with additional CA certificates to trust, e.g. of a TLS intercepting proxy")
+	flag.StringVar(&caPath, "capath", "", "directory with additional CA certificates (PEM) to trust")
+	flag.BoolVar(&tlsInsecure, "insecure", false, "skip TLS certificate verification (insecure, for testing only)")
+	flag.StringVar(&tlsMinVersion, "tls-min-version", "", "minimum TLS version: 1.2 or 1.3 (default 1.2)")
+	flag.StringVar(&tlsCiphers, "tls-ciphers", "", "comma separated TLS 1.2 cipher suites to allow, e.g. TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384 (default: all secure ones)")
+	flag.Var(&tlsPins, "tls-pin", "sha256/<base64 hash> of a public key of the certificate chain of the Webex API to pin (repeatable)")
+	flag.BoolVar(&traceHTTP, "trace", false, "write all HTTP requests and responses with headers and bodies to standard error, credentials redacted")
+	flag.StringVar(&traceFile, "trace-file", "", "write the trace of --trace to the file instead of standard error. implies --trace")
+	flag.BoolVar(&noProxy, "no-proxy", false, "do not use a proxy server, not even from the HTTP_PROXY/HTTPS_PROXY environment variables")
+	flag.DurationVar(&requestTimeout, "timeout", 2*time.Minute, "timeout for sending a message incl. room lookup and upload. 0 means no timeout")
+	flag.IntVar(&maxRetries, "max-retries", 3, "number of retries of a Webex API request answered with a status code of --retry-on. 0 means no retries")
+	flag.DurationVar(&retryInitWait, "retry-initial-wait", time.Second, "wait before the first retry without Retry-After header, doubled for every further retry")
+	flag.DurationVar(&retryMaxWait, "retry-max-wait", 0, "maximum wait before a retry, also of the Retry-After header. 0 means 1h")
+	flag.StringVar(&idempotencyKey, "idempotency-key", "", "send the message only once per recipient for this key, e.g. the ID of the workflow run. a retry with the same key is skipped")
+	flag.DurationVar(&idempotencyWin, "idempotency-window", 24*time.Hour, "time an idempotency key is remembered")
+	flag.StringVar(&idempotencyFile, "idempotency-file", defaultIdempotencyFile(), "state file of the idempotency keys")
+	flag.StringVar(&saveAs, "save-as", "", "save the ID of the sent message under this name, e.g. deploy-42, for --edit-ref and --delete-ref")
+	flag.StringVar(&editRef, "edit-ref", "", "edit the message saved by --save-as under this name")
+	flag.StringVar(&deleteRef, "delete-ref", "", "delete the message saved by --save-as under this name and remove the name")
+	flag.StringVar(&handlesFile, "handles-file", defaultHandlesFile(), "state file of the names of --save-as and the last messages of --reply-last")
+	flag.BoolVar(&replyLast, "reply-last", false, "send the message as reply in the thread of the last message sent to the room")
+	flag.StringVar(&textLang, "lang", "en", "language of the built-in card and message texts and their dates: de, en or fr")
+	flag.StringVar(&expandEnvList, "expand-env", "", "expand ${NAME} in -m, the message file, the card and the template files for the comma separated environment variables, e.g. 'CI_*,DEPLOY_ENV'")
+	flag.BoolVar(&scrubBuiltin, "scrub", false, "replace common token and password shapes in all messages, cards and uploaded text files by REDACTED")
+	flag.Var(&scrubPatterns, "scrub-pattern", "also replace the matches of this regular expression, only its first group if it has one, by REDACTED (repeatable)")
+	flag.StringVar(&footerMode, "footer", "", "add a footer to the message: auto (OS user, host, time and version) or a template, e.g. \"{{.Hostname}} backup job\"")
+	flag.StringVar(&idempotencyMode, "idempotency-check", "local", "where the idempotency keys are checked: local (state file) or room (also the recent messages of the bot in the room, with a marker in the message)")
+	flag.StringVar(&reportFile, "report", "", "recipients, manifest, multi: write the result of every target to this JSON file")
+	flag.StringVar(&failOn, "fail-on", "any", "recipients, manifest, multi: fail if any, all or at least <n>% of the targets failed, or never")
+	flag.IntVar(&circuitThresh, "circuit-threshold", 5, "serve, mqtt, daemon, k8s-watch: consecutive failed sends which open the circuit and pause the sends. 0 disables the circuit breaker")
+	flag.DurationVar(&circuitCooldown, "circuit-cooldown", time.Minute, "time the circuit stays open before a single send probes the Webex API")
+	flag.StringVar(&circuitAlertCmd, "circuit-alert-cmd", "", "command run when the circuit opens and closes, with the environment variable NOTIFY_CIRCUIT=open|closed")
+	flag.StringVar(&retryOn, "retry-on", "429", "comma separated status codes and classes of the responses to retry, e.g. 429,5xx")
+	flag.StringVar(&logFormat, "log-format", "text", "log format: text or json")
+	flag.StringVar(&logLevel, "log-level", "info", "log level: debug, info, warn or error")
+	flag.StringVar(&proxyAuth, "proxy-auth", "basic", "proxy authentication scheme: basic, ntlm or negotiate. user and password are taken from flag -p")
+	flag.StringVar(&proxyCertFile, "proxy-cert", "", "PEM file with the client certificate to present to the HTTPS proxy server of -p")
+	flag.StringVar(&proxyKeyFile, "proxy-key", "", "PEM file with the private key of --proxy-cert")
+	flag.StringVar(&editMessageId, "e", "", "edit message. provide message id, the new text is given by -m or -i")
+	flag.BoolVar(&memberModerator, "moderator", false, "members add: make the new members moderators")
+	flag.StringVar(&webhookName, "name", "notify_by_webex_teams", "webhooks create: name of the webhook")
+	flag.StringVar(&webhookTarget, "target-url", "", "webhooks create: URL the webhook events are posted to")
+	flag.StringVar(&webhookResource, "resource", "messages", "webhooks create: resource, e.g. messages, memberships or attachmentActions")
+	flag.StringVar(&webhookEvent, "event", "created", "webhooks create: event, e.g. created, updated, deleted or all")
+	flag.StringVar(&webhookFilter, "filter", "", "webhooks create: filter, e.g. roomId=<room id>")
+	flag.StringVar(&webhookSecret, "secret", "", "webhooks create: secret used to sign the webhook events. serve: reject webhook events without a valid signature")
+	flag.StringVar(&serveListen, "listen", "127.0.0.1:8080", "serve: listen address of the HTTP relay, e.g. :8080 for all interfaces")
+	flag.StringVar(&serveToken, "serve-token", "", "serve: bearer token required for POST /send")
+	flag.BoolVar(&chatops, "chatops", false, "serve: run the commands of the config file (-c) requested by messages to the bot and reply with the output. needs --secret")
+	flag.StringVar(&forwardURL, "forward-url", "", "serve: URL every card submission (attachmentActions webhook event) is POSTed to. needs --secret")
+	flag.StringVar(&forwardTemplate, "forward-template", "", "serve: file with the Go text/template of the forward-url payload. fields: .Action, .Event (default: the submission as JSON)")
+	flag.Var(&forwardHeaders, "forward-header", "serve: header of the forward-url requests, e.g. \"Authorization: Bearer <token>\" (repeatable)")
+	flag.BoolVar(&jiraWebhooks, "jira", false, "serve: post the Jira issue webhooks received on /jira as cards to the rooms of the projects in the config file (-c) or to -t/-r")
+	flag.StringVar(&jiraSecret, "jira-secret", "", "serve: reject Jira webhooks without a valid signature of this secret")
+	flag.BoolVar(&pagerDutyHooks, "pagerduty", false, "serve: post the incidents of the PagerDuty webhooks received on /pagerduty as one card per incident to the rooms of the services in the config file (-c) or to -t/-r")
+	flag.StringVar(&pagerDutySecret, "pagerduty-secret", "", "serve: reject PagerDuty webhooks without a valid signature of this secret")
+	flag.StringVar(&pagerDutyState, "pagerduty-state", defaultPagerDutyState(), "serve: file with the cards of the PagerDuty incidents")
+	flag.BoolVar(&sentryHooks, "sentry", false, "serve: post the Sentry issue alerts received on /sentry as cards, one per issue and --sentry-window, to the rooms of the projects in the config file (-c) or to -t/-r")
+	flag.StringVar(&sentrySecret, "sentry-secret", "", "serve: reject Sentry alerts without a valid signature of this client secret")
+	flag.DurationVar(&sentryWindow, "sentry-window", time.Hour, "serve: time the further alerts of a posted Sentry issue are aggregated")
+	flag.IntVar(&sentryThreshold, "sentry-threshold", 1, "serve: alerts of an issue within --sentry-window needed to post the issue again")
+	flag.BoolVar(&datadogHooks, "datadog", false, "serve: post the Datadog monitor alerts received on /datadog as cards to the rooms of the tags in the config file (-c) or to -t/-r")
+	flag.StringVar(&datadogSecret, "datadog-secret", "", "serve: reject Datadog alerts without this secret in the header X-Datadog-Secret")
+	flag.BoolVar(&alertmanagerHooks, "alertmanager", false, "serve: post the Prometheus Alertmanager alerts received on /alertmanager as cards to -t/-r or the rooms of the rules in the config file (-c)")
+	flag.StringVar(&alertmanagerSecret, "alertmanager-secret", "", "serve: reject Alertmanager alerts without this secret as bearer token")
+	flag.BoolVar(&grafanaHooks, "grafana", false, "serve: post the Grafana alerts received on /grafana as cards to -t/-r or the rooms of the rules in the config file (-c)")
+	flag.StringVar(&grafanaSecret, "grafana-secret", "", "serve: reject Grafana alerts without this secret as bearer token")
+	flag.BoolVar(&ackButton, "ack", false, "add an Acknowledge button to the card, a message without card is sent as card. serve: show who pressed the button on the card (attachmentActions webhook on /webhook). needs --secret")
+	flag.DurationVar(&escalateAfter, "escalate-after", 0, "post a card with --ack which is not acknowledged within the given time again to --escalate-room, e.g. 15m. the CLI waits for it")
+	flag.StringVar(&escalateRoom, "escalate-room", "", "escalation room of --escalate-after, in the team of the card or -t")
+	flag.Var(&escalateMention, "escalate-mention", "email address of a person to @mention in the escalation (repeatable, default: the mentions of the message)")
+	flag.StringVar(&onCallFile, "oncall-file", "", "send the message to the person on call now by the given YAML schedule file instead of to the room of -t/-r")
+	flag.StringVar(&onCallPD, "oncall-pagerduty", "", "send the message to the person on call now of the given PagerDuty schedule ID (API token in PAGERDUTY_TOKEN)")
+	flag.StringVar(&onCallOpsgenie, "oncall-opsgenie", "", "send the message to the person on call now of the given Opsgenie schedule name or ID (API key in OPSGENIE_API_KEY)")
+	flag.BoolVar(&onCallCC, "oncall-cc", false, "send the message of --oncall-* to the room of -t/-r as well")
+	flag.StringVar(&allowIPs, "allow-ip", "", "serve: comma separated IP addresses and CIDR ranges allowed to post webhook events, e.g. 10.0.0.0/8 (default: all)")
+	flag.BoolVar(&showPreview, "preview", false, "render the message and card to the terminal instead of sending it")
+	flag.StringVar(&previewHTML, "preview-html", "", "render the message and card to the given HTML file instead of sending it")
+	flag.StringVar(&sinceString, "since", "", "export, search: only messages since, e.g. 30d, 12h, 2006-01-02 or RFC3339")
+	flag.StringVar(&searchMatch, "match", "", "search, purge: text to search for (case insensitive)")
+	flag.Var(&mentionEmails, "mention", "email address of a person to @mention in the message (repeatable)")
+	flag.Var(&labelList, "labels", "labels of the message, e.g. team=db,env=prod. without -t and -D the routing rules of the config file (-c) choose the room by severity, labels and template")
+	flag.BoolVar(&mentionAll, "mention-all", false, "notify everyone in the room with the group mention @all. needs confirmation")
+	flag.BoolVar(&confirmAll, "confirm-mention-all", false, "confirm --mention-all without asking, e.g. in scripts. serve: allow mentionAll in relayed notifications")
+	flag.BoolVar(&purgeMessages, "purge", false, "delete the messages of the bot in room -r selected by --older-than and/or --match")
+	flag.StringVar(&olderThan, "older-than", "", "purge: only messages older than, e.g. 7d, 12h, 2006-01-02 or RFC3339")
+	flag.BoolVar(&dryRun, "dry-run", false, "purge: list the messages instead of deleting them. recipients: print the rendered messages instead of sending them. provision, apply: print the changes instead of making them")
+	flag.BoolVar(&pruneMembers, "prune", false, "provision, apply: remove the members which are not listed from the rooms with members")
+	flag.BoolVar(&lockedRooms, "locked", false, "create missing rooms locked (moderated). the bot becomes their moderator")
+	flag.BoolVar(&announceRooms, "announcement", false, "create missing rooms, rooms lock: only moderators may post (implies --locked)")
+	flag.StringVar(&severityName, "severity", "", "severity of the message: info, warning, critical or a severity of the config file (-c). adds emoji, card color and @mentions")
+	flag.BoolVar(&noEmoji, "no-emoji", false, "do not expand emoji shortcodes like :warning: in the message")
+	flag.StringVar(&serviceName, "service-name", "notify_by_webex_teams", "service: name of the system service")
+	flag.StringVar(&serviceRunName, "run-as-service", "", "run as the system service with the given name. set by service install")
+	flag.StringVar(&meetingTitle, "title", "Incident bridge", "meeting create: title of the meeting")
+	flag.DurationVar(&meetingDuration, "duration", time.Hour, "meeting create: duration of the meeting")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "serve, mqtt, daemon, k8s-watch: time to finish the sends in progress after SIGTERM or SIGINT")
+	flag.BoolVar(&deleteWebhooks, "deregister-webhooks", false, "serve: delete the webhooks posting to --target-url on shutdown")
+	flag.StringVar(&metricsListen, "metrics-listen", "", "mqtt, daemon, k8s-watch: listen address of /metrics, /healthz and /readyz, e.g. :9090")
+	flag.StringVar(&outputFile, "o", "", "export, thread: output file. the format is taken from the extension: .json, .csv or .html (export only) (default: JSON to standard output)")
+
+	for long, short := range map[string]string{
+		"token":     "T",
+		"team":      "t",
+		"room":      "r",
+		"file":      "f",
+		"message":   "m",
+		"proxy":     "p",
+		"delete":    "d",
+		"edit":      "e",
+		"card":      "a",
+		"card-file": "A",
+		"version":   "V",
+		"stdin":     "i",
+		"email":     "D",
+		"config":    "c",
+	} {
+		aliasFlag(long, short)
+	}
 }
 
-func createMessageAndAttachmentsToRoom(markdownMsg, roomID, attachment string) (string, error) {
-
-	b := new(bytes.Buffer)
-	/*
-		b.WriteString(`{"roomId": "`)
-		b.WriteString(roomID)
-		b.WriteString(`", `)
-		b.WriteString(`"markdown": "`)
-		b.WriteString(markdownMsg)
-		b.WriteString(`", `)
-		b.WriteString(`"attachments": [`)
-		b.WriteString(attachment)
-		b.WriteString(`]`)
-		b.WriteString(`}`)
-
-		log.Printf("postData: %s\n", b.String())
-	*/
-	postDataStr := fmt.Sprintf("{ \"roomId\": \"%s\", \"markdown\": \"%s\", \"attachments\": [ %s ]}", roomID, markdownMsg, attachment)
-	log.Printf("postDataStr: %s\n", postDataStr)
-	b.WriteString(postDataStr)
-
-	resp, err := webexTeamsRequest(apiToken, proxyString, "POST", messagesURL, nil, b)
-	if err != nil {
-		return "", err
-	}
-	log.Printf("createMessageAndAttachmentsToRoom() HTTP status code: %d", resp.StatusCode)
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-	resp.Body.Close()
+// aliasFlag registers alias as another name of the flag name. Both names
+// share the same value.
+func aliasFlag(alias, name string) {
+	f := flag.Lookup(name)
+	flag.Var(f.Value, alias, "alias for -"+name)
+}
 
-	var m Message
-	err = json.Unmarshal(body, &m)
+// webexClient returns the Webex API client using the token, proxy and TLS
+// settings of the flags. The client is built once and shared by all
+// requests of the process, so connections are reused.
+func webexClient() (*webex.Client, error) {
+	httpClient, err := sharedHTTPClient()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	log.Printf("createMessageAndAttachmentsToRoom() message ID: %s", m.ID)
-	log.Printf("createMessageAndAttachmentsToRoom() message created: %s", m.Created)
-	// log.Printf("createMessageToRoom body: %s\n", body)
-	return "", err
+	webexClientOnce.Do(func() {
+		sharedWebexClient = &webex.Client{
+			TokenSource: webex.TokenFunc(accessToken),
+			BaseURL:     apiBaseURL,
+			HTTPClient:  httpClient,
+			Logger:      slog.Default(),
+
+			RateLimitRetries: maxRetries,
+			RetryInitialWait: retryInitWait,
+			RetryMaxWait:     retryMaxWait,
+			RetryOn:          retryStatus,
+			OnRequest:        observeRequest,
+			FilterMessage:    scrubMessage,
+			FilterFile:       scrubFile,
+		}
+	})
+	return sharedWebexClient, nil
 }
 
-func createMessageAndUploadToRoom(markdownMsg, roomID, uploadFile string) (string, error) {
+// apiBaseURLs are the short names of the Webex API URLs of --api-url and
+// of the profiles.
+var apiBaseURLs = map[string]string{
+	"commercial": webex.DefaultBaseURL,
+	"usgov":      webex.USGovBaseURL,
+}
 
-	extraParams := map[string]string{
-		"roomId":   roomID,
-		"markdown": markdownMsg,
-		"roomType": "group",
+// resolveAPIURL returns the Webex API URL of the given short name or URL.
+func resolveAPIURL(s string) (string, error) {
+	if u, ok := apiBaseURLs[strings.ToLower(s)]; ok || len(s) == 0 {
+		return u, nil
 	}
-
-	log.Printf("file to upload: %s\n", uploadFile)
-	request, err := newfileUploadRequest(messagesURL, extraParams, "files", uploadFile)
-	// log.Printf("newfileUploadRequest: %+v\n", request)
-	if err != nil {
-		return "", err
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return "", fmt.Errorf("invalid API URL %q. use usgov or a URL, e.g. https://webexapis.com/v1", s)
 	}
+	return strings.TrimSuffix(s, "/"), nil
+}
 
-	client := &http.Client{}
-	if len(proxyString) > 0 {
-		proxyURL, err := url.Parse(proxyString)
-		if err != nil {
-			log.Fatal(err)
-		}
-		tr := &http.Transport{
-			Proxy: http.ProxyURL(proxyURL),
-			// TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client.Transport = tr
-	}
-
-	authBearer := fmt.Sprintf("Bearer %s", apiToken)
-	request.Header.Add("Authorization", authBearer)
-
-	log.Printf("request.ContentLength %d\n", request.ContentLength)
-	// fmt.Printf("request.Header: %#v\n", request.Header)
-	resp, err := client.Do(request)
-	if err != nil {
-		log.Printf("request error\n")
-		log.Fatal(err)
-	} else {
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatal(err)
-		}
-		resp.Body.Close()
-		log.Printf("createMessageAndUploadToRoom() HTTP status code: %d", resp.StatusCode)
+// apiURL returns the URL of the given path of the Webex API, e.g. /authorize.
+func apiURL(path string) string {
+	return firstNonEmpty(apiBaseURL, webex.DefaultBaseURL) + path
+}
 
-		var m Message
-		err = json.Unmarshal(body, &m)
-		if err != nil {
-			log.Fatal(err)
-		}
-		log.Printf("createMessageAndUploadToRoom() message ID: %s", m.ID)
-		log.Printf("createMessageAndUploadToRoom() message created: %s", m.Created)
+// requestContext returns the context for the API requests of a single
+// message, limited by flag --timeout.
+func requestContext() (context.Context, context.CancelFunc) {
+	if requestTimeout > 0 {
+		return context.WithTimeout(context.Background(), requestTimeout)
 	}
-	return "", err
+	return context.WithCancel(context.Background())
 }
 
-func createPngFormFile(w *multipart.Writer, fieldname, filename string) (io.Writer, error) {
-	h := make(textproto.MIMEHeader)
-	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, fieldname, filename))
-	h.Set("Content-Type", "image/png;")
-	return w.CreatePart(h)
+// lookupRoomID returns the ID of the room with the given name in the team
+// with the given name. The room is created if it does not exist.
+func lookupRoomID(ctx context.Context, team, room string) (string, error) {
+	return lookupProfileRoomID(ctx, "", team, room)
 }
 
-// Creates a new file upload http request with optional extra params
-func newfileUploadRequest(uri string, params map[string]string, fieldname, uploadFile string) (*http.Request, error) {
-	buf := new(bytes.Buffer)
-	w := multipart.NewWriter(buf)
-
-	fw, err := createPngFormFile(w, fieldname, uploadFile)
-	if err != nil {
-		log.Println(err)
-	}
-	fd, err := os.Open(uploadFile)
-	if err != nil {
-		log.Println(err)
+// lookupProfileRoomID is lookupRoomID with the bot of the given profile, see
+// profile.go.
+func lookupProfileRoomID(ctx context.Context, profile, team, room string) (string, error) {
+	key := profile + "\x00" + team + "\x00" + room
+	roomIDsMutex.Lock()
+	cached, ok := roomIDs[key]
+	roomIDsMutex.Unlock()
+	if ok && time.Since(cached.found) < roomIDsTTL {
+		return cached.id, nil
 	}
-	defer fd.Close()
 
-	_, err = io.Copy(fw, fd)
+	client, err := profileClient(profile)
 	if err != nil {
-		log.Println(err)
+		return "", err
 	}
-
-	for key, val := range params {
-		err = w.WriteField(key, val)
-		if err != nil {
-			log.Println(err)
-		}
+	teamID, err := client.TeamIDByName(ctx, team)
+	if err != nil {
+		return "", err
 	}
-
-	// Important if you do not close the multipart writer you will not have a
-	// terminating boundry
-	w.Close()
-
-	req, err := http.NewRequest("POST", uri, buf)
+	slog.Debug("team found", "teamID", teamID)
+
+	roomID, err := client.FindOrCreateRoomWith(ctx, &webex.RoomRequest{
+		TeamID:             teamID,
+		Title:              room,
+		IsLocked:           lockedRooms || announceRooms,
+		IsAnnouncementOnly: announceRooms,
+	})
 	if err != nil {
-		log.Println(err)
+		return "", err
 	}
-	req.Header.Set("Content-Type", w.FormDataContentType())
-
-	return req, err
+	roomIDsMutex.Lock()
+	roomIDs[key] = cachedRoom{id: roomID, found: time.Now()}
+	roomIDsMutex.Unlock()
+	return roomID, nil
 }
 
-func webexTeamsRequest(apiToken string,
-	proxyString string,
-	method string,
-	baseURL string,
-	values url.Values,
-	buf io.Reader) (*http.Response, error) {
-
-	var resp *http.Response
-
-	authBearer := fmt.Sprintf("Bearer %s", apiToken)
+// roomIDsTTL is how long lookupRoomID keeps a room ID, e.g. for the
+// messages of --multi and the long-running modes.
+const roomIDsTTL = 10 * time.Minute
 
-	uriAndValues := fmt.Sprintf("%s?%s", baseURL, values.Encode())
-	log.Printf("webexTeamsRequest() uriAndValues: %s\n", uriAndValues)
-	req, err := http.NewRequest(method, uriAndValues, buf)
-
-	client := &http.Client{}
-	if len(proxyString) > 0 {
-		proxyURL, err := url.Parse(proxyString)
-		if err != nil {
-			return resp, err
-		}
-		tr := &http.Transport{
-			Proxy: http.ProxyURL(proxyURL),
-			// TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client.Transport = tr
-	}
+type cachedRoom struct {
+	id    string
+	found time.Time
+}
 
-	req.Header.Add("Content-Type", "application/json; charset=utf-8")
-	req.Header.Add("Authorization", authBearer)
+var (
+	roomIDsMutex sync.Mutex
+	roomIDs      = make(map[string]cachedRoom)
+)
 
-	resp, err = client.Do(req)
+// readMessage reads the message from r. CR LF line endings are converted to
+// LF unless keepCRLF is set, everything else is kept byte for byte.
+func readMessage(r io.Reader, keepCRLF bool) (string, error) {
+	data, err := io.ReadAll(r)
 	if err != nil {
-		return resp, err
+		return "", fmt.Errorf("reading the message from standard input: %v", err)
+	}
+	if !utf8.Valid(data) {
+		return "", errors.New("standard input is not UTF-8 text. use flag -f to send files")
 	}
-	return resp, nil
+	if !keepCRLF {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	return string(data), nil
 }
 
-func getTeamIDByName(name string) (string, error) {
-	queryValues := url.Values{}
-	queryValues.Add("type", "group")
+func main() {
+	cmd, args := findCommand(os.Args[1:])
+	args = parseFlags(args)
 
-	log.Printf("roomsURL: %s", roomsURL)
-	resp, err := webexTeamsRequest(apiToken, proxyString, "GET", roomsURL, queryValues, nil)
+	registerFlagSecrets()
+	err := setupLogging(logFormat, logLevel)
 	if err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	log.Printf("getTeamIDByName() HTTP status code: %d", resp.StatusCode)
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
+	if err := applyProfile(); err != nil {
+		fatal(err)
 	}
-
-	var rr roomsResp
-	err = json.Unmarshal(body, &rr)
-	if err != nil {
-		log.Fatal(err)
+	if apiBaseURL, err = resolveAPIURL(apiBaseURL); err != nil {
+		fatal(err)
+	}
+	if err := applyTokenSource(); err != nil {
+		fatal(err)
+	}
+	if err := applyTLSOptions(); err != nil {
+		fatal(err)
+	}
+	if err := applyRetryFlags(); err != nil {
+		fatal(err)
+	}
+	if _, err := failsBatch(failOn, 0, 0); err != nil {
+		fatal(err)
+	}
+	if err := checkIdempotency(); err != nil {
+		fatal(err)
+	}
+	if err := checkLang(); err != nil {
+		fatal(err)
+	}
+	if err := applyScrubOptions(); err != nil {
+		fatal(err)
 	}
 
-	for _, v := range rr.Items {
-		if v.Title == name {
-			return v.TeamID, nil
+	if len(serviceRunName) > 0 {
+		err := startService(serviceRunName)
+		if err != nil {
+			fatal(err)
 		}
 	}
-	errMessage := fmt.Sprintf("No room with name: %s was found\n", name)
-	return "", errors.New(errMessage)
-}
-
-func createRoomAndGetRoom(teamID string, name string) (string, error) {
-	queryValues := url.Values{}
-	queryValues.Add("teamId", teamID)
-	queryValues.Add("type", "group")
 
-	resp, err := webexTeamsRequest(apiToken, proxyString, "GET", roomsURL, queryValues, nil)
-	if err != nil {
-		log.Fatal(err)
+	// before the message of -i is added, which is never expanded
+	markdownMsg = expandEnv(markdownMsg, nil)
+	if useStdIn {
+		text, err := readMessage(os.Stdin, keepCRLF)
+		if err != nil {
+			fatal(err)
+		}
+		err = addMessageInput(text, ".")
+		if err != nil {
+			fatal(err)
+		}
 	}
-	log.Printf("createRoomAndGetRoom() HTTP status code: %d", resp.StatusCode)
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
+	if len(messageFile) > 0 {
+		err := readMessageFile(messageFile)
+		if err != nil {
+			fatal(err)
+		}
 	}
-
-	var rr roomsResp
-	err = json.Unmarshal(body, &rr)
-	if err != nil {
-		log.Fatal(err)
+	if len(codeFile) > 0 {
+		err := appendCode(codeFile, codeLang)
+		if err != nil {
+			fatal(err)
+		}
 	}
-
-	for _, v := range rr.Items {
-		if v.Title == name {
-			return v.ID, nil
+	if len(tableFile) > 0 {
+		err := appendTable(tableFile)
+		if err != nil {
+			fatal(err)
 		}
 	}
-
-	log.Printf("room name >>%s<< not found\n", name)
-
-	roomID, err := createRoom(name, teamID)
-	if err != nil {
-		log.Fatal(err)
+	if gitPush {
+		err := appendGitPush(os.Stdin)
+		if err != nil {
+			fatal(err)
+		}
 	}
-	return roomID, nil
-}
-
-func createRoom(roomTitle, teamID string) (string, error) {
 
-	var nr SparkRoom
-
-	type NewSparkRoom struct {
-		TeamID string `json:"teamId"`
-		Title  string `json:"title"`
+	if showVersion {
+		fmt.Printf("%s version: %s\n", path.Base(os.Args[0]), version)
+		os.Exit(0)
 	}
 
-	newRoom := &NewSparkRoom{TeamID: teamID, Title: roomTitle}
-
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(newRoom)
-	fmt.Printf("createRoom json: %s\n", b.String())
-	// bytes, err := s.PostRequest(RoomsUrl, b, "")
-	resp, err := webexTeamsRequest(apiToken, proxyString, "POST", roomsURL, nil, b)
-	if err != nil {
-		log.Fatal(err)
+	if cmd != nil {
+		err := runCommand(cmd, args)
+		if err != nil {
+			fatal(err)
+		}
+		stopService()
+		os.Exit(0)
 	}
-	log.Printf("createRoom() HTTP status code: %d", resp.StatusCode)
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
+	if len(args) > 0 {
+		fatal(fmt.Errorf("unknown command %q. see %s -h", args[0], path.Base(os.Args[0])))
 	}
 
-	if err != nil {
-		return nr.Id, err
+	if len(oauthLoginMode) > 0 {
+		err := oauthLogin(oauthLoginMode)
+		if err != nil {
+			fatal(err)
+		}
+		os.Exit(0)
 	}
-	err = json.Unmarshal(body, &nr)
-	return nr.Id, err
-}
-
-func createMessageDirect(messageText, toPersonEmail string) (string, error) {
 
-	type NewSparkMessage struct {
-		ToPersonEmail string `json:"toPersonEmail"`
-		Markdown      string `json:"markdown"`
+	if len(metricsListen) > 0 && (len(mqttBroker) > 0 || runAsDaemon || k8sWatch) {
+		err := startMetricsServer(metricsListen)
+		if err != nil {
+			fatal(err)
+		}
 	}
 
-	newMessage := &NewSparkMessage{ToPersonEmail: toPersonEmail, Markdown: messageText}
+	if len(mqttBroker) > 0 {
+		err := runMQTTBridge()
+		if err != nil {
+			fatal(err)
+		}
+		stopService()
+		os.Exit(0)
+	}
 
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(newMessage)
+	if k8sWatch {
+		err := runK8sWatch()
+		if err != nil {
+			fatal(err)
+		}
+		stopService()
+		os.Exit(0)
+	}
 
-	resp, err := webexTeamsRequest(apiToken, proxyString, "POST", messagesURL, nil, b)
-	if err != nil {
-		return "", err
+	if runAsDaemon {
+		if len(configFile) == 0 {
+			fatal(errors.New("no config file. use flag -c"))
+		}
+		c, err := loadConfig(configFile)
+		if err != nil {
+			fatal(err)
+		}
+		err = runDaemon(c)
+		if err != nil {
+			fatal(err)
+		}
+		stopService()
+		os.Exit(0)
 	}
-	log.Printf("createMessageDirect() HTTP status code: %d", resp.StatusCode)
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+
+	if flushSpool {
+		err := flushSpoolDir()
+		if err != nil {
+			fatal(err)
+		}
+		os.Exit(0)
 	}
-	resp.Body.Close()
-	log.Printf("createMessageDirect() HTTP status code: %d", resp.StatusCode)
 
-	var m Message
-	err = json.Unmarshal(body, &m)
+	switch {
+	case len(deleteMessageId) > 0 || len(deleteRef) > 0 || purgeMessages:
+		err = cmdDelete("", nil)
+	case len(editMessageId) > 0 || len(editRef) > 0:
+		err = cmdEdit("", nil)
+	case multiDoc:
+		err = sendDocuments()
+	case len(manifestFile) > 0:
+		err = sendManifest(manifestFile)
+	default:
+		err = cmdSend("", nil)
+	}
 	if err != nil {
-		return "", err
+		fatal(err)
 	}
-	log.Printf("createMessageDirect() message ID: %s", m.ID)
-	log.Printf("createMessageDirect() message Created: %s", m.Created)
-	log.Printf("createMessageDirect() message RoomID: %s", m.RoomID)
-	return m.RoomID, nil
 }
 
-func createMessageToRoom(messageText, roomID string) (string, error) {
-
-	type NewSparkMessage struct {
-		RoomID   string `json:"roomId"`
-		Markdown string `json:"markdown"`
-	}
+// notification describes a single message to send. It is also the format of
+// the files in the spool directory, so it must never contain the bot token.
+type notification struct {
+	TeamName string `json:"teamName,omitempty"`
+	RoomName string `json:"roomName,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Markdown string `json:"markdown"`
+	File     string `json:"file,omitempty"`
+	Card     string `json:"card,omitempty"`
+	// Files are several files, uploaded concurrently, see upload.go
+	Files []string `json:"files,omitempty"`
+	// Mentions are the email addresses of the people to @mention
+	Mentions []string `json:"mentions,omitempty"`
+	// MentionAll puts the group mention <@all> in front of the message
+	MentionAll bool `json:"mentionAll,omitempty"`
+	// Severity formats the message, e.g. "critical", see severity.go
+	Severity string `json:"severity,omitempty"`
+	// Template is the name of a message template with its Vars, see template.go
+	Template string            `json:"template,omitempty"`
+	Vars     map[string]string `json:"vars,omitempty"`
+	// Ack adds an Acknowledge button to the card, see ack.go
+	Ack bool `json:"ack,omitempty"`
+	// Profile is the bot of the config file sending the message, see profile.go
+	Profile string `json:"profile,omitempty"`
+	// Labels select the recipient by the routing rules, see routing.go
+	Labels map[string]string `json:"labels,omitempty"`
+	// IdempotencyKey is sent only once per recipient, see idempotency.go
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// ReplyLast threads the message under the last message sent to the
+	// room, see handles.go
+	ReplyLast bool `json:"replyLast,omitempty"`
+	// ChecksumsAdded is set once the checksums of the files are added to
+	// the message, see checksum.go
+	ChecksumsAdded bool `json:"checksumsAdded,omitempty"`
+	// DirectRoomID is the room of the private 1:1 message once it is sent,
+	// so a spooled or retried message sends only the rest
+	DirectRoomID string `json:"directRoomID,omitempty"`
+}
 
-	newMessage := &NewSparkMessage{RoomID: roomID, Markdown: messageText}
+// sendNotification sends n as a private 1:1 message and/or to the team room.
+// The result is counted in the metrics and written to the journal.
+func sendNotification(ctx context.Context, n *notification) error {
+	_, err := sendMessage(ctx, n)
+	return err
+}
 
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(newMessage)
+// sendMessage is sendNotification returning the sent message, nil for a
+// suppressed duplicate.
+func sendMessage(ctx context.Context, n *notification) (*webex.Message, error) {
+	m, err := deliverNotification(ctx, n)
+	journalNotification(n, m, err)
+	switch {
+	case errors.Is(err, errIdempotent):
+		slog.Info("duplicate message suppressed", "idempotencyKey", n.IdempotencyKey, "window", idempotencyWin)
+		countSuppressed()
+		return nil, nil
+	case errors.Is(err, errDuplicate):
+		slog.Info("duplicate message suppressed", "window", dedupeWindow)
+		countSuppressed()
+		return nil, nil
+	}
+	countNotification(err)
+	return m, err
+}
 
-	resp, err := webexTeamsRequest(apiToken, proxyString, "POST", messagesURL, nil, b)
+func deliverNotification(ctx context.Context, n *notification) (sent *webex.Message, err error) {
+	client, err := profileClient(n.Profile)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	log.Printf("createMessageToRoom() HTTP status code: %d", resp.StatusCode)
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+	if err := checkFiles(n); err != nil {
+		return nil, err
 	}
-	resp.Body.Close()
-	log.Printf("createMessageToRoom() HTTP status code: %d", resp.StatusCode)
-
-	var m Message
-	err = json.Unmarshal(body, &m)
-	if err != nil {
-		log.Fatal(err)
+	files := notificationFiles(n)
+	if err := preUploadCheck(files); err != nil {
+		return nil, err
 	}
-	log.Printf("createMessageToRoom() message ID: %s", m.ID)
-	log.Printf("createMessageToRoom() message created: %s", m.Created)
-	// log.Printf("createMessageToRoom body: %s\n", body)
-	return "", err
-}
-
-func deleteMessage(messageID string) error {
-	url := fmt.Sprintf("%s/%s", messagesURL, messageID)
-	resp, err := webexTeamsRequest(apiToken, proxyString, "DELETE", url, nil, nil)
-	if err != nil {
-		return err
+	// the card of the button is colored by the severity
+	if err := withAckButton(n); err != nil {
+		return nil, err
 	}
-	log.Printf("deleteMessage() HTTP status code: %d", resp.StatusCode)
-	_, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	if err := applySeverity(n); err != nil {
+		return nil, err
 	}
-	return nil
-}
-
-func main() {
-	flag.Parse()
-
-	lineSeparator := byte('\n')
-	if runtime.GOOS == "darwin" {
-		lineSeparator = byte('\r')
+	if !noEmoji {
+		n.Markdown = expandEmoji(n.Markdown)
 	}
-
-	if useStdIn {
-		reader := bufio.NewReader(os.Stdin)
-		for {
-			line, err := reader.ReadString(lineSeparator)
-			line = strings.TrimSuffix(line, "\r")
-			line = strings.TrimSuffix(line, "\r\n")
-			markdownMsg += line + "\n"
-			if err == io.EOF {
-				break
-			}
-		}
+	if err := applyChecksums(n); err != nil {
+		return nil, err
 	}
-
-	if len(markdownMsg) == 0 {
-		fmt.Println("no message. use flag -m or flag -i")
+	if err := offloadLargeFiles(ctx, n); err != nil {
+		return nil, err
 	}
 
-	if showVersion {
-		fmt.Printf("%s version: %s\n", path.Base(os.Args[0]), version)
-		os.Exit(0)
+	if dedupeWindow > 0 {
+		key := dedupeKey(n)
+		first, claimErr := claimMessage(key, dedupeWindow)
+		if claimErr != nil {
+			return nil, claimErr
+		}
+		if !first {
+			return nil, errDuplicate
+		}
+		defer func() {
+			if err != nil {
+				releaseMessage(key)
+			}
+		}()
 	}
-
-	if len(deleteMessageId) > 0 {
-		err := deleteMessage(deleteMessageId)
-		if err != nil {
-			log.Fatal(err)
+	if len(n.IdempotencyKey) > 0 {
+		if err := claimIdempotencyKey(n); err != nil {
+			return nil, err
 		}
-		os.Exit(0)
+		defer func() {
+			// a key found in the room stays claimed
+			if err != nil && !errors.Is(err, errIdempotent) {
+				releaseIdempotencyKey(n)
+			}
+		}()
+	}
+
+	markdown, err := withMentions(ctx, client, n.Mentions, withMentionAll(n.MentionAll, n.Markdown))
+	if err != nil {
+		return nil, err
 	}
+	markdown = withIdempotencyMarker(n.IdempotencyKey, markdown)
 
-	var roomID string
 	// sending a private 1:1 message if emailAddr is set
-	if len(emailAddr) > 0 {
-		var err error
-		roomID, err = createMessageDirect(markdownMsg, emailAddr)
+	if len(n.Email) > 0 && len(n.DirectRoomID) == 0 {
+		m, err := client.CreateMessage(ctx, &webex.MessageRequest{ToPersonEmail: n.Email, Markdown: markdown})
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
+		n.DirectRoomID = m.RoomID
 	}
+	roomID := n.DirectRoomID
 
-	if len(teamName) > 0 {
-		teamID, err := getTeamIDByName(teamName)
+	if len(n.TeamName) > 0 {
+		roomID, err = lookupProfileRoomID(ctx, n.Profile, n.TeamName, n.RoomName)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
+		}
+		if err := checkRoomIdempotency(ctx, client, roomID, n.IdempotencyKey); err != nil {
+			return nil, err
 		}
-		log.Printf("teamID: %s\n", teamID)
+	}
 
-		roomID, err = createRoomAndGetRoom(teamID, roomName)
-		if err != nil {
-			log.Fatal(err)
+	var parentID string
+	if n.ReplyLast && len(roomID) > 0 {
+		if parentID, err = lastThread(roomID); err != nil {
+			return nil, fmt.Errorf("--reply-last: %w", err)
 		}
 	}
 
-	log.Printf("roomID: %s\n", roomID)
+	slog.Debug("target room", "roomID", roomID, "parentID", parentID)
 
-	if len(cardAttachment) > 0 {
-		_, err := createMessageAndAttachmentsToRoom(markdownMsg, roomID, cardAttachment)
+	if len(n.Card) > 0 {
+		m, err := client.CreateMessage(ctx, &webex.MessageRequest{
+			RoomID:      roomID,
+			ParentID:    parentID,
+			Markdown:    markdown,
+			Attachments: []json.RawMessage{json.RawMessage(n.Card)},
+		})
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		os.Exit(0)
+		watchEscalation(n, m)
+		return m, nil
 	}
 
-	if len(uploadFile) > 0 {
-		_, err := createMessageAndUploadToRoom(markdownMsg, roomID, uploadFile)
-		if err != nil {
-			log.Fatal(err)
-		}
+	if len(n.Files) > 0 {
+		return sendFiles(ctx, client, roomID, parentID, markdown, n.Files)
+	}
 
-	} else {
-		_, err := createMessageToRoom(markdownMsg, roomID)
+	if len(n.File) > 0 {
+		file, cleanup, err := shrinkImage(n.File, int64(maxImageSize))
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
+		defer cleanup()
+		return client.UploadFileWith(ctx, &webex.MessageRequest{RoomID: roomID, ParentID: parentID, Markdown: markdown}, file)
 	}
 
+	return client.CreateMessage(ctx, &webex.MessageRequest{RoomID: roomID, ParentID: parentID, Markdown: markdown})
 }
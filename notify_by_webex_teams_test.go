@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex/webextest"
+)
+
+// useFakeWebex points the Webex client of the flags to a fake Webex API
+// server for the test. Nothing is written to the journal.
+func useFakeWebex(t *testing.T) *webextest.Server {
+	fake := webextest.NewServer("test-token")
+	reset := func() {
+		webexClientOnce, sharedWebexClient = sync.Once{}, nil
+		roomIDsMutex.Lock()
+		roomIDs = make(map[string]cachedRoom)
+		roomIDsMutex.Unlock()
+		circuit = circuitBreaker{}
+	}
+	reset()
+	apiToken, apiBaseURL, noJournal = "test-token", fake.BaseURL(), true
+	t.Cleanup(func() {
+		fake.Close()
+		reset()
+		apiToken, apiBaseURL, noJournal = "", "", false
+	})
+	return fake
+}
+
+// captureStdout returns what f writes to stdout.
+func captureStdout(t *testing.T, f func()) string {
+	file, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	defer func(stdout *os.File) { os.Stdout = stdout }(os.Stdout)
+	os.Stdout = file
+	f()
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReadMessage(t *testing.T) {
+	for _, tt := range []struct {
+		in       string
+		keepCRLF bool
+		want     string
+		wantErr  string
+	}{
+		{in: "line 1\r\nline 2\r\n", want: "line 1\nline 2\n"},
+		{in: "line 1\r\nline 2", keepCRLF: true, want: "line 1\r\nline 2"},
+		// single CR, tabs, leading spaces and missing final newline are kept
+		{in: "  10%\r20%\tdone", want: "  10%\r20%\tdone"},
+		{in: "Grüße\n\n", want: "Grüße\n\n"},
+		{in: "", want: ""},
+		{in: "PK\x03\x04\xff\xfe", wantErr: "standard input is not UTF-8 text. use flag -f to send files"},
+	} {
+		got, err := readMessage(strings.NewReader(tt.in), tt.keepCRLF)
+		if got != tt.want || errorString(err) != tt.wantErr {
+			t.Errorf("readMessage(%q, %v) = %q, %v, want %q, %s", tt.in, tt.keepCRLF, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// ntlm.go
+//
+// NTLMv2 authentication messages (MS-NLMP) for proxy servers which require
+// NTLM or Negotiate authentication. Only the client side is implemented.
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	ntlmNegotiateUnicode                 = 0x00000001
+	ntlmRequestTarget                    = 0x00000004
+	ntlmNegotiateNTLM                    = 0x00000200
+	ntlmNegotiateAlwaysSign              = 0x00008000
+	ntlmNegotiateExtendedSessionSecurity = 0x00080000
+	ntlmNegotiateTargetInfo              = 0x00800000
+	ntlmNegotiate128                     = 0x20000000
+	ntlmNegotiate56                      = 0x80000000
+
+	ntlmNegotiateFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM |
+		ntlmNegotiateAlwaysSign | ntlmNegotiateExtendedSessionSecurity | ntlmNegotiateTargetInfo |
+		ntlmNegotiate128 | ntlmNegotiate56
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmCredentials holds the user of an NTLM authentication. The domain is
+// taken from user names of the form DOMAIN\user or user@domain.
+type ntlmCredentials struct {
+	domain   string
+	user     string
+	password string
+}
+
+func newNTLMCredentials(user, password string) *ntlmCredentials {
+	c := &ntlmCredentials{user: user, password: password}
+	if i := strings.Index(user, `\`); i >= 0 {
+		c.domain = user[:i]
+		c.user = user[i+1:]
+	} else if i := strings.LastIndex(user, "@"); i >= 0 {
+		c.domain = user[i+1:]
+		c.user = user[:i]
+	}
+	return c
+}
+
+// negotiateMessage returns the NTLM type 1 message.
+func (c *ntlmCredentials) negotiateMessage() []byte {
+	m := make([]byte, 32)
+	copy(m, ntlmSignature)
+	binary.LittleEndian.PutUint32(m[8:], 1)
+	binary.LittleEndian.PutUint32(m[12:], ntlmNegotiateFlags)
+	// empty domain and workstation fields
+	return m
+}
+
+// authenticateMessage returns the NTLM type 3 message answering the type 2
+// challenge message of the server.
+func (c *ntlmCredentials) authenticateMessage(challenge []byte) ([]byte, error) {
+	if len(challenge) < 32 || !bytes.Equal(challenge[:8], ntlmSignature) || binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, errors.New("invalid NTLM challenge message")
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+
+	var targetInfo []byte
+	if len(challenge) >= 48 {
+		l := int(binary.LittleEndian.Uint16(challenge[40:]))
+		offset := int(binary.LittleEndian.Uint32(challenge[44:]))
+		if offset+l <= len(challenge) {
+			targetInfo = challenge[offset : offset+l]
+		}
+	}
+
+	clientChallenge := make([]byte, 8)
+	_, err := rand.Read(clientChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	ntowf := ntowfv2(c.user, c.password, c.domain)
+
+	// NTLMv2 client challenge blob
+	blob := new(bytes.Buffer)
+	blob.Write([]byte{0x01, 0x01, 0, 0, 0, 0, 0, 0})
+	binary.Write(blob, binary.LittleEndian, ntlmTimestamp(time.Now()))
+	blob.Write(clientChallenge)
+	blob.Write([]byte{0, 0, 0, 0})
+	blob.Write(targetInfo)
+	blob.Write([]byte{0, 0, 0, 0})
+
+	ntProof := hmacMD5(ntowf, serverChallenge, blob.Bytes())
+	ntResponse := append(ntProof, blob.Bytes()...)
+	lmResponse := append(hmacMD5(ntowf, serverChallenge, clientChallenge), clientChallenge...)
+
+	domain := ntlmString(c.domain)
+	user := ntlmString(c.user)
+	workstation := ntlmString("")
+
+	const headerLen = 64
+	m := make([]byte, headerLen)
+	copy(m, ntlmSignature)
+	binary.LittleEndian.PutUint32(m[8:], 3)
+	offset := headerLen
+	for i, field := range [][]byte{lmResponse, ntResponse, domain, user, workstation, nil} {
+		pos := 12 + i*8
+		binary.LittleEndian.PutUint16(m[pos:], uint16(len(field)))
+		binary.LittleEndian.PutUint16(m[pos+2:], uint16(len(field)))
+		binary.LittleEndian.PutUint32(m[pos+4:], uint32(offset))
+		offset += len(field)
+	}
+	binary.LittleEndian.PutUint32(m[60:], flags&ntlmNegotiateFlags)
+	for _, field := range [][]byte{lmResponse, ntResponse, domain, user, workstation} {
+		m = append(m, field...)
+	}
+	return m, nil
+}
+
+// ntowfv2 returns the NTLMv2 hash of the credentials.
+func ntowfv2(user, password, domain string) []byte {
+	h := md4Sum(ntlmString(password))
+	return hmacMD5(h[:], ntlmString(strings.ToUpper(user)+domain))
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// ntlmString encodes s as UTF-16LE.
+func ntlmString(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, r := range u {
+		binary.LittleEndian.PutUint16(b[2*i:], r)
+	}
+	return b
+}
+
+// ntlmTimestamp returns t as Windows FILETIME (100ns intervals since 1601).
+func ntlmTimestamp(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100) + 116444736000000000
+}
+
+// md4Sum returns the MD4 digest of data (RFC 1320). MD4 is broken and only
+// implemented here because the NT password hash requires it.
+func md4Sum(data []byte) [16]byte {
+	msg := append([]byte{}, data...)
+	msg = append(msg, 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(data))*8)
+
+	a, b, c, d := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+	var x [16]uint32
+	for chunk := 0; chunk < len(msg); chunk += 64 {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(msg[chunk+4*i:])
+		}
+		aa, bb, cc, dd := a, b, c, d
+
+		f := func(x, y, z uint32) uint32 { return x&y | ^x&z }
+		g := func(x, y, z uint32) uint32 { return x&y | x&z | y&z }
+		h := func(x, y, z uint32) uint32 { return x ^ y ^ z }
+
+		for _, i := range []int{0, 4, 8, 12} {
+			a = bits.RotateLeft32(a+f(b, c, d)+x[i], 3)
+			d = bits.RotateLeft32(d+f(a, b, c)+x[i+1], 7)
+			c = bits.RotateLeft32(c+f(d, a, b)+x[i+2], 11)
+			b = bits.RotateLeft32(b+f(c, d, a)+x[i+3], 19)
+		}
+		for _, i := range []int{0, 1, 2, 3} {
+			a = bits.RotateLeft32(a+g(b, c, d)+x[i]+0x5a827999, 3)
+			d = bits.RotateLeft32(d+g(a, b, c)+x[i+4]+0x5a827999, 5)
+			c = bits.RotateLeft32(c+g(d, a, b)+x[i+8]+0x5a827999, 9)
+			b = bits.RotateLeft32(b+g(c, d, a)+x[i+12]+0x5a827999, 13)
+		}
+		for _, i := range []int{0, 2, 1, 3} {
+			a = bits.RotateLeft32(a+h(b, c, d)+x[i]+0x6ed9eba1, 3)
+			d = bits.RotateLeft32(d+h(a, b, c)+x[i+8]+0x6ed9eba1, 9)
+			c = bits.RotateLeft32(c+h(d, a, b)+x[i+4]+0x6ed9eba1, 11)
+			b = bits.RotateLeft32(b+h(c, d, a)+x[i+12]+0x6ed9eba1, 15)
+		}
+
+		a += aa
+		b += bb
+		c += cc
+		d += dd
+	}
+
+	var sum [16]byte
+	binary.LittleEndian.PutUint32(sum[0:], a)
+	binary.LittleEndian.PutUint32(sum[4:], b)
+	binary.LittleEndian.PutUint32(sum[8:], c)
+	binary.LittleEndian.PutUint32(sum[12:], d)
+	return sum
+}
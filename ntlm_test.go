@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMD4Sum(t *testing.T) {
+	// test suite of RFC 1320
+	for _, tt := range []struct{ data, want string }{
+		{"", "31d6cfe0d16ae931b73c59d7e0c089c0"},
+		{"abc", "a448017aaf21d8525fc10ae87aa6729d"},
+		{"message digest", "d9130a8164549fe818874806e1c7014b"},
+		{"12345678901234567890123456789012345678901234567890123456789012345678901234567890", "e33b4ddc9c38f2199c3e7b164fcc0536"},
+	} {
+		if sum := md4Sum([]byte(tt.data)); hex.EncodeToString(sum[:]) != tt.want {
+			t.Errorf("md4Sum(%q) = %x, want %s", tt.data, sum, tt.want)
+		}
+	}
+}
+
+func TestNTOWFv2(t *testing.T) {
+	// MS-NLMP 4.2.4.1.1
+	if got := hex.EncodeToString(ntowfv2("User", "Password", "Domain")); got != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Errorf("ntowfv2() = %s, want 0c868a403bfd7a93a3001ef22ef02e3f", got)
+	}
+}
+
+func TestNewNTLMCredentials(t *testing.T) {
+	for _, tt := range []struct{ user, wantDomain, wantUser string }{
+		{`KMP\jdoe`, "KMP", "jdoe"},
+		{"jdoe@kmp.example.com", "kmp.example.com", "jdoe"},
+		{"jdoe", "", "jdoe"},
+	} {
+		c := newNTLMCredentials(tt.user, "Password")
+		if c.domain != tt.wantDomain || c.user != tt.wantUser || c.password != "Password" {
+			t.Errorf("newNTLMCredentials(%q) = %+v, want domain %q and user %q", tt.user, c, tt.wantDomain, tt.wantUser)
+		}
+	}
+}
+
+// ntlmChallenge returns a type 2 message with the server challenge and the
+// domain as target info.
+func ntlmChallenge(serverChallenge []byte, domain string) []byte {
+	targetInfo := binary.LittleEndian.AppendUint16(nil, 2)
+	targetInfo = binary.LittleEndian.AppendUint16(targetInfo, uint16(len(ntlmString(domain))))
+	targetInfo = append(targetInfo, ntlmString(domain)...)
+	targetInfo = append(targetInfo, 0, 0, 0, 0)
+
+	m := make([]byte, 48)
+	copy(m, ntlmSignature)
+	binary.LittleEndian.PutUint32(m[8:], 2)
+	binary.LittleEndian.PutUint32(m[20:], ntlmNegotiateFlags)
+	copy(m[24:], serverChallenge)
+	binary.LittleEndian.PutUint16(m[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(m[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(m[44:], 48)
+	return append(m, targetInfo...)
+}
+
+// ntlmField returns the field of a type 3 message at the header position pos.
+func ntlmField(m []byte, pos int) []byte {
+	l := int(binary.LittleEndian.Uint16(m[pos:]))
+	offset := int(binary.LittleEndian.Uint32(m[pos+4:]))
+	if offset+l > len(m) {
+		return nil
+	}
+	return m[offset : offset+l]
+}
+
+// verifyNTLM reports whether the type 3 message m answers the server
+// challenge with the NTLMv2 response of the credentials.
+func verifyNTLM(m, serverChallenge []byte, user, password, domain string) bool {
+	if len(m) < 64 || !bytes.Equal(m[:8], ntlmSignature) || binary.LittleEndian.Uint32(m[8:]) != 3 {
+		return false
+	}
+	ntResponse := ntlmField(m, 20)
+	if len(ntResponse) < 16 || !bytes.Equal(ntlmField(m, 28), ntlmString(domain)) || !bytes.Equal(ntlmField(m, 36), ntlmString(user)) {
+		return false
+	}
+	return bytes.Equal(ntResponse[:16], hmacMD5(ntowfv2(user, password, domain), serverChallenge, ntResponse[16:]))
+}
+
+func TestAuthenticateMessage(t *testing.T) {
+	c := newNTLMCredentials(`Domain\User`, "Password")
+	negotiate := c.negotiateMessage()
+	if !bytes.Equal(negotiate[:8], ntlmSignature) || binary.LittleEndian.Uint32(negotiate[8:]) != 1 ||
+		binary.LittleEndian.Uint32(negotiate[12:]) != ntlmNegotiateFlags {
+		t.Errorf("negotiateMessage() = %x", negotiate)
+	}
+
+	serverChallenge := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	m, err := c.authenticateMessage(ntlmChallenge(serverChallenge, "Domain"))
+	if err != nil || !verifyNTLM(m, serverChallenge, "User", "Password", "Domain") {
+		t.Errorf("authenticateMessage() = %x, %v, want a valid NTLMv2 response", m, err)
+	}
+	if !bytes.Contains(ntlmField(m, 20), ntlmString("Domain")) {
+		t.Errorf("authenticateMessage() = %x, want the target info in the NTLMv2 response", m)
+	}
+	if verifyNTLM(m, serverChallenge, "User", "Passw0rd", "Domain") {
+		t.Errorf("authenticateMessage() verified with the wrong password")
+	}
+
+	for _, challenge := range [][]byte{nil, negotiate, append([]byte("NTLMSSP\x00\x02"), make([]byte, 10)...)} {
+		if _, err := c.authenticateMessage(challenge); errorString(err) != "invalid NTLM challenge message" {
+			t.Errorf("authenticateMessage(%x) error = %v, want invalid NTLM challenge message", challenge, err)
+		}
+	}
+}
@@ -0,0 +1,410 @@
+// oauth.go
+//
+// OAuth authorization of Webex integrations. With an integration the command
+// acts as a real Webex user instead of a bot, e.g. in spaces where bots are
+// not allowed.
+//
+//	--oauth-login code     prints the authorization URL and receives the
+//	                       authorization code on the local redirect URI
+//	--oauth-login device   uses the device authorization grant, the user
+//	                       enters a code on a second device
+//
+// The tokens are stored in the OAuth token file. If flag -T is not set and the
+// token file exists, its access token is used and refreshed automatically
+// before it expires.
+//
+// doc links:
+//
+//	https://developer.webex.com/docs/integrations
+//	https://developer.webex.com/docs/login-with-webex#device-grant-flow
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// paths of the API URL (--api-url)
+	oauthAuthorizePath   = "/authorize"
+	oauthTokenPath       = "/access_token"
+	oauthDevicePath      = "/device/authorize"
+	oauthDeviceTokenPath = "/device/token"
+	oauthDeviceGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// access tokens are refreshed if they expire within this period
+	oauthRefreshMargin = 24 * time.Hour
+)
+
+// oauthToken is the content of the OAuth token file.
+type oauthToken struct {
+	ClientID              string    `json:"clientId"`
+	ClientSecret          string    `json:"clientSecret"`
+	AccessToken           string    `json:"accessToken"`
+	ExpiresAt             time.Time `json:"expiresAt"`
+	RefreshToken          string    `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+}
+
+// oauthTokenResp is the token endpoint response.
+type oauthTokenResp struct {
+	AccessToken           string `json:"access_token"`
+	ExpiresIn             int64  `json:"expires_in"`
+	RefreshToken          string `json:"refresh_token"`
+	RefreshTokenExpiresIn int64  `json:"refresh_token_expires_in"`
+	Error                 string `json:"error"`
+	ErrorDescription      string `json:"error_description"`
+	Message               string `json:"message"`
+}
+
+type oauthDeviceResp struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+var (
+	tokenMutex   sync.Mutex
+	oauthCurrent *oauthToken
+)
+
+func defaultOAuthTokenFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "notify_by_webex_teams", "oauth-token.json")
+}
+
+// errNoToken is returned by accessToken if no token is configured.
+var errNoToken = errors.New("no token. use flag -T, --oauth-login or a guest issuer")
+
+// accessToken returns the token for Webex API requests. This is the bot token
+// of flag -T, a guest token if a guest issuer is set or the access token of the
+// OAuth token file, which is refreshed first if necessary. A token which
+// expires soon is still returned if the refresh fails.
+func accessToken() (string, error) {
+	tokenMutex.Lock()
+	defer tokenMutex.Unlock()
+
+	if len(apiToken) > 0 {
+		return apiToken, nil
+	}
+
+	if len(guestIssuerID) > 0 {
+		return guestAccessToken()
+	}
+
+	if oauthCurrent == nil {
+		t, err := loadOAuthToken(oauthTokenFile)
+		if os.IsNotExist(err) {
+			return "", errNoToken
+		}
+		if err != nil {
+			return "", err
+		}
+		oauthCurrent = t
+	}
+
+	if time.Until(oauthCurrent.ExpiresAt) < oauthRefreshMargin {
+		err := oauthCurrent.refresh()
+		switch {
+		case err != nil && time.Now().After(oauthCurrent.ExpiresAt):
+			return "", fmt.Errorf("refreshing the expired OAuth access token failed: %w", err)
+		case err != nil:
+			slog.Error("refreshing OAuth access token failed", "error", err)
+		default:
+			if err := saveOAuthToken(oauthTokenFile, oauthCurrent); err != nil {
+				slog.Error("access token", "error", err)
+			}
+		}
+	}
+	return oauthCurrent.AccessToken, nil
+}
+
+// oauthLogin runs the authorization flow of the given mode and stores the
+// resulting tokens in the OAuth token file.
+func oauthLogin(mode string) error {
+	if len(oauthClientID) == 0 || len(oauthSecret) == 0 {
+		return errors.New("flags --oauth-client-id and --oauth-client-secret are required")
+	}
+
+	var resp *oauthTokenResp
+	var err error
+	switch mode {
+	case "code":
+		resp, err = oauthCodeFlow()
+	case "device":
+		resp, err = oauthDeviceFlow()
+	default:
+		return fmt.Errorf("unknown OAuth login mode: %s. use code or device", mode)
+	}
+	if err != nil {
+		return err
+	}
+
+	t := &oauthToken{ClientID: oauthClientID, ClientSecret: oauthSecret}
+	t.update(resp)
+	err = saveOAuthToken(oauthTokenFile, t)
+	if err != nil {
+		return err
+	}
+	slog.Info("OAuth tokens stored", "file", oauthTokenFile, "validUntil", t.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+func oauthCodeFlow() (*oauthTokenResp, error) {
+	redirect, err := url.Parse(oauthRedirect)
+	if err != nil || len(redirect.Host) == 0 {
+		return nil, fmt.Errorf("invalid --oauth-redirect-uri %q. use e.g. http://localhost:8080/callback", oauthRedirect)
+	}
+	// the redirect URI http://localhost:8080 has no path
+	callbackPath := redirect.Path
+	if len(callbackPath) == 0 {
+		callbackPath = "/"
+	}
+	state := make([]byte, 16)
+	_, err = rand.Read(state)
+	if err != nil {
+		return nil, err
+	}
+	stateStr := hex.EncodeToString(state)
+
+	authValues := url.Values{}
+	authValues.Add("client_id", oauthClientID)
+	authValues.Add("response_type", "code")
+	authValues.Add("redirect_uri", oauthRedirect)
+	authValues.Add("scope", oauthScopes)
+	authValues.Add("state", stateStr)
+
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case q.Get("state") != stateStr:
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		case len(q.Get("error")) > 0:
+			fmt.Fprintf(w, "authorization failed: %s\n", q.Get("error"))
+			errs <- fmt.Errorf("authorization failed: %s %s", q.Get("error"), q.Get("error_description"))
+		default:
+			fmt.Fprintln(w, "authorization successful. you can close this window.")
+			codes <- q.Get("code")
+		}
+	})
+	go http.Serve(listener, mux)
+
+	fmt.Printf("open the following URL in your browser to authorize the integration:\n\n%s?%s\n\n", apiURL(oauthAuthorizePath), authValues.Encode())
+
+	var code string
+	select {
+	case code = <-codes:
+	case err = <-errs:
+		return nil, err
+	case <-time.After(10 * time.Minute):
+		return nil, errors.New("timeout waiting for the authorization")
+	}
+
+	values := url.Values{}
+	values.Add("grant_type", "authorization_code")
+	values.Add("client_id", oauthClientID)
+	values.Add("client_secret", oauthSecret)
+	values.Add("code", code)
+	values.Add("redirect_uri", oauthRedirect)
+	return oauthTokenRequest(apiURL(oauthTokenPath), values, false)
+}
+
+func oauthDeviceFlow() (*oauthTokenResp, error) {
+	values := url.Values{}
+	values.Add("client_id", oauthClientID)
+	values.Add("scope", oauthScopes)
+
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.PostForm(apiURL(oauthDevicePath), values)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("device authorization failed. HTTP status code: %d: %s", resp.StatusCode, body)
+	}
+	var d oauthDeviceResp
+	err = json.Unmarshal(body, &d)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("open %s and enter the code %s\n", d.VerificationURI, d.UserCode)
+	if len(d.VerificationURIComplete) > 0 {
+		fmt.Printf("or open %s\n", d.VerificationURIComplete)
+	}
+
+	interval := time.Duration(d.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(d.ExpiresIn) * time.Second)
+
+	values = url.Values{}
+	values.Add("grant_type", oauthDeviceGrantType)
+	values.Add("device_code", d.DeviceCode)
+	values.Add("client_id", oauthClientID)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		t, err := oauthTokenRequest(apiURL(oauthDeviceTokenPath), values, true)
+		if err == nil {
+			return t, nil
+		}
+		switch {
+		case strings.Contains(err.Error(), "authorization_pending"):
+		case strings.Contains(err.Error(), "slow_down"):
+			interval += 5 * time.Second
+		default:
+			return nil, err
+		}
+	}
+	return nil, errors.New("device code expired before the authorization was completed")
+}
+
+// oauthTokenRequest posts values to a token endpoint. The client credentials
+// are sent as basic authentication if basicAuth is true.
+func oauthTokenRequest(tokenURL string, values url.Values, basicAuth bool) (*oauthTokenResp, error) {
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basicAuth {
+		req.SetBasicAuth(oauthClientID, oauthSecret)
+	}
+
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var t oauthTokenResp
+	json.Unmarshal(body, &t)
+	if resp.StatusCode != http.StatusOK || len(t.AccessToken) == 0 {
+		if len(t.Error) > 0 {
+			return nil, fmt.Errorf("token request failed: %s %s", t.Error, t.ErrorDescription)
+		}
+		return nil, fmt.Errorf("token request failed. HTTP status code: %d: %s", resp.StatusCode, body)
+	}
+	return &t, nil
+}
+
+func (t *oauthToken) refresh() error {
+	if len(t.RefreshToken) == 0 {
+		return errors.New("no refresh token")
+	}
+	if !t.RefreshTokenExpiresAt.IsZero() && time.Now().After(t.RefreshTokenExpiresAt) {
+		return errors.New("refresh token expired. use flag --oauth-login to authorize again")
+	}
+
+	// the client credentials from the flags take precedence over the stored ones
+	if len(oauthClientID) > 0 {
+		t.ClientID = oauthClientID
+	}
+	if len(oauthSecret) > 0 {
+		t.ClientSecret = oauthSecret
+	}
+
+	values := url.Values{}
+	values.Add("grant_type", "refresh_token")
+	values.Add("client_id", t.ClientID)
+	values.Add("client_secret", t.ClientSecret)
+	values.Add("refresh_token", t.RefreshToken)
+	resp, err := oauthTokenRequest(apiURL(oauthTokenPath), values, false)
+	if err != nil {
+		return err
+	}
+	t.update(resp)
+	slog.Info("OAuth access token refreshed", "validUntil", t.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+func (t *oauthToken) update(resp *oauthTokenResp) {
+	addSecret(resp.AccessToken)
+	addSecret(resp.RefreshToken)
+	now := time.Now()
+	t.AccessToken = resp.AccessToken
+	t.ExpiresAt = now.Add(time.Duration(resp.ExpiresIn) * time.Second)
+	if len(resp.RefreshToken) > 0 {
+		t.RefreshToken = resp.RefreshToken
+		t.RefreshTokenExpiresAt = now.Add(time.Duration(resp.RefreshTokenExpiresIn) * time.Second)
+	}
+}
+
+func loadOAuthToken(filename string) (*oauthToken, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var t oauthToken
+	err = json.Unmarshal(data, &t)
+	if err != nil {
+		return nil, fmt.Errorf("OAuth token file %s: %v", filename, err)
+	}
+	addSecret(t.AccessToken)
+	addSecret(t.RefreshToken)
+	addSecret(t.ClientSecret)
+	return &t, nil
+}
+
+func saveOAuthToken(filename string, t *oauthToken) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != oauthTokenPath || r.FormValue("refresh_token") != "good" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"refreshed","expires_in":1209600}`)
+	}))
+	defer srv.Close()
+	apiBaseURL = srv.URL
+	defer func() { apiBaseURL = "" }()
+
+	for _, tt := range []struct {
+		name    string
+		bot     string
+		token   *oauthToken
+		want    string
+		wantErr string
+	}{
+		{name: "bot token", bot: "bot", want: "bot"},
+		{name: "no token file", wantErr: "no token"},
+		{name: "valid", token: &oauthToken{AccessToken: "current", ExpiresAt: time.Now().Add(10 * 24 * time.Hour)}, want: "current"},
+		{name: "refreshed", token: &oauthToken{AccessToken: "current", ExpiresAt: time.Now().Add(time.Hour), RefreshToken: "good"}, want: "refreshed"},
+		// still valid for an hour, the refresh is tried again with the next request
+		{name: "refresh failed", token: &oauthToken{AccessToken: "current", ExpiresAt: time.Now().Add(time.Hour), RefreshToken: "bad"}, want: "current"},
+		{name: "expired", token: &oauthToken{AccessToken: "current", ExpiresAt: time.Now().Add(-time.Hour), RefreshToken: "bad"}, wantErr: "expired OAuth access token"},
+		{name: "no refresh token", token: &oauthToken{AccessToken: "current", ExpiresAt: time.Now().Add(-time.Hour)}, wantErr: "no refresh token"},
+	} {
+		apiToken, oauthCurrent = tt.bot, nil
+		oauthTokenFile = filepath.Join(t.TempDir(), "oauth-token.json")
+		if tt.token != nil {
+			if err := saveOAuthToken(oauthTokenFile, tt.token); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := accessToken()
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: accessToken() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("%s: accessToken() = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+	apiToken, oauthCurrent = "", nil
+}
+
+func TestOAuthCodeFlowRedirect(t *testing.T) {
+	defer func(s string) { oauthRedirect = s }(oauthRedirect)
+	for _, uri := range []string{"", "/callback", "localhost:8080", "http://%zz"} {
+		oauthRedirect = uri
+		if _, err := oauthCodeFlow(); err == nil || !strings.Contains(err.Error(), "invalid --oauth-redirect-uri") {
+			t.Errorf("oauthCodeFlow() with redirect URI %q: error = %v", uri, err)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+// oncall.go
+//
+// On-call recipient (flags --oncall-file, --oncall-pagerduty,
+// --oncall-opsgenie). The person on call now is looked up and the message is
+// sent to them as a private message instead of to the room of -t/-r, with
+// --oncall-cc to the room as well:
+//
+//	notify_by_webex_teams -t KMP-Team -r Operations -m "**disk full** on db-1" --oncall-file oncall.yaml --oncall-cc
+//
+// The schedule file is a rotation of people with handovers every shift,
+// and overrides of the rotation, e.g. for holidays:
+//
+//	timezone: Europe/Vienna
+//	rotation:
+//	  start: 2026-01-05 09:00
+//	  shift: 1w
+//	  people: [alice@example.com, bob@example.com, carol@example.com]
+//	overrides:
+//	  - from: 2026-10-12 09:00
+//	    to: 2026-10-19 09:00
+//	    email: dave@example.com
+//
+// --oncall-pagerduty looks up the on-call of a PagerDuty schedule with the
+// API token of the environment variable PAGERDUTY_TOKEN, --oncall-opsgenie
+// the on-call of an Opsgenie schedule (name or ID) with the API key of
+// OPSGENIE_API_KEY, at OPSGENIE_API_URL for the EU instance.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// base URLs of the on-call APIs, overridden in tests
+	pagerDutyAPI = "https://api.pagerduty.com"
+	opsgenieAPI  = "https://api.opsgenie.com"
+
+	onCallKeys         = []string{"timezone", "rotation", "overrides"}
+	onCallRotationKeys = []string{"start", "shift", "people"}
+	onCallOverrideKeys = []string{"from", "to", "email"}
+)
+
+// onCallGiven reports whether an on-call flag is set.
+func onCallGiven() bool {
+	return len(onCallFile) > 0 || len(onCallPD) > 0 || len(onCallOpsgenie) > 0
+}
+
+// routeOnCall sends n to the person on call, and with --oncall-cc to the
+// room of n as well.
+func routeOnCall(ctx context.Context, n *notification) error {
+	if !onCallGiven() {
+		return nil
+	}
+	var email string
+	var err error
+	switch {
+	case len(onCallFile) > 0:
+		email, err = onCallFromFile(onCallFile, time.Now())
+	case len(onCallPD) > 0:
+		email, err = onCallFromPagerDuty(ctx, onCallPD)
+	default:
+		email, err = onCallFromOpsgenie(ctx, onCallOpsgenie)
+	}
+	if err != nil {
+		return fmt.Errorf("looking up the on-call: %w", err)
+	}
+	slog.Info("on-call", "email", email)
+	n.Email = email
+	if !onCallCC {
+		n.TeamName = ""
+	}
+	return nil
+}
+
+// checkOnCall checks the on-call flags.
+func checkOnCall() error {
+	given := 0
+	for _, s := range []string{onCallFile, onCallPD, onCallOpsgenie} {
+		if len(s) > 0 {
+			given++
+		}
+	}
+	switch {
+	case given > 1:
+		return errors.New("use only one of --oncall-file, --oncall-pagerduty and --oncall-opsgenie")
+	case given == 1 && len(emailAddr) > 0:
+		return errors.New("--oncall-* can not be combined with -D")
+	case onCallCC && given == 0:
+		return errors.New("--oncall-cc needs --oncall-file, --oncall-pagerduty or --oncall-opsgenie")
+	}
+	return nil
+}
+
+// onCallFromFile returns the person on call at now by the schedule file.
+func onCallFromFile(file string, now time.Time) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", file, err)
+	}
+	m, ok := doc.(yamlMapping)
+	if !ok {
+		return "", fmt.Errorf("%s: not a mapping with the key rotation", file)
+	}
+	if err := checkYAMLKeys(file, m, onCallKeys); err != nil {
+		return "", err
+	}
+	loc := time.Local
+	if v, ok := m.Get("timezone"); ok {
+		if loc, err = time.LoadLocation(yamlString(v)); err != nil {
+			return "", fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	parseTime := func(name string, v interface{}) (time.Time, error) {
+		s := yamlString(v)
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+		if err != nil {
+			return t, fmt.Errorf("%s: invalid %s %q. use 2006-01-02 15:04 or RFC3339", file, name, s)
+		}
+		return t, nil
+	}
+
+	v, _ := m.Get("overrides")
+	overrides, ok := v.([]interface{})
+	if v != nil && !ok {
+		return "", fmt.Errorf("%s: overrides is not a list", file)
+	}
+	for i, item := range overrides {
+		o, ok := item.(yamlMapping)
+		if !ok {
+			return "", fmt.Errorf("%s: override %d is not a mapping", file, i+1)
+		}
+		if err := checkYAMLKeys(fmt.Sprintf("%s: override %d", file, i+1), o, onCallOverrideKeys); err != nil {
+			return "", err
+		}
+		from, _ := o.Get("from")
+		to, _ := o.Get("to")
+		email, _ := o.Get("email")
+		start, err := parseTime("from", from)
+		if err != nil {
+			return "", err
+		}
+		end, err := parseTime("to", to)
+		if err != nil {
+			return "", err
+		}
+		if len(yamlString(email)) == 0 {
+			return "", fmt.Errorf("%s: override %d: no email", file, i+1)
+		}
+		if !now.Before(start) && now.Before(end) {
+			return yamlString(email), nil
+		}
+	}
+
+	v, _ = m.Get("rotation")
+	r, ok := v.(yamlMapping)
+	if !ok {
+		return "", fmt.Errorf("%s: no rotation", file)
+	}
+	if err := checkYAMLKeys(file+": rotation", r, onCallRotationKeys); err != nil {
+		return "", err
+	}
+	v, _ = r.Get("start")
+	start, err := parseTime("start", v)
+	if err != nil {
+		return "", err
+	}
+	v, _ = r.Get("shift")
+	shift := yamlString(v)
+	v, _ = r.Get("people")
+	people, err := yamlStrings(v)
+	if err != nil || len(people) == 0 {
+		return "", fmt.Errorf("%s: rotation: people is not a list of email addresses", file)
+	}
+	n, err := shiftNumber(start.In(loc), now.In(loc), shift)
+	if err != nil {
+		return "", fmt.Errorf("%s: rotation: %w", file, err)
+	}
+	return people[n%len(people)], nil
+}
+
+// shiftNumber returns the number of the shift at now of a rotation with
+// shifts of the given length since start. Shifts of days (1d) and weeks
+// (1w) hand over at the same local time across changes of daylight saving
+// time, others (12h) are durations.
+func shiftNumber(start, now time.Time, shift string) (int, error) {
+	if now.Before(start) {
+		return 0, fmt.Errorf("starts at %s", start.Format("2006-01-02 15:04"))
+	}
+	days := 0
+	if n, err := strconv.Atoi(strings.TrimSuffix(shift, "d")); err == nil && strings.HasSuffix(shift, "d") {
+		days = n
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(shift, "w")); err == nil && strings.HasSuffix(shift, "w") {
+		days = 7 * n
+	}
+	if days == 0 {
+		d, err := time.ParseDuration(shift)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid shift %q. use e.g. 1w, 1d or 12h", shift)
+		}
+		return int(now.Sub(start) / d), nil
+	}
+	if days < 0 {
+		return 0, fmt.Errorf("invalid shift %q. use e.g. 1w, 1d or 12h", shift)
+	}
+	n := int(now.Sub(start) / (time.Duration(days) * 24 * time.Hour))
+	for n > 0 && start.AddDate(0, 0, n*days).After(now) {
+		n--
+	}
+	for !start.AddDate(0, 0, (n+1)*days).After(now) {
+		n++
+	}
+	return n, nil
+}
+
+// onCallFromPagerDuty returns the email address of the person on call of
+// the PagerDuty schedule, the first of the escalation levels.
+func onCallFromPagerDuty(ctx context.Context, schedule string) (string, error) {
+	token := os.Getenv("PAGERDUTY_TOKEN")
+	if len(token) == 0 {
+		return "", errors.New("--oncall-pagerduty needs the API token in the environment variable PAGERDUTY_TOKEN")
+	}
+	addSecret(token)
+	query := url.Values{"schedule_ids[]": {schedule}, "include[]": {"users"}, "earliest": {"true"}}
+	header := http.Header{
+		"Authorization": {"Token token=" + token},
+		"Accept":        {"application/vnd.pagerduty+json;version=2"},
+	}
+	var resp struct {
+		Oncalls []struct {
+			EscalationLevel int `json:"escalation_level"`
+			User            struct {
+				Email string `json:"email"`
+			} `json:"user"`
+		} `json:"oncalls"`
+	}
+	if err := onCallRequest(ctx, pagerDutyAPI+"/oncalls?"+query.Encode(), header, &resp); err != nil {
+		return "", err
+	}
+	email, level := "", 0
+	for _, o := range resp.Oncalls {
+		if len(o.User.Email) > 0 && (len(email) == 0 || o.EscalationLevel < level) {
+			email, level = o.User.Email, o.EscalationLevel
+		}
+	}
+	if len(email) == 0 {
+		return "", fmt.Errorf("nobody on call in PagerDuty schedule %s", schedule)
+	}
+	return email, nil
+}
+
+// onCallFromOpsgenie returns the email address of the person on call of
+// the Opsgenie schedule, given by name or ID.
+func onCallFromOpsgenie(ctx context.Context, schedule string) (string, error) {
+	key := os.Getenv("OPSGENIE_API_KEY")
+	if len(key) == 0 {
+		return "", errors.New("--oncall-opsgenie needs the API key in the environment variable OPSGENIE_API_KEY")
+	}
+	addSecret(key)
+	base := firstNonEmpty(os.Getenv("OPSGENIE_API_URL"), opsgenieAPI)
+	idType := "name"
+	if isUUID(schedule) {
+		idType = "id"
+	}
+	query := url.Values{"scheduleIdentifierType": {idType}, "flat": {"true"}}
+	header := http.Header{"Authorization": {"GenieKey " + key}}
+	var resp struct {
+		Data struct {
+			OnCallRecipients []string `json:"onCallRecipients"`
+		} `json:"data"`
+	}
+	u := strings.TrimSuffix(base, "/") + "/v2/schedules/" + url.PathEscape(schedule) + "/on-calls?" + query.Encode()
+	if err := onCallRequest(ctx, u, header, &resp); err != nil {
+		return "", err
+	}
+	for _, r := range resp.Data.OnCallRecipients {
+		if strings.Contains(r, "@") {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("nobody on call in Opsgenie schedule %s", schedule)
+}
+
+// isUUID reports whether s looks like a UUID, the IDs of Opsgenie.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if r != '-' {
+				return false
+			}
+		case !strings.ContainsRune("0123456789abcdefABCDEF", r):
+			return false
+		}
+	}
+	return true
+}
+
+// onCallRequest GETs the JSON of an on-call API.
+func onCallRequest(ctx context.Context, u string, header http.Header, v interface{}) error {
+	client, err := sharedHTTPClient()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const onCallSchedule = `timezone: Europe/Vienna
+rotation:
+  start: 2026-01-05 09:00
+  shift: 1w
+  people: [alice@example.com, bob@example.com, carol@example.com]
+overrides:
+  - from: 2026-10-12 09:00
+    to: 2026-10-19 09:00
+    email: dave@example.com
+`
+
+func TestOnCallFromFile(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	write := func(text string) string {
+		file := filepath.Join(dir, "oncall.yaml")
+		if err := os.WriteFile(file, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	file := write(onCallSchedule)
+	for _, tt := range []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2026, 1, 5, 9, 0, 0, 0, vienna), "alice@example.com"},
+		{time.Date(2026, 1, 12, 8, 59, 0, 0, vienna), "alice@example.com"},
+		{time.Date(2026, 1, 12, 9, 0, 0, 0, vienna), "bob@example.com"},
+		{time.Date(2026, 1, 19, 9, 0, 0, 0, vienna), "carol@example.com"},
+		// the handover stays at 09:00 local time after the change to summer time
+		{time.Date(2026, 3, 30, 9, 0, 0, 0, vienna), "alice@example.com"},
+		{time.Date(2026, 3, 30, 8, 30, 0, 0, vienna), "carol@example.com"},
+		{time.Date(2026, 10, 15, 12, 0, 0, 0, vienna), "dave@example.com"},
+		{time.Date(2026, 10, 19, 9, 0, 0, 0, vienna), "carol@example.com"},
+	} {
+		got, err := onCallFromFile(file, tt.now)
+		if err != nil || got != tt.want {
+			t.Errorf("onCallFromFile(%s) = %q, %v, want %q", tt.now, got, err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		text, wantErr string
+	}{
+		{"- alice@example.com\n", "not a mapping"},
+		{"rotaton: {}\n", `unknown key "rotaton"`},
+		{"timezone: Mars/Olympus\n", "unknown time zone"},
+		{"overrides: dave\n", "overrides is not a list"},
+		{"overrides:\n  - from: tomorrow\n    to: 2026-10-19 09:00\n    email: dave@example.com\n", `invalid from "tomorrow"`},
+		{"overrides:\n  - from: 2026-10-12 09:00\n    to: 2026-10-19 09:00\n", "override 1: no email"},
+		{"timezone: UTC\n", "no rotation"},
+		{"rotation:\n  start: 2026-01-05 09:00\n  shift: 1w\n", "people is not a list"},
+		{"rotation:\n  start: 2026-01-05 09:00\n  shift: fortnight\n  people: [alice@example.com]\n", `rotation: invalid shift "fortnight"`},
+		{"rotation:\n  start: 2027-01-05 09:00\n  shift: 1w\n  people: [alice@example.com]\n", "rotation: starts at 2027-01-05 09:00"},
+	} {
+		_, err := onCallFromFile(write(tt.text), time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("onCallFromFile(%q) error = %v, want %s", tt.text, err, tt.wantErr)
+		}
+	}
+}
+
+func TestShiftNumber(t *testing.T) {
+	start := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		now     time.Time
+		shift   string
+		want    int
+		wantErr bool
+	}{
+		{start, "1d", 0, false},
+		{start.Add(47 * time.Hour), "1d", 1, false},
+		{start.Add(48 * time.Hour), "2d", 1, false},
+		{start.Add(15 * 24 * time.Hour), "1w", 2, false},
+		{start.Add(13 * time.Hour), "12h", 1, false},
+		{start.Add(-time.Minute), "1d", 0, true},
+		{start, "0h", 0, true},
+		{start, "-1d", 0, true},
+		{start, "daily", 0, true},
+	} {
+		got, err := shiftNumber(start, tt.now, tt.shift)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("shiftNumber(%s, %s) = %d, %v, want %d, error %v", tt.now, tt.shift, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestIsUUID(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		want bool
+	}{
+		{"d875alp4-...", false},
+		{"d875a4a6-1234-4b7a-9f0e-0123456789AB", true},
+		{"d875a4a6x1234-4b7a-9f0e-0123456789ab", false},
+		{"d875a4a6-1234-4b7a-9f0e-0123456789ag", false},
+		{"Ops Team_schedule", false},
+	} {
+		if got := isUUID(tt.s); got != tt.want {
+			t.Errorf("isUUID(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestCheckOnCall(t *testing.T) {
+	defer func(file, pd, og, email string, cc bool) {
+		onCallFile, onCallPD, onCallOpsgenie, emailAddr, onCallCC = file, pd, og, email, cc
+	}(onCallFile, onCallPD, onCallOpsgenie, emailAddr, onCallCC)
+	for _, tt := range []struct {
+		file, pd, email string
+		cc              bool
+		wantErr         string
+	}{
+		{wantErr: ""},
+		{file: "oncall.yaml", cc: true, wantErr: ""},
+		{file: "oncall.yaml", pd: "PABC123", wantErr: "use only one of"},
+		{file: "oncall.yaml", email: "alice@example.com", wantErr: "can not be combined with -D"},
+		{cc: true, wantErr: "--oncall-cc needs"},
+	} {
+		onCallFile, onCallPD, onCallOpsgenie, emailAddr, onCallCC = tt.file, tt.pd, "", tt.email, tt.cc
+		err := checkOnCall()
+		if got := errorString(err); !strings.Contains(got, tt.wantErr) || (len(tt.wantErr) == 0) != (err == nil) {
+			t.Errorf("checkOnCall(%+v) = %v, want %q", tt, err, tt.wantErr)
+		}
+	}
+}
+
+func TestOnCallFromPagerDuty(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token token=pd-token" || r.URL.Path != "/oncalls" || r.URL.Query().Get("schedule_ids[]") != "PABC123" {
+			http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"oncalls": [{"escalation_level": 2, "user": {"email": "manager@example.com"}},
+			{"escalation_level": 1, "user": {"email": "alice@example.com"}}]}`))
+	}))
+	defer server.Close()
+	defer func(api string) { pagerDutyAPI = api }(pagerDutyAPI)
+	pagerDutyAPI = server.URL
+
+	t.Setenv("PAGERDUTY_TOKEN", "")
+	if _, err := onCallFromPagerDuty(context.Background(), "PABC123"); err == nil || !strings.Contains(err.Error(), "PAGERDUTY_TOKEN") {
+		t.Errorf("onCallFromPagerDuty() without token error = %v", err)
+	}
+	t.Setenv("PAGERDUTY_TOKEN", "pd-token")
+	if got, err := onCallFromPagerDuty(context.Background(), "PABC123"); err != nil || got != "alice@example.com" {
+		t.Errorf("onCallFromPagerDuty() = %q, %v, want alice@example.com", got, err)
+	}
+	if _, err := onCallFromPagerDuty(context.Background(), "PXYZ999"); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Errorf("onCallFromPagerDuty() of another schedule error = %v, want 401", err)
+	}
+}
+
+func TestOnCallFromOpsgenie(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey og-key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path + "?" + r.URL.Query().Get("scheduleIdentifierType") {
+		case "/v2/schedules/Ops Team/on-calls?name":
+			w.Write([]byte(`{"data": {"onCallRecipients": ["ops-team", "bob@example.com"]}}`))
+		case "/v2/schedules/d875a4a6-1234-4b7a-9f0e-0123456789ab/on-calls?id":
+			w.Write([]byte(`{"data": {"onCallRecipients": []}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("OPSGENIE_API_KEY", "og-key")
+	t.Setenv("OPSGENIE_API_URL", server.URL+"/")
+
+	if got, err := onCallFromOpsgenie(context.Background(), "Ops Team"); err != nil || got != "bob@example.com" {
+		t.Errorf("onCallFromOpsgenie(Ops Team) = %q, %v, want bob@example.com", got, err)
+	}
+	if _, err := onCallFromOpsgenie(context.Background(), "d875a4a6-1234-4b7a-9f0e-0123456789ab"); err == nil || !strings.Contains(err.Error(), "nobody on call") {
+		t.Errorf("onCallFromOpsgenie(ID) error = %v, want nobody on call", err)
+	}
+}
+
+func TestRouteOnCall(t *testing.T) {
+	defer func(file string, cc bool) { onCallFile, onCallCC = file, cc }(onCallFile, onCallCC)
+	onCallFile = filepath.Join(t.TempDir(), "oncall.yaml")
+	if err := os.WriteFile(onCallFile, []byte("rotation:\n  start: 2020-01-01T00:00:00Z\n  shift: 1000w\n  people: [alice@example.com]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, cc := range []bool{false, true} {
+		onCallCC = cc
+		n := &notification{TeamName: "KMP-Team", RoomName: "Operations"}
+		if err := routeOnCall(context.Background(), n); err != nil {
+			t.Fatal(err)
+		}
+		if n.Email != "alice@example.com" || (len(n.TeamName) > 0) != cc {
+			t.Errorf("routeOnCall() with --oncall-cc=%v = team %q, email %q", cc, n.TeamName, n.Email)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// overflow.go
+//
+// Messages longer than Webex accepts (flag --overflow), e.g. piped command
+// output. "fail" (default) fails before anything is sent, "truncate" cuts the
+// message at a line end and "attach" sends the beginning of the message as
+// summary with the full message attached as message.txt.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// length of the beginning of the message in the summary of "attach"
+const overflowSummaryLength = 1500
+
+// handleOverflow applies --overflow to n. The returned function removes the
+// attached file.
+func handleOverflow(n *notification) (func(), error) {
+	noop := func() {}
+	limit := maxMarkdownLength - codeReserve
+	if len(n.Markdown) <= limit {
+		return noop, nil
+	}
+	lines := strings.Count(strings.TrimRight(n.Markdown, "\n"), "\n") + 1
+
+	switch overflowMode {
+	case "fail":
+		return noop, fmt.Errorf("the message has %d bytes, Webex accepts up to %d. use --overflow truncate or --overflow attach", len(n.Markdown), maxMarkdownLength)
+	case "truncate":
+		cut := max(strings.LastIndexByte(n.Markdown[:limit-50], '\n'), 0)
+		omitted := lines
+		if cut > 0 {
+			omitted -= strings.Count(n.Markdown[:cut], "\n") + 1
+		}
+		n.Markdown = n.Markdown[:cut] + "\n\n" + trf("… truncated, %d more lines", omitted)
+		return noop, nil
+	case "attach":
+		dir, err := os.MkdirTemp("", "notify_by_webex_teams")
+		if err != nil {
+			return noop, err
+		}
+		cleanup := func() { os.RemoveAll(dir) }
+		file := filepath.Join(dir, "message.txt")
+		err = os.WriteFile(file, []byte(n.Markdown), 0600)
+		if err != nil {
+			cleanup()
+			return noop, err
+		}
+		summary, _ := codeBlock(n.Markdown, "", overflowSummaryLength)
+		n.Markdown = summary + trf("📄 full message attached as message.txt (%d lines, %.1f KB)", lines, float64(len(n.Markdown))/1024)
+		addFiles(n, file)
+		return cleanup, nil
+	}
+	return noop, fmt.Errorf("invalid --overflow %q. use fail, truncate or attach", overflowMode)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHandleOverflow(t *testing.T) {
+	defer func(mode string) { overflowMode = mode }(overflowMode)
+	var b strings.Builder
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	long := b.String()
+
+	for _, mode := range []string{"fail", "truncate", "attach", "drop"} {
+		overflowMode = mode
+		n := &notification{Markdown: "short message"}
+		cleanup, err := handleOverflow(n)
+		cleanup()
+		if err != nil || n.Markdown != "short message" || len(notificationFiles(n)) > 0 {
+			t.Errorf("handleOverflow() of a short message with %s = %v, %q, %v", mode, err, n.Markdown, notificationFiles(n))
+		}
+	}
+
+	overflowMode = "fail"
+	n := &notification{Markdown: long}
+	if _, err := handleOverflow(n); err == nil || n.Markdown != long {
+		t.Errorf("handleOverflow() with fail = %v, want error and the message unchanged", err)
+	}
+
+	overflowMode = "drop"
+	if _, err := handleOverflow(&notification{Markdown: long}); err == nil || !strings.Contains(err.Error(), "invalid --overflow") {
+		t.Errorf("handleOverflow() with drop: error = %v", err)
+	}
+
+	overflowMode = "truncate"
+	n = &notification{Markdown: long}
+	if _, err := handleOverflow(n); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Markdown) > maxMarkdownLength-codeReserve {
+		t.Errorf("truncated message has %d bytes, want at most %d", len(n.Markdown), maxMarkdownLength-codeReserve)
+	}
+	kept := strings.Count(n.Markdown, "line ")
+	if want := fmt.Sprintf("line %d\n\n… truncated, %d more lines", kept, 1000-kept); !strings.HasSuffix(n.Markdown, want) {
+		t.Errorf("truncated message ends with %q, want %q", n.Markdown[len(n.Markdown)-60:], want)
+	}
+
+	overflowMode = "attach"
+	n = &notification{Markdown: long, Files: []string{"report.pdf"}}
+	cleanup, err := handleOverflow(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := notificationFiles(n)
+	if len(files) != 2 || files[0] != "report.pdf" || !strings.HasSuffix(files[1], "message.txt") {
+		t.Fatalf("files = %v, want report.pdf and message.txt", files)
+	}
+	if data, err := os.ReadFile(files[1]); err != nil || string(data) != long {
+		t.Errorf("message.txt = %d bytes, %v, want the full message", len(data), err)
+	}
+	if !strings.Contains(n.Markdown, "line 1\n") || !strings.Contains(n.Markdown, "full message attached as message.txt (1000 lines") {
+		t.Errorf("summary = %q", n.Markdown)
+	}
+	cleanup()
+	if _, err := os.Stat(files[1]); !os.IsNotExist(err) {
+		t.Errorf("message.txt not removed by cleanup: %v", err)
+	}
+}
@@ -0,0 +1,415 @@
+// pagerduty.go
+//
+// PagerDuty webhooks (serve --pagerduty). Incident events of PagerDuty V3
+// webhooks POSTed to /pagerduty are posted as one card per incident, which
+// follows the incident through its lifecycle: triggered (red), acknowledged
+// (yellow), resolved (green), and reassignments, escalations and priority
+// changes in between. Webex can not edit cards, so on every change the card
+// of the incident is deleted and posted again with the new state. The cards
+// of the incidents are kept in --pagerduty-state, so a restart of the relay
+// does not lose them.
+//
+// The rooms of the services are set in the config file (-c), by name or ID
+// of the service, other services go to -t/-r or -D:
+//
+//	{
+//	  "pagerduty": {
+//	    "services": {
+//	      "API Service": { "team": "KMP-Team", "room": "API Incidents" },
+//	      "PF9KMXH": { "team": "KMP-Team", "room": "Shop Incidents" }
+//	    }
+//	  }
+//	}
+//
+// With --pagerduty-secret only events signed with the secret of the
+// PagerDuty webhook subscription (X-PagerDuty-Signature) are accepted.
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// cards of incidents not changed for this long are forgotten
+const pagerDutyKeep = 30 * 24 * time.Hour
+
+// pagerDutyConfig is the section pagerduty of the config file.
+type pagerDutyConfig struct {
+	Services map[string]*eventRoute `json:"services"`
+}
+
+// pdReference is a reference to another PagerDuty object.
+type pdReference struct {
+	ID      string `json:"id"`
+	Summary string `json:"summary"`
+	HTMLURL string `json:"html_url"`
+}
+
+// pdIncident is the data of an incident event.
+type pdIncident struct {
+	ID               string         `json:"id"`
+	Type             string         `json:"type"`
+	HTMLURL          string         `json:"html_url"`
+	Number           int            `json:"number"`
+	Status           string         `json:"status"`
+	Title            string         `json:"title"`
+	Urgency          string         `json:"urgency"`
+	CreatedAt        time.Time      `json:"created_at"`
+	Service          *pdReference   `json:"service"`
+	Assignees        []*pdReference `json:"assignees"`
+	EscalationPolicy *pdReference   `json:"escalation_policy"`
+	Priority         *pdReference   `json:"priority"`
+}
+
+// pdWebhook is the payload of a PagerDuty V3 webhook.
+type pdWebhook struct {
+	Event struct {
+		ID           string          `json:"id"`
+		EventType    string          `json:"event_type"`
+		ResourceType string          `json:"resource_type"`
+		OccurredAt   time.Time       `json:"occurred_at"`
+		Agent        *pdReference    `json:"agent"`
+		Data         json.RawMessage `json:"data"`
+	} `json:"event"`
+}
+
+// pdCard is the card posted for an incident.
+type pdCard struct {
+	MessageID string    `json:"messageId"`
+	RoomID    string    `json:"roomId"`
+	Updated   time.Time `json:"updated"`
+}
+
+// pagerDutyRelay posts the incidents of serve --pagerduty.
+type pagerDutyRelay struct {
+	routes map[string]*eventRoute
+	// cards of the incidents by incident ID, saved in stateFile
+	mu        sync.Mutex
+	cards     map[string]*pdCard
+	stateFile string
+}
+
+// pagerDuty is the relay of serve --pagerduty, nil without.
+var pagerDuty *pagerDutyRelay
+
+func defaultPagerDutyState() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "notify_by_webex_teams", "pagerduty.json")
+}
+
+// newPagerDutyRelay returns the relay with the routes of the config file
+// and the cards of the state file.
+func newPagerDutyRelay() (*pagerDutyRelay, error) {
+	p := &pagerDutyRelay{routes: make(map[string]*eventRoute), cards: make(map[string]*pdCard), stateFile: pagerDutyState}
+	if len(configFile) > 0 {
+		c, err := loadConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+		if c.PagerDuty != nil {
+			for service, r := range c.PagerDuty.Services {
+				if len(r.Team) == 0 && len(r.Email) == 0 {
+					return nil, fmt.Errorf("config file %s, pagerduty service %s: set team or email", configFile, service)
+				}
+				if len(r.Team) > 0 && len(r.Room) == 0 {
+					r.Room = roomName
+				}
+				p.routes[strings.ToLower(service)] = r
+			}
+		}
+	}
+	if len(p.routes) == 0 && len(teamName) == 0 && len(emailAddr) == 0 {
+		return nil, errors.New("--pagerduty needs the rooms of the services in the config file (-c) or flag -t or -D")
+	}
+	data, err := os.ReadFile(p.stateFile)
+	if err == nil {
+		data, err = openState(data)
+	}
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case json.Unmarshal(data, &p.cards) != nil:
+		slog.Warn("invalid PagerDuty state file, starting over", "file", p.stateFile)
+		p.cards = make(map[string]*pdCard)
+	}
+	return p, nil
+}
+
+func handlePagerDuty(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !allowedSource(r) {
+		slog.Warn("PagerDuty event of a source not allowed rejected", "remote", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, "invalid PagerDuty event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(pagerDutySecret) > 0 && !verifyPagerDutySignature(body, r.Header.Get("X-PagerDuty-Signature"), pagerDutySecret) {
+		slog.Warn("PagerDuty event with invalid signature rejected", "remote", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var hook pdWebhook
+	if err := json.Unmarshal(body, &hook); err != nil {
+		http.Error(w, "invalid PagerDuty event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	ev := &hook.Event
+	var inc pdIncident
+	if ev.ResourceType != "incident" || json.Unmarshal(ev.Data, &inc) != nil || inc.Type != "incident" || len(inc.ID) == 0 {
+		// e.g. the ping of a new subscription or an incident note
+		slog.Debug("PagerDuty event ignored", "event", ev.EventType, "id", ev.ID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	agent := ""
+	if ev.Agent != nil {
+		agent = ev.Agent.Summary
+	}
+	fields := map[string]string{"event": ev.EventType, "status": inc.Status, "urgency": inc.Urgency, "title": inc.Title}
+	if inc.Service != nil {
+		fields["service"] = inc.Service.Summary
+	}
+	if inc.Priority != nil {
+		fields["priority"] = inc.Priority.Summary
+	}
+	rule := matchEventRule("pagerduty", fields)
+	if rule != nil && rule.Suppress {
+		slog.Info("event suppressed by rule", "source", "pagerduty", "incident", inc.ID, "event", ev.EventType)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := pagerDuty.post(&inc, ev.EventType, agent, rule); err != nil {
+		slog.Error("posting PagerDuty incident failed", "incident", inc.ID, "event", ev.EventType, "error", err)
+		http.Error(w, redact(err.Error()), http.StatusBadGateway)
+		return
+	}
+	slog.Info("PagerDuty incident posted", "incident", inc.ID, "event", ev.EventType, "status", inc.Status)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verifyPagerDutySignature checks the X-PagerDuty-Signature header, a comma
+// separated list of "v1=<hex>" HMAC-SHA256 of body, one per secret of the
+// subscription during a rotation.
+func verifyPagerDutySignature(body []byte, header, secret string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	want := mac.Sum(nil)
+	for _, s := range strings.Split(header, ",") {
+		sum, ok := strings.CutPrefix(strings.TrimSpace(s), "v1=")
+		if !ok {
+			continue
+		}
+		if got, err := hex.DecodeString(sum); err == nil && hmac.Equal(got, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// post posts the card of the incident, replacing the card posted before.
+// rule is the matching rule of the incident, if any (see rules.go).
+func (p *pagerDutyRelay) post(inc *pdIncident, eventType, agent string, rule *eventRule) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	card, err := pagerDutyCard(inc, eventType, agent)
+	if err != nil {
+		return err
+	}
+	markdown := pagerDutyText(inc)
+	if eventType == "incident.triggered" {
+		mentions := []string(mentionEmails)
+		if rule != nil {
+			mentions = append(append([]string{}, mentions...), rule.Mentions...)
+		}
+		if markdown, err = withMentions(ctx, client, mentions, markdown); err != nil {
+			return err
+		}
+	}
+	m := &webex.MessageRequest{Markdown: markdown, Attachments: []json.RawMessage{json.RawMessage(card)}}
+
+	old := p.cards[inc.ID]
+	if old != nil {
+		m.RoomID = old.RoomID
+	} else {
+		route := p.route(inc)
+		if rule != nil && rule.route() != nil {
+			route = rule.route()
+		}
+		if route == nil {
+			return errors.New("no room for the service")
+		}
+		if len(route.Team) > 0 {
+			if m.RoomID, err = lookupRoomID(ctx, route.Team, route.Room); err != nil {
+				return err
+			}
+		} else {
+			m.ToPersonEmail = route.Email
+		}
+	}
+	msg, err := client.CreateMessage(ctx, m)
+	journalNotification(&notification{Email: m.ToPersonEmail, Markdown: m.Markdown, Card: string(m.Attachments[0])}, msg, err)
+	if err != nil {
+		return err
+	}
+	if old != nil {
+		if err := client.DeleteMessage(ctx, old.MessageID); err != nil {
+			slog.Warn("deleting the old card of the incident failed", "incident", inc.ID, "messageID", old.MessageID, "error", err)
+		}
+	}
+	p.cards[inc.ID] = &pdCard{MessageID: msg.ID, RoomID: msg.RoomID, Updated: time.Now()}
+	return p.save()
+}
+
+// route returns the recipient of the incident, nil if there is none.
+func (p *pagerDutyRelay) route(inc *pdIncident) *eventRoute {
+	if inc.Service != nil {
+		for _, key := range []string{inc.Service.ID, inc.Service.Summary} {
+			if r := p.routes[strings.ToLower(key)]; r != nil {
+				return r
+			}
+		}
+	}
+	if len(teamName) == 0 && len(emailAddr) == 0 {
+		return nil
+	}
+	if len(teamName) > 0 {
+		return &eventRoute{Team: teamName, Room: roomName}
+	}
+	return &eventRoute{Email: emailAddr}
+}
+
+// save writes the cards to the state file. Cards of incidents not changed
+// for pagerDutyKeep are forgotten.
+func (p *pagerDutyRelay) save() error {
+	for id, c := range p.cards {
+		if time.Since(c.Updated) > pagerDutyKeep {
+			delete(p.cards, id)
+		}
+	}
+	data, err := json.Marshal(p.cards)
+	if err != nil {
+		return err
+	}
+	if data, err = sealState(data); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.stateFile), 0700); err != nil {
+		return err
+	}
+	tmp := p.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.stateFile)
+}
+
+// pagerDutyText returns the message of clients without cards.
+func pagerDutyText(inc *pdIncident) string {
+	icon := map[string]string{"triggered": "🔴", "acknowledged": "🟡", "resolved": "✅"}[inc.Status]
+	title := fmt.Sprintf("#%d %s", inc.Number, inc.Title)
+	if len(inc.HTMLURL) > 0 {
+		title = fmt.Sprintf("[%s](%s)", title, inc.HTMLURL)
+	}
+	text := strings.TrimSpace(fmt.Sprintf("%s **%s** %s", icon, title, inc.Status))
+	if inc.Service != nil && len(inc.Service.Summary) > 0 {
+		text += " · " + inc.Service.Summary
+	}
+	return text
+}
+
+// pagerDutyCard returns the card attachment of the incident.
+func pagerDutyCard(inc *pdIncident, eventType, agent string) (string, error) {
+	var facts []interface{}
+	// facts without value are invalid
+	addFact := func(title, value string) {
+		if len(strings.TrimSpace(value)) > 0 {
+			facts = append(facts, map[string]interface{}{"title": title, "value": value})
+		}
+	}
+	summary := func(r *pdReference) string {
+		if r == nil {
+			return ""
+		}
+		return r.Summary
+	}
+	var assignees []string
+	for _, a := range inc.Assignees {
+		assignees = append(assignees, a.Summary)
+	}
+	addFact(tr("Status"), inc.Status)
+	addFact(tr("Service"), summary(inc.Service))
+	addFact(tr("Urgency"), inc.Urgency)
+	addFact(tr("Priority"), summary(inc.Priority))
+	addFact(tr("Assigned to"), strings.Join(assignees, ", "))
+	addFact(tr("Escalation policy"), summary(inc.EscalationPolicy))
+	if !inc.CreatedAt.IsZero() {
+		addFact(tr("Triggered"), formatTimeSeconds(inc.CreatedAt.Local()))
+	}
+	last := strings.ReplaceAll(strings.TrimPrefix(eventType, "incident."), "_", " ")
+	if len(agent) > 0 {
+		last = trf("%s by %s", last, agent)
+	}
+	addFact(tr("Last change"), last)
+
+	color := map[string]string{"triggered": "attention", "acknowledged": "warning", "resolved": "good"}[inc.Status]
+	if len(color) == 0 {
+		color = "default"
+	}
+	title := fmt.Sprintf("#%d %s", inc.Number, inc.Title)
+	items := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": title, "size": "Large", "weight": "Bolder", "wrap": true},
+	}
+	if len(facts) > 0 {
+		items = append(items, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": defaultCardVersion,
+		"body": []interface{}{
+			map[string]interface{}{"type": "Container", "style": color, "bleed": true, "items": items},
+		},
+	}
+	if len(inc.HTMLURL) > 0 {
+		card["actions"] = []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": trf("Open in %s", "PagerDuty"), "url": inc.HTMLURL},
+		}
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pdEvent returns a PagerDuty webhook of an event of incident PT4KHLK.
+func pdEvent(eventType, status string) string {
+	return `{"event": {"id": "01DEN", "event_type": "incident.` + eventType + `", "resource_type": "incident",
+		"agent": {"id": "PLH1HKV", "summary": "Alice"},
+		"data": {"id": "PT4KHLK", "type": "incident", "html_url": "https://acme.pagerduty.com/incidents/PT4KHLK",
+			"number": 2, "status": "` + status + `", "title": "Disk full on srv1", "urgency": "high",
+			"service": {"id": "PF9KMXH", "summary": "API Service"}, "assignees": [{"id": "PTUXL6G", "summary": "Bob"}]}}}`
+}
+
+func TestVerifyPagerDutySignature(t *testing.T) {
+	body := []byte(pdEvent("triggered", "triggered"))
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(body)
+	sum := hex.EncodeToString(mac.Sum(nil))
+	for _, tt := range []struct {
+		header string
+		want   bool
+	}{
+		{"v1=" + sum, true},
+		{"v1=00ff, v1=" + sum, true},
+		{"v1=00ff", false},
+		{sum, false},
+		{"", false},
+	} {
+		if got := verifyPagerDutySignature(body, tt.header, "s3cr3t"); got != tt.want {
+			t.Errorf("verifyPagerDutySignature(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestPagerDutyText(t *testing.T) {
+	for _, tt := range []struct {
+		inc  pdIncident
+		want string
+	}{
+		{pdIncident{Number: 2, Title: "Disk full", Status: "triggered", HTMLURL: "https://acme.pagerduty.com/incidents/PT4KHLK", Service: &pdReference{Summary: "API Service"}},
+			"🔴 **[#2 Disk full](https://acme.pagerduty.com/incidents/PT4KHLK)** triggered · API Service"},
+		{pdIncident{Number: 2, Title: "Disk full", Status: "resolved"}, "✅ **#2 Disk full** resolved"},
+		{pdIncident{Number: 2, Title: "Disk full", Status: "snoozed"}, "**#2 Disk full** snoozed"},
+	} {
+		if got := pagerDutyText(&tt.inc); got != tt.want {
+			t.Errorf("pagerDutyText(%+v) = %q, want %q", tt.inc, got, tt.want)
+		}
+	}
+}
+
+func TestPagerDutyCard(t *testing.T) {
+	inc := &pdIncident{Number: 2, Title: "Disk full", Status: "acknowledged", Urgency: "high",
+		Service: &pdReference{Summary: "API Service"}, Assignees: []*pdReference{{Summary: "Alice"}, {Summary: "Bob"}}}
+	card, err := pagerDutyCard(inc, "incident.priority_updated", "Carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"style":"warning"`, `"text":"#2 Disk full"`, `{"title":"Assigned to","value":"Alice, Bob"}`,
+		`{"title":"Last change","value":"priority updated by Carol"}`} {
+		if !strings.Contains(card, want) {
+			t.Errorf("pagerDutyCard() = %s, want %s", card, want)
+		}
+	}
+	for _, unwanted := range []string{"Priority", "Escalation policy", "Triggered", "actions"} {
+		if strings.Contains(card, unwanted) {
+			t.Errorf("pagerDutyCard() = %s, want no %s", card, unwanted)
+		}
+	}
+}
+
+func TestPagerDutyRoute(t *testing.T) {
+	defer func(team, room, email string) { teamName, roomName, emailAddr = team, room, email }(teamName, roomName, emailAddr)
+	p := &pagerDutyRelay{routes: map[string]*eventRoute{
+		"pf9kmxh":     {Team: "KMP-Team", Room: "Shop Incidents"},
+		"api service": {Team: "KMP-Team", Room: "API Incidents"},
+	}}
+	for _, tt := range []struct {
+		service           *pdReference
+		team, room, email string
+		want              string
+	}{
+		{&pdReference{ID: "PF9KMXH", Summary: "API Service"}, "", "", "", "Shop Incidents"},
+		{&pdReference{ID: "P000000", Summary: "API Service"}, "", "", "", "API Incidents"},
+		{&pdReference{ID: "P000000", Summary: "Billing"}, "KMP-Team", "Incidents", "", "Incidents"},
+		{nil, "", "", "ops@example.com", "ops@example.com"},
+		{nil, "", "", "", ""},
+	} {
+		teamName, roomName, emailAddr = tt.team, tt.room, tt.email
+		got := ""
+		if r := p.route(&pdIncident{Service: tt.service}); r != nil {
+			got = r.Room + r.Email
+		}
+		if got != tt.want {
+			t.Errorf("route(%+v) = %q, want %q", tt.service, got, tt.want)
+		}
+	}
+}
+
+func TestHandlePagerDuty(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(file, team, room, state, secret string, relay *pagerDutyRelay) {
+		configFile, teamName, roomName, pagerDutyState, pagerDutySecret, pagerDuty = file, team, room, state, secret, relay
+	}(configFile, teamName, roomName, pagerDutyState, pagerDutySecret, pagerDuty)
+	dir := t.TempDir()
+	configFile = filepath.Join(dir, "config.json")
+	err := os.WriteFile(configFile, []byte(`{"pagerduty": {"services": {"API Service": {"team": "KMP-Team", "room": "API Incidents"}}}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	teamName, roomName, pagerDutySecret = "", "", ""
+	pagerDutyState = filepath.Join(dir, "state", "pagerduty.json")
+	if pagerDuty, err = newPagerDutyRelay(); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		handlePagerDuty(w, httptest.NewRequest("POST", "/pagerduty", strings.NewReader(body)))
+		return w.Code
+	}
+	if code := post(pdEvent("triggered", "triggered")); code != http.StatusNoContent {
+		t.Fatalf("handlePagerDuty(triggered) status = %d", code)
+	}
+	if code := post(`{"event": {"event_type": "pagey.ping", "resource_type": "pagey"}}`); code != http.StatusNoContent {
+		t.Errorf("handlePagerDuty(ping) status = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := post(`{"event": `); code != http.StatusBadRequest {
+		t.Errorf("handlePagerDuty(invalid) status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	// a restarted relay replaces the card of the incident
+	if pagerDuty, err = newPagerDutyRelay(); err != nil {
+		t.Fatal(err)
+	}
+	if c := pagerDuty.cards["PT4KHLK"]; c == nil || time.Since(c.Updated) > time.Minute {
+		t.Fatalf("card of the incident not in the state file: %+v", pagerDuty.cards)
+	}
+	if code := post(pdEvent("acknowledged", "acknowledged")); code != http.StatusNoContent {
+		t.Fatalf("handlePagerDuty(acknowledged) status = %d", code)
+	}
+	messages := fake.Messages()
+	if len(messages) != 1 || !strings.Contains(messages[0].Markdown, "acknowledged") {
+		t.Fatalf("messages = %+v, want the acknowledged card only", messages)
+	}
+	for _, r := range fake.Rooms() {
+		if r.ID == messages[0].RoomID && r.Title != "API Incidents" {
+			t.Errorf("card posted to %s, want API Incidents", r.Title)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// preupload.go
+//
+// Pre-upload hook (flag --pre-upload-cmd). Every file is checked by a
+// command, e.g. a virus scan or a DLP check, before anything is uploaded,
+// and nothing is sent if the command fails for any file:
+//
+//	notify_by_webex_teams -t KMP-Team -r Reports -f report.pdf --pre-upload-cmd "clamscan --no-summary %f"
+//
+// %f in the command is the file, without %f the file is the last argument.
+// The command is split at spaces, with single or double quotes around
+// arguments with spaces, and run without a shell. A file is checked once
+// per run unless it changes.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// time a check of a file may take
+	preUploadTimeout = 10 * time.Minute
+	// end of the output of a failed check in the error
+	preUploadOutput = 500
+)
+
+var (
+	checkedFilesMutex sync.Mutex
+	// checkedFiles are the files passed, with size and modification time
+	checkedFiles = make(map[string]string)
+)
+
+// preUploadCheck runs the command of --pre-upload-cmd for the files.
+func preUploadCheck(files []string) error {
+	if len(preUploadCmd) == 0 || len(files) == 0 {
+		return nil
+	}
+	args, err := splitCommandLine(preUploadCmd)
+	if err != nil {
+		return fmt.Errorf("--pre-upload-cmd: %w", err)
+	}
+	if len(args) == 0 {
+		return errors.New("--pre-upload-cmd: no command")
+	}
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		state := fmt.Sprint(fi.Size(), fi.ModTime().UnixNano())
+		checkedFilesMutex.Lock()
+		checked := checkedFiles[file] == state
+		checkedFilesMutex.Unlock()
+		if checked {
+			continue
+		}
+		if err := runPreUpload(args, file); err != nil {
+			return err
+		}
+		checkedFilesMutex.Lock()
+		checkedFiles[file] = state
+		checkedFilesMutex.Unlock()
+	}
+	return nil
+}
+
+// runPreUpload runs the command args for the file.
+func runPreUpload(args []string, file string) error {
+	argv := make([]string, 0, len(args)+1)
+	replaced := false
+	for _, a := range args {
+		if strings.Contains(a, "%f") {
+			a = strings.ReplaceAll(a, "%f", file)
+			replaced = true
+		}
+		argv = append(argv, a)
+	}
+	if !replaced {
+		argv = append(argv, file)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), preUploadTimeout)
+	defer cancel()
+	output := &tailBuffer{max: preUploadOutput}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = 5 * time.Second
+	start := time.Now()
+	err := cmd.Run()
+	slog.Debug("pre-upload command", "file", file, "duration", time.Since(start).Round(time.Millisecond), "error", err)
+	if ctx.Err() != nil {
+		return fmt.Errorf("--pre-upload-cmd timed out after %s for %s", preUploadTimeout, file)
+	}
+	if err != nil {
+		out := strings.Join(strings.Fields(output.String()), " ")
+		if len(out) > 0 {
+			return fmt.Errorf("--pre-upload-cmd rejected %s: %v: %s", file, err, out)
+		}
+		return fmt.Errorf("--pre-upload-cmd rejected %s: %v", file, err)
+	}
+	return nil
+}
+
+// splitCommandLine splits s at spaces. Single or double quotes group
+// arguments with spaces.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("missing closing quote %c", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    []string
+		wantErr string
+	}{
+		{"clamscan --no-summary %f", []string{"clamscan", "--no-summary", "%f"}, ""},
+		{"  dlp-check\t'--policy=Internal Only' \"%f\"  ", []string{"dlp-check", "--policy=Internal Only", "%f"}, ""},
+		{`scan "" it's`, nil, "missing closing quote '"},
+		{`scan ""`, []string{"scan", ""}, ""},
+		{"", nil, ""},
+	} {
+		got, err := splitCommandLine(tt.in)
+		if errorString(err) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommandLine(%q) = %q, %v, want %q, %s", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPreUploadCheck(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	defer func(cmd string) { preUploadCmd = cmd }(preUploadCmd)
+	dir := t.TempDir()
+	log := filepath.Join(dir, "checked.log")
+	clean := filepath.Join(dir, "report.pdf")
+	infected := filepath.Join(dir, "eicar.com")
+	for file, content := range map[string]string{clean: "%PDF", infected: "EICAR"} {
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the command logs the checked file and fails for the EICAR test file
+	script := `echo "$0" >> ` + log + `; if grep -q EICAR "$0"; then echo "$0: Eicar-Signature FOUND"; exit 1; fi`
+	checked := func() []string {
+		data, _ := os.ReadFile(log)
+		return strings.Fields(string(data))
+	}
+
+	preUploadCmd = "sh -c '" + script + "' %f"
+	if err := preUploadCheck([]string{clean}); err != nil || !reflect.DeepEqual(checked(), []string{clean}) {
+		t.Fatalf("preUploadCheck(%s) = %v, checked %q", clean, err, checked())
+	}
+	// checked once unless changed
+	if err := preUploadCheck([]string{clean}); err != nil || len(checked()) != 1 {
+		t.Errorf("second preUploadCheck(%s) = %v, checked %q", clean, err, checked())
+	}
+	if err := os.WriteFile(clean, []byte("%PDF-1.7"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := preUploadCheck([]string{clean, infected})
+	want := "--pre-upload-cmd rejected " + infected + ": exit status 1: " + infected + ": Eicar-Signature FOUND"
+	if errorString(err) != want || len(checked()) != 3 {
+		t.Errorf("preUploadCheck(%s, %s) = %v, checked %q, want %s", clean, infected, err, checked(), want)
+	}
+
+	// without %f the file is the last argument
+	preUploadCmd = "sh -c 'test \"$1\" = " + infected + "' sh"
+	if err := preUploadCheck([]string{infected}); err != nil {
+		t.Errorf("preUploadCheck() with the file as last argument = %v", err)
+	}
+
+	for _, tt := range []struct{ cmd, wantErr string }{
+		{"", ""},
+		{" ", "--pre-upload-cmd: no command"},
+		{"scan 'report", "--pre-upload-cmd: missing closing quote '"},
+	} {
+		preUploadCmd = tt.cmd
+		if got := errorString(preUploadCheck([]string{clean})); got != tt.wantErr {
+			t.Errorf("preUploadCheck() of %q = %q, want %q", tt.cmd, got, tt.wantErr)
+		}
+	}
+}
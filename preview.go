@@ -0,0 +1,499 @@
+// preview.go
+//
+// Local preview of a message (flags --preview and --preview-html). The
+// markdown subset supported by Webex (headings, emphasis, code, links,
+// lists and quotes) and Adaptive Card attachments are rendered to the
+// terminal or to an HTML file, so the formatting can be checked before the
+// message is sent.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// markdownRenderer formats the elements of a markdown message. The inline
+// methods get already rendered content, except text, code and codeBlock.
+type markdownRenderer interface {
+	text(s string) string
+	bold(s string) string
+	italic(s string) string
+	code(s string) string
+	link(text, url string) string
+	heading(level int, s string) string
+	paragraph(lines []string) string
+	list(ordered bool, items []string) string
+	quote(lines []string) string
+	codeBlock(lines []string) string
+}
+
+var (
+	headingPattern  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bulletPattern   = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	orderedPattern  = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	quotePattern    = regexp.MustCompile(`^>\s?(.*)$`)
+	fencePattern    = regexp.MustCompile("^\\s*```")
+	linkTextPattern = regexp.MustCompile(`^\[([^\]]*)\]\(([^)\s]+)\)`)
+)
+
+// renderMarkdown renders the markdown message md with r.
+func renderMarkdown(md string, r markdownRenderer) string {
+	var out strings.Builder
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fencePattern.MatchString(line):
+			var block []string
+			for i++; i < len(lines) && !fencePattern.MatchString(lines[i]); i++ {
+				block = append(block, lines[i])
+			}
+			i++
+			out.WriteString(r.codeBlock(block))
+		case headingPattern.MatchString(line):
+			m := headingPattern.FindStringSubmatch(line)
+			out.WriteString(r.heading(len(m[1]), renderInline(m[2], r)))
+			i++
+		case quotePattern.MatchString(line):
+			var block []string
+			for ; i < len(lines) && quotePattern.MatchString(lines[i]); i++ {
+				block = append(block, renderInline(quotePattern.FindStringSubmatch(lines[i])[1], r))
+			}
+			out.WriteString(r.quote(block))
+		case bulletPattern.MatchString(line), orderedPattern.MatchString(line):
+			ordered := orderedPattern.MatchString(line)
+			pattern := bulletPattern
+			if ordered {
+				pattern = orderedPattern
+			}
+			var items []string
+			for ; i < len(lines) && pattern.MatchString(lines[i]); i++ {
+				items = append(items, renderInline(pattern.FindStringSubmatch(lines[i])[1], r))
+			}
+			out.WriteString(r.list(ordered, items))
+		default:
+			var block []string
+			for ; i < len(lines) && isParagraphLine(lines[i]); i++ {
+				block = append(block, renderInline(lines[i], r))
+			}
+			out.WriteString(r.paragraph(block))
+		}
+	}
+	return out.String()
+}
+
+func isParagraphLine(line string) bool {
+	return strings.TrimSpace(line) != "" &&
+		!fencePattern.MatchString(line) &&
+		!headingPattern.MatchString(line) &&
+		!quotePattern.MatchString(line) &&
+		!bulletPattern.MatchString(line) &&
+		!orderedPattern.MatchString(line)
+}
+
+// renderInline renders code spans, links and emphasis of a single line.
+func renderInline(s string, r markdownRenderer) string {
+	var out strings.Builder
+	prev := ' '
+	for len(s) > 0 {
+		if rendered, rest, ok := renderInlineElement(s, prev, r); ok {
+			out.WriteString(rendered)
+			prev = 'x'
+			s = rest
+			continue
+		}
+		i := strings.IndexAny(s[1:], "`*_[")
+		if i < 0 {
+			i = len(s) - 1
+		}
+		out.WriteString(r.text(s[:i+1]))
+		prev = lastRune(s[:i+1])
+		s = s[i+1:]
+	}
+	return out.String()
+}
+
+func renderInlineElement(s string, prev rune, r markdownRenderer) (string, string, bool) {
+	switch {
+	case s[0] == '`':
+		if end := strings.IndexByte(s[1:], '`'); end >= 0 {
+			return r.code(s[1 : 1+end]), s[end+2:], true
+		}
+	case s[0] == '[':
+		if m := linkTextPattern.FindStringSubmatch(s); m != nil {
+			return r.link(renderInline(m[1], r), m[2]), s[len(m[0]):], true
+		}
+	case strings.HasPrefix(s, "**"), strings.HasPrefix(s, "__"):
+		delim := s[:2]
+		if end := strings.Index(s[2:], delim); end > 0 {
+			return r.bold(renderInline(s[2:2+end], r)), s[end+4:], true
+		}
+	case s[0] == '*', s[0] == '_':
+		// intra-word underscores like in file_name are no emphasis
+		if s[0] == '_' && (unicode.IsLetter(prev) || unicode.IsDigit(prev)) {
+			return "", s, false
+		}
+		if end := strings.IndexByte(s[1:], s[0]); end > 0 && s[1] != ' ' {
+			return r.italic(renderInline(s[1:1+end], r)), s[end+2:], true
+		}
+	}
+	return "", s, false
+}
+
+func lastRune(s string) rune {
+	runes := []rune(s)
+	return runes[len(runes)-1]
+}
+
+// terminalRenderer renders markdown for the terminal, with ANSI escape
+// sequences if color is set.
+type terminalRenderer struct {
+	color bool
+}
+
+func (t terminalRenderer) style(code, s string) string {
+	if !t.color {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+func (t terminalRenderer) text(s string) string   { return s }
+func (t terminalRenderer) bold(s string) string   { return t.style("1", s) }
+func (t terminalRenderer) italic(s string) string { return t.style("3", s) }
+func (t terminalRenderer) code(s string) string   { return t.style("36", s) }
+
+func (t terminalRenderer) link(text, url string) string {
+	if text == url {
+		return t.style("4", url)
+	}
+	return t.style("4", text) + " <" + url + ">"
+}
+
+func (t terminalRenderer) heading(level int, s string) string {
+	if !t.color {
+		return s + "\n" + strings.Repeat("=", len([]rune(s))) + "\n\n"
+	}
+	return t.style("1;4", s) + "\n\n"
+}
+
+func (t terminalRenderer) paragraph(lines []string) string {
+	return strings.Join(lines, "\n") + "\n\n"
+}
+
+func (t terminalRenderer) list(ordered bool, items []string) string {
+	var out strings.Builder
+	for i, item := range items {
+		marker := "  •"
+		if ordered {
+			marker = fmt.Sprintf("%3d.", i+1)
+		}
+		fmt.Fprintf(&out, "%s %s\n", marker, item)
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
+func (t terminalRenderer) quote(lines []string) string {
+	var out strings.Builder
+	for _, line := range lines {
+		out.WriteString(t.style("2", "│ ") + line + "\n")
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
+func (t terminalRenderer) codeBlock(lines []string) string {
+	var out strings.Builder
+	for _, line := range lines {
+		out.WriteString("    " + t.code(line) + "\n")
+	}
+	out.WriteString("\n")
+	return out.String()
+}
+
+// htmlRenderer renders markdown as HTML.
+type htmlRenderer struct{}
+
+func (htmlRenderer) text(s string) string   { return html.EscapeString(s) }
+func (htmlRenderer) bold(s string) string   { return "<strong>" + s + "</strong>" }
+func (htmlRenderer) italic(s string) string { return "<em>" + s + "</em>" }
+func (htmlRenderer) code(s string) string   { return "<code>" + html.EscapeString(s) + "</code>" }
+
+// link renders http, https and mailto links only. Other URLs, e.g.
+// javascript: in a message of someone else in an export, are shown as text.
+func (htmlRenderer) link(text, url string) string {
+	if !safeURL(url, "http", "https", "mailto") {
+		return text + " &lt;" + html.EscapeString(url) + "&gt;"
+	}
+	return `<a href="` + html.EscapeString(url) + `">` + text + "</a>"
+}
+
+// safeURL reports whether url is absolute with one of the schemes.
+func safeURL(url string, schemes ...string) bool {
+	scheme, _, ok := strings.Cut(url, ":")
+	if !ok {
+		return false
+	}
+	for _, s := range schemes {
+		if strings.EqualFold(scheme, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (htmlRenderer) heading(level int, s string) string {
+	return fmt.Sprintf("<h%d>%s</h%d>\n", level, s, level)
+}
+
+func (htmlRenderer) paragraph(lines []string) string {
+	return "<p>" + strings.Join(lines, "<br>\n") + "</p>\n"
+}
+
+func (htmlRenderer) list(ordered bool, items []string) string {
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	return "<" + tag + ">\n<li>" + strings.Join(items, "</li>\n<li>") + "</li>\n</" + tag + ">\n"
+}
+
+func (htmlRenderer) quote(lines []string) string {
+	return "<blockquote>" + strings.Join(lines, "<br>\n") + "</blockquote>\n"
+}
+
+func (htmlRenderer) codeBlock(lines []string) string {
+	return "<pre><code>" + html.EscapeString(strings.Join(lines, "\n")) + "</code></pre>\n"
+}
+
+// cardElement is a flattened element of an Adaptive Card for the preview.
+type cardElement struct {
+	kind  string // text, fact, image, input or action
+	label string
+	text  string
+	bold  bool
+}
+
+// cardElements flattens the Adaptive Card of an attachment. card is either
+// an attachment with contentType and content or the bare card.
+func cardElements(card string) ([]cardElement, error) {
+	var attachment struct {
+		Content map[string]interface{} `json:"content"`
+	}
+	err := json.Unmarshal([]byte(card), &attachment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid card: %v", err)
+	}
+	content := attachment.Content
+	if content == nil {
+		json.Unmarshal([]byte(card), &content)
+	}
+
+	var elements []cardElement
+	walkCardItems(content["body"], &elements)
+	walkCardActions(content["actions"], &elements)
+	return elements, nil
+}
+
+func walkCardItems(v interface{}, elements *[]cardElement) {
+	items, _ := v.([]interface{})
+	for _, item := range items {
+		m, _ := item.(map[string]interface{})
+		typ, _ := m["type"].(string)
+		switch {
+		case typ == "TextBlock" || typ == "RichTextBlock":
+			text, _ := m["text"].(string)
+			if typ == "RichTextBlock" {
+				text = richText(m["inlines"])
+			}
+			weight, _ := m["weight"].(string)
+			size, _ := m["size"].(string)
+			*elements = append(*elements, cardElement{kind: "text", text: text, bold: strings.EqualFold(weight, "bolder") || strings.EqualFold(size, "large")})
+		case typ == "FactSet":
+			facts, _ := m["facts"].([]interface{})
+			for _, f := range facts {
+				fact, _ := f.(map[string]interface{})
+				title, _ := fact["title"].(string)
+				value, _ := fact["value"].(string)
+				*elements = append(*elements, cardElement{kind: "fact", label: title, text: value})
+			}
+		case typ == "Image":
+			url, _ := m["url"].(string)
+			alt, _ := m["altText"].(string)
+			*elements = append(*elements, cardElement{kind: "image", label: alt, text: url})
+		case strings.HasPrefix(typ, "Input."):
+			label, _ := m["label"].(string)
+			if len(label) == 0 {
+				label, _ = m["title"].(string)
+			}
+			placeholder, _ := m["placeholder"].(string)
+			*elements = append(*elements, cardElement{kind: "input", label: label, text: placeholder})
+		case typ == "ColumnSet":
+			columns, _ := m["columns"].([]interface{})
+			for _, c := range columns {
+				column, _ := c.(map[string]interface{})
+				walkCardItems(column["items"], elements)
+			}
+		case typ == "ActionSet":
+			walkCardActions(m["actions"], elements)
+		default:
+			// Container, Column and unknown elements with nested items
+			walkCardItems(m["items"], elements)
+		}
+	}
+}
+
+func walkCardActions(v interface{}, elements *[]cardElement) {
+	actions, _ := v.([]interface{})
+	for _, a := range actions {
+		m, _ := a.(map[string]interface{})
+		title, _ := m["title"].(string)
+		url, _ := m["url"].(string)
+		*elements = append(*elements, cardElement{kind: "action", label: title, text: url})
+	}
+}
+
+func richText(v interface{}) string {
+	var texts []string
+	inlines, _ := v.([]interface{})
+	for _, inline := range inlines {
+		switch i := inline.(type) {
+		case string:
+			texts = append(texts, i)
+		case map[string]interface{}:
+			text, _ := i["text"].(string)
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "")
+}
+
+func renderCardTerminal(elements []cardElement, t terminalRenderer) string {
+	var out strings.Builder
+	out.WriteString(t.style("2", "┌─ card") + "\n")
+	var actions []string
+	for _, e := range elements {
+		var line string
+		switch e.kind {
+		case "text":
+			line = renderInline(e.text, t)
+			if e.bold {
+				line = t.bold(line)
+			}
+		case "fact":
+			line = t.bold(e.label) + " " + e.text
+		case "image":
+			line = "[image " + strings.TrimSpace(e.label+" "+e.text) + "]"
+		case "input":
+			line = strings.TrimSpace(e.label + " [" + e.text + "____]")
+		case "action":
+			actions = append(actions, "[ "+e.label+" ]")
+			continue
+		}
+		for _, l := range strings.Split(line, "\n") {
+			out.WriteString(t.style("2", "│ ") + l + "\n")
+		}
+	}
+	if len(actions) > 0 {
+		out.WriteString(t.style("2", "│ ") + strings.Join(actions, " ") + "\n")
+	}
+	out.WriteString(t.style("2", "└─") + "\n")
+	return out.String()
+}
+
+func renderCardHTML(elements []cardElement) string {
+	var out strings.Builder
+	r := htmlRenderer{}
+	out.WriteString("<div class=\"card\">\n")
+	for _, e := range elements {
+		switch e.kind {
+		case "text":
+			text := renderInline(e.text, r)
+			if e.bold {
+				text = r.bold(text)
+			}
+			out.WriteString("<p>" + text + "</p>\n")
+		case "fact":
+			out.WriteString("<p><strong>" + html.EscapeString(e.label) + "</strong> " + html.EscapeString(e.text) + "</p>\n")
+		case "image":
+			if !safeURL(e.text, "http", "https") {
+				out.WriteString("<p>" + html.EscapeString(e.label) + " &lt;" + html.EscapeString(e.text) + "&gt;</p>\n")
+				continue
+			}
+			out.WriteString(`<img src="` + html.EscapeString(e.text) + `" alt="` + html.EscapeString(e.label) + "\">\n")
+		case "input":
+			out.WriteString("<label>" + html.EscapeString(e.label) + ` <input placeholder="` + html.EscapeString(e.text) + "\"></label>\n")
+		case "action":
+			out.WriteString("<button>" + html.EscapeString(e.label) + "</button>\n")
+		}
+	}
+	out.WriteString("</div>\n")
+	return out.String()
+}
+
+const previewHTMLHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>message preview</title>
+<style>
+body { font-family: sans-serif; max-width: 46em; margin: 2em auto; color: #222; }
+code, pre { background: #f3f3f3; border-radius: 3px; }
+pre { padding: .5em; overflow-x: auto; }
+blockquote { border-left: 3px solid #ccc; margin-left: 0; padding-left: 1em; color: #555; }
+.card { border: 1px solid #ccc; border-radius: 6px; padding: .5em 1em; margin-top: 1em; }
+.card img { max-width: 100%; }
+.card button { margin-right: .5em; }
+.message { border-bottom: 1px solid #eee; padding: .5em 0; }
+.meta { color: #777; margin-bottom: 0; }
+</style>
+</head>
+<body>
+`
+
+// previewMessage renders the markdown message and the card attachment
+// (if any) to the terminal or, if htmlFile is set, to an HTML file.
+func previewMessage(markdown, card, htmlFile string) error {
+	var elements []cardElement
+	if len(card) > 0 {
+		var err error
+		elements, err = cardElements(card)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(htmlFile) > 0 {
+		var out strings.Builder
+		out.WriteString(previewHTMLHeader)
+		out.WriteString(renderMarkdown(markdown, htmlRenderer{}))
+		if len(card) > 0 {
+			out.WriteString(renderCardHTML(elements))
+		}
+		out.WriteString("</body>\n</html>\n")
+		return os.WriteFile(htmlFile, []byte(out.String()), 0644)
+	}
+
+	t := terminalRenderer{color: isTerminal(os.Stdout)}
+	fmt.Print(renderMarkdown(markdown, t))
+	if len(card) > 0 {
+		fmt.Print(renderCardTerminal(elements, t))
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a character device, i.e. most likely a
+// terminal which understands ANSI escape sequences.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
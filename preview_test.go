@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownTerminal(t *testing.T) {
+	for _, tt := range []struct {
+		md    string
+		color bool
+		want  string
+	}{
+		{"# Alert\n\nbackup **failed** on `db-1`", false, "Alert\n=====\n\nbackup failed on db-1\n\n"},
+		{"- one\n- two", false, "  • one\n  • two\n\n"},
+		{"1. stop\n2) start", false, "  1. stop\n  2. start\n\n"},
+		{"> quoted\n> more", false, "│ quoted\n│ more\n\n"},
+		{"```\nx := 1\n```", false, "    x := 1\n\n"},
+		{"see [docs](https://docs.example.com)\n[https://x.example](https://x.example)", false, "see docs <https://docs.example.com>\nhttps://x.example\n\n"},
+		{"file_name_here and _emph_ and *it*", false, "file_name_here and emph and it\n\n"},
+		{"backup **failed**", true, "backup \x1b[1mfailed\x1b[0m\n\n"},
+		{"# Alert", true, "\x1b[1;4mAlert\x1b[0m\n\n"},
+	} {
+		if got := renderMarkdown(tt.md, terminalRenderer{color: tt.color}); got != tt.want {
+			t.Errorf("renderMarkdown(%q, color %v) = %q, want %q", tt.md, tt.color, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdownHTML(t *testing.T) {
+	for _, tt := range []struct{ md, want string }{
+		{"## Alert", "<h2>Alert</h2>\n"},
+		{"a < b\r\nc", "<p>a &lt; b<br>\nc</p>\n"},
+		{"- x\n- **y**", "<ul>\n<li>x</li>\n<li><strong>y</strong></li>\n</ul>\n"},
+		{"1. x", "<ol>\n<li>x</li>\n</ol>\n"},
+		{"> _q_", "<blockquote><em>q</em></blockquote>\n"},
+		{"```\n<b>\n```", "<pre><code>&lt;b&gt;</code></pre>\n"},
+		{"`a<b`", "<p><code>a&lt;b</code></p>\n"},
+	} {
+		if got := renderMarkdown(tt.md, htmlRenderer{}); got != tt.want {
+			t.Errorf("renderMarkdown(%q) = %q, want %q", tt.md, got, tt.want)
+		}
+	}
+}
+
+func TestCardElements(t *testing.T) {
+	content := `{"type":"AdaptiveCard","body":[
+		{"type":"TextBlock","text":"Backup failed","weight":"Bolder"},
+		{"type":"FactSet","facts":[{"title":"Host","value":"db-1"}]},
+		{"type":"ColumnSet","columns":[{"type":"Column","items":[{"type":"Image","url":"https://example.com/chart.png","altText":"chart"}]}]},
+		{"type":"Container","items":[{"type":"RichTextBlock","inlines":["exit ",{"type":"TextRun","text":"code 2"}]}]},
+		{"type":"Input.Text","label":"Reason","placeholder":"why"},
+		{"type":"ActionSet","actions":[{"type":"Action.Submit","title":"Ack"}]}],
+		"actions":[{"type":"Action.OpenUrl","title":"Open","url":"https://example.com/runbook"}]}`
+	want := []cardElement{
+		{kind: "text", text: "Backup failed", bold: true},
+		{kind: "fact", label: "Host", text: "db-1"},
+		{kind: "image", label: "chart", text: "https://example.com/chart.png"},
+		{kind: "text", text: "exit code 2"},
+		{kind: "input", label: "Reason", text: "why"},
+		{kind: "action", label: "Ack"},
+		{kind: "action", label: "Open", text: "https://example.com/runbook"},
+	}
+	for _, card := range []string{content, `{"contentType":"application/vnd.microsoft.card.adaptive","content":` + content + `}`} {
+		got, err := cardElements(card)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("cardElements(%s) = %+v, %v, want %+v", card, got, err, want)
+		}
+	}
+	if _, err := cardElements(`{"body":`); err == nil || !strings.HasPrefix(err.Error(), "invalid card: ") {
+		t.Errorf("cardElements() of invalid JSON error = %v", err)
+	}
+
+	terminal := "┌─ card\n│ Backup failed\n│ Host db-1\n│ [image chart https://example.com/chart.png]\n│ exit code 2\n│ Reason [why____]\n│ [ Ack ] [ Open ]\n└─\n"
+	if got := renderCardTerminal(want, terminalRenderer{}); got != terminal {
+		t.Errorf("renderCardTerminal() = %q, want %q", got, terminal)
+	}
+	html := renderCardHTML(append(want, cardElement{kind: "image", label: "x", text: "javascript:alert(1)"}))
+	for _, s := range []string{"<p><strong>Backup failed</strong></p>", `<img src="https://example.com/chart.png" alt="chart">`,
+		`<label>Reason <input placeholder="why"></label>`, "<button>Open</button>", "<p>x &lt;javascript:alert(1)&gt;</p>"} {
+		if !strings.Contains(html, s) {
+			t.Errorf("renderCardHTML() = %s, want %s", html, s)
+		}
+	}
+}
+
+func TestPreviewMessage(t *testing.T) {
+	card := `{"body":[{"type":"TextBlock","text":"Backup failed"}]}`
+	var err error
+	out := captureStdout(t, func() { err = previewMessage("# Alert", card, "") })
+	if want := "Alert\n=====\n\n┌─ card\n│ Backup failed\n└─\n"; err != nil || out != want {
+		t.Errorf("previewMessage() = %v, %q, want %q", err, out, want)
+	}
+
+	file := filepath.Join(t.TempDir(), "preview.html")
+	if err := previewMessage("# Alert", card, file); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if html := string(data); err != nil || !strings.HasPrefix(html, "<!DOCTYPE html>") || !strings.Contains(html, "<h1>Alert</h1>\n<div class=\"card\">\n<p>Backup failed</p>") ||
+		!strings.HasSuffix(html, "</body>\n</html>\n") {
+		t.Errorf("previewMessage(%s) = %v, %s", file, err, data)
+	}
+
+	if err := previewMessage("# Alert", "{", ""); err == nil || !strings.HasPrefix(err.Error(), "invalid card: ") {
+		t.Errorf("previewMessage() of an invalid card error = %v", err)
+	}
+}
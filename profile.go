@@ -0,0 +1,167 @@
+// profile.go
+//
+// Profiles of the config file (-c) for bots in several Webex organizations,
+// each with its own token, API URL and proxy server. --profile selects the
+// profile of all messages, "profile" of a routing rule (see routing.go) or of
+// a relayed notification the profile of a single message:
+//
+//	{
+//	  "profiles": {
+//	    "org-a": { "token": "<bot token of org A>" },
+//	    "org-b": { "tokenFile": "/etc/notify/org-b.token", "proxy": "http://proxy.b.example.com:3128" }
+//	  },
+//	  "routes": [
+//	    { "labels": { "org": "b" }, "team": "B-Ops", "room": "Alerts", "profile": "org-b" }
+//	  ]
+//	}
+//
+//	notify_by_webex_teams -c /etc/notify/config.json --profile org-a -t KMP-Team -r Alerts -m "backup done"
+//
+// -T, -p and --api-url override the token, proxy server and API URL of
+// --profile. The API URL usgov is the one of Webex for Government. Messages
+// without profile use --profile or else the flags.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// profile is a bot of the config file.
+type profile struct {
+	Token     string `json:"token"`
+	TokenFile string `json:"tokenFile"`
+	APIURL    string `json:"apiUrl"`
+	Proxy     string `json:"proxy"`
+}
+
+// check checks the profile of the given name.
+func (p *profile) check(name string) error {
+	if len(p.Token) > 0 && len(p.TokenFile) > 0 {
+		return fmt.Errorf("profile %s: use token or tokenFile", name)
+	}
+	if len(p.Token) == 0 && len(p.TokenFile) == 0 {
+		return fmt.Errorf("profile %s: no token or tokenFile", name)
+	}
+	if _, err := resolveAPIURL(p.APIURL); err != nil {
+		return fmt.Errorf("profile %s: %v", name, err)
+	}
+	return nil
+}
+
+// token returns the token of the profile.
+func (p *profile) token() (string, error) {
+	if len(p.TokenFile) == 0 {
+		return p.Token, nil
+	}
+	data, err := os.ReadFile(p.TokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+var (
+	profileMutex   sync.Mutex
+	profileClients = make(map[string]*webex.Client)
+)
+
+// lookupProfile returns the profile of the given name of the config file.
+func lookupProfile(name string) (*profile, error) {
+	if len(configFile) == 0 {
+		return nil, fmt.Errorf("profile %s: no config file. use flag -c", name)
+	}
+	c, err := loadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q of config file %s", name, configFile)
+	}
+	return p, nil
+}
+
+// applyProfile sets the token, API URL and proxy server of --profile unless
+// given by flags.
+func applyProfile() error {
+	if len(profileName) == 0 {
+		return nil
+	}
+	p, err := lookupProfile(profileName)
+	if err != nil {
+		return err
+	}
+	if !flagGiven("T", "token") {
+		if apiToken, err = p.token(); err != nil {
+			return fmt.Errorf("profile %s: %w", profileName, err)
+		}
+		addSecret(apiToken)
+	}
+	if !flagGiven("p", "proxy") && len(p.Proxy) > 0 {
+		proxyString = p.Proxy
+		addURLSecret(proxyString)
+	}
+	if !flagGiven("api-url") {
+		apiBaseURL = p.APIURL
+	}
+	slog.Debug("profile", "name", profileName)
+	return nil
+}
+
+// profileClient returns the Webex client of the profile of the given name,
+// the client of the flags and --profile if name is empty or --profile.
+func profileClient(name string) (*webex.Client, error) {
+	if len(name) == 0 || name == profileName {
+		return webexClient()
+	}
+	profileMutex.Lock()
+	defer profileMutex.Unlock()
+	if client, ok := profileClients[name]; ok {
+		return client, nil
+	}
+	p, err := lookupProfile(name)
+	if err != nil {
+		return nil, err
+	}
+	token, err := p.token()
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	if len(token) == 0 {
+		return nil, errors.New("profile " + name + ": empty token")
+	}
+	addSecret(token)
+	proxy := p.Proxy
+	if len(proxy) == 0 {
+		proxy = proxyString
+	}
+	addURLSecret(proxy)
+	baseURL, _ := resolveAPIURL(p.APIURL)
+	httpClient, err := newHTTPClient(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	client := &webex.Client{
+		TokenSource: webex.StaticToken(token),
+		BaseURL:     baseURL,
+		HTTPClient:  httpClient,
+		Logger:      slog.Default().With("profile", name),
+
+		RateLimitRetries: maxRetries,
+		RetryInitialWait: retryInitWait,
+		RetryMaxWait:     retryMaxWait,
+		RetryOn:          retryStatus,
+		OnRequest:        observeRequest,
+		FilterMessage:    scrubMessage,
+		FilterFile:       scrubFile,
+	}
+	profileClients[name] = client
+	return client, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestProfileCheck(t *testing.T) {
+	for _, tt := range []struct {
+		p       profile
+		wantErr string
+	}{
+		{profile{Token: "t"}, ""},
+		{profile{TokenFile: "/etc/notify/org-b.token", APIURL: "usgov"}, ""},
+		{profile{Token: "t", APIURL: "https://webex.example.com/v1"}, ""},
+		{profile{Token: "t", TokenFile: "/etc/notify/org-b.token"}, "profile org-b: use token or tokenFile"},
+		{profile{}, "profile org-b: no token or tokenFile"},
+		{profile{Token: "t", APIURL: "ftp://webex.example.com"}, `profile org-b: invalid API URL "ftp://webex.example.com"`},
+	} {
+		if got := errorString(tt.p.check("org-b")); !strings.HasPrefix(got, tt.wantErr) || (len(tt.wantErr) == 0) != (len(got) == 0) {
+			t.Errorf("check(%+v) = %q, want %q", tt.p, got, tt.wantErr)
+		}
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	defer func(file, name, token, proxy, api string) {
+		configFile, profileName, apiToken, proxyString, apiBaseURL = file, name, token, proxy, api
+	}(configFile, profileName, apiToken, proxyString, apiBaseURL)
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "org-b.token")
+	if err := os.WriteFile(tokenFile, []byte("org-b-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configFile = filepath.Join(dir, "config.json")
+	config := `{"profiles": {
+		"org-a": {"token": "org-a-token"},
+		"org-b": {"tokenFile": "` + tokenFile + `", "apiUrl": "usgov", "proxy": "http://proxy.b.example.com:3128"},
+		"org-c": {"tokenFile": "` + filepath.Join(dir, "missing") + `"}}}`
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name                 string
+		wantToken, wantProxy string
+		wantAPIURL, wantErr  string
+	}{
+		{name: "", wantToken: "flag-token"},
+		{name: "org-a", wantToken: "org-a-token"},
+		{name: "org-b", wantToken: "org-b-token", wantProxy: "http://proxy.b.example.com:3128", wantAPIURL: "usgov"},
+		{name: "org-c", wantErr: "profile org-c: open "},
+		{name: "org-d", wantErr: `unknown profile "org-d" of config file ` + configFile},
+	} {
+		profileName, apiToken, proxyString, apiBaseURL = tt.name, "flag-token", "", ""
+		err := applyProfile()
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("applyProfile(%q) error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || apiToken != tt.wantToken || proxyString != tt.wantProxy || apiBaseURL != tt.wantAPIURL {
+			t.Errorf("applyProfile(%q) = %v, token %q, proxy %q, API URL %q, want %q, %q, %q",
+				tt.name, err, apiToken, proxyString, apiBaseURL, tt.wantToken, tt.wantProxy, tt.wantAPIURL)
+		}
+	}
+
+	configFile = ""
+	if _, err := lookupProfile("org-a"); err == nil || !strings.Contains(err.Error(), "no config file") {
+		t.Errorf("lookupProfile() without config file error = %v", err)
+	}
+}
+
+func TestProfileClient(t *testing.T) {
+	fake := useFakeWebex(t)
+	defer func(file, name string) { configFile, profileName = file, name }(configFile, profileName)
+	defer func() { profileClients = make(map[string]*webex.Client) }()
+	configFile = filepath.Join(t.TempDir(), "config.json")
+	config := `{"profiles": {
+		"default": {"token": "test-token"},
+		"org-b": {"token": "test-token", "apiUrl": "` + fake.BaseURL() + `"},
+		"org-c": {"token": "test-token", "apiUrl": "usgov"}}}`
+	if err := os.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	profileName = "default"
+
+	client, err := webexClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"", "default"} {
+		if got, err := profileClient(name); err != nil || got != client {
+			t.Errorf("profileClient(%q) = %v, want the client of the flags", name, err)
+		}
+	}
+	b, err := profileClient("org-b")
+	if err != nil || b == client || b.BaseURL != fake.BaseURL() {
+		t.Fatalf("profileClient(org-b) = %+v, %v", b, err)
+	}
+	if again, _ := profileClient("org-b"); again != b {
+		t.Error("profileClient(org-b) is not cached")
+	}
+	if c, err := profileClient("org-c"); err != nil || c.BaseURL != webex.USGovBaseURL {
+		t.Errorf("profileClient(org-c) = %+v, %v, want %s", c, err, webex.USGovBaseURL)
+	}
+	if _, err := profileClient("org-d"); err == nil {
+		t.Error("profileClient(org-d) of an unknown profile = nil error")
+	}
+}
@@ -0,0 +1,469 @@
+// provision.go
+//
+// Room provisioning (command provision). A YAML file describes a room with
+// its moderation, members, welcome message and webhooks, and provision
+// creates what is missing:
+//
+//	team: KMP-Team
+//	room: Project Apollo
+//	locked: true
+//	moderators: [lead@example.com]
+//	members: [alice@example.com, bob@example.com]
+//	welcome: |
+//	  **Welcome to Project Apollo** :rocket:
+//	  Docs: https://wiki.example.com/apollo
+//	webhooks:
+//	  - name: apollo messages
+//	    resource: messages
+//	    targetUrl: https://relay.example.com/webhook
+//
+//	notify_by_webex_teams provision -f space.yaml
+//
+// provision is idempotent: an existing room is locked or unlocked as
+// described, missing members are added and the moderator role of the
+// members is set, the welcome message is posted unless the bot already sent
+// it to the room and webhooks are created unless the bot has one with the
+// same name, resource, event, filter and target URL. Members not listed stay
+// in the room unless --prune is given. The webhooks default to the event
+// created and the filter of the room. Webex has no API to pin messages, so
+// the welcome message is posted as the first message of a new room.
+// --dry-run prints the changes without making them.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+var (
+	provisionKeys        = []string{"team", "room", "locked", "announcement", "moderators", "members", "welcome", "welcomeFile", "webhooks"}
+	provisionWebhookKeys = []string{"name", "resource", "event", "filter", "targetUrl", "secret"}
+)
+
+// space is the room of a provisioning file.
+type space struct {
+	team, room           string
+	locked, announcement bool
+	moderators, members  []string
+	welcome              string
+	webhooks             []*webex.Webhook
+}
+
+// readSpace reads the provisioning file.
+func readSpace(file string) (*space, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	m, ok := doc.(yamlMapping)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a mapping with the key room", file)
+	}
+	if err := checkYAMLKeys(file, m, provisionKeys); err != nil {
+		return nil, err
+	}
+	return parseSpace(file, m, filepath.Dir(file), &space{team: teamName, room: roomName})
+}
+
+// parseSpace parses the room of the mapping m of a provisioning file, with
+// the defaults of s. name is the name of m in errors, relative files are
+// relative to dir.
+func parseSpace(name string, m yamlMapping, dir string, s *space) (*space, error) {
+	for _, p := range m {
+		switch p.Key {
+		case "team":
+			s.team = yamlString(p.Value)
+		case "room":
+			s.room = yamlString(p.Value)
+		case "locked", "announcement":
+			b, ok := p.Value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s is not true or false", name, p.Key)
+			}
+			if p.Key == "locked" {
+				s.locked = b
+			} else {
+				s.announcement = b
+			}
+		case "moderators", "members":
+			list, err := yamlStrings(p.Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %w", name, p.Key, err)
+			}
+			if p.Key == "moderators" {
+				s.moderators = list
+			} else {
+				s.members = list
+			}
+		case "welcome":
+			s.welcome = yamlString(p.Value)
+		case "welcomeFile":
+			file := yamlString(p.Value)
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(dir, file)
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			s.welcome = string(data)
+		case "webhooks":
+			items, ok := p.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: webhooks is not a list", name)
+			}
+			for i, item := range items {
+				w, err := readSpaceWebhook(fmt.Sprintf("%s: webhook %d", name, i+1), item)
+				if err != nil {
+					return nil, err
+				}
+				s.webhooks = append(s.webhooks, w)
+			}
+		}
+	}
+	if _, ok := m.Get("welcome"); ok {
+		if _, ok := m.Get("welcomeFile"); ok {
+			return nil, fmt.Errorf("%s: use welcome or welcomeFile", name)
+		}
+	}
+	if len(s.room) == 0 {
+		return nil, fmt.Errorf("%s: no room. set room or use flag -r", name)
+	}
+	if s.announcement {
+		s.locked = true
+	}
+	s.welcome = strings.TrimSpace(s.welcome)
+	return s, nil
+}
+
+// readSpaceWebhook reads a webhook of the provisioning file.
+func readSpaceWebhook(name string, item interface{}) (*webex.Webhook, error) {
+	m, ok := item.(yamlMapping)
+	if !ok {
+		return nil, fmt.Errorf("%s: not a mapping", name)
+	}
+	if err := checkYAMLKeys(name, m, provisionWebhookKeys); err != nil {
+		return nil, err
+	}
+	w := &webex.Webhook{Event: "created"}
+	for _, p := range m {
+		value := yamlString(p.Value)
+		switch p.Key {
+		case "name":
+			w.Name = value
+		case "resource":
+			w.Resource = value
+		case "event":
+			w.Event = value
+		case "filter":
+			w.Filter = value
+		case "targetUrl":
+			w.TargetURL = value
+		case "secret":
+			w.Secret = value
+			addSecret(value)
+		}
+	}
+	if len(w.Name) == 0 || len(w.Resource) == 0 || len(w.TargetURL) == 0 {
+		return nil, fmt.Errorf("%s: set name, resource and targetUrl", name)
+	}
+	return w, nil
+}
+
+func cmdProvision(action string, args []string) error {
+	files := append(append([]string{}, uploadFiles...), args...)
+	if len(files) != 1 {
+		return errors.New("use provision -f <file> with one provisioning file")
+	}
+	s, err := readSpace(files[0])
+	if err != nil {
+		return err
+	}
+	r, err := newReconciler(dryRun, pruneMembers)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	teamID, err := r.team(ctx, s.team, false)
+	if err != nil {
+		return err
+	}
+	return r.space(ctx, teamID, s)
+}
+
+// reconciler makes the rooms of the provisioning and apply files, or with
+// --dry-run prints what it would change.
+type reconciler struct {
+	client *webex.Client
+	dryRun bool
+	// prune removes the members which are not listed
+	prune bool
+	// changes made or, with dryRun, pending
+	changes int
+
+	me    *webex.Person
+	hooks []webex.Webhook
+}
+
+// newReconciler returns a reconciler which only prints the changes if
+// dryRun is set and removes unlisted members if prune is set.
+func newReconciler(dryRun, prune bool) (*reconciler, error) {
+	client, err := webexClient()
+	if err != nil {
+		return nil, err
+	}
+	return &reconciler{client: client, dryRun: dryRun, prune: prune}, nil
+}
+
+// change prints the change desc and makes it with do unless dryRun.
+func (r *reconciler) change(desc string, do func() error) error {
+	r.changes++
+	if r.dryRun {
+		fmt.Println("would " + desc)
+		return nil
+	}
+	if err := do(); err != nil {
+		return fmt.Errorf("%s: %w", desc, err)
+	}
+	fmt.Println(desc)
+	return nil
+}
+
+// team returns the ID of the team with the given name, empty if name is
+// empty. A missing team is created if create is set, with dryRun its ID is
+// empty.
+func (r *reconciler) team(ctx context.Context, name string, create bool) (string, error) {
+	if len(name) == 0 {
+		return "", nil
+	}
+	teams, err := r.client.ListTeams(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, t := range teams {
+		if t.Name == name {
+			return t.ID, nil
+		}
+	}
+	if !create {
+		return "", fmt.Errorf("team %q not found", name)
+	}
+	var teamID string
+	err = r.change(fmt.Sprintf("create team %q", name), func() error {
+		t, err := r.client.CreateTeam(ctx, name)
+		if err == nil {
+			teamID = t.ID
+		}
+		return err
+	})
+	return teamID, err
+}
+
+// space makes the room of s in the team of teamID. An empty teamID of a
+// space with team is a missing team of a dry run.
+func (r *reconciler) space(ctx context.Context, teamID string, s *space) error {
+	var room *webex.Room
+	if len(teamID) > 0 || len(s.team) == 0 {
+		rooms, err := r.client.ListRooms(ctx, teamID, "group")
+		if err != nil {
+			return err
+		}
+		for i := range rooms {
+			if rooms[i].Title == s.room {
+				room = &rooms[i]
+			}
+		}
+	}
+	created := room == nil
+	if created {
+		err := r.change(fmt.Sprintf("create room %q (locked: %t, announcement only: %t)", s.room, s.locked, s.announcement), func() error {
+			var err error
+			room, err = r.client.CreateRoomWith(ctx, &webex.RoomRequest{
+				TeamID:             teamID,
+				Title:              s.room,
+				IsLocked:           s.locked,
+				IsAnnouncementOnly: s.announcement,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	} else if room.IsLocked != s.locked || room.IsAnnouncementOnly != s.announcement {
+		err := r.change(fmt.Sprintf("lock room %q (locked: %t, announcement only: %t)", s.room, s.locked, s.announcement), func() error {
+			_, err := r.client.LockRoom(ctx, room.ID, s.locked, s.announcement)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// the room of a dry run does not exist
+	roomID := ""
+	if room != nil {
+		roomID = room.ID
+	}
+
+	if err := r.members(ctx, roomID, s); err != nil {
+		return err
+	}
+
+	if len(s.welcome) > 0 {
+		welcome := s.welcome
+		if !noEmoji {
+			welcome = expandEmoji(welcome)
+		}
+		posted := false
+		if !created {
+			me, err := r.self(ctx)
+			if err != nil {
+				return err
+			}
+			if posted, err = sentBy(ctx, r.client, me.ID, roomID, welcome); err != nil {
+				return err
+			}
+		}
+		if !posted {
+			err := r.change(fmt.Sprintf("post the welcome message to room %q", s.room), func() error {
+				_, err := r.client.CreateMessage(ctx, &webex.MessageRequest{RoomID: roomID, Markdown: welcome})
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(s.webhooks) > 0 && r.hooks == nil {
+		hooks, err := r.client.ListWebhooks(ctx)
+		if err != nil {
+			return err
+		}
+		r.hooks = append([]webex.Webhook{}, hooks...)
+	}
+	for _, w := range s.webhooks {
+		w := *w
+		if len(w.Filter) == 0 {
+			w.Filter = "roomId=" + roomID
+		}
+		if len(roomID) > 0 && hasWebhook(r.hooks, &w) {
+			continue
+		}
+		err := r.change(fmt.Sprintf("create webhook %q of room %q", w.Name, s.room), func() error {
+			_, err := r.client.CreateWebhook(ctx, &w)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// members makes the members of the room of s. roomID is empty for a missing
+// room of a dry run.
+func (r *reconciler) members(ctx context.Context, roomID string, s *space) error {
+	var members []webex.Membership
+	if len(roomID) > 0 {
+		var err error
+		if members, err = r.client.ListMemberships(ctx, roomID); err != nil {
+			return err
+		}
+	}
+	moderators := make(map[string]bool)
+	for _, email := range s.moderators {
+		moderators[strings.ToLower(email)] = true
+	}
+	listed := make(map[string]bool)
+	for _, email := range append(append([]string{}, s.moderators...), s.members...) {
+		if listed[strings.ToLower(email)] {
+			continue
+		}
+		listed[strings.ToLower(email)] = true
+		moderator := moderators[strings.ToLower(email)]
+		m := findMembership(members, email)
+		var err error
+		switch {
+		case m == nil:
+			err = r.change(fmt.Sprintf("add %s to room %q (moderator: %t)", email, s.room, moderator), func() error {
+				_, err := r.client.CreateMembership(ctx, roomID, email, moderator)
+				return err
+			})
+		case m.IsModerator != moderator:
+			err = r.change(fmt.Sprintf("update %s in room %q (moderator: %t)", email, s.room, moderator), func() error {
+				_, err := r.client.UpdateMembership(ctx, m.ID, moderator)
+				return err
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// a room without list of members keeps its members
+	if !r.prune || len(listed) == 0 {
+		return nil
+	}
+	me, err := r.self(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if listed[strings.ToLower(m.PersonEmail)] || m.PersonID == me.ID {
+			continue
+		}
+		id := m.ID
+		err := r.change(fmt.Sprintf("remove %s from room %q", m.PersonEmail, s.room), func() error {
+			return r.client.DeleteMembership(ctx, id)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// self returns the bot or user of the token.
+func (r *reconciler) self(ctx context.Context) (*webex.Person, error) {
+	if r.me == nil {
+		me, err := r.client.Me(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r.me = me
+	}
+	return r.me, nil
+}
+
+// sentBy reports whether the person sent the message with the given
+// markdown to the room.
+func sentBy(ctx context.Context, client *webex.Client, personID, roomID, markdown string) (bool, error) {
+	found := false
+	err := client.ListMessages(ctx, roomID, time.Time{}, func(m *webex.Message) bool {
+		found = m.PersonID == personID && strings.TrimSpace(m.Markdown) == markdown
+		return !found
+	})
+	return found, err
+}
+
+// hasWebhook reports whether hooks contain w.
+func hasWebhook(hooks []webex.Webhook, w *webex.Webhook) bool {
+	for _, h := range hooks {
+		if h.Name == w.Name && h.Resource == w.Resource && h.Event == w.Event && h.Filter == w.Filter && h.TargetURL == w.TargetURL {
+			return true
+		}
+	}
+	return false
+}
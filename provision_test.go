@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestReadSpace(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "welcome.md"), []byte("**Welcome**\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		yaml    string
+		want    space
+		wantErr string
+	}{
+		{yaml: "team: KMP-Team\nroom: Apollo\nannouncement: true\nmoderators: [lead@example.com]\nmembers: [alice@example.com]\nwelcomeFile: welcome.md\n",
+			want: space{team: "KMP-Team", room: "Apollo", locked: true, announcement: true, moderators: []string{"lead@example.com"},
+				members: []string{"alice@example.com"}, welcome: "**Welcome**"}},
+		{yaml: "room: Apollo\nlocked: yes please\n", wantErr: "locked is not true or false"},
+		{yaml: "room: Apollo\nwelcome: hi\nwelcomeFile: welcome.md\n", wantErr: "use welcome or welcomeFile"},
+		{yaml: "team: KMP-Team\nroom: \"\"\n", wantErr: "no room"},
+		{yaml: "room: Apollo\nowner: lead@example.com\n", wantErr: "owner"},
+		{yaml: "room: Apollo\nwebhooks:\n  - name: hook\n    resource: messages\n", wantErr: "set name, resource and targetUrl"},
+		{yaml: "- room: Apollo\n", wantErr: "not a mapping"},
+	} {
+		file := filepath.Join(dir, "space.yaml")
+		if err := os.WriteFile(file, []byte(tt.yaml), 0600); err != nil {
+			t.Fatal(err)
+		}
+		s, err := readSpace(file)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readSpace(%q) error = %v, want %s", tt.yaml, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("readSpace(%q): %v", tt.yaml, err)
+			continue
+		}
+		if s.team != tt.want.team || s.room != tt.want.room || s.locked != tt.want.locked || s.announcement != tt.want.announcement ||
+			strings.Join(s.moderators, ",") != strings.Join(tt.want.moderators, ",") || strings.Join(s.members, ",") != strings.Join(tt.want.members, ",") ||
+			s.welcome != tt.want.welcome {
+			t.Errorf("readSpace(%q) = %+v, want %+v", tt.yaml, s, tt.want)
+		}
+	}
+}
+
+func TestReconcilerDryRun(t *testing.T) {
+	fake := useFakeWebex(t)
+	teamID := fake.AddTeam("KMP-Team")
+	s := &space{team: "KMP-Team", room: "Apollo", moderators: []string{"lead@example.com"}, members: []string{"alice@example.com"}}
+	ctx := context.Background()
+
+	members := func(roomID string) string {
+		var emails []string
+		for _, m := range fake.Memberships() {
+			if m.RoomID == roomID {
+				emails = append(emails, m.PersonEmail)
+			}
+		}
+		sort.Strings(emails)
+		return strings.Join(emails, ",")
+	}
+	run := func(dryRun, prune bool) int {
+		t.Helper()
+		r, err := newReconciler(dryRun, prune)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.space(ctx, teamID, s); err != nil {
+			t.Fatal(err)
+		}
+		return r.changes
+	}
+
+	rooms := len(fake.Rooms())
+	if changes := run(true, false); changes != 3 || len(fake.Rooms()) != rooms {
+		t.Fatalf("dry run: %d changes, %d rooms, want 3 changes and no new room", changes, len(fake.Rooms())-rooms)
+	}
+	if changes := run(false, false); changes != 3 {
+		t.Fatalf("first run: %d changes, want 3", changes)
+	}
+	var roomID string
+	for _, r := range fake.Rooms() {
+		if r.Title == "Apollo" {
+			roomID = r.ID
+		}
+	}
+	if got := members(roomID); got != "alice@example.com,lead@example.com" {
+		t.Fatalf("members after the first run = %s", got)
+	}
+	if changes := run(false, false); changes != 0 {
+		t.Errorf("second run: %d changes, want 0", changes)
+	}
+
+}
@@ -0,0 +1,75 @@
+// purge.go
+//
+// Purge mode of the delete command (flag --purge). Deletes the messages the
+// bot itself sent to a room which are older than --older-than and/or
+// contain the --match text, e.g. to clean up auto-notifications from cron.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func runPurge() error {
+	if len(roomName) == 0 {
+		return errors.New("no room. use flag -r")
+	}
+	if len(olderThan) == 0 && len(searchMatch) == 0 {
+		return errors.New("purge needs flag --older-than and/or flag --match")
+	}
+	before, err := parseSince(olderThan, time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	me, err := client.Me(ctx)
+	if err != nil {
+		return err
+	}
+	room, err := findRoom(ctx, teamName, roomName)
+	if err != nil {
+		return err
+	}
+
+	var purge []*webex.Message
+	match := strings.ToLower(searchMatch)
+	err = client.ListMessages(ctx, room.ID, before, func(m *webex.Message) bool {
+		if m.PersonID != me.ID {
+			return true
+		}
+		if len(match) > 0 && !strings.Contains(strings.ToLower(m.Text), match) && !strings.Contains(strings.ToLower(m.Markdown), match) {
+			return true
+		}
+		purge = append(purge, m)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, m := range purge {
+		if dryRun {
+			fmt.Printf("%s\t%s\t%s\n", m.ID, m.Created.Local().Format("2006-01-02 15:04"), shorten(m.Text, maxSearchTextLen))
+			continue
+		}
+		err = client.DeleteMessage(ctx, m.ID)
+		if err != nil {
+			return fmt.Errorf("deleting message %s failed: %w", m.ID, err)
+		}
+	}
+	slog.Info("messages purged", "room", room.Title, "count", len(purge), "dryRun", dryRun)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex/webextest"
+)
+
+func TestRunPurge(t *testing.T) {
+	fake := useFakeWebex(t)
+	room := fake.AddRoom("Cron", "", "group")
+	now := time.Now()
+	oldCron := fake.AddMessage(room.ID, webextest.BotEmail, "cron: backup done", now.Add(-10*24*time.Hour))
+	oldOther := fake.AddMessage(room.ID, webextest.BotEmail, "deployment done", now.Add(-9*24*time.Hour))
+	newCron := fake.AddMessage(room.ID, webextest.BotEmail, "**CRON**: backup done", now.Add(-time.Hour))
+	human := fake.AddMessage(room.ID, "alice@example.com", "cron: thanks", now.Add(-10*24*time.Hour))
+	defer func(room, older, match string, dry bool) {
+		roomName, olderThan, searchMatch, dryRun = room, older, match, dry
+	}(roomName, olderThan, searchMatch, dryRun)
+	remaining := func() []string {
+		var ids []string
+		for _, m := range fake.Messages() {
+			ids = append(ids, m.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	ids := func(ids ...string) string {
+		sort.Strings(ids)
+		return strings.Join(ids, " ")
+	}
+
+	for _, tt := range []struct {
+		name, older, match string
+		dry                bool
+		want               string
+		wantErr            string
+	}{
+		{name: "no filter", wantErr: "purge needs flag --older-than and/or flag --match"},
+		{name: "invalid time", older: "a week", wantErr: `invalid time "a week"`},
+		{name: "dry run", older: "7d", dry: true, want: ids(oldCron.ID, oldOther.ID, newCron.ID, human.ID)},
+		{name: "old and matching", older: "7d", match: "cron", want: ids(oldOther.ID, newCron.ID, human.ID)},
+		{name: "matching", match: "cron", want: ids(oldOther.ID, human.ID)},
+		{name: "old", older: "7d", want: ids(human.ID)},
+	} {
+		roomName, olderThan, searchMatch, dryRun = "Cron", tt.older, tt.match, tt.dry
+		var err error
+		out := captureStdout(t, func() { err = runPurge() })
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("%s: runPurge() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if got := strings.Join(remaining(), " "); err != nil || got != tt.want {
+			t.Errorf("%s: runPurge() = %v, remaining %s, want %s", tt.name, err, got, tt.want)
+		}
+		if tt.dry && (!strings.Contains(out, oldCron.ID+"\t") || !strings.Contains(out, oldOther.ID+"\t") || strings.Count(out, "\n") != 2) {
+			t.Errorf("%s: output = %q, want the old messages of the bot", tt.name, out)
+		}
+	}
+}
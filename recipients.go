@@ -0,0 +1,200 @@
+// recipients.go
+//
+// Mail merge (flag --recipients). Every row of a CSV file is a recipient
+// with its own template variables, e.g. for maintenance notices to many
+// project rooms:
+//
+//	team,room,project,window
+//	KMP-Team,INM18/00021,Billing,Sat 22:00-23:00
+//	KMP-Team,INM18/00042,Shop,Sun 06:00-07:00
+//
+// The columns team, room and email select the recipient, all other columns
+// are available as .Vars in the message (-m, -i) or the template of
+// --template, e.g. "Maintenance of {{.Vars.project}}: {{.Vars.window}}".
+// An empty room column takes -r. Files saved by Excel with ";" as separator
+// and a byte order mark are accepted.
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recipient is a row of the recipients file.
+type recipient struct {
+	line              int
+	team, room, email string
+	vars              map[string]string
+}
+
+func readRecipients(file string) ([]*recipient, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	r := csv.NewReader(strings.NewReader(text))
+	r.TrimLeadingSpace = true
+	header := text
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		header = text[:i]
+	}
+	if strings.Contains(header, ";") && !strings.Contains(header, ",") {
+		r.Comma = ';'
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%s: no recipients. the first row names the columns", file)
+	}
+
+	columns := records[0]
+	hasRecipient := false
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+		switch strings.ToLower(columns[i]) {
+		case "team", "email":
+			hasRecipient = true
+		}
+	}
+	if !hasRecipient {
+		return nil, fmt.Errorf("%s: no column team or email", file)
+	}
+
+	var rows []*recipient
+	for i, record := range records[1:] {
+		row := &recipient{line: i + 2, vars: make(map[string]string)}
+		for j, value := range record {
+			switch strings.ToLower(columns[j]) {
+			case "team":
+				row.team = value
+			case "room":
+				row.room = value
+			case "email":
+				row.email = value
+			default:
+				row.vars[columns[j]] = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// recipientName returns the recipient of n for the output.
+func recipientName(n *notification) string {
+	if len(n.Email) > 0 {
+		return n.Email
+	}
+	return n.TeamName + " / " + n.RoomName
+}
+
+// sendToRecipients sends base rendered with the variables of every row of
+// the recipients file to the recipient of the row.
+func sendToRecipients(base *notification, file string) error {
+	if len(sendAtString) > 0 || sendDelay > 0 {
+		return errors.New("--at and --delay are not supported with --recipients")
+	}
+	if mentionAll {
+		return errors.New("--mention-all is not supported with --recipients")
+	}
+	if len(previewHTML) > 0 {
+		return errors.New("--preview-html is not supported with --recipients. use --preview or --dry-run")
+	}
+	rows, err := readRecipients(file)
+	if err != nil {
+		return err
+	}
+	vars, err := parseVars(templateVars)
+	if err != nil {
+		return err
+	}
+
+	b := newBatch("recipients", "recipients", "LINE")
+	for _, row := range rows {
+		n, err := renderRecipient(base, row, vars)
+		target := recipientName(&notification{TeamName: row.team, RoomName: row.room, Email: row.email})
+		if err == nil {
+			target = recipientName(n)
+			err = sendRecipient(n, row.line)
+		}
+		if err != nil {
+			slog.Error("sending to recipient failed", "line", row.line, "error", err)
+		}
+		b.add(strconv.Itoa(row.line), target, err)
+	}
+	if showPreview || dryRun {
+		return nil
+	}
+	return b.finish()
+}
+
+// renderRecipient returns the notification of a row. The variables of the
+// row take precedence over the variables of --var.
+func renderRecipient(base *notification, row *recipient, vars map[string]string) (*notification, error) {
+	n := *base
+	n.TeamName, n.RoomName, n.Email = row.team, row.room, row.email
+	n.Vars = make(map[string]string)
+	for k, v := range vars {
+		n.Vars[k] = v
+	}
+	for k, v := range row.vars {
+		n.Vars[k] = v
+	}
+
+	if len(templateName) > 0 {
+		n.Template = templateName
+		if err := applyTemplate(&n); err != nil {
+			return nil, err
+		}
+	} else {
+		hostname, _ := os.Hostname()
+		var err error
+		n.Markdown, err = renderTemplate("message", base.Markdown, &templateData{Vars: n.Vars, Time: time.Now(), Hostname: hostname})
+		if err != nil {
+			return nil, err
+		}
+		n.Vars = nil
+	}
+	if len(n.TeamName) > 0 && len(n.RoomName) == 0 {
+		n.RoomName = roomName
+	}
+	if len(n.TeamName) == 0 && len(n.Email) == 0 {
+		return nil, errors.New("no recipient. set the column team or email")
+	}
+	if err := attachLocalImages(&n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func sendRecipient(n *notification, line int) error {
+	if err := applyFooter(n); err != nil {
+		return fmt.Errorf("--footer: %w", err)
+	}
+	switch {
+	case dryRun:
+		fmt.Printf("--- line %d: %s\n%s\n", line, recipientName(n), strings.TrimRight(n.Markdown, "\n"))
+		return nil
+	case showPreview:
+		fmt.Printf("--- line %d: %s\n", line, recipientName(n))
+		if err := applySeverity(n); err != nil {
+			return err
+		}
+		if !noEmoji {
+			n.Markdown = expandEmoji(n.Markdown)
+		}
+		return previewMessage(n.Markdown, n.Card, "")
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	return sendOrSpool(ctx, n)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadRecipients(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name, csv string
+		want      []string
+		wantErr   string
+	}{
+		{name: "comma", csv: "team,room,project,window\nKMP-Team,INM18/00021,Billing,Sat 22:00-23:00\nKMP-Team, INM18/00042,Shop,\"Sun 06:00, 07:00\"\n",
+			want: []string{"2 KMP-Team/INM18/00021 map[project:Billing window:Sat 22:00-23:00]", "3 KMP-Team/INM18/00042 map[project:Shop window:Sun 06:00, 07:00]"}},
+		{name: "excel", csv: "\ufeffEmail;Name\r\nalice@example.com;Alice\r\n", want: []string{"2 alice@example.com map[Name:Alice]"}},
+		{name: "no rows", csv: "team,room\n", wantErr: "no recipients"},
+		{name: "no recipient column", csv: "room,project\nAlerts,Billing\n", wantErr: "no column team or email"},
+		{name: "columns", csv: "team,room\nKMP-Team,Alerts,extra\n", wantErr: "wrong number of fields"},
+	} {
+		file := filepath.Join(dir, tt.name+".csv")
+		if err := os.WriteFile(file, []byte(tt.csv), 0600); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := readRecipients(file)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: readRecipients() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		var got []string
+		for _, r := range rows {
+			target := r.email
+			if len(r.team) > 0 {
+				target = r.team + "/" + r.room
+			}
+			got = append(got, strings.Join([]string{fmt.Sprint(r.line), target, fmt.Sprint(r.vars)}, " "))
+		}
+		if err != nil || strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: readRecipients() = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSendToRecipients(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func() { roomName, templateVars = "", nil }()
+	roomName, templateVars = "Maintenance", stringList{"window=tonight"}
+
+	file := filepath.Join(t.TempDir(), "recipients.csv")
+	err := os.WriteFile(file, []byte("team,room,email,project\nKMP-Team,Billing,,Billing\nKMP-Team,,,Shop\n,,,Orphan\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := &notification{Markdown: "Maintenance of {{.Vars.project}} {{.Vars.window}}"}
+	err = sendToRecipients(base, file)
+	if err == nil || err.Error() != "1 of 3 recipients failed: line 4" {
+		t.Errorf("sendToRecipients() error = %v, want line 4 failed", err)
+	}
+
+	rooms := make(map[string]string)
+	for _, r := range fake.Rooms() {
+		rooms[r.ID] = r.Title
+	}
+	var got []string
+	for _, m := range fake.Messages() {
+		got = append(got, m.Markdown+" @"+rooms[m.RoomID])
+	}
+	want := "Maintenance of Billing tonight @Billing|Maintenance of Shop tonight @Maintenance"
+	if strings.Join(got, "|") != want {
+		t.Errorf("messages = %q, want %s", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+// retry.go
+//
+// Retries of the Webex API requests (flags --max-retries, --retry-initial-wait,
+// --retry-max-wait and --retry-on): interactive callers can fail fast, batch
+// jobs can wait longer for the API to recover:
+//
+//	notify_by_webex_teams --max-retries 0 -t KMP-Team -r Alerts -m "fail fast"
+//	notify_by_webex_teams --max-retries 6 --retry-max-wait 1m --retry-on 429,5xx --recipients users.csv -m "maintenance tonight"
+//
+// The wait before a retry is the time of the Retry-After header of the
+// response or else the initial wait, doubled for every further retry, and is
+// at most --retry-max-wait. Server errors should only be retried if a
+// duplicate message is acceptable, since the message may have been posted
+// before the error.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// retryStatus reports whether a response with the given status code is
+// retried, parsed from --retry-on.
+var retryStatus func(statusCode int) bool
+
+// parseRetryOn returns the function reporting whether a status code is one of
+// the comma separated status codes or classes, e.g. 429,5xx, of spec.
+func parseRetryOn(spec string) (func(statusCode int) bool, error) {
+	codes := make(map[int]bool)
+	classes := make(map[int]bool)
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if len(s) == 3 && strings.HasSuffix(s, "xx") && s[0] >= '4' && s[0] <= '5' {
+			classes[int(s[0]-'0')] = true
+			continue
+		}
+		code, err := strconv.Atoi(s)
+		if err != nil || code < 400 || code > 599 {
+			return nil, fmt.Errorf("invalid --retry-on %q. use status codes and classes >= 400, e.g. 429,5xx", s)
+		}
+		codes[code] = true
+	}
+	return func(statusCode int) bool {
+		return codes[statusCode] || classes[statusCode/100]
+	}, nil
+}
+
+// applyRetryFlags checks the retry flags and parses --retry-on.
+func applyRetryFlags() error {
+	if maxRetries < 0 || retryInitWait < 0 || retryMaxWait < 0 {
+		return errors.New("--max-retries, --retry-initial-wait and --retry-max-wait must not be negative")
+	}
+	var err error
+	retryStatus, err = parseRetryOn(retryOn)
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetryOn(t *testing.T) {
+	for _, tt := range []struct {
+		spec    string
+		retry   []int
+		noRetry []int
+		wantErr string
+	}{
+		{spec: "429", retry: []int{429}, noRetry: []int{500, 503, 404}},
+		{spec: "429, 5XX", retry: []int{429, 500, 502, 599}, noRetry: []int{404, 428}},
+		{spec: "4xx,503", retry: []int{400, 404, 429, 503}, noRetry: []int{500, 502}},
+		{spec: "200", wantErr: `invalid --retry-on "200". use status codes and classes >= 400, e.g. 429,5xx`},
+		{spec: "3xx", wantErr: `invalid --retry-on "3xx". use status codes and classes >= 400, e.g. 429,5xx`},
+		{spec: "429,", wantErr: `invalid --retry-on "". use status codes and classes >= 400, e.g. 429,5xx`},
+	} {
+		retry, err := parseRetryOn(tt.spec)
+		if errorString(err) != tt.wantErr {
+			t.Errorf("parseRetryOn(%q) error = %v, want %s", tt.spec, err, tt.wantErr)
+			continue
+		}
+		for _, code := range tt.retry {
+			if !retry(code) {
+				t.Errorf("parseRetryOn(%q)(%d) = false, want true", tt.spec, code)
+			}
+		}
+		for _, code := range tt.noRetry {
+			if retry(code) {
+				t.Errorf("parseRetryOn(%q)(%d) = true, want false", tt.spec, code)
+			}
+		}
+	}
+}
+
+func TestApplyRetryFlags(t *testing.T) {
+	defer func(retries int, initial, max time.Duration, on string, status func(int) bool) {
+		maxRetries, retryInitWait, retryMaxWait, retryOn, retryStatus = retries, initial, max, on, status
+	}(maxRetries, retryInitWait, retryMaxWait, retryOn, retryStatus)
+	for _, tt := range []struct {
+		retries      int
+		initial, max time.Duration
+		on           string
+		wantErr      string
+	}{
+		{3, time.Second, 0, "429,5xx", ""},
+		{0, 0, 0, "429", ""},
+		{-1, time.Second, 0, "429", "--max-retries, --retry-initial-wait and --retry-max-wait must not be negative"},
+		{3, -time.Second, 0, "429", "--max-retries, --retry-initial-wait and --retry-max-wait must not be negative"},
+		{3, time.Second, -time.Minute, "429", "--max-retries, --retry-initial-wait and --retry-max-wait must not be negative"},
+		{3, time.Second, 0, "too many", `invalid --retry-on "too many". use status codes and classes >= 400, e.g. 429,5xx`},
+	} {
+		maxRetries, retryInitWait, retryMaxWait, retryOn, retryStatus = tt.retries, tt.initial, tt.max, tt.on, nil
+		err := applyRetryFlags()
+		if errorString(err) != tt.wantErr || (err == nil) != (retryStatus != nil) {
+			t.Errorf("applyRetryFlags(%d, %s, %s, %q) error = %v, want %s", tt.retries, tt.initial, tt.max, tt.on, err, tt.wantErr)
+		}
+	}
+}
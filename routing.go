@@ -0,0 +1,123 @@
+// routing.go
+//
+// Routing rules of the config file (-c). A message without recipient, i.e.
+// without -t and -D, goes to the team room or person of the first rule
+// matching its severity, labels (--labels) and template, so the scripts only
+// say what happened and the config file decides where it goes:
+//
+//	{
+//	  "routes": [
+//	    { "severity": "critical", "labels": { "team": "db" }, "team": "DB-Team", "room": "DB Alerts" },
+//	    { "labels": { "team": "db" }, "team": "DB-Team", "room": "DB" },
+//	    { "template": "deploy-success", "team": "KMP-Team", "room": "Deployments" },
+//	    { "team": "KMP-Team", "room": "Notifications" }
+//	  ]
+//	}
+//
+//	notify_by_webex_teams -c /etc/notify/config.json -m "replication lag 5m" --severity critical --labels team=db,host=db-1
+//
+// A rule matches if all of its conditions match, a rule without conditions
+// matches every message. The values of the labels may contain the wildcards
+// of path.Match, e.g. "db-*". "profile" sends the message with another bot
+// of the config file (see profile.go). The routing applies to relayed
+// notifications ("labels") without teamName and email as well.
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// routeRule is a routing rule of the config file.
+type routeRule struct {
+	Severity string            `json:"severity"`
+	Labels   map[string]string `json:"labels"`
+	Template string            `json:"template"`
+	Team     string            `json:"team"`
+	Room     string            `json:"room"`
+	Email    string            `json:"email"`
+	Profile  string            `json:"profile"`
+}
+
+// check checks the rule, the i-th of the config file.
+func (r *routeRule) check(i int) error {
+	if len(r.Team) == 0 && len(r.Email) == 0 {
+		return fmt.Errorf("route %d: set team or email", i+1)
+	}
+	for key, value := range r.Labels {
+		if _, err := path.Match(value, ""); err != nil {
+			return fmt.Errorf("route %d: invalid value of label %s: %q", i+1, key, value)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the rule matches the notification of the given
+// template.
+func (r *routeRule) matches(n *notification, template string) bool {
+	if len(r.Severity) > 0 && !strings.EqualFold(r.Severity, n.Severity) {
+		return false
+	}
+	if len(r.Template) > 0 && r.Template != template {
+		return false
+	}
+	for key, pattern := range r.Labels {
+		value, ok := n.Labels[key]
+		if !ok {
+			return false
+		}
+		if matched, _ := path.Match(pattern, value); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// routeNotification sets the recipient of n without recipient by the
+// routing rules of the config file. template is the name of the message
+// template of n, if any.
+func routeNotification(n *notification, template string) error {
+	if len(n.TeamName) > 0 || len(n.Email) > 0 || len(configFile) == 0 {
+		return nil
+	}
+	c, err := loadConfig(configFile)
+	if err != nil {
+		return err
+	}
+	for i, r := range c.Routes {
+		if !r.matches(n, template) {
+			continue
+		}
+		n.TeamName, n.Email = r.Team, r.Email
+		if len(r.Room) > 0 {
+			n.RoomName = r.Room
+		}
+		if len(r.Profile) > 0 {
+			n.Profile = r.Profile
+		}
+		slog.Debug("message routed", "route", i+1, "team", n.TeamName, "room", n.RoomName, "email", n.Email, "profile", n.Profile)
+		return nil
+	}
+	return nil
+}
+
+// parseLabels parses the labels of --labels, key=value pairs separated by
+// commas.
+func parseLabels(list []string) (map[string]string, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	for _, s := range list {
+		for _, pair := range strings.Split(s, ",") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok || len(key) == 0 {
+				return nil, fmt.Errorf("invalid --labels %q. use key=value,key=value", s)
+			}
+			labels[key] = value
+		}
+	}
+	return labels, nil
+}
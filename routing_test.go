@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRouteNotification(t *testing.T) {
+	defer func(file string) { configFile = file }(configFile)
+	configFile = filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configFile, []byte(`{"profiles": {"deploy-bot": {"token": "deploy-token"}}, "routes": [
+		{"severity": "critical", "labels": {"team": "db"}, "team": "DB-Team", "room": "DB Alerts"},
+		{"labels": {"team": "db", "host": "db-*"}, "team": "DB-Team", "room": "DB"},
+		{"template": "deploy-success", "team": "KMP-Team", "room": "Deployments", "profile": "deploy-bot"},
+		{"severity": "info", "email": "ops@example.com"},
+		{"team": "KMP-Team", "room": "Notifications"}]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		n        notification
+		template string
+		want     string
+	}{
+		{notification{Severity: "CRITICAL", Labels: map[string]string{"team": "db", "host": "db-1"}}, "", "DB-Team/DB Alerts"},
+		{notification{Severity: "warning", Labels: map[string]string{"team": "db", "host": "db-1"}}, "", "DB-Team/DB"},
+		{notification{Labels: map[string]string{"team": "db", "host": "web-1"}}, "", "KMP-Team/Notifications"},
+		{notification{Labels: map[string]string{"team": "db"}}, "", "KMP-Team/Notifications"},
+		{notification{}, "deploy-success", "KMP-Team/Deployments deploy-bot"},
+		{notification{Severity: "info", RoomName: "Alerts"}, "", "/Alerts ops@example.com"},
+		{notification{TeamName: "Other", RoomName: "Alerts", Severity: "critical", Labels: map[string]string{"team": "db"}}, "", "Other/Alerts"},
+		{notification{Email: "alice@example.com"}, "", "/ alice@example.com"},
+	} {
+		n := tt.n
+		if err := routeNotification(&n, tt.template); err != nil {
+			t.Fatal(err)
+		}
+		got := n.TeamName + "/" + n.RoomName
+		if len(n.Email) > 0 || len(n.Profile) > 0 {
+			got += " " + n.Email + n.Profile
+		}
+		if got != tt.want {
+			t.Errorf("routeNotification(%+v, %q) = %s, want %s", tt.n, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestRouteRuleCheck(t *testing.T) {
+	for i, tt := range []struct {
+		r       routeRule
+		wantErr bool
+	}{
+		{routeRule{Team: "KMP-Team"}, false},
+		{routeRule{Email: "ops@example.com", Labels: map[string]string{"host": "db-*"}}, false},
+		{routeRule{Severity: "critical"}, true},
+		{routeRule{Team: "KMP-Team", Labels: map[string]string{"host": "db-["}}, true},
+	} {
+		if err := tt.r.check(i); tt.wantErr != (err != nil) {
+			t.Errorf("check() of %+v: error = %v", tt.r, err)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	for _, tt := range []struct {
+		list    []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "map[]", false},
+		{[]string{"team=db, host=db-1", "env="}, "map[env: host:db-1 team:db]", false},
+		{[]string{"url=https://x.example.com/?a=b"}, "map[url:https://x.example.com/?a=b]", false},
+		{[]string{"team"}, "", true},
+		{[]string{"=db"}, "", true},
+	} {
+		got, err := parseLabels(tt.list)
+		if tt.wantErr != (err != nil) || !tt.wantErr && fmt.Sprint(got) != tt.want {
+			t.Errorf("parseLabels(%q) = %v, %v, want %s", tt.list, got, err, tt.want)
+		}
+	}
+}
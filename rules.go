@@ -0,0 +1,167 @@
+// rules.go
+//
+// Rules of the webhook receivers of the relay (serve --jira, --pagerduty,
+// --sentry, --datadog, --alertmanager, --grafana) in the config file (-c).
+// Every event is matched against the fields of its source, the first
+// matching rule decides: the room or person, a message template, additional
+// @mentions or the suppression of the event. Events without matching rule
+// are posted as configured by the section of the source, the alerts of
+// Alertmanager and Grafana to -t/-r or -D.
+//
+//	{
+//	  "rules": [
+//	    { "source": "datadog", "match": { "tags.env": "staging" }, "suppress": true },
+//	    { "source": "sentry", "match": { "level": "fatal" }, "team": "KMP-Team", "room": "Shop Errors",
+//	      "mentions": ["oncall@example.com"] },
+//	    { "source": "jira", "match": { "project": "OPS", "priority": "highest" }, "template": "jira-urgent" },
+//	    { "source": "alertmanager", "match": { "labels.team": "payments" }, "team": "KMP-Team",
+//	      "room": "Payments" },
+//	    { "match": { "title": "*\\[test\\]*" }, "suppress": true }
+//	  ]
+//	}
+//
+// The values of match may contain the wildcards of path.Match and are
+// matched case-insensitively, \[ matches a bracket. A value starting with !
+// matches if the pattern does not match, a missing field is empty. A rule
+// without source matches the events of all sources. The fields are:
+//
+//	jira:      event (created, updated, moved, commented), project, issue, summary, type, status,
+//	           priority, assignee, user
+//	pagerduty: event (e.g. incident.triggered), service, status, urgency, priority, title
+//	sentry:    issue, title, culprit, level, project, environment, rule
+//	datadog:   title, transition, alert_type, priority, hostname, id, tags, tags.<key> of key:value tags
+//	alertmanager, grafana:
+//	           status (firing, resolved), receiver, alertname, severity, title, labels.<name> of the
+//	           common labels, annotations.<name> of the common annotations
+//
+// A template is rendered with the fields as .Vars and the text of the event
+// as .Message, and replaces the message and card of the event. PagerDuty
+// incidents keep their card, so templates do not apply to them.
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+)
+
+// known sources of the rules
+var ruleSources = []string{"jira", "pagerduty", "sentry", "datadog", "alertmanager", "grafana"}
+
+// eventRule is a rule of the webhook receivers.
+type eventRule struct {
+	Source   string            `json:"source"`
+	Match    map[string]string `json:"match"`
+	Team     string            `json:"team"`
+	Room     string            `json:"room"`
+	Email    string            `json:"email"`
+	Template string            `json:"template"`
+	Mentions []string          `json:"mentions"`
+	Suppress bool              `json:"suppress"`
+}
+
+// eventRules are the rules of the config file, loaded by serve.
+var eventRules []*eventRule
+
+// check checks the rule, the i-th of the config file.
+func (r *eventRule) check(i int) error {
+	known := len(r.Source) == 0
+	for _, s := range ruleSources {
+		known = known || r.Source == s
+	}
+	if !known {
+		return fmt.Errorf("rule %d: unknown source %q. use %s", i+1, r.Source, strings.Join(ruleSources, ", "))
+	}
+	for field, pattern := range r.Match {
+		if _, err := path.Match(strings.TrimPrefix(pattern, "!"), ""); err != nil {
+			return fmt.Errorf("rule %d: invalid pattern of %s: %q", i+1, field, pattern)
+		}
+	}
+	if r.Suppress && (len(r.Team) > 0 || len(r.Email) > 0 || len(r.Template) > 0 || len(r.Mentions) > 0) {
+		return fmt.Errorf("rule %d: a rule with suppress has no room, email, template or mentions", i+1)
+	}
+	return nil
+}
+
+// matches reports whether the rule matches the event of source with the
+// given fields.
+func (r *eventRule) matches(source string, fields map[string]string) bool {
+	if len(r.Source) > 0 && r.Source != source {
+		return false
+	}
+	for field, pattern := range r.Match {
+		pattern = strings.ToLower(pattern)
+		negate := strings.HasPrefix(pattern, "!")
+		matched, _ := path.Match(strings.TrimPrefix(pattern, "!"), strings.ToLower(fields[field]))
+		if matched == negate {
+			return false
+		}
+	}
+	return true
+}
+
+// route returns the recipient of the rule, nil if the rule has none.
+func (r *eventRule) route() *eventRoute {
+	if len(r.Team) == 0 && len(r.Email) == 0 {
+		return nil
+	}
+	route := &eventRoute{Team: r.Team, Room: r.Room, Email: r.Email}
+	if len(route.Team) > 0 && len(route.Room) == 0 {
+		route.Room = roomName
+	}
+	return route
+}
+
+// loadEventRules returns the rules of the config file.
+func loadEventRules() ([]*eventRule, error) {
+	if len(configFile) == 0 {
+		return nil, nil
+	}
+	c, err := loadConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+	return c.Rules, nil
+}
+
+// matchEventRule returns the first rule matching the event of source, nil
+// if none matches.
+func matchEventRule(source string, fields map[string]string) *eventRule {
+	for i, r := range eventRules {
+		if r.matches(source, fields) {
+			slog.Debug("event rule matched", "source", source, "rule", i+1)
+			return r
+		}
+	}
+	return nil
+}
+
+// applyEventRule applies the first rule matching the event of source to
+// its notification n. It returns false if the event is suppressed. If the
+// template of the rule fails, the error is returned and n is posted without
+// the template.
+func applyEventRule(source string, fields map[string]string, n *notification) (bool, error) {
+	r := matchEventRule(source, fields)
+	if r == nil {
+		return true, nil
+	}
+	if r.Suppress {
+		slog.Info("event suppressed by rule", "source", source)
+		return false, nil
+	}
+	if route := r.route(); route != nil {
+		n.TeamName, n.RoomName, n.Email = route.Team, route.Room, route.Email
+	}
+	// n.Mentions may be the slice of --mention
+	n.Mentions = append(append([]string{}, n.Mentions...), r.Mentions...)
+	if len(r.Template) > 0 {
+		t := *n
+		t.Template, t.Vars, t.Card = r.Template, fields, ""
+		if err := applyTemplate(&t); err != nil {
+			return true, fmt.Errorf("template %s of the rule: %w", r.Template, err)
+		}
+		*n = t
+	}
+	return true, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEventRuleMatches(t *testing.T) {
+	fields := map[string]string{"project": "OPS", "priority": "Highest", "title": "Disk full [test]", "tags.env": "prod"}
+	for _, tt := range []struct {
+		rule eventRule
+		want bool
+	}{
+		{eventRule{}, true},
+		{eventRule{Source: "jira"}, true},
+		{eventRule{Source: "sentry"}, false},
+		{eventRule{Match: map[string]string{"project": "ops", "priority": "highest"}}, true},
+		// [test] is a character class
+		{eventRule{Match: map[string]string{"title": "*[xyz]*"}}, false},
+		{eventRule{Match: map[string]string{"title": `*\[test\]*`}}, true},
+		{eventRule{Match: map[string]string{"tags.env": "!staging"}}, true},
+		{eventRule{Match: map[string]string{"tags.env": "!prod"}}, false},
+		{eventRule{Match: map[string]string{"assignee": ""}}, true},
+		{eventRule{Match: map[string]string{"assignee": "*?"}}, false},
+	} {
+		if got := tt.rule.matches("jira", fields); got != tt.want {
+			t.Errorf("matches() of rule %+v = %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestEventRuleCheck(t *testing.T) {
+	for _, tt := range []struct {
+		rule    eventRule
+		wantErr string
+	}{
+		{rule: eventRule{Source: "alertmanager", Match: map[string]string{"labels.team": "pay*"}, Team: "KMP-Team"}},
+		{rule: eventRule{Source: "grafana", Suppress: true}},
+		{rule: eventRule{Source: "nagios"}, wantErr: "unknown source"},
+		{rule: eventRule{Match: map[string]string{"title": "[a-"}}, wantErr: "invalid pattern"},
+		{rule: eventRule{Suppress: true, Team: "KMP-Team"}, wantErr: "suppress"},
+	} {
+		err := tt.rule.check(0)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("check() of rule %+v error = %v, want %s", tt.rule, err, tt.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("check() of rule %+v: %v", tt.rule, err)
+		}
+	}
+}
+
+func TestApplyEventRule(t *testing.T) {
+	defer func() { eventRules = nil }()
+	eventRules = []*eventRule{
+		{Source: "sentry", Match: map[string]string{"level": "debug"}, Suppress: true},
+		{Source: "sentry", Match: map[string]string{"level": "fatal"}, Team: "KMP-Team", Room: "Errors", Mentions: []string{"oncall@example.com"}},
+	}
+	mentions := []string{"ops@example.com"}
+	n := &notification{TeamName: "KMP-Team", RoomName: "Alerts", Mentions: mentions}
+	if post, err := applyEventRule("sentry", map[string]string{"level": "fatal"}, n); !post || err != nil {
+		t.Fatalf("applyEventRule(fatal) = %v, %v", post, err)
+	}
+	if n.RoomName != "Errors" || strings.Join(n.Mentions, ",") != "ops@example.com,oncall@example.com" || len(mentions) != 1 {
+		t.Errorf("applyEventRule(fatal): room %q, mentions %q", n.RoomName, n.Mentions)
+	}
+	if post, _ := applyEventRule("sentry", map[string]string{"level": "debug"}, &notification{}); post {
+		t.Error("applyEventRule(debug) = true, want suppressed")
+	}
+}
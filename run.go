@@ -0,0 +1,193 @@
+// run.go
+//
+// Command wrapper (command run). "run -- <command> [<args>]" runs the
+// command, passes its output through and sends a message with the exit
+// status, the duration and, on failure, the end of the output, e.g. for
+// cron jobs and backups instead of "cmd && notify ... || notify ...":
+//
+//	notify_by_webex_teams run -t KMP-Team -r Backups --only-failure -- restic backup /srv
+//
+// The message of -m or -i is a Go text/template with the result as .Run
+// (.Run.Command, .Run.Success, .Run.ExitCode, .Run.Duration, .Run.Output).
+// Without -m a default message is sent. A failure has severity critical
+// unless --severity is given. run exits with the exit code of the command.
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// output of the command kept for .Run.Output
+	runOutputMax = 64 << 10
+	// end of the output in the default failure message
+	runOutputTail = 2000
+)
+
+// runResult is the result of the command of run.
+type runResult struct {
+	Command  string
+	Success  bool
+	ExitCode int
+	Duration time.Duration
+	Output   string
+	// Error is set if the command could not be started or was killed
+	Error string
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.ToValidUTF8(string(b.buf), "")
+}
+
+func cmdRun(action string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("no command. use run [flags] -- <command> [<args>]")
+	}
+	if useStdIn {
+		return errors.New("-i is not supported with run, standard input is passed to the command")
+	}
+	result := runWrapped(args)
+	if result.Success && onlyFailure {
+		return nil
+	}
+
+	var err error
+	markdownMsg, err = runMessage(result, markdownMsg)
+	if err != nil {
+		return err
+	}
+	if !result.Success && len(severityName) == 0 {
+		severityName = "critical"
+	}
+	if ciCard {
+		runDuration = result.Duration
+		if len(ciStatus) == 0 {
+			ciStatus = map[bool]string{true: "success", false: "failure"}[result.Success]
+		}
+	}
+	err = cmdSend("", nil)
+	if !result.Success {
+		if err != nil {
+			slog.Error(err.Error())
+		}
+		// the exit code of the command, not of sending the message
+		os.Exit(max(result.ExitCode, 1))
+	}
+	return err
+}
+
+// runWrapped runs the command args, passing its input and output through.
+// SIGINT and SIGTERM are passed on to the command, so its end is reported.
+func runWrapped(args []string) *runResult {
+	result := &runResult{Command: strings.Join(args, " ")}
+	output := &tailBuffer{max: runOutputMax}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, output)
+
+	start := time.Now()
+	err := cmd.Start()
+	if err != nil {
+		result.ExitCode = 127
+		result.Error = err.Error()
+		result.Output = err.Error()
+		slog.Error("starting the command failed", "command", result.Command, "error", err)
+		return result
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err = cmd.Wait()
+	result.Duration = time.Since(start).Round(time.Millisecond)
+	result.Output = output.String()
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	result.Success = err == nil
+	if result.ExitCode < 0 {
+		// killed by a signal
+		result.Error = cmd.ProcessState.String()
+	}
+	signal.Stop(signals)
+	close(signals)
+	return result
+}
+
+// runMessage renders the message text, a template with the result, or
+// returns the default message.
+func runMessage(result *runResult, text string) (string, error) {
+	hostname, _ := os.Hostname()
+	if len(text) > 0 {
+		return renderTemplate("message", text, &templateData{Vars: map[string]string{}, Time: time.Now(), Hostname: hostname, Run: result})
+	}
+
+	command := result.Command
+	if len(command) > 200 {
+		command = command[:197] + "..."
+	}
+	fence := strings.Repeat("`", longestRun(command, '`')+1)
+	command = fence + " " + command + " " + fence
+	if result.Success {
+		return trf("✅ %s succeeded on **%s** in %s", command, hostname, result.Duration), nil
+	}
+
+	var status string
+	switch {
+	case result.ExitCode < 0:
+		status = trf("was terminated (%s)", result.Error)
+	case len(result.Error) > 0:
+		status = tr("could not be started")
+	default:
+		status = trf("failed with exit code %d", result.ExitCode)
+	}
+	msg := trf("%s %s on **%s** after %s", command, status, hostname, result.Duration)
+	if output := strings.TrimSpace(result.Output); len(output) > 0 {
+		block, _ := codeBlock(outputTail(output, runOutputTail), "", runOutputTail+100)
+		msg += "\n" + block
+	}
+	return msg, nil
+}
+
+// outputTail returns the last lines of output within limit bytes.
+func outputTail(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+	tail := output[len(output)-limit:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+	return "…\n" + strings.ToValidUTF8(tail, "")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 8}
+	for _, s := range []string{"abc", "defgh", "ij"} {
+		if n, err := b.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", s, n, err)
+		}
+	}
+	if got := b.String(); got != "cdefghij" {
+		t.Errorf("String() = %q, want cdefghij", got)
+	}
+	// a multi-byte character cut at the start is dropped
+	b = &tailBuffer{max: 4}
+	b.Write([]byte("xäöü"))
+	if got := b.String(); got != "öü" {
+		t.Errorf("String() = %q, want öü", got)
+	}
+}
+
+func TestOutputTail(t *testing.T) {
+	for _, tt := range []struct {
+		output string
+		limit  int
+		want   string
+	}{
+		{"line 1\nline 2\n", 100, "line 1\nline 2\n"},
+		{"line 1\nline 2\nline 3", 12, "…\nline 3"},
+		{"0123456789", 4, "…\n6789"},
+	} {
+		if got := outputTail(tt.output, tt.limit); got != tt.want {
+			t.Errorf("outputTail(%q, %d) = %q, want %q", tt.output, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestRunMessage(t *testing.T) {
+	hostname, _ := os.Hostname()
+	for _, tt := range []struct {
+		name   string
+		result runResult
+		text   string
+		want   []string
+	}{
+		{name: "success", result: runResult{Command: "restic backup /srv", Success: true, Duration: 90 * time.Second},
+			want: []string{"✅ ` restic backup /srv ` succeeded on **" + hostname + "** in 1m30s"}},
+		{name: "failure", result: runResult{Command: "make `test`", ExitCode: 2, Duration: time.Second, Output: "ok\nFAIL: TestX\n"},
+			want: []string{"`` make `test` `` failed with exit code 2 on **" + hostname + "** after 1s\n", "ok\nFAIL: TestX"}},
+		{name: "killed", result: runResult{Command: "sleep 60", ExitCode: -1, Error: "signal: killed"},
+			want: []string{"` sleep 60 ` was terminated (signal: killed)"}},
+		{name: "not started", result: runResult{Command: "backup.sh", ExitCode: 127, Error: "not found", Output: "not found"},
+			want: []string{"` backup.sh ` could not be started on **"}},
+		{name: "template", result: runResult{Command: "backup.sh", ExitCode: 3},
+			text: "{{.Run.Command}}: {{if .Run.Success}}ok{{else}}exit {{.Run.ExitCode}}{{end}}", want: []string{"backup.sh: exit 3"}},
+	} {
+		got, err := runMessage(&tt.result, tt.text)
+		if err != nil {
+			t.Errorf("%s: runMessage() error = %v", tt.name, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: runMessage() = %q, want %q", tt.name, got, want)
+			}
+		}
+	}
+	if _, err := runMessage(&runResult{}, "{{.Run.Missing}}"); err == nil {
+		t.Error("runMessage() of an invalid template = nil error")
+	}
+}
+
+func TestRunWrapped(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	var result *runResult
+	out := captureStdout(t, func() { result = runWrapped([]string{"sh", "-c", "echo backing up; exit 3"}) })
+	if out != "backing up\n" || result.Success || result.ExitCode != 3 || result.Output != "backing up\n" ||
+		result.Command != "sh -c echo backing up; exit 3" || len(result.Error) > 0 {
+		t.Errorf("runWrapped() = %+v, output %q", result, out)
+	}
+
+	result = runWrapped([]string{"sh", "-c", "kill -TERM $$"})
+	if result.Success || result.ExitCode != -1 || result.Error != "signal: terminated" {
+		t.Errorf("runWrapped() of a killed command = %+v", result)
+	}
+
+	result = runWrapped([]string{"/nonexistent/backup.sh"})
+	if result.Success || result.ExitCode != 127 || len(result.Error) == 0 {
+		t.Errorf("runWrapped() of a missing command = %+v", result)
+	}
+}
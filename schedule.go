@@ -0,0 +1,344 @@
+// schedule.go
+//
+// Scheduled and delayed sending (flags --at and --delay). A delayed message
+// is either held by the running process or handed off to a spool directory.
+// Spooled messages are sent by a later invocation with --spool-flush, e.g.
+// from cron, which supplies the token itself. The token is never written to
+// the spool.
+//
+// With --spool the spool also stores and forwards messages which could not
+// be sent because the Webex API or the proxy was unreachable. They are sent
+// by the next successful invocation, by --spool-flush or by the long-running
+// modes, which flush the spool every --spool-interval. The parts of a
+// message already sent, e.g. the private 1:1 message, are not sent again. A
+// flush claims every file by renaming it before sending, so flushes of
+// several processes, e.g. of the daemon and of cron, never send a message
+// twice.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// spoolEntry is the content of a single file in the spool directory.
+type spoolEntry struct {
+	NotBefore    time.Time     `json:"notBefore"`
+	Notification *notification `json:"notification"`
+}
+
+func defaultSpoolDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "notify_by_webex_teams", "spool")
+}
+
+// scheduledTime returns the point in time a message should be sent at, or
+// the zero time if it should be sent immediately.
+func scheduledTime(at string, delay time.Duration) (time.Time, error) {
+	if len(at) > 0 && delay > 0 {
+		return time.Time{}, fmt.Errorf("flags --at and --delay are mutually exclusive")
+	}
+	if delay > 0 {
+		return time.Now().Add(delay), nil
+	}
+	if len(at) == 0 {
+		return time.Time{}, nil
+	}
+	return parseAt(at, time.Now())
+}
+
+// parseAt parses the --at argument. A time of day without a date refers to
+// its next occurrence after now.
+func parseAt(at string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, at); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, at, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.ParseInLocation(layout, at, now.Location()); err == nil {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+			if !t.After(now) {
+				t = t.AddDate(0, 0, 1)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time for flag --at: %s. use 2006-01-02T15:04, 15:04 or RFC3339", at)
+}
+
+// spoolNotification writes n to the spool directory to be sent by --spool-flush
+// not before the given time.
+func spoolNotification(n *notification, notBefore time.Time) error {
+	if len(n.File) > 0 {
+		abs, err := filepath.Abs(n.File)
+		if err != nil {
+			return err
+		}
+		n.File = abs
+	}
+	for i, file := range n.Files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		n.Files[i] = abs
+	}
+
+	err := os.MkdirAll(spoolDir, 0700)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(&spoolEntry{NotBefore: notBefore, Notification: n}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = sealState(data); err != nil {
+		return err
+	}
+
+	id := make([]byte, 8)
+	_, err = rand.Read(id)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(spoolDir, fmt.Sprintf("%d-%s.json", notBefore.Unix(), hex.EncodeToString(id)))
+
+	// write to a temporary name first so a concurrent --spool-flush never
+	// sees a partially written file
+	tmp := name + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, name)
+	if err != nil {
+		return err
+	}
+	slog.Info("message spooled", "file", name, "due", notBefore.Format(time.RFC3339))
+	return nil
+}
+
+// isTransient reports whether sending failed for a reason which may go
+// away, e.g. a network outage, an unreachable proxy or an overloaded API.
+func isTransient(err error) bool {
+	if errors.Is(err, errCircuitOpen) {
+		return true
+	}
+	var apiErr *webex.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	// a local file error is not transient, although syscall.Errno is a
+	// net.Error
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+}
+
+// spoolFailed spools n, which failed to send with err, to be sent again as
+// soon as possible.
+func spoolFailed(n *notification, err error) error {
+	slog.Warn("sending failed, message spooled", "error", err)
+	return spoolNotification(n, time.Now())
+}
+
+// sendOrSpool sends n. With --spool messages failing for a transient
+// reason or while the circuit is open (see circuit.go) are spooled instead.
+func sendOrSpool(ctx context.Context, n *notification) error {
+	err := sendGuarded(ctx, n)
+	if err != nil && useSpool && isTransient(err) {
+		return spoolFailed(n, err)
+	}
+	return err
+}
+
+// runSpoolFlusher sends the due messages of the spool every --spool-interval
+// until ctx is canceled.
+func runSpoolFlusher(ctx context.Context) {
+	ticker := time.NewTicker(spoolInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := flushSpoolDir(); err != nil {
+				slog.Warn("flushing the spool", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// spoolFlushing serializes the flushes of the spool within the process, the
+// flushes of other processes are excluded by the claims of the files.
+var spoolFlushing sync.Mutex
+
+const (
+	// spoolClaimSuffix is appended to a spool file claimed by a flush
+	spoolClaimSuffix = ".sending"
+	// spoolStaleClaim is the age of a claim after which the flushing process
+	// is assumed to have crashed and the file is spooled again
+	spoolStaleClaim = time.Hour
+)
+
+// claimSpoolFile claims file for sending by renaming it and returns the name
+// of the claim, or "" if another process claimed or sent it already.
+func claimSpoolFile(file string) (string, error) {
+	claim := file + spoolClaimSuffix
+	err := os.Rename(file, claim)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	// the age of the claim, a rename keeps the modification time
+	now := time.Now()
+	if err := os.Chtimes(claim, now, now); err != nil {
+		return "", err
+	}
+	return claim, nil
+}
+
+// unclaimSpoolFile returns the claimed file to the spool.
+func unclaimSpoolFile(claim string) error {
+	return os.Rename(claim, strings.TrimSuffix(claim, spoolClaimSuffix))
+}
+
+// releaseStaleClaims returns the files of stale claims, e.g. of a crashed
+// process, to the spool.
+func releaseStaleClaims() {
+	claims, _ := filepath.Glob(filepath.Join(spoolDir, "*.json"+spoolClaimSuffix))
+	for _, claim := range claims {
+		fi, err := os.Stat(claim)
+		if err != nil || time.Since(fi.ModTime()) < spoolStaleClaim {
+			continue
+		}
+		if err := unclaimSpoolFile(claim); err == nil {
+			slog.Warn("stale spool claim released", "file", claim)
+		}
+	}
+}
+
+// flushSpoolDir sends all spooled messages which are due. Messages which
+// fail for a transient reason stay in the spool for the next run, others
+// are moved to the subdirectory failed.
+func flushSpoolDir() error {
+	spoolFlushing.Lock()
+	defer spoolFlushing.Unlock()
+	releaseStaleClaims()
+	files, err := filepath.Glob(filepath.Join(spoolDir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	var failed []string
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			// sent by the flush of another process
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if data, err = openState(data); err != nil {
+			slog.Warn("skipping spool file", "file", file, "error", err)
+			continue
+		}
+		var e spoolEntry
+		err = json.Unmarshal(data, &e)
+		if err != nil || e.Notification == nil {
+			slog.Warn("skipping invalid spool file", "file", file, "error", err)
+			continue
+		}
+		if e.NotBefore.After(time.Now()) {
+			continue
+		}
+		claim, err := claimSpoolFile(file)
+		if err != nil {
+			return err
+		}
+		if len(claim) == 0 {
+			// sent by the flush of another process
+			continue
+		}
+
+		ctx, cancel := requestContext()
+		err = sendGuarded(ctx, e.Notification)
+		cancel()
+		if errors.Is(err, errCircuitOpen) {
+			// the remaining messages are sent after the cooldown
+			slog.Debug("spool flush paused, circuit open")
+			if err := unclaimSpoolFile(claim); err != nil {
+				return err
+			}
+			break
+		}
+		if err != nil {
+			slog.Error("sending spooled message failed", "file", file, "error", err)
+			failed = append(failed, filepath.Base(file))
+			if isTransient(err) {
+				err = unclaimSpoolFile(claim)
+			} else {
+				err = moveToFailed(claim)
+				slog.Warn("spooled message moved to the failed messages", "dir", filepath.Join(spoolDir, "failed"))
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err = os.Remove(claim)
+		if err != nil {
+			return err
+		}
+		slog.Info("spooled message sent", "file", file)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d spooled messages could not be sent: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// moveToFailed moves a spool file which cannot be sent, e.g. because the
+// room does not exist, out of the way of the next flushes.
+func moveToFailed(file string) error {
+	dir := filepath.Join(spoolDir, "failed")
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	name := strings.TrimSuffix(filepath.Base(file), spoolClaimSuffix)
+	return os.Rename(file, filepath.Join(dir, name))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestDirectMessageSentOnce(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddPerson("alice@example.com", "Alice")
+	n := &notification{Email: "alice@example.com", TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "disk full"}
+
+	// the team does not exist yet, only the 1:1 message is sent
+	if err := sendNotification(context.Background(), n); err == nil {
+		t.Fatal("sendNotification() to missing team: err = nil")
+	}
+	if len(n.DirectRoomID) == 0 {
+		t.Fatal("DirectRoomID not set after the 1:1 message")
+	}
+	// as spooled
+	data, err := json.Marshal(&spoolEntry{Notification: n})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e spoolEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.AddTeam("KMP-Team")
+	if err := sendNotification(context.Background(), e.Notification); err != nil {
+		t.Fatal(err)
+	}
+	direct := 0
+	for _, m := range fake.Messages() {
+		if m.RoomID == n.DirectRoomID {
+			direct++
+		}
+	}
+	if direct != 1 || len(fake.Messages()) != 2 {
+		t.Errorf("1:1 messages = %d, all messages = %d, want 1 and 2", direct, len(fake.Messages()))
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"circuit open", errCircuitOpen, true},
+		{"rate limited", &webex.APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", fmt.Errorf("send: %w", &webex.APIError{StatusCode: http.StatusBadGateway}), true},
+		{"bad request", &webex.APIError{StatusCode: http.StatusBadRequest}, false},
+		{"timeout", context.DeadlineExceeded, true},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"missing file", &fs.PathError{Op: "open", Path: "report.pdf", Err: fs.ErrNotExist}, false},
+		{"other", errors.New("no team"), false},
+	} {
+		if got := isTransient(tt.err); got != tt.want {
+			t.Errorf("%s: isTransient(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
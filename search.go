@@ -0,0 +1,66 @@
+// search.go
+//
+// Search of the messages of a room (command search), to find the ID of a
+// message to edit, delete or reply to without opening the Webex client.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxSearchTextLen = 80
+
+func runSearch() error {
+	if len(roomName) == 0 {
+		return errors.New("no room. use flag -r")
+	}
+	if len(searchMatch) == 0 {
+		return errors.New("no search text. use flag --match")
+	}
+	since, err := parseSince(sinceString, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	room, err := findRoom(ctx, teamName, roomName)
+	if err != nil {
+		return err
+	}
+	messages, err := roomMessages(ctx, room, since)
+	if err != nil {
+		return err
+	}
+
+	match := strings.ToLower(searchMatch)
+	w := newTable("ID", "CREATED", "SENDER", "TEXT")
+	for _, m := range messages {
+		text := m.Text
+		if len(text) == 0 {
+			text = m.Markdown
+		}
+		if !strings.Contains(strings.ToLower(text), match) && !strings.Contains(strings.ToLower(m.Markdown), match) {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.ID, m.Created.Local().Format("2006-01-02 15:04"), m.PersonEmail, shorten(text, maxSearchTextLen))
+	}
+	return w.Flush()
+}
+
+// shorten returns the first line of s, cut to at most n characters.
+func shorten(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		s = s[:i] + " …"
+	}
+	if utf8.RuneCountInString(s) > n {
+		s = string([]rune(s)[:n-1]) + "…"
+	}
+	return s
+}
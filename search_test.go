@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShorten(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"  backup done  ", 80, "backup done"},
+		{"backup failed\nexit code 2", 80, "backup failed …"},
+		{"Größenänderung abgeschlossen", 10, "Größenänd…"},
+		{"", 80, ""},
+	} {
+		if got := shorten(tt.s, tt.n); got != tt.want {
+			t.Errorf("shorten(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRunSearch(t *testing.T) {
+	fake := useFakeWebex(t)
+	room := fake.AddRoom("Backups", fake.AddTeam("KMP-Team"), "group")
+	now := time.Now()
+	old := fake.AddMessage(room.ID, "cron@example.com", "backup **FAILED** on db-1", now.Add(-48*time.Hour))
+	failed := fake.AddMessage(room.ID, "cron@example.com", "backup failed on db-2\nexit code 2", now.Add(-time.Hour))
+	fake.AddMessage(room.ID, "cron@example.com", "backup done on db-3", now)
+	defer func(team, room, match, since string) {
+		teamName, roomName, searchMatch, sinceString = team, room, match, since
+	}(teamName, roomName, searchMatch, sinceString)
+
+	for _, tt := range []struct {
+		team, room, match, since string
+		want                     []string
+		wantErr                  string
+	}{
+		{team: "KMP-Team", room: "Backups", match: "Failed", want: []string{old.ID, failed.ID}},
+		{room: "Backups", match: "failed", since: "24h", want: []string{failed.ID}},
+		{room: "Backups", match: "restore"},
+		{room: "Backups", wantErr: "no search text. use flag --match"},
+		{match: "failed", wantErr: "no room. use flag -r"},
+		{room: "Backups", match: "failed", since: "yesterday", wantErr: `invalid time "yesterday"`},
+		{room: "Restores", match: "failed", wantErr: `room "Restores" not found`},
+	} {
+		teamName, roomName, searchMatch, sinceString = tt.team, tt.room, tt.match, tt.since
+		var err error
+		out := captureStdout(t, func() { err = runSearch() })
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("search %q in %s error = %v, want %s", tt.match, tt.room, err, tt.wantErr)
+			}
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if err != nil || len(lines) != len(tt.want)+1 || !strings.HasPrefix(lines[0], "ID") {
+			t.Errorf("search %q in %s = %v, %q, want %d messages", tt.match, tt.room, err, out, len(tt.want))
+			continue
+		}
+		for i, id := range tt.want {
+			if !strings.HasPrefix(lines[i+1], id+" ") || !strings.Contains(lines[i+1], "cron@example.com") {
+				t.Errorf("search %q in %s line %d = %q, want %s", tt.match, tt.room, i+1, lines[i+1], id)
+			}
+		}
+	}
+}
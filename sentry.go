@@ -0,0 +1,406 @@
+// sentry.go
+//
+// Sentry webhooks (serve --sentry). Issue alerts POSTed to /sentry are
+// posted as error cards with the title, culprit, level, environment, the
+// number of alerts and when the issue was first and last seen. Accepted are
+// the payloads of the WebHooks plugin of Sentry and of internal integrations
+// (event_alert and issue resources).
+//
+// The cards are de-duplicated by issue: after the card of an issue further
+// alerts of the issue are only counted for --sentry-window. At the end of the
+// window the card is posted again with the new count if at least
+// --sentry-threshold alerts arrived, so a noisy issue is one card per window
+// instead of a card per error, and an issue which stopped is not posted
+// again.
+//
+// The rooms of the projects are set in the config file (-c), by slug or ID
+// of the project, other projects go to -t/-r or -D:
+//
+//	{
+//	  "sentry": {
+//	    "projects": {
+//	      "shop-backend": { "team": "KMP-Team", "room": "Shop Errors" }
+//	    }
+//	  }
+//	}
+//
+// With --sentry-secret only alerts signed with the client secret of the
+// internal integration (Sentry-Hook-Signature) are accepted.
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sentryConfig is the section sentry of the config file.
+type sentryConfig struct {
+	Projects map[string]*eventRoute `json:"projects"`
+}
+
+// sentryAlert is an alert of an issue, of any of the payloads.
+type sentryAlert struct {
+	IssueID     string
+	Title       string
+	Culprit     string
+	Level       string
+	Project     string
+	ProjectID   string
+	Environment string
+	URL         string
+	Rule        string
+	Time        time.Time
+}
+
+// sentryIssue is the state of an issue of the de-duplication.
+type sentryIssue struct {
+	alert     sentryAlert
+	firstSeen time.Time
+	lastSeen  time.Time
+	// alerts in total and since the card was posted
+	count, pending int
+	timer          *time.Timer
+}
+
+// sentryRelay posts the issues of serve --sentry.
+type sentryRelay struct {
+	routes map[string]*eventRoute
+	mu     sync.Mutex
+	issues map[string]*sentryIssue
+	// cards in progress, waited for on shutdown
+	running sync.WaitGroup
+}
+
+// sentry is the relay of serve --sentry, nil without.
+var sentry *sentryRelay
+
+// newSentryRelay returns the relay with the routes of the config file.
+func newSentryRelay() (*sentryRelay, error) {
+	s := &sentryRelay{routes: make(map[string]*eventRoute), issues: make(map[string]*sentryIssue)}
+	if len(configFile) > 0 {
+		c, err := loadConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sentry != nil {
+			for project, r := range c.Sentry.Projects {
+				if len(r.Team) == 0 && len(r.Email) == 0 {
+					return nil, fmt.Errorf("config file %s, sentry project %s: set team or email", configFile, project)
+				}
+				if len(r.Team) > 0 && len(r.Room) == 0 {
+					r.Room = roomName
+				}
+				s.routes[strings.ToLower(project)] = r
+			}
+		}
+	}
+	if len(s.routes) == 0 && len(teamName) == 0 && len(emailAddr) == 0 {
+		return nil, errors.New("--sentry needs the rooms of the projects in the config file (-c) or flag -t or -D")
+	}
+	if sentryWindow <= 0 {
+		return nil, errors.New("--sentry-window must be positive")
+	}
+	return s, nil
+}
+
+func handleSentry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !allowedSource(r) {
+		slog.Warn("Sentry alert of a source not allowed rejected", "remote", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, "invalid Sentry alert: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(sentrySecret) > 0 && !verifySentrySignature(body, r.Header.Get("Sentry-Hook-Signature"), sentrySecret) {
+		slog.Warn("Sentry alert with invalid signature rejected", "remote", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	alert, err := parseSentryAlert(body, r.Header.Get("Sentry-Hook-Resource"))
+	if err != nil {
+		http.Error(w, "invalid Sentry alert: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if alert == nil {
+		// e.g. the installation of the integration or a resolved issue
+		slog.Debug("Sentry webhook ignored", "resource", r.Header.Get("Sentry-Hook-Resource"))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	sentry.alert(alert)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verifySentrySignature checks the Sentry-Hook-Signature header, the hex
+// HMAC-SHA256 of body with the client secret.
+func verifySentrySignature(body []byte, signature, secret string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// parseSentryAlert returns the alert of a webhook, nil for other webhooks.
+// resource is the Sentry-Hook-Resource header of internal integrations,
+// empty for the WebHooks plugin.
+func parseSentryAlert(body []byte, resource string) (*sentryAlert, error) {
+	switch resource {
+	case "":
+		// WebHooks plugin
+		var p struct {
+			ID          string   `json:"id"`
+			Project     string   `json:"project"`
+			ProjectSlug string   `json:"project_slug"`
+			Culprit     string   `json:"culprit"`
+			Level       string   `json:"level"`
+			Message     string   `json:"message"`
+			URL         string   `json:"url"`
+			Rules       []string `json:"triggering_rules"`
+			Event       struct {
+				Title       string          `json:"title"`
+				Environment string          `json:"environment"`
+				Timestamp   json.RawMessage `json:"timestamp"`
+			} `json:"event"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, err
+		}
+		if len(p.ID) == 0 {
+			return nil, errors.New("no issue id")
+		}
+		a := &sentryAlert{IssueID: p.ID, Title: p.Event.Title, Culprit: p.Culprit, Level: p.Level,
+			Project: firstNonEmpty(p.ProjectSlug, p.Project), Environment: p.Event.Environment, URL: p.URL}
+		if len(a.Title) == 0 {
+			a.Title = p.Message
+		}
+		if len(p.Rules) > 0 {
+			a.Rule = strings.Join(p.Rules, ", ")
+		}
+		return a, nil
+	case "event_alert":
+		var p struct {
+			Data struct {
+				Event struct {
+					IssueID     string    `json:"issue_id"`
+					Title       string    `json:"title"`
+					Culprit     string    `json:"culprit"`
+					Level       string    `json:"level"`
+					Project     int64     `json:"project"`
+					Environment string    `json:"environment"`
+					WebURL      string    `json:"web_url"`
+					IssueURL    string    `json:"issue_url"`
+					Datetime    time.Time `json:"datetime"`
+				} `json:"event"`
+				Rule string `json:"triggered_rule"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, err
+		}
+		e := p.Data.Event
+		if len(e.IssueID) == 0 {
+			return nil, errors.New("no issue id")
+		}
+		return &sentryAlert{IssueID: e.IssueID, Title: e.Title, Culprit: e.Culprit, Level: e.Level,
+			ProjectID: fmt.Sprint(e.Project), Environment: e.Environment, URL: e.WebURL, Rule: p.Data.Rule, Time: e.Datetime}, nil
+	case "issue":
+		var p struct {
+			Action string `json:"action"`
+			Data   struct {
+				Issue struct {
+					ID        string    `json:"id"`
+					Title     string    `json:"title"`
+					Culprit   string    `json:"culprit"`
+					Level     string    `json:"level"`
+					Permalink string    `json:"permalink"`
+					WebURL    string    `json:"web_url"`
+					LastSeen  time.Time `json:"lastSeen"`
+					Project   struct {
+						ID   string `json:"id"`
+						Slug string `json:"slug"`
+					} `json:"project"`
+				} `json:"issue"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return nil, err
+		}
+		if p.Action != "created" && p.Action != "unresolved" {
+			return nil, nil
+		}
+		i := p.Data.Issue
+		if len(i.ID) == 0 {
+			return nil, errors.New("no issue id")
+		}
+		return &sentryAlert{IssueID: i.ID, Title: i.Title, Culprit: i.Culprit, Level: i.Level,
+			Project: i.Project.Slug, ProjectID: i.Project.ID, URL: firstNonEmpty(i.Permalink, i.WebURL), Time: i.LastSeen}, nil
+	}
+	return nil, nil
+}
+
+// alert posts the card of a new issue, or counts the alert of an issue
+// posted before.
+func (s *sentryRelay) alert(a *sentryAlert) {
+	now := a.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	issue := s.issues[a.IssueID]
+	if issue != nil {
+		issue.alert = *a
+		issue.lastSeen = now
+		issue.count++
+		issue.pending++
+		slog.Debug("Sentry alert counted", "issue", a.IssueID, "count", issue.count)
+		return
+	}
+	issue = &sentryIssue{alert: *a, firstSeen: now, lastSeen: now, count: 1}
+	s.issues[a.IssueID] = issue
+	s.post(issue)
+	s.schedule(a.IssueID, issue)
+}
+
+// schedule ends the window of the issue after --sentry-window.
+func (s *sentryRelay) schedule(id string, issue *sentryIssue) {
+	issue.timer = time.AfterFunc(sentryWindow, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if issue.pending < max(sentryThreshold, 1) {
+			// quiet for a window, the next alert is a new card
+			delete(s.issues, id)
+			return
+		}
+		s.post(issue)
+		issue.pending = 0
+		s.schedule(id, issue)
+	})
+}
+
+// post posts the card of the issue in the background. s.mu is held.
+func (s *sentryRelay) post(issue *sentryIssue) {
+	a := issue.alert
+	n := &notification{TeamName: teamName, RoomName: roomName, Email: emailAddr, Mentions: mentionEmails, Severity: severityName, Ack: ackButton}
+	for _, key := range []string{a.Project, a.ProjectID} {
+		if r := s.routes[strings.ToLower(key)]; r != nil && len(key) > 0 {
+			n.TeamName, n.RoomName, n.Email = r.Team, r.Room, r.Email
+			break
+		}
+	}
+	if len(n.TeamName) == 0 && len(n.Email) == 0 {
+		slog.Warn("Sentry alert of a project without room ignored", "issue", a.IssueID, "project", firstNonEmpty(a.Project, a.ProjectID))
+		return
+	}
+	// the severity colors the card
+	if len(n.Severity) == 0 {
+		n.Severity = map[string]string{"fatal": "critical", "error": "critical", "warning": "warning"}[a.Level]
+	}
+	n.Markdown = fmt.Sprintf("**%s** %s", a.Title, a.Culprit)
+	if len(a.URL) > 0 {
+		n.Markdown = fmt.Sprintf("[**%s**](%s) %s", a.Title, a.URL, a.Culprit)
+	}
+	if issue.count > 1 {
+		n.Markdown += fmt.Sprintf(" (%d alerts)", issue.count)
+	}
+	card, err := sentryCard(issue)
+	if err != nil {
+		slog.Error("building the Sentry card failed", "issue", a.IssueID, "error", err)
+	}
+	n.Card = card
+	count := issue.count
+
+	fields := map[string]string{"issue": a.IssueID, "title": a.Title, "culprit": a.Culprit, "level": a.Level,
+		"project": firstNonEmpty(a.Project, a.ProjectID), "environment": a.Environment, "rule": a.Rule}
+	post, err := applyEventRule("sentry", fields, n)
+	if err != nil {
+		slog.Error("applying the rule to the Sentry alert failed", "issue", a.IssueID, "error", err)
+	}
+	if !post {
+		return
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		if digestWindow > 0 {
+			queueDigest(n)
+			return
+		}
+		ctx, cancel := requestContext()
+		defer cancel()
+		if err := sendOrSpool(ctx, n); err != nil {
+			slog.Error("posting Sentry alert failed", "issue", a.IssueID, "error", err)
+			return
+		}
+		slog.Info("Sentry alert posted", "issue", a.IssueID, "project", firstNonEmpty(a.Project, a.ProjectID), "count", count)
+	}()
+}
+
+// sentryCard returns the card attachment of the issue. s.mu is held.
+func sentryCard(issue *sentryIssue) (string, error) {
+	a := issue.alert
+	var facts []interface{}
+	// facts without value are invalid
+	addFact := func(title, value string) {
+		if len(strings.TrimSpace(value)) > 0 {
+			facts = append(facts, map[string]interface{}{"title": title, "value": value})
+		}
+	}
+	addFact(tr("Culprit"), a.Culprit)
+	addFact(tr("Level"), a.Level)
+	addFact(tr("Project"), firstNonEmpty(a.Project, a.ProjectID))
+	addFact(tr("Environment"), a.Environment)
+	addFact(tr("Alerts"), fmt.Sprint(issue.count))
+	addFact(tr("First seen"), formatTimeSeconds(issue.firstSeen.Local()))
+	addFact(tr("Last seen"), formatTimeSeconds(issue.lastSeen.Local()))
+	addFact(tr("Rule"), a.Rule)
+
+	title := a.Title
+	if len(title) == 0 {
+		title = trf("Sentry issue %s", a.IssueID)
+	}
+	body := []interface{}{
+		map[string]interface{}{"type": "TextBlock", "text": title, "size": "Large", "weight": "Bolder", "wrap": true},
+	}
+	if issue.count > 1 {
+		text := trf("%d alerts since %s", issue.count, formatTime(issue.firstSeen.Local()))
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": text, "isSubtle": true, "wrap": true})
+	}
+	body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	card := map[string]interface{}{
+		"type":    "AdaptiveCard",
+		"version": defaultCardVersion,
+		"body":    body,
+	}
+	if len(a.URL) > 0 {
+		card["actions"] = []interface{}{
+			map[string]interface{}{"type": "Action.OpenUrl", "title": trf("Open in %s", "Sentry"), "url": a.URL},
+		}
+	}
+	data, err := json.Marshal(card)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySentrySignature(t *testing.T) {
+	body := []byte(`{"action":"created"}`)
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(body)
+	sum := hex.EncodeToString(mac.Sum(nil))
+	for _, tt := range []struct {
+		signature string
+		want      bool
+	}{
+		{sum, true},
+		{"sha256=" + sum, false},
+		{sum[:62] + "00", false},
+		{"", false},
+	} {
+		if got := verifySentrySignature(body, tt.signature, "s3cr3t"); got != tt.want {
+			t.Errorf("verifySentrySignature(%q) = %v, want %v", tt.signature, got, tt.want)
+		}
+	}
+}
+
+func TestParseSentryAlert(t *testing.T) {
+	seen := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name, resource, body string
+		want                 *sentryAlert
+		wantErr              bool
+	}{
+		{name: "plugin", body: `{"id": "4711", "project": "Shop Backend", "project_slug": "shop-backend", "culprit": "cart.checkout",
+			"level": "error", "message": "fallback", "url": "https://sentry.example.com/issues/4711/", "triggering_rules": ["errors", "new"],
+			"event": {"title": "ZeroDivisionError", "environment": "production"}}`,
+			want: &sentryAlert{IssueID: "4711", Title: "ZeroDivisionError", Culprit: "cart.checkout", Level: "error", Project: "shop-backend",
+				Environment: "production", URL: "https://sentry.example.com/issues/4711/", Rule: "errors, new"}},
+		{name: "plugin message", body: `{"id": "4711", "project": "shop", "message": "disk full"}`,
+			want: &sentryAlert{IssueID: "4711", Title: "disk full", Project: "shop"}},
+		{name: "plugin without id", body: `{"project": "shop"}`, wantErr: true},
+		{name: "event_alert", resource: "event_alert", body: `{"data": {"event": {"issue_id": "4711", "title": "ZeroDivisionError", "level": "fatal",
+			"project": 12, "web_url": "https://sentry.example.com/issues/4711/", "datetime": "2026-10-15T08:00:00Z"}, "triggered_rule": "errors"}}`,
+			want: &sentryAlert{IssueID: "4711", Title: "ZeroDivisionError", Level: "fatal", ProjectID: "12",
+				URL: "https://sentry.example.com/issues/4711/", Rule: "errors", Time: seen}},
+		{name: "issue created", resource: "issue", body: `{"action": "created", "data": {"issue": {"id": "4711", "title": "ZeroDivisionError",
+			"permalink": "https://sentry.example.com/issues/4711/", "lastSeen": "2026-10-15T08:00:00Z", "project": {"id": "12", "slug": "shop"}}}}`,
+			want: &sentryAlert{IssueID: "4711", Title: "ZeroDivisionError", Project: "shop", ProjectID: "12",
+				URL: "https://sentry.example.com/issues/4711/", Time: seen}},
+		{name: "issue resolved", resource: "issue", body: `{"action": "resolved", "data": {"issue": {"id": "4711"}}}`},
+		{name: "installation", resource: "installation", body: `{"action": "created"}`},
+		{name: "invalid", resource: "event_alert", body: `{"data": `, wantErr: true},
+	} {
+		got, err := parseSentryAlert([]byte(tt.body), tt.resource)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseSentryAlert() error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("%s: parseSentryAlert() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSentryCard(t *testing.T) {
+	first := time.Date(2026, 10, 15, 8, 0, 0, 0, time.Local)
+	issue := &sentryIssue{alert: sentryAlert{IssueID: "4711", Level: "error", ProjectID: "12"}, firstSeen: first, lastSeen: first.Add(time.Minute), count: 3}
+	card, err := sentryCard(issue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"text":"Sentry issue 4711"`, `"text":"3 alerts since `, `{"title":"Project","value":"12"}`, `{"title":"Alerts","value":"3"}`} {
+		if !strings.Contains(card, want) {
+			t.Errorf("sentryCard() = %s, want %s", card, want)
+		}
+	}
+	if strings.Contains(card, "Culprit") || strings.Contains(card, "actions") {
+		t.Errorf("sentryCard() = %s, want no culprit and no actions", card)
+	}
+}
+
+func TestSentryRelay(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(file, team, room string, window time.Duration, threshold int, relay *sentryRelay) {
+		configFile, teamName, roomName, sentryWindow, sentryThreshold, sentry = file, team, room, window, threshold, relay
+	}(configFile, teamName, roomName, sentryWindow, sentryThreshold, sentry)
+	configFile, teamName, roomName, sentryWindow, sentryThreshold = "", "KMP-Team", "Errors", 100*time.Millisecond, 2
+	var err error
+	if sentry, err = newSentryRelay(); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		handleSentry(w, httptest.NewRequest("POST", "/sentry", strings.NewReader(body)))
+		return w.Code
+	}
+	alert := `{"id": "4711", "project": "shop", "level": "error", "culprit": "cart.checkout", "event": {"title": "ZeroDivisionError"}}`
+	for i := 0; i < 3; i++ {
+		if code := post(alert); code != http.StatusAccepted {
+			t.Fatalf("handleSentry() status = %d, want %d", code, http.StatusAccepted)
+		}
+	}
+	if code := post(`{"project": "shop"}`); code != http.StatusBadRequest {
+		t.Errorf("handleSentry() of an alert without issue status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	// the card of the issue and, after the window, the card with the count
+	var got []string
+	for deadline := time.Now().Add(5 * time.Second); len(got) < 2 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		got = got[:0]
+		for _, m := range fake.Messages() {
+			got = append(got, m.Markdown)
+		}
+	}
+	want := []string{"🚨 **ZeroDivisionError** cart.checkout", "🚨 **ZeroDivisionError** cart.checkout (3 alerts)"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+
+	// quiet for a window, the issue is forgotten
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		sentry.mu.Lock()
+		n := len(sentry.issues)
+		sentry.mu.Unlock()
+		if n == 0 {
+			break
+		}
+	}
+	sentry.mu.Lock()
+	defer sentry.mu.Unlock()
+	if len(sentry.issues) > 0 {
+		t.Errorf("issues = %d after a quiet window, want 0", len(sentry.issues))
+	}
+	sentry.running.Wait()
+}
@@ -0,0 +1,355 @@
+// serve.go
+//
+// HTTP relay mode (command serve). Other programs POST notifications as JSON
+// to /send instead of running the CLI for every message, and Webex webhooks
+// can be pointed at /webhook. Received webhook events are logged. With
+// --secret only events signed with the secret of the webhook are accepted,
+// with --allow-ip only events of the given addresses. With --chatops the
+// messages to the bot run commands, see chatops.go, with --forward-url the
+// card submissions are posted to automation, see forward.go, with --ack the
+// Acknowledge buttons are handled, see ack.go. --chatops, --forward-url and
+// --ack need --secret. With --jira Jira webhooks on /jira are posted as cards, see
+// jira.go, with --pagerduty the incidents of PagerDuty webhooks on
+// /pagerduty, see pagerduty.go, with --sentry the Sentry issue alerts on
+// /sentry, see sentry.go, with --datadog the Datadog monitor alerts on
+// /datadog, see datadog.go, with --alertmanager and --grafana the alerts of
+// Prometheus Alertmanager on /alertmanager and of Grafana on /grafana, see
+// alertmanager.go.
+// /healthz and /readyz are the probes of the relay, see health.go, /metrics
+// serves the Prometheus metrics, see metrics.go.
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+const maxRequestBody = 1 << 20
+
+// webhookSources are the addresses of --allow-ip, nil if all are allowed.
+var webhookSources []netip.Prefix
+
+func runServer(listen string) error {
+	sources, err := parsePrefixes(allowIPs)
+	if err != nil {
+		return fmt.Errorf("--allow-ip: %w", err)
+	}
+	webhookSources = sources
+	if len(serveToken) == 0 && !loopbackAddress(listen) {
+		slog.Warn("POST /send accepts notifications of everyone who can reach the relay, set --serve-token", "address", listen)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/send", handleSend)
+	mux.HandleFunc("/webhook", handleWebhook)
+	if jiraRoutes != nil {
+		mux.HandleFunc("/jira", handleJira)
+	}
+	if pagerDuty != nil {
+		mux.HandleFunc("/pagerduty", handlePagerDuty)
+	}
+	if sentry != nil {
+		mux.HandleFunc("/sentry", handleSentry)
+	}
+	if datadogRoutes != nil {
+		mux.HandleFunc("/datadog", handleDatadog)
+	}
+	if alertmanagerHooks {
+		mux.HandleFunc("/alertmanager", alertmanagerReceiver.handler())
+	}
+	if grafanaHooks {
+		mux.HandleFunc("/grafana", grafanaReceiver.handler())
+	}
+	mux.HandleFunc("/healthz", handleHealthz)
+	mux.HandleFunc("/readyz", handleReadyz)
+	mux.HandleFunc("/metrics", handleMetrics)
+
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := shutdownContext()
+	defer stop()
+	if useSpool {
+		go runSpoolFlusher(ctx)
+	}
+	go runTokenReloader(ctx)
+	failed := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "address", listen)
+		failed <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Shutdown waits for the requests in progress
+	err = srv.Shutdown(context.Background())
+	if err != nil {
+		return err
+	}
+	if bot != nil {
+		bot.running.Wait()
+	}
+	if forward != nil {
+		forward.running.Wait()
+	}
+	if acks != nil {
+		acks.running.Wait()
+	}
+	if sentry != nil {
+		sentry.running.Wait()
+	}
+	if deleteWebhooks {
+		err = deleteWebhooksOf(webhookTarget)
+	}
+	finishShutdown()
+	slog.Info("relay stopped")
+	return err
+}
+
+// deleteWebhooksOf deletes the webhooks posting to targetURL.
+func deleteWebhooksOf(targetURL string) error {
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	hooks, err := client.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, h := range hooks {
+		if h.TargetURL != targetURL {
+			continue
+		}
+		if err := client.DeleteWebhook(ctx, h.ID); err != nil {
+			return err
+		}
+		slog.Info("webhook deleted", "id", h.ID, "name", h.Name)
+	}
+	return nil
+}
+
+// handleSend sends the notification in the request body, e.g.
+// {"teamName": "KMP-Team", "roomName": "Alerts", "markdown": "disk full"}.
+func handleSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var n notification
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&n)
+	if err != nil {
+		http.Error(w, "invalid notification: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(n.IdempotencyKey) == 0 {
+		n.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	if err := validateRelayed(&n); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if digestWindow > 0 {
+		queueDigest(&n)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	ctx, cancel := requestContext()
+	defer cancel()
+	err = sendGuarded(ctx, &n)
+	if err != nil && useSpool && isTransient(err) {
+		err = spoolFailed(&n, err)
+		if err == nil {
+			// accepted, but not sent yet
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+	}
+	if err != nil {
+		slog.Error("relaying notification failed", "remote", r.RemoteAddr, "error", err)
+		http.Error(w, redact(err.Error()), http.StatusBadGateway)
+		return
+	}
+	slog.Info("notification relayed", "remote", r.RemoteAddr, "team", n.TeamName, "room", n.RoomName, "email", n.Email)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateRelayed checks a notification received by the relay. Files are
+// rejected, as they would be read from the file system of the relay host.
+func validateRelayed(n *notification) error {
+	if len(n.File) > 0 || len(n.Files) > 0 {
+		return errors.New("file uploads are not supported by the relay")
+	}
+	// set only for spooled messages
+	n.DirectRoomID = ""
+	if n.MentionAll && !confirmAll {
+		return errors.New("mentionAll is not allowed. start the relay with flag --confirm-mention-all")
+	}
+	template := n.Template
+	if len(n.Template) > 0 {
+		if err := applyTemplate(n); err != nil {
+			return err
+		}
+	}
+	if err := routeNotification(n, template); err != nil {
+		return err
+	}
+	if len(n.TeamName) == 0 && len(n.Email) == 0 {
+		return errors.New("no recipient. set teamName or email, or labels of a route of the config file")
+	}
+	if len(n.TeamName) > 0 && len(n.RoomName) == 0 {
+		n.RoomName = roomName
+	}
+	if len(n.Severity) > 0 {
+		if _, err := lookupSeverity(n.Severity); err != nil {
+			return err
+		}
+	}
+	if len(n.Card) > 0 {
+		card, err := normalizeCard(n.Card)
+		if err != nil {
+			return err
+		}
+		n.Card = card
+	}
+	return nil
+}
+
+// loopbackAddress reports whether the listen address accepts local
+// connections only.
+func loopbackAddress(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+// authorized checks the bearer token of a relay request if flag
+// --serve-token is set.
+func authorized(r *http.Request) bool {
+	if len(serveToken) == 0 {
+		return true
+	}
+	got := []byte(r.Header.Get("Authorization"))
+	want := []byte("Bearer " + serveToken)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+func handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !allowedSource(r) {
+		slog.Warn("webhook event of a source not allowed rejected", "remote", r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, "invalid webhook event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(webhookSecret) > 0 && !webex.VerifySignature(body, r.Header.Get(webex.SignatureHeader), webhookSecret) {
+		slog.Warn("webhook event with invalid signature rejected", "remote", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var ev webex.WebhookEvent
+	err = json.Unmarshal(body, &ev)
+	if err != nil {
+		http.Error(w, "invalid webhook event: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.Info("webhook event", "name", ev.Name, "resource", ev.Resource, "event", ev.Event, "actorID", ev.ActorID, "data", string(ev.Data))
+	if bot != nil && ev.Resource == "messages" && ev.Event == "created" {
+		bot.handle(&ev)
+	}
+	if forward != nil && ev.Resource == "attachmentActions" && ev.Event == "created" {
+		forward.handle(&ev)
+	}
+	if acks != nil && ev.Resource == "attachmentActions" && ev.Event == "created" {
+		acks.handle(&ev)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowedSource checks the remote address of a webhook request against
+// --allow-ip. A reverse proxy in front of the relay has to restrict the
+// sources itself.
+func allowedSource(r *http.Request) bool {
+	if webhookSources == nil {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range webhookSources {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePrefixes parses a comma separated list of IP addresses and CIDR
+// ranges, e.g. 10.0.0.0/8,192.0.2.7.
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
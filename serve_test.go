@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestLoopbackAddress(t *testing.T) {
+	for _, tt := range []struct {
+		listen string
+		want   bool
+	}{
+		{"localhost:8080", true},
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"192.0.2.7:8080", false},
+		{"relay.example.com:8080", false},
+		{"localhost", false},
+	} {
+		if got := loopbackAddress(tt.listen); got != tt.want {
+			t.Errorf("loopbackAddress(%q) = %v, want %v", tt.listen, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	defer func() { serveToken = "" }()
+	for _, tt := range []struct {
+		token, header string
+		want          bool
+	}{
+		{"", "", true},
+		{"s3cr3t", "Bearer s3cr3t", true},
+		{"s3cr3t", "", false},
+		{"s3cr3t", "Bearer s3cr3", false},
+		{"s3cr3t", "Bearer s3cr3t ", false},
+		{"s3cr3t", "s3cr3t", false},
+	} {
+		serveToken = tt.token
+		r := httptest.NewRequest("POST", "/send", nil)
+		if len(tt.header) > 0 {
+			r.Header.Set("Authorization", tt.header)
+		}
+		if got := authorized(r); got != tt.want {
+			t.Errorf("authorized() with token %q and header %q = %v, want %v", tt.token, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRelayed(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		n        notification
+		wantRoom string
+		wantErr  string
+	}{
+		{name: "team", n: notification{TeamName: "KMP-Team", Markdown: "hi"}, wantRoom: roomName},
+		{name: "email", n: notification{Email: "john.smith@example.com", Markdown: "hi"}},
+		{name: "file", n: notification{TeamName: "KMP-Team", File: "/etc/passwd"}, wantErr: "file uploads are not supported"},
+		{name: "no recipient", n: notification{Markdown: "hi"}, wantErr: "no recipient"},
+		{name: "mention all", n: notification{TeamName: "KMP-Team", MentionAll: true}, wantErr: "mentionAll is not allowed"},
+	} {
+		err := validateRelayed(&tt.n)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: validateRelayed() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || tt.n.RoomName != tt.wantRoom {
+			t.Errorf("%s: validateRelayed() = %v, room %q, want room %q", tt.name, err, tt.n.RoomName, tt.wantRoom)
+		}
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	for _, tt := range []struct {
+		list    string
+		want    string
+		wantErr bool
+	}{
+		{list: "", want: "[]"},
+		{list: "192.0.2.7", want: "[192.0.2.7/32]"},
+		{list: "10.1.2.3/8, 2001:db8::1/32,", want: "[10.0.0.0/8 2001:db8::/32]"},
+		{list: "::ffff:192.0.2.7", want: "[192.0.2.7/32]"},
+		{list: "192.0.2.300", wantErr: true},
+		{list: "10.0.0.0/33", wantErr: true},
+		{list: "relay.example.com", wantErr: true},
+	} {
+		got, err := parsePrefixes(tt.list)
+		if tt.wantErr != (err != nil) {
+			t.Errorf("parsePrefixes(%q) error = %v, want error %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && fmt.Sprint(got) != tt.want {
+			t.Errorf("parsePrefixes(%q) = %v, want %s", tt.list, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedSource(t *testing.T) {
+	defer func() { webhookSources = nil }()
+	for _, tt := range []struct {
+		allow, remote string
+		want          bool
+	}{
+		{"", "198.51.100.1:443", true},
+		{"10.0.0.0/8", "10.1.2.3:443", true},
+		{"10.0.0.0/8", "[::ffff:10.1.2.3]:443", true},
+		{"10.0.0.0/8", "11.1.2.3:443", false},
+		{"192.0.2.7,2001:db8::/32", "[2001:db8::5]:443", true},
+		{"192.0.2.7", "192.0.2.8:443", false},
+		{"192.0.2.7", "192.0.2.7", false},
+	} {
+		webhookSources = nil
+		if len(tt.allow) > 0 {
+			var err error
+			if webhookSources, err = parsePrefixes(tt.allow); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r := httptest.NewRequest("POST", "/webhook", nil)
+		r.RemoteAddr = tt.remote
+		if got := allowedSource(r); got != tt.want {
+			t.Errorf("allowedSource() of %s with --allow-ip %q = %v, want %v", tt.remote, tt.allow, got, tt.want)
+		}
+	}
+}
+
+func TestHandleWebhookRejects(t *testing.T) {
+	defer func() { webhookSecret, webhookSources = "", nil }()
+	webhookSecret = "s3cr3t"
+	webhookSources, _ = parsePrefixes("192.0.2.0/24")
+	body := `{"resource":"messages","event":"created","data":{"id":"1"}}`
+	mac := hmac.New(sha1.New, []byte(webhookSecret))
+	mac.Write([]byte(body))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	for _, tt := range []struct {
+		name, method, remote, signature string
+		want                            int
+	}{
+		{"valid", "POST", "192.0.2.7:443", signature, http.StatusNoContent},
+		{"get", "GET", "192.0.2.7:443", signature, http.StatusMethodNotAllowed},
+		{"source", "POST", "198.51.100.1:443", signature, http.StatusForbidden},
+		{"no signature", "POST", "192.0.2.7:443", "", http.StatusUnauthorized},
+		{"wrong signature", "POST", "192.0.2.7:443", signature[:len(signature)-2] + "00", http.StatusUnauthorized},
+	} {
+		r := httptest.NewRequest(tt.method, "/webhook", strings.NewReader(body))
+		r.RemoteAddr = tt.remote
+		if len(tt.signature) > 0 {
+			r.Header.Set(webex.SignatureHeader, tt.signature)
+		}
+		w := httptest.NewRecorder()
+		handleWebhook(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: handleWebhook() status = %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// service.go
+//
+// Command service. Installs one of the long-running modes (serve, --mqtt,
+// --daemon, --k8s-watch) as system service, a systemd unit on Linux and a
+// service of the service control manager on Windows, and starts, stops or
+// removes it. The arguments of the mode follow "--":
+//
+//	notify_by_webex_teams service install --service-name webex-relay -- serve --listen :8080 -c /etc/notify.json
+//
+// The installed service runs the binary with flag --run-as-service, which
+// connects to the service control manager on Windows and logs to the
+// Windows event log. On Linux systemd collects the log of standard error in
+// the journal.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func cmdService(action string, args []string) error {
+	if len(serviceName) == 0 || strings.ContainsAny(serviceName, `/\ "'`) {
+		return fmt.Errorf("invalid service name %q", serviceName)
+	}
+	if action != "install" {
+		if err := noArgs(args); err != nil {
+			return err
+		}
+		err := controlService(serviceName, action)
+		if err != nil {
+			return err
+		}
+		slog.Info("service "+action, "name", serviceName)
+		return nil
+	}
+
+	if err := checkServiceArgs(args); err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+	for _, a := range args {
+		if a == "-T" || a == "--token" || strings.HasPrefix(a, "-T=") || strings.HasPrefix(a, "--token=") {
+			slog.Warn("the token is stored in the service definition. prefer --oauth-token-file")
+			break
+		}
+	}
+	// the command, e.g. serve, has to stay the first argument
+	cmdline := append(append([]string{exe}, args...), "--run-as-service", serviceName)
+	err = installService(serviceName, cmdline)
+	if err != nil {
+		return err
+	}
+	slog.Info("service installed", "name", serviceName, "mode", args[0])
+	return nil
+}
+
+// checkServiceArgs checks that args select a long-running mode, as other
+// modes would exit right away and be restarted over and over.
+func checkServiceArgs(args []string) error {
+	if len(args) == 0 {
+		return errors.New("no service arguments. use service install -- serve|--mqtt <broker> ...|--daemon -c <config file>")
+	}
+	if args[0] == "serve" {
+		return nil
+	}
+	for _, a := range args {
+		name := strings.TrimLeft(strings.SplitN(a, "=", 2)[0], "-")
+		if strings.HasPrefix(a, "-") && (name == "mqtt" || name == "daemon" || name == "k8s-watch") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a long-running mode. use serve, --mqtt, --daemon or --k8s-watch", strings.Join(args, " "))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const systemdUnitDir = "/etc/systemd/system"
+
+const systemdUnit = `[Unit]
+Description=notify_by_webex_teams %s
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+ExecStart=%s
+Restart=on-failure
+RestartSec=5
+SyslogIdentifier=%s
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// installService writes a systemd unit running cmdline and enables it. The
+// unit is readable by root only, as the command line may contain secrets.
+func installService(name string, cmdline []string) error {
+	var quoted []string
+	for _, arg := range cmdline {
+		quoted = append(quoted, quoteSystemdArg(arg))
+	}
+	unit := fmt.Sprintf(systemdUnit, name, strings.Join(quoted, " "), name)
+	err := os.WriteFile(unitFile(name), []byte(unit), 0600)
+	if err != nil {
+		return err
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", name+".service")
+}
+
+// controlService starts, stops or uninstalls the systemd unit.
+func controlService(name, action string) error {
+	unit := name + ".service"
+	switch action {
+	case "start", "stop":
+		return systemctl(action, unit)
+	default:
+		if err := systemctl("disable", "--now", unit); err != nil {
+			return err
+		}
+		if err := os.Remove(unitFile(name)); err != nil {
+			return err
+		}
+		return systemctl("daemon-reload")
+	}
+}
+
+// startService is a no-op, systemd needs no registration and collects the
+// log of standard error in the journal.
+func startService(name string) error {
+	return nil
+}
+
+// stopService is a no-op, systemd sees the process exit.
+func stopService() {}
+
+func unitFile(name string) string {
+	return systemdUnitDir + "/" + name + ".service"
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// quoteSystemdArg quotes an argument of ExecStart. % and $ would be expanded
+// by systemd.
+func quoteSystemdArg(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	arg = strings.ReplaceAll(arg, "$", "$$")
+	if len(arg) > 0 && !strings.ContainsAny(arg, " \t\"'\\;") {
+		return arg
+	}
+	arg = strings.ReplaceAll(arg, `\`, `\\`)
+	arg = strings.ReplaceAll(arg, `"`, `\"`)
+	return `"` + arg + `"`
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestQuoteSystemdArg(t *testing.T) {
+	for _, tt := range []struct{ arg, want string }{
+		{"--listen", "--listen"},
+		{":8080", ":8080"},
+		{"", `""`},
+		{"100%", "100%%"},
+		{"$HOME/notify.json", "$$HOME/notify.json"},
+		{"KMP Team", `"KMP Team"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\notify`, `"C:\\notify"`},
+		{"a;b", `"a;b"`},
+	} {
+		if got := quoteSystemdArg(tt.arg); got != tt.want {
+			t.Errorf("quoteSystemdArg(%q) = %s, want %s", tt.arg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+
+package main
+
+import "errors"
+
+var errServiceUnsupported = errors.New("services are supported on Linux (systemd) and Windows only")
+
+func installService(name string, cmdline []string) error {
+	return errServiceUnsupported
+}
+
+func controlService(name, action string) error {
+	return errServiceUnsupported
+}
+
+func startService(name string) error {
+	return nil
+}
+
+func stopService() {}
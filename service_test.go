@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckServiceArgs(t *testing.T) {
+	for _, tt := range []struct {
+		args    []string
+		wantErr string
+	}{
+		{[]string{"serve", "--listen", ":8080"}, ""},
+		{[]string{"--mqtt", "tcp://broker:1883", "-c", "/etc/notify.json"}, ""},
+		{[]string{"-c", "/etc/notify.json", "--daemon"}, ""},
+		{[]string{"--k8s-watch=production"}, ""},
+		{nil, "no service arguments. use service install -- serve|--mqtt <broker> ...|--daemon -c <config file>"},
+		{[]string{"-t", "KMP-Team", "-r", "daemon"}, `"-t KMP-Team -r daemon" is not a long-running mode. use serve, --mqtt, --daemon or --k8s-watch`},
+	} {
+		if err := checkServiceArgs(tt.args); errorString(err) != tt.wantErr {
+			t.Errorf("checkServiceArgs(%q) error = %v, want %s", tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCmdServiceName(t *testing.T) {
+	defer func(name string) { serviceName = name }(serviceName)
+	for _, name := range []string{"", "webex relay", "../relay", `relay"`} {
+		serviceName = name
+		if err := cmdService("install", []string{"serve"}); err == nil || !strings.HasPrefix(err.Error(), "invalid service name") {
+			t.Errorf("cmdService() with name %q error = %v, want invalid service name", name, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Windows service control manager and event log API, see
+// https://learn.microsoft.com/en-us/windows/win32/services/service-programs
+var (
+	advapi32                          = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = advapi32.NewProc("SetServiceStatus")
+	procRegisterEventSourceW          = advapi32.NewProc("RegisterEventSourceW")
+	procReportEventW                  = advapi32.NewProc("ReportEventW")
+)
+
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped        = 1
+	serviceStopPending    = 3
+	serviceRunning        = 4
+	serviceAcceptStop     = 1
+	serviceAcceptShutdown = 4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+
+	errorCallNotImplemented = 120
+
+	eventlogErrorType       = 1
+	eventlogWarningType     = 2
+	eventlogInformationType = 4
+
+	eventLogKey = `HKLM\SYSTEM\CurrentControlSet\Services\EventLog\Application\`
+)
+
+type serviceStatus struct {
+	ServiceType             uint32
+	CurrentState            uint32
+	ControlsAccepted        uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+	CheckPoint              uint32
+	WaitHint                uint32
+}
+
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// the running service. there is only one per process
+var winService struct {
+	name    *uint16
+	handle  uintptr
+	started chan error
+	stopped chan struct{}
+}
+
+// installService registers cmdline as automatically started service and the
+// service name as event log source. The message file of eventcreate.exe
+// shows the log records as they are.
+func installService(name string, cmdline []string) error {
+	var quoted []string
+	for _, arg := range cmdline {
+		quoted = append(quoted, syscall.EscapeArg(arg))
+	}
+	err := runTool("sc.exe", "create", name, "binPath=", strings.Join(quoted, " "), "start=", "auto", "DisplayName=", name)
+	if err != nil {
+		return err
+	}
+	// the description is shown to all users, so it must not contain secrets
+	err = runTool("sc.exe", "description", name, "notify_by_webex_teams "+cmdline[1])
+	if err != nil {
+		return err
+	}
+	err = runTool("reg.exe", "add", eventLogKey+name, "/v", "EventMessageFile", "/t", "REG_EXPAND_SZ", "/d", `%SystemRoot%\System32\EventCreate.exe`, "/f")
+	if err != nil {
+		return err
+	}
+	return runTool("reg.exe", "add", eventLogKey+name, "/v", "TypesSupported", "/t", "REG_DWORD", "/d", "7", "/f")
+}
+
+// controlService starts, stops or uninstalls the service.
+func controlService(name, action string) error {
+	switch action {
+	case "start", "stop":
+		return runTool("sc.exe", action, name)
+	default:
+		// a stopped service fails to stop again
+		runTool("sc.exe", "stop", name)
+		if err := runTool("sc.exe", "delete", name); err != nil {
+			return err
+		}
+		return runTool("reg.exe", "delete", eventLogKey+name, "/f")
+	}
+}
+
+// startService connects to the service control manager and redirects the
+// log to the Windows event log. It returns when the service is running, the
+// mode then runs as usual. A stop request shuts the mode down gracefully,
+// see requestShutdown.
+func startService(name string) error {
+	source, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	h, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(source)))
+	if h == 0 {
+		return fmt.Errorf("registering event source %s: %v", name, err)
+	}
+	err = setupLoggingTo(&eventLogWriter{handle: h}, logFormat, logLevel)
+	if err != nil {
+		return err
+	}
+
+	winService.name = source
+	winService.started = make(chan error, 1)
+	winService.stopped = make(chan struct{})
+	go func() {
+		// the dispatcher blocks the thread until the service stopped
+		runtime.LockOSThread()
+		table := []serviceTableEntry{{name: source, proc: syscall.NewCallback(serviceMain)}, {}}
+		r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0])))
+		if r == 0 {
+			winService.started <- fmt.Errorf("connecting to the service control manager: %v", err)
+		}
+	}()
+	return <-winService.started
+}
+
+func serviceMain(argc, argv uintptr) uintptr {
+	h, _, err := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(winService.name)), syscall.NewCallback(serviceHandler), 0)
+	if h == 0 {
+		winService.started <- fmt.Errorf("registering the service control handler: %v", err)
+		return 0
+	}
+	winService.handle = h
+	setServiceStatus(serviceRunning)
+	slog.Info("service started")
+	winService.started <- nil
+	<-winService.stopped
+	return 0
+}
+
+func serviceHandler(control, eventType, eventData, context uintptr) uintptr {
+	switch control {
+	case serviceControlStop, serviceControlShutdown:
+		setServiceStatus(serviceStopPending)
+		requestShutdown()
+		return 0
+	case serviceControlInterrogate:
+		return 0
+	}
+	return errorCallNotImplemented
+}
+
+// stopService reports the stopped service to the service control manager,
+// after the mode has finished.
+func stopService() {
+	if winService.handle == 0 {
+		return
+	}
+	slog.Info("service stopped")
+	setServiceStatus(serviceStopped)
+	close(winService.stopped)
+}
+
+func setServiceStatus(state uint32) {
+	status := serviceStatus{
+		ServiceType:  serviceWin32OwnProcess,
+		CurrentState: state,
+	}
+	switch state {
+	case serviceRunning:
+		status.ControlsAccepted = serviceAcceptStop | serviceAcceptShutdown
+	case serviceStopPending:
+		status.WaitHint = uint32((shutdownTimeout + 5*time.Second) / time.Millisecond)
+	}
+	procSetServiceStatus.Call(winService.handle, uintptr(unsafe.Pointer(&status)))
+}
+
+// eventLogWriter writes each log record as event. The event type is taken
+// from the level of the record.
+type eventLogWriter struct {
+	handle uintptr
+}
+
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	eventType := eventlogInformationType
+	switch {
+	case bytes.Contains(p, []byte("level=ERROR")) || bytes.Contains(p, []byte(`"level":"ERROR"`)):
+		eventType = eventlogErrorType
+	case bytes.Contains(p, []byte("level=WARN")) || bytes.Contains(p, []byte(`"level":"WARN"`)):
+		eventType = eventlogWarningType
+	}
+	msg, err := syscall.UTF16PtrFromString(strings.ReplaceAll(strings.TrimSpace(string(p)), "\x00", ""))
+	if err != nil {
+		return 0, err
+	}
+	r, _, err := procReportEventW.Call(w.handle, uintptr(eventType), 0, 1, 0, 1, 0, uintptr(unsafe.Pointer(&msg)), 0)
+	if r == 0 {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func runTool(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// severity.go
+//
+// Severity of a notification (flag --severity, "severity" of relayed and
+// spooled notifications). A severity puts an emoji in front of the message,
+// @mentions people and colors the card, so wrapper scripts no longer have
+// to format alerts themselves. The styles can be changed and extended in
+// the config file (-c):
+//
+//	{
+//	  "severities": {
+//	    "critical": { "emoji": "🔥", "color": "attention", "mentions": ["oncall@example.com"] },
+//	    "debug": { "emoji": "🐞" }
+//	  }
+//	}
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// severityStyle is the formatting of a severity. Color is the style of the
+// card container: default, emphasis, good, attention, warning or accent.
+type severityStyle struct {
+	Emoji    string   `json:"emoji"`
+	Color    string   `json:"color"`
+	Mentions []string `json:"mentions"`
+}
+
+var defaultSeverities = map[string]*severityStyle{
+	"info":     {Emoji: "ℹ️", Color: "accent"},
+	"warning":  {Emoji: "⚠️", Color: "warning"},
+	"critical": {Emoji: "🚨", Color: "attention"},
+}
+
+var cardContainerStyles = []string{"default", "emphasis", "good", "attention", "warning", "accent"}
+
+var (
+	severitiesOnce sync.Once
+	severities     map[string]*severityStyle
+	severitiesErr  error
+)
+
+// severityStyles returns the default styles merged with the styles of the
+// config file.
+func severityStyles() (map[string]*severityStyle, error) {
+	severitiesOnce.Do(func() {
+		severities = make(map[string]*severityStyle)
+		for name, style := range defaultSeverities {
+			severities[name] = style
+		}
+		if len(configFile) == 0 {
+			return
+		}
+		c, err := loadConfig(configFile)
+		if err != nil {
+			severitiesErr = err
+			return
+		}
+		for name, style := range c.Severities {
+			severities[strings.ToLower(name)] = style
+		}
+	})
+	return severities, severitiesErr
+}
+
+// lookupSeverity returns the style of the severity name.
+func lookupSeverity(name string) (*severityStyle, error) {
+	styles, err := severityStyles()
+	if err != nil {
+		return nil, err
+	}
+	style, ok := styles[strings.ToLower(name)]
+	if !ok {
+		var names []string
+		for n := range styles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown severity %q. use %s", name, strings.Join(names, ", "))
+	}
+	return style, nil
+}
+
+// applySeverity formats n according to its severity. The severity is
+// cleared, so n is formatted only once.
+func applySeverity(n *notification) error {
+	if len(n.Severity) == 0 {
+		return nil
+	}
+	style, err := lookupSeverity(n.Severity)
+	if err != nil {
+		return err
+	}
+	if len(style.Emoji) > 0 {
+		n.Markdown = style.Emoji + " " + n.Markdown
+	}
+	n.Mentions = append(n.Mentions, style.Mentions...)
+	if len(n.Card) > 0 && len(style.Color) > 0 {
+		n.Card, err = colorCard(n.Card, style.Color)
+		if err != nil {
+			return err
+		}
+	}
+	n.Severity = ""
+	return nil
+}
+
+// colorCard wraps the body of the card attachment into a container of the
+// given style.
+func colorCard(card, color string) (string, error) {
+	known := false
+	for _, s := range cardContainerStyles {
+		known = known || s == color
+	}
+	if !known {
+		return "", fmt.Errorf("unknown severity color %q. use %s", color, strings.Join(cardContainerStyles, ", "))
+	}
+
+	attachment, content, err := decodeCard(card)
+	if err != nil {
+		return "", err
+	}
+	body, ok := content["body"].([]interface{})
+	if !ok {
+		body = []interface{}{}
+	}
+	content["body"] = []interface{}{
+		map[string]interface{}{"type": "Container", "style": color, "bleed": true, "items": body},
+	}
+	data, err := json.Marshal(attachment)
+	if err != nil {
+		return "", err
+	}
+	return normalizeCard(string(data))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testCard = `{"contentType":"application/vnd.microsoft.card.adaptive","content":{"type":"AdaptiveCard","version":"1.3","body":[{"type":"TextBlock","text":"disk full"}]}}`
+
+func TestApplySeverity(t *testing.T) {
+	defer func(file string) { configFile, severitiesOnce = file, sync.Once{} }(configFile)
+	configFile = filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configFile, []byte(`{"severities": {
+		"Critical": {"emoji": "🔥", "color": "attention", "mentions": ["oncall@example.com"]},
+		"debug": {"emoji": "🐞"},
+		"pink": {"color": "pink"}}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	severitiesOnce = sync.Once{}
+
+	for _, tt := range []struct {
+		name         string
+		n            notification
+		wantMarkdown string
+		wantMentions []string
+		wantColor    string
+		wantErr      string
+	}{
+		{name: "none", n: notification{Markdown: "disk full"}, wantMarkdown: "disk full"},
+		{name: "default", n: notification{Markdown: "disk full", Severity: "WARNING"}, wantMarkdown: "⚠️ disk full"},
+		{name: "config", n: notification{Markdown: "disk full", Severity: "critical", Mentions: []string{"alice@example.com"}, Card: testCard},
+			wantMarkdown: "🔥 disk full", wantMentions: []string{"alice@example.com", "oncall@example.com"}, wantColor: `"style":"attention"`},
+		{name: "emoji only", n: notification{Markdown: "trace", Severity: "debug", Card: testCard}, wantMarkdown: "🐞 trace"},
+		{name: "unknown", n: notification{Markdown: "disk full", Severity: "fatal"}, wantErr: "unknown severity \"fatal\". use critical, debug, info, pink, warning"},
+		{name: "unknown color", n: notification{Markdown: "disk full", Severity: "pink", Card: testCard}, wantErr: "unknown severity color \"pink\""},
+	} {
+		err := applySeverity(&tt.n)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: applySeverity() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || tt.n.Markdown != tt.wantMarkdown || !reflect.DeepEqual(tt.n.Mentions, tt.wantMentions) || len(tt.n.Severity) > 0 {
+			t.Errorf("%s: applySeverity() = %v, %q, %v, severity %q, want %q, %v", tt.name, err, tt.n.Markdown, tt.n.Mentions, tt.n.Severity, tt.wantMarkdown, tt.wantMentions)
+		}
+		if len(tt.wantColor) > 0 && !strings.Contains(tt.n.Card, tt.wantColor) {
+			t.Errorf("%s: card = %s, want %s", tt.name, tt.n.Card, tt.wantColor)
+		}
+		if len(tt.wantColor) == 0 && len(tt.n.Card) > 0 && tt.n.Card != testCard {
+			t.Errorf("%s: card changed to %s", tt.name, tt.n.Card)
+		}
+	}
+}
+
+func TestColorCard(t *testing.T) {
+	got, err := colorCard(testCard, "good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, content, err := decodeCard(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := content["body"].([]interface{})
+	container, _ := body[0].(map[string]interface{})
+	if len(body) != 1 || container["type"] != "Container" || container["style"] != "good" || container["bleed"] != true {
+		t.Fatalf("body = %v, want one container of style good", body)
+	}
+	items, _ := container["items"].([]interface{})
+	if item, _ := items[0].(map[string]interface{}); len(items) != 1 || item["text"] != "disk full" {
+		t.Errorf("items = %v, want the body of the card", items)
+	}
+}
@@ -0,0 +1,68 @@
+// shutdown.go
+//
+// Graceful shutdown of the long-running modes (serve, --mqtt, --daemon,
+// --k8s-watch). On SIGINT or SIGTERM, or a stop request of the Windows
+// service control manager, the modes stop accepting new messages and finish
+// the sends in progress, so no message is cut off in the middle of an
+// upload. Afterwards
+// the pending digests (with --digest) and the due messages of the spool
+// (with --spool) are sent and the relay deletes its webhooks (with
+// --deregister-webhooks). If this takes longer than --shutdown-timeout, or a
+// second signal arrives, the process exits right away.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownRequests receives the signals, and the stop request of the Windows
+// service control manager, see requestShutdown.
+var shutdownRequests = make(chan os.Signal, 1)
+
+// shutdownContext returns a context which is canceled when the shutdown is
+// requested.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signal.Notify(shutdownRequests, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-shutdownRequests:
+			// a second signal terminates the process
+			signal.Reset(os.Interrupt, syscall.SIGTERM)
+			slog.Info("shutting down", "signal", sig.String(), "timeout", shutdownTimeout)
+			cancel()
+			time.AfterFunc(shutdownTimeout, func() {
+				slog.Error("shutdown timeout exceeded, sends in progress are aborted")
+				os.Exit(1)
+			})
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// requestShutdown starts the shutdown as if SIGTERM was received.
+func requestShutdown() {
+	select {
+	case shutdownRequests <- syscall.SIGTERM:
+	default:
+	}
+}
+
+// finishShutdown sends the pending digests and the due messages of the
+// spool, after the sends in progress are finished.
+func finishShutdown() {
+	flushDigests()
+	if !useSpool {
+		return
+	}
+	err := flushSpoolDir()
+	if err != nil {
+		slog.Error("flushing the spool failed", "error", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShutdownContext(t *testing.T) {
+	defer func(timeout time.Duration) { shutdownTimeout = timeout }(shutdownTimeout)
+	// the process exits if the shutdown takes longer
+	shutdownTimeout = time.Hour
+
+	ctx, cancel := shutdownContext()
+	defer cancel()
+	requestShutdown()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdownContext() not canceled by requestShutdown()")
+	}
+
+	// without a receiver the requests do not block
+	ctx, cancel = shutdownContext()
+	cancel()
+	<-ctx.Done()
+	requestShutdown()
+	requestShutdown()
+	if n := len(shutdownRequests); n != 1 {
+		t.Errorf("%d pending shutdown requests, want 1", n)
+	}
+	<-shutdownRequests
+}
+
+func TestFinishShutdown(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(w time.Duration, spool bool) { digestWindow, useSpool = w, spool }(digestWindow, useSpool)
+	digestWindow, useSpool = time.Hour, false
+
+	queueDigest(&notification{TeamName: "KMP-Team", RoomName: "Alerts", Markdown: "disk full"})
+	finishShutdown()
+	if messages := fake.Messages(); len(messages) != 1 || messages[0].Markdown != "disk full" {
+		t.Errorf("messages = %+v, want the pending digest", messages)
+	}
+}
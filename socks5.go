@@ -0,0 +1,198 @@
+// socks5.go
+//
+// SOCKS5 proxy dialer (RFC 1928) with optional username/password
+// authentication (RFC 1929). Used for proxy servers given as
+// socks5://[<user>:<password>@]<hostname>:<port>. With socks5:// the target
+// host name is resolved locally, with socks5h:// by the proxy server.
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var socks5Errors = []string{
+	"",
+	"general SOCKS server failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+type socks5Dialer struct {
+	proxyAddr     string
+	username      string
+	password      string
+	remoteResolve bool
+}
+
+func newSOCKS5Dialer(proxyURL *url.URL) *socks5Dialer {
+	d := &socks5Dialer{
+		proxyAddr:     proxyURL.Host,
+		remoteResolve: proxyURL.Scheme == "socks5h",
+	}
+	if len(proxyURL.Port()) == 0 {
+		d.proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "1080")
+	}
+	if proxyURL.User != nil {
+		d.username = proxyURL.User.Username()
+		d.password, _ = proxyURL.User.Password()
+	}
+	return d
+}
+
+// DialContext connects to addr through the SOCKS5 proxy server.
+func (d *socks5Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("socks5: network %s not supported", network)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", d.proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	err = d.handshake(ctx, conn, addr)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("socks5 proxy %s: %v", d.proxyAddr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *socks5Dialer) handshake(ctx context.Context, conn net.Conn, addr string) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	// method negotiation
+	methods := []byte{0x00}
+	if len(d.username) > 0 {
+		methods = []byte{0x00, 0x02}
+	}
+	_, err = conn.Write(append([]byte{0x05, byte(len(methods))}, methods...))
+	if err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(conn, reply)
+	if err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return errors.New("no SOCKS5 server")
+	}
+
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if len(d.username) > 255 || len(d.password) > 255 {
+			return errors.New("username or password too long")
+		}
+		auth := []byte{0x01, byte(len(d.username))}
+		auth = append(auth, d.username...)
+		auth = append(auth, byte(len(d.password)))
+		auth = append(auth, d.password...)
+		_, err = conn.Write(auth)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadFull(conn, reply)
+		if err != nil {
+			return err
+		}
+		if reply[1] != 0x00 {
+			return errors.New("authentication failed")
+		}
+	default:
+		return errors.New("no acceptable authentication method")
+	}
+
+	// connect request
+	req := []byte{0x05, 0x01, 0x00}
+	ip := net.ParseIP(host)
+	if ip == nil && !d.remoteResolve {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+		if len(addrs) == 0 {
+			return fmt.Errorf("no address found for %s", host)
+		}
+		ip = addrs[0].IP
+	}
+	switch {
+	case ip == nil:
+		if len(host) > 255 {
+			return errors.New("host name too long")
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	case ip.To4() != nil:
+		req = append(req, 0x01)
+		req = append(req, ip.To4()...)
+	default:
+		req = append(req, 0x04)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	_, err = conn.Write(req)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	_, err = io.ReadFull(conn, header)
+	if err != nil {
+		return err
+	}
+	if header[1] != 0x00 {
+		if int(header[1]) < len(socks5Errors) {
+			return fmt.Errorf("connect to %s failed: %s", addr, socks5Errors[header[1]])
+		}
+		return fmt.Errorf("connect to %s failed: error code %d", addr, header[1])
+	}
+
+	// skip the bound address
+	var skip int
+	switch header[3] {
+	case 0x01:
+		skip = net.IPv4len
+	case 0x04:
+		skip = net.IPv6len
+	case 0x03:
+		l := make([]byte, 1)
+		_, err = io.ReadFull(conn, l)
+		if err != nil {
+			return err
+		}
+		skip = int(l[0])
+	default:
+		return errors.New("invalid address type in reply")
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
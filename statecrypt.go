@@ -0,0 +1,169 @@
+// statecrypt.go
+//
+// Encryption at rest of the files with message content or state (flag
+// --state-key-file, environment variable NOTIFY_STATE_KEY): the spool files,
+// the state of --dedupe and of serve --pagerduty, and the lines of the
+// journal. With a key, they are encrypted with AES-256-GCM, using the
+// SHA-256 hash of the key as AES key, so any secret string will do:
+//
+//	openssl rand -base64 32 > /etc/notify/state.key
+//	notify_by_webex_teams -t KMP-Team -r Alerts -m "disk full on db-1" --spool --state-key-file /etc/notify/state.key
+//
+// Files written before the key was set are still read and are encrypted
+// when they are written again. The command decrypt prints a file decrypted,
+// e.g. the journal:
+//
+//	notify_by_webex_teams decrypt --state-key-file /etc/notify/state.key ~/.config/notify_by_webex_teams/journal.jsonl
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// prefix of an encrypted file, followed by the nonce and the ciphertext
+	stateMagic = "notify_by_webex_teams encrypted v1\n"
+	// prefix of an encrypted journal line, followed by the base64 of the
+	// nonce and the ciphertext
+	lineMagic = "enc1:"
+)
+
+var (
+	stateAEADOnce sync.Once
+	stateAEAD     cipher.AEAD
+	stateAEADErr  error
+)
+
+// stateCipher returns the cipher of --state-key-file or NOTIFY_STATE_KEY,
+// nil without key.
+func stateCipher() (cipher.AEAD, error) {
+	stateAEADOnce.Do(func() {
+		key := os.Getenv("NOTIFY_STATE_KEY")
+		if len(stateKeyFile) > 0 {
+			data, err := os.ReadFile(stateKeyFile)
+			if err != nil {
+				stateAEADErr = err
+				return
+			}
+			key = string(data)
+		}
+		key = strings.TrimSpace(key)
+		if len(key) == 0 {
+			if len(stateKeyFile) > 0 {
+				stateAEADErr = fmt.Errorf("empty key in %s", stateKeyFile)
+			}
+			return
+		}
+		addSecret(key)
+		sum := sha256.Sum256([]byte(key))
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			stateAEADErr = err
+			return
+		}
+		stateAEAD, stateAEADErr = cipher.NewGCM(block)
+	})
+	return stateAEAD, stateAEADErr
+}
+
+// sealState returns data encrypted if a key is set, else data.
+func sealState(data []byte) ([]byte, error) {
+	aead, err := stateCipher()
+	if aead == nil || err != nil {
+		return data, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte(stateMagic), nonce...)
+	return aead.Seal(out, nonce, data, nil), nil
+}
+
+// openState returns data decrypted if it is encrypted, else data.
+func openState(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(stateMagic)) {
+		return data, nil
+	}
+	aead, err := stateCipher()
+	if err != nil {
+		return nil, err
+	}
+	if aead == nil {
+		return nil, errors.New("encrypted file. use flag --state-key-file or NOTIFY_STATE_KEY")
+	}
+	data = data[len(stateMagic):]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("decrypting failed, wrong key or modified file")
+	}
+	return plain, nil
+}
+
+// sealLine returns the line of a line-based file, e.g. the journal,
+// encrypted if a key is set.
+func sealLine(line []byte) ([]byte, error) {
+	aead, err := stateCipher()
+	if aead == nil || err != nil {
+		return line, err
+	}
+	sealed, err := sealState(line)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(lineMagic + base64.StdEncoding.EncodeToString(sealed[len(stateMagic):])), nil
+}
+
+// openLine returns the line decrypted if it is encrypted, else line.
+func openLine(line []byte) ([]byte, error) {
+	if !bytes.HasPrefix(line, []byte(lineMagic)) {
+		return line, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(line[len(lineMagic):]))
+	if err != nil {
+		return nil, err
+	}
+	return openState(append([]byte(stateMagic), sealed...))
+}
+
+func cmdDecrypt(action string, args []string) error {
+	if len(args) != 1 {
+		return errors.New("use decrypt <file>")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(data, []byte(stateMagic)) {
+		plain, err := openState(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		_, err = os.Stdout.Write(plain)
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for i := 1; scanner.Scan(); i++ {
+		line, err := openLine(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", args[0], i, err)
+		}
+		fmt.Printf("%s\n", line)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useStateKey sets the key of the state encryption for the test, "" for no
+// encryption.
+func useStateKey(t *testing.T, key string) {
+	reset := func() {
+		stateAEADOnce = sync.Once{}
+		stateAEAD, stateAEADErr = nil, nil
+	}
+	reset()
+	stateKeyFile = ""
+	t.Setenv("NOTIFY_STATE_KEY", key)
+	t.Cleanup(reset)
+}
+
+func TestSealState(t *testing.T) {
+	plain := []byte(`{"markdown":"disk full on db-1"}`)
+
+	useStateKey(t, "")
+	data, err := sealState(plain)
+	if err != nil || !bytes.Equal(data, plain) {
+		t.Errorf("sealState() without key = %q, %v, want unchanged", data, err)
+	}
+
+	useStateKey(t, "correct horse battery staple")
+	sealed, err := sealState(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(sealed, []byte(stateMagic)) || bytes.Contains(sealed, []byte("disk full")) {
+		t.Errorf("sealState() = %q, want encrypted", sealed)
+	}
+	again, _ := sealState(plain)
+	if bytes.Equal(sealed, again) {
+		t.Error("sealState() twice = same ciphertext, want a new nonce")
+	}
+	if got, err := openState(sealed); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("openState() = %q, %v, want %q", got, err, plain)
+	}
+	// files written before the key was set
+	if got, err := openState(plain); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("openState() of plain data = %q, %v", got, err)
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	for name, data := range map[string][]byte{
+		"modified":  tampered,
+		"truncated": sealed[:len(stateMagic)+4],
+	} {
+		if _, err := openState(data); err == nil {
+			t.Errorf("openState() of %s file: err = nil", name)
+		}
+	}
+
+	useStateKey(t, "wrong key")
+	if _, err := openState(sealed); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Errorf("openState() with wrong key: err = %v", err)
+	}
+	useStateKey(t, "")
+	if _, err := openState(sealed); err == nil || !strings.Contains(err.Error(), "--state-key-file") {
+		t.Errorf("openState() without key: err = %v", err)
+	}
+}
+
+func TestSealLine(t *testing.T) {
+	line := []byte(`{"room":"Alerts","markdown":"disk full"}`)
+	useStateKey(t, "correct horse battery staple")
+	sealed, err := sealLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(sealed, []byte(lineMagic)) || bytes.ContainsAny(sealed, "\n{") {
+		t.Errorf("sealLine() = %q, want one encrypted line", sealed)
+	}
+	if got, err := openLine(sealed); err != nil || !bytes.Equal(got, line) {
+		t.Errorf("openLine() = %q, %v, want %q", got, err, line)
+	}
+	if got, err := openLine(line); err != nil || !bytes.Equal(got, line) {
+		t.Errorf("openLine() of plain line = %q, %v", got, err)
+	}
+	if _, err := openLine([]byte(lineMagic + "not base64!")); err == nil {
+		t.Error("openLine() of invalid line: err = nil")
+	}
+}
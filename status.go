@@ -0,0 +1,225 @@
+// status.go
+//
+// Live status message (command status). The lines of standard input, or of
+// the output of "status -- <command> [<args>]", update one message in place
+// instead of posting a message per line, e.g. the progress of a backup:
+//
+//	**nightly backup**
+//	⏳ 42% 12.3 GiB/29.1 GiB
+//
+// The message is edited at most every --update-interval with the latest
+// line. At the end of the input, or of the command, it shows ✅ or ❌ with
+// the duration. If Webex refuses to edit the message any more, a new status
+// message is posted and updated instead.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// statusMessage is the message updated by status.
+type statusMessage struct {
+	client *webex.Client
+	roomID string
+	id     string
+	title  string
+	// markdown of the last edit
+	sent string
+}
+
+func cmdStatus(action string, args []string) error {
+	if useStdIn {
+		return errors.New("-i is not supported with status, it reads standard input itself")
+	}
+	if updateInterval <= 0 {
+		return errors.New("--update-interval must be positive")
+	}
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	title := strings.TrimSpace(markdownMsg)
+	if len(title) == 0 {
+		title = "status"
+	}
+	s := &statusMessage{client: client, title: title}
+
+	input := io.Reader(os.Stdin)
+	var cmd *exec.Cmd
+	if len(args) > 0 {
+		pr, pw := io.Pipe()
+		cmd = exec.Command(args[0], args[1:]...)
+		cmd.Stdout = io.MultiWriter(os.Stdout, pw)
+		cmd.Stderr = io.MultiWriter(os.Stderr, pw)
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		go func() {
+			pw.CloseWithError(cmd.Wait())
+		}()
+		input = pr
+	}
+
+	ctx, stop := shutdownContext()
+	defer stop()
+	if err := s.post("⏳ started"); err != nil {
+		return err
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(input)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		// progress output often ends its lines with CR only
+		scanner.Split(scanLinesCR)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); len(line) > 0 {
+				lines <- line
+			}
+		}
+	}()
+
+	start := time.Now()
+	ticker := time.NewTicker(updateInterval)
+	defer ticker.Stop()
+	latest, interrupted := "", false
+loop:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				break loop
+			}
+			latest = line
+		case <-ticker.C:
+			if len(latest) > 0 {
+				s.update("⏳ " + latest)
+			}
+		case <-ctx.Done():
+			interrupted = true
+			if cmd != nil {
+				if err := cmd.Process.Signal(os.Interrupt); err != nil {
+					cmd.Process.Kill()
+				}
+			}
+			break loop
+		}
+	}
+
+	duration := time.Since(start).Round(time.Second)
+	exitCode := 0
+	if cmd != nil && !interrupted {
+		// the pipe is closed after Wait
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	var final string
+	switch {
+	case interrupted:
+		final = fmt.Sprintf("⚠️ interrupted after %s", duration)
+	case exitCode != 0:
+		final = fmt.Sprintf("❌ failed with exit code %d after %s", exitCode, duration)
+	default:
+		final = fmt.Sprintf("✅ done in %s", duration)
+	}
+	if len(latest) > 0 {
+		final = latest + "\n" + final
+	}
+	if err := s.update(final); err != nil {
+		return err
+	}
+	if interrupted {
+		return errors.New("interrupted")
+	}
+	if exitCode != 0 {
+		os.Exit(max(exitCode, 1))
+	}
+	return nil
+}
+
+// post posts a new status message with the text.
+func (s *statusMessage) post(text string) error {
+	ctx, cancel := requestContext()
+	defer cancel()
+	m := &webex.MessageRequest{Markdown: s.markdown(text)}
+	if len(emailAddr) > 0 {
+		m.ToPersonEmail = emailAddr
+	} else {
+		if len(teamName) == 0 {
+			return errors.New("no recipient. use flag -t or flag -D")
+		}
+		roomID, err := lookupRoomID(ctx, teamName, roomName)
+		if err != nil {
+			return err
+		}
+		m.RoomID = roomID
+	}
+	msg, err := s.client.CreateMessage(ctx, m)
+	if err != nil {
+		return err
+	}
+	s.id, s.roomID, s.sent = msg.ID, msg.RoomID, m.Markdown
+	slog.Info("status message posted", "messageID", msg.ID)
+	return nil
+}
+
+// update edits the status message to show text. Failures are logged, as the
+// next update may succeed.
+func (s *statusMessage) update(text string) error {
+	markdown := s.markdown(text)
+	if markdown == s.sent {
+		return nil
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+	_, err := s.client.EditMessage(ctx, s.id, markdown)
+	var apiErr *webex.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 && apiErr.StatusCode != http.StatusTooManyRequests {
+		// e.g. the edit limit of the message is reached
+		slog.Warn("editing the status message failed, posting a new one", "error", err)
+		m, err := s.client.CreateMessage(ctx, &webex.MessageRequest{RoomID: s.roomID, Markdown: markdown})
+		if err != nil {
+			slog.Error("posting the status message failed", "error", err)
+			return err
+		}
+		s.id, s.sent = m.ID, markdown
+		return nil
+	}
+	if err != nil {
+		slog.Warn("editing the status message failed", "error", err)
+		return err
+	}
+	s.sent = markdown
+	return nil
+}
+
+func (s *statusMessage) markdown(text string) string {
+	markdown := "**" + s.title + "**\n" + text
+	if !noEmoji {
+		markdown = expandEmoji(markdown)
+	}
+	return markdown
+}
+
+// scanLinesCR is bufio.ScanLines, which also ends a line at a single CR.
+func scanLinesCR(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
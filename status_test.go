@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanLinesCR(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader("10%\r20%\r30%\ncopying\r\ndone"))
+	scanner.Split(scanLinesCR)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if want := []string{"10%", "20%", "30%", "copying", "", "done"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestStatusMessage(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(team, room, email string) { teamName, roomName, emailAddr = team, room, email }(teamName, roomName, emailAddr)
+	teamName, roomName, emailAddr = "KMP-Team", "Backups", ""
+	client, err := webexClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &statusMessage{client: client, title: "nightly backup"}
+	if err := s.post(":hourglass_flowing_sand: started"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.update("⏳ 42%"); err != nil {
+		t.Fatal(err)
+	}
+	messages := fake.Messages()
+	if len(messages) != 1 || messages[0].ID != s.id || messages[0].Markdown != "**nightly backup**\n⏳ 42%" {
+		t.Fatalf("messages = %+v, want the edited status message", messages)
+	}
+	// an unchanged status is not sent again
+	fake.Fail(1, http.StatusInternalServerError)
+	if err := s.update("⏳ 42%"); err != nil {
+		t.Errorf("update() of an unchanged status = %v", err)
+	}
+	// a refused edit posts a new status message
+	fake.Fail(1, http.StatusBadRequest)
+	first := s.id
+	if err := s.update("⏳ 50%"); err != nil {
+		t.Fatal(err)
+	}
+	messages = fake.Messages()
+	if len(messages) != 2 || s.id == first || messages[1].ID != s.id || messages[1].Markdown != "**nightly backup**\n⏳ 50%" {
+		t.Errorf("messages = %+v, want a new status message", messages)
+	}
+
+	teamName = ""
+	if err := (&statusMessage{client: client, title: "x"}).post("started"); err == nil || !strings.HasPrefix(err.Error(), "no recipient") {
+		t.Errorf("post() without recipient error = %v", err)
+	}
+}
+
+func TestCmdStatus(t *testing.T) {
+	fake := useFakeWebex(t)
+	fake.AddTeam("KMP-Team")
+	defer func(team, room, msg string, interval time.Duration, stdin *os.File) {
+		teamName, roomName, markdownMsg, updateInterval, os.Stdin = team, room, msg, interval, stdin
+	}(teamName, roomName, markdownMsg, updateInterval, os.Stdin)
+	teamName, roomName, markdownMsg, updateInterval = "KMP-Team", "Backups", "nightly backup", time.Hour
+
+	input := filepath.Join(t.TempDir(), "input")
+	if err := os.WriteFile(input, []byte("10%\r50%\r100%\n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	os.Stdin = f
+	if err := cmdStatus("", nil); err != nil {
+		t.Fatal(err)
+	}
+	messages := fake.Messages()
+	if len(messages) != 1 || !strings.HasPrefix(messages[0].Markdown, "**nightly backup**\n100%\n✅ done in ") {
+		t.Errorf("messages = %+v, want the final status", messages)
+	}
+
+	updateInterval = 0
+	if err := cmdStatus("", nil); err == nil || err.Error() != "--update-interval must be positive" {
+		t.Errorf("cmdStatus() with --update-interval 0 error = %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+// table.go
+//
+// Tables (flag --table). A CSV or TSV file, or standard input with
+// "--table -", is appended to the message as aligned table. Webex does not
+// render markdown pipe tables, so the table is a code block in which the
+// columns line up:
+//
+//	host  | cpu | disk
+//	------+-----+-----
+//	web1  | 12% | 81%
+//
+// The first row is the header. Cells longer than --table-max-width are cut,
+// rows after --table-max-rows, or beyond the length of a Webex message, are
+// left out and, if the table was read from a file, the file is sent with the
+// message.
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// appendTable appends the table of file ("-" is standard input) to the
+// message.
+func appendTable(file string) error {
+	var data []byte
+	var err error
+	if file == "-" {
+		if useStdIn {
+			return errors.New("--table - and -i both read standard input")
+		}
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	rows, err := parseTable(data, strings.EqualFold(filepath.Ext(file), ".tsv"))
+	if err != nil {
+		return fmt.Errorf("--table %s: %w", file, err)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("--table %s: no rows", file)
+	}
+
+	prefix := markdownMsg
+	if len(prefix) > 0 && !strings.HasSuffix(prefix, "\n") {
+		prefix += "\n"
+	}
+	table, truncated := formatTable(rows, tableMaxWidth, tableMaxRows, maxMarkdownLength-codeReserve-len(prefix))
+	markdownMsg = prefix + table
+	if truncated && file != "-" {
+		uploadFiles = append(uploadFiles, file)
+	}
+	return nil
+}
+
+// parseTable parses CSV, or TSV if tsv is set or the first line has tabs
+// but no commas.
+func parseTable(data []byte, tsv bool) ([][]string, error) {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	first, _, _ := strings.Cut(text, "\n")
+	r := csv.NewReader(strings.NewReader(text))
+	if tsv || (strings.Contains(first, "\t") && !strings.Contains(first, ",")) {
+		r.Comma = '\t'
+		r.LazyQuotes = true
+	}
+	r.FieldsPerRecord = -1
+	return r.ReadAll()
+}
+
+// formatTable returns rows as code block of at most limit bytes with the
+// columns aligned, and whether rows were left out.
+func formatTable(rows [][]string, maxWidth, maxRows, limit int) (string, bool) {
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	cells := make([][]string, len(rows))
+	widths := make([]int, columns)
+	for i, row := range rows {
+		cells[i] = make([]string, columns)
+		for j := range cells[i] {
+			if j < len(row) {
+				cells[i][j] = tableCell(row[j], maxWidth)
+			}
+			widths[j] = max(widths[j], utf8.RuneCountInString(cells[i][j]))
+		}
+	}
+
+	line := func(row []string) string {
+		var b strings.Builder
+		for j, cell := range row {
+			if j > 0 {
+				b.WriteString(" | ")
+			}
+			b.WriteString(cell)
+			if j < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)))
+			}
+		}
+		return strings.TrimRight(b.String(), " ") + "\n"
+	}
+	separator := make([]string, columns)
+	for j, w := range widths {
+		separator[j] = strings.Repeat("-", w)
+	}
+
+	var b strings.Builder
+	b.WriteString(line(cells[0]))
+	b.WriteString(strings.ReplaceAll(line(separator), " | ", "-+-"))
+	// the fences and the truncation note
+	limit -= 2*4 + 40
+	shown := 0
+	for _, row := range cells[1:] {
+		l := line(row)
+		if shown == maxRows || b.Len()+len(l) > limit {
+			break
+		}
+		b.WriteString(l)
+		shown++
+	}
+	truncated := shown < len(cells)-1
+	if truncated {
+		fmt.Fprintf(&b, "… %d more rows\n", len(cells)-1-shown)
+	}
+	return "```\n" + b.String() + "```\n", truncated
+}
+
+// tableCell returns the cell on one line of at most maxWidth characters.
+func tableCell(cell string, maxWidth int) string {
+	cell = strings.Join(strings.Fields(cell), " ")
+	// a fence within the code block would end it
+	cell = strings.ReplaceAll(cell, "```", "'''")
+	if maxWidth > 0 && utf8.RuneCountInString(cell) > maxWidth {
+		cell = string([]rune(cell)[:max(maxWidth-1, 0)]) + "…"
+	}
+	return cell
+}
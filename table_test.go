@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	for _, tt := range []struct {
+		data string
+		tsv  bool
+		want string
+	}{
+		{"host,status\nweb-1,up\n", false, "[[host status] [web-1 up]]"},
+		{"\ufeffhost,status\n\"web-1, eu\",up\n", false, "[[host status] [web-1, eu up]]"},
+		{"host\tstatus\nweb-1\tup \"ok\"\n", false, `[[host status] [web-1 up "ok"]]`},
+		{"host\tstatus,code\nweb-1\tup,200\n", true, "[[host status,code] [web-1 up,200]]"},
+		{"host,status,note\nweb-1,up\n", false, "[[host status note] [web-1 up]]"},
+	} {
+		rows, err := parseTable([]byte(tt.data), tt.tsv)
+		if got := fmt.Sprint(rows); err != nil || got != tt.want {
+			t.Errorf("parseTable(%q, %v) = %s, %v, want %s", tt.data, tt.tsv, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormatTable(t *testing.T) {
+	rows := [][]string{{"host", "status"}, {"web-1", "up"}, {"db-primary", "down"}, {"cache"}}
+	want := "```\n" +
+		"host       | status\n" +
+		"-----------+-------\n" +
+		"web-1      | up\n" +
+		"db-primary | down\n" +
+		"cache      |\n" +
+		"```\n"
+	if got, truncated := formatTable(rows, 0, 50, 7000); got != want || truncated {
+		t.Errorf("formatTable() = %q, %v, want %q", got, truncated, want)
+	}
+
+	got, truncated := formatTable(rows, 0, 1, 7000)
+	if !truncated || !strings.Contains(got, "web-1      | up\n… 2 more rows\n```") {
+		t.Errorf("formatTable() with 1 row = %q, %v", got, truncated)
+	}
+
+	var many [][]string
+	for i := 0; i < 1000; i++ {
+		many = append(many, []string{fmt.Sprint(i), "some value"})
+	}
+	got, truncated = formatTable(many, 0, 1000, 2000)
+	if !truncated || len(got) > 2000 || !strings.HasSuffix(got, "more rows\n```\n") {
+		t.Errorf("formatTable() of 1000 rows = %d bytes, %v, want at most 2000 bytes", len(got), truncated)
+	}
+}
+
+func TestTableCell(t *testing.T) {
+	for _, tt := range []struct {
+		cell     string
+		maxWidth int
+		want     string
+	}{
+		{"  web-1 \n eu  ", 0, "web-1 eu"},
+		{"a ```fence```", 0, "a '''fence'''"},
+		{"Überwachung", 5, "Über…"},
+		{"web-1", 5, "web-1"},
+		{"web-1", 1, "…"},
+	} {
+		if got := tableCell(tt.cell, tt.maxWidth); got != tt.want {
+			t.Errorf("tableCell(%q, %d) = %q, want %q", tt.cell, tt.maxWidth, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// template.go
+//
+// Named message templates (flag --template, "template" of relayed
+// notifications). The templates are files in the templates directory of
+// the config file (-c), <name>.yaml, <name>.yml or <name>.json, with the
+// message as Go text/template and defaults for the recipient, severity,
+// mentions and card:
+//
+//	team: KMP-Team
+//	room: Deployments
+//	severity: info
+//	card: cards/deploy.yaml
+//	message: |
+//	  ✅ **{{.Vars.app}}** {{.Vars.version}} deployed to {{.Vars.env}}
+//	  {{.Message}}
+//
+// The values of --var key=value are available as .Vars, the message of -m
+// or -i as .Message. The card file, relative to the templates directory and
+// best kept in a subdirectory, is rendered the same way, {{json .Vars.app}}
+// quotes a value for JSON. The time functions are in templatetime.go.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// messageTemplate is a template file.
+type messageTemplate struct {
+	Team     string   `json:"team"`
+	Room     string   `json:"room"`
+	Email    string   `json:"email"`
+	Severity string   `json:"severity"`
+	Mentions []string `json:"mentions"`
+	Card     string   `json:"card"`
+	Message  string   `json:"message"`
+}
+
+// templateData is passed to the message and card of a template.
+type templateData struct {
+	Message  string
+	Vars     map[string]string
+	Time     time.Time
+	Hostname string
+	// Run is the result of the command of run, see run.go
+	Run *runResult
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// templatesDir returns the templates directory of the config file. A
+// relative directory is relative to the config file.
+func templatesDir() (string, error) {
+	if len(configFile) == 0 {
+		return "", errors.New("no config file with a templates directory. use flag -c")
+	}
+	c, err := loadConfig(configFile)
+	if err != nil {
+		return "", err
+	}
+	if len(c.Templates) == 0 {
+		return "", fmt.Errorf("no templates directory in config file %s", configFile)
+	}
+	if filepath.IsAbs(c.Templates) {
+		return c.Templates, nil
+	}
+	return filepath.Join(filepath.Dir(configFile), c.Templates), nil
+}
+
+// loadTemplate reads the template name from dir.
+func loadTemplate(dir, name string) (*messageTemplate, error) {
+	if !templateNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid template name %q", name)
+	}
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		file := filepath.Join(dir, name+ext)
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ext != ".json" {
+			v, err := parseYAML(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			data, err = json.Marshal(yamlToJSON(v))
+			if err != nil {
+				return nil, err
+			}
+		}
+		var t messageTemplate
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&t); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		return &t, nil
+	}
+	return nil, fmt.Errorf("template %q not found in %s", name, dir)
+}
+
+// applyTemplate renders the template n.Template into n. The message of n
+// becomes .Message, the fields of n which are set take precedence over the
+// defaults of the template.
+func applyTemplate(n *notification) error {
+	dir, err := templatesDir()
+	if err != nil {
+		return err
+	}
+	t, err := loadTemplate(dir, n.Template)
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	data := &templateData{Message: n.Markdown, Vars: n.Vars, Time: time.Now(), Hostname: hostname}
+	if data.Vars == nil {
+		data.Vars = make(map[string]string)
+	}
+
+	n.Markdown, err = renderTemplate(n.Template, expandEnv(t.Message, nil), data)
+	if err != nil {
+		return err
+	}
+	if len(n.TeamName) == 0 && len(n.Email) == 0 {
+		n.TeamName, n.Email = t.Team, t.Email
+	}
+	if len(n.RoomName) == 0 {
+		n.RoomName = t.Room
+	}
+	if len(n.Severity) == 0 {
+		n.Severity = t.Severity
+	}
+	if len(n.Mentions) == 0 {
+		n.Mentions = t.Mentions
+	}
+	if len(n.Card) == 0 && len(t.Card) > 0 {
+		n.Card, err = renderTemplateCard(filepath.Join(dir, t.Card), data)
+		if err != nil {
+			return err
+		}
+	}
+	// a spooled notification must not be rendered again
+	n.Template, n.Vars = "", nil
+	return nil
+}
+
+func renderTemplate(name, text string, data *templateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// renderTemplateCard renders the card file of a template, a JSON card or a
+// YAML card description.
+func renderTemplateCard(file string, data *templateData) (string, error) {
+	text, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	quote := jsonEscape
+	if isCardYAML(file) {
+		quote = nil
+	}
+	card, err := renderTemplate(filepath.Base(file), expandEnv(string(text), quote), data)
+	if err != nil {
+		return "", err
+	}
+	if isCardYAML(file) {
+		card, err = compileCardYAML(card)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return normalizeCard(card)
+}
+
+// parseVars parses the key=value pairs of flag --var.
+func parseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for _, pair := range pairs {
+		i := strings.Index(pair, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid --var %q. use key=value", pair)
+		}
+		vars[pair[:i]] = pair[i+1:]
+	}
+	return vars, nil
+}
+
+// flagGiven reports whether one of the flag names is set on the command line.
+func flagGiven(names ...string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		for _, name := range names {
+			given = given || f.Name == name
+		}
+	})
+	return given
+}
+
+func cmdTemplates(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	dir, err := templatesDir()
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	w := newTable("NAME", "TEAM", "ROOM", "EMAIL", "SEVERITY", "CARD")
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ext)
+		t, err := loadTemplate(dir, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", name, t.Team, t.Room, t.Email, t.Severity, t.Card)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVars(t *testing.T) {
+	for _, tt := range []struct {
+		pairs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{nil, map[string]string{}, false},
+		{[]string{"app=shop", "url=https://x/?a=b", "empty="}, map[string]string{"app": "shop", "url": "https://x/?a=b", "empty": ""}, false},
+		{[]string{"app"}, nil, true},
+		{[]string{"=shop"}, nil, true},
+	} {
+		got, err := parseVars(tt.pairs)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("parseVars(%q) = %v, %v, want %v, error %v", tt.pairs, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// writeTemplates writes a config file with a templates directory and the
+// given template files and makes it the config file of the test.
+func writeTemplates(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, text := range files {
+		file := filepath.Join(dir, "templates", name)
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(text), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(file, []byte(`{"templates": "templates"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := configFile
+	configFile = file
+	t.Cleanup(func() { configFile = old })
+	return filepath.Join(dir, "templates")
+}
+
+func TestLoadTemplate(t *testing.T) {
+	dir := writeTemplates(t, map[string]string{
+		"deploy.yaml": "team: KMP-Team\nroom: Deployments\nmentions: [alice@example.com]\nmessage: deployed\n",
+		"backup.json": `{"email": "ops@example.com", "message": "backup done"}`,
+		"typo.yml":    "team: KMP-Team\nrom: Deployments\n",
+	})
+	for _, tt := range []struct {
+		name    string
+		want    *messageTemplate
+		wantErr string
+	}{
+		{name: "deploy", want: &messageTemplate{Team: "KMP-Team", Room: "Deployments", Mentions: []string{"alice@example.com"}, Message: "deployed"}},
+		{name: "backup", want: &messageTemplate{Email: "ops@example.com", Message: "backup done"}},
+		{name: "typo", wantErr: `unknown field "rom"`},
+		{name: "missing", wantErr: `template "missing" not found`},
+		{name: "../config", wantErr: `invalid template name "../config"`},
+	} {
+		got, err := loadTemplate(dir, tt.name)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadTemplate(%q) error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("loadTemplate(%q) = %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestTemplatesDir(t *testing.T) {
+	defer func(file string) { configFile = file }(configFile)
+	configFile = ""
+	if _, err := templatesDir(); err == nil || !strings.Contains(err.Error(), "use flag -c") {
+		t.Errorf("templatesDir() without config error = %v, want use flag -c", err)
+	}
+	configFile = filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, []byte(`{}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := templatesDir(); err == nil || !strings.Contains(err.Error(), "no templates directory") {
+		t.Errorf("templatesDir() error = %v, want no templates directory", err)
+	}
+}
+
+func TestApplyTemplate(t *testing.T) {
+	writeTemplates(t, map[string]string{
+		"deploy.yaml":       "team: KMP-Team\nroom: Deployments\nseverity: info\ncard: cards/deploy.json\nmessage: |\n  deployed {{.Vars.app}} {{.Vars.version}}\n  {{.Message}}\n",
+		"cards/deploy.json": `{"type": "AdaptiveCard", "version": "1.3", "body": [{"type": "TextBlock", "text": {{json .Vars.app}}}]}`,
+		"broken.yaml":       "message: \"{{.Vars.app\"\n",
+	})
+
+	n := &notification{Template: "deploy", Markdown: "all green", Vars: map[string]string{"app": `shop "v2"`, "version": "2.1"}}
+	if err := applyTemplate(n); err != nil {
+		t.Fatal(err)
+	}
+	if want := "deployed shop \"v2\" 2.1\nall green\n"; n.Markdown != want {
+		t.Errorf("applyTemplate() markdown = %q, want %q", n.Markdown, want)
+	}
+	if n.TeamName != "KMP-Team" || n.RoomName != "Deployments" || n.Severity != "info" {
+		t.Errorf("applyTemplate() = %+v, want the defaults of the template", n)
+	}
+	if !strings.Contains(n.Card, `"text":"shop \"v2\""`) || !strings.Contains(n.Card, cardContentType) {
+		t.Errorf("applyTemplate() card = %s, want the rendered card", n.Card)
+	}
+	if len(n.Template) > 0 || n.Vars != nil {
+		t.Errorf("applyTemplate() left template %q and vars %v", n.Template, n.Vars)
+	}
+
+	n = &notification{Template: "deploy", Email: "alice@example.com", Severity: "critical", Card: testCard}
+	if err := applyTemplate(n); err != nil {
+		t.Fatal(err)
+	}
+	if len(n.TeamName) > 0 || n.RoomName != "Deployments" || n.Severity != "critical" || n.Card != testCard {
+		t.Errorf("applyTemplate() = %+v, want the fields set to take precedence", n)
+	}
+	if want := "deployed  \n\n"; n.Markdown != want {
+		t.Errorf("applyTemplate() without vars markdown = %q, want %q", n.Markdown, want)
+	}
+
+	if err := applyTemplate(&notification{Template: "broken"}); err == nil {
+		t.Error("applyTemplate() of a broken template succeeded")
+	}
+}
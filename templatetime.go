@@ -0,0 +1,173 @@
+// templatetime.go
+//
+// Time functions of the templates (see template.go), also of --mqtt-template,
+// the daemon sources and the forward template, e.g. for the times and
+// durations of alerts, which usually arrive as strings in .Vars:
+//
+//	message: |
+//	  🔥 {{.Vars.alert}} since {{.Vars.startsAt | inZone "Europe/Vienna" | date "15:04 MST"}} ({{ago .Vars.startsAt}})
+//	  resolved after {{since .Vars.startsAt | duration}}, {{localDate now}}
+//
+// The times may be time.Time values, e.g. .Time, RFC3339 strings, dates with
+// or without time of day, or Unix seconds. The durations may be Go durations
+// like 90s, seconds or values returned by since.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// templateTimeLayouts are the layouts of the times parsed by toTime.
+var templateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// templateTimeFuncs are the time functions of the templates.
+var templateTimeFuncs = map[string]interface{}{
+	"now":    time.Now,
+	"toTime": toTime,
+	"inZone": func(zone string, v interface{}) (time.Time, error) {
+		t, err := toTime(v)
+		if err != nil {
+			return t, err
+		}
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return t, err
+		}
+		return t.In(loc), nil
+	},
+	"date": func(layout string, v interface{}) (string, error) {
+		t, err := toTime(v)
+		return t.Format(layout), err
+	},
+	"rfc3339": func(v interface{}) (string, error) {
+		t, err := toTime(v)
+		return t.Format(time.RFC3339), err
+	},
+	"localDate": func(v interface{}) (string, error) {
+		t, err := toTime(v)
+		return formatTime(t), err
+	},
+	"since": func(v interface{}) (time.Duration, error) {
+		t, err := toTime(v)
+		return time.Since(t), err
+	},
+	"ago": func(v interface{}) (string, error) {
+		t, err := toTime(v)
+		if err != nil {
+			return "", err
+		}
+		d := time.Since(t)
+		if d < 0 {
+			return trf("in %s", shortDuration(-d, 1)), nil
+		}
+		return trf("%s ago", shortDuration(d, 1)), nil
+	},
+	"duration": func(v interface{}) (string, error) {
+		d, err := toDuration(v)
+		return shortDuration(d, 2), err
+	},
+}
+
+func init() {
+	for name, f := range templateTimeFuncs {
+		templateFuncs[name] = f
+	}
+}
+
+// toTime converts the value v of a template to a time.
+func toTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case int:
+		return time.Unix(int64(v), 0), nil
+	case int64:
+		return time.Unix(v, 0), nil
+	case float64:
+		return time.Unix(0, int64(v*1e9)), nil
+	case json.Number:
+		return toTime(v.String())
+	case string:
+		s := strings.TrimSpace(v)
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return time.Unix(0, int64(f*1e9)), nil
+		}
+		for _, layout := range templateTimeLayouts {
+			if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid time %q. use RFC3339, 2006-01-02 15:04:05 or Unix seconds", v)
+	}
+	return time.Time{}, fmt.Errorf("invalid time %v", v)
+}
+
+// toDuration converts the value v of a template to a duration.
+func toDuration(v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case time.Duration:
+		return v, nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case json.Number:
+		return toDuration(v.String())
+	case string:
+		s := strings.TrimSpace(v)
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return time.Duration(f * float64(time.Second)), nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q. use e.g. 90s, 1h30m or seconds", v)
+		}
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid duration %v", v)
+}
+
+// shortDuration returns d truncated to at most units units, e.g. "1h 30m"
+// for 2 units or "3m" for 1 unit. Days are the largest unit.
+func shortDuration(d time.Duration, units int) string {
+	if d < 0 {
+		return "-" + shortDuration(-d, units)
+	}
+	if d < time.Second {
+		return "0s"
+	}
+	var parts []string
+	for _, u := range []struct {
+		d    time.Duration
+		name string
+	}{{24 * time.Hour, "d"}, {time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}} {
+		if n := d / u.d; n > 0 || len(parts) > 0 {
+			if n > 0 {
+				parts = append(parts, fmt.Sprintf("%d%s", n, u.name))
+			}
+			d -= n * u.d
+			if units--; units == 0 {
+				break
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
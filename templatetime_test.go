@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestToTime(t *testing.T) {
+	want := time.Date(2026, 10, 15, 8, 0, 0, 0, time.UTC)
+	for _, v := range []interface{}{
+		want, &want, int(want.Unix()), want.Unix(), float64(want.Unix()), json.Number("1792051200"),
+		"2026-10-15T08:00:00Z", "2026-10-15T10:00:00+02:00", " 1792051200 ", "Thu, 15 Oct 2026 08:00:00 +0000",
+	} {
+		if got, err := toTime(v); err != nil || !got.Equal(want) {
+			t.Errorf("toTime(%#v) = %v, %v, want %v", v, got, err, want)
+		}
+	}
+	local := time.Date(2026, 10, 15, 8, 0, 0, 0, time.Local)
+	for _, v := range []interface{}{"2026-10-15 08:00", "2026-10-15 08:00:00", "2026-10-15T08:00:00"} {
+		if got, err := toTime(v); err != nil || !got.Equal(local) {
+			t.Errorf("toTime(%q) = %v, %v, want %v", v, got, err, local)
+		}
+	}
+	for _, v := range []interface{}{"yesterday", "15.10.2026", true, (*time.Time)(nil)} {
+		if _, err := toTime(v); err == nil {
+			t.Errorf("toTime(%#v) error = nil", v)
+		}
+	}
+}
+
+func TestToDuration(t *testing.T) {
+	for _, tt := range []struct {
+		v    interface{}
+		want time.Duration
+	}{
+		{90 * time.Second, 90 * time.Second},
+		{90, 90 * time.Second},
+		{int64(90), 90 * time.Second},
+		{1.5, 1500 * time.Millisecond},
+		{json.Number("90"), 90 * time.Second},
+		{"1h30m", 90 * time.Minute},
+		{" 90 ", 90 * time.Second},
+	} {
+		if got, err := toDuration(tt.v); err != nil || got != tt.want {
+			t.Errorf("toDuration(%#v) = %v, %v, want %v", tt.v, got, err, tt.want)
+		}
+	}
+	for _, v := range []interface{}{"1 hour", true} {
+		if _, err := toDuration(v); err == nil {
+			t.Errorf("toDuration(%#v) error = nil", v)
+		}
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	for _, tt := range []struct {
+		d     time.Duration
+		units int
+		want  string
+	}{
+		{500 * time.Millisecond, 2, "0s"},
+		{90 * time.Second, 2, "1m 30s"},
+		{90 * time.Second, 1, "1m"},
+		{26*time.Hour + 5*time.Minute + 7*time.Second, 2, "1d 2h"},
+		{time.Hour + 7*time.Second, 2, "1h"},
+		{time.Hour + 7*time.Second, 3, "1h 7s"},
+		{-3 * time.Minute, 1, "-3m"},
+	} {
+		if got := shortDuration(tt.d, tt.units); got != tt.want {
+			t.Errorf("shortDuration(%v, %d) = %q, want %q", tt.d, tt.units, got, tt.want)
+		}
+	}
+}
+
+func TestTemplateTimeFuncs(t *testing.T) {
+	data := &templateData{Vars: map[string]string{
+		"startsAt": time.Now().Add(-90 * time.Minute).UTC().Format(time.RFC3339),
+		"at":       "2026-10-15T08:00:00Z",
+		"took":     "4000",
+	}}
+	for _, tt := range []struct {
+		text, want string
+	}{
+		{`{{.Vars.at | inZone "Europe/Vienna" | date "15:04 MST"}}`, "10:00 CEST"},
+		{`{{rfc3339 .Vars.at}}`, "2026-10-15T08:00:00Z"},
+		{`{{ago .Vars.startsAt}}`, "1h ago"},
+		{`{{since .Vars.startsAt | duration}}`, "1h 30m"},
+		{`{{duration .Vars.took}}`, "1h 6m"},
+	} {
+		if got, err := renderTemplate("test", tt.text, data); err != nil || got != tt.want {
+			t.Errorf("renderTemplate(%q) = %q, %v, want %q", tt.text, got, err, tt.want)
+		}
+	}
+	if _, err := renderTemplate("test", `{{date "15:04" .Vars.missing}}`, data); err == nil {
+		t.Errorf("renderTemplate() of a missing time: error = nil")
+	}
+}
@@ -0,0 +1,89 @@
+// thread.go
+//
+// Listing of the replies to a message (command thread), e.g. to extract the
+// timeline of an incident which is kept as a thread.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// messageThread is the format of a JSON thread export.
+type messageThread struct {
+	Parent  *webex.Message   `json:"parent"`
+	Replies []*webex.Message `json:"replies"`
+}
+
+// runThread lists the parent message and its replies, oldest first, as
+// table or written to output as JSON or CSV ("-" is JSON to standard
+// output).
+func runThread(parentID, output string) error {
+	ext := strings.ToLower(filepath.Ext(output))
+	if len(output) > 0 && output != "-" && ext != ".json" && ext != ".csv" {
+		return errors.New("unsupported thread format. use .json or .csv")
+	}
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	parent, err := client.GetMessage(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	if len(parent.ParentID) > 0 {
+		return fmt.Errorf("message %s is a reply. use the ID of the parent message %s", parentID, parent.ParentID)
+	}
+	thread := &messageThread{Parent: parent, Replies: []*webex.Message{}}
+	err = client.ListReplies(ctx, parent.RoomID, parent.ID, func(m *webex.Message) bool {
+		thread.Replies = append(thread.Replies, m)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for i, j := 0, len(thread.Replies)-1; i < j; i, j = i+1, j-1 {
+		thread.Replies[i], thread.Replies[j] = thread.Replies[j], thread.Replies[i]
+	}
+
+	if len(output) == 0 {
+		w := newTable("ID", "CREATED", "SENDER", "TEXT")
+		for _, m := range append([]*webex.Message{parent}, thread.Replies...) {
+			text := m.Text
+			if len(text) == 0 {
+				text = m.Markdown
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.ID, m.Created.Local().Format("2006-01-02 15:04"), m.PersonEmail, shorten(text, maxSearchTextLen))
+		}
+		return w.Flush()
+	}
+
+	w := io.Writer(os.Stdout)
+	if output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if ext == ".csv" {
+		archive := &roomArchive{Exported: time.Now(), Messages: append([]*webex.Message{parent}, thread.Replies...)}
+		return writeArchiveCSV(w, archive)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(thread)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+func TestRunThread(t *testing.T) {
+	fake := useFakeWebex(t)
+	room := fake.AddRoom("Incidents", "", "group")
+	parent := fake.AddMessage(room.ID, "alice@example.com", "INC-42 database down", time.Now().Add(-time.Hour))
+	client, err := webexClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var replies []string
+	for _, text := range []string{"failover started", "failover done, monitoring"} {
+		m, err := client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: room.ID, ParentID: parent.ID, Markdown: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, m.ID)
+	}
+	fake.AddMessage(room.ID, "alice@example.com", "unrelated", time.Now())
+
+	out := captureStdout(t, func() { err = runThread(parent.ID, "-") })
+	var thread messageThread
+	if err != nil || json.Unmarshal([]byte(out), &thread) != nil {
+		t.Fatalf("runThread(-) = %v, %q", err, out)
+	}
+	if thread.Parent.ID != parent.ID || len(thread.Replies) != 2 || thread.Replies[0].ID != replies[0] || thread.Replies[1].ID != replies[1] {
+		t.Errorf("thread = %+v, want the parent and the replies oldest first", thread)
+	}
+
+	out = captureStdout(t, func() { err = runThread(parent.ID, "") })
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if err != nil || len(lines) != 4 || !strings.HasPrefix(lines[1], parent.ID+" ") || !strings.HasPrefix(lines[3], replies[1]+" ") {
+		t.Errorf("runThread() table = %v, %q", err, out)
+	}
+
+	csv := filepath.Join(t.TempDir(), "INC-42.csv")
+	if err := runThread(parent.ID, csv); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(csv)
+	if err != nil || strings.Count(string(data), "\n") != 4 || !strings.Contains(string(data), "failover done, monitoring") {
+		t.Errorf("runThread(%s) = %v, %q", csv, err, data)
+	}
+
+	for _, tt := range []struct{ id, output, wantErr string }{
+		{parent.ID, "INC-42.txt", "unsupported thread format. use .json or .csv"},
+		{replies[0], "-", "message " + replies[0] + " is a reply. use the ID of the parent message " + parent.ID},
+	} {
+		if err := runThread(tt.id, tt.output); errorString(err) != tt.wantErr {
+			t.Errorf("runThread(%s, %s) error = %v, want %s", tt.id, tt.output, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// tlsoptions.go
+//
+// TLS hardening of the connections (flags --tls-min-version, --tls-ciphers,
+// --tls-pin or the "tls" section of the config file): the minimum TLS
+// version, the cipher suites allowed with TLS 1.2 and the public keys pinned
+// for the hosts of the Webex API.
+//
+//	{
+//	  "tls": {
+//	    "minVersion": "1.3",
+//	    "pins": [ "sha256/<base64 of the SHA-256 hash of the public key>" ]
+//	  }
+//	}
+//
+// A pin matches if the public key of a certificate of a verified chain of the
+// server has the given hash, so the key of an intermediate or root CA can be
+// pinned as well. Certificates the server sends but which are not part of a
+// verified chain never match, so pins can not be combined with --insecure.
+// Pins are only checked for the hosts of the Webex API and
+// the Webex file servers, not e.g. for the proxy server or the MQTT broker.
+// The flags override the config file.
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// tlsOptions is the "tls" section of the config file.
+type tlsOptions struct {
+	MinVersion string   `json:"minVersion"`
+	Ciphers    []string `json:"ciphers"`
+	Pins       []string `json:"pins"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// webexDomains are the domains of the Webex API and file servers whose
+// certificates are checked against the pins.
+var webexDomains = []string{"webexapis.com", "webex.com", "wbx2.com", "ciscospark.com"}
+
+// applyTLSOptions sets the TLS options of the config file unless given by
+// flags.
+func applyTLSOptions() error {
+	if len(configFile) == 0 {
+		return nil
+	}
+	c, err := loadConfig(configFile)
+	if err != nil || c.TLS == nil {
+		return err
+	}
+	if !flagGiven("tls-min-version") {
+		tlsMinVersion = c.TLS.MinVersion
+	}
+	if !flagGiven("tls-ciphers") {
+		tlsCiphers = strings.Join(c.TLS.Ciphers, ",")
+	}
+	if !flagGiven("tls-pin") {
+		tlsPins = c.TLS.Pins
+	}
+	return nil
+}
+
+// hardenTLSConfig applies the minimum TLS version, the cipher suites and the
+// pins to tlsConfig.
+func hardenTLSConfig(tlsConfig *tls.Config) error {
+	if len(tlsMinVersion) > 0 {
+		v, ok := tlsVersions[tlsMinVersion]
+		if !ok {
+			return fmt.Errorf("invalid TLS version %q. use 1.2 or 1.3", tlsMinVersion)
+		}
+		tlsConfig.MinVersion = v
+	}
+
+	if len(tlsCiphers) > 0 {
+		for _, name := range strings.Split(tlsCiphers, ",") {
+			id, err := cipherSuiteID(strings.TrimSpace(name))
+			if err != nil {
+				return err
+			}
+			tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+		}
+	}
+
+	if len(tlsPins) == 0 {
+		return nil
+	}
+	if tlsInsecure {
+		return fmt.Errorf("--tls-pin can not be combined with --insecure, no certificate chain is verified")
+	}
+	pins := make(map[string]bool)
+	for _, pin := range tlsPins {
+		hash, ok := strings.CutPrefix(pin, "sha256/")
+		sum, err := base64.StdEncoding.DecodeString(hash)
+		if !ok || err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("invalid TLS pin %q. use sha256/<base64 of the SHA-256 hash of the public key>", pin)
+		}
+		pins[hash] = true
+	}
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if !pinnedHost(cs.ServerName) {
+			return nil
+		}
+		// only the verified chains, the server may send any certificate
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if pins[base64.StdEncoding.EncodeToString(sum[:])] {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("no certificate of %s matches the TLS pins", cs.ServerName)
+	}
+	return nil
+}
+
+// cipherSuiteID returns the ID of the secure TLS 1.2 cipher suite of the
+// given Go name, e.g. TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384.
+func cipherSuiteID(name string) (uint16, error) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name != name {
+			continue
+		}
+		for _, v := range suite.SupportedVersions {
+			if v == tls.VersionTLS12 {
+				return suite.ID, nil
+			}
+		}
+		return 0, fmt.Errorf("cipher suite %s is TLS 1.3 only. TLS 1.3 cipher suites can not be restricted", name)
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.Name == name {
+			return 0, fmt.Errorf("cipher suite %s is insecure", name)
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher suite %q", name)
+}
+
+// pinnedHost reports whether the certificates of host are checked against
+// the pins: the hosts of the Webex domains and of the API URL.
+func pinnedHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range webexDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	if u, err := url.Parse(apiBaseURL); err == nil && len(apiBaseURL) > 0 {
+		return strings.EqualFold(u.Hostname(), host)
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// testCert returns a certificate with a new public key and its pin.
+func testCert(t *testing.T) (*x509.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(spki)
+	return &x509.Certificate{RawSubjectPublicKeyInfo: spki}, "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// useTLSOptions sets the TLS flags for the test.
+func useTLSOptions(t *testing.T, minVersion, ciphers string, insecure bool, pins ...string) {
+	tlsMinVersion, tlsCiphers, tlsInsecure, tlsPins = minVersion, ciphers, insecure, pins
+	t.Cleanup(func() {
+		tlsMinVersion, tlsCiphers, tlsInsecure, tlsPins = "", "", false, nil
+	})
+}
+
+func TestTLSPins(t *testing.T) {
+	leaf, leafPin := testCert(t)
+	ca, caPin := testCert(t)
+	other, _ := testCert(t)
+	for _, tt := range []struct {
+		name   string
+		pin    string
+		host   string
+		peers  []*x509.Certificate
+		chains [][]*x509.Certificate
+		ok     bool
+	}{
+		{name: "leaf", pin: leafPin, host: "webexapis.com", peers: []*x509.Certificate{leaf}, chains: [][]*x509.Certificate{{leaf, ca}}, ok: true},
+		{name: "CA", pin: caPin, host: "files.wbx2.com", peers: []*x509.Certificate{leaf}, chains: [][]*x509.Certificate{{leaf, ca}}, ok: true},
+		{name: "second chain", pin: caPin, host: "webexapis.com", chains: [][]*x509.Certificate{{other}, {leaf, ca}}, ok: true},
+		{name: "no match", pin: caPin, host: "webexapis.com", peers: []*x509.Certificate{leaf}, chains: [][]*x509.Certificate{{leaf, other}}},
+		// e.g. an intercepting proxy which appends the pinned certificate
+		{name: "sent but not verified", pin: caPin, host: "webexapis.com", peers: []*x509.Certificate{other, ca}, chains: [][]*x509.Certificate{{other}}},
+		{name: "not verified", pin: leafPin, host: "webexapis.com", peers: []*x509.Certificate{leaf}},
+		{name: "other host", pin: caPin, host: "proxy.example.com", chains: [][]*x509.Certificate{{other}}, ok: true},
+		{name: "lookalike host", pin: caPin, host: "evilwebex.com", chains: [][]*x509.Certificate{{other}}, ok: true},
+	} {
+		useTLSOptions(t, "", "", false, tt.pin)
+		var config tls.Config
+		if err := hardenTLSConfig(&config); err != nil {
+			t.Fatal(err)
+		}
+		err := config.VerifyConnection(tls.ConnectionState{ServerName: tt.host, PeerCertificates: tt.peers, VerifiedChains: tt.chains})
+		if tt.ok != (err == nil) {
+			t.Errorf("%s: VerifyConnection() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestHardenTLSConfig(t *testing.T) {
+	_, pin := testCert(t)
+	for _, tt := range []struct {
+		minVersion string
+		ciphers    string
+		insecure   bool
+		pins       []string
+		wantErr    string
+	}{
+		{minVersion: "1.3"},
+		{ciphers: "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384, TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+		{pins: []string{pin}},
+		{minVersion: "1.1", wantErr: "invalid TLS version"},
+		{ciphers: "TLS_RSA_WITH_RC4_128_SHA", wantErr: "is insecure"},
+		{ciphers: "TLS_AES_128_GCM_SHA256", wantErr: "TLS 1.3 only"},
+		{ciphers: "TLS_FOO", wantErr: "unknown cipher suite"},
+		{pins: []string{"sha256/abc="}, wantErr: "invalid TLS pin"},
+		{pins: []string{strings.TrimPrefix(pin, "sha256/")}, wantErr: "invalid TLS pin"},
+		{pins: []string{pin}, insecure: true, wantErr: "can not be combined with --insecure"},
+	} {
+		useTLSOptions(t, tt.minVersion, tt.ciphers, tt.insecure, tt.pins...)
+		var config tls.Config
+		err := hardenTLSConfig(&config)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("hardenTLSConfig(%+v) error = %v, want %s", tt, err, tt.wantErr)
+			}
+		} else if err != nil {
+			t.Errorf("hardenTLSConfig(%+v): %v", tt, err)
+		}
+	}
+}
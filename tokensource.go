@@ -0,0 +1,156 @@
+// tokensource.go
+//
+// Bot token from a file or a secret store instead of -T, so the token is
+// only kept in memory and never written to disk, e.g. of CI runners: a file,
+// e.g. a mounted Kubernetes secret (--token-file), HashiCorp Vault
+// (--token-vault, see vault.go), AWS Secrets Manager and SSM Parameter Store
+// (--token-aws-secret, see awssecret.go) or Azure Key Vault
+// (--token-azure-keyvault, see azurekeyvault.go). The token is fetched at the
+// start and overrides the token of --profile.
+//
+// The long-running modes (serve, --mqtt, --daemon, --k8s-watch) fetch the
+// token again on SIGHUP and read --token-file again when it changes, so a
+// rotated token is used without restart:
+//
+//	kill -HUP $(pidof notify_by_webex_teams)
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// metadataTimeout limits the requests to the link-local metadata
+	// endpoints of the clouds, which do not exist elsewhere.
+	metadataTimeout = 2 * time.Second
+	// tokenFileInterval is the interval of checking --token-file for a new
+	// token. Kubernetes updates mounted secrets within about a minute.
+	tokenFileInterval = 10 * time.Second
+)
+
+// tokenStore is a secret store of the bot token.
+type tokenStore struct {
+	flag string
+	// ref is the secret of the flag
+	ref   string
+	fetch func(ctx context.Context, ref string) (string, error)
+}
+
+// tokenStores returns the secret stores of the flags.
+func tokenStores() []*tokenStore {
+	var stores []*tokenStore
+	for _, s := range []*tokenStore{
+		{flag: "--token-file", ref: tokenFile, fetch: readTokenFile},
+		{flag: "--token-vault", ref: tokenVault, fetch: vaultSecret},
+		{flag: "--token-aws-secret", ref: tokenAWSSecret, fetch: awsSecret},
+		{flag: "--token-azure-keyvault", ref: tokenAzureKV, fetch: azureKeyVaultSecret},
+	} {
+		if len(s.ref) > 0 {
+			stores = append(stores, s)
+		}
+	}
+	return stores
+}
+
+// applyTokenSource sets the token of the secret store of the flags.
+func applyTokenSource() error {
+	stores := tokenStores()
+	if len(stores) == 0 {
+		return nil
+	}
+	if len(stores) > 1 || flagGiven("T", "token") {
+		return errors.New("use only one of -T, --token-file, --token-vault, --token-aws-secret and --token-azure-keyvault")
+	}
+	s := stores[0]
+	ctx, cancel := requestContext()
+	defer cancel()
+	token, err := s.fetch(ctx, s.ref)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", s.flag, s.ref, err)
+	}
+	addSecret(token)
+	apiToken = token
+	slog.Debug("token from secret store", "flag", s.flag, "secret", s.ref)
+	return nil
+}
+
+// readTokenFile returns the token of the given file.
+func readTokenFile(ctx context.Context, file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if len(token) == 0 {
+		return "", errors.New("empty token file")
+	}
+	return token, nil
+}
+
+// runTokenReloader fetches the token of the secret store again on SIGHUP
+// and reads --token-file again every tokenFileInterval, until ctx is
+// canceled.
+func runTokenReloader(ctx context.Context) {
+	stores := tokenStores()
+	if len(stores) != 1 {
+		return
+	}
+	s := stores[0]
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+	var ticks <-chan time.Time
+	if s.flag == "--token-file" {
+		ticker := time.NewTicker(tokenFileInterval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	for {
+		select {
+		case <-hangups:
+			slog.Info("reloading the token", "flag", s.flag, "signal", "SIGHUP")
+			s.reload(ctx)
+		case <-ticks:
+			s.reload(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reload fetches the token again and replaces the token if it changed. The
+// current token is kept if the token can not be fetched.
+func (s *tokenStore) reload(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	token, err := s.fetch(ctx, s.ref)
+	if err != nil {
+		slog.Error("reloading the token failed, keeping the current token", "flag", s.flag, "error", err)
+		return
+	}
+	tokenMutex.Lock()
+	changed := token != apiToken
+	if changed {
+		addSecret(token)
+		apiToken = token
+	}
+	tokenMutex.Unlock()
+	if changed {
+		slog.Info("token reloaded", "flag", s.flag)
+	}
+}
+
+// metadataClient returns the HTTP client of the metadata and managed
+// identity endpoints, which are reached without proxy server.
+func metadataClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{}}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyTokenSource(t *testing.T) {
+	defer func(file, vault, token string) { tokenFile, tokenVault, apiToken = file, vault, token }(tokenFile, tokenVault, apiToken)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "token")
+	if err := os.WriteFile(file, []byte("  file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		file, vault   string
+		want, wantErr string
+	}{
+		{want: "flag-token"},
+		{file: file, want: "file-token"},
+		{file: empty, wantErr: "--token-file " + empty + ": empty token file"},
+		{file: filepath.Join(dir, "missing"), wantErr: "no such file"},
+		{file: file, vault: "secret/webex#token", wantErr: "use only one of -T, --token-file"},
+	} {
+		tokenFile, tokenVault, apiToken = tt.file, tt.vault, "flag-token"
+		err := applyTokenSource()
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("applyTokenSource(%q, %q) error = %v, want %s", tt.file, tt.vault, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || apiToken != tt.want {
+			t.Errorf("applyTokenSource(%q, %q) = %v, token %q, want %q", tt.file, tt.vault, err, apiToken, tt.want)
+		}
+	}
+}
+
+func TestTokenStoreReload(t *testing.T) {
+	defer func(file, token string) { tokenFile, apiToken = file, token }(tokenFile, apiToken)
+	tokenFile = filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("new-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	apiToken = "old-token"
+	stores := tokenStores()
+	if len(stores) != 1 || stores[0].flag != "--token-file" {
+		t.Fatalf("tokenStores() = %+v, want --token-file", stores)
+	}
+	stores[0].reload(context.Background())
+	if apiToken != "new-token" {
+		t.Errorf("token after reload = %q, want new-token", apiToken)
+	}
+
+	// a token which can not be read keeps the current token
+	if err := os.Remove(tokenFile); err != nil {
+		t.Fatal(err)
+	}
+	stores[0].reload(context.Background())
+	if apiToken != "new-token" {
+		t.Errorf("token after failed reload = %q, want new-token", apiToken)
+	}
+}
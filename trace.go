@@ -0,0 +1,178 @@
+// trace.go
+//
+// HTTP trace of all requests and responses (flag --trace), written to
+// standard error or to the file of --trace-file, to debug proxy and card
+// problems:
+//
+//	notify_by_webex_teams --trace -t KMP-Team -r Alerts -A card.json -m "fallback text"
+//
+// The lines of a request start with ">", of a response with "<" and the
+// connection details, e.g. the proxy server and the TLS handshake, with "*",
+// as with curl -v. The Authorization header and the other credential headers,
+// the registered secrets (see logging.go) and the values of token, secret and
+// password fields of JSON and form bodies are replaced by REDACTED. Bodies of
+// more than traceMaxBody bytes, e.g. uploaded files, are left out.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"net/http/httputil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceMaxBody is the maximum size of a body written to the trace.
+const traceMaxBody = 64 << 10
+
+var (
+	traceOnce   sync.Once
+	traceOutput io.Writer
+	traceErr    error
+	// traceMutex keeps the lines of parallel requests together
+	traceMutex sync.Mutex
+
+	// traceHeaderRE matches the header lines with credentials
+	traceHeaderRE = regexp.MustCompile(`(?im)^([\w-]*(?:authorization|token|cookie|secret|signature|identity-header)[\w-]*): .*$`)
+	// traceJSONRE matches the JSON string fields with credentials
+	traceJSONRE = regexp.MustCompile(`(?i)("[\w-]*(?:token|secret|password|assertion)[\w-]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// traceFormRE matches the query and form fields with credentials
+	traceFormRE = regexp.MustCompile(`(?im)(^|[?&])([\w-]*(?:token|secret|password|assertion)[\w-]*)=[^&\s]*`)
+	// traceValueRE matches the values of the secret stores, which are
+	// redacted for all hosts except the Webex API
+	traceValueRE = regexp.MustCompile(`("(?:[Vv]alue|SecretString)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+)
+
+// traceTransport writes the requests and responses of the next transport to
+// the trace.
+type traceTransport struct {
+	next http.RoundTripper
+	w    io.Writer
+}
+
+// traceWriter returns the writer of the trace, standard error or the file
+// of --trace-file, opened once.
+func traceWriter() (io.Writer, error) {
+	traceOnce.Do(func() {
+		if len(traceFile) == 0 {
+			traceOutput = os.Stderr
+			return
+		}
+		traceOutput, traceErr = os.OpenFile(traceFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	})
+	return traceOutput, traceErr
+}
+
+// withTrace returns next with the HTTP trace of --trace, or next unchanged
+// without --trace.
+func withTrace(next http.RoundTripper) (http.RoundTripper, error) {
+	if !traceHTTP && len(traceFile) == 0 {
+		return next, nil
+	}
+	w, err := traceWriter()
+	if err != nil {
+		return nil, fmt.Errorf("--trace-file: %w", err)
+	}
+	return &traceTransport{next: next, w: w}, nil
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var buf bytes.Buffer
+	start := time.Now()
+	webexHost := pinnedHost(req.URL.Hostname())
+	fmt.Fprintf(&buf, "* %s %s %s\n", start.Format(time.RFC3339Nano), req.Method, redactTrace(req.URL.Redacted(), webexHost))
+
+	var connMutex sync.Mutex
+	ct := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			connMutex.Lock()
+			defer connMutex.Unlock()
+			fmt.Fprintf(&buf, "* connection to %s, reused: %t\n", info.Conn.RemoteAddr(), info.Reused)
+		},
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			connMutex.Lock()
+			defer connMutex.Unlock()
+			if err != nil {
+				fmt.Fprintf(&buf, "* TLS handshake with %s failed: %v\n", cs.ServerName, err)
+				return
+			}
+			fmt.Fprintf(&buf, "* TLS handshake with %s: %s, %s\n", cs.ServerName, tls.VersionName(cs.Version), tls.CipherSuiteName(cs.CipherSuite))
+		},
+	}
+
+	// the request is dumped before adding the trace, since dumping sends it
+	// to a fake connection
+	dumpBody := req.ContentLength >= 0 && req.ContentLength <= traceMaxBody
+	dump, err := httputil.DumpRequestOut(req, dumpBody)
+	if err != nil {
+		fmt.Fprintf(&buf, "* dumping the request failed: %v\n", err)
+	} else {
+		if !dumpBody {
+			dump = append(dump, fmt.Sprintf("[body of %d bytes not shown]", req.ContentLength)...)
+		}
+		writeTraceLines(&buf, "> ", redactTrace(string(dump), webexHost))
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), ct))
+
+	resp, err := t.next.RoundTrip(req)
+	connMutex.Lock()
+	defer connMutex.Unlock()
+	if err != nil {
+		fmt.Fprintf(&buf, "* %v, after %s\n", redact(err.Error()), time.Since(start).Round(time.Millisecond))
+		t.write(buf.Bytes())
+		return nil, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	dumpBody = resp.ContentLength >= 0 && resp.ContentLength <= traceMaxBody ||
+		resp.ContentLength < 0 && (mediaType == "application/json" || strings.HasPrefix(mediaType, "text/"))
+	dump, err = httputil.DumpResponse(resp, dumpBody)
+	if err != nil {
+		fmt.Fprintf(&buf, "* dumping the response failed: %v\n", err)
+	} else {
+		if !dumpBody {
+			dump = append(dump, fmt.Sprintf("[body of %d bytes not shown]", resp.ContentLength)...)
+		}
+		writeTraceLines(&buf, "< ", redactTrace(string(dump), webexHost))
+	}
+	fmt.Fprintf(&buf, "* %s in %s\n\n", resp.Status, time.Since(start).Round(time.Millisecond))
+	t.write(buf.Bytes())
+	return resp, nil
+}
+
+func (t *traceTransport) write(p []byte) {
+	traceMutex.Lock()
+	defer traceMutex.Unlock()
+	t.w.Write(p)
+}
+
+// writeTraceLines writes the lines of s with the given prefix to buf.
+func writeTraceLines(buf *bytes.Buffer, prefix, s string) {
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	scanner.Buffer(nil, traceMaxBody+1<<10)
+	for scanner.Scan() {
+		buf.WriteString(prefix)
+		buf.WriteString(strings.TrimSuffix(scanner.Text(), "\r"))
+		buf.WriteByte('\n')
+	}
+}
+
+// redactTrace replaces the credentials of a dumped request or response.
+// The values of the secret stores are only kept for the Webex API.
+func redactTrace(s string, webexHost bool) string {
+	s = traceHeaderRE.ReplaceAllString(s, "$1: "+redacted)
+	s = traceJSONRE.ReplaceAllString(s, `$1"`+redacted+`"`)
+	s = traceFormRE.ReplaceAllString(s, "$1$2="+redacted)
+	if !webexHost {
+		s = traceValueRE.ReplaceAllString(s, `$1"`+redacted+`"`)
+	}
+	return redact(s)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactTrace(t *testing.T) {
+	for _, tt := range []struct {
+		s         string
+		webexHost bool
+		want      string
+	}{
+		{"Authorization: Bearer abc123", true, "Authorization: REDACTED"},
+		{"X-Amz-Security-Token: abc123\nX-Spark-Signature: 0a1b", true, "X-Amz-Security-Token: REDACTED\nX-Spark-Signature: REDACTED"},
+		{"Content-Type: application/json", true, "Content-Type: application/json"},
+		{`{"access_token": "abc", "refresh_token":"d\"ef", "expires_in": 1209600}`, true,
+			`{"access_token": "REDACTED", "refresh_token":"REDACTED", "expires_in": 1209600}`},
+		{`{"client_assertion":"eyJ","markdown":"password reset done"}`, true, `{"client_assertion":"REDACTED","markdown":"password reset done"}`},
+		{"POST /oauth2/token?client_secret=s3cr3t&grant_type=refresh_token", true, "POST /oauth2/token?client_secret=REDACTED&grant_type=refresh_token"},
+		{"grant_type=refresh_token&refresh_token=abc&client_id=C1", true, "grant_type=refresh_token&refresh_token=REDACTED&client_id=C1"},
+		{`{"SecretString":"bot-token"}`, false, `{"SecretString":"REDACTED"}`},
+		{`{"Parameter":{"Value":"bot-token"}}`, false, `{"Parameter":{"Value":"REDACTED"}}`},
+		{`{"items":[{"value":"42"}]}`, true, `{"items":[{"value":"42"}]}`},
+	} {
+		if got := redactTrace(tt.s, tt.webexHost); got != tt.want {
+			t.Errorf("redactTrace(%q, %v) = %q, want %q", tt.s, tt.webexHost, got, tt.want)
+		}
+	}
+}
+
+func TestTraceTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"M1","token":"t0k3n"}`)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	client := &http.Client{Transport: &traceTransport{next: http.DefaultTransport, w: &buf}}
+	req, _ := http.NewRequest("POST", srv.URL+"/messages", strings.NewReader(`{"markdown":"hi","secret":"s3cr3t"}`))
+	req.Header.Set("Authorization", "Bearer abc123")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"id":"M1","token":"t0k3n"}` {
+		t.Errorf("body read after the trace = %s", body)
+	}
+
+	trace := buf.String()
+	for _, want := range []string{"> POST /messages HTTP/1.1", "> Authorization: REDACTED", `> {"markdown":"hi","secret":"REDACTED"}`,
+		"< HTTP/1.1 200 OK", `< {"id":"M1","token":"REDACTED"}`, "* 200 OK in "} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("trace does not contain %q:\n%s", want, trace)
+		}
+	}
+	for _, secret := range []string{"abc123", "s3cr3t", "t0k3n"} {
+		if strings.Contains(trace, secret) {
+			t.Errorf("trace contains %q:\n%s", secret, trace)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// transport.go
+//
+// HTTP transport settings shared by all Webex API requests: proxy server
+// (flag -p or the standard proxy environment variables, HTTP or SOCKS5, with
+// basic, NTLM or Negotiate authentication and a client certificate) and TLS
+// options (additional CA certificates, certificate verification, hardening,
+// see tlsoptions.go). With --trace the requests are traced (see trace.go).
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxIdleConnsPerHost allows parallel requests to the Webex API in batch
+// modes to keep their connections open for reuse.
+const maxIdleConnsPerHost = 16
+
+var (
+	httpClientOnce sync.Once
+	httpClient     *http.Client
+	httpClientErr  error
+)
+
+// sharedHTTPClient returns the HTTP client used for all requests of the
+// process. It is built once with the settings of the flags, so the
+// connections of its transport are reused across requests.
+func sharedHTTPClient() (*http.Client, error) {
+	httpClientOnce.Do(func() {
+		httpClient, httpClientErr = newHTTPClient(proxyString)
+	})
+	return httpClient, httpClientErr
+}
+
+// newHTTPClient returns a HTTP client using the given proxy server, if any,
+// and the TLS options of the flags --cacert, --capath, --insecure and --tls-*.
+func newHTTPClient(proxyString string) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = tlsConfig
+	tr.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used unless -p or --no-proxy is set
+	tr.Proxy = http.ProxyFromEnvironment
+	switch {
+	case noProxy:
+		tr.Proxy = nil
+	case len(proxyString) > 0:
+		proxyURL, err := url.Parse(proxyString)
+		if err != nil {
+			return nil, err
+		}
+		switch proxyURL.Scheme {
+		case "http", "https":
+			tr.Proxy = http.ProxyURL(proxyURL)
+			if len(proxyAuth) > 0 && !strings.EqualFold(proxyAuth, "basic") || len(proxyCertFile) > 0 || len(proxyKeyFile) > 0 {
+				d, err := newConnectDialer(proxyURL, proxyAuth)
+				if err != nil {
+					return nil, err
+				}
+				tr.Proxy = nil
+				tr.DialContext = d.DialContext
+			}
+		case "socks5", "socks5h":
+			tr.Proxy = nil
+			tr.DialContext = newSOCKS5Dialer(proxyURL).DialContext
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme: %s. use http, https, socks5 or socks5h", proxyURL.Scheme)
+		}
+	case len(proxyCertFile) > 0 || len(proxyKeyFile) > 0:
+		return nil, errors.New("--proxy-cert needs the HTTPS proxy server of -p")
+	}
+	rt, err := withTrace(tr)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// dialContext returns the function used to open plain TCP connections, e.g.
+// to a MQTT broker. Only SOCKS5 proxy servers are used for these connections.
+// Without -p the proxy server of the ALL_PROXY environment variable is used.
+func dialContext(proxyString string) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if noProxy {
+		return dialer.DialContext, nil
+	}
+	if len(proxyString) == 0 {
+		proxyString = os.Getenv("ALL_PROXY")
+		if len(proxyString) == 0 {
+			proxyString = os.Getenv("all_proxy")
+		}
+	}
+	if len(proxyString) == 0 {
+		return dialer.DialContext, nil
+	}
+	proxyURL, err := url.Parse(proxyString)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "socks5" || proxyURL.Scheme == "socks5h" {
+		return newSOCKS5Dialer(proxyURL).DialContext, nil
+	}
+	return dialer.DialContext, nil
+}
+
+// newTLSConfig returns the TLS client configuration. Additional CA
+// certificates are trusted on top of the system certificate pool.
+func newTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if err := hardenTLSConfig(tlsConfig); err != nil {
+		return nil, err
+	}
+
+	if tlsInsecure {
+		slog.Warn("TLS certificate verification is disabled")
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	if len(caCertFile) == 0 && len(caPath) == 0 {
+		return tlsConfig, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if len(caCertFile) > 0 {
+		err = appendCertsFromFile(pool, caCertFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(caPath) > 0 {
+		entries, err := ioutil.ReadDir(caPath)
+		if err != nil {
+			return nil, err
+		}
+		found := 0
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			// skip files which are no certificates, e.g. c_rehash symlinks to CRLs
+			if appendCertsFromFile(pool, filepath.Join(caPath, entry.Name())) == nil {
+				found++
+			}
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("no PEM certificates found in %s", caPath)
+		}
+	}
+
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+func appendCertsFromFile(pool *x509.CertPool, filename string) error {
+	pem, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("CA certificate file not found: %s", filename)
+		}
+		return err
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("no PEM certificates found in " + filename)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// upload.go
+//
+// Several files (flag -f given more than once). Webex takes one file per
+// message, so every file is a message of its own. The files are uploaded
+// concurrently, up to --upload-parallel at the same time, which is much
+// faster than one after the other over a slow proxy. Afterwards the message
+// is sent with a list of the files as summary:
+//
+//	nightly report
+//
+//	📎 3 files: report.pdf, errors.csv, graph.png
+//
+// With --thread-files the message, with the list of files, is sent first
+// and the files are replies in its thread instead.
+//
+// Local images referenced by the message, e.g. ![graph](./graph.png), are
+// sent as files, as Webex does not show images in messages. The reference is
+// replaced by "📎 graph".
+//
+// All files are checked before anything is sent: a missing, empty or too
+// large file (above 100 MB) fails right away instead of after minutes of
+// uploading. Files without a preview in Webex are sent as downloads.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// imageReference matches a markdown image with an optional title.
+var imageReference = regexp.MustCompile(`!\[([^\]]*)\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+
+// urlScheme matches URLs, but not Windows drive letters
+var urlScheme = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]+:`)
+
+// attachLocalImages moves the local images referenced by the message of n to
+// its files. Relative paths are relative to the working directory.
+func attachLocalImages(n *notification) error {
+	var images []string
+	var err error
+	n.Markdown = imageReference.ReplaceAllStringFunc(n.Markdown, func(ref string) string {
+		m := imageReference.FindStringSubmatch(ref)
+		alt, path := m[1], m[2]
+		if urlScheme.MatchString(path) {
+			return ref
+		}
+		if _, statErr := os.Stat(path); statErr != nil && err == nil {
+			err = fmt.Errorf("image of the message: %v", statErr)
+		}
+		images = append(images, path)
+		if len(strings.TrimSpace(alt)) == 0 {
+			alt = filepath.Base(path)
+		}
+		return "📎 " + alt
+	})
+	if err != nil {
+		return err
+	}
+	addFiles(n, images...)
+	return nil
+}
+
+// addFiles adds files to the files of n.
+func addFiles(n *notification, files ...string) {
+	if len(files) == 0 {
+		return
+	}
+	// n.Files may be shared with other notifications, e.g. of --recipients
+	all := append([]string(nil), n.Files...)
+	if len(n.File) > 0 {
+		all = []string{n.File}
+	}
+	all = append(all, files...)
+	n.File, n.Files = "", nil
+	if len(all) == 1 {
+		n.File = all[0]
+	} else {
+		n.Files = all
+	}
+}
+
+// previewTypes are the file types Webex shows a preview of.
+var previewTypes = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".bmp": true,
+	".pdf": true, ".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true, ".pptx": true,
+	".txt": true,
+}
+
+// notificationFiles returns the files of n.
+func notificationFiles(n *notification) []string {
+	if len(n.File) > 0 {
+		return append([]string{n.File}, n.Files...)
+	}
+	return n.Files
+}
+
+// checkFiles checks the files of n before anything is sent.
+func checkFiles(n *notification) error {
+	for _, file := range notificationFiles(n) {
+		if offloadable(file) {
+			// sent as link, see largefile.go
+			continue
+		}
+		if err := webex.CheckFile(file); err != nil {
+			return err
+		}
+		if ext := strings.ToLower(filepath.Ext(file)); !previewTypes[ext] {
+			slog.Debug("file type has no preview in Webex, sent as download", "file", file, "type", webex.FileContentType(file))
+		}
+	}
+	return nil
+}
+
+// sendFiles sends markdown and files to the room, as replies to parentID if
+// it is set. It returns the message with the list of the files and the URLs
+// of the uploaded files.
+func sendFiles(ctx context.Context, client *webex.Client, roomID, parentID, markdown string, files []string) (*webex.Message, error) {
+	var summary *webex.Message
+	if threadFiles {
+		m, err := client.CreateMessage(ctx, &webex.MessageRequest{RoomID: roomID, ParentID: parentID, Markdown: filesSummary(markdown, files, nil)})
+		if err != nil {
+			return nil, err
+		}
+		summary = m
+		// a reply cannot have replies, the files go to the same thread
+		if len(parentID) == 0 {
+			parentID = m.ID
+		}
+	}
+
+	errs := make([]error, len(files))
+	fileURLs := make([][]string, len(files))
+	var wg sync.WaitGroup
+	parallel := make(chan struct{}, max(uploadParallel, 1))
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file string) {
+			defer wg.Done()
+			parallel <- struct{}{}
+			defer func() { <-parallel }()
+			shrunk, cleanup, err := shrinkImage(file, int64(maxImageSize))
+			if err == nil {
+				defer cleanup()
+				var m *webex.Message
+				m, err = client.UploadFileWith(ctx, &webex.MessageRequest{RoomID: roomID, ParentID: parentID}, shrunk)
+				if err == nil {
+					fileURLs[i] = m.Files
+				}
+			}
+			errs[i] = err
+			if errs[i] != nil {
+				slog.Error("uploading file failed", "file", file, "error", errs[i])
+			}
+		}(i, file)
+	}
+	wg.Wait()
+
+	if !threadFiles {
+		m, err := client.CreateMessage(ctx, &webex.MessageRequest{RoomID: roomID, ParentID: parentID, Markdown: filesSummary(markdown, files, errs)})
+		if err != nil {
+			return nil, err
+		}
+		summary = m
+	}
+	// the summary message has no files, it lists the URLs of the uploads
+	// for the output of the message
+	for _, urls := range fileURLs {
+		summary.Files = append(summary.Files, urls...)
+	}
+
+	var failed []string
+	var firstErr error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, filepath.Base(files[i]))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		// wrapping keeps the first error for isTransient
+		return summary, fmt.Errorf("uploading %d of %d files failed (%s): %w", len(failed), len(files), strings.Join(failed, ", "), firstErr)
+	}
+	return summary, nil
+}
+
+// filesSummary returns markdown followed by the list of files. Files which
+// failed according to errs are marked.
+func filesSummary(markdown string, files []string, errs []error) string {
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = filepath.Base(file)
+		if errs != nil && errs[i] != nil {
+			names[i] = "⚠️ ~~" + names[i] + "~~"
+		}
+	}
+	list := trf("📎 %d files: %s", len(files), strings.Join(names, ", "))
+	if len(strings.TrimSpace(markdown)) == 0 {
+		return list
+	}
+	return strings.TrimRight(markdown, "\n") + "\n\n" + list
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttachLocalImages(t *testing.T) {
+	dir := t.TempDir()
+	graph := filepath.Join(dir, "graph.png")
+	cpu := filepath.Join(dir, "cpu.png")
+	for _, file := range []string{graph, cpu} {
+		if err := os.WriteFile(file, []byte("png"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shared := []string{"report.pdf"}
+
+	for _, tt := range []struct {
+		name     string
+		n        notification
+		markdown string
+		file     string
+		files    []string
+		wantErr  string
+	}{
+		{name: "no images", n: notification{Markdown: "disk full", File: "df.txt"}, markdown: "disk full", file: "df.txt"},
+		{name: "remote image", n: notification{Markdown: "![graph](https://grafana.example.com/graph.png)"},
+			markdown: "![graph](https://grafana.example.com/graph.png)"},
+		{name: "one image", n: notification{Markdown: "load:\n![graph](" + graph + ")"}, markdown: "load:\n📎 graph", file: graph},
+		{name: "title and brackets", n: notification{Markdown: `![ ](<` + graph + `> "Load") and ![CPU](` + cpu + `)`},
+			markdown: "📎 graph.png and 📎 CPU", files: []string{graph, cpu}},
+		{name: "with file", n: notification{Markdown: "![graph](" + graph + ")", File: "df.txt"},
+			markdown: "📎 graph", files: []string{"df.txt", graph}},
+		{name: "with files", n: notification{Markdown: "![graph](" + graph + ")", Files: shared},
+			markdown: "📎 graph", files: []string{"report.pdf", graph}},
+		{name: "missing image", n: notification{Markdown: "![graph](" + filepath.Join(dir, "missing.png") + ")"},
+			wantErr: "image of the message: stat " + filepath.Join(dir, "missing.png")},
+	} {
+		err := attachLocalImages(&tt.n)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("%s: attachLocalImages() error = %v, want %s", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || tt.n.Markdown != tt.markdown || tt.n.File != tt.file || !reflect.DeepEqual(tt.n.Files, tt.files) {
+			t.Errorf("%s: attachLocalImages() = %v, %q, file %q, files %q, want %q, %q, %q",
+				tt.name, err, tt.n.Markdown, tt.n.File, tt.n.Files, tt.markdown, tt.file, tt.files)
+		}
+	}
+	// the files of other notifications are not changed
+	if !reflect.DeepEqual(shared, []string{"report.pdf"}) {
+		t.Errorf("shared files = %q, want unchanged", shared)
+	}
+}
@@ -0,0 +1,162 @@
+// vault.go
+//
+// Bot token from HashiCorp Vault (flag --token-vault <path>#<field>). The
+// address of Vault is taken from VAULT_ADDR, the namespace (Vault
+// Enterprise) from VAULT_NAMESPACE. The command logs in with VAULT_TOKEN or
+// else with AppRole, the role ID and secret ID in VAULT_ROLE_ID and
+// VAULT_SECRET_ID, the mount path of AppRole in VAULT_APPROLE_PATH (default
+// approle):
+//
+//	export VAULT_ADDR=https://vault.example.com:8200 VAULT_ROLE_ID=... VAULT_SECRET_ID=...
+//	notify_by_webex_teams --token-vault secret/webex#token -t KMP-Team -r Alerts -m "build failed"
+//
+// Secrets of the KV secrets engine version 1 and 2 are supported, the
+// version is taken from the mount of the path. Vault is usually reached
+// without the proxy server of -p, the proxy environment variables and
+// NO_PROXY apply.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// vaultClient sends the requests to the Vault API.
+type vaultClient struct {
+	addr      string
+	namespace string
+	token     string
+	client    *http.Client
+}
+
+// vaultSecret returns the field of the Vault secret of ref <path>#<field>.
+func vaultSecret(ctx context.Context, ref string) (string, error) {
+	path, field, _ := strings.Cut(ref, "#")
+	path = strings.Trim(path, "/")
+	if len(path) == 0 || len(field) == 0 {
+		return "", fmt.Errorf("invalid Vault secret %q. use <path>#<field>, e.g. secret/webex#token", ref)
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if len(addr) == 0 {
+		return "", errors.New("no Vault address. set VAULT_ADDR")
+	}
+	httpClient, err := newHTTPClient("")
+	if err != nil {
+		return "", err
+	}
+	v := &vaultClient{
+		addr:      strings.TrimSuffix(addr, "/"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		client:    httpClient,
+	}
+	if err := v.login(ctx); err != nil {
+		return "", err
+	}
+
+	// KV version 2 keeps the secrets below <mount>/data/
+	readPath, kv2 := path, false
+	var mount struct {
+		Data struct {
+			Path    string            `json:"path"`
+			Options map[string]string `json:"options"`
+		} `json:"data"`
+	}
+	if err := v.request(ctx, "GET", "sys/internal/ui/mounts/"+path, nil, &mount); err == nil && mount.Data.Options["version"] == "2" {
+		prefix := strings.TrimSuffix(mount.Data.Path, "/")
+		readPath, kv2 = prefix+"/data"+strings.TrimPrefix(path, prefix), true
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := v.request(ctx, "GET", readPath, nil, &secret); err != nil {
+		return "", fmt.Errorf("reading %s: %w", path, err)
+	}
+	data := secret.Data
+	if kv2 {
+		data, _ = data["data"].(map[string]interface{})
+	}
+	s, _ := data[field].(string)
+	if len(s) == 0 {
+		return "", fmt.Errorf("no field %s in secret %s", field, path)
+	}
+	return s, nil
+}
+
+// login sets the Vault token of VAULT_TOKEN or of the AppRole login.
+func (v *vaultClient) login(ctx context.Context) error {
+	if v.token = os.Getenv("VAULT_TOKEN"); len(v.token) > 0 {
+		addSecret(v.token)
+		return nil
+	}
+	roleID, secretID := os.Getenv("VAULT_ROLE_ID"), os.Getenv("VAULT_SECRET_ID")
+	if len(roleID) == 0 || len(secretID) == 0 {
+		return errors.New("no Vault credentials. set VAULT_TOKEN or VAULT_ROLE_ID and VAULT_SECRET_ID")
+	}
+	addSecret(secretID)
+	mount := firstNonEmpty(os.Getenv("VAULT_APPROLE_PATH"), "approle")
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	body := map[string]string{"role_id": roleID, "secret_id": secretID}
+	if err := v.request(ctx, "POST", "auth/"+strings.Trim(mount, "/")+"/login", body, &resp); err != nil {
+		return fmt.Errorf("Vault AppRole login: %w", err)
+	}
+	if len(resp.Auth.ClientToken) == 0 {
+		return errors.New("Vault AppRole login returned no token")
+	}
+	v.token = resp.Auth.ClientToken
+	addSecret(v.token)
+	return nil
+}
+
+// request sends a request to the given path of the Vault API and decodes
+// the JSON response into v.
+func (v *vaultClient) request(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, v.addr+"/v1/"+path, reader)
+	if err != nil {
+		return err
+	}
+	if len(v.token) > 0 {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	if len(v.namespace) > 0 {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(data, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return fmt.Errorf("%s: %s", resp.Status, strings.Join(vaultErr.Errors, ", "))
+		}
+		return errors.New(resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVaultSecret(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/ci-approle/login" {
+			var in map[string]string
+			json.NewDecoder(r.Body).Decode(&in)
+			if in["role_id"] != "role" || in["secret_id"] != "secret" {
+				http.Error(w, `{"errors":["invalid role or secret ID"]}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"auth":{"client_token":"approle-token"}}`)
+			return
+		}
+		if token := r.Header.Get("X-Vault-Token"); token != "root-token" && token != "approle-token" || r.Header.Get("X-Vault-Namespace") != "ops" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/sys/internal/ui/mounts/secret/webex", "/v1/sys/internal/ui/mounts/secret/other":
+			fmt.Fprint(w, `{"data":{"path":"secret/","options":{"version":"2"}}}`)
+		case "/v1/sys/internal/ui/mounts/kv1/webex":
+			fmt.Fprint(w, `{"data":{"path":"kv1/","options":null}}`)
+		case "/v1/secret/data/webex":
+			fmt.Fprint(w, `{"data":{"data":{"token":"kv2-token"},"metadata":{"version":3}}}`)
+		case "/v1/kv1/webex":
+			fmt.Fprint(w, `{"data":{"token":"kv1-token"}}`)
+		default:
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("VAULT_ADDR", srv.URL+"/")
+	t.Setenv("VAULT_NAMESPACE", "ops")
+	t.Setenv("VAULT_TOKEN", "root-token")
+	t.Setenv("VAULT_APPROLE_PATH", "/ci-approle/")
+
+	for _, tt := range []struct {
+		name, ref, token, roleID string
+		want, wantErr            string
+	}{
+		{name: "kv2", ref: "/secret/webex#token", token: "root-token", want: "kv2-token"},
+		{name: "kv1", ref: "kv1/webex#token", token: "root-token", want: "kv1-token"},
+		{name: "approle", ref: "secret/webex#token", roleID: "role", want: "kv2-token"},
+		{name: "approle denied", ref: "secret/webex#token", roleID: "guest", wantErr: "Vault AppRole login: 400 Bad Request: invalid role or secret ID"},
+		{name: "no credentials", ref: "secret/webex#token", wantErr: "no Vault credentials"},
+		{name: "denied", ref: "secret/webex#token", token: "guest-token", wantErr: "reading secret/webex: 403 Forbidden: permission denied"},
+		{name: "no secret", ref: "secret/other#token", token: "root-token", wantErr: "reading secret/other: 404 Not Found"},
+		{name: "no field", ref: "kv1/webex#password", token: "root-token", wantErr: "no field password in secret kv1/webex"},
+		{name: "no field given", ref: "secret/webex", wantErr: "invalid Vault secret"},
+	} {
+		t.Setenv("VAULT_TOKEN", tt.token)
+		t.Setenv("VAULT_ROLE_ID", tt.roleID)
+		t.Setenv("VAULT_SECRET_ID", "secret")
+		got, err := vaultSecret(context.Background(), tt.ref)
+		if len(tt.wantErr) > 0 {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("%s: vaultSecret(%q) error = %v, want %s", tt.name, tt.ref, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: vaultSecret(%q) = %q, %v, want %q", tt.name, tt.ref, got, err, tt.want)
+		}
+	}
+
+	t.Setenv("VAULT_ADDR", "")
+	if _, err := vaultSecret(context.Background(), "secret/webex#token"); err == nil || !strings.Contains(err.Error(), "set VAULT_ADDR") {
+		t.Errorf("vaultSecret() without VAULT_ADDR error = %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package webex
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// AttachmentAction is the submission of a card, e.g. by Action.Submit.
+type AttachmentAction struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	MessageID string                 `json:"messageId"`
+	PersonID  string                 `json:"personId"`
+	RoomID    string                 `json:"roomId"`
+	Inputs    map[string]interface{} `json:"inputs"`
+	Created   time.Time              `json:"created"`
+}
+
+// GetAttachmentAction returns the card submission of an attachmentActions
+// webhook event.
+func (c *Client) GetAttachmentAction(ctx context.Context, actionID string) (*AttachmentAction, error) {
+	var a AttachmentAction
+	err := c.request(ctx, "GET", c.url("attachment/actions/"+url.PathEscape(actionID)), nil, nil, &a)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,45 @@
+package webex
+
+import (
+	"context"
+	"time"
+)
+
+// Meeting is a scheduled Webex meeting.
+type Meeting struct {
+	ID            string    `json:"id"`
+	MeetingNumber string    `json:"meetingNumber"`
+	Title         string    `json:"title"`
+	Password      string    `json:"password"`
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	Timezone      string    `json:"timezone"`
+	WebLink       string    `json:"webLink"`
+	SIPAddress    string    `json:"sipAddress"`
+	HostEmail     string    `json:"hostEmail"`
+}
+
+// MeetingRequest is the body of a new meeting. Start and End are required.
+type MeetingRequest struct {
+	Title    string    `json:"title"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Password string    `json:"password,omitempty"`
+	// EnabledJoinBeforeHost lets participants start the meeting without
+	// the host, e.g. an incident bridge scheduled by an integration
+	EnabledJoinBeforeHost bool `json:"enabledJoinBeforeHost,omitempty"`
+	JoinBeforeHostMinutes int  `json:"joinBeforeHostMinutes,omitempty"`
+}
+
+// CreateMeeting schedules a meeting. The Meetings API is not available for
+// bots, it needs a user or integration token with scope
+// meeting:schedules_write.
+func (c *Client) CreateMeeting(ctx context.Context, m *MeetingRequest) (*Meeting, error) {
+	var meeting Meeting
+	err := c.request(ctx, "POST", c.url("meetings"), nil, m, &meeting)
+	if err != nil {
+		return nil, err
+	}
+	c.debug("meeting created", "meetingID", meeting.ID, "meetingNumber", meeting.MeetingNumber)
+	return &meeting, nil
+}
@@ -0,0 +1,67 @@
+package webex
+
+import (
+	"context"
+	"net/url"
+	"time"
+)
+
+// Membership is the membership of a person in a room.
+type Membership struct {
+	ID                string    `json:"id"`
+	RoomID            string    `json:"roomId"`
+	PersonID          string    `json:"personId"`
+	PersonEmail       string    `json:"personEmail"`
+	PersonDisplayName string    `json:"personDisplayName"`
+	IsModerator       bool      `json:"isModerator"`
+	IsMonitor         bool      `json:"isMonitor"`
+	Created           time.Time `json:"created"`
+}
+
+// ListMemberships returns the memberships of a room.
+func (c *Client) ListMemberships(ctx context.Context, roomID string) ([]Membership, error) {
+	queryValues := url.Values{}
+	queryValues.Add("roomId", roomID)
+	queryValues.Add("max", "1000")
+
+	var resp struct {
+		Items []Membership `json:"items"`
+	}
+	err := c.request(ctx, "GET", c.url("memberships"), queryValues, nil, &resp)
+	return resp.Items, err
+}
+
+// CreateMembership adds the person with the given email address to a room.
+func (c *Client) CreateMembership(ctx context.Context, roomID, personEmail string, isModerator bool) (*Membership, error) {
+	req := struct {
+		RoomID      string `json:"roomId"`
+		PersonEmail string `json:"personEmail"`
+		IsModerator bool   `json:"isModerator"`
+	}{RoomID: roomID, PersonEmail: personEmail, IsModerator: isModerator}
+
+	var m Membership
+	err := c.request(ctx, "POST", c.url("memberships"), nil, &req, &m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// UpdateMembership makes the member a moderator of the room or not.
+func (c *Client) UpdateMembership(ctx context.Context, membershipID string, isModerator bool) (*Membership, error) {
+	req := struct {
+		IsModerator bool `json:"isModerator"`
+	}{IsModerator: isModerator}
+
+	var m Membership
+	err := c.request(ctx, "PUT", c.url("memberships/"+url.PathEscape(membershipID)), nil, &req, &m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// DeleteMembership removes a membership.
+func (c *Client) DeleteMembership(ctx context.Context, membershipID string) error {
+	return c.request(ctx, "DELETE", c.url("memberships/"+url.PathEscape(membershipID)), nil, nil, nil)
+}
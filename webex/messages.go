@@ -0,0 +1,279 @@
+package webex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is a Webex message.
+type Message struct {
+	ID          string    `json:"id"`
+	RoomID      string    `json:"roomId"`
+	RoomType    string    `json:"roomType"`
+	ParentID    string    `json:"parentId,omitempty"`
+	Text        string    `json:"text"`
+	Files       []string  `json:"files"`
+	PersonID    string    `json:"personId"`
+	PersonEmail string    `json:"personEmail"`
+	Markdown    string    `json:"markdown"`
+	HTML        string    `json:"html"`
+	Created     time.Time `json:"created"`
+	// Attachments are the card attachments of the message
+	Attachments []json.RawMessage `json:"attachments,omitempty"`
+}
+
+// MessageRequest is the body of a new message. Either RoomID or
+// ToPersonEmail must be set. ParentID makes the message a reply in the
+// thread of the parent message. Attachments are card attachments, see
+// https://developer.webex.com/docs/api/guides/cards
+type MessageRequest struct {
+	RoomID        string            `json:"roomId,omitempty"`
+	ToPersonEmail string            `json:"toPersonEmail,omitempty"`
+	ParentID      string            `json:"parentId,omitempty"`
+	Markdown      string            `json:"markdown"`
+	Attachments   []json.RawMessage `json:"attachments,omitempty"`
+}
+
+// MaxFileSize is the maximum size of a file sent with a message.
+const MaxFileSize = 100 << 20
+
+// CheckFile returns an error if the local file cannot be sent with a
+// message, without reading it.
+func CheckFile(filename string) error {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !fi.Mode().IsRegular():
+		return fmt.Errorf("%s is not a regular file", filename)
+	case fi.Size() == 0:
+		return fmt.Errorf("%s is empty", filename)
+	case fi.Size() > MaxFileSize:
+		return fmt.Errorf("%s has %.1f MB, files are limited to %d MB", filename, float64(fi.Size())/(1<<20), MaxFileSize>>20)
+	}
+	return nil
+}
+
+// CreateMessage sends a new message.
+func (c *Client) CreateMessage(ctx context.Context, m *MessageRequest) (*Message, error) {
+	m, err := c.filterMessage(m)
+	if err != nil {
+		return nil, err
+	}
+	var msg Message
+	err = c.request(ctx, "POST", c.url("messages"), nil, m, &msg)
+	if err != nil {
+		return nil, err
+	}
+	c.debug("message created", "messageID", msg.ID, "created", msg.Created)
+	return &msg, nil
+}
+
+// UploadFile sends a new message with the markdown text and the local file
+// to the room.
+func (c *Client) UploadFile(ctx context.Context, roomID, markdown, filename string) (*Message, error) {
+	return c.UploadFileWith(ctx, &MessageRequest{RoomID: roomID, Markdown: markdown}, filename)
+}
+
+// UploadFileWith sends a new message with the local file, e.g. as a reply in
+// a thread. The attachments of m are not supported.
+func (c *Client) UploadFileWith(ctx context.Context, m *MessageRequest, filename string) (*Message, error) {
+	if len(m.Attachments) > 0 {
+		return nil, fmt.Errorf("card attachments cannot be sent with a file")
+	}
+	m, err := c.filterMessage(m)
+	if err != nil {
+		return nil, err
+	}
+	if c.FilterFile != nil {
+		file, done, err := c.FilterFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if done != nil {
+			defer done()
+		}
+		filename = file
+	}
+	if err := CheckFile(filename); err != nil {
+		return nil, err
+	}
+	fd, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	buf := new(bytes.Buffer)
+	w := multipart.NewWriter(buf)
+
+	fw, err := createFormFile(w, "files", filename)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(fw, fd)
+	if err != nil {
+		return nil, err
+	}
+
+	params := map[string]string{
+		"roomId":        m.RoomID,
+		"toPersonEmail": m.ToPersonEmail,
+		"parentId":      m.ParentID,
+		"markdown":      m.Markdown,
+		"roomType":      "group",
+	}
+	for key, val := range params {
+		if len(val) == 0 && key != "markdown" {
+			continue
+		}
+		err = w.WriteField(key, val)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Important if you do not close the multipart writer you will not have a
+	// terminating boundry
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	c.debug("uploading file", "file", filename, "requestBytes", buf.Len())
+	req, err := c.newRequest(ctx, "POST", c.url("messages"), nil, buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+
+	var msg Message
+	err = c.do(req, &msg)
+	if err != nil {
+		return nil, err
+	}
+	c.debug("message created", "messageID", msg.ID, "created", msg.Created)
+	return &msg, nil
+}
+
+func createFormFile(w *multipart.Writer, fieldname, filename string) (io.Writer, error) {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, fieldname, filename))
+	h.Set("Content-Type", FileContentType(filename))
+	return w.CreatePart(h)
+}
+
+// FileContentType returns the content type of the file by its extension.
+func FileContentType(filename string) string {
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename)))
+	if len(contentType) == 0 {
+		return "application/octet-stream"
+	}
+	return contentType
+}
+
+// ListMessages calls fn for the messages of a room, newest first, until fn
+// returns false or there are no more messages. Messages created at or after
+// before are skipped if before is not zero. Bots only see the messages of
+// group rooms which mention them.
+func (c *Client) ListMessages(ctx context.Context, roomID string, before time.Time, fn func(m *Message) bool) error {
+	queryValues := url.Values{}
+	queryValues.Add("roomId", roomID)
+	queryValues.Add("max", "100")
+	if !before.IsZero() {
+		queryValues.Add("before", before.UTC().Format(time.RFC3339Nano))
+	}
+
+	return c.listMessages(ctx, queryValues, fn)
+}
+
+// ListReplies calls fn for the replies to the parent message in a room,
+// newest first, until fn returns false or there are no more replies.
+func (c *Client) ListReplies(ctx context.Context, roomID, parentID string, fn func(m *Message) bool) error {
+	queryValues := url.Values{}
+	queryValues.Add("roomId", roomID)
+	queryValues.Add("parentId", parentID)
+	queryValues.Add("max", "100")
+	return c.listMessages(ctx, queryValues, fn)
+}
+
+func (c *Client) listMessages(ctx context.Context, queryValues url.Values, fn func(m *Message) bool) error {
+	return c.list(ctx, c.url("messages"), queryValues, func(items json.RawMessage) (bool, error) {
+		var messages []Message
+		if err := json.Unmarshal(items, &messages); err != nil {
+			return false, err
+		}
+		for i := range messages {
+			if !fn(&messages[i]) {
+				return false, nil
+			}
+		}
+		return len(messages) > 0, nil
+	})
+}
+
+// GetMessage returns the message with the given ID.
+func (c *Client) GetMessage(ctx context.Context, messageID string) (*Message, error) {
+	var msg Message
+	err := c.request(ctx, "GET", c.url("messages/"+url.PathEscape(messageID)), nil, nil, &msg)
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// EditMessage replaces the markdown text of a message. The room of the
+// message is looked up first, as the API requires it.
+func (c *Client) EditMessage(ctx context.Context, messageID, markdown string) (*Message, error) {
+	old, err := c.GetMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.filterMessage(&MessageRequest{RoomID: old.RoomID, Markdown: markdown})
+	if err != nil {
+		return nil, err
+	}
+	edit := struct {
+		RoomID   string `json:"roomId"`
+		Markdown string `json:"markdown"`
+	}{RoomID: old.RoomID, Markdown: m.Markdown}
+
+	var msg Message
+	err = c.request(ctx, "PUT", c.url("messages/"+url.PathEscape(messageID)), nil, &edit, &msg)
+	if err != nil {
+		return nil, err
+	}
+	c.debug("message edited", "messageID", msg.ID)
+	return &msg, nil
+}
+
+// filterMessage returns the copy of m filtered by FilterMessage, or m
+// without filter.
+func (c *Client) filterMessage(m *MessageRequest) (*MessageRequest, error) {
+	if c.FilterMessage == nil {
+		return m, nil
+	}
+	filtered := *m
+	filtered.Attachments = append([]json.RawMessage(nil), m.Attachments...)
+	if err := c.FilterMessage(&filtered); err != nil {
+		return nil, err
+	}
+	return &filtered, nil
+}
+
+// DeleteMessage deletes the message with the given ID.
+func (c *Client) DeleteMessage(ctx context.Context, messageID string) error {
+	return c.request(ctx, "DELETE", c.url("messages/"+url.PathEscape(messageID)), nil, nil, nil)
+}
@@ -0,0 +1,76 @@
+package webex
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Person is a Webex user or bot.
+type Person struct {
+	ID          string    `json:"id"`
+	Emails      []string  `json:"emails"`
+	DisplayName string    `json:"displayName"`
+	NickName    string    `json:"nickName"`
+	OrgID       string    `json:"orgId"`
+	Type        string    `json:"type"`
+	Created     time.Time `json:"created"`
+}
+
+// Organization is a Webex organization.
+type Organization struct {
+	ID          string    `json:"id"`
+	DisplayName string    `json:"displayName"`
+	Created     time.Time `json:"created"`
+}
+
+// Me returns the person the token belongs to, e.g. the bot.
+func (c *Client) Me(ctx context.Context) (*Person, error) {
+	var p Person
+	err := c.request(ctx, "GET", c.url("people/me"), nil, nil, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetPerson returns the person with the given ID, e.g. the PersonID of a
+// card submission.
+func (c *Client) GetPerson(ctx context.Context, personID string) (*Person, error) {
+	var p Person
+	err := c.request(ctx, "GET", c.url("people/"+url.PathEscape(personID)), nil, nil, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// PersonByEmail returns the person with the given email address.
+func (c *Client) PersonByEmail(ctx context.Context, email string) (*Person, error) {
+	queryValues := url.Values{}
+	queryValues.Add("email", email)
+
+	var resp struct {
+		Items []Person `json:"items"`
+	}
+	err := c.request(ctx, "GET", c.url("people"), queryValues, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Items) == 0 {
+		return nil, fmt.Errorf("no person found with email address %s", email)
+	}
+	return &resp.Items[0], nil
+}
+
+// GetOrganization returns the organization with the given ID, e.g. the
+// OrgID of a person. Bots can only read their own organization.
+func (c *Client) GetOrganization(ctx context.Context, orgID string) (*Organization, error) {
+	var o Organization
+	err := c.request(ctx, "GET", c.url("organizations/"+url.PathEscape(orgID)), nil, nil, &o)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
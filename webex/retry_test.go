@@ -0,0 +1,36 @@
+package webex
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryWait(t *testing.T) {
+	for _, tt := range []struct {
+		initial, max time.Duration
+		retryAfter   string
+		attempt      int
+		want         time.Duration
+	}{
+		{attempt: 1, want: time.Second},
+		{attempt: 3, want: 4 * time.Second},
+		{initial: 2 * time.Second, attempt: 2, want: 4 * time.Second},
+		{max: 3 * time.Second, attempt: 3, want: 3 * time.Second},
+		{attempt: 100, want: maxRetryWait},
+		{initial: 3 * time.Hour, attempt: 1, want: maxRetryWait},
+		{retryAfter: "30", attempt: 5, want: 30 * time.Second},
+		{retryAfter: "30", max: 10 * time.Second, attempt: 1, want: 10 * time.Second},
+		{retryAfter: "9223372036854775807", attempt: 1, want: maxRetryWait},
+		{retryAfter: "-1", attempt: 2, want: 2 * time.Second},
+	} {
+		c := &Client{RetryInitialWait: tt.initial, RetryMaxWait: tt.max}
+		header := http.Header{}
+		if len(tt.retryAfter) > 0 {
+			header.Set("Retry-After", tt.retryAfter)
+		}
+		if got := c.retryWait(header, tt.attempt); got != tt.want {
+			t.Errorf("retryWait(%q, %d) with initial %v, max %v = %v, want %v", tt.retryAfter, tt.attempt, tt.initial, tt.max, got, tt.want)
+		}
+	}
+}
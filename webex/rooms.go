@@ -0,0 +1,145 @@
+package webex
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Room is a Webex room (space).
+type Room struct {
+	ID                 string    `json:"id"`
+	Title              string    `json:"title"`
+	Type               string    `json:"type"`
+	IsLocked           bool      `json:"isLocked"`
+	IsAnnouncementOnly bool      `json:"isAnnouncementOnly"`
+	LastActivity       time.Time `json:"lastActivity"`
+	TeamID             string    `json:"teamId,omitempty"`
+	CreatorID          string    `json:"creatorId"`
+	Created            time.Time `json:"created"`
+}
+
+// RoomRequest is the body of a new room. A locked room is moderated, i.e.
+// only moderators can add and remove members. The creator of a locked room
+// is its first moderator.
+type RoomRequest struct {
+	Title              string `json:"title"`
+	TeamID             string `json:"teamId,omitempty"`
+	IsLocked           bool   `json:"isLocked,omitempty"`
+	IsAnnouncementOnly bool   `json:"isAnnouncementOnly,omitempty"`
+}
+
+type roomsResp struct {
+	Items []Room `json:"items"`
+}
+
+// ListRooms returns the rooms the bot or user is a member of, optionally
+// filtered by teamId and type ("group" or "direct").
+func (c *Client) ListRooms(ctx context.Context, teamID, roomType string) ([]Room, error) {
+	queryValues := url.Values{}
+	if len(teamID) > 0 {
+		queryValues.Add("teamId", teamID)
+	}
+	if len(roomType) > 0 {
+		queryValues.Add("type", roomType)
+	}
+	queryValues.Add("max", "1000")
+
+	var rr roomsResp
+	err := c.request(ctx, "GET", c.url("rooms"), queryValues, nil, &rr)
+	return rr.Items, err
+}
+
+// TeamIDByName returns the ID of the team with the given name. The team is
+// found by its general room, which has the name of the team.
+func (c *Client) TeamIDByName(ctx context.Context, name string) (string, error) {
+	rooms, err := c.ListRooms(ctx, "", "group")
+	if err != nil {
+		return "", err
+	}
+	for _, v := range rooms {
+		if v.Title == name {
+			return v.TeamID, nil
+		}
+	}
+	return "", fmt.Errorf("No room with name: %s was found", name)
+}
+
+// FindOrCreateRoom returns the ID of the room with the given title in the
+// team. The room is created if it does not exist.
+func (c *Client) FindOrCreateRoom(ctx context.Context, teamID, title string) (string, error) {
+	return c.FindOrCreateRoomWith(ctx, &RoomRequest{TeamID: teamID, Title: title})
+}
+
+// FindOrCreateRoomWith is like FindOrCreateRoom, but a missing room is
+// created with the settings of r, e.g. locked.
+func (c *Client) FindOrCreateRoomWith(ctx context.Context, r *RoomRequest) (string, error) {
+	rooms, err := c.ListRooms(ctx, r.TeamID, "group")
+	if err != nil {
+		return "", err
+	}
+	for _, v := range rooms {
+		if v.Title == r.Title {
+			return v.ID, nil
+		}
+	}
+
+	c.debug("room not found", "title", r.Title)
+	room, err := c.CreateRoomWith(ctx, r)
+	if err != nil {
+		return "", err
+	}
+	return room.ID, nil
+}
+
+// CreateRoom creates a room in the team.
+func (c *Client) CreateRoom(ctx context.Context, title, teamID string) (*Room, error) {
+	return c.CreateRoomWith(ctx, &RoomRequest{Title: title, TeamID: teamID})
+}
+
+// CreateRoomWith creates a room with the settings of r.
+func (c *Client) CreateRoomWith(ctx context.Context, r *RoomRequest) (*Room, error) {
+	var room Room
+	err := c.request(ctx, "POST", c.url("rooms"), nil, r, &room)
+	if err != nil {
+		return nil, err
+	}
+	c.debug("room created", "roomID", room.ID, "locked", room.IsLocked)
+	return &room, nil
+}
+
+// GetRoom returns the room with the given ID.
+func (c *Client) GetRoom(ctx context.Context, roomID string) (*Room, error) {
+	var room Room
+	err := c.request(ctx, "GET", c.url("rooms/"+url.PathEscape(roomID)), nil, nil, &room)
+	if err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
+// LockRoom locks (moderates) or unlocks a room. announcementOnly makes a
+// locked room read-only for everyone except the moderators. Only a moderator
+// can change the lock. The room is looked up first, as the API requires its
+// title.
+func (c *Client) LockRoom(ctx context.Context, roomID string, locked, announcementOnly bool) (*Room, error) {
+	old, err := c.GetRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	// without omitempty, so unlocking sends isLocked false
+	update := struct {
+		Title              string `json:"title"`
+		IsLocked           bool   `json:"isLocked"`
+		IsAnnouncementOnly bool   `json:"isAnnouncementOnly"`
+	}{Title: old.Title, IsLocked: locked, IsAnnouncementOnly: locked && announcementOnly}
+
+	var room Room
+	err = c.request(ctx, "PUT", c.url("rooms/"+url.PathEscape(roomID)), nil, &update, &room)
+	if err != nil {
+		return nil, err
+	}
+	c.debug("room updated", "roomID", room.ID, "locked", room.IsLocked, "announcementOnly", room.IsAnnouncementOnly)
+	return &room, nil
+}
@@ -0,0 +1,38 @@
+package webex
+
+import (
+	"context"
+	"time"
+)
+
+// Team is a Webex team.
+type Team struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatorID string    `json:"creatorId"`
+	Created   time.Time `json:"created"`
+}
+
+// ListTeams returns the teams the bot or user is a member of.
+func (c *Client) ListTeams(ctx context.Context) ([]Team, error) {
+	var resp struct {
+		Items []Team `json:"items"`
+	}
+	err := c.request(ctx, "GET", c.url("teams"), nil, nil, &resp)
+	return resp.Items, err
+}
+
+// CreateTeam creates a team with the given name. The bot or user becomes its
+// moderator, the team gets a general room with the name of the team.
+func (c *Client) CreateTeam(ctx context.Context, name string) (*Team, error) {
+	req := struct {
+		Name string `json:"name"`
+	}{Name: name}
+
+	var t Team
+	err := c.request(ctx, "POST", c.url("teams"), nil, &req, &t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
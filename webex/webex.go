@@ -0,0 +1,428 @@
+// Package webex is a small client for the Cisco Webex REST API. It covers
+// what notify_by_webex_teams needs: finding and creating rooms, sending
+// messages with markdown, card attachments and file uploads, and deleting
+// messages. Only the standard library is used.
+//
+// example:
+//
+//	client := webex.NewClient("<bot token>")
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//	defer cancel()
+//	teamID, err := client.TeamIDByName(ctx, "KMP-Team")
+//	...
+//	roomID, err := client.FindOrCreateRoom(ctx, teamID, "Alerts")
+//	...
+//	msg, err := client.CreateMessage(ctx, &webex.MessageRequest{RoomID: roomID, Markdown: "Happy hacking"})
+//
+// All API calls take a context.Context for deadlines and cancellation.
+package webex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Webex API endpoints for Client.BaseURL.
+const (
+	// DefaultBaseURL is the endpoint used if Client.BaseURL is empty.
+	DefaultBaseURL = "https://webexapis.com/v1"
+	// USGovBaseURL is the endpoint of Webex for Government (FedRAMP).
+	USGovBaseURL = "https://api-usgov.webex.com/v1"
+)
+
+// TokenSource supplies the access token for each API request.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// StaticToken is a TokenSource always returning the same token, e.g. a bot token.
+type StaticToken string
+
+// Token returns t.
+func (t StaticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+// TokenFunc adapts a function to a TokenSource, e.g. for tokens which are
+// refreshed.
+type TokenFunc func() (string, error)
+
+// Token calls f.
+func (f TokenFunc) Token() (string, error) {
+	return f()
+}
+
+// Client is a Webex API client.
+type Client struct {
+	TokenSource TokenSource
+	// BaseURL of the API, e.g. of the Webex sandbox, a recording proxy or a
+	// webextest.Server. DefaultBaseURL if empty
+	BaseURL string
+	// HTTPClient is used for all requests. http.DefaultClient if nil
+	HTTPClient *http.Client
+	// Logger receives debug level records of the requests. no output if nil
+	Logger *slog.Logger
+	// RateLimitRetries is the number of retries of a request answered with
+	// 429 Too Many Requests or a status code of RetryOn, after the time of
+	// the Retry-After header or an exponential backoff. 0 means no retries
+	RateLimitRetries int
+	// RetryInitialWait is the backoff before the first retry of a response
+	// without Retry-After header, doubled for every further retry. 1s if 0
+	RetryInitialWait time.Duration
+	// RetryMaxWait limits the wait before a retry, also of the Retry-After
+	// header. 1h if 0
+	RetryMaxWait time.Duration
+	// RetryOn reports whether a response with the given status code is
+	// retried. only 429 Too Many Requests if nil
+	RetryOn func(statusCode int) bool
+	// OnRequest is called after every API request, e.g. to collect metrics
+	OnRequest func(info *RequestInfo)
+	// FilterMessage is called with a copy of every message before it is
+	// created or edited, e.g. to redact secrets. The message is not sent if
+	// it returns an error
+	FilterMessage func(m *MessageRequest) error
+	// FilterFile is called with the local file of every upload and returns
+	// the file to upload instead, e.g. a redacted copy with the same name,
+	// and a function called after the upload or nil
+	FilterFile func(filename string) (string, func(), error)
+}
+
+// RequestInfo describes a finished API request, see Client.OnRequest.
+type RequestInfo struct {
+	Method string
+	// Resource is the first element of the API path, e.g. "messages"
+	Resource string
+	// StatusCode is 0 if no response was received
+	StatusCode int
+	Duration   time.Duration
+	// Attempt is 1 for the first try and counts the retries
+	Attempt int
+}
+
+// NewClient returns a client using the given bot or access token.
+func NewClient(token string) *Client {
+	return &Client{TokenSource: StaticToken(token)}
+}
+
+// APIError is returned for requests answered with a HTTP status code >= 400.
+type APIError struct {
+	StatusCode int
+	Body       string
+	// Message is the error message of the body, if it is a Webex error
+	Message string
+	// TrackingID identifies the request for the Cisco support
+	TrackingID string
+}
+
+// newAPIError returns the error of a response with the given status code,
+// header and body. Webex answers errors with a JSON body:
+//
+//	{"message":"...","errors":[{"description":"..."}],"trackingId":"ROUTER_..."}
+func newAPIError(statusCode int, header http.Header, body []byte) *APIError {
+	e := &APIError{StatusCode: statusCode, Body: string(body), TrackingID: header.Get("TrackingID")}
+	var resp struct {
+		Message string `json:"message"`
+		Errors  []struct {
+			Description string `json:"description"`
+		} `json:"errors"`
+		TrackingID string `json:"trackingId"`
+	}
+	if json.Unmarshal(body, &resp) == nil {
+		e.Message = resp.Message
+		if len(e.Message) == 0 && len(resp.Errors) > 0 {
+			e.Message = resp.Errors[0].Description
+		}
+		if len(resp.TrackingID) > 0 {
+			e.TrackingID = resp.TrackingID
+		}
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("Webex API error. HTTP status code: %d: %s", e.StatusCode, e.Body)
+	}
+	if len(e.TrackingID) == 0 {
+		return fmt.Sprintf("Webex API error. HTTP status code: %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("Webex API error. HTTP status code: %d: %s (trackingId %s)", e.StatusCode, e.Message, e.TrackingID)
+}
+
+func (c *Client) debug(msg string, args ...interface{}) {
+	if c.Logger != nil {
+		c.Logger.Debug(msg, args...)
+	}
+}
+
+// url returns the URL of an API resource, e.g. "rooms".
+func (c *Client) url(resource string) string {
+	base := c.BaseURL
+	if len(base) == 0 {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/" + resource
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// newRequest returns an authorized request for the Webex API.
+func (c *Client) newRequest(ctx context.Context, method, baseURL string, values url.Values, body io.Reader) (*http.Request, error) {
+	uri := baseURL
+	if len(values) > 0 {
+		uri = fmt.Sprintf("%s?%s", baseURL, values.Encode())
+	}
+	c.debug("webex request", "method", method, "url", uri)
+	req, err := http.NewRequestWithContext(ctx, method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.TokenSource == nil {
+		return nil, fmt.Errorf("webex: no token source")
+	}
+	token, err := c.TokenSource.Token()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	return req, nil
+}
+
+// do sends req and decodes the JSON response into v, if v is not nil.
+func (c *Client) do(req *http.Request, v interface{}) error {
+	_, err := c.doHeader(req, v)
+	return err
+}
+
+// doHeader is like do but also returns the response header. Requests
+// answered with 429 Too Many Requests or a status code of RetryOn are
+// retried, see RateLimitRetries.
+func (c *Client) doHeader(req *http.Request, v interface{}) (http.Header, error) {
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			c.observe(req, 0, start, attempt)
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.observe(req, resp.StatusCode, start, attempt)
+		if err != nil {
+			return nil, err
+		}
+		c.debug("webex response", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode)
+
+		if c.retryOn(resp.StatusCode) && attempt <= c.RateLimitRetries {
+			wait := c.retryWait(resp.Header, attempt)
+			c.debug("retrying", "status", resp.StatusCode, "retryAfter", wait, "attempt", attempt)
+			if err := c.rewind(req, wait); err == nil {
+				continue
+			}
+		}
+		if resp.StatusCode >= 400 {
+			return nil, newAPIError(resp.StatusCode, resp.Header, body)
+		}
+
+		if v == nil || len(body) == 0 {
+			return resp.Header, nil
+		}
+		return resp.Header, json.Unmarshal(body, v)
+	}
+}
+
+// rewind waits before req is sent again and resets its body. An error is
+// returned if the body cannot be reset or the context ends before.
+func (c *Client) rewind(req *http.Request, wait time.Duration) error {
+	if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < wait {
+		return context.DeadlineExceeded
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return errors.New("webex: request body cannot be sent again")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		req.Body = body
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// retryOn reports whether a response with the given status code is retried.
+func (c *Client) retryOn(statusCode int) bool {
+	if c.RetryOn == nil {
+		return statusCode == http.StatusTooManyRequests
+	}
+	return c.RetryOn(statusCode)
+}
+
+// maxRetryWait limits the wait before a retry if RetryMaxWait is 0, so that
+// neither the backoff nor a huge Retry-After header overflows time.Duration.
+const maxRetryWait = time.Hour
+
+// retryWait returns the wait time of the Retry-After header in seconds, or
+// an exponential backoff if the header is missing, at most RetryMaxWait.
+func (c *Client) retryWait(header http.Header, attempt int) time.Duration {
+	limit := c.RetryMaxWait
+	if limit <= 0 {
+		limit = maxRetryWait
+	}
+	wait := c.RetryInitialWait
+	if wait <= 0 {
+		wait = time.Second
+	}
+	for i := 1; i < attempt && wait < limit; i++ {
+		wait *= 2
+	}
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		if int64(seconds) > int64(limit/time.Second) {
+			return limit
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait > limit {
+		wait = limit
+	}
+	return wait
+}
+
+func (c *Client) observe(req *http.Request, status int, start time.Time, attempt int) {
+	if c.OnRequest == nil {
+		return
+	}
+	c.OnRequest(&RequestInfo{
+		Method:     req.Method,
+		Resource:   c.resource(req.URL.Path),
+		StatusCode: status,
+		Duration:   time.Since(start),
+		Attempt:    attempt,
+	})
+}
+
+// resource returns the first element of the API path, e.g. "messages" for
+// /v1/messages/<id>.
+func (c *Client) resource(path string) string {
+	if base, err := url.Parse(c.url("")); err == nil {
+		path = strings.TrimPrefix(path, base.Path)
+	}
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.Index(path, "/"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// list requests a paged list resource and calls fn with the JSON items of
+// every page, following the "next" links of the Link header until there
+// are no more pages or fn returns false.
+func (c *Client) list(ctx context.Context, baseURL string, values url.Values, fn func(items json.RawMessage) (bool, error)) error {
+	uri := baseURL
+	for len(uri) > 0 {
+		req, err := c.newRequest(ctx, "GET", uri, values, nil)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Items json.RawMessage `json:"items"`
+		}
+		header, err := c.doHeader(req, &page)
+		if err != nil {
+			return err
+		}
+		more, err := fn(page.Items)
+		if err != nil || !more {
+			return err
+		}
+		// the next link already contains all query parameters
+		uri, values = nextLink(header), nil
+		if len(uri) > 0 {
+			if err := c.checkNextLink(uri); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkNextLink returns an error if the next link of a page points to
+// another scheme or host than BaseURL, because the token is sent with the
+// request.
+func (c *Client) checkNextLink(uri string) error {
+	next, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid next page link %q: %w", uri, err)
+	}
+	base, err := url.Parse(c.url(""))
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(next.Scheme, base.Scheme) || !strings.EqualFold(next.Host, base.Host) {
+		return fmt.Errorf("next page link %q does not point to %s://%s", uri, base.Scheme, base.Host)
+	}
+	return nil
+}
+
+// nextLink returns the URL of the next page from a Link header like
+// <https://webexapis.com/v1/messages?...>; rel="next".
+func nextLink(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			if len(parts) < 2 {
+				continue
+			}
+			for _, param := range parts[1:] {
+				if strings.ReplaceAll(strings.TrimSpace(param), " ", "") == `rel="next"` {
+					return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// request sends a JSON request. in is encoded as request body if not nil.
+func (c *Client) request(ctx context.Context, method, baseURL string, values url.Values, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := c.newRequest(ctx, method, baseURL, values, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Add("Content-Type", "application/json; charset=utf-8")
+	}
+	return c.do(req, out)
+}
@@ -0,0 +1,791 @@
+package webex_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+	"github.com/hgrimm/notify_by_webex_teams/webex/webextest"
+)
+
+const testToken = "test-token"
+
+func newTestClient(t *testing.T) (*webex.Client, *webextest.Server) {
+	t.Helper()
+	srv := webextest.NewServer(testToken)
+	t.Cleanup(srv.Close)
+	client := webex.NewClient(testToken)
+	client.BaseURL = srv.BaseURL()
+	return client, srv
+}
+
+func TestTeamIDByName(t *testing.T) {
+	client, srv := newTestClient(t)
+	teamID := srv.AddTeam("KMP-Team")
+
+	got, err := client.TeamIDByName(context.Background(), "KMP-Team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != teamID {
+		t.Errorf("TeamIDByName() = %q, want %q", got, teamID)
+	}
+
+	_, err = client.TeamIDByName(context.Background(), "unknown")
+	if err == nil {
+		t.Error("TeamIDByName() of unknown team: expected error")
+	}
+}
+
+func TestFindOrCreateRoom(t *testing.T) {
+	client, srv := newTestClient(t)
+	teamID := srv.AddTeam("KMP-Team")
+	existing := srv.AddRoom("Alerts", teamID, "group")
+	srv.AddRoom("Alerts", "other-team", "group")
+
+	got, err := client.FindOrCreateRoom(context.Background(), teamID, "Alerts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != existing.ID {
+		t.Errorf("FindOrCreateRoom() = %q, want existing room %q", got, existing.ID)
+	}
+
+	created, err := client.FindOrCreateRoom(context.Background(), teamID, "New Room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, r := range srv.Rooms() {
+		if r.ID == created && r.Title == "New Room" && r.TeamID == teamID {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("FindOrCreateRoom() did not create room %q", created)
+	}
+}
+
+func TestLockRoom(t *testing.T) {
+	client, srv := newTestClient(t)
+	ctx := context.Background()
+
+	room, err := client.CreateRoomWith(ctx, &webex.RoomRequest{Title: "Announcements", IsLocked: true, IsAnnouncementOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !room.IsLocked || !room.IsAnnouncementOnly {
+		t.Errorf("CreateRoomWith() = %+v, want locked announcement room", room)
+	}
+	members := srv.Memberships()
+	if len(members) != 1 || members[0].PersonEmail != webextest.BotEmail || !members[0].IsModerator {
+		t.Errorf("memberships = %+v, want the bot as moderator", members)
+	}
+
+	room, err = client.LockRoom(ctx, room.ID, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.IsLocked || room.IsAnnouncementOnly || room.Title != "Announcements" {
+		t.Errorf("LockRoom(unlock) = %+v", room)
+	}
+	room, err = client.LockRoom(ctx, room.ID, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !room.IsLocked || room.IsAnnouncementOnly {
+		t.Errorf("LockRoom(lock) = %+v", room)
+	}
+
+	var apiErr *webex.APIError
+	_, err = client.LockRoom(ctx, "room-unknown", true, false)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("LockRoom() of unknown room: err = %v", err)
+	}
+}
+
+func TestCreateMessage(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "group")
+
+	m, err := client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: room.ID, Markdown: "**Happy** hacking"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID == "" || m.RoomID != room.ID {
+		t.Errorf("CreateMessage() = %+v", m)
+	}
+	msgs := srv.Messages()
+	if len(msgs) != 1 || msgs[0].Markdown != "**Happy** hacking" {
+		t.Errorf("server messages = %+v", msgs)
+	}
+}
+
+func TestGetMessageAttachments(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "group")
+	card := json.RawMessage(`{"contentType":"application/vnd.microsoft.card.adaptive","content":{"type":"AdaptiveCard","version":"1.3","body":[]}}`)
+
+	m, err := client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: room.ID, Markdown: "card", Attachments: []json.RawMessage{card}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetMessage(context.Background(), m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Attachments) != 1 || !strings.Contains(string(got.Attachments[0]), "AdaptiveCard") {
+		t.Errorf("GetMessage() attachments = %s", got.Attachments)
+	}
+}
+
+func TestCreateDirectMessage(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	m, err := client.CreateMessage(context.Background(), &webex.MessageRequest{ToPersonEmail: "john.smith@example.com", Markdown: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.RoomType != "direct" || m.RoomID == "" {
+		t.Errorf("CreateMessage() = %+v", m)
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "group")
+
+	filename := filepath.Join(t.TempDir(), "graph.png")
+	content := []byte("\x89PNG fake image")
+	if err := ioutil.WriteFile(filename, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := client.UploadFile(context.Background(), room.ID, "see graph", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 {
+		t.Fatalf("UploadFile() files = %v", m.Files)
+	}
+	if got := srv.File(m.Files[0]); string(got) != string(content) {
+		t.Errorf("uploaded content = %q, want %q", got, content)
+	}
+
+	reply, err := client.UploadFileWith(context.Background(), &webex.MessageRequest{RoomID: room.ID, ParentID: m.ID}, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.ParentID != m.ID || len(reply.Files) != 1 {
+		t.Errorf("UploadFileWith() = parent %q, files %v, want parent %q", reply.ParentID, reply.Files, m.ID)
+	}
+
+	_, err = client.UploadFile(context.Background(), room.ID, "", filepath.Join(t.TempDir(), "missing.png"))
+	if !os.IsNotExist(err) {
+		t.Errorf("UploadFile() of missing file: err = %v", err)
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.png")
+	large := filepath.Join(dir, "large.zip")
+	small := filepath.Join(dir, "small.pdf")
+	if err := ioutil.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(small, []byte("%PDF-1.4"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(large, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(large, webex.MaxFileSize+1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		file    string
+		wantErr bool
+	}{
+		{small, false},
+		{empty, true},
+		{large, true},
+		{dir, true},
+		{filepath.Join(dir, "missing.png"), true},
+	} {
+		if err := webex.CheckFile(tt.file); (err != nil) != tt.wantErr {
+			t.Errorf("CheckFile(%s) = %v, want error %v", filepath.Base(tt.file), err, tt.wantErr)
+		}
+	}
+
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "group")
+	if _, err := client.UploadFile(context.Background(), room.ID, "", large); err == nil {
+		t.Error("UploadFile() of a too large file: no error")
+	}
+	if n := len(srv.Messages()); n != 0 {
+		t.Errorf("%d messages after a failed upload, want 0", n)
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "group")
+	m, err := client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: room.ID, Markdown: "bye"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.DeleteMessage(context.Background(), m.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Messages()); n != 0 {
+		t.Errorf("%d messages left after delete", n)
+	}
+
+	var apiErr *webex.APIError
+	err = client.DeleteMessage(context.Background(), m.ID)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("DeleteMessage() of deleted message: err = %v", err)
+	}
+}
+
+func TestInvalidToken(t *testing.T) {
+	client, _ := newTestClient(t)
+	client.TokenSource = webex.StaticToken("wrong")
+
+	var apiErr *webex.APIError
+	_, err := client.ListRooms(context.Background(), "", "")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 401 {
+		t.Fatalf("ListRooms() with wrong token: err = %v", err)
+	}
+	if len(apiErr.Message) == 0 || !strings.HasPrefix(apiErr.TrackingID, "WEBEXTEST_") {
+		t.Errorf("APIError = %+v, want message and tracking ID", apiErr)
+	}
+	if !strings.Contains(err.Error(), apiErr.TrackingID) {
+		t.Errorf("Error() = %q, want tracking ID", err.Error())
+	}
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "")
+	var infos []webex.RequestInfo
+	client.OnRequest = func(info *webex.RequestInfo) { infos = append(infos, *info) }
+
+	// the body of the request must be sent again
+	client.RateLimitRetries = 2
+	srv.RateLimit(2, 0)
+	msg, err := client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: room.ID, Markdown: "retried"})
+	if err != nil {
+		t.Fatalf("CreateMessage() after rate limit: %v", err)
+	}
+	if msg.Markdown != "retried" {
+		t.Errorf("Markdown = %q, want retried", msg.Markdown)
+	}
+	if len(infos) != 3 || infos[0].StatusCode != 429 || infos[2].StatusCode != 200 || infos[2].Attempt != 3 {
+		t.Errorf("OnRequest() infos = %+v, want 2 x 429 and 200", infos)
+	}
+	if infos[0].Resource != "messages" || infos[0].Method != "POST" {
+		t.Errorf("OnRequest() resource = %s %s, want POST messages", infos[0].Method, infos[0].Resource)
+	}
+
+	client.RateLimitRetries = 1
+	srv.RateLimit(2, 0)
+	var apiErr *webex.APIError
+	_, err = client.ListRooms(context.Background(), "", "")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 429 {
+		t.Errorf("ListRooms() with retries exhausted: err = %v", err)
+	}
+}
+
+func TestRetryOn(t *testing.T) {
+	client, srv := newTestClient(t)
+	var infos []webex.RequestInfo
+	client.OnRequest = func(info *webex.RequestInfo) { infos = append(infos, *info) }
+
+	// server errors are only retried with RetryOn
+	srv.Fail(1, http.StatusServiceUnavailable)
+	var apiErr *webex.APIError
+	_, err := client.ListRooms(context.Background(), "", "")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("ListRooms() without RetryOn: err = %v", err)
+	}
+
+	client.RateLimitRetries = 2
+	client.RetryInitialWait = time.Millisecond
+	client.RetryOn = func(code int) bool { return code >= 500 }
+	infos = nil
+	srv.Fail(2, http.StatusBadGateway)
+	start := time.Now()
+	if _, err := client.ListRooms(context.Background(), "", ""); err != nil {
+		t.Fatalf("ListRooms() after server errors: %v", err)
+	}
+	if len(infos) != 3 || infos[1].StatusCode != http.StatusBadGateway || infos[2].StatusCode != 200 {
+		t.Errorf("OnRequest() infos = %+v, want 2 x 502 and 200", infos)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("retries took %s, want backoff of RetryInitialWait", d)
+	}
+
+	// Retry-After is limited by RetryMaxWait
+	client.RetryOn = func(code int) bool { return code == http.StatusTooManyRequests }
+	client.RetryMaxWait = time.Millisecond
+	srv.RateLimit(1, 60)
+	start = time.Now()
+	if _, err := client.ListRooms(context.Background(), "", ""); err != nil {
+		t.Fatalf("ListRooms() after rate limit: %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("retry took %s, want at most RetryMaxWait", d)
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+	client, srv := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	time.Sleep(time.Millisecond)
+
+	_, err := client.ListRooms(ctx, "", "")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ListRooms() with expired context: err = %v", err)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("%d requests sent with expired context", n)
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "group")
+	m, err := client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: room.ID, Markdown: "deploying"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited, err := client.EditMessage(context.Background(), m.ID, "deployed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Markdown != "deployed" || srv.Messages()[0].Markdown != "deployed" {
+		t.Errorf("EditMessage() = %+v", edited)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "group")
+	client.FilterMessage = func(m *webex.MessageRequest) error {
+		if strings.Contains(m.Markdown, "forbidden") {
+			return errors.New("forbidden")
+		}
+		m.Markdown = strings.ReplaceAll(m.Markdown, "s3cr3t", "REDACTED")
+		return nil
+	}
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	redacted := filepath.Join(dir, "redacted", "app.log")
+	for file, content := range map[string]string{filename: "password s3cr3t", redacted: "password REDACTED"} {
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	done := 0
+	client.FilterFile = func(file string) (string, func(), error) {
+		return redacted, func() { done++ }, nil
+	}
+
+	req := &webex.MessageRequest{RoomID: room.ID, Markdown: "token s3cr3t"}
+	m, err := client.CreateMessage(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Markdown != "token REDACTED" || req.Markdown != "token s3cr3t" {
+		t.Errorf("CreateMessage() = %q, request %q, want filtered copy", m.Markdown, req.Markdown)
+	}
+	edited, err := client.EditMessage(context.Background(), m.ID, "new s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Markdown != "new REDACTED" {
+		t.Errorf("EditMessage() = %q, want filtered", edited.Markdown)
+	}
+	up, err := client.UploadFile(context.Background(), room.ID, "log s3cr3t", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.File(up.Files[0]); up.Markdown != "log REDACTED" || string(got) != "password REDACTED" || done != 1 {
+		t.Errorf("UploadFile() = %q with %q, done %d, want filtered", up.Markdown, got, done)
+	}
+	if _, err := client.CreateMessage(context.Background(), &webex.MessageRequest{RoomID: room.ID, Markdown: "forbidden"}); err == nil {
+		t.Error("CreateMessage() rejected by filter: err = nil")
+	}
+	if n := len(srv.Messages()); n != 2 {
+		t.Errorf("messages = %d, want 2", n)
+	}
+}
+
+func TestListTeams(t *testing.T) {
+	client, srv := newTestClient(t)
+	teamID := srv.AddTeam("KMP-Team")
+
+	teams, err := client.ListTeams(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(teams) != 1 || teams[0].ID != teamID || teams[0].Name != "KMP-Team" {
+		t.Errorf("ListTeams() = %+v", teams)
+	}
+}
+
+func TestCreateTeam(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	team, err := client.CreateTeam(context.Background(), "Ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Name != "Ops" || len(team.ID) == 0 {
+		t.Errorf("CreateTeam() = %+v", team)
+	}
+	teamID, err := client.TeamIDByName(context.Background(), "Ops")
+	if err != nil || teamID != team.ID {
+		t.Errorf("TeamIDByName() = %q, %v, want %q", teamID, err, team.ID)
+	}
+}
+
+func TestMemberships(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "group")
+	srv.AddRoom("Other", "", "group")
+
+	m, err := client.CreateMembership(context.Background(), room.ID, "john.smith@example.com", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.IsModerator || m.RoomID != room.ID {
+		t.Errorf("CreateMembership() = %+v", m)
+	}
+
+	members, err := client.ListMemberships(context.Background(), room.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].PersonEmail != "john.smith@example.com" {
+		t.Errorf("ListMemberships() = %+v", members)
+	}
+
+	m, err = client.UpdateMembership(context.Background(), m.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.IsModerator || srv.Memberships()[0].IsModerator {
+		t.Errorf("UpdateMembership() = %+v", m)
+	}
+
+	if err := client.DeleteMembership(context.Background(), m.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Memberships()); n != 0 {
+		t.Errorf("%d memberships left after delete", n)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	client, srv := newTestClient(t)
+
+	wh, err := client.CreateWebhook(context.Background(), &webex.Webhook{
+		Name:      "cards",
+		TargetURL: "https://example.com/webhook",
+		Resource:  "attachmentActions",
+		Event:     "created",
+		Secret:    "s3cret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wh.ID == "" || wh.Status != "active" {
+		t.Errorf("CreateWebhook() = %+v", wh)
+	}
+
+	hooks, err := client.ListWebhooks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 1 || hooks[0].TargetURL != "https://example.com/webhook" {
+		t.Errorf("ListWebhooks() = %+v", hooks)
+	}
+
+	if err := client.DeleteWebhook(context.Background(), wh.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(srv.Webhooks()); n != 0 {
+		t.Errorf("%d webhooks left after delete", n)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"id":"1","resource":"attachmentActions","event":"created"}`)
+	// printf %s "$body" | openssl sha1 -hmac s3cret
+	signature := "c22681128c879ad4d7b451188036d9dfd9f270d2"
+	if !webex.VerifySignature(body, signature, "s3cret") {
+		t.Errorf("VerifySignature() = false for the signature of the body")
+	}
+	for _, c := range []struct{ body, signature, secret string }{
+		{string(body), signature, "other"},
+		{string(body) + " ", signature, "s3cret"},
+		{string(body), "c22681128c879ad4d7b451188036d9dfd9f270d3", "s3cret"},
+		{string(body), "not hex", "s3cret"},
+		{string(body), "", "s3cret"},
+	} {
+		if webex.VerifySignature([]byte(c.body), c.signature, c.secret) {
+			t.Errorf("VerifySignature(%q, %q, %q) = true", c.body, c.signature, c.secret)
+		}
+	}
+}
+
+func TestListMessages(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Alerts", "", "group")
+	other := srv.AddRoom("Other", "", "group")
+	start := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 250; i++ {
+		srv.AddMessage(room.ID, "john.smith@example.com", fmt.Sprintf("message %d", i), start.Add(time.Duration(i)*time.Minute))
+	}
+	srv.AddMessage(other.ID, "john.smith@example.com", "other room", start)
+
+	var got []string
+	err := client.ListMessages(context.Background(), room.ID, time.Time{}, func(m *webex.Message) bool {
+		got = append(got, m.Markdown)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 250 || got[0] != "message 249" || got[249] != "message 0" {
+		t.Errorf("ListMessages() returned %d messages, first %q", len(got), got[0])
+	}
+
+	got = nil
+	err = client.ListMessages(context.Background(), room.ID, start.Add(10*time.Minute), func(m *webex.Message) bool {
+		got = append(got, m.Markdown)
+		return len(got) < 3
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != "message 9" {
+		t.Errorf("ListMessages(before) = %q", got)
+	}
+}
+
+func TestListNextLink(t *testing.T) {
+	var leaked []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = append(leaked, r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"items":[]}`)
+	}))
+	defer other.Close()
+
+	for _, tt := range []struct {
+		name    string
+		next    func(base string) string
+		wantErr bool
+	}{
+		{name: "same host", next: func(base string) string { return base + "/messages?cursor=2" }},
+		{name: "other host", next: func(string) string { return other.URL + "/v1/messages?cursor=2" }, wantErr: true},
+		{name: "other scheme", next: func(base string) string { return strings.Replace(base, "http:", "ftp:", 1) + "/messages" }, wantErr: true},
+	} {
+		var pages int
+		var srv *httptest.Server
+		srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pages++
+			if len(r.URL.Query().Get("cursor")) == 0 {
+				w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, tt.next(srv.URL+"/v1")))
+			}
+			fmt.Fprint(w, `{"items":[{"id":"1","text":"hello"}]}`)
+		}))
+		client := webex.NewClient(testToken)
+		client.BaseURL = srv.URL + "/v1"
+		err := client.ListMessages(context.Background(), "room", time.Time{}, func(*webex.Message) bool { return true })
+		srv.Close()
+		if tt.wantErr != (err != nil) {
+			t.Errorf("%s: ListMessages() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if want := map[bool]int{false: 2, true: 1}[tt.wantErr]; pages != want {
+			t.Errorf("%s: %d pages requested, want %d", tt.name, pages, want)
+		}
+	}
+	if len(leaked) > 0 {
+		t.Errorf("token sent to other host: %q", leaked)
+	}
+}
+
+func TestListReplies(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Incidents", "", "group")
+	ctx := context.Background()
+
+	parent, err := client.CreateMessage(ctx, &webex.MessageRequest{RoomID: room.ID, Markdown: "incident 42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := client.CreateMessage(ctx, &webex.MessageRequest{RoomID: room.ID, Markdown: "incident 43"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"investigating", "mitigated", "resolved"} {
+		_, err := client.CreateMessage(ctx, &webex.MessageRequest{RoomID: room.ID, ParentID: parent.ID, Markdown: text})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = client.CreateMessage(ctx, &webex.MessageRequest{RoomID: room.ID, ParentID: other.ID, Markdown: "other thread"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err = client.ListReplies(ctx, room.ID, parent.ID, func(m *webex.Message) bool {
+		if m.ParentID != parent.ID {
+			t.Errorf("reply %s has parentId %q", m.ID, m.ParentID)
+		}
+		got = append(got, m.Markdown)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Errorf("ListReplies() = %q, want 3 replies", got)
+	}
+}
+
+func TestCreateMeeting(t *testing.T) {
+	client, srv := newTestClient(t)
+	start := time.Now().Add(time.Minute).Truncate(time.Second)
+
+	m, err := client.CreateMeeting(context.Background(), &webex.MeetingRequest{Title: "Incident bridge", Start: start, End: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Title != "Incident bridge" || len(m.WebLink) == 0 || len(m.MeetingNumber) == 0 || !m.Start.Equal(start) {
+		t.Errorf("CreateMeeting() = %+v", m)
+	}
+	if n := len(srv.Meetings()); n != 1 {
+		t.Errorf("%d meetings scheduled, want 1", n)
+	}
+
+	var apiErr *webex.APIError
+	_, err = client.CreateMeeting(context.Background(), &webex.MeetingRequest{Title: "no end", Start: start})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 {
+		t.Errorf("CreateMeeting() without end: err = %v", err)
+	}
+}
+
+func TestGetAttachmentAction(t *testing.T) {
+	client, srv := newTestClient(t)
+	room := srv.AddRoom("Approvals", "", "group")
+	m := srv.AddMessage(room.ID, webextest.BotEmail, "approve the change?", time.Now())
+	added := srv.AddAttachmentAction(m.ID, "john.smith@example.com", map[string]interface{}{"answer": "accept"})
+
+	a, err := client.GetAttachmentAction(context.Background(), added.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.MessageID != m.ID || a.RoomID != room.ID || a.Inputs["answer"] != "accept" {
+		t.Errorf("GetAttachmentAction() = %+v", a)
+	}
+
+	var apiErr *webex.APIError
+	_, err = client.GetAttachmentAction(context.Background(), "unknown")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("GetAttachmentAction(unknown): err = %v", err)
+	}
+}
+
+func TestMe(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	me, err := client.Me(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if me.ID != webextest.BotID || len(me.Emails) != 1 || me.Emails[0] != webextest.BotEmail {
+		t.Errorf("Me() = %+v", me)
+	}
+}
+
+func TestPersonByEmail(t *testing.T) {
+	client, srv := newTestClient(t)
+	want := srv.AddPerson("john.smith@example.com", "John Smith")
+
+	p, err := client.PersonByEmail(context.Background(), "John.Smith@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != want.ID || p.DisplayName != "John Smith" {
+		t.Errorf("PersonByEmail() = %+v", p)
+	}
+
+	_, err = client.PersonByEmail(context.Background(), "nobody@example.com")
+	if err == nil {
+		t.Error("PersonByEmail() of unknown email address returned no error")
+	}
+}
+
+func TestGetPerson(t *testing.T) {
+	client, srv := newTestClient(t)
+	want := srv.AddPerson("john.smith@example.com", "John Smith")
+
+	p, err := client.GetPerson(context.Background(), want.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID != want.ID || p.DisplayName != "John Smith" {
+		t.Errorf("GetPerson() = %+v", p)
+	}
+
+	var apiErr *webex.APIError
+	_, err = client.GetPerson(context.Background(), "unknown")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("GetPerson(unknown): err = %v", err)
+	}
+}
+
+func TestGetOrganization(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	me, err := client.Me(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := client.GetOrganization(context.Background(), me.OrgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.ID != webextest.OrgID || o.DisplayName != webextest.OrgName {
+		t.Errorf("GetOrganization() = %+v", o)
+	}
+
+	var apiErr *webex.APIError
+	_, err = client.GetOrganization(context.Background(), "unknown")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 {
+		t.Errorf("GetOrganization(unknown): err = %v", err)
+	}
+}
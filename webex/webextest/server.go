@@ -0,0 +1,795 @@
+// Package webextest provides a fake Webex API server for tests, in the
+// spirit of net/http/httptest. It keeps rooms and messages in memory and
+// implements the subset of the API used by package webex.
+//
+// example:
+//
+//	srv := webextest.NewServer("test-token")
+//	defer srv.Close()
+//	client := webex.NewClient("test-token")
+//	client.BaseURL = srv.BaseURL()
+package webextest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// The identity of the token owner, e.g. the author of the messages sent by
+// the client.
+const (
+	BotID    = "person-bot"
+	BotEmail = "notify-bot@webex.bot"
+)
+
+// The organization of the bot and of the persons added.
+const (
+	OrgID   = "org-1"
+	OrgName = "Example Org"
+)
+
+// Server is a fake Webex API server.
+type Server struct {
+	*httptest.Server
+
+	// Token is the only accepted bearer token
+	Token string
+
+	mu          sync.Mutex
+	teams       []webex.Team
+	rooms       []webex.Room
+	messages    []webex.Message
+	memberships []webex.Membership
+	webhooks    []webex.Webhook
+	people      []webex.Person
+	meetings    []webex.Meeting
+	actions     []webex.AttachmentAction
+	files       map[string][]byte
+	requests    []string
+	nextID      int
+	rateLimited int
+	retryAfter  int
+	failures    int
+	failStatus  int
+}
+
+// NewServer starts a fake Webex API server accepting the given token.
+// The caller must call Close when finished.
+func NewServer(token string) *Server {
+	s := &Server{Token: token, files: make(map[string][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// BaseURL returns the value for webex.Client.BaseURL.
+func (s *Server) BaseURL() string {
+	return s.URL + "/v1"
+}
+
+// AddRoom adds a room and returns it. An empty roomType means "group".
+func (s *Server) AddRoom(title, teamID, roomType string) webex.Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addRoom(title, teamID, roomType)
+}
+
+// AddTeam adds a team with its general room, which has the name of the team,
+// and returns the team ID.
+func (s *Server) AddTeam(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addTeam(name).ID
+}
+
+func (s *Server) addTeam(name string) webex.Team {
+	t := webex.Team{ID: s.newID("team"), Name: name, CreatorID: BotID, Created: time.Now().UTC()}
+	s.teams = append(s.teams, t)
+	s.addRoom(name, t.ID, "group")
+	return t
+}
+
+// AddMembership adds a person to a room and returns the membership.
+func (s *Server) AddMembership(roomID, personEmail string, isModerator bool) webex.Membership {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addMembership(roomID, personEmail, isModerator)
+}
+
+// AddMessage adds a message created at the given time to a room, e.g. to
+// prepare the history of a room.
+func (s *Server) AddMessage(roomID, personEmail, markdown string, created time.Time) webex.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := webex.Message{
+		ID:          s.newID("message"),
+		RoomID:      roomID,
+		RoomType:    "group",
+		Text:        markdown,
+		Markdown:    markdown,
+		PersonID:    personID(personEmail),
+		PersonEmail: personEmail,
+		Created:     created.UTC(),
+	}
+	s.messages = append(s.messages, m)
+	return m
+}
+
+// AddPerson adds a person which can be found by email address.
+func (s *Server) AddPerson(email, displayName string) webex.Person {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := webex.Person{
+		ID:          personID(email),
+		Emails:      []string{email},
+		DisplayName: displayName,
+		OrgID:       OrgID,
+		Type:        "person",
+		Created:     time.Now().UTC(),
+	}
+	s.people = append(s.people, p)
+	return p
+}
+
+// AddAttachmentAction adds the submission of the card of a message by the
+// person with the given email address.
+func (s *Server) AddAttachmentAction(messageID, personEmail string, inputs map[string]interface{}) webex.AttachmentAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := webex.AttachmentAction{
+		ID:        s.newID("action"),
+		Type:      "submit",
+		MessageID: messageID,
+		PersonID:  personID(personEmail),
+		Inputs:    inputs,
+		Created:   time.Now().UTC(),
+	}
+	if m := s.message(messageID); m != nil {
+		a.RoomID = m.RoomID
+	}
+	s.actions = append(s.actions, a)
+	return a
+}
+
+// Memberships returns a copy of all memberships.
+func (s *Server) Memberships() []webex.Membership {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webex.Membership(nil), s.memberships...)
+}
+
+// Webhooks returns a copy of all registered webhooks.
+func (s *Server) Webhooks() []webex.Webhook {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webex.Webhook(nil), s.webhooks...)
+}
+
+// Rooms returns a copy of all rooms.
+func (s *Server) Rooms() []webex.Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webex.Room(nil), s.rooms...)
+}
+
+// Messages returns a copy of all messages.
+func (s *Server) Messages() []webex.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webex.Message(nil), s.messages...)
+}
+
+// Meetings returns a copy of all scheduled meetings.
+func (s *Server) Meetings() []webex.Meeting {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webex.Meeting(nil), s.meetings...)
+}
+
+// File returns the content of an uploaded file by the URL in Message.Files.
+func (s *Server) File(fileURL string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files[fileURL]
+}
+
+// RateLimit answers the next n requests with 429 Too Many Requests and a
+// Retry-After header of the given seconds.
+func (s *Server) RateLimit(n, retryAfterSeconds int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimited, s.retryAfter = n, retryAfterSeconds
+}
+
+// Fail answers the next n requests with the given status code, e.g. 503
+// Service Unavailable, without Retry-After header.
+func (s *Server) Fail(n, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures, s.failStatus = n, statusCode
+}
+
+// Requests returns the received requests as "METHOD /path".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) newID(kind string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", kind, s.nextID)
+}
+
+func (s *Server) addRoom(title, teamID, roomType string) webex.Room {
+	if len(roomType) == 0 {
+		roomType = "group"
+	}
+	now := time.Now().UTC()
+	r := webex.Room{
+		ID:           s.newID("room"),
+		Title:        title,
+		Type:         roomType,
+		TeamID:       teamID,
+		CreatorID:    BotID,
+		Created:      now,
+		LastActivity: now,
+	}
+	s.rooms = append(s.rooms, r)
+	return r
+}
+
+func (s *Server) addMembership(roomID, personEmail string, isModerator bool) webex.Membership {
+	m := webex.Membership{
+		ID:                s.newID("membership"),
+		RoomID:            roomID,
+		PersonID:          personID(personEmail),
+		PersonEmail:       personEmail,
+		PersonDisplayName: personEmail,
+		IsModerator:       isModerator,
+		Created:           time.Now().UTC(),
+	}
+	s.memberships = append(s.memberships, m)
+	return m
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		writeError(w, http.StatusUnauthorized, "The request requires a valid access token set in the Authorization request header.")
+		return
+	}
+	if s.rateLimited > 0 {
+		s.rateLimited--
+		w.Header().Set("Retry-After", strconv.Itoa(s.retryAfter))
+		writeError(w, http.StatusTooManyRequests, "Too many requests have been made in a given amount of time.")
+		return
+	}
+	if s.failures > 0 {
+		s.failures--
+		writeError(w, s.failStatus, http.StatusText(s.failStatus))
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	resource, id := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		resource, id = path[:i], path[i+1:]
+	}
+
+	switch {
+	case resource == "rooms" && id == "" && r.Method == "GET":
+		s.listRooms(w, r)
+	case resource == "rooms" && id == "" && r.Method == "POST":
+		s.createRoom(w, r)
+	case resource == "rooms" && id != "" && r.Method == "GET":
+		s.getRoom(w, id)
+	case resource == "rooms" && id != "" && r.Method == "PUT":
+		s.updateRoom(w, r, id)
+	case resource == "messages" && id == "" && r.Method == "POST":
+		s.createMessage(w, r)
+	case resource == "messages" && id == "" && r.Method == "GET":
+		s.listMessages(w, r)
+	case resource == "messages" && id != "" && r.Method == "GET":
+		s.getMessage(w, id)
+	case resource == "messages" && id != "" && r.Method == "PUT":
+		s.editMessage(w, r, id)
+	case resource == "messages" && id != "" && r.Method == "DELETE":
+		s.deleteMessage(w, id)
+	case resource == "meetings" && id == "" && r.Method == "POST":
+		s.createMeeting(w, r)
+	case resource == "people" && id == "me" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, webex.Person{ID: BotID, Emails: []string{BotEmail}, DisplayName: "Notify Bot", OrgID: OrgID, Type: "bot"})
+	case resource == "people" && id == "" && r.Method == "GET":
+		s.listPeople(w, r)
+	case resource == "people" && id != "" && r.Method == "GET":
+		s.getPerson(w, id)
+	case resource == "organizations" && id == OrgID && r.Method == "GET":
+		writeJSON(w, http.StatusOK, webex.Organization{ID: OrgID, DisplayName: OrgName})
+	case resource == "teams" && id == "" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"items": append([]webex.Team{}, s.teams...)})
+	case resource == "teams" && id == "" && r.Method == "POST":
+		s.createTeam(w, r)
+	case resource == "memberships" && id == "" && r.Method == "GET":
+		s.listMemberships(w, r)
+	case resource == "memberships" && id == "" && r.Method == "POST":
+		s.createMembership(w, r)
+	case resource == "memberships" && id != "" && r.Method == "PUT":
+		s.updateMembership(w, r, id)
+	case resource == "memberships" && id != "" && r.Method == "DELETE":
+		s.deleteMembership(w, id)
+	case resource == "attachment" && strings.HasPrefix(id, "actions/") && r.Method == "GET":
+		s.getAttachmentAction(w, strings.TrimPrefix(id, "actions/"))
+	case resource == "webhooks" && id == "" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"items": append([]webex.Webhook{}, s.webhooks...)})
+	case resource == "webhooks" && id == "" && r.Method == "POST":
+		s.createWebhook(w, r)
+	case resource == "webhooks" && id != "" && r.Method == "DELETE":
+		s.deleteWebhook(w, id)
+	default:
+		writeError(w, http.StatusNotFound, "The requested resource could not be found.")
+	}
+}
+
+func (s *Server) listRooms(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	items := []webex.Room{}
+	for _, room := range s.rooms {
+		if len(q.Get("teamId")) > 0 && room.TeamID != q.Get("teamId") {
+			continue
+		}
+		if len(q.Get("type")) > 0 && room.Type != q.Get("type") {
+			continue
+		}
+		items = append(items, room)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (s *Server) createRoom(w http.ResponseWriter, r *http.Request) {
+	var req webex.RoomRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Title) == 0 {
+		writeError(w, http.StatusBadRequest, "Invalid room.")
+		return
+	}
+	if req.IsAnnouncementOnly && !req.IsLocked {
+		writeError(w, http.StatusBadRequest, "isAnnouncementOnly requires isLocked.")
+		return
+	}
+	room := s.addRoom(req.Title, req.TeamID, "group")
+	if req.IsLocked {
+		// the creator of a locked room is its moderator
+		s.addMembership(room.ID, BotEmail, true)
+		p := s.room(room.ID)
+		p.IsLocked = true
+		p.IsAnnouncementOnly = req.IsAnnouncementOnly
+		room = *p
+	}
+	writeJSON(w, http.StatusOK, room)
+}
+
+func (s *Server) getRoom(w http.ResponseWriter, id string) {
+	room := s.room(id)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "Room not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, room)
+}
+
+// updateRoom changes the title and lock of a room. Like in the Webex API the
+// title is required, the lock is kept if not given.
+func (s *Server) updateRoom(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Title              string `json:"title"`
+		IsLocked           *bool  `json:"isLocked"`
+		IsAnnouncementOnly *bool  `json:"isAnnouncementOnly"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Title) == 0 {
+		writeError(w, http.StatusBadRequest, "title is required.")
+		return
+	}
+	room := s.room(id)
+	if room == nil {
+		writeError(w, http.StatusNotFound, "Room not found.")
+		return
+	}
+	locked, announcementOnly := room.IsLocked, room.IsAnnouncementOnly
+	if req.IsLocked != nil {
+		locked = *req.IsLocked
+	}
+	if req.IsAnnouncementOnly != nil {
+		announcementOnly = *req.IsAnnouncementOnly
+	}
+	if announcementOnly && !locked {
+		writeError(w, http.StatusBadRequest, "isAnnouncementOnly requires isLocked.")
+		return
+	}
+	room.Title = req.Title
+	room.IsLocked = locked
+	room.IsAnnouncementOnly = announcementOnly
+	writeJSON(w, http.StatusOK, room)
+}
+
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RoomID        string            `json:"roomId"`
+		ToPersonEmail string            `json:"toPersonEmail"`
+		ParentID      string            `json:"parentId"`
+		Markdown      string            `json:"markdown"`
+		Attachments   []json.RawMessage `json:"attachments"`
+	}
+	var files []string
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(100 << 20); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.RoomID = r.FormValue("roomId")
+		req.ToPersonEmail = r.FormValue("toPersonEmail")
+		req.ParentID = r.FormValue("parentId")
+		req.Markdown = r.FormValue("markdown")
+		for _, fh := range r.MultipartForm.File["files"] {
+			f, err := fh.Open()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			data, err := ioutil.ReadAll(f)
+			f.Close()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			fileURL := s.BaseURL() + "/contents/" + s.newID("file")
+			s.files[fileURL] = data
+			files = append(files, fileURL)
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON.")
+		return
+	}
+
+	m := webex.Message{
+		ID:          s.newID("message"),
+		Markdown:    req.Markdown,
+		Text:        req.Markdown,
+		ParentID:    req.ParentID,
+		Files:       files,
+		Attachments: req.Attachments,
+		PersonID:    BotID,
+		PersonEmail: BotEmail,
+		Created:     time.Now().UTC(),
+	}
+	switch {
+	case len(req.ToPersonEmail) > 0:
+		room := s.directRoom(req.ToPersonEmail)
+		m.RoomID = room.ID
+		m.RoomType = "direct"
+	case len(req.RoomID) > 0:
+		room := s.room(req.RoomID)
+		if room == nil {
+			writeError(w, http.StatusNotFound, "Room not found.")
+			return
+		}
+		m.RoomID = room.ID
+		m.RoomType = room.Type
+	default:
+		writeError(w, http.StatusBadRequest, "roomId or toPersonEmail required.")
+		return
+	}
+	if len(m.ParentID) > 0 {
+		parent := s.message(m.ParentID)
+		if parent == nil || parent.RoomID != m.RoomID || len(parent.ParentID) > 0 {
+			writeError(w, http.StatusBadRequest, "Invalid parentId.")
+			return
+		}
+	}
+	s.messages = append(s.messages, m)
+	writeJSON(w, http.StatusOK, m)
+}
+
+// listMessages returns the messages of a room newest first, only the
+// replies to a message if parentId is given. Pages are linked
+// with the Link header like in the Webex API.
+func (s *Server) listMessages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	max, _ := strconv.Atoi(query.Get("max"))
+	if max <= 0 {
+		max = 50
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	before, _ := time.Parse(time.RFC3339Nano, query.Get("before"))
+
+	items := []webex.Message{}
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		m := s.messages[i]
+		if m.RoomID != query.Get("roomId") || !before.IsZero() && !m.Created.Before(before) {
+			continue
+		}
+		if parentID := query.Get("parentId"); len(parentID) > 0 && m.ParentID != parentID {
+			continue
+		}
+		items = append(items, m)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Created.After(items[j].Created)
+	})
+	if offset > len(items) {
+		offset = len(items)
+	}
+	items = items[offset:]
+	if len(items) > max {
+		items = items[:max]
+		query.Set("offset", strconv.Itoa(offset+max))
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s?%s>; rel="next"`, s.URL, r.URL.Path, query.Encode()))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (s *Server) listPeople(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if len(email) == 0 {
+		writeError(w, http.StatusBadRequest, "email, displayName or id required.")
+		return
+	}
+	items := []webex.Person{}
+	for _, p := range s.people {
+		for _, e := range p.Emails {
+			if strings.EqualFold(e, email) {
+				items = append(items, p)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (s *Server) getPerson(w http.ResponseWriter, id string) {
+	for _, p := range s.people {
+		if p.ID == id {
+			writeJSON(w, http.StatusOK, p)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Person not found.")
+}
+
+func personID(email string) string {
+	if email == BotEmail {
+		return BotID
+	}
+	return "person-" + email
+}
+
+func (s *Server) message(id string) *webex.Message {
+	for i := range s.messages {
+		if s.messages[i].ID == id {
+			return &s.messages[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) getMessage(w http.ResponseWriter, id string) {
+	m := s.message(id)
+	if m == nil {
+		writeError(w, http.StatusNotFound, "Message not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) editMessage(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		RoomID   string `json:"roomId"`
+		Markdown string `json:"markdown"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON.")
+		return
+	}
+	m := s.message(id)
+	if m == nil {
+		writeError(w, http.StatusNotFound, "Message not found.")
+		return
+	}
+	if m.RoomID != req.RoomID {
+		writeError(w, http.StatusBadRequest, "roomId does not match the message.")
+		return
+	}
+	m.Markdown = req.Markdown
+	m.Text = req.Markdown
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) createMeeting(w http.ResponseWriter, r *http.Request) {
+	var req webex.MeetingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON.")
+		return
+	}
+	if len(req.Title) == 0 || req.Start.IsZero() || !req.End.After(req.Start) {
+		writeError(w, http.StatusBadRequest, "title, start and end are required and end must be after start.")
+		return
+	}
+	id := s.newID("meeting")
+	number := fmt.Sprintf("2650%05d", s.nextID)
+	password := req.Password
+	if len(password) == 0 {
+		password = "Xy3" + number[len(number)-4:]
+	}
+	m := webex.Meeting{
+		ID:            id,
+		MeetingNumber: number,
+		Title:         req.Title,
+		Password:      password,
+		Start:         req.Start.UTC(),
+		End:           req.End.UTC(),
+		Timezone:      "UTC",
+		WebLink:       s.URL + "/meet/j.php?MTID=" + id,
+		SIPAddress:    number + "@example.webex.com",
+		HostEmail:     BotEmail,
+	}
+	s.meetings = append(s.meetings, m)
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) createTeam(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Name) == 0 {
+		writeError(w, http.StatusBadRequest, "Invalid team.")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.addTeam(req.Name))
+}
+
+func (s *Server) listMemberships(w http.ResponseWriter, r *http.Request) {
+	items := []webex.Membership{}
+	for _, m := range s.memberships {
+		if roomID := r.URL.Query().Get("roomId"); len(roomID) > 0 && m.RoomID != roomID {
+			continue
+		}
+		items = append(items, m)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items})
+}
+
+func (s *Server) createMembership(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RoomID      string `json:"roomId"`
+		PersonEmail string `json:"personEmail"`
+		IsModerator bool   `json:"isModerator"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || s.room(req.RoomID) == nil {
+		writeError(w, http.StatusBadRequest, "Invalid membership.")
+		return
+	}
+	for _, m := range s.memberships {
+		if m.RoomID == req.RoomID && m.PersonEmail == req.PersonEmail {
+			writeError(w, http.StatusConflict, "Person is already a member of the room.")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, s.addMembership(req.RoomID, req.PersonEmail, req.IsModerator))
+}
+
+func (s *Server) updateMembership(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		IsModerator bool `json:"isModerator"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid membership.")
+		return
+	}
+	for i, m := range s.memberships {
+		if m.ID == id {
+			s.memberships[i].IsModerator = req.IsModerator
+			writeJSON(w, http.StatusOK, s.memberships[i])
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Membership not found.")
+}
+
+func (s *Server) deleteMembership(w http.ResponseWriter, id string) {
+	for i, m := range s.memberships {
+		if m.ID == id {
+			s.memberships = append(s.memberships[:i], s.memberships[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Membership not found.")
+}
+
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var wh webex.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&wh); err != nil || len(wh.TargetURL) == 0 {
+		writeError(w, http.StatusBadRequest, "Invalid webhook.")
+		return
+	}
+	wh.ID = s.newID("webhook")
+	wh.Status = "active"
+	wh.Created = time.Now().UTC()
+	s.webhooks = append(s.webhooks, wh)
+	writeJSON(w, http.StatusOK, wh)
+}
+
+func (s *Server) getAttachmentAction(w http.ResponseWriter, id string) {
+	for _, a := range s.actions {
+		if a.ID == id {
+			writeJSON(w, http.StatusOK, a)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Attachment action not found.")
+}
+
+func (s *Server) deleteWebhook(w http.ResponseWriter, id string) {
+	for i, wh := range s.webhooks {
+		if wh.ID == id {
+			s.webhooks = append(s.webhooks[:i], s.webhooks[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Webhook not found.")
+}
+
+func (s *Server) deleteMessage(w http.ResponseWriter, id string) {
+	for i, m := range s.messages {
+		if m.ID == id {
+			s.messages = append(s.messages[:i], s.messages[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Message not found.")
+}
+
+func (s *Server) room(id string) *webex.Room {
+	for i := range s.rooms {
+		if s.rooms[i].ID == id {
+			return &s.rooms[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) directRoom(email string) webex.Room {
+	for _, room := range s.rooms {
+		if room.Type == "direct" && room.Title == email {
+			return room
+		}
+	}
+	return s.addRoom(email, "", "direct")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"message":    message,
+		"errors":     []map[string]string{{"description": message}},
+		"trackingId": "WEBEXTEST_00000000-0000-0000-0000-000000000000",
+	})
+}
@@ -0,0 +1,87 @@
+package webex
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// Webhook is a Webex webhook registration.
+type Webhook struct {
+	ID        string    `json:"id,omitempty"`
+	Name      string    `json:"name"`
+	TargetURL string    `json:"targetUrl"`
+	Resource  string    `json:"resource"`
+	Event     string    `json:"event"`
+	Filter    string    `json:"filter,omitempty"`
+	Secret    string    `json:"secret,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Created   time.Time `json:"created"`
+}
+
+// WebhookEvent is the body of a webhook notification.
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Resource  string          `json:"resource"`
+	Event     string          `json:"event"`
+	Filter    string          `json:"filter"`
+	OrgID     string          `json:"orgId"`
+	CreatedBy string          `json:"createdBy"`
+	ActorID   string          `json:"actorId"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// SignatureHeader is the header with the signature of a webhook
+// notification of a webhook with a secret.
+const SignatureHeader = "X-Spark-Signature"
+
+// VerifySignature reports whether signature, the value of SignatureHeader,
+// is the HMAC-SHA1 of body with the secret of the webhook.
+func VerifySignature(body []byte, signature, secret string) bool {
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// ListWebhooks returns the webhooks of the bot or user.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var resp struct {
+		Items []Webhook `json:"items"`
+	}
+	err := c.request(ctx, "GET", c.url("webhooks"), nil, nil, &resp)
+	return resp.Items, err
+}
+
+// CreateWebhook registers a webhook. Name, TargetURL, Resource and Event
+// are required.
+func (c *Client) CreateWebhook(ctx context.Context, w *Webhook) (*Webhook, error) {
+	req := struct {
+		Name      string `json:"name"`
+		TargetURL string `json:"targetUrl"`
+		Resource  string `json:"resource"`
+		Event     string `json:"event"`
+		Filter    string `json:"filter,omitempty"`
+		Secret    string `json:"secret,omitempty"`
+	}{w.Name, w.TargetURL, w.Resource, w.Event, w.Filter, w.Secret}
+
+	var created Webhook
+	err := c.request(ctx, "POST", c.url("webhooks"), nil, &req, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// DeleteWebhook removes a webhook.
+func (c *Client) DeleteWebhook(ctx context.Context, webhookID string) error {
+	return c.request(ctx, "DELETE", c.url("webhooks/"+url.PathEscape(webhookID)), nil, nil, nil)
+}
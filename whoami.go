@@ -0,0 +1,154 @@
+// whoami.go
+//
+// Commands whoami and token check. whoami prints the identity the configured
+// token belongs to and whether the token is valid, so a wrong token is
+// noticed right away and not as a confusing "no room found" later.
+//
+// token check is meant for monitoring: it prints the identity with its
+// organization and how long the token remains valid, and fails if the token
+// is invalid or, for integrations, expires within --min-validity:
+//
+//	notify_by_webex_teams token check --min-validity 336h
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+)
+
+// tokenOwner returns the person the token belongs to. An invalid token is
+// an error.
+func tokenOwner(ctx context.Context, client *webex.Client) (*webex.Person, error) {
+	if _, err := accessToken(); err != nil {
+		return nil, err
+	}
+	me, err := client.Me(ctx)
+	if err != nil {
+		var apiErr *webex.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("the token of %s is invalid or expired", tokenSource())
+		}
+		return nil, err
+	}
+	return me, nil
+}
+
+func runWhoami() error {
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	me, err := tokenOwner(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "name:\t%s\n", me.DisplayName)
+	fmt.Fprintf(w, "emails:\t%s\n", strings.Join(me.Emails, ", "))
+	fmt.Fprintf(w, "type:\t%s\n", me.Type)
+	fmt.Fprintf(w, "id:\t%s\n", me.ID)
+	fmt.Fprintf(w, "org id:\t%s\n", me.OrgID)
+	fmt.Fprintf(w, "token:\tvalid, %s\n", tokenSource())
+	tokenMutex.Lock()
+	if len(apiToken) == 0 && len(guestIssuerID) == 0 && oauthCurrent != nil {
+		fmt.Fprintf(w, "access token expires:\t%s\n", oauthCurrent.ExpiresAt.Local().Format(time.RFC1123))
+		fmt.Fprintf(w, "refresh token expires:\t%s\n", oauthCurrent.RefreshTokenExpiresAt.Local().Format(time.RFC1123))
+	}
+	tokenMutex.Unlock()
+	return w.Flush()
+}
+
+// tokenSource describes where the access token is taken from.
+func tokenSource() string {
+	switch {
+	case len(apiToken) > 0:
+		return "flag -T"
+	case len(guestIssuerID) > 0:
+		return "guest issuer " + guestIssuerID
+	default:
+		return "OAuth token file " + oauthTokenFile
+	}
+}
+
+func cmdToken(action string, args []string) error {
+	if err := noArgs(args); err != nil {
+		return err
+	}
+	client, err := webexClient()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext()
+	defer cancel()
+
+	me, err := tokenOwner(ctx, client)
+	if err != nil {
+		return err
+	}
+	org := me.OrgID
+	if o, err := client.GetOrganization(ctx, me.OrgID); err == nil {
+		org = fmt.Sprintf("%s (%s)", o.DisplayName, o.ID)
+	} else {
+		slog.Debug("reading the organization failed", "id", me.OrgID, "error", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "identity:\t%s <%s> (%s)\n", me.DisplayName, strings.Join(me.Emails, ", "), me.Type)
+	fmt.Fprintf(w, "organization:\t%s\n", org)
+	fmt.Fprintf(w, "token:\tvalid, %s\n", tokenSource())
+
+	// expires is the end of the validity of an expiring token
+	var expires time.Time
+	tokenMutex.Lock()
+	switch {
+	case len(apiToken) > 0 && me.Type == "bot":
+		fmt.Fprintf(w, "expires:\tnever (bot token)\n")
+	case len(apiToken) > 0:
+		fmt.Fprintf(w, "expires:\tunknown (personal access tokens are valid for 12 hours, integration tokens for 14 days)\n")
+	case len(guestIssuerID) > 0:
+		fmt.Fprintf(w, "expires:\t%s (guest token, renewed by the guest issuer secret)\n", validity(guestCurrent.ExpiresAt))
+	case oauthCurrent != nil:
+		fmt.Fprintf(w, "access token expires:\t%s\n", validity(oauthCurrent.ExpiresAt))
+		expires = oauthCurrent.ExpiresAt
+		if len(oauthCurrent.RefreshToken) > 0 {
+			// the access token is renewed with the refresh token
+			fmt.Fprintf(w, "refresh token expires:\t%s\n", validity(oauthCurrent.RefreshTokenExpiresAt))
+			expires = oauthCurrent.RefreshTokenExpiresAt
+		}
+	}
+	tokenMutex.Unlock()
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !expires.IsZero() && time.Until(expires) < minValidity {
+		return fmt.Errorf("the token of %s expires within %s. run --oauth-login again", tokenSource(), minValidity)
+	}
+	return nil
+}
+
+// validity returns the given expiry time with the time remaining until then.
+func validity(t time.Time) string {
+	d := time.Until(t)
+	if d <= 0 {
+		return t.Local().Format(time.RFC1123) + " (expired)"
+	}
+	remaining := d.Round(time.Minute).String()
+	if d >= 48*time.Hour {
+		remaining = fmt.Sprintf("%dd%dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	}
+	return fmt.Sprintf("%s (in %s)", t.Local().Format(time.RFC1123), remaining)
+}
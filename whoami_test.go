@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hgrimm/notify_by_webex_teams/webex"
+	"github.com/hgrimm/notify_by_webex_teams/webex/webextest"
+)
+
+func TestValidity(t *testing.T) {
+	for _, tt := range []struct {
+		in   time.Duration
+		want string
+	}{
+		{90*time.Minute + 10*time.Second, "(in 1h30m0s)"},
+		{47 * time.Hour, "(in 47h0m0s)"},
+		{14*24*time.Hour + 3*time.Hour + time.Minute, "(in 14d3h)"},
+		{-time.Minute, "(expired)"},
+	} {
+		if got := validity(time.Now().Add(tt.in)); !strings.HasSuffix(got, tt.want) {
+			t.Errorf("validity(now + %s) = %q, want suffix %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTokenOwner(t *testing.T) {
+	fake := useFakeWebex(t)
+	client, err := webexClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	me, err := tokenOwner(context.Background(), client)
+	if err != nil || me.ID != webextest.BotID || me.Type != "bot" {
+		t.Errorf("tokenOwner() = %+v, %v, want the bot", me, err)
+	}
+	other := webex.NewClient("other-token")
+	other.BaseURL = fake.BaseURL()
+	if _, err := tokenOwner(context.Background(), other); err == nil || err.Error() != "the token of flag -T is invalid or expired" {
+		t.Errorf("tokenOwner() of an invalid token error = %v", err)
+	}
+}
+
+func TestCmdToken(t *testing.T) {
+	useFakeWebex(t)
+	defer func(current *oauthToken, min time.Duration) { oauthCurrent, minValidity = current, min }(oauthCurrent, minValidity)
+	minValidity = 7 * 24 * time.Hour
+
+	var err error
+	out := captureStdout(t, func() { err = cmdToken("check", nil) })
+	for _, want := range []string{"identity:      Notify Bot <" + webextest.BotEmail + "> (bot)\n",
+		"organization:  " + webextest.OrgName + " (" + webextest.OrgID + ")\n", "expires:       never (bot token)\n"} {
+		if err != nil || !strings.Contains(out, want) {
+			t.Errorf("token check = %v, output %q, want %q", err, out, want)
+		}
+	}
+
+	// an integration token is checked against --min-validity
+	apiToken = ""
+	for _, tt := range []struct {
+		refreshExpires time.Duration
+		wantErr        string
+	}{
+		{30 * 24 * time.Hour, ""},
+		{3 * 24 * time.Hour, "the token of OAuth token file " + oauthTokenFile + " expires within 168h0m0s. run --oauth-login again"},
+	} {
+		oauthCurrent = &oauthToken{AccessToken: "test-token", ExpiresAt: time.Now().Add(24 * time.Hour),
+			RefreshToken: "refresh-token", RefreshTokenExpiresAt: time.Now().Add(tt.refreshExpires)}
+		out := captureStdout(t, func() { err = cmdToken("check", nil) })
+		if got := errorString(err); got != tt.wantErr || !strings.Contains(out, "refresh token expires:") {
+			t.Errorf("token check of a refresh token valid for %s = %q, output %q, want %q", tt.refreshExpires, got, out, tt.wantErr)
+		}
+	}
+}
+
+func TestRunWhoami(t *testing.T) {
+	useFakeWebex(t)
+	var err error
+	out := captureStdout(t, func() { err = runWhoami() })
+	for _, want := range []string{"name:    Notify Bot\n", "emails:  " + webextest.BotEmail + "\n", "type:    bot\n",
+		"id:      " + webextest.BotID + "\n", "org id:  " + webextest.OrgID + "\n", "token:   valid, flag -T\n"} {
+		if err != nil || !strings.Contains(out, want) {
+			t.Errorf("runWhoami() = %v, output %q, want %q", err, out, want)
+		}
+	}
+
+	defer func(token, issuer, file string) { apiToken, guestIssuerID, oauthTokenFile = token, issuer, file }(apiToken, guestIssuerID, oauthTokenFile)
+	for _, tt := range []struct{ token, issuer, file, want string }{
+		{"test-token", "", "", "flag -T"},
+		{"", "issuer-1", "", "guest issuer issuer-1"},
+		{"", "", "/etc/notify/oauth.json", "OAuth token file /etc/notify/oauth.json"},
+	} {
+		apiToken, guestIssuerID, oauthTokenFile = tt.token, tt.issuer, tt.file
+		if got := tokenSource(); got != tt.want {
+			t.Errorf("tokenSource() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,508 @@
+// yaml.go
+//
+// A small parser for the subset of YAML used by the description files of
+// this command, so no 3rd party library is required. Supported are block
+// mappings and sequences, flow collections ([a, b] and {a: b}), plain,
+// single and double quoted scalars, literal (|) and folded (>) block
+// scalars and comments. Anchors, aliases, tags and multiple documents are
+// not supported.
+//
+// Mappings are returned as yamlMapping to keep the order of the keys,
+// sequences as []interface{} and scalars as string, bool, json.Number or nil.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlMapping is a YAML mapping with the keys in document order.
+type yamlMapping []yamlPair
+
+type yamlPair struct {
+	Key   string
+	Value interface{}
+}
+
+// Get returns the value of key and whether it exists.
+func (m yamlMapping) Get(key string) (interface{}, bool) {
+	for _, p := range m {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+// yamlError is a parse error with the line number of the document.
+type yamlError struct {
+	line int
+	msg  string
+}
+
+func (e *yamlError) Error() string {
+	return fmt.Sprintf("YAML line %d: %s", e.line, e.msg)
+}
+
+var yamlNumberPattern = regexp.MustCompile(`^[-+]?(\d+|\d*\.\d+|\d+\.\d*)([eE][-+]?\d+)?$`)
+
+// parseYAML parses a YAML document.
+func parseYAML(data string) (interface{}, error) {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.TrimPrefix(data, "\ufeff")
+	p := &yamlParser{lines: strings.Split(data, "\n")}
+	if indent, text, ok := p.peek(); ok && indent == 0 && text == "---" {
+		p.pos++
+	}
+	v, err := p.parseNode(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, text, ok := p.peek(); ok && text != "..." {
+		return nil, p.errorf("unexpected content %q, check the indentation", text)
+	}
+	return v, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return &yamlError{line: p.pos + 1, msg: fmt.Sprintf(format, args...)}
+}
+
+// peek returns the indentation and the text without comment of the next
+// line with content, skipping blank and comment lines.
+func (p *yamlParser) peek() (int, string, bool) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		text := strings.TrimSpace(stripYAMLComment(line))
+		if len(text) == 0 {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		return indent, text, true
+	}
+	return 0, "", false
+}
+
+// parseNode parses the block node starting at the next line, if it is
+// indented at least minIndent.
+func (p *yamlParser) parseNode(minIndent int) (interface{}, error) {
+	indent, text, ok := p.peek()
+	if !ok || indent < minIndent {
+		return nil, nil
+	}
+	if strings.HasPrefix(p.lines[p.pos], "\t") {
+		return nil, p.errorf("tabs are not allowed for indentation")
+	}
+	switch {
+	case text == "-" || strings.HasPrefix(text, "- "):
+		return p.parseSequence(indent)
+	case yamlKeyEnd(text) >= 0:
+		return p.parseMapping(indent)
+	default:
+		p.pos++
+		return parseYAMLFlow(text, p.pos)
+	}
+}
+
+func (p *yamlParser) parseMapping(indent int) (yamlMapping, error) {
+	var m yamlMapping
+	for {
+		i, text, ok := p.peek()
+		if !ok || i < indent || i == 0 && text == "..." {
+			// the end of the document is checked by parseYAML
+			return m, nil
+		}
+		if i > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		end := yamlKeyEnd(text)
+		if end < 0 {
+			return nil, p.errorf("expected \"key: value\", got %q", text)
+		}
+		key, err := yamlKey(text[:end])
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if _, exists := m.Get(key); exists {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		rest := strings.TrimSpace(text[end+1:])
+		p.pos++
+
+		var value interface{}
+		switch {
+		case len(rest) == 0:
+			// a sequence may have the same indentation as its key
+			if i, t, ok := p.peek(); ok && i == indent && (t == "-" || strings.HasPrefix(t, "- ")) {
+				value, err = p.parseSequence(indent)
+			} else {
+				value, err = p.parseNode(indent + 1)
+			}
+		case rest[0] == '|' || rest[0] == '>':
+			value, err = p.parseBlockScalar(rest, indent)
+		default:
+			value, err = parseYAMLFlow(rest, p.pos)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, yamlPair{key, value})
+	}
+}
+
+func (p *yamlParser) parseSequence(indent int) ([]interface{}, error) {
+	var items []interface{}
+	for {
+		i, text, ok := p.peek()
+		if !ok || i < indent || !(text == "-" || strings.HasPrefix(text, "- ")) {
+			return items, nil
+		}
+		if i > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		rest := strings.TrimSpace(text[1:])
+
+		var item interface{}
+		var err error
+		switch {
+		case len(rest) == 0:
+			p.pos++
+			item, err = p.parseNode(indent + 1)
+		case rest == "-" || strings.HasPrefix(rest, "- ") || yamlKeyEnd(rest) >= 0 && rest[0] != '[' && rest[0] != '{':
+			// a nested collection starting on the line of the dash, e.g.
+			// "- key: value". the line is re-read with the dash replaced
+			line := p.lines[p.pos]
+			offset := strings.Index(line, "-") + 1
+			offset += len(line[offset:]) - len(strings.TrimLeft(line[offset:], " "))
+			p.lines[p.pos] = strings.Repeat(" ", offset) + line[offset:]
+			item, err = p.parseNode(offset)
+		case rest[0] == '|' || rest[0] == '>':
+			p.pos++
+			item, err = p.parseBlockScalar(rest, indent)
+		default:
+			p.pos++
+			item, err = parseYAMLFlow(rest, p.pos)
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar. header
+// is the indicator with optional chomping indicator, parentIndent the
+// indentation of the key or dash.
+func (p *yamlParser) parseBlockScalar(header string, parentIndent int) (string, error) {
+	literal := header[0] == '|'
+	chomp := strings.TrimSpace(header[1:])
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return "", p.errorf("unsupported block scalar header %q", header)
+	}
+
+	var lines []string
+	contentIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if contentIndent < 0 {
+			contentIndent = indent
+		}
+		if indent <= parentIndent || indent < contentIndent {
+			break
+		}
+		lines = append(lines, line[contentIndent:])
+	}
+
+	// trailing empty lines belong to the chomping, not to the content
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var text string
+	if literal {
+		text = strings.Join(lines, "\n")
+	} else {
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "" || strings.HasPrefix(line, " "):
+				text += "\n"
+			case lines[i-1] == "":
+			default:
+				text += " "
+			}
+			text += line
+		}
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	switch chomp {
+	case "-":
+		return text, nil
+	case "+":
+		return text + strings.Repeat("\n", trailing+1), nil
+	}
+	return text + "\n", nil
+}
+
+// stripYAMLComment removes a comment, i.e. "#" at the start or after white
+// space, outside of quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || line[i-1] == ' ' || strings.ContainsRune("[{,:-", rune(line[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlKeyEnd returns the index of the colon ending the key of a mapping
+// entry in text, or -1 if text is no mapping entry.
+func yamlKeyEnd(text string) int {
+	if len(text) == 0 || text[0] == '[' || text[0] == '{' {
+		return -1
+	}
+	start := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end < 0 {
+			return -1
+		}
+		start = end + 1
+	}
+	for i := start; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return i
+		}
+	}
+	return -1
+}
+
+func yamlKey(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		return unquoteYAML(s)
+	}
+	return s, nil
+}
+
+// closingQuote returns the index of the quote closing the quoted scalar at
+// the start of s, or -1.
+func closingQuote(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func unquoteYAML(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return strconv.Unquote(s)
+}
+
+// resolveYAMLScalar returns the value of a plain (unquoted) scalar.
+func resolveYAMLScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE", "yes", "Yes", "on", "On":
+		return true
+	case "false", "False", "FALSE", "no", "No", "off", "Off":
+		return false
+	}
+	if yamlNumberPattern.MatchString(s) {
+		return json.Number(strings.TrimPrefix(s, "+"))
+	}
+	return s
+}
+
+// parseYAMLFlow parses a value on a single line: a flow collection, a
+// quoted or a plain scalar. line is the line number for errors.
+func parseYAMLFlow(s string, line int) (interface{}, error) {
+	f := &yamlFlow{s: s}
+	v, err := f.value()
+	if err == nil {
+		f.skipSpace()
+		if f.pos < len(f.s) {
+			err = fmt.Errorf("unexpected %q", f.s[f.pos:])
+		}
+	}
+	if err != nil {
+		return nil, &yamlError{line: line, msg: err.Error()}
+	}
+	return v, nil
+}
+
+type yamlFlow struct {
+	s     string
+	pos   int
+	depth int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) value() (interface{}, error) {
+	f.skipSpace()
+	if f.pos == len(f.s) {
+		return nil, nil
+	}
+	switch f.s[f.pos] {
+	case '[':
+		return f.sequence()
+	case '{':
+		return f.mapping()
+	case '"', '\'':
+		end := closingQuote(f.s[f.pos:])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quoted string %s", f.s[f.pos:])
+		}
+		quoted := f.s[f.pos : f.pos+end+1]
+		f.pos += end + 1
+		return unquoteYAML(quoted)
+	}
+	return f.plain(), nil
+}
+
+// plain reads a plain scalar. Inside flow collections it ends at ",", "]",
+// "}" and ": ".
+func (f *yamlFlow) plain() interface{} {
+	start := f.pos
+	for ; f.pos < len(f.s); f.pos++ {
+		if f.depth == 0 {
+			continue
+		}
+		c := f.s[f.pos]
+		if c == ',' || c == ']' || c == '}' || c == ':' && (f.pos+1 == len(f.s) || f.s[f.pos+1] == ' ') {
+			break
+		}
+	}
+	return resolveYAMLScalar(strings.TrimSpace(f.s[start:f.pos]))
+}
+
+func (f *yamlFlow) sequence() ([]interface{}, error) {
+	f.pos++
+	f.depth++
+	defer func() { f.depth-- }()
+	items := []interface{}{}
+	for {
+		f.skipSpace()
+		if f.pos < len(f.s) && f.s[f.pos] == ']' {
+			f.pos++
+			return items, nil
+		}
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		f.skipSpace()
+		if f.pos == len(f.s) {
+			return nil, fmt.Errorf("missing ] in %s", f.s)
+		}
+		if f.s[f.pos] == ',' {
+			f.pos++
+		} else if f.s[f.pos] != ']' {
+			return nil, fmt.Errorf("expected , or ] in %s", f.s)
+		}
+	}
+}
+
+func (f *yamlFlow) mapping() (yamlMapping, error) {
+	f.pos++
+	f.depth++
+	defer func() { f.depth-- }()
+	m := yamlMapping{}
+	for {
+		f.skipSpace()
+		if f.pos < len(f.s) && f.s[f.pos] == '}' {
+			f.pos++
+			return m, nil
+		}
+		k, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.pos == len(f.s) || f.s[f.pos] != ':' {
+			return nil, fmt.Errorf("expected : after key in %s", f.s)
+		}
+		f.pos++
+		v, err := f.value()
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, yamlPair{fmt.Sprint(k), v})
+		f.skipSpace()
+		if f.pos == len(f.s) {
+			return nil, fmt.Errorf("missing } in %s", f.s)
+		}
+		if f.s[f.pos] == ',' {
+			f.pos++
+		} else if f.s[f.pos] != '}' {
+			return nil, fmt.Errorf("expected , or } in %s", f.s)
+		}
+	}
+}
+
+// yamlToJSON converts a parsed YAML value to the types of encoding/json,
+// i.e. mappings to map[string]interface{}.
+func yamlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yamlMapping:
+		m := make(map[string]interface{}, len(v))
+		for _, p := range v {
+			m[p.Key] = yamlToJSON(p.Value)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = yamlToJSON(item)
+		}
+		return items
+	}
+	return v
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want string
+	}{
+		{"a: 1\nb: true\nc: ~\nd: hello world\ne: '0x10'\nf: \"tab\\tx\"", `{"a":1,"b":true,"c":null,"d":"hello world","e":"0x10","f":"tab\tx"}`},
+		{"- 1.5\n- -2\n- 1e3\n- yes\n- off", `[1.5,-2,1e3,true,false]`},
+		{"items:\n  - one\n  - two: 2\n    three: 3\n  -\n    - nested", `{"items":["one",{"three":3,"two":2},["nested"]]}`},
+		{"items:\n- a\n- b\nnext: c", `{"items":["a","b"],"next":"c"}`},
+		{"flow: [a, 'b, c', {x: 1, y: [2, 3]}]\nmap: {k: v}", `{"flow":["a","b, c",{"x":1,"y":[2,3]}],"map":{"k":"v"}}`},
+		{"text: |\n  line 1\n  line 2\n\nfolded: >\n  a\n  b\nnext: x", `{"folded":"a b\n","next":"x","text":"line 1\nline 2\n"}`},
+		{"# comment\n---\nkey: value # trailing\nurl: http://x#y\nq: 'it''s'", `{"key":"value","q":"it's","url":"http://x#y"}`},
+		{"key: value\r\n...\r\n", `{"key":"value"}`},
+	} {
+		v, err := parseYAML(tt.in)
+		if err != nil {
+			t.Errorf("parseYAML(%q): %v", tt.in, err)
+			continue
+		}
+		got, err := json.Marshal(yamlToJSON(v))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("parseYAML(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseYAMLOrder(t *testing.T) {
+	v, err := parseYAML("z: 1\na: 2\nm: 3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _ := v.(yamlMapping)
+	var keys []string
+	for _, p := range m {
+		keys = append(keys, p.Key)
+	}
+	if got := strings.Join(keys, ","); got != "z,a,m" {
+		t.Errorf("keys = %s, want document order z,a,m", got)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want string
+	}{
+		{"a: 1\n  b: 2", "YAML line 2: unexpected indentation"},
+		{"a: 1\na: 2", `YAML line 2: duplicate key "a"`},
+		{"a: [1, 2", "YAML line 1: missing ]"},
+		{"a: \"unterminated", "YAML line 1: unterminated quoted string"},
+		{"\ta: 1", "YAML line 1: tabs are not allowed"},
+		{"a: 1\n- b", `YAML line 2: expected "key: value"`},
+	} {
+		_, err := parseYAML(tt.in)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+			t.Errorf("parseYAML(%q) error = %v, want %s", tt.in, err, tt.want)
+		}
+	}
+}